@@ -0,0 +1,288 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package abi computes how the parameters and results of a Go function
+// are assigned to registers and stack slots under the register-based
+// internal calling convention, ABIInternal.
+//
+// The convention is described in cmd/compile/abi-internal.md. This
+// package implements the assignment algorithm only; code generation
+// still uses the stack-based ABI0 for all functions.
+package abi
+
+import (
+	"bytes"
+	"cmd/compile/internal/types"
+	"fmt"
+)
+
+// A Config describes the registers available for passing arguments
+// and results on one architecture.
+type Config struct {
+	PtrSize int64
+	// Names of the integer and floating-point argument registers,
+	// in assignment order.
+	IntRegs   []string
+	FloatRegs []string
+}
+
+// AMD64 is the ABIInternal configuration for amd64.
+var AMD64 = &Config{
+	PtrSize:   8,
+	IntRegs:   []string{"AX", "BX", "CX", "DI", "SI", "R8", "R9", "R10", "R11"},
+	FloatRegs: []string{"X0", "X1", "X2", "X3", "X4", "X5", "X6", "X7", "X8", "X9", "X10", "X11", "X12", "X13", "X14"},
+}
+
+// ARM64 is the ABIInternal configuration for arm64.
+var ARM64 = &Config{
+	PtrSize:   8,
+	IntRegs:   []string{"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7", "R8", "R9", "R10", "R11", "R12", "R13", "R14", "R15"},
+	FloatRegs: []string{"F0", "F1", "F2", "F3", "F4", "F5", "F6", "F7", "F8", "F9", "F10", "F11", "F12", "F13", "F14", "F15"},
+}
+
+// ForArch returns the ABIInternal configuration for the named
+// architecture, or nil if the architecture uses only ABI0.
+func ForArch(arch string) *Config {
+	switch arch {
+	case "amd64":
+		return AMD64
+	case "arm64":
+		return ARM64
+	}
+	return nil
+}
+
+// A Reg identifies an argument register. Integer registers are
+// numbered from 0; floating-point register i is numbered
+// len(IntRegs)+i.
+type Reg uint8
+
+// A ParamAssignment describes where a single parameter or result is
+// passed.
+type ParamAssignment struct {
+	Type *types.Type
+
+	// Registers holds the registers the value is assigned to, in
+	// the order of its register-sized pieces. It is nil if the value
+	// is passed on the stack or has no register-sized pieces.
+	Registers []Reg
+
+	// Offset is the value's offset in the stack argument area if
+	// it is stack-assigned, or in the spill area if it is
+	// register-assigned.
+	Offset int64
+
+	stack bool
+}
+
+// OnStack reports whether the value is passed on the stack.
+func (p *ParamAssignment) OnStack() bool {
+	return p.stack
+}
+
+// A FuncAssignment describes where all the parameters and results of a
+// function are passed.
+type FuncAssignment struct {
+	config *Config
+
+	// Params includes the receiver, if any, as its first element.
+	Params  []ParamAssignment
+	Results []ParamAssignment
+
+	// ArgSize is the size of the stack argument area, which holds
+	// the stack-assigned parameters followed by the stack-assigned
+	// results. SpillSize is the size of the spill area that follows
+	// it, reserved for register-assigned parameters.
+	ArgSize   int64
+	SpillSize int64
+}
+
+// AssignFunc computes the assignment of the receiver, parameters and
+// results of the function type fn.
+func (c *Config) AssignFunc(fn *types.Type) *FuncAssignment {
+	var params, results []*types.Type
+	if r := fn.Recv(); r != nil {
+		params = append(params, r.Type)
+	}
+	for _, f := range fn.Params().FieldSlice() {
+		params = append(params, f.Type)
+	}
+	for _, f := range fn.Results().FieldSlice() {
+		results = append(results, f.Type)
+	}
+	return c.Assign(params, results)
+}
+
+// Assign computes the assignment of the given parameters and results.
+// A receiver is passed as the first parameter.
+func (c *Config) Assign(params, results []*types.Type) *FuncAssignment {
+	a := &FuncAssignment{config: c}
+	var off int64
+	a.Params, off = c.assignList(params, off)
+	off = rnd(off, c.PtrSize)
+	a.Results, off = c.assignList(results, off)
+	a.ArgSize = rnd(off, c.PtrSize)
+
+	// Reserve spill slots for the register-assigned parameters.
+	var spill int64
+	for i := range a.Params {
+		p := &a.Params[i]
+		if p.stack {
+			continue
+		}
+		spill = rnd(spill, p.Type.Alignment())
+		p.Offset = spill
+		spill += p.Type.Size()
+	}
+	a.SpillSize = rnd(spill, c.PtrSize)
+	return a
+}
+
+// assignList assigns ts, starting with all registers free and the
+// stack area at offset off. It returns the new stack offset.
+func (c *Config) assignList(ts []*types.Type, off int64) ([]ParamAssignment, int64) {
+	s := assignState{config: c}
+	var as []ParamAssignment
+	for _, t := range ts {
+		p := ParamAssignment{Type: t}
+		if regs, ok := s.tryRegs(t); ok {
+			p.Registers = regs
+		} else {
+			p.stack = true
+			off = rnd(off, t.Alignment())
+			p.Offset = off
+			off += t.Size()
+		}
+		as = append(as, p)
+	}
+	return as, off
+}
+
+// assignState tracks the next free integer and floating-point
+// registers.
+type assignState struct {
+	config     *Config
+	nint, nflt int
+	regs       []Reg
+}
+
+// tryRegs tries to assign t to registers. If t does not fit, the
+// state is left unchanged.
+func (s *assignState) tryRegs(t *types.Type) ([]Reg, bool) {
+	nint, nflt := s.nint, s.nflt
+	s.regs = nil
+	if !s.regAssign(t) {
+		s.nint, s.nflt = nint, nflt
+		return nil, false
+	}
+	return s.regs, true
+}
+
+func (s *assignState) intReg() bool {
+	if s.nint >= len(s.config.IntRegs) {
+		return false
+	}
+	s.regs = append(s.regs, Reg(s.nint))
+	s.nint++
+	return true
+}
+
+func (s *assignState) floatReg() bool {
+	if s.nflt >= len(s.config.FloatRegs) {
+		return false
+	}
+	s.regs = append(s.regs, Reg(len(s.config.IntRegs)+s.nflt))
+	s.nflt++
+	return true
+}
+
+// regAssign assigns the register-sized pieces of t, reporting whether
+// there were enough registers.
+func (s *assignState) regAssign(t *types.Type) bool {
+	switch t.Etype {
+	case types.TBOOL, types.TINT8, types.TUINT8, types.TINT16, types.TUINT16,
+		types.TINT32, types.TUINT32, types.TINT, types.TUINT, types.TUINTPTR,
+		types.TPTR, types.TUNSAFEPTR, types.TMAP, types.TCHAN, types.TFUNC:
+		return s.intReg()
+	case types.TINT64, types.TUINT64:
+		if s.config.PtrSize < 8 {
+			// Two registers, least significant half first.
+			return s.intReg() && s.intReg()
+		}
+		return s.intReg()
+	case types.TFLOAT32, types.TFLOAT64:
+		return s.floatReg()
+	case types.TCOMPLEX64, types.TCOMPLEX128:
+		return s.floatReg() && s.floatReg()
+	case types.TSTRING:
+		return s.intReg() && s.intReg()
+	case types.TINTER:
+		return s.intReg() && s.intReg()
+	case types.TSLICE:
+		return s.intReg() && s.intReg() && s.intReg()
+	case types.TSTRUCT:
+		for _, f := range t.FieldSlice() {
+			if !s.regAssign(f.Type) {
+				return false
+			}
+		}
+		return true
+	case types.TARRAY:
+		switch t.NumElem() {
+		case 0:
+			return true
+		case 1:
+			return s.regAssign(t.Elem())
+		}
+		return false
+	}
+	return false
+}
+
+// RegName returns the name of register r.
+func (c *Config) RegName(r Reg) string {
+	if int(r) < len(c.IntRegs) {
+		return c.IntRegs[r]
+	}
+	return c.FloatRegs[int(r)-len(c.IntRegs)]
+}
+
+// String returns a human-readable description of a, such as
+//
+//	params: AX BX, [0+8]; results: AX; args 16 spill 16
+func (a *FuncAssignment) String() string {
+	var b bytes.Buffer
+	list := func(ps []ParamAssignment) {
+		for i := range ps {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			p := &ps[i]
+			if p.stack {
+				fmt.Fprintf(&b, "[%d+%d]", p.Offset, p.Type.Size())
+				continue
+			}
+			if len(p.Registers) == 0 {
+				b.WriteString("-")
+			}
+			for j, r := range p.Registers {
+				if j > 0 {
+					b.WriteString(" ")
+				}
+				b.WriteString(a.config.RegName(r))
+			}
+		}
+	}
+	b.WriteString("params: ")
+	list(a.Params)
+	b.WriteString("; results: ")
+	list(a.Results)
+	fmt.Fprintf(&b, "; args %d spill %d", a.ArgSize, a.SpillSize)
+	return b.String()
+}
+
+// rnd rounds o up to a multiple of r, which must be a power of 2.
+func rnd(o, r int64) int64 {
+	return (o + r - 1) &^ (r - 1)
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package abi
+
+import (
+	"cmd/compile/internal/types"
+	"testing"
+)
+
+func init() {
+	// Initialize just enough of the types package for the tests.
+	types.Widthptr = 8
+	types.Dowidth = testDowidth
+}
+
+// testDowidth is a minimal version of the compiler's size calculation,
+// for 64-bit systems.
+func testDowidth(t *types.Type) {
+	if t.WidthCalculated() {
+		return
+	}
+	var w, a int64
+	switch t.Etype {
+	case types.TBOOL, types.TINT8, types.TUINT8:
+		w = 1
+	case types.TINT16, types.TUINT16:
+		w = 2
+	case types.TINT32, types.TUINT32, types.TFLOAT32:
+		w = 4
+	case types.TINT64, types.TUINT64, types.TINT, types.TUINT, types.TUINTPTR,
+		types.TFLOAT64, types.TPTR, types.TUNSAFEPTR, types.TMAP, types.TCHAN, types.TFUNC:
+		w = 8
+	case types.TCOMPLEX64:
+		w, a = 8, 4
+	case types.TCOMPLEX128:
+		w, a = 16, 8
+	case types.TSTRING, types.TINTER:
+		w, a = 16, 8
+	case types.TSLICE:
+		w, a = 24, 8
+	case types.TARRAY:
+		testDowidth(t.Elem())
+		w, a = t.NumElem()*t.Elem().Width, int64(t.Elem().Align)
+	case types.TSTRUCT:
+		a = 1
+		for _, f := range t.FieldSlice() {
+			testDowidth(f.Type)
+			w = rnd(w, int64(f.Type.Align))
+			f.Offset = w
+			w += f.Type.Width
+			if int64(f.Type.Align) > a {
+				a = int64(f.Type.Align)
+			}
+		}
+		w = rnd(w, a)
+	default:
+		panic("testDowidth: unexpected type")
+	}
+	if a == 0 {
+		a = w
+	}
+	if a == 0 {
+		a = 1
+	}
+	t.Width = w
+	t.Align = uint8(a)
+}
+
+func tstruct(fields ...*types.Type) *types.Type {
+	t := types.New(types.TSTRUCT)
+	var fs []*types.Field
+	for _, ft := range fields {
+		f := types.NewField()
+		f.Type = ft
+		fs = append(fs, f)
+	}
+	t.SetFields(fs)
+	return t
+}
+
+func repeat(t *types.Type, n int) []*types.Type {
+	var ts []*types.Type
+	for i := 0; i < n; i++ {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+func TestAssign(t *testing.T) {
+	var (
+		tint        = types.New(types.TINT)
+		tint8       = types.New(types.TINT8)
+		tbool       = types.New(types.TBOOL)
+		tfloat32    = types.New(types.TFLOAT32)
+		tfloat64    = types.New(types.TFLOAT64)
+		tcomplex128 = types.New(types.TCOMPLEX128)
+		tstring     = types.New(types.TSTRING)
+		terror      = types.New(types.TINTER)
+		tslice      = types.NewSlice(tint)
+		tptr        = types.NewPtr(tint)
+	)
+
+	tests := []struct {
+		name            string
+		params, results []*types.Type
+		want            string
+	}{
+		{
+			name:    "basic",
+			params:  []*types.Type{tint, tfloat64, tstring},
+			results: []*types.Type{tbool, terror},
+			want:    "params: AX, X0, BX CX; results: AX, BX CX; args 0 spill 32",
+		},
+		{
+			name:   "complex and slice",
+			params: []*types.Type{tcomplex128, tslice, tptr},
+			want:   "params: X0 X1, AX BX CX, DI; results: ; args 0 spill 48",
+		},
+		{
+			name:   "struct",
+			params: []*types.Type{tstruct(tint8, tfloat32, types.NewArray(tstring, 1))},
+			want:   "params: AX X0 BX CX; results: ; args 0 spill 24",
+		},
+		{
+			name:    "array on stack",
+			params:  []*types.Type{types.NewArray(tint, 2), tint},
+			results: []*types.Type{types.NewArray(tint8, 3)},
+			want:    "params: [0+16], AX; results: [16+3]; args 24 spill 8",
+		},
+		{
+			name:   "zero-sized",
+			params: []*types.Type{tstruct(), types.NewArray(tint, 0)},
+			want:   "params: -, -; results: ; args 0 spill 0",
+		},
+		{
+			name:   "out of int registers",
+			params: repeat(tint, 10),
+			want:   "params: AX, BX, CX, DI, SI, R8, R9, R10, R11, [0+8]; results: ; args 8 spill 72",
+		},
+		{
+			// The string does not fit in the one remaining register,
+			// but the int after it does.
+			name:   "partial fit",
+			params: append(repeat(tint, 8), tstring, tint),
+			want:   "params: AX, BX, CX, DI, SI, R8, R9, R10, [0+16], R11; results: ; args 16 spill 72",
+		},
+		{
+			name:   "struct does not fit",
+			params: append(repeat(tfloat64, 14), tstruct(tfloat64, tfloat64), tfloat64),
+			want:   "params: X0, X1, X2, X3, X4, X5, X6, X7, X8, X9, X10, X11, X12, X13, [0+16], X14; results: ; args 16 spill 120",
+		},
+	}
+	for _, tt := range tests {
+		got := AMD64.Assign(tt.params, tt.results).String()
+		if got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestARM64(t *testing.T) {
+	tint := types.New(types.TINT)
+	got := ARM64.Assign(repeat(tint, 17), []*types.Type{tint}).String()
+	want := "params: R0, R1, R2, R3, R4, R5, R6, R7, R8, R9, R10, R11, R12, R13, R14, R15, [0+8]; results: R0; args 8 spill 128"
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -544,7 +544,7 @@ func tostr(v Val) Val {
 		if u.Cmp(minintval[TUINT32]) >= 0 && u.Cmp(maxintval[TUINT32]) <= 0 {
 			i = u.Int64()
 		}
-		v.U = string(i)
+		v.U = string(rune(i))
 	}
 
 	return v
@@ -5,6 +5,7 @@
 package gc
 
 import (
+	"cmd/compile/internal/logopt"
 	"cmd/compile/internal/types"
 	"fmt"
 	"strconv"
@@ -492,6 +493,21 @@ func (e *Escape) paramTag(fn *Node, narg int, f *types.Field) string {
 			}
 		}
 	}
+	if logopt.Enabled() && !loc.escapes {
+		if esc == EscHeap {
+			logopt.LogOpt(f.Pos, "leak", "escape", fn.funcname(), "leaking param: "+name())
+		} else {
+			if esc&EscContentEscapes != 0 {
+				logopt.LogOpt(f.Pos, "leak", "escape", fn.funcname(), "leaking param content: "+name())
+			}
+			for i := 0; i < numEscReturns; i++ {
+				if x := getEscReturn(esc, i); x >= 0 {
+					res := fn.Type.Results().Field(i).Sym
+					logopt.LogOpt(f.Pos, "leak", "escape", fn.funcname(), fmt.Sprintf("leaking param: %v to result %v level=%d", name(), res, x))
+				}
+			}
+		}
+	}
 
 	return mktag(int(esc))
 }
@@ -5,8 +5,10 @@
 package gc
 
 import (
+	"cmd/compile/internal/logopt"
 	"cmd/compile/internal/types"
 	"fmt"
+	"strings"
 )
 
 // Escape analysis.
@@ -105,6 +107,11 @@ type EscLocation struct {
 	derefs  int // >= -1
 	walkgen uint32
 
+	// dst and dstEdgeIdx record the edge by which walkOne reached
+	// this location, for explaining escapes with -json.
+	dst        *EscLocation
+	dstEdgeIdx int
+
 	// queued is used by walkAll to track whether this location is
 	// in the walk queue.
 	queued bool
@@ -122,6 +129,10 @@ type EscLocation struct {
 	// paramEsc records the represented parameter's escape tags.
 	// See "Parameter tags" below for details.
 	paramEsc uint16
+
+	// explanation describes why the location escapes, when
+	// logging with -json.
+	explanation []*logopt.LoggedOpt
 }
 
 // An EscEdge represents an assignment edge between two Go variables.
@@ -1037,6 +1048,11 @@ func (e *Escape) newLoc(n *Node, transient bool) *EscLocation {
 
 		if mustHeapAlloc(n) && !loc.isName(PPARAM) && !loc.isName(PPARAMOUT) {
 			loc.escapes = true
+			if logopt.Enabled() {
+				loc.explanation = []*logopt.LoggedOpt{
+					logopt.NewLoggedOpt(n.Pos, "escflow", "escape", e.curfn.funcname(), "too large for stack"),
+				}
+			}
 		}
 	}
 	return loc
@@ -1061,6 +1077,9 @@ func (e *Escape) flow(k EscHole, src *EscLocation) {
 	}
 	if dst.escapes && k.derefs < 0 { // dst = &src
 		src.escapes = true
+		if logopt.Enabled() {
+			src.explanation = []*logopt.LoggedOpt{e.explainFlow(dst, src, k.derefs)}
+		}
 		return
 	}
 
@@ -1157,12 +1176,15 @@ func (e *Escape) walkOne(root *EscLocation, walkgen uint32, enqueue func(*EscLoc
 			// allocated.
 			if addressOf && !l.escapes {
 				l.escapes = true
+				if logopt.Enabled() {
+					l.explanation = e.explainPath(root, l)
+				}
 				enqueue(l)
 				continue
 			}
 		}
 
-		for _, edge := range l.edges {
+		for i, edge := range l.edges {
 			if edge.src.escapes {
 				continue
 			}
@@ -1170,12 +1192,57 @@ func (e *Escape) walkOne(root *EscLocation, walkgen uint32, enqueue func(*EscLoc
 			if edge.src.walkgen != walkgen || edge.src.derefs > derefs {
 				edge.src.walkgen = walkgen
 				edge.src.derefs = derefs
+				edge.src.dst = l
+				edge.src.dstEdgeIdx = i
 				todo = append(todo, edge.src)
 			}
 		}
 	}
 }
 
+// explainPath returns the chain of assignments, recorded by walkOne,
+// through which src's address flows to root.
+func (e *Escape) explainPath(root, src *EscLocation) []*logopt.LoggedOpt {
+	var explanation []*logopt.LoggedOpt
+	seen := make(map[*EscLocation]bool)
+	for l := src; l != root && l.dst != nil && !seen[l]; l = l.dst {
+		seen[l] = true
+		edge := l.dst.edges[l.dstEdgeIdx]
+		explanation = append(explanation, e.explainFlow(l.dst, l, edge.derefs))
+	}
+	return explanation
+}
+
+// explainFlow describes the assignment "dst = src" with the given
+// dereferences, for example "{heap} = &x".
+func (e *Escape) explainFlow(dst, src *EscLocation, derefs int) *logopt.LoggedOpt {
+	ops := "&"
+	if derefs >= 0 {
+		ops = strings.Repeat("*", derefs)
+	}
+	pos := src.curfn.Pos
+	if src.n != nil {
+		pos = src.n.Pos
+	} else if dst.n != nil {
+		pos = dst.n.Pos
+	}
+	msg := fmt.Sprintf("%s = %s%s", e.explainLoc(dst), ops, e.explainLoc(src))
+	return logopt.NewLoggedOpt(pos, "escflow", "escape", src.curfn.funcname(), msg)
+}
+
+func (e *Escape) explainLoc(l *EscLocation) string {
+	if l == &e.heapLoc {
+		return "{heap}"
+	}
+	if l.n == nil {
+		return "{temp}"
+	}
+	if l.n.Op == ONAME {
+		return fmt.Sprintf("%v", l.n)
+	}
+	return fmt.Sprintf("{storage for %v}", l.n)
+}
+
 // outlives reports whether values stored in l may survive beyond
 // other's lifetime if stack allocated.
 func (e *Escape) outlives(l, other *EscLocation) bool {
@@ -1303,6 +1370,9 @@ func (e *Escape) finish(fns []*Node) {
 			if Debug['m'] != 0 && n.Op != ONAME {
 				Warnl(n.Pos, "%S escapes to heap", n)
 			}
+			if logopt.Enabled() {
+				logopt.LogOptExplained(n.Pos, "escape", "escape", loc.curfn.funcname(), loc.explanation, fmt.Sprint(n))
+			}
 			n.Esc = EscHeap
 			addrescapes(n)
 		} else {
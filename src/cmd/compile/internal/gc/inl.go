@@ -27,6 +27,7 @@
 package gc
 
 import (
+	"cmd/compile/internal/logopt"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
 	"cmd/internal/src"
@@ -115,10 +116,13 @@ func caninl(fn *Node) {
 	}
 
 	var reason string // reason, if any, that the function was not inlined
-	if Debug['m'] > 1 {
+	if Debug['m'] > 1 || logopt.Enabled() {
 		defer func() {
 			if reason != "" {
-				fmt.Printf("%v: cannot inline %v: %s\n", fn.Line(), fn.Func.Nname, reason)
+				if Debug['m'] > 1 {
+					fmt.Printf("%v: cannot inline %v: %s\n", fn.Line(), fn.Func.Nname, reason)
+				}
+				logopt.LogOpt(fn.Pos, "cannotInlineFunction", "inline", fn.funcname(), reason)
 			}
 		}()
 	}
@@ -188,8 +192,9 @@ func caninl(fn *Node) {
 	// locals, and we use this map to produce a pruned Inline.Dcl
 	// list. See issue 25249 for more context.
 
+	budget := pgoInlineBudget(n)
 	visitor := hairyVisitor{
-		budget:        inlineMaxBudget,
+		budget:        budget,
 		extraCallCost: cc,
 		usedLocals:    make(map[*Node]bool),
 	}
@@ -198,12 +203,12 @@ func caninl(fn *Node) {
 		return
 	}
 	if visitor.budget < 0 {
-		reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", inlineMaxBudget-visitor.budget, inlineMaxBudget)
+		reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", budget-visitor.budget, budget)
 		return
 	}
 
 	n.Func.Inl = &Inline{
-		Cost: inlineMaxBudget - visitor.budget,
+		Cost: budget - visitor.budget,
 		Dcl:  inlcopylist(pruneUnusedAutos(n.Name.Defn.Func.Dcl, &visitor)),
 		Body: inlcopylist(fn.Nbody.Slice()),
 	}
@@ -217,6 +222,9 @@ func caninl(fn *Node) {
 	} else if Debug['m'] != 0 {
 		fmt.Printf("%v: can inline %v\n", fn.Line(), n)
 	}
+	if logopt.Enabled() {
+		logopt.LogOpt(fn.Pos, "canInlineFunction", "inline", fn.funcname(), fmt.Sprintf("cost: %d", n.Func.Inl.Cost))
+	}
 }
 
 // inlFlood marks n's inline body for export and recursively ensures
@@ -693,6 +701,11 @@ func inlnode(n *Node, maxCost int32) *Node {
 		}
 
 		n = mkinlcall(n, asNode(n.Left.Type.FuncType().Nname), maxCost)
+
+	case OCALLINTER:
+		if pgoProfile != nil && Debug_pgodevirtualize != 0 && !n.NoInline() {
+			n = pgoDevirtualize(n, maxCost)
+		}
 	}
 
 	lineno = lno
@@ -820,7 +833,10 @@ func mkinlcall(n, fn *Node, maxCost int32) *Node {
 	if fn.Func.Inl.Cost > maxCost {
 		// The inlined function body is too big. Typically we use this check to restrict
 		// inlining into very big functions.  See issue 26546 and 17566.
-		return n
+		// Hot call sites in the profile get a larger budget.
+		if !pgoHotInline(n, fn) {
+			return n
+		}
 	}
 
 	if fn == Curfn || fn.Name.Defn == Curfn {
@@ -848,6 +864,9 @@ func mkinlcall(n, fn *Node, maxCost int32) *Node {
 	} else if Debug['m'] != 0 {
 		fmt.Printf("%v: inlining call to %v\n", n.Line(), fn)
 	}
+	if logopt.Enabled() {
+		logopt.LogOpt(n.Pos, "inlineCall", "inline", Curfn.funcname(), fmt.Sprint(fn))
+	}
 	if Debug['m'] > 2 {
 		fmt.Printf("%v: Before inlining: %+v\n", n.Line(), n)
 	}
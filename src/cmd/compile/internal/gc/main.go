@@ -9,6 +9,7 @@ package gc
 import (
 	"bufio"
 	"bytes"
+	"cmd/compile/internal/logopt"
 	"cmd/compile/internal/ssa"
 	"cmd/compile/internal/types"
 	"cmd/internal/bio"
@@ -52,6 +53,11 @@ var (
 	Debug_typecheckinl int
 	Debug_gendwarfinl  int
 	Debug_softfloat    int
+	Debug_abiinfo      int
+
+	Debug_pgoinlinebudget       = 2000
+	Debug_pgoinlinecdfthreshold = 99
+	Debug_pgodevirtualize       = 1
 )
 
 // Debug arguments.
@@ -81,6 +87,10 @@ var debugtab = []struct {
 	{"typecheckinl", "eager typechecking of inline function bodies", &Debug_typecheckinl},
 	{"dwarfinl", "print information about DWARF inlined function creation", &Debug_gendwarfinl},
 	{"softfloat", "force compiler to emit soft-float code", &Debug_softfloat},
+	{"abiinfo", "print register ABI assignment of function parameters and results", &Debug_abiinfo},
+	{"pgoinlinebudget", "inline budget for hot functions when using a profile", &Debug_pgoinlinebudget},
+	{"pgoinlinecdfthreshold", "percentage of profile call edge weight considered hot", &Debug_pgoinlinecdfthreshold},
+	{"pgodevirtualize", "devirtualize hot interface calls when using a profile (0 to disable)", &Debug_pgodevirtualize},
 }
 
 const debugHelpHeader = `usage: -d arg[,arg]* and arg is <key>[=<value>]
@@ -222,6 +232,7 @@ func Main(archInit func(*Arch)) {
 	objabi.Flagfn1("importcfg", "read import configuration from `file`", readImportCfg)
 	flag.StringVar(&flag_installsuffix, "installsuffix", "", "set pkg directory `suffix`")
 	objabi.Flagcount("j", "debug runtime-initialized variables", &Debug['j'])
+	objabi.Flagfn1("json", "log optimization decisions as JSON; `value` is version,destination", setJSONLogging)
 	objabi.Flagcount("l", "disable inlining", &Debug['l'])
 	flag.StringVar(&flag_lang, "lang", "", "release to compile for")
 	flag.StringVar(&linkobj, "linkobj", "", "write linker-specific object to `file`")
@@ -241,6 +252,7 @@ func Main(archInit func(*Arch)) {
 	if enableTrace {
 		flag.BoolVar(&trace, "t", false, "trace type-checking")
 	}
+	flag.StringVar(&pgoprofile, "pgoprofile", "", "read CPU profile from `file` for profile-guided optimization")
 	flag.StringVar(&pathPrefix, "trimpath", "", "remove `prefix` from recorded source file paths")
 	flag.BoolVar(&Debug_vlog, "v", false, "increase debug verbosity")
 	objabi.Flagcount("w", "debug type checking", &Debug['w'])
@@ -444,6 +456,10 @@ func Main(archInit func(*Arch)) {
 		thearch.SoftFloat = true
 	}
 
+	if pgoprofile != "" {
+		readPGOProfile(pgoprofile)
+	}
+
 	// enable inlining.  for now:
 	//	default: inlining on.  (debug['l'] == 1)
 	//	-l: inlining off  (debug['l'] == 0)
@@ -727,6 +743,9 @@ func Main(archInit func(*Arch)) {
 	if asmhdr != "" {
 		dumpasmhdr()
 	}
+	if err := logopt.FlushLoggedOpts(Ctxt, myimportpath); err != nil {
+		log.Fatalf("writing -json output: %v", err)
+	}
 
 	// Check whether any of the functions we have compiled have gigantic stack frames.
 	sort.Slice(largeStackFrames, func(i, j int) bool {
@@ -798,6 +817,12 @@ func addImportMap(s string) {
 	importMap[source] = actual
 }
 
+func setJSONLogging(s string) {
+	if err := logopt.LogJsonOption(s); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func readImportCfg(file string) {
 	packageFile = map[string]string{}
 	data, err := ioutil.ReadFile(file)
@@ -5,6 +5,7 @@
 package gc
 
 import (
+	"cmd/compile/internal/abi"
 	"cmd/compile/internal/ssa"
 	"cmd/compile/internal/types"
 	"cmd/internal/dwarf"
@@ -252,6 +253,12 @@ func compile(fn *Node) {
 		return
 	}
 
+	if Debug_abiinfo != 0 {
+		if c := abi.ForArch(thearch.LinkArch.Name); c != nil {
+			fmt.Printf("%v: ABIInternal for %v: %v\n", fn.Line(), fn.funcname(), c.AssignFunc(fn.Type))
+		}
+	}
+
 	// Set up the function's LSym early to avoid data races with the assemblers.
 	fn.Func.initLSym(true)
 
@@ -323,6 +330,7 @@ func compileSSA(fn *Node, worker int) {
 	pp.Flush() // assemble, fill in boilerplate, etc.
 	// fieldtrack must be called after pp.Flush. See issue 20014.
 	fieldtrack(pp.Text.From.Sym, fn.Func.FieldTrack)
+	namedmethods(pp.Text.From.Sym, fn.Func.NamedMethods)
 }
 
 func init() {
@@ -763,6 +771,25 @@ func fieldtrack(fnsym *obj.LSym, tracked map[*types.Sym]struct{}) {
 	}
 }
 
+// namedmethods adds an R_USENAMEDMETHOD relocation to fnsym for each
+// method name in names, which fnsym passes to MethodByName.
+func namedmethods(fnsym *obj.LSym, names map[*obj.LSym]struct{}) {
+	if fnsym == nil || len(names) == 0 {
+		return
+	}
+
+	syms := make([]*obj.LSym, 0, len(names))
+	for s := range names {
+		syms = append(syms, s)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Name < syms[j].Name })
+	for _, s := range syms {
+		r := obj.Addrel(fnsym)
+		r.Sym = s
+		r.Type = objabi.R_USENAMEDMETHOD
+	}
+}
+
 type symByName []*types.Sym
 
 func (a symByName) Len() int           { return len(a) }
@@ -0,0 +1,259 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Profile-guided optimization.
+//
+// When a CPU profile is supplied with -pgoprofile, the compiler uses it to
+//
+//	- inline hot functions with a larger budget (-d pgoinlinebudget)
+//	  at hot call sites,
+//	- devirtualize hot interface method calls whose profiled target is a
+//	  method of a type in the package being compiled, guarded by a type
+//	  assertion, and
+//	- lay out the basic blocks of hot paths first.
+//
+// A call edge is hot if it is among the heaviest edges that together
+// account for -d pgoinlinecdfthreshold percent of the profile's call
+// edge weight.
+
+package gc
+
+import (
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/types"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+	"fmt"
+	"strings"
+)
+
+var (
+	pgoprofile string       // -pgoprofile flag
+	pgoProfile *pgo.Profile // profile read from pgoprofile, if any
+)
+
+func readPGOProfile(file string) {
+	p, err := pgo.Open(file, float64(Debug_pgoinlinecdfthreshold))
+	if err != nil {
+		Fatalf("reading profile: %v", err)
+	}
+	pgoProfile = p
+}
+
+// pgoLinkName returns the symbol name the runtime reports for the
+// linker symbol name, which uses "" for the package being compiled.
+func pgoLinkName(name string) string {
+	if strings.HasPrefix(name, `"".`) {
+		return objabi.PathToPrefix(myimportpath) + name[2:]
+	}
+	return name
+}
+
+// pgoSymName returns the name under which the function named by s
+// appears in profiles.
+func pgoSymName(s *types.Sym) string {
+	return pgoLinkName(s.Linksym().Name)
+}
+
+// pgoCallSite returns the profile call site of the call n in Curfn.
+// For calls in inlined bodies, the caller is the inlined function.
+func pgoCallSite(n *Node) pgo.CallSite {
+	pos := Ctxt.InnermostPos(n.Pos)
+	caller := pgoSymName(Curfn.Func.Nname.Sym)
+	if ix := pos.Base().InliningIndex(); ix >= 0 {
+		caller = pgoLinkName(Ctxt.InlTree.InlinedFunction(ix).Name)
+	}
+	return pgo.CallSite{Caller: caller, Line: int(pos.RelLine())}
+}
+
+// pgoInlineBudget returns the inlining budget for the function fn.
+func pgoInlineBudget(fn *Node) int32 {
+	if pgoProfile != nil && pgoProfile.HotCallee(pgoSymName(fn.Sym)) {
+		if Debug['m'] > 1 {
+			fmt.Printf("%v: hot function %v: inline budget %d\n", fn.Line(), fn, Debug_pgoinlinebudget)
+		}
+		return int32(Debug_pgoinlinebudget)
+	}
+	return inlineMaxBudget
+}
+
+// pgoHotInline reports whether the call n to fn is hot enough to be
+// inlined even though fn's cost exceeds the usual maximum.
+func pgoHotInline(n, fn *Node) bool {
+	if pgoProfile == nil || fn.Func.Inl.Cost > int32(Debug_pgoinlinebudget) {
+		return false
+	}
+	if !pgoProfile.HotCall(pgoCallSite(n), pgoSymName(fn.Sym)) {
+		return false
+	}
+	if Debug['m'] > 1 {
+		fmt.Printf("%v: hot call to %v: inlining with cost %d\n", n.Line(), fn, fn.Func.Inl.Cost)
+	}
+	return true
+}
+
+// pgoDevirtualize rewrites the interface method call n, if it is hot
+// and its hottest target is a method of a concrete type T declared in
+// this package, into
+//
+//	x, a1, ..., an := receiver, args...
+//	if t, ok := x.(T); ok {
+//		r1, ..., rm = t.M(a1, ..., an)
+//	} else {
+//		r1, ..., rm = x.M(a1, ..., an)
+//	}
+//
+// and inlines the direct call if possible. The result is an OINLCALL,
+// like those produced by mkinlcall.
+func pgoDevirtualize(n *Node, maxCost int32) *Node {
+	sel := n.Left
+	if sel.Op != ODOTINTER {
+		return n
+	}
+	args := n.List.Slice()
+	if len(args) == 1 && args[0].Type != nil && args[0].Type.IsFuncArgStruct() {
+		// f(g()) with multiple results; leave it alone.
+		return n
+	}
+	for _, arg := range args {
+		if arg.Type == nil {
+			return n
+		}
+	}
+
+	callees := pgoProfile.HotCallees(pgoCallSite(n))
+	if len(callees) == 0 {
+		return n
+	}
+	typ := pgoConcreteType(callees[0], sel.Sym, sel.Left.Type)
+	if typ == nil {
+		return n
+	}
+	if Debug['m'] != 0 {
+		fmt.Printf("%v: PGO devirtualizing %v to %v\n", n.Line(), sel, typ)
+	}
+
+	x := temp(sel.Left.Type)
+	body := []*Node{nod(OAS, x, sel.Left)}
+	var temps []*Node
+	for _, arg := range args {
+		t := temp(arg.Type)
+		body = append(body, nod(OAS, t, arg))
+		temps = append(temps, t)
+	}
+
+	var rets []*Node
+	if t := n.Type; t != nil {
+		if t.IsFuncArgStruct() {
+			for _, f := range t.FieldSlice() {
+				rets = append(rets, temp(f.Type))
+			}
+		} else {
+			rets = append(rets, temp(t))
+		}
+	}
+	call := func(recv *Node) (stmt, c *Node) {
+		c = nod(OCALL, nodSym(OXDOT, recv, sel.Sym), nil)
+		c.List.Set(append([]*Node(nil), temps...))
+		c.SetIsDDD(n.IsDDD())
+		switch len(rets) {
+		case 0:
+			return c, c
+		case 1:
+			return nod(OAS, rets[0], c), c
+		}
+		as := nod(OAS2, nil, nil)
+		as.List.Set(rets)
+		as.Rlist.Set1(c)
+		return as, c
+	}
+
+	t := temp(typ)
+	ok := temp(types.Types[TBOOL])
+	assert := nod(OAS2, nil, nil)
+	assert.List.Set2(t, ok)
+	assert.Rlist.Set1(nod(ODOTTYPE, x, typenod(typ)))
+
+	direct, _ := call(t)
+	fallback, fallbackCall := call(x)
+	// The fallback is still an interface call; don't devirtualize it again.
+	fallbackCall.SetNoInline(true)
+
+	nif := nod(OIF, ok, nil)
+	nif.Nbody.Set1(direct)
+	nif.Rlist.Set1(fallback)
+	body = append(body, assert, nif)
+	typecheckslice(body, ctxStmt)
+
+	inlnodelist(nif.Nbody, maxCost)
+	for _, n1 := range nif.Nbody.Slice() {
+		if n1.Op == OINLCALL {
+			inlconv2stmt(n1)
+		}
+	}
+
+	res := nod(OINLCALL, nil, nil)
+	res.Ninit.Set(n.Ninit.Slice())
+	res.Nbody.Set(body)
+	res.Rlist.Set(rets)
+	res.Type = n.Type
+	res.SetTypecheck(1)
+	return res
+}
+
+// pgoConcreteType returns the concrete type whose method the profile
+// symbol name refers to, if that type is declared in this package, the
+// method is named method, and the type implements iface.
+func pgoConcreteType(name string, method *types.Sym, iface *types.Type) *types.Type {
+	prefix := objabi.PathToPrefix(myimportpath) + "."
+	if !strings.HasPrefix(name, prefix) {
+		return nil
+	}
+	name = name[len(prefix):]
+
+	// Method symbols are named "(*T).M" or "T.M".
+	var tname, mname string
+	ptr := strings.HasPrefix(name, "(*")
+	if ptr {
+		i := strings.Index(name, ").")
+		if i < 0 {
+			return nil
+		}
+		tname, mname = name[len("(*"):i], name[i+len(")."):]
+	} else {
+		i := strings.Index(name, ".")
+		if i < 0 {
+			return nil
+		}
+		tname, mname = name[:i], name[i+len("."):]
+	}
+	if mname != method.Name {
+		return nil
+	}
+
+	d := asNode(localpkg.Lookup(tname).Def)
+	if d == nil || d.Op != OTYPE || d.Type == nil || d.Type.IsInterface() {
+		return nil
+	}
+	t := d.Type
+	if ptr {
+		t = types.NewPtr(t)
+	}
+	var missing, have *types.Field
+	var ptrRecv int
+	if !implements(t, iface, &missing, &have, &ptrRecv) {
+		return nil
+	}
+	return t
+}
+
+// pgoLineWeight returns the profile weight of the source line at pos.
+// It is used by SSA block layout.
+func pgoLineWeight(pos src.XPos) int64 {
+	p := Ctxt.InnermostPos(pos)
+	if !p.IsKnown() {
+		return 0
+	}
+	return pgoProfile.LineWeight(p.AbsFilename(), int(p.RelLine()))
+}
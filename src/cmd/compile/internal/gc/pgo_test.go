@@ -0,0 +1,167 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gc
+
+import (
+	"fmt"
+	"internal/testenv"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// pgoSrc is the program compiled by TestPGO. The markers in comments
+// identify the call sites referred to by the profile.
+var pgoSrc = `package main
+
+import "fmt"
+
+type Shape interface {
+	Area() int
+}
+
+type Square struct{ n int }
+
+func (s *Square) Area() int { return s.n * s.n }
+
+type Circle struct{ r int }
+
+func (c *Circle) Area() int { return 3 * c.r * c.r }
+
+func total(shapes []Shape) int {
+	t := 0
+	for _, s := range shapes {
+		t += s.Area() // AREA
+	}
+	return t
+}
+
+func big(x int) int {
+` + strings.Repeat("\tx = x*3 + 1\n\tx ^= x >> 7\n", 20) + `	return x
+}
+
+func main() {
+	shapes := []Shape{&Square{2}, &Circle{1}, &Square{3}}
+	fmt.Println(total(shapes))
+	fmt.Println(big(3)) // HOT
+	fmt.Println(big(4)) // COLD
+}
+`
+
+// pgoLine returns the line number of the marker in pgoSrc.
+func pgoLine(t *testing.T, marker string) int64 {
+	for i, line := range strings.Split(pgoSrc, "\n") {
+		if strings.HasSuffix(line, "// "+marker) {
+			return int64(i + 1)
+		}
+	}
+	t.Fatalf("marker %s not found", marker)
+	return 0
+}
+
+// writePGOProfile writes a CPU profile for pgoSrc in which the AREA
+// call almost always goes to (*Square).Area and big is hot only when
+// called from the HOT line.
+func writePGOProfile(t *testing.T, file, srcFile string) {
+	var functions []*profile.Function
+	fn := func(name string) *profile.Function {
+		f := &profile.Function{ID: uint64(len(functions) + 1), Name: name, Filename: srcFile}
+		functions = append(functions, f)
+		return f
+	}
+	fnMain, fnTotal, fnBig := fn("main.main"), fn("main.total"), fn("main.big")
+	fnSquare, fnCircle := fn("main.(*Square).Area"), fn("main.(*Circle).Area")
+
+	var locations []*profile.Location
+	loc := func(f *profile.Function, line int64) *profile.Location {
+		l := &profile.Location{ID: uint64(len(locations) + 1), Line: []profile.Line{{Function: f, Line: line}}}
+		locations = append(locations, l)
+		return l
+	}
+	area := loc(fnTotal, pgoLine(t, "AREA"))
+	hot := loc(fnMain, pgoLine(t, "HOT"))
+	cold := loc(fnMain, pgoLine(t, "COLD"))
+	square, circle, bigLoc := loc(fnSquare, 12), loc(fnCircle, 16), loc(fnBig, 30)
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{square, area}, Value: []int64{100}},
+			{Location: []*profile.Location{circle, area}, Value: []int64{1}},
+			{Location: []*profile.Location{bigLoc, hot}, Value: []int64{100}},
+			{Location: []*profile.Location{bigLoc, cold}, Value: []int64{1}},
+		},
+		Location: locations,
+		Function: functions,
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGO(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+
+	dir, err := ioutil.TempDir("", "TestPGO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(src, []byte(pgoSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prof := filepath.Join(dir, "cpu.pprof")
+	writePGOProfile(t, prof, src)
+
+	exe := filepath.Join(dir, "main.exe")
+	cmd := exec.Command(testenv.GoToolPath(t), "build", "-pgo="+prof, "-gcflags=-m -m", "-o", exe, src)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	want := []string{
+		fmt.Sprintf("main.go:%d:%d: PGO devirtualizing s.Area to *Square", pgoLine(t, "AREA"), 14),
+		fmt.Sprintf("main.go:%d:%d: inlining call to (*Square).Area", pgoLine(t, "AREA"), 14),
+		fmt.Sprintf("main.go:%d:%d: hot call to big: inlining with cost", pgoLine(t, "HOT"), 17),
+		"main.go:25:6: can inline big as",
+	}
+	for _, w := range want {
+		if !strings.Contains(string(out), w) {
+			t.Errorf("compiler output does not contain %q", w)
+		}
+	}
+	notWant := fmt.Sprintf("main.go:%d:17: inlining call to big", pgoLine(t, "COLD"))
+	if strings.Contains(string(out), notWant) {
+		t.Errorf("compiler output contains %q", notWant)
+	}
+	if t.Failed() {
+		t.Logf("compiler output:\n%s", out)
+	}
+
+	out, err = exec.Command(exe).CombinedOutput()
+	if err != nil {
+		t.Fatalf("running program failed: %v\n%s", err, out)
+	}
+	lines := strings.Split(string(out), "\n")
+	if len(lines) < 1 || lines[0] != "16" {
+		t.Errorf("program output:\n%s\nwant first line 16", out)
+	}
+}
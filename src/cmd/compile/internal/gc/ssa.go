@@ -206,6 +206,9 @@ func buildssa(fn *Node, worker int) *ssa.Func {
 	s.f.DebugTest = s.f.DebugHashMatch("GOSSAHASH", name)
 	s.f.Name = name
 	s.f.PrintOrHtmlSSA = printssa
+	if pgoProfile != nil {
+		s.f.ProfileWeight = pgoLineWeight
+	}
 	if fn.Func.Pragma&Nosplit != 0 {
 		s.f.NoSplit = true
 	}
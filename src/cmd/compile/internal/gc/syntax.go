@@ -477,13 +477,14 @@ type Func struct {
 	// function names.
 	Closgen int
 
-	FieldTrack map[*types.Sym]struct{}
-	DebugInfo  *ssa.FuncDebug
-	Ntype      *Node // signature
-	Top        int   // top context (ctxCallee, etc)
-	Closure    *Node // OCLOSURE <-> ODCLFUNC
-	Nname      *Node
-	lsym       *obj.LSym
+	FieldTrack   map[*types.Sym]struct{}
+	NamedMethods map[*obj.LSym]struct{} // names passed to MethodByName, as string symbols
+	DebugInfo    *ssa.FuncDebug
+	Ntype        *Node // signature
+	Top          int   // top context (ctxCallee, etc)
+	Closure      *Node // OCLOSURE <-> ODCLFUNC
+	Nname        *Node
+	lsym         *obj.LSym
 
 	Inl *Inline
 
@@ -528,7 +529,7 @@ const (
 	funcDupok         = 1 << iota // duplicate definitions ok
 	funcWrapper                   // is method wrapper
 	funcNeedctxt                  // function uses context register (has closure variables)
-	funcReflectMethod             // function calls reflect.Type.Method or MethodByName
+	funcReflectMethod             // function calls reflect.Type.Method, reflect.Value.Method or MethodByName
 	funcIsHiddenClosure
 	funcHasDefer            // contains a defer statement
 	funcNilCheckDisabled    // disable nil checks when compiling this function
@@ -6,6 +6,7 @@ package gc
 
 import (
 	"cmd/compile/internal/types"
+	"cmd/internal/obj"
 	"cmd/internal/objabi"
 	"cmd/internal/sys"
 	"encoding/binary"
@@ -567,7 +568,7 @@ opswitch:
 		n.SetAddable(true)
 
 	case OCALLINTER, OCALLFUNC, OCALLMETH:
-		if n.Op == OCALLINTER {
+		if n.Op == OCALLINTER || n.Op == OCALLMETH {
 			usemethod(n)
 		}
 
@@ -3621,16 +3622,39 @@ func bounded(n *Node, max int64) bool {
 	return false
 }
 
-// usemethod checks interface method calls for uses of reflect.Type.Method.
+// usemethod checks method calls for uses of reflect.Type.Method,
+// reflect.Type.MethodByName, reflect.Value.Method and
+// reflect.Value.MethodByName, which can reach any exported method
+// of any type in the program. A call of MethodByName with a constant
+// name is recorded in Curfn.Func.NamedMethods, so that the linker need
+// only keep the methods with that name. Any other call marks Curfn as
+// using reflection to reach methods, and the linker then keeps all
+// exported methods of reachable types.
 func usemethod(n *Node) {
+	// The reflect package implements these methods in terms of
+	// each other. Only their callers matter.
+	if myimportpath == "reflect" {
+		return
+	}
+
 	t := n.Left.Type
+	if n.Op == OCALLMETH {
+		if s := n.Left.Left.Type.Sym; s == nil || s.Name != "Value" || s.Pkg == nil || s.Pkg.Path != "reflect" {
+			return
+		}
+		// n.Left.Sym is the method symbol, as in "Value.Method".
+		switch n.Left.Sym.Name {
+		case "Value.Method":
+			Curfn.Func.SetReflectMethod(true)
+		case "Value.MethodByName":
+			usemethodname(n)
+		}
+		return
+	}
 
 	// Looking for either of:
 	//	Method(int) reflect.Method
 	//	MethodByName(string) (reflect.Method, bool)
-	//
-	// TODO(crawshaw): improve precision of match by working out
-	//                 how to check the method name.
 	if n := t.NumParams(); n != 1 {
 		return
 	}
@@ -3660,8 +3684,31 @@ func usemethod(n *Node) {
 	// Note: Don't rely on res0.Type.String() since its formatting depends on multiple factors
 	//       (including global variables such as numImports - was issue #19028).
 	if s := res0.Type.Sym; s != nil && s.Name == "Method" && s.Pkg != nil && s.Pkg.Path == "reflect" {
+		if res1 != nil {
+			usemethodname(n)
+		} else {
+			Curfn.Func.SetReflectMethod(true)
+		}
+	}
+}
+
+// usemethodname records a call n of MethodByName.
+// Reflection can only find exported methods, so a constant name
+// that is not exported cannot reach any method at all.
+func usemethodname(n *Node) {
+	arg := n.List.First()
+	if !Isconst(arg, CTSTR) {
 		Curfn.Func.SetReflectMethod(true)
+		return
+	}
+	name := strlit(arg)
+	if !types.IsExported(name) {
+		return
+	}
+	if Curfn.Func.NamedMethods == nil {
+		Curfn.Func.NamedMethods = make(map[*obj.LSym]struct{})
 	}
+	Curfn.Func.NamedMethods[stringsym(n.Pos, name)] = struct{}{}
 }
 
 func usefield(n *Node) {
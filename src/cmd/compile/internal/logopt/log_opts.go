@@ -0,0 +1,323 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logopt records optimization decisions made by the compiler
+// and writes them as machine-readable diagnostics.
+//
+// Logging is enabled by the compiler flag -json=version,destination.
+// The only version is 0. The destination is a directory, given as an
+// absolute path or a file:// URI. For each source file of the package
+// being compiled, the compiler writes
+//
+//	destination/<escaped package path>/<file base name>.json
+//
+// The first line of the file is a JSON object describing the
+// compilation, for example
+//
+//	{"version":0,"package":"p","goos":"linux","goarch":"amd64","gc_version":"devel","file":"/src/p/p.go"}
+//
+// Each following line is a Diagnostic as defined by the Language Server
+// Protocol. Its code names the kind of decision, such as "escape" or
+// "canInlineFunction", its message gives details, and its
+// relatedInformation holds an explanation, such as the data flow that
+// causes a value to escape, and the positions of any calls inlined
+// around the decision. As in LSP, lines and characters are 0-based.
+//
+// The kinds of decision logged are
+//
+//	canInlineFunction     a function can be inlined; the message gives its cost
+//	cannotInlineFunction  a function cannot be inlined; the message gives the reason
+//	inlineCall            a call was inlined; the message names the callee
+//	escape                a value is heap allocated; the explanation gives the flow
+//	leak                  a parameter leaks; the message says where to
+//	isInBounds            a bounds check remains
+//	isSliceInBounds       a slice bounds check remains
+package logopt
+
+import (
+	"bytes"
+	"cmd/internal/obj"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A LoggedOpt is one optimization decision.
+type LoggedOpt struct {
+	pos          src.XPos      // position of the decision
+	compilerPass string        // compiler pass that made the decision
+	functionName string        // function the decision was made in
+	what         string        // kind of decision
+	target       []interface{} // details, formatted into the message
+	explanation  []*LoggedOpt  // why the decision was made, if known
+}
+
+var (
+	dest       string // destination directory, or "" if logging is disabled
+	mu         sync.Mutex
+	loggedOpts []*LoggedOpt
+)
+
+// LogJsonOption parses the value of the -json flag and enables logging.
+func LogJsonOption(flagValue string) error {
+	i := strings.Index(flagValue, ",")
+	if i < 0 {
+		return fmt.Errorf("-json option should be 'version,destination', got %q", flagValue)
+	}
+	version, err := strconv.Atoi(flagValue[:i])
+	if err != nil || version != 0 {
+		return fmt.Errorf("-json version must be 0, got %q", flagValue[:i])
+	}
+	d := flagValue[i+1:]
+	if strings.HasPrefix(d, "file://") {
+		u, err := url.Parse(d)
+		if err != nil {
+			return fmt.Errorf("-json destination %q: %v", d, err)
+		}
+		d = filepath.FromSlash(u.Path)
+		if runtime.GOOS == "windows" && strings.HasPrefix(d, `\`) {
+			// file:///C:/dir has path /C:/dir.
+			d = d[1:]
+		}
+	}
+	if !filepath.IsAbs(d) {
+		return fmt.Errorf("-json destination must be an absolute path or file:// URI, got %q", flagValue[i+1:])
+	}
+	dest = d
+	return nil
+}
+
+// Enabled reports whether optimization decisions are being logged.
+func Enabled() bool {
+	return dest != ""
+}
+
+// NewLoggedOpt returns a new LoggedOpt, for use as part of the
+// explanation of another.
+func NewLoggedOpt(pos src.XPos, what, pass, funcName string, args ...interface{}) *LoggedOpt {
+	return &LoggedOpt{pos: pos, compilerPass: pass, functionName: funcName, what: what, target: args}
+}
+
+// LogOpt logs the decision what made by pass in funcName at pos.
+// args, if any, are formatted with %v into the diagnostic's message.
+// It is safe to call LogOpt concurrently.
+func LogOpt(pos src.XPos, what, pass, funcName string, args ...interface{}) {
+	if !Enabled() {
+		return
+	}
+	add(NewLoggedOpt(pos, what, pass, funcName, args...))
+}
+
+// LogOptExplained is like LogOpt, but attaches an explanation.
+func LogOptExplained(pos src.XPos, what, pass, funcName string, explanation []*LoggedOpt, args ...interface{}) {
+	if !Enabled() {
+		return
+	}
+	lo := NewLoggedOpt(pos, what, pass, funcName, args...)
+	lo.explanation = explanation
+	add(lo)
+}
+
+func add(lo *LoggedOpt) {
+	mu.Lock()
+	loggedOpts = append(loggedOpts, lo)
+	mu.Unlock()
+}
+
+func (lo *LoggedOpt) message() string {
+	var b bytes.Buffer
+	for i, a := range lo.target {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprint(&b, a)
+	}
+	return b.String()
+}
+
+// The following types define the subset of the Language Server
+// Protocol used in the output.
+
+type Position struct {
+	Line      uint `json:"line"`      // 0-based
+	Character uint `json:"character"` // 0-based
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type DiagnosticRelatedInformation struct {
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
+}
+
+// DiagnosticSeverityInformation is the severity of all diagnostics
+// written by the compiler.
+const DiagnosticSeverityInformation = 3
+
+type Diagnostic struct {
+	Range              Range                          `json:"range"`
+	Severity           int                            `json:"severity,omitempty"`
+	Code               string                         `json:"code,omitempty"`
+	Source             string                         `json:"source,omitempty"`
+	Message            string                         `json:"message"`
+	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type versionHeader struct {
+	Version   int    `json:"version"`
+	Package   string `json:"package"`
+	Goos      string `json:"goos"`
+	Goarch    string `json:"goarch"`
+	GcVersion string `json:"gc_version"`
+	File      string `json:"file"`
+}
+
+func uriIfy(f string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(f)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path // Windows drive letter paths
+	}
+	return u.String()
+}
+
+func newRange(p src.Pos) Range {
+	pos := Position{Line: zeroBased(p.Line()), Character: zeroBased(p.Col())}
+	return Range{Start: pos, End: pos}
+}
+
+func zeroBased(n uint) uint {
+	if n == 0 {
+		return 0
+	}
+	return n - 1
+}
+
+func newLocation(p src.Pos) Location {
+	return Location{URI: uriIfy(p.AbsFilename()), Range: newRange(p)}
+}
+
+// appendInlinedPos appends the inlining positions of pos, innermost
+// first, to related.
+func appendInlinedPos(ctxt *obj.Link, pos src.XPos, related []DiagnosticRelatedInformation) []DiagnosticRelatedInformation {
+	p := ctxt.InnermostPos(pos)
+	for ix := p.Base().InliningIndex(); ix >= 0; ix = ctxt.InlTree.Parent(ix) {
+		related = append(related, DiagnosticRelatedInformation{Location: newLocation(p), Message: "inlineLoc"})
+		p = ctxt.InnermostPos(ctxt.InlTree.CallPos(ix))
+	}
+	return related
+}
+
+// FlushLoggedOpts writes the logged decisions for the package with
+// the given import path and discards them.
+func FlushLoggedOpts(ctxt *obj.Link, pkgPath string) error {
+	if !Enabled() {
+		return nil
+	}
+	mu.Lock()
+	opts := loggedOpts
+	loggedOpts = nil
+	mu.Unlock()
+
+	type diag struct {
+		file string
+		pos  src.Pos
+		d    Diagnostic
+	}
+	var diags []diag
+	for _, lo := range opts {
+		p := ctxt.OutermostPos(lo.pos)
+		d := Diagnostic{
+			Range:    newRange(p),
+			Severity: DiagnosticSeverityInformation,
+			Code:     lo.what,
+			Source:   "go compiler",
+			Message:  lo.message(),
+		}
+		d.RelatedInformation = appendInlinedPos(ctxt, lo.pos, nil)
+		for _, e := range lo.explanation {
+			d.RelatedInformation = append(d.RelatedInformation, DiagnosticRelatedInformation{
+				Location: newLocation(ctxt.OutermostPos(e.pos)),
+				Message:  e.what + ": " + e.message(),
+			})
+			d.RelatedInformation = appendInlinedPos(ctxt, e.pos, d.RelatedInformation)
+		}
+		diags = append(diags, diag{p.AbsFilename(), p, d})
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].file != diags[j].file {
+			return diags[i].file < diags[j].file
+		}
+		if diags[i].pos.Line() != diags[j].pos.Line() {
+			return diags[i].pos.Line() < diags[j].pos.Line()
+		}
+		if diags[i].pos.Col() != diags[j].pos.Col() {
+			return diags[i].pos.Col() < diags[j].pos.Col()
+		}
+		return diags[i].d.Code < diags[j].d.Code
+	})
+
+	dir := filepath.Join(dest, url.PathEscape(pkgPath))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var f *os.File
+	var enc *json.Encoder
+	var curFile string
+	closeFile := func() error {
+		if f == nil {
+			return nil
+		}
+		err := f.Close()
+		f = nil
+		return err
+	}
+	for _, d := range diags {
+		if f == nil || d.file != curFile {
+			if err := closeFile(); err != nil {
+				return err
+			}
+			curFile = d.file
+			var err error
+			f, err = os.Create(filepath.Join(dir, strings.TrimSuffix(filepath.Base(curFile), ".go")+".json"))
+			if err != nil {
+				return err
+			}
+			enc = json.NewEncoder(f)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(versionHeader{
+				Version:   0,
+				Package:   pkgPath,
+				Goos:      objabi.GOOS,
+				Goarch:    objabi.GOARCH,
+				GcVersion: objabi.Version,
+				File:      curFile,
+			}); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if err := enc.Encode(d.d); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return closeFile()
+}
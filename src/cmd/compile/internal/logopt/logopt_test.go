@@ -0,0 +1,119 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logopt
+
+import (
+	"internal/testenv"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const srcCode = `package x
+
+type pair struct{ a, b int }
+
+func id(p *int) *int {
+	return p
+}
+
+var sink interface{}
+
+func foo(s []int, i int) *pair {
+	p := &pair{s[i], 1}
+	y := 5
+	q := id(&y)
+	sink = q
+	return p
+}
+`
+
+func TestLogJsonOption(t *testing.T) {
+	defer func() { dest = "" }()
+
+	abs := "/tmp/x"
+	uri := "file:///tmp/x"
+	if runtime.GOOS == "windows" {
+		abs = `C:\tmp\x`
+		uri = "file:///C:/tmp/x"
+	}
+	for _, tt := range []struct {
+		in   string
+		want string // "" means an error
+	}{
+		{"0," + abs, abs},
+		{"0," + uri, abs},
+		{"1," + abs, ""},
+		{abs, ""},
+		{"0,tmp/x", ""},
+	} {
+		dest = ""
+		err := LogJsonOption(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("LogJsonOption(%q) succeeded, want error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("LogJsonOption(%q): %v", tt.in, err)
+		} else if dest != tt.want {
+			t.Errorf("LogJsonOption(%q) set destination %q, want %q", tt.in, dest, tt.want)
+		}
+	}
+}
+
+func TestLogOpt(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+
+	dir, err := ioutil.TempDir("", "TestLogOpt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "file.go")
+	if err := ioutil.WriteFile(src, []byte(srcCode), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outdir := filepath.Join(dir, "json")
+	cmd := exec.Command(testenv.GoToolPath(t), "tool", "compile", "-p", "example.com/x",
+		"-json=0,"+outdir, "-o", filepath.Join(dir, "file.o"), src)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(outdir, "example.com%2Fx", "file.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	t.Logf("%s", out)
+
+	if !strings.HasPrefix(out, `{"version":0,"package":"example.com/x",`) {
+		t.Errorf("missing or incorrect version header")
+	}
+	want := []string{
+		`{"range":{"start":{"line":4,"character":5},"end":{"line":4,"character":5}},"severity":3,"code":"canInlineFunction","source":"go compiler","message":"cost: 2"}`,
+		`"code":"leak","source":"go compiler","message":"leaking param: p to result ~r1 level=0"}`,
+		`"code":"isInBounds","source":"go compiler","message":""}`,
+		`"code":"inlineCall","source":"go compiler","message":"id"}`,
+		`"code":"escape","source":"go compiler","message":"&pair literal","relatedInformation":[`,
+		`"message":"escflow: p = &{storage for &pair literal}"}`,
+		`{"range":{"start":{"line":12,"character":1},"end":{"line":12,"character":1}},"severity":3,"code":"escape","source":"go compiler","message":"y","relatedInformation":[`,
+		`"message":"escflow: {heap} = q"}`,
+		`"message":"inlineLoc"}`,
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("output does not contain %s", w)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pgo reads CPU profiles in the pprof format and summarizes
+// them for profile-guided optimization.
+//
+// Functions in the profile are identified by their symbol names, as
+// reported by runtime.FuncForPC, for example "pkg/path.(*T).M".
+// Call sites are identified by the calling function and the absolute
+// line number of the call.
+package pgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// A CallSite identifies a call within a function.
+type CallSite struct {
+	Caller string // symbol name of the calling function
+	Line   int    // line number of the call
+}
+
+type callEdge struct {
+	site   CallSite
+	callee string
+}
+
+type fileLine struct {
+	file string
+	line int
+}
+
+// A Profile is a summary of a CPU profile.
+type Profile struct {
+	// Total sample weight across all samples.
+	TotalWeight int64
+
+	edges      map[callEdge]int64
+	sites      map[CallSite][]string // callees at each call site, hottest first
+	lines      map[fileLine]int64
+	hotWeight  int64 // minimum weight of a hot edge
+	hotCallees map[string]bool
+}
+
+// Open reads the pprof profile in file. Call edges are considered
+// hot if they are among the heaviest edges accounting for hotCDF
+// percent of the total edge weight.
+func Open(file string, hotCDF float64) (*Profile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data, hotCDF)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return p, nil
+}
+
+// Parse is like Open but reads the profile from data, which may be
+// gzip-compressed.
+func Parse(data []byte, hotCDF float64) (*Profile, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		data, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pp, err := decodeProfile(data)
+	if err != nil {
+		return nil, err
+	}
+	return newProfile(pp, hotCDF)
+}
+
+type frame struct {
+	fn   *protoFunction
+	line int
+}
+
+func newProfile(pp *protoProfile, hotCDF float64) (*Profile, error) {
+	str := func(i int64) (string, error) {
+		if i < 0 || i >= int64(len(pp.strings)) {
+			return "", errors.New("string table index out of range")
+		}
+		return pp.strings[i], nil
+	}
+
+	// Weight samples by sample count if present, otherwise by the
+	// last value, which for CPU profiles is the time spent.
+	if len(pp.sampleTypes) == 0 {
+		return nil, errors.New("profile has no sample types")
+	}
+	valueIndex := len(pp.sampleTypes) - 1
+	for i, st := range pp.sampleTypes {
+		typ, err := str(st.typ)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "samples" {
+			valueIndex = i
+			break
+		}
+	}
+
+	functions := make(map[uint64]*protoFunction)
+	for i := range pp.functions {
+		fn := &pp.functions[i]
+		functions[fn.id] = fn
+	}
+	locations := make(map[uint64]*protoLocation)
+	for i := range pp.locations {
+		loc := &pp.locations[i]
+		locations[loc.id] = loc
+	}
+
+	p := &Profile{
+		edges:      make(map[callEdge]int64),
+		sites:      make(map[CallSite][]string),
+		lines:      make(map[fileLine]int64),
+		hotCallees: make(map[string]bool),
+	}
+	var stack []frame
+	var seen []fileLine
+	for _, s := range pp.samples {
+		if valueIndex >= len(s.values) {
+			return nil, errors.New("sample has too few values")
+		}
+		w := int64(s.values[valueIndex])
+		if w <= 0 {
+			continue
+		}
+		p.TotalWeight += w
+
+		// Expand the stack, innermost frame first. A location
+		// with several lines represents inlined calls; its last
+		// line is the outermost caller.
+		stack = stack[:0]
+		for _, id := range s.locations {
+			loc := locations[id]
+			if loc == nil {
+				return nil, fmt.Errorf("sample refers to unknown location %d", id)
+			}
+			for _, l := range loc.lines {
+				fn := functions[l.function]
+				if fn == nil {
+					return nil, fmt.Errorf("location refers to unknown function %d", l.function)
+				}
+				stack = append(stack, frame{fn, int(l.line)})
+			}
+		}
+
+		for i := 0; i+1 < len(stack); i++ {
+			callee, err := str(stack[i].fn.name)
+			if err != nil {
+				return nil, err
+			}
+			caller, err := str(stack[i+1].fn.name)
+			if err != nil {
+				return nil, err
+			}
+			p.edges[callEdge{CallSite{caller, stack[i+1].line}, callee}] += w
+		}
+
+		// Attribute the weight to every line on the stack, but only
+		// once per sample so that recursion is not over-counted.
+		seen = seen[:0]
+	lines:
+		for _, f := range stack {
+			file, err := str(f.fn.filename)
+			if err != nil {
+				return nil, err
+			}
+			fl := fileLine{file, f.line}
+			for _, s := range seen {
+				if s == fl {
+					continue lines
+				}
+			}
+			seen = append(seen, fl)
+			p.lines[fl] += w
+		}
+	}
+
+	p.computeHot(hotCDF)
+	return p, nil
+}
+
+type edgeWeight struct {
+	edge   callEdge
+	weight int64
+}
+
+type byWeight []edgeWeight
+
+func (x byWeight) Len() int      { return len(x) }
+func (x byWeight) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byWeight) Less(i, j int) bool {
+	if x[i].weight != x[j].weight {
+		return x[i].weight > x[j].weight
+	}
+	// Break ties deterministically.
+	a, b := x[i].edge, x[j].edge
+	if a.site.Caller != b.site.Caller {
+		return a.site.Caller < b.site.Caller
+	}
+	if a.site.Line != b.site.Line {
+		return a.site.Line < b.site.Line
+	}
+	return a.callee < b.callee
+}
+
+// computeHot determines the hot edge weight threshold and records the
+// callees at each call site.
+func (p *Profile) computeHot(hotCDF float64) {
+	var edges []edgeWeight
+	var total int64
+	for e, w := range p.edges {
+		edges = append(edges, edgeWeight{e, w})
+		total += w
+	}
+	sort.Sort(byWeight(edges))
+
+	p.hotWeight = 0
+	if total > 0 && hotCDF > 0 {
+		var cum int64
+		for _, e := range edges {
+			cum += e.weight
+			p.hotWeight = e.weight
+			if float64(cum)*100 >= float64(total)*hotCDF {
+				break
+			}
+		}
+	}
+
+	for _, e := range edges {
+		p.sites[e.edge.site] = append(p.sites[e.edge.site], e.edge.callee)
+		if p.isHot(e.weight) {
+			p.hotCallees[e.edge.callee] = true
+		}
+	}
+}
+
+func (p *Profile) isHot(w int64) bool {
+	return p.hotWeight > 0 && w >= p.hotWeight
+}
+
+// EdgeWeight returns the total weight of calls from site to callee.
+func (p *Profile) EdgeWeight(site CallSite, callee string) int64 {
+	return p.edges[callEdge{site, callee}]
+}
+
+// HotCall reports whether the call from site to callee is hot.
+func (p *Profile) HotCall(site CallSite, callee string) bool {
+	return p.isHot(p.EdgeWeight(site, callee))
+}
+
+// HotCallee reports whether the named function is the callee of any
+// hot call.
+func (p *Profile) HotCallee(name string) bool {
+	return p.hotCallees[name]
+}
+
+// HotCallees returns the callees of the hot calls at site, hottest first.
+func (p *Profile) HotCallees(site CallSite) []string {
+	var hot []string
+	for _, callee := range p.sites[site] {
+		if !p.HotCall(site, callee) {
+			break
+		}
+		hot = append(hot, callee)
+	}
+	return hot
+}
+
+// LineWeight returns the weight of the samples whose stack includes
+// the given line of file.
+func (p *Profile) LineWeight(file string, line int) int64 {
+	return p.lines[fileLine{file, line}]
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// testProfile returns a CPU profile in which main.main calls main.hot
+// at line 10 (90 samples, 50 of them through main.inlined, which is
+// inlined into main.hot) and main.cold at line 12 (1 sample).
+func testProfile() *profile.Profile {
+	fnMain := &profile.Function{ID: 1, Name: "main.main", Filename: "/src/main.go"}
+	fnHot := &profile.Function{ID: 2, Name: "main.hot", Filename: "/src/main.go"}
+	fnCold := &profile.Function{ID: 3, Name: "main.cold", Filename: "/src/main.go"}
+	fnInlined := &profile.Function{ID: 4, Name: "main.inlined", Filename: "/src/main.go"}
+
+	locMain10 := &profile.Location{ID: 1, Line: []profile.Line{{Function: fnMain, Line: 10}}}
+	locMain12 := &profile.Location{ID: 2, Line: []profile.Line{{Function: fnMain, Line: 12}}}
+	locHot := &profile.Location{ID: 3, Line: []profile.Line{{Function: fnHot, Line: 20}}}
+	locCold := &profile.Location{ID: 4, Line: []profile.Line{{Function: fnCold, Line: 30}}}
+	locInlined := &profile.Location{ID: 5, Line: []profile.Line{
+		{Function: fnInlined, Line: 40},
+		{Function: fnHot, Line: 21},
+	}}
+
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locHot, locMain10}, Value: []int64{40, 400}},
+			{Location: []*profile.Location{locInlined, locMain10}, Value: []int64{50, 500}},
+			{Location: []*profile.Location{locCold, locMain12}, Value: []int64{1, 10}},
+		},
+		Location: []*profile.Location{locMain10, locMain12, locHot, locCold, locInlined},
+		Function: []*profile.Function{fnMain, fnHot, fnCold, fnInlined},
+	}
+}
+
+func TestProfile(t *testing.T) {
+	var compressed, uncompressed bytes.Buffer
+	if err := testProfile().Write(&compressed); err != nil {
+		t.Fatal(err)
+	}
+	if err := testProfile().WriteUncompressed(&uncompressed); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range [][]byte{compressed.Bytes(), uncompressed.Bytes()} {
+		p, err := Parse(data, 95)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.TotalWeight != 91 {
+			t.Errorf("TotalWeight = %d, want 91", p.TotalWeight)
+		}
+
+		main10 := CallSite{"main.main", 10}
+		if w := p.EdgeWeight(main10, "main.hot"); w != 90 {
+			t.Errorf("weight of main.main -> main.hot = %d, want 90", w)
+		}
+		if !p.HotCall(main10, "main.hot") {
+			t.Errorf("main.main -> main.hot is not hot")
+		}
+		if !p.HotCall(CallSite{"main.hot", 21}, "main.inlined") {
+			t.Errorf("inlined call main.hot -> main.inlined is not hot")
+		}
+		if p.HotCall(CallSite{"main.main", 12}, "main.cold") {
+			t.Errorf("main.main -> main.cold is hot")
+		}
+		if !p.HotCallee("main.hot") || p.HotCallee("main.cold") {
+			t.Errorf("HotCallee(main.hot) = %v, HotCallee(main.cold) = %v, want true, false",
+				p.HotCallee("main.hot"), p.HotCallee("main.cold"))
+		}
+		if got := p.HotCallees(main10); len(got) != 1 || got[0] != "main.hot" {
+			t.Errorf("HotCallees(%v) = %v, want [main.hot]", main10, got)
+		}
+		if w := p.LineWeight("/src/main.go", 10); w != 90 {
+			t.Errorf("LineWeight(main.go:10) = %d, want 90", w)
+		}
+		if w := p.LineWeight("/src/main.go", 40); w != 50 {
+			t.Errorf("LineWeight(main.go:40) = %d, want 50", w)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testProfile().WriteUncompressed(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if _, err := Parse(data[:len(data)-3], 95); err == nil {
+		t.Error("Parse succeeded on a truncated profile")
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+// This file contains a minimal decoder for the protocol buffer
+// encoding of pprof profiles. See
+// github.com/google/pprof/proto/profile.proto for the schema.
+// Only the fields needed to build the call graph are decoded.
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errTruncated = errors.New("truncated profile")
+
+// A protoBuffer is a protocol buffer message being decoded.
+type protoBuffer struct {
+	data []byte
+
+	// The current field, set by next.
+	field int
+	typ   int    // wire type
+	u64   uint64 // value for varint fields
+	bytes []byte // value for length-delimited fields
+}
+
+// next decodes the next field of the message.
+// It reports false at the end of the message.
+func (b *protoBuffer) next() (bool, error) {
+	if len(b.data) == 0 {
+		return false, nil
+	}
+	key, err := b.varint()
+	if err != nil {
+		return false, err
+	}
+	b.field = int(key >> 3)
+	b.typ = int(key & 7)
+	b.u64 = 0
+	b.bytes = nil
+	switch b.typ {
+	case 0: // varint
+		b.u64, err = b.varint()
+		if err != nil {
+			return false, err
+		}
+	case 1: // fixed64
+		if len(b.data) < 8 {
+			return false, errTruncated
+		}
+		for i := 7; i >= 0; i-- {
+			b.u64 = b.u64<<8 | uint64(b.data[i])
+		}
+		b.data = b.data[8:]
+	case 2: // length-delimited
+		n, err := b.varint()
+		if err != nil {
+			return false, err
+		}
+		if uint64(len(b.data)) < n {
+			return false, errTruncated
+		}
+		b.bytes = b.data[:n]
+		b.data = b.data[n:]
+	case 5: // fixed32
+		if len(b.data) < 4 {
+			return false, errTruncated
+		}
+		for i := 3; i >= 0; i-- {
+			b.u64 = b.u64<<8 | uint64(b.data[i])
+		}
+		b.data = b.data[4:]
+	default:
+		return false, fmt.Errorf("unsupported protocol buffer wire type %d", b.typ)
+	}
+	return true, nil
+}
+
+func (b *protoBuffer) varint() (uint64, error) {
+	var x uint64
+	for i := uint(0); i < 64; i += 7 {
+		if len(b.data) == 0 {
+			return 0, errTruncated
+		}
+		c := b.data[0]
+		b.data = b.data[1:]
+		x |= uint64(c&0x7f) << i
+		if c < 0x80 {
+			return x, nil
+		}
+	}
+	return 0, errors.New("malformed varint")
+}
+
+// uint64s appends the values of the current repeated integer field,
+// which may be packed or unpacked, to x.
+func (b *protoBuffer) uint64s(x []uint64) ([]uint64, error) {
+	if b.typ != 2 {
+		return append(x, b.u64), nil
+	}
+	packed := protoBuffer{data: b.bytes}
+	for len(packed.data) > 0 {
+		v, err := packed.varint()
+		if err != nil {
+			return nil, err
+		}
+		x = append(x, v)
+	}
+	return x, nil
+}
+
+type protoValueType struct {
+	typ, unit int64 // indexes into the string table
+}
+
+type protoSample struct {
+	locations []uint64
+	values    []uint64
+}
+
+type protoLine struct {
+	function uint64
+	line     int64
+}
+
+type protoLocation struct {
+	id    uint64
+	lines []protoLine
+}
+
+type protoFunction struct {
+	id       uint64
+	name     int64 // index into the string table
+	filename int64 // index into the string table
+}
+
+// protoProfile is the subset of a decoded pprof Profile message
+// used for profile-guided optimization.
+type protoProfile struct {
+	sampleTypes []protoValueType
+	samples     []protoSample
+	locations   []protoLocation
+	functions   []protoFunction
+	strings     []string
+}
+
+func decodeProfile(data []byte) (*protoProfile, error) {
+	p := new(protoProfile)
+	b := protoBuffer{data: data}
+	for {
+		ok, err := b.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		switch b.field {
+		case 1: // sample_type
+			vt, err := decodeValueType(b.bytes)
+			if err != nil {
+				return nil, err
+			}
+			p.sampleTypes = append(p.sampleTypes, vt)
+		case 2: // sample
+			s, err := decodeSample(b.bytes)
+			if err != nil {
+				return nil, err
+			}
+			p.samples = append(p.samples, s)
+		case 4: // location
+			loc, err := decodeLocation(b.bytes)
+			if err != nil {
+				return nil, err
+			}
+			p.locations = append(p.locations, loc)
+		case 5: // function
+			fn, err := decodeFunction(b.bytes)
+			if err != nil {
+				return nil, err
+			}
+			p.functions = append(p.functions, fn)
+		case 6: // string_table
+			p.strings = append(p.strings, string(b.bytes))
+		}
+	}
+	if len(p.strings) == 0 || p.strings[0] != "" {
+		return nil, errors.New("malformed string table")
+	}
+	return p, nil
+}
+
+func decodeValueType(data []byte) (protoValueType, error) {
+	var vt protoValueType
+	b := protoBuffer{data: data}
+	for {
+		ok, err := b.next()
+		if err != nil || !ok {
+			return vt, err
+		}
+		switch b.field {
+		case 1:
+			vt.typ = int64(b.u64)
+		case 2:
+			vt.unit = int64(b.u64)
+		}
+	}
+}
+
+func decodeSample(data []byte) (protoSample, error) {
+	var s protoSample
+	b := protoBuffer{data: data}
+	for {
+		ok, err := b.next()
+		if err != nil || !ok {
+			return s, err
+		}
+		switch b.field {
+		case 1:
+			s.locations, err = b.uint64s(s.locations)
+		case 2:
+			s.values, err = b.uint64s(s.values)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+}
+
+func decodeLocation(data []byte) (protoLocation, error) {
+	var loc protoLocation
+	b := protoBuffer{data: data}
+	for {
+		ok, err := b.next()
+		if err != nil || !ok {
+			return loc, err
+		}
+		switch b.field {
+		case 1:
+			loc.id = b.u64
+		case 4:
+			l, err := decodeLine(b.bytes)
+			if err != nil {
+				return loc, err
+			}
+			loc.lines = append(loc.lines, l)
+		}
+	}
+}
+
+func decodeLine(data []byte) (protoLine, error) {
+	var l protoLine
+	b := protoBuffer{data: data}
+	for {
+		ok, err := b.next()
+		if err != nil || !ok {
+			return l, err
+		}
+		switch b.field {
+		case 1:
+			l.function = b.u64
+		case 2:
+			l.line = int64(b.u64)
+		}
+	}
+}
+
+func decodeFunction(data []byte) (protoFunction, error) {
+	var fn protoFunction
+	b := protoBuffer{data: data}
+	for {
+		ok, err := b.next()
+		if err != nil || !ok {
+			return fn, err
+		}
+		switch b.field {
+		case 1:
+			fn.id = b.u64
+		case 2:
+			fn.name = int64(b.u64)
+		case 4:
+			fn.filename = int64(b.u64)
+		}
+	}
+}
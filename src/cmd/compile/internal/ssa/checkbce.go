@@ -4,19 +4,31 @@
 
 package ssa
 
+import "cmd/compile/internal/logopt"
+
 // checkbce prints all bounds checks that are present in the function.
 // Useful to find regressions. checkbce is only activated when with
-// corresponding debug options, so it's off by default.
+// corresponding debug options or -json logging, so it's off by default.
 // See test/checkbce.go
 func checkbce(f *Func) {
-	if f.pass.debug <= 0 {
+	if f.pass.debug <= 0 && !logopt.Enabled() {
 		return
 	}
 
 	for _, b := range f.Blocks {
 		for _, v := range b.Values {
 			if v.Op == OpIsInBounds || v.Op == OpIsSliceInBounds {
-				f.Warnl(v.Pos, "Found %v", v.Op)
+				if f.pass.debug > 0 {
+					f.Warnl(v.Pos, "Found %v", v.Op)
+				}
+				if logopt.Enabled() {
+					if v.Op == OpIsInBounds {
+						logopt.LogOpt(v.Pos, "isInBounds", "checkbce", f.Name)
+					}
+					if v.Op == OpIsSliceInBounds {
+						logopt.LogOpt(v.Pos, "isSliceInBounds", "checkbce", f.Name)
+					}
+				}
 			}
 		}
 	}
@@ -47,6 +47,11 @@ type Func struct {
 	laidout   bool // Blocks are ordered
 	NoSplit   bool // true if function is marked as nosplit.  Used by schedule check pass.
 
+	// ProfileWeight, if non-nil, returns the execution weight of the
+	// source line at pos in a CPU profile. Layout uses it to place
+	// hot blocks first.
+	ProfileWeight func(pos src.XPos) int64
+
 	// when register allocation is done, maps value ids to locations
 	RegAlloc []Location
 
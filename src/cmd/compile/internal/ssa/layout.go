@@ -63,6 +63,15 @@ func layoutOrder(f *Func) []*Block {
 		}
 	}
 
+	// If we have a profile, weigh each block by its hottest line.
+	var weight []int64
+	if f.ProfileWeight != nil {
+		weight = make([]int64, f.NumBlocks())
+		for _, b := range f.Blocks {
+			weight[b.ID] = blockProfileWeight(f, b)
+		}
+	}
+
 	bid := f.Entry.ID
 blockloop:
 	for {
@@ -86,6 +95,14 @@ blockloop:
 		// Pick the next block to schedule
 		// Pick among the successor blocks that have not been scheduled yet.
 
+		// Prefer the successor the profile shows to be hottest.
+		if weight != nil {
+			if hot := hottestSucc(b, weight, scheduled); hot != nil {
+				bid = hot.ID
+				continue
+			}
+		}
+
 		// Use likely direction if we have it.
 		var likely *Block
 		switch b.Likely {
@@ -147,3 +164,39 @@ blockloop:
 	return order
 	//f.Blocks = order
 }
+
+// blockProfileWeight returns the largest profile weight of the source
+// lines of b's values.
+func blockProfileWeight(f *Func, b *Block) int64 {
+	w := f.ProfileWeight(b.Pos)
+	for _, v := range b.Values {
+		if vw := f.ProfileWeight(v.Pos); vw > w {
+			w = vw
+		}
+	}
+	return w
+}
+
+// hottestSucc returns the unscheduled, non-exit successor of b with
+// the strictly largest positive weight, or nil if there is none.
+func hottestSucc(b *Block, weight []int64, scheduled []bool) *Block {
+	var hot *Block
+	var hotWeight int64
+	tie := false
+	for _, e := range b.Succs {
+		c := e.b
+		if scheduled[c.ID] || c.Kind == BlockExit {
+			continue
+		}
+		switch w := weight[c.ID]; {
+		case w > hotWeight:
+			hot, hotWeight, tie = c, w, false
+		case w == hotWeight:
+			tie = true
+		}
+	}
+	if tie {
+		return nil
+	}
+	return hot
+}
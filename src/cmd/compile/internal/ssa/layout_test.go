@@ -0,0 +1,59 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+import (
+	"cmd/compile/internal/types"
+	"cmd/internal/src"
+	"testing"
+)
+
+func TestLayoutProfile(t *testing.T) {
+	c := testConfig(t)
+	layoutFun := func() fun {
+		return c.Fun("entry",
+			Bloc("entry",
+				Valu("mem", OpInitMem, types.TypeMem, 0, nil),
+				Valu("cond", OpConstBool, c.config.Types.Bool, 1, nil),
+				If("cond", "cold", "hot")),
+			Bloc("cold",
+				Goto("merge")),
+			Bloc("hot",
+				Goto("merge")),
+			Bloc("merge",
+				Goto("exit")),
+			Bloc("exit",
+				Exit("mem")))
+	}
+
+	// Without a profile, the first successor follows the entry block.
+	fun := layoutFun()
+	CheckFunc(fun.f)
+	if order := layoutOrder(fun.f); order[1] != fun.blocks["cold"] {
+		t.Errorf("without profile: block after entry is %v, want cold", order[1])
+	}
+
+	// With a profile, the hot successor follows the entry block.
+	var tab src.PosTable
+	base := src.NewFileBase("a.go", "a.go")
+	coldPos := tab.XPos(src.MakePos(base, 10, 1))
+	hotPos := tab.XPos(src.MakePos(base, 20, 1))
+	fun = layoutFun()
+	fun.blocks["cold"].Pos = coldPos
+	fun.blocks["hot"].Pos = hotPos
+	fun.f.ProfileWeight = func(pos src.XPos) int64 {
+		switch pos {
+		case coldPos:
+			return 1
+		case hotPos:
+			return 100
+		}
+		return 0
+	}
+	CheckFunc(fun.f)
+	if order := layoutOrder(fun.f); order[1] != fun.blocks["hot"] {
+		t.Errorf("with profile: block after entry is %v, want hot", order[1])
+	}
+}
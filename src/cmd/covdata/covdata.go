@@ -0,0 +1,200 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"internal/coverage"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cmd/internal/objabi"
+)
+
+const usageMessage = "" +
+	`Usage of 'go tool covdata':
+Merge the counter files in dir1 and dir2 into a single counter file in outdir:
+	go tool covdata merge -i=dir1,dir2 -o=outdir
+
+Write the counters in dir1, less the blocks executed in dir2, to outdir:
+	go tool covdata subtract -i=dir1,dir2 -o=outdir
+
+Convert the counter files in dir1 and dir2 to a coverage profile:
+	go tool covdata textfmt -i=dir1,dir2 -o=cover.out
+`
+
+func usage() {
+	fmt.Fprintln(os.Stderr, usageMessage)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+var (
+	input  = flag.String("i", "", "comma-separated list of input directories")
+	output = flag.String("o", "", "output directory, or output file for textfmt")
+)
+
+// mergedFile is the name of the counter file written by merge and subtract.
+const mergedFile = coverage.CounterFilePrefix + "merged"
+
+func main() {
+	objabi.AddVersionFlag()
+	flag.Usage = usage
+	if len(os.Args) < 2 {
+		usage()
+	}
+	mode := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+	if flag.NArg() != 0 || *input == "" || *output == "" {
+		usage()
+	}
+	dirs := strings.Split(*input, ",")
+
+	var err error
+	switch mode {
+	case "merge":
+		err = merge(dirs, *output)
+	case "subtract":
+		err = subtract(dirs, *output)
+	case "textfmt":
+		err = textfmt(dirs, *output)
+	default:
+		fmt.Fprintf(os.Stderr, "covdata: unknown mode %q\n", mode)
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "covdata: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// readDir reads and merges the counter files in dir.
+func readDir(dir string) (*Profile, error) {
+	names, err := filepath.Glob(filepath.Join(dir, coverage.CounterFilePrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no counter files in %s", dir)
+	}
+	var p *Profile
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		q, err := ParseProfile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if p == nil {
+			p = q
+		} else if err := p.Merge(q); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return p, nil
+}
+
+// readDirs reads and merges the counter files in dirs.
+func readDirs(dirs []string) (*Profile, error) {
+	var p *Profile
+	for _, dir := range dirs {
+		q, err := readDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			p = q
+		} else if err := p.Merge(q); err != nil {
+			return nil, fmt.Errorf("%s: %v", dir, err)
+		}
+	}
+	return p, nil
+}
+
+func merge(dirs []string, outdir string) error {
+	if err := checkOutdir(dirs, outdir); err != nil {
+		return err
+	}
+	p, err := readDirs(dirs)
+	if err != nil {
+		return err
+	}
+	return writeCounterFile(p, outdir)
+}
+
+func subtract(dirs []string, outdir string) error {
+	if len(dirs) < 2 {
+		return fmt.Errorf("subtract requires at least two input directories")
+	}
+	if err := checkOutdir(dirs, outdir); err != nil {
+		return err
+	}
+	p, err := readDir(dirs[0])
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs[1:] {
+		q, err := readDir(dir)
+		if err != nil {
+			return err
+		}
+		if err := p.Subtract(q); err != nil {
+			return fmt.Errorf("%s: %v", dir, err)
+		}
+	}
+	return writeCounterFile(p, outdir)
+}
+
+func textfmt(dirs []string, file string) error {
+	p, err := readDirs(dirs)
+	if err != nil {
+		return err
+	}
+	return writeFile(p, file)
+}
+
+// checkOutdir reports an error if outdir is one of the input
+// directories, where the merged counter file would be read again
+// by later runs.
+func checkOutdir(dirs []string, outdir string) error {
+	out, err := filepath.Abs(outdir)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if d, err := filepath.Abs(dir); err == nil && d == out {
+			return fmt.Errorf("output directory %s is also an input directory", outdir)
+		}
+	}
+	return nil
+}
+
+func writeCounterFile(p *Profile, outdir string) error {
+	if err := os.MkdirAll(outdir, 0777); err != nil {
+		return err
+	}
+	return writeFile(p, filepath.Join(outdir, mergedFile))
+}
+
+func writeFile(p *Profile, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	p.Write(w)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
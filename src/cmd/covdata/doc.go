@@ -0,0 +1,33 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Covdata is a program for manipulating the coverage counter files
+written by programs built with 'go build -cover'.
+
+Such a program writes a counter file to the directory named by the
+GOCOVERDIR environment variable each time it exits. Covdata reads
+the counter files in one or more such directories. Usage:
+
+	go tool covdata <mode> -i=<dir1,dir2,...> -o=<output>
+
+The modes are:
+
+	merge     merge the counters of all input directories and write
+	          them as a single counter file to the output directory
+	subtract  write the counters of the first input directory to the
+	          output directory, with the counters of blocks executed by
+	          any of the other input directories set to zero
+	textfmt   merge the counters of all input directories and write
+	          them to the output file as a coverage profile
+
+The profile written by textfmt is in the format written by
+'go test -coverprofile' and can be viewed with 'go tool cover'.
+For example:
+
+	GOCOVERDIR=covdir ./prog
+	go tool covdata textfmt -i=covdir -o=cover.out
+	go tool cover -html=cover.out
+*/
+package main
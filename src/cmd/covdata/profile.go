@@ -0,0 +1,242 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Profile holds the counters of a coverage profile in the text
+// format, keyed by block. In branch mode it also holds the outcome
+// counts of each decision, keyed by the decision's source range.
+type Profile struct {
+	Mode     string
+	Blocks   map[Block]*Counter
+	Branches map[Block][]int64
+}
+
+// A Block is the source range of a basic block or decision.
+type Block struct {
+	File                                 string
+	StartLine, StartCol, EndLine, EndCol int
+}
+
+// A Counter holds the statement count and execution count of a block.
+type Counter struct {
+	NumStmt int
+	Count   int64
+}
+
+// ParseProfile parses a coverage profile in the text format:
+//
+//	mode: set
+//	name.go:line.column,line.column numberOfStatements count
+//
+// In branch mode, the profile also has lines of the form
+//
+//	name.go:line.column,line.column br count count...
+//
+// giving the number of times each outcome of a decision was taken.
+func ParseProfile(r io.Reader) (*Profile, error) {
+	p := &Profile{Blocks: make(map[Block]*Counter), Branches: make(map[Block][]int64)}
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		if lineno == 1 {
+			if !strings.HasPrefix(line, "mode: ") {
+				return nil, fmt.Errorf("line 1: missing mode line")
+			}
+			p.Mode = strings.TrimPrefix(line, "mode: ")
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: malformed block %q", lineno, line)
+		}
+		b := Block{File: line[:i]}
+		if j := strings.Index(line[i:], " br"); j >= 0 {
+			counts, err := parseBranch(line[i+1:i+j], line[i+j+len(" br"):], &b)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed branch %q", lineno, line)
+			}
+			if err := p.addBranch(b, counts); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineno, err)
+			}
+			continue
+		}
+		var c Counter
+		n, err := fmt.Sscanf(line[i+1:], "%d.%d,%d.%d %d %d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol, &c.NumStmt, &c.Count)
+		if n != 6 || err != nil {
+			return nil, fmt.Errorf("line %d: malformed block %q", lineno, line)
+		}
+		if err := p.add(b, c); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if p.Mode == "" {
+		return nil, fmt.Errorf("empty profile")
+	}
+	return p, nil
+}
+
+// parseBranch parses the position pos of a branch line into b
+// and returns the outcome counts listed in counts.
+func parseBranch(pos, counts string, b *Block) ([]int64, error) {
+	n, err := fmt.Sscanf(pos, "%d.%d,%d.%d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol)
+	if n != 4 || err != nil {
+		return nil, fmt.Errorf("malformed position")
+	}
+	var c []int64
+	for _, f := range strings.Fields(counts) {
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, v)
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("no outcomes")
+	}
+	return c, nil
+}
+
+// addBranch merges the outcome counts c for decision b into p.
+func (p *Profile) addBranch(b Block, c []int64) error {
+	old := p.Branches[b]
+	if old == nil {
+		p.Branches[b] = c
+		return nil
+	}
+	if len(old) != len(c) {
+		return fmt.Errorf("inconsistent outcome count for %s:%d.%d,%d.%d", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+	}
+	for i := range c {
+		old[i] += c[i]
+	}
+	return nil
+}
+
+// add merges the counter c for block b into p.
+func (p *Profile) add(b Block, c Counter) error {
+	old := p.Blocks[b]
+	if old == nil {
+		p.Blocks[b] = &c
+		return nil
+	}
+	if old.NumStmt != c.NumStmt {
+		return fmt.Errorf("inconsistent statement count for %s:%d.%d,%d.%d", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+	}
+	if p.Mode == "set" {
+		if c.Count != 0 {
+			old.Count = 1
+		}
+	} else {
+		old.Count += c.Count
+	}
+	return nil
+}
+
+// Merge adds the counters of q to p.
+func (p *Profile) Merge(q *Profile) error {
+	if p.Mode != q.Mode {
+		return fmt.Errorf("cannot merge profiles with modes %s and %s", p.Mode, q.Mode)
+	}
+	for b, c := range q.Blocks {
+		if err := p.add(b, *c); err != nil {
+			return err
+		}
+	}
+	for b, c := range q.Branches {
+		if err := p.addBranch(b, append([]int64(nil), c...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subtract zeroes the counters of the blocks in p that were
+// executed according to q, and likewise the counts of the decision
+// outcomes taken according to q.
+func (p *Profile) Subtract(q *Profile) error {
+	if p.Mode != q.Mode {
+		return fmt.Errorf("cannot subtract profiles with modes %s and %s", p.Mode, q.Mode)
+	}
+	for b, c := range q.Blocks {
+		if old := p.Blocks[b]; old != nil && c.Count != 0 {
+			old.Count = 0
+		}
+	}
+	for b, c := range q.Branches {
+		old := p.Branches[b]
+		if len(old) != len(c) {
+			continue
+		}
+		for i := range c {
+			if c[i] != 0 {
+				old[i] = 0
+			}
+		}
+	}
+	return nil
+}
+
+// Write writes p in the text format, with the blocks sorted by
+// file and position, followed by the decisions sorted likewise.
+func (p *Profile) Write(w io.Writer) {
+	blocks := make([]Block, 0, len(p.Blocks))
+	for b := range p.Blocks {
+		blocks = append(blocks, b)
+	}
+	sortBlocks(blocks)
+	fmt.Fprintf(w, "mode: %s\n", p.Mode)
+	for _, b := range blocks {
+		c := p.Blocks[b]
+		fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol, c.NumStmt, c.Count)
+	}
+
+	branches := make([]Block, 0, len(p.Branches))
+	for b := range p.Branches {
+		branches = append(branches, b)
+	}
+	sortBlocks(branches)
+	for _, b := range branches {
+		fmt.Fprintf(w, "%s:%d.%d,%d.%d br", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+		for _, c := range p.Branches[b] {
+			fmt.Fprintf(w, " %d", c)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// sortBlocks sorts blocks by file and position.
+func sortBlocks(blocks []Block) {
+	sort.Slice(blocks, func(i, j int) bool {
+		bi, bj := blocks[i], blocks[j]
+		if bi.File != bj.File {
+			return bi.File < bj.File
+		}
+		if bi.StartLine != bj.StartLine {
+			return bi.StartLine < bj.StartLine
+		}
+		if bi.StartCol != bj.StartCol {
+			return bi.StartCol < bj.StartCol
+		}
+		if bi.EndLine != bj.EndLine {
+			return bi.EndLine < bj.EndLine
+		}
+		return bi.EndCol < bj.EndCol
+	})
+}
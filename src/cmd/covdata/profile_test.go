@@ -0,0 +1,96 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *Profile {
+	t.Helper()
+	p, err := ParseProfile(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func text(p *Profile) string {
+	var buf bytes.Buffer
+	p.Write(&buf)
+	return buf.String()
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{
+			a:    "mode: set\nx/a.go:1.2,3.4 1 1\nx/a.go:5.1,6.2 2 0\n",
+			b:    "mode: set\nx/a.go:5.1,6.2 2 1\nx/b.go:1.1,1.9 1 0\n",
+			want: "mode: set\nx/a.go:1.2,3.4 1 1\nx/a.go:5.1,6.2 2 1\nx/b.go:1.1,1.9 1 0\n",
+		},
+		{
+			a:    "mode: count\nx/a.go:1.2,3.4 1 3\nx/a.go:5.1,6.2 2 0\n",
+			b:    "mode: count\nx/a.go:1.2,3.4 1 4\n",
+			want: "mode: count\nx/a.go:1.2,3.4 1 7\nx/a.go:5.1,6.2 2 0\n",
+		},
+		{
+			a:    "mode: branch\nx/a.go:1.2,3.4 1 3\nx/a.go:2.5,2.9 br 3 0\nx/a.go:1.1,1.9 br 0 1 2\n",
+			b:    "mode: branch\nx/a.go:1.2,3.4 1 1\nx/a.go:2.5,2.9 br 0 1\n",
+			want: "mode: branch\nx/a.go:1.2,3.4 1 4\nx/a.go:1.1,1.9 br 0 1 2\nx/a.go:2.5,2.9 br 3 1\n",
+		},
+	}
+	for _, tt := range tests {
+		p := mustParse(t, tt.a)
+		if err := p.Merge(mustParse(t, tt.b)); err != nil {
+			t.Fatal(err)
+		}
+		if got := text(p); got != tt.want {
+			t.Errorf("merge:\n%s\n%s\ngot:\n%s\nwant:\n%s", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	p := mustParse(t, "mode: set\nx/a.go:1.2,3.4 1 1\n")
+	if err := p.Merge(mustParse(t, "mode: count\nx/a.go:1.2,3.4 1 1\n")); err == nil {
+		t.Errorf("merge of set and count profiles succeeded")
+	}
+	if err := p.Merge(mustParse(t, "mode: set\nx/a.go:1.2,3.4 2 1\n")); err == nil {
+		t.Errorf("merge of inconsistent blocks succeeded")
+	}
+	p = mustParse(t, "mode: branch\nx/a.go:1.2,3.4 br 1 1\n")
+	if err := p.Merge(mustParse(t, "mode: branch\nx/a.go:1.2,3.4 br 1 1 1\n")); err == nil {
+		t.Errorf("merge of inconsistent branches succeeded")
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	p := mustParse(t, "mode: count\nx/a.go:1.2,3.4 1 3\nx/a.go:5.1,6.2 2 5\n")
+	q := mustParse(t, "mode: count\nx/a.go:1.2,3.4 1 1\nx/a.go:5.1,6.2 2 0\nx/b.go:1.1,1.9 1 1\n")
+	if err := p.Subtract(q); err != nil {
+		t.Fatal(err)
+	}
+	want := "mode: count\nx/a.go:1.2,3.4 1 0\nx/a.go:5.1,6.2 2 5\n"
+	if got := text(p); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"x/a.go:1.2,3.4 1 1\n",
+		"mode: set\nx/a.go 1 1\n",
+		"mode: set\nx/a.go:1.2,3.4 1\n",
+		"mode: branch\nx/a.go:1.2,3.4 br\n",
+		"mode: branch\nx/a.go:1.2,3.4 br 1 x\n",
+	} {
+		if _, err := ParseProfile(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseProfile(%q) succeeded", s)
+		}
+	}
+}
@@ -49,7 +49,7 @@ func usage() {
 }
 
 var (
-	mode    = flag.String("mode", "", "coverage mode: set, count, atomic")
+	mode    = flag.String("mode", "", "coverage mode: set, count, atomic, branch")
 	varVar  = flag.String("var", "GoCover", "name of coverage variable to generate")
 	output  = flag.String("o", "", "file for output; default: stdout")
 	htmlOut = flag.String("html", "", "generate HTML representation of coverage profile")
@@ -128,6 +128,8 @@ func parseFlags() error {
 			counterStmt = incCounterStmt
 		case "atomic":
 			counterStmt = atomicCounterStmt
+		case "branch":
+			counterStmt = incCounterStmt
 		default:
 			return fmt.Errorf("unknown -mode %v", *mode)
 		}
@@ -152,15 +154,26 @@ type Block struct {
 	numStmt   int
 }
 
+// Branch represents a decision recorded in branch mode: the evaluation of
+// an operand of an if or for condition, which has two outcomes, true and
+// false, or the choice of a switch clause, which has one outcome per clause.
+type Branch struct {
+	startByte token.Pos
+	endByte   token.Pos
+	outcomes  int
+}
+
 // File is a wrapper for the state of a file used in the parser.
 // The basic parse tree walker is a method of this type.
 type File struct {
-	fset    *token.FileSet
-	name    string // Name of file.
-	astFile *ast.File
-	blocks  []Block
-	content []byte
-	edit    *edit.Buffer
+	fset     *token.FileSet
+	name     string // Name of file.
+	astFile  *ast.File
+	blocks   []Block
+	branches []Branch
+	nbranch  int // Number of branch outcome counters.
+	content  []byte
+	edit     *edit.Buffer
 }
 
 // findText finds text in the original source, starting at pos.
@@ -225,6 +238,9 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 		if n.Init != nil {
 			ast.Walk(f, n.Init)
 		}
+		if *mode == "branch" {
+			f.addConditionBranches(n.Cond)
+		}
 		ast.Walk(f, n.Cond)
 		ast.Walk(f, n.Body)
 		if n.Else == nil {
@@ -268,6 +284,10 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 		}
 		ast.Walk(f, n.Else)
 		return nil
+	case *ast.ForStmt:
+		if *mode == "branch" && n.Cond != nil {
+			f.addConditionBranches(n.Cond)
+		}
 	case *ast.SelectStmt:
 		// Don't annotate an empty select - creates a syntax error.
 		if n.Body == nil || len(n.Body.List) == 0 {
@@ -284,6 +304,9 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 			}
 			return nil
 		}
+		if *mode == "branch" {
+			f.addSwitchBranches(n.Switch, n.Body)
+		}
 	case *ast.TypeSwitchStmt:
 		// Don't annotate an empty type switch - creates a syntax error.
 		if n.Body == nil || len(n.Body.List) == 0 {
@@ -293,10 +316,80 @@ func (f *File) Visit(node ast.Node) ast.Visitor {
 			ast.Walk(f, n.Assign)
 			return nil
 		}
+		if *mode == "branch" {
+			f.addSwitchBranches(n.Switch, n.Body)
+		}
 	}
 	return f
 }
 
+// newBranch records a decision with the given number of outcomes and
+// returns the index of the counter for its first outcome.
+func (f *File) newBranch(start, end token.Pos, outcomes int) int {
+	first := f.nbranch
+	f.branches = append(f.branches, Branch{start, end, outcomes})
+	f.nbranch += outcomes
+	return first
+}
+
+// addConditionBranches records the outcomes of each operand of the
+// && and || operators in the condition e. For instance, given
+//
+//	if a && !b {
+//
+// it produces
+//
+//	if GoCoverBranch(0, bool(a)) && !GoCoverBranch(2, bool(b)) {
+//
+// where GoCoverBranch counts its second argument as true or false and
+// returns it. The conversion to bool handles named boolean types.
+func (f *File) addConditionBranches(e ast.Expr) {
+	switch x := e.(type) {
+	case *ast.ParenExpr:
+		f.addConditionBranches(x.X)
+		return
+	case *ast.UnaryExpr:
+		if x.Op == token.NOT {
+			f.addConditionBranches(x.X)
+			return
+		}
+	case *ast.BinaryExpr:
+		if x.Op == token.LAND || x.Op == token.LOR {
+			f.addConditionBranches(x.X)
+			f.addConditionBranches(x.Y)
+			return
+		}
+	}
+	i := f.newBranch(e.Pos(), e.End(), 2)
+	f.edit.Insert(f.offset(e.Pos()), fmt.Sprintf("%sBranch(%d, bool(", *varVar, i))
+	f.edit.Insert(f.offset(e.End()), "))")
+}
+
+// addSwitchBranches records which clause of the switch statement
+// starting at pos with the given body is chosen. A switch without a
+// default clause gets one, so that falling through all cases is
+// recorded too.
+func (f *File) addSwitchBranches(pos token.Pos, body *ast.BlockStmt) {
+	hasDefault := false
+	for _, s := range body.List {
+		if s.(*ast.CaseClause).List == nil {
+			hasDefault = true
+		}
+	}
+	outcomes := len(body.List)
+	if !hasDefault {
+		outcomes++
+	}
+	first := f.newBranch(pos, body.Lbrace, outcomes)
+	for i, s := range body.List {
+		clause := s.(*ast.CaseClause)
+		f.edit.Insert(f.offset(clause.Colon+1), fmt.Sprintf("%s.BranchCount[%d]++;", *varVar, first+i))
+	}
+	if !hasDefault {
+		f.edit.Insert(f.offset(body.Rbrace), fmt.Sprintf(";default: %s.BranchCount[%d]++;", *varVar, first+len(body.List)))
+	}
+}
+
 func annotate(name string) {
 	fset := token.NewFileSet()
 	content, err := ioutil.ReadFile(name)
@@ -639,6 +732,11 @@ func (f *File) addVariables(w io.Writer) {
 	fmt.Fprintf(w, "\tCount     [%d]uint32\n", len(f.blocks))
 	fmt.Fprintf(w, "\tPos       [3 * %d]uint32\n", len(f.blocks))
 	fmt.Fprintf(w, "\tNumStmt   [%d]uint16\n", len(f.blocks))
+	if *mode == "branch" {
+		fmt.Fprintf(w, "\tBranchCount    [%d]uint32\n", f.nbranch)
+		fmt.Fprintf(w, "\tBranchPos      [3 * %d]uint32\n", len(f.branches))
+		fmt.Fprintf(w, "\tBranchOutcomes [%d]uint16\n", len(f.branches))
+	}
 	fmt.Fprintf(w, "} {\n")
 
 	// Initialize the position array field.
@@ -677,9 +775,20 @@ func (f *File) addVariables(w io.Writer) {
 	// Close the statements-per-block array.
 	fmt.Fprintf(w, "\t},\n")
 
+	if *mode == "branch" {
+		f.addBranchVariables(w)
+	}
+
 	// Close the struct initialization.
 	fmt.Fprintf(w, "}\n")
 
+	if *mode == "branch" {
+		// The function used by addConditionBranches.
+		fmt.Fprintf(w, "\nfunc %sBranch(i int, b bool) bool {\n", *varVar)
+		fmt.Fprintf(w, "\tif b {\n\t\t%s.BranchCount[i]++\n\t} else {\n\t\t%s.BranchCount[i+1]++\n\t}\n", *varVar, *varVar)
+		fmt.Fprintf(w, "\treturn b\n}\n")
+	}
+
 	// Emit a reference to the atomic package to avoid
 	// import and not used error when there's no code in a file.
 	if *mode == "atomic" {
@@ -687,6 +796,24 @@ func (f *File) addVariables(w io.Writer) {
 	}
 }
 
+// addBranchVariables writes the initializers of the branch position and
+// outcome arrays. Positions are encoded as for blocks.
+func (f *File) addBranchVariables(w io.Writer) {
+	fmt.Fprintf(w, "\tBranchPos: [3 * %d]uint32{\n", len(f.branches))
+	for i, branch := range f.branches {
+		start := f.fset.Position(branch.startByte)
+		end := f.fset.Position(branch.endByte)
+		fmt.Fprintf(w, "\t\t%d, %d, %#x, // [%d]\n", start.Line, end.Line, (end.Column&0xFFFF)<<16|(start.Column&0xFFFF), i)
+	}
+	fmt.Fprintf(w, "\t},\n")
+
+	fmt.Fprintf(w, "\tBranchOutcomes: [%d]uint16{\n", len(f.branches))
+	for i, branch := range f.branches {
+		fmt.Fprintf(w, "\t\t%d, // %d\n", branch.outcomes, i)
+	}
+	fmt.Fprintf(w, "\t},\n")
+}
+
 // It is possible for positions to repeat when there is a line
 // directive that does not specify column information and the input
 // has not been passed through gofmt.
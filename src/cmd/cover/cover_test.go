@@ -54,6 +54,8 @@ var (
 	lineDupGo      string
 	lineDupTestGo  string
 	lineDupProfile string
+	branchDir      string
+	branchProfile  string
 )
 
 var (
@@ -104,6 +106,8 @@ func TestMain(m *testing.M) {
 	lineDupGo = filepath.Join(lineDupDir, "linedup.go")
 	lineDupTestGo = filepath.Join(lineDupDir, "linedup_test.go")
 	lineDupProfile = filepath.Join(lineDupDir, "linedup.out")
+	branchDir = filepath.Join(dir, "branch")
+	branchProfile = filepath.Join(branchDir, "branch.out")
 
 	status := m.Run()
 
@@ -561,6 +565,102 @@ func TestFuncWithDuplicateLines(t *testing.T) {
 	run(cmd, t)
 }
 
+const branchContents = `package branch
+
+func Classify(a, b int) string {
+	if a > 0 && b > 0 {
+		return "both"
+	}
+	switch a {
+	case 0:
+		return "zero"
+	case 1:
+		return "one"
+	}
+	return "other"
+}
+`
+
+const branchTestContents = `package branch
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	Classify(1, 1)
+	Classify(1, 0)
+	Classify(0, 0)
+}
+`
+
+// Test -covermode=branch and its reporting by -func and -html.
+func TestCoverBranch(t *testing.T) {
+	t.Parallel()
+	testenv.MustHaveGoRun(t)
+	buildCover(t)
+
+	if err := os.Mkdir(branchDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(branchDir, "go.mod"), []byte("module branch\n"), 0444); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(branchDir, "branch.go"), []byte(branchContents), 0444); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(branchDir, "branch_test.go"), []byte(branchTestContents), 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	// go test -covermode branch -coverprofile TMPDIR/branch.out
+	cmd := exec.Command(testenv.GoToolPath(t), "test", toolexecArg, "-covermode", "branch", "-coverprofile", branchProfile)
+	cmd.Dir = branchDir
+	run(cmd, t)
+
+	profile, err := ioutil.ReadFile(branchProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"branch/branch.go:4.5,4.10 br 2 1\n",   // a > 0
+		"branch/branch.go:4.14,4.19 br 1 1\n",  // b > 0
+		"branch/branch.go:7.2,7.11 br 1 1 0\n", // switch a
+	} {
+		if !strings.Contains(string(profile), want) {
+			t.Errorf("profile does not contain %q:\n%s", want, profile)
+		}
+	}
+
+	// testcover -func=TMPDIR/branch.out
+	cmd = exec.Command(testcover, "-func", branchProfile)
+	cmd.Dir = branchDir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Six of the seven outcomes were taken.
+	for _, re := range []string{
+		`(?m)^branch/branch.go:3:\s+Classify\s+83.3%\s+85.7%$`,
+		`(?m)^total:\s+\(branches\)\s+85.7%$`,
+	} {
+		if got, err := regexp.Match(re, out); err != nil || !got {
+			t.Errorf("-func output does not match %s:\n%s", re, out)
+		}
+	}
+
+	// testcover -html=TMPDIR/branch.out -o TMPDIR/branch.html
+	branchHTML := filepath.Join(branchDir, "branch.html")
+	cmd = exec.Command(testcover, "-html", branchProfile, "-o", branchHTML)
+	cmd.Dir = branchDir
+	run(cmd, t)
+	html, err := ioutil.ReadFile(branchHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<span class="partial" title="2 of 3 branches taken [1 1 0]">switch a </span>`; !bytes.Contains(html, []byte(want)) {
+		t.Errorf("HTML output does not contain %s", want)
+	}
+}
+
 func run(c *exec.Cmd, t *testing.T) {
 	t.Helper()
 	t.Log("running", c.Args)
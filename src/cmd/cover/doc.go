@@ -11,8 +11,17 @@ annotations to track which parts of each function are executed.
 It operates on one Go source file at a time, computing approximate
 basic block information by studying the source. It is thus more portable
 than binary-rewriting coverage tools, but also a little less capable.
-For instance, it does not probe inside && and || expressions, and can
-be mildly confused by single statements with multiple function literals.
+For instance, in the set, count, and atomic modes it does not probe
+inside && and || expressions, and it can be mildly confused by single
+statements with multiple function literals.
+
+The branch mode counts statements as the count mode does and also
+records the outcome of each decision: each operand of the && and ||
+operators in an if or for condition is counted as true or false, and
+each switch statement counts the clause it takes, including falling
+through all cases when there is no default. 'go tool cover -func' and
+'go tool cover -html' report these counts for profiles generated with
+'go test -covermode=branch'.
 
 When computing coverage of a package that uses cgo, the cover tool
 must be applied to the output of cgo preprocessing, not the input,
@@ -36,6 +36,16 @@ import (
 //	fmt/scan.go:1075:	advance			96.2%
 //	fmt/scan.go:1119:	doScanf			96.8%
 //	total:		(statements)			91.9%
+//
+// For a profile generated with -covermode=branch, each function also
+// shows the percentage of decision outcomes that were taken, or "-" if
+// the function makes no decisions, and the summary has a second line:
+//
+//	fmt/scan.go:1046:	doScan			100.0%	87.5%
+//	fmt/scan.go:1075:	advance			96.2%	-
+//	...
+//	total:		(statements)			91.9%
+//	total:		(branches)			78.4%
 
 func funcOutput(profile, outputFile string) error {
 	profiles, err := ParseProfiles(profile)
@@ -64,7 +74,9 @@ func funcOutput(profile, outputFile string) error {
 	tabber := tabwriter.NewWriter(out, 1, 8, 1, '\t', 0)
 	defer tabber.Flush()
 
+	branches := len(profiles) > 0 && profiles[0].Mode == "branch"
 	var total, covered int64
+	var outcomes, taken int64
 	for _, profile := range profiles {
 		fn := profile.FileName
 		file, err := findFile(dirs, fn)
@@ -78,12 +90,26 @@ func funcOutput(profile, outputFile string) error {
 		// Now match up functions and profile blocks.
 		for _, f := range funcs {
 			c, t := f.coverage(profile)
-			fmt.Fprintf(tabber, "%s:%d:\t%s\t%.1f%%\n", fn, f.startLine, f.name, percent(c, t))
+			if !branches {
+				fmt.Fprintf(tabber, "%s:%d:\t%s\t%.1f%%\n", fn, f.startLine, f.name, percent(c, t))
+			} else {
+				bc, bt := f.branchCoverage(profile)
+				bp := "-"
+				if bt > 0 {
+					bp = fmt.Sprintf("%.1f%%", percent(bc, bt))
+				}
+				fmt.Fprintf(tabber, "%s:%d:\t%s\t%.1f%%\t%s\n", fn, f.startLine, f.name, percent(c, t), bp)
+				outcomes += bt
+				taken += bc
+			}
 			total += t
 			covered += c
 		}
 	}
 	fmt.Fprintf(tabber, "total:\t(statements)\t%.1f%%\n", percent(covered, total))
+	if branches {
+		fmt.Fprintf(tabber, "total:\t(branches)\t%.1f%%\n", percent(taken, outcomes))
+	}
 
 	return nil
 }
@@ -166,6 +192,28 @@ func (f *FuncExtent) coverage(profile *Profile) (num, den int64) {
 	return covered, total
 }
 
+// branchCoverage returns the fraction of the decision outcomes in the function that were taken, as a numerator and denominator.
+func (f *FuncExtent) branchCoverage(profile *Profile) (num, den int64) {
+	var taken, total int64
+	for _, b := range profile.Branches {
+		if b.StartLine > f.endLine || (b.StartLine == f.endLine && b.StartCol >= f.endCol) {
+			// Past the end of the function.
+			break
+		}
+		if b.EndLine < f.startLine || (b.EndLine == f.startLine && b.EndCol <= f.startCol) {
+			// Before the beginning of the function
+			continue
+		}
+		for _, c := range b.Counts {
+			total++
+			if c > 0 {
+				taken++
+			}
+		}
+	}
+	return taken, total
+}
+
 // Pkg describes a single package, compatible with the JSON output from 'go list'; see 'go help list'.
 type Pkg struct {
 	ImportPath string
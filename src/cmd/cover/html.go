@@ -39,6 +39,9 @@ func htmlOutput(profile, outfile string) error {
 		if profile.Mode == "set" {
 			d.Set = true
 		}
+		if profile.Mode == "branch" {
+			d.Branch = true
+		}
 		file, err := findFile(dirs, fn)
 		if err != nil {
 			return err
@@ -48,7 +51,7 @@ func htmlOutput(profile, outfile string) error {
 			return fmt.Errorf("can't read %q: %v", fn, err)
 		}
 		var buf strings.Builder
-		err = htmlGen(&buf, src, profile.Boundaries(src))
+		err = htmlGen(&buf, src, profile.Boundaries(src), profile.BranchBoundaries(src))
 		if err != nil {
 			return err
 		}
@@ -109,9 +112,15 @@ func percentCovered(p *Profile) float64 {
 
 // htmlGen generates an HTML coverage report with the provided filename,
 // source code, and tokens, and writes it to the given Writer.
-func htmlGen(w io.Writer, src []byte, boundaries []Boundary) error {
+// The branches, if any, mark partially covered decisions; they lie
+// within the blocks, so their spans nest inside the block spans.
+func htmlGen(w io.Writer, src []byte, boundaries, branches []Boundary) error {
 	dst := bufio.NewWriter(w)
 	for i := range src {
+		for len(branches) > 0 && branches[0].Offset == i && !branches[0].Start {
+			dst.WriteString("</span>")
+			branches = branches[1:]
+		}
 		for len(boundaries) > 0 && boundaries[0].Offset == i {
 			b := boundaries[0]
 			if b.Start {
@@ -125,6 +134,10 @@ func htmlGen(w io.Writer, src []byte, boundaries []Boundary) error {
 			}
 			boundaries = boundaries[1:]
 		}
+		for len(branches) > 0 && branches[0].Offset == i && branches[0].Start {
+			fmt.Fprintf(dst, `<span class="partial" title="%v">`, branchTitle(branches[0].Outcomes))
+			branches = branches[1:]
+		}
 		switch b := src[i]; b {
 		case '>':
 			dst.WriteString("&gt;")
@@ -141,6 +154,17 @@ func htmlGen(w io.Writer, src []byte, boundaries []Boundary) error {
 	return dst.Flush()
 }
 
+// branchTitle describes the outcome counts of a decision.
+func branchTitle(counts []int) string {
+	taken := 0
+	for _, c := range counts {
+		if c > 0 {
+			taken++
+		}
+	}
+	return fmt.Sprintf("%d of %d branches taken %v", taken, len(counts), counts)
+}
+
 // rgb returns an rgb value for the specified coverage value
 // between 0 (no coverage) and 10 (max coverage).
 func rgb(n int) string {
@@ -168,8 +192,9 @@ var htmlTemplate = template.Must(template.New("html").Funcs(template.FuncMap{
 }).Parse(tmplHTML))
 
 type templateData struct {
-	Files []*templateFile
-	Set   bool
+	Files  []*templateFile
+	Set    bool
+	Branch bool
 }
 
 type templateFile struct {
@@ -216,6 +241,7 @@ const tmplHTML = `
 				margin: 0 5px;
 			}
 			{{colors}}
+			.partial { border-bottom: 2px dotted rgb(255, 200, 0); }
 		</style>
 	</head>
 	<body>
@@ -245,6 +271,9 @@ const tmplHTML = `
 				<span class="cov9">*</span>
 				<span class="cov10">high coverage</span>
 			{{end}}
+			{{if .Branch}}
+				<span class="partial">partially taken branch</span>
+			{{end}}
 			</div>
 		</div>
 		<div id="content">
@@ -24,6 +24,7 @@ type Profile struct {
 	FileName string
 	Mode     string
 	Blocks   []ProfileBlock
+	Branches []ProfileBranch
 }
 
 // ProfileBlock represents a single block of profiling data.
@@ -33,6 +34,14 @@ type ProfileBlock struct {
 	NumStmt, Count      int
 }
 
+// ProfileBranch represents the outcome counts of a single decision
+// in a profile generated with -covermode=branch.
+type ProfileBranch struct {
+	StartLine, StartCol int
+	EndLine, EndCol     int
+	Counts              []int
+}
+
 type byFileName []*Profile
 
 func (p byFileName) Len() int           { return len(p) }
@@ -54,6 +63,10 @@ func ParseProfiles(fileName string) ([]*Profile, error) {
 	// Rest of file is in the format
 	//	encoding/base64/base64.go:34.44,37.40 3 1
 	// where the fields are: name.go:line.column,line.column numberOfStatements count
+	// In branch mode, there are also lines in the format
+	//	encoding/base64/base64.go:35.5,35.14 br 2 1
+	// where the fields are: name.go:line.column,line.column br count...
+	// giving the number of times each outcome of a decision was taken.
 	s := bufio.NewScanner(buf)
 	mode := ""
 	for s.Scan() {
@@ -66,6 +79,28 @@ func ParseProfiles(fileName string) ([]*Profile, error) {
 			mode = line[len(p):]
 			continue
 		}
+		if m := branchRe.FindStringSubmatch(line); m != nil {
+			p := files[m[1]]
+			if p == nil {
+				p = &Profile{
+					FileName: m[1],
+					Mode:     mode,
+				}
+				files[m[1]] = p
+			}
+			var counts []int
+			for _, f := range strings.Fields(m[6]) {
+				counts = append(counts, toInt(f))
+			}
+			p.Branches = append(p.Branches, ProfileBranch{
+				StartLine: toInt(m[2]),
+				StartCol:  toInt(m[3]),
+				EndLine:   toInt(m[4]),
+				EndCol:    toInt(m[5]),
+				Counts:    counts,
+			})
+			continue
+		}
 		m := lineRe.FindStringSubmatch(line)
 		if m == nil {
 			return nil, fmt.Errorf("line %q doesn't match expected format: %v", m, lineRe)
@@ -116,6 +151,30 @@ func ParseProfiles(fileName string) ([]*Profile, error) {
 			j++
 		}
 		p.Blocks = p.Blocks[:j]
+
+		sort.Sort(branchesByStart(p.Branches))
+		j = 1
+		for i := 1; i < len(p.Branches); i++ {
+			b := p.Branches[i]
+			last := p.Branches[j-1]
+			if b.StartLine == last.StartLine &&
+				b.StartCol == last.StartCol &&
+				b.EndLine == last.EndLine &&
+				b.EndCol == last.EndCol {
+				if len(b.Counts) != len(last.Counts) {
+					return nil, fmt.Errorf("inconsistent number of outcomes: changed from %d to %d", len(last.Counts), len(b.Counts))
+				}
+				for k, c := range b.Counts {
+					last.Counts[k] += c
+				}
+				continue
+			}
+			p.Branches[j] = b
+			j++
+		}
+		if len(p.Branches) > 0 {
+			p.Branches = p.Branches[:j]
+		}
 	}
 	// Generate a sorted slice.
 	profiles := make([]*Profile, 0, len(files))
@@ -135,6 +194,17 @@ func (b blocksByStart) Less(i, j int) bool {
 	return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
 }
 
+type branchesByStart []ProfileBranch
+
+func (b branchesByStart) Len() int      { return len(b) }
+func (b branchesByStart) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b branchesByStart) Less(i, j int) bool {
+	bi, bj := b[i], b[j]
+	return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
+}
+
+var branchRe = regexp.MustCompile(`^(.+):([0-9]+).([0-9]+),([0-9]+).([0-9]+) br((?: [0-9]+)+)$`)
+
 var lineRe = regexp.MustCompile(`^(.+):([0-9]+).([0-9]+),([0-9]+).([0-9]+) ([0-9]+) ([0-9]+)$`)
 
 func toInt(s string) int {
@@ -154,6 +224,10 @@ type Boundary struct {
 	Count  int     // Event count from the cover profile.
 	Norm   float64 // Count normalized to [0..1].
 	Index  int     // Order in input file.
+
+	// Outcomes holds, at the start of a partially covered decision
+	// reported by BranchBoundaries, the count of each of its outcomes.
+	Outcomes []int
 }
 
 // Boundaries returns a Profile as a set of Boundary objects within the provided src.
@@ -206,6 +280,43 @@ func (p *Profile) Boundaries(src []byte) (boundaries []Boundary) {
 	return
 }
 
+// BranchBoundaries returns the decisions of a Profile in branch mode that
+// were reached but not all of whose outcomes were taken, as a set of
+// Boundary objects within the provided src. The Outcomes field of each
+// start Boundary holds the outcome counts.
+func (p *Profile) BranchBoundaries(src []byte) (boundaries []Boundary) {
+	// Map each line to the offset of its first byte.
+	lines := []int{0, 0}
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	offset := func(line, col int) int {
+		if line >= len(lines) {
+			return len(src)
+		}
+		return lines[line] + col - 1
+	}
+	for _, b := range p.Branches {
+		reached, taken := false, 0
+		for _, c := range b.Counts {
+			if c > 0 {
+				reached = true
+				taken++
+			}
+		}
+		if !reached || taken == len(b.Counts) {
+			continue
+		}
+		boundaries = append(boundaries,
+			Boundary{Offset: offset(b.StartLine, b.StartCol), Start: true, Outcomes: b.Counts, Index: len(boundaries)},
+			Boundary{Offset: offset(b.EndLine, b.EndCol), Index: len(boundaries) + 1})
+	}
+	sort.Sort(boundariesByPos(boundaries))
+	return
+}
+
 type boundariesByPos []Boundary
 
 func (b boundariesByPos) Len() int      { return len(b) }
@@ -37,12 +37,15 @@ var bootstrapDirs = []string{
 	"cmd/asm/internal/lex",
 	"cmd/cgo",
 	"cmd/compile",
+	"cmd/compile/internal/abi",
 	"cmd/compile/internal/amd64",
 	"cmd/compile/internal/arm",
 	"cmd/compile/internal/arm64",
 	"cmd/compile/internal/gc",
+	"cmd/compile/internal/logopt",
 	"cmd/compile/internal/mips",
 	"cmd/compile/internal/mips64",
+	"cmd/compile/internal/pgo",
 	"cmd/compile/internal/ppc64",
 	"cmd/compile/internal/types",
 	"cmd/compile/internal/s390x",
@@ -34,6 +34,11 @@
 // The -all flag causes doc to print all documentation for the package and
 // all its visible symbols. The argument must identify a package.
 //
+// The -http flag causes doc to serve the documentation of all packages
+// it can find as HTML on the given address, instead of printing anything.
+// In module mode these are the packages of the main module, of its
+// dependencies in the module cache and of the standard library.
+//
 // For complete documentation, run "go help doc".
 package main
 
@@ -52,12 +57,13 @@ import (
 )
 
 var (
-	unexported bool // -u flag
-	matchCase  bool // -c flag
-	showAll    bool // -all flag
-	showCmd    bool // -cmd flag
-	showSrc    bool // -src flag
-	short      bool // -short flag
+	unexported bool   // -u flag
+	matchCase  bool   // -c flag
+	showAll    bool   // -all flag
+	showCmd    bool   // -cmd flag
+	showSrc    bool   // -src flag
+	short      bool   // -short flag
+	httpAddr   string // -http flag
 )
 
 // usage is a replacement usage function for the flags package.
@@ -68,6 +74,7 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "\tgo doc <sym>[.<method>]\n")
 	fmt.Fprintf(os.Stderr, "\tgo doc [<pkg>].<sym>[.<method>]\n")
 	fmt.Fprintf(os.Stderr, "\tgo doc <pkg> <sym>[.<method>]\n")
+	fmt.Fprintf(os.Stderr, "\tgo doc -http=<addr>\n")
 	fmt.Fprintf(os.Stderr, "For more information run\n")
 	fmt.Fprintf(os.Stderr, "\tgo help doc\n\n")
 	fmt.Fprintf(os.Stderr, "Flags:\n")
@@ -96,7 +103,14 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 	flagSet.BoolVar(&showCmd, "cmd", false, "show symbols with package docs even if package is a command")
 	flagSet.BoolVar(&showSrc, "src", false, "show source code for symbol")
 	flagSet.BoolVar(&short, "short", false, "one-line representation for each symbol")
+	flagSet.StringVar(&httpAddr, "http", "", "serve HTML documentation of all packages on this address (for example, localhost:6060)")
 	flagSet.Parse(args)
+	if httpAddr != "" {
+		if flagSet.NArg() != 0 {
+			usage()
+		}
+		return serveHTTP(writer, httpAddr)
+	}
 	var paths []string
 	var symbol, method string
 	// Loop until something is printed.
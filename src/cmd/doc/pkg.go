@@ -245,7 +245,7 @@ func (pkg *Package) emit(comment string, node ast.Node) {
 		}
 		if comment != "" && !showSrc {
 			pkg.newlines(1)
-			doc.ToText(&pkg.buf, comment, indent, indent+indent, indentedWidth)
+			pkg.ToText(&pkg.buf, comment, indent, indent+indent)
 			pkg.newlines(2) // Blank line after comment to separate from next item.
 		} else {
 			pkg.newlines(1)
@@ -443,11 +443,22 @@ func joinStrings(ss []string) string {
 	return strings.Join(ss, ", ")
 }
 
+// ToText formats the doc comment text like doc.ToText, wrapped to
+// indentedWidth, but resolves its doc links against the package.
+func (pkg *Package) ToText(w io.Writer, text, prefix, codePrefix string) {
+	d := pkg.doc.Parser().Parse(text)
+	pr := pkg.doc.Printer()
+	pr.TextPrefix = prefix
+	pr.TextCodePrefix = codePrefix
+	pr.TextWidth = indentedWidth
+	w.Write(pr.Text(d))
+}
+
 // allDoc prints all the docs for the package.
 func (pkg *Package) allDoc() {
 	defer pkg.flush()
 
-	doc.ToText(&pkg.buf, pkg.doc.Doc, "", indent, indentedWidth)
+	pkg.ToText(&pkg.buf, pkg.doc.Doc, "", indent)
 	pkg.newlines(1)
 
 	printed := make(map[*ast.GenDecl]bool)
@@ -508,7 +519,7 @@ func (pkg *Package) packageDoc() {
 	defer pkg.flush()
 
 	if !short {
-		doc.ToText(&pkg.buf, pkg.doc.Doc, "", indent, indentedWidth)
+		pkg.ToText(&pkg.buf, pkg.doc.Doc, "", indent)
 		pkg.newlines(1)
 	}
 
@@ -960,7 +971,7 @@ func (pkg *Package) printMethodDoc(symbol, method string) bool {
 			if match(method, name) {
 				if iMethod.Doc != nil {
 					for _, comment := range iMethod.Doc.List {
-						doc.ToText(&pkg.buf, comment.Text, "", indent, indentedWidth)
+						pkg.ToText(&pkg.buf, comment.Text, "", indent)
 					}
 				}
 				s := pkg.oneLineNode(iMethod.Type)
@@ -1014,7 +1025,7 @@ func (pkg *Package) printFieldDoc(symbol, fieldName string) bool {
 					// To present indented blocks in comments correctly, process the comment as
 					// a unit before adding the leading // to each line.
 					docBuf := bytes.Buffer{}
-					doc.ToText(&docBuf, field.Doc.Text(), "", indent, indentedWidth)
+					pkg.ToText(&docBuf, field.Doc.Text(), "", indent)
 					scanner := bufio.NewScanner(&docBuf)
 					for scanner.Scan() {
 						fmt.Fprintf(&pkg.buf, "%s// %s\n", indent, scanner.Bytes())
@@ -0,0 +1,546 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTML documentation server for go doc -http.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// serveHTTP serves the documentation of all the packages that go doc
+// can find, which in module mode are those of the main module and its
+// dependencies, at addr. It only returns if the server fails.
+func serveHTTP(w io.Writer, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Serving documentation on http://%s/\n", ln.Addr())
+	return http.Serve(ln, newDocServer())
+}
+
+// A docServer serves package documentation as HTML.
+//
+//	/                     index of all packages
+//	/pkg/<importpath>     documentation of a package
+//	/src/<importpath>/<file>  source file of a package
+type docServer struct {
+	once   sync.Once
+	pkgs   []Dir          // sorted by import path
+	byPath map[string]Dir // by import path
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	synopses map[string]string // by directory
+}
+
+func newDocServer() *docServer {
+	s := &docServer{
+		mux:      http.NewServeMux(),
+		synopses: make(map[string]string),
+	}
+	s.mux.HandleFunc("/", s.serveIndex)
+	s.mux.HandleFunc("/pkg/", s.servePackage)
+	s.mux.HandleFunc("/src/", s.serveSource)
+	return s
+}
+
+func (s *docServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.once.Do(s.scan)
+	s.mux.ServeHTTP(w, r)
+}
+
+// scan finds all package directories. When an import path occurs
+// more than once, the first directory wins, as it does for go doc.
+func (s *docServer) scan() {
+	s.byPath = make(map[string]Dir)
+	dirs.Reset()
+	for {
+		d, ok := dirs.Next()
+		if !ok {
+			break
+		}
+		if _, dup := s.byPath[d.importPath]; dup || d.importPath == "" {
+			continue
+		}
+		s.byPath[d.importPath] = d
+		s.pkgs = append(s.pkgs, d)
+	}
+	sort.Slice(s.pkgs, func(i, j int) bool { return s.pkgs[i].importPath < s.pkgs[j].importPath })
+}
+
+// indexEntry is a package in the index.
+type indexEntry struct {
+	Path     string
+	Synopsis string
+}
+
+// indexGroup is a group of packages in the index: the standard
+// library, or the packages of a module.
+type indexGroup struct {
+	Name string
+	Pkgs []indexEntry
+}
+
+func (s *docServer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	goroot := filepath.Join(buildCtx.GOROOT, "src")
+	var groups []*indexGroup
+	byName := make(map[string]*indexGroup)
+	for _, d := range s.pkgs {
+		name := "Standard library"
+		if _, ok := trim(filepath.ToSlash(d.dir), filepath.ToSlash(goroot)); !ok {
+			name = moduleOf(d)
+		}
+		g := byName[name]
+		if g == nil {
+			g = &indexGroup{Name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		g.Pkgs = append(g.Pkgs, indexEntry{Path: d.importPath, Synopsis: s.synopsis(d.dir)})
+	}
+	// The standard library comes last, after the packages of the user.
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[j].Name == "Standard library" && groups[i].Name != "Standard library"
+	})
+	render(w, "index", groups)
+}
+
+// moduleOf returns the name of the group of d in the index: the
+// module that contains it, or its GOPATH directory.
+func moduleOf(d Dir) string {
+	for _, root := range codeRoots() {
+		if root.importPath == "" {
+			if p, ok := trim(filepath.ToSlash(d.dir), filepath.ToSlash(root.dir)); ok && p != d.dir {
+				return root.dir
+			}
+			continue
+		}
+		if d.importPath == root.importPath || strings.HasPrefix(d.importPath, root.importPath+"/") {
+			return root.importPath
+		}
+	}
+	return "Other packages"
+}
+
+// synopsis returns the first sentence of the package documentation
+// of the package in dir.
+func (s *docServer) synopsis(dir string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if syn, ok := s.synopses[dir]; ok {
+		return syn
+	}
+	syn := ""
+	if pkg, err := build.ImportDir(dir, build.ImportComment); err == nil {
+		fset := token.NewFileSet()
+		for _, name := range append(pkg.GoFiles, pkg.CgoFiles...) {
+			f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly|parser.ParseComments)
+			if err == nil && f.Doc != nil {
+				syn = doc.Synopsis(f.Doc.Text())
+				break
+			}
+		}
+	}
+	s.synopses[dir] = syn
+	return syn
+}
+
+// pkgPage is the documentation of a package.
+type pkgPage struct {
+	Path     string
+	Name     string
+	IsCmd    bool
+	Doc      string
+	Examples []*exampleDoc // of the package itself
+	Consts   []*valueDoc
+	Vars     []*valueDoc
+	Funcs    []*funcDoc
+	Types    []*typeDoc
+	Files    []string
+	Subdirs  []indexEntry
+}
+
+type valueDoc struct {
+	Decl string
+	Doc  string
+	Src  string
+}
+
+type funcDoc struct {
+	Name     string
+	Anchor   string
+	Decl     string
+	Doc      string
+	Src      string
+	Examples []*exampleDoc
+}
+
+type typeDoc struct {
+	funcDoc
+	Consts  []*valueDoc
+	Vars    []*valueDoc
+	Funcs   []*funcDoc
+	Methods []*funcDoc
+}
+
+type exampleDoc struct {
+	Name   string
+	Doc    string
+	Code   string
+	Output string
+}
+
+func (s *docServer) servePackage(w http.ResponseWriter, r *http.Request) {
+	importPath := strings.Trim(strings.TrimPrefix(r.URL.Path, "/pkg/"), "/")
+	d, ok := s.byPath[importPath]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no such package %s", importPath), http.StatusNotFound)
+		return
+	}
+	page, err := s.loadPackage(d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	render(w, "package", page)
+}
+
+// loadPackage parses the package in d and its tests, and returns its
+// documentation page.
+func (s *docServer) loadPackage(d Dir) (*pkgPage, error) {
+	page := &pkgPage{Path: d.importPath}
+	for _, p := range s.pkgs {
+		if strings.HasPrefix(p.importPath, d.importPath+"/") {
+			page.Subdirs = append(page.Subdirs, indexEntry{Path: p.importPath, Synopsis: s.synopsis(p.dir)})
+		}
+	}
+
+	bpkg, err := build.ImportDir(d.dir, build.ImportComment)
+	if _, ok := err.(*build.NoGoError); ok {
+		// A directory with only subdirectories or only tests.
+		return page, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	page.Name = bpkg.Name
+	page.IsCmd = bpkg.IsCommand()
+
+	fset := token.NewFileSet()
+	parse := func(names []string) ([]*ast.File, error) {
+		var files []*ast.File
+		for _, name := range names {
+			f, err := parser.ParseFile(fset, filepath.Join(d.dir, name), nil, parser.ParseComments)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, f)
+		}
+		return files, nil
+	}
+	files, err := parse(append(append([]string(nil), bpkg.GoFiles...), bpkg.CgoFiles...))
+	if err != nil {
+		return nil, err
+	}
+	testFiles, err := parse(append(append([]string(nil), bpkg.TestGoFiles...), bpkg.XTestGoFiles...))
+	if err != nil {
+		return nil, err
+	}
+	page.Files = append(append([]string(nil), bpkg.GoFiles...), bpkg.CgoFiles...)
+
+	astPkg := &ast.Package{Name: bpkg.Name, Files: make(map[string]*ast.File)}
+	for i, f := range files {
+		astPkg.Files[page.Files[i]] = f
+	}
+	var mode doc.Mode
+	if unexported {
+		mode |= doc.AllDecls
+	}
+	dpkg := doc.New(astPkg, d.importPath, mode)
+	page.Doc = dpkg.Doc
+
+	// Attach the examples to what they are examples of.
+	examples := make(map[string][]*exampleDoc)
+	for _, ex := range doc.Examples(testFiles...) {
+		name, suffix := ex.Name, ""
+		if i := strings.LastIndex(name, "_"); i >= 0 && i+1 < len(name) && !token.IsExported(name[i+1:]) {
+			name, suffix = name[:i], name[i+1:]
+		}
+		name = strings.Replace(name, "_", ".", 1)
+		examples[name] = append(examples[name], &exampleDoc{
+			Name:   suffix,
+			Doc:    ex.Doc,
+			Code:   exampleCode(fset, ex),
+			Output: ex.Output,
+		})
+	}
+	page.Examples = examples[""]
+
+	src := func(n ast.Node) string {
+		pos := fset.Position(n.Pos())
+		return fmt.Sprintf("/src/%s/%s#L%d", d.importPath, filepath.Base(pos.Filename), pos.Line)
+	}
+	values := func(vals []*doc.Value) []*valueDoc {
+		var docs []*valueDoc
+		for _, v := range vals {
+			docs = append(docs, &valueDoc{Decl: nodeString(fset, v.Decl), Doc: v.Doc, Src: src(v.Decl)})
+		}
+		return docs
+	}
+	funcs := func(fns []*doc.Func, recv string) []*funcDoc {
+		var docs []*funcDoc
+		for _, f := range fns {
+			anchor := f.Name
+			if recv != "" {
+				anchor = recv + "." + f.Name
+			}
+			docs = append(docs, &funcDoc{
+				Name:     f.Name,
+				Anchor:   anchor,
+				Decl:     nodeString(fset, f.Decl),
+				Doc:      f.Doc,
+				Src:      src(f.Decl),
+				Examples: examples[anchor],
+			})
+		}
+		return docs
+	}
+	page.Consts = values(dpkg.Consts)
+	page.Vars = values(dpkg.Vars)
+	page.Funcs = funcs(dpkg.Funcs, "")
+	for _, t := range dpkg.Types {
+		page.Types = append(page.Types, &typeDoc{
+			funcDoc: funcDoc{
+				Name:     t.Name,
+				Anchor:   t.Name,
+				Decl:     nodeString(fset, t.Decl),
+				Doc:      t.Doc,
+				Src:      src(t.Decl),
+				Examples: examples[t.Name],
+			},
+			Consts:  values(t.Consts),
+			Vars:    values(t.Vars),
+			Funcs:   funcs(t.Funcs, ""),
+			Methods: funcs(t.Methods, t.Name),
+		})
+	}
+	return page, nil
+}
+
+// nodeString returns the formatted source of a declaration.
+func nodeString(fset *token.FileSet, n interface{}) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, n); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return buf.String()
+}
+
+// exampleCode returns the code of an example: the whole program if
+// it needs one, and the body of the example function otherwise.
+func exampleCode(fset *token.FileSet, ex *doc.Example) string {
+	if ex.Play != nil {
+		return nodeString(fset, ex.Play)
+	}
+	code := nodeString(fset, &printer.CommentedNode{Node: ex.Code, Comments: ex.Comments})
+	if strings.HasPrefix(code, "{") && strings.HasSuffix(code, "}") {
+		// Remove the braces and the indentation of the body.
+		lines := strings.Split(strings.TrimSpace(code[1:len(code)-1]), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimPrefix(line, "\t")
+		}
+		code = strings.Join(lines, "\n")
+	}
+	// The output is shown separately.
+	if loc := exampleOutputRx.FindStringIndex(code); loc != nil {
+		code = strings.TrimSpace(code[:loc[0]])
+	}
+	return code
+}
+
+var exampleOutputRx = regexp.MustCompile(`(?i)//[[:space:]]*(unordered )?output:`)
+
+func (s *docServer) serveSource(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/src/")
+	importPath, file := path.Split(p)
+	importPath = strings.TrimSuffix(importPath, "/")
+	d, ok := s.byPath[importPath]
+	if !ok || !strings.HasSuffix(file, ".go") {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(d.dir, file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	render(w, "source", struct {
+		Path  string
+		File  string
+		Lines []string
+	}{importPath, file, lines})
+}
+
+// render executes the named template with data and writes the
+// result to w, or an error if execution fails.
+func render(w http.ResponseWriter, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Print(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"comment": func(text string) template.HTML {
+		var buf bytes.Buffer
+		doc.ToHTML(&buf, text, nil)
+		return template.HTML(buf.String())
+	},
+	"add": func(a, b int) int { return a + b },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; max-width: 60em; line-height: 1.4; }
+pre { background: #f0f0f0; padding: 0.5em; overflow-x: auto; }
+a { color: #375eab; text-decoration: none; }
+a:hover { text-decoration: underline; }
+h2 { border-bottom: 1px solid #ddd; }
+table.pkgs td { padding-right: 2em; vertical-align: top; }
+table.source td { font-family: monospace; white-space: pre; padding: 0 0.5em; }
+table.source td.num { color: #999; text-align: right; user-select: none; }
+table.source tr:target { background: #ffffa0; }
+.example { border-left: 3px solid #ddd; padding-left: 1em; margin: 1em 0; }
+</style>
+</head>
+<body>
+<p><a href="/">Packages</a></p>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" "Packages"}}
+<h1>Packages</h1>
+{{range .}}
+<h2>{{.Name}}</h2>
+<table class="pkgs">
+{{range .Pkgs}}<tr><td><a href="/pkg/{{.Path}}">{{.Path}}</a></td><td>{{.Synopsis}}</td></tr>
+{{end}}</table>
+{{end}}
+{{template "footer"}}{{end}}
+
+{{define "examples"}}{{range .}}
+<div class="example">
+<p><b>Example{{if .Name}} ({{.Name}}){{end}}</b></p>
+{{comment .Doc}}
+<pre>{{.Code}}</pre>
+{{if .Output}}<p>Output:</p>
+<pre>{{.Output}}</pre>{{end}}
+</div>
+{{end}}{{end}}
+
+{{define "values"}}{{range .}}
+<pre><a href="{{.Src}}" title="source">{{.Decl}}</a></pre>
+{{comment .Doc}}
+{{end}}{{end}}
+
+{{define "func"}}
+<h3 id="{{.Anchor}}">func <a href="{{.Src}}" title="source">{{.Name}}</a></h3>
+<pre>{{.Decl}}</pre>
+{{comment .Doc}}
+{{template "examples" .Examples}}
+{{end}}
+
+{{define "package"}}{{template "header" .Path}}
+<h1>{{if .IsCmd}}Command {{.Path}}{{else if .Name}}Package {{.Name}}{{else}}Directory {{.Path}}{{end}}</h1>
+{{if .Name}}{{if not .IsCmd}}<pre>import "{{.Path}}"</pre>{{end}}
+{{comment .Doc}}
+{{template "examples" .Examples}}
+{{if not .IsCmd}}
+<h2 id="pkg-index">Index</h2>
+<ul>
+{{if .Consts}}<li><a href="#pkg-constants">Constants</a></li>{{end}}
+{{if .Vars}}<li><a href="#pkg-variables">Variables</a></li>{{end}}
+{{range .Funcs}}<li><a href="#{{.Anchor}}">func {{.Name}}</a></li>
+{{end}}{{range $t := .Types}}<li><a href="#{{.Anchor}}">type {{.Name}}</a>
+{{if or .Funcs .Methods}}<ul>
+{{range .Funcs}}<li><a href="#{{.Anchor}}">func {{.Name}}</a></li>
+{{end}}{{range .Methods}}<li><a href="#{{.Anchor}}">func ({{$t.Name}}) {{.Name}}</a></li>
+{{end}}</ul>{{end}}</li>
+{{end}}</ul>
+{{if .Consts}}<h2 id="pkg-constants">Constants</h2>{{template "values" .Consts}}{{end}}
+{{if .Vars}}<h2 id="pkg-variables">Variables</h2>{{template "values" .Vars}}{{end}}
+{{range .Funcs}}{{template "func" .}}{{end}}
+{{range .Types}}
+<h2 id="{{.Anchor}}">type <a href="{{.Src}}" title="source">{{.Name}}</a></h2>
+<pre>{{.Decl}}</pre>
+{{comment .Doc}}
+{{template "examples" .Examples}}
+{{template "values" .Consts}}
+{{template "values" .Vars}}
+{{range .Funcs}}{{template "func" .}}{{end}}
+{{range .Methods}}{{template "func" .}}{{end}}
+{{end}}
+{{end}}
+<h2 id="pkg-files">Files</h2>
+<p>{{range .Files}}<a href="/src/{{$.Path}}/{{.}}">{{.}}</a> {{end}}</p>
+{{end}}
+{{if .Subdirs}}<h2 id="pkg-subdirectories">Subdirectories</h2>
+<table class="pkgs">
+{{range .Subdirs}}<tr><td><a href="/pkg/{{.Path}}">{{.Path}}</a></td><td>{{.Synopsis}}</td></tr>
+{{end}}</table>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "source"}}{{template "header" .File}}
+<h1><a href="/pkg/{{.Path}}">{{.Path}}</a>/{{.File}}</h1>
+<table class="source">
+{{range $i, $line := .Lines}}<tr id="L{{add $i 1}}"><td class="num"><a href="#L{{add $i 1}}">{{add $i 1}}</a></td><td>{{$line}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+`))
@@ -0,0 +1,90 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	maybeSkip(t)
+	ts := httptest.NewServer(newDocServer())
+	defer ts.Close()
+
+	for _, test := range []struct {
+		path   string
+		status int
+		yes    []string // Regular expressions that should match.
+		no     []string // Regular expressions that should not match.
+	}{
+		{
+			"/",
+			200,
+			[]string{
+				`<h2>Standard library</h2>`,
+				`<a href="/pkg/testdata">testdata</a></td><td>Package comment.</td>`,
+				`<a href="/pkg/go/doc">go/doc</a>`,
+			},
+			nil,
+		},
+		{
+			"/pkg/testdata",
+			200,
+			[]string{
+				`<h1>Package pkg</h1>`,
+				`<pre>import "testdata"</pre>`,
+				`<p>\nPackage comment.\n</p>`,
+				`<a href="#ExportedType.ExportedMethod">func \(ExportedType\) ExportedMethod</a>`,
+				`<h3 id="ExportedFunc">func <a href="/src/testdata/pkg.go#L57" title="source">ExportedFunc</a></h3>`,
+				`(?s)Package example.*<pre>fmt.Println\(pkg.ExportedConstant\)</pre>.*Output:.*<pre>1\n</pre>`,
+				`(?s)id="ExportedType.ExportedMethod".*Example of calling ExportedMethod.*var t pkg.ExportedType`,
+				`<a href="/pkg/testdata/nested">testdata/nested</a>`,
+				`<a href="/src/testdata/pkg.go">pkg.go</a>`,
+			},
+			[]string{
+				`internalFunc`,
+				`unexportedMethod`,
+			},
+		},
+		{
+			"/src/testdata/pkg.go",
+			200,
+			[]string{
+				`<tr id="L57"><td class="num"><a href="#L57">57</a></td><td>func ExportedFunc\(a int\) bool {`,
+			},
+			nil,
+		},
+		{"/pkg/no/such/package", 404, nil, nil},
+		{"/src/testdata/nosuchfile.go", 404, nil, nil},
+		{"/src/testdata/../../../go.mod", 404, nil, nil},
+	} {
+		resp, err := ts.Client().Get(ts.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: got status %d, want %d", test.path, resp.StatusCode, test.status)
+			continue
+		}
+		for _, re := range test.yes {
+			if !regexp.MustCompile(re).Match(body) {
+				t.Errorf("%s: no match for %#q in:\n%s", test.path, re, body)
+			}
+		}
+		for _, re := range test.no {
+			if regexp.MustCompile(re).Match(body) {
+				t.Errorf("%s: unexpected match for %#q", test.path, re)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkg_test
+
+import (
+	"fmt"
+
+	"cmd/doc/testdata"
+)
+
+// Package example.
+func Example() {
+	fmt.Println(pkg.ExportedConstant)
+	// Output: 1
+}
+
+// Example of calling ExportedMethod.
+func ExampleExportedType_ExportedMethod() {
+	var t pkg.ExportedType
+	fmt.Println(t.ExportedMethod(1))
+}
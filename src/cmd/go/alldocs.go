@@ -30,6 +30,7 @@
 // 	tool        run specified go tool
 // 	version     print Go version
 // 	vet         report likely mistakes in packages
+// 	vuln        report reachable known vulnerabilities
 //
 // Use "go help <command>" for more information about a command.
 //
@@ -97,6 +98,18 @@
 //
 // The -i flag installs the packages that are dependencies of the target.
 //
+// The -cover flag builds the target with coverage instrumentation.
+// When a program built with -cover exits, by returning from main.main
+// or calling os.Exit, it writes a coverage counter file to the directory
+// named by the GOCOVERDIR environment variable. Use 'go tool covdata' to
+// merge counter files and to convert them to a coverage profile for
+// 'go tool cover'. By default, the packages named on the command line
+// and, in module mode, all packages in the main module are instrumented.
+// The -covermode and -coverpkg flags set the coverage mode and the
+// packages to instrument as for 'go test' (see 'go help testflag'), and
+// imply -cover. The coverage flags are accepted by the build, install,
+// and run commands.
+//
 // The build flags are shared by the build, clean, get, install, list, run,
 // and test commands:
 //
@@ -154,6 +167,12 @@
 // 		install and load all packages from dir instead of the usual locations.
 // 		For example, when building with a non-standard configuration,
 // 		use -pkgdir to keep generated packages in a separate location.
+// 	-pgo file
+// 		use the CPU profile in file, in the format written by runtime/pprof,
+// 		for profile-guided optimization. The gc compiler uses the profile
+// 		to inline and devirtualize hot calls and to lay out hot code paths.
+// 		The profile is applied to all packages in the build, and its
+// 		content is part of each package's build cache key.
 // 	-tags tag,list
 // 		a comma-separated list of build tags to consider satisfied during the
 // 		build. For more information about build tags, see the description of
@@ -363,6 +382,13 @@
 // 		Treat a command (package main) like a regular package.
 // 		Otherwise package main's exported symbols are hidden
 // 		when showing the package's top-level documentation.
+// 	-http addr
+// 		Instead of printing documentation, serve the documentation
+// 		of all packages as HTML on the given address, such as
+// 		localhost:6060, with their examples, source code and an
+// 		index of packages. In module mode these are the packages of
+// 		the main module, of its dependencies and of the standard
+// 		library, read from the module cache without network access.
 // 	-short
 // 		One-line representation for each symbol.
 // 	-src
@@ -1420,7 +1446,7 @@
 //
 // Usage:
 //
-// 	go vet [-n] [-x] [-vettool prog] [build flags] [vet flags] [packages]
+// 	go vet [-n] [-x] [-fix] [-diff] [-vettool prog] [build flags] [vet flags] [packages]
 //
 // Vet runs the Go vet command on the packages named by the import paths.
 //
@@ -1432,6 +1458,18 @@
 // The -n flag prints commands that would be executed.
 // The -x flag prints commands as they are executed.
 //
+// Some checks suggest fixes for the problems they report, such as
+// removing a redundant newline from a Println call.
+// The -fix flag applies those fixes to the source files instead of
+// reporting the problems; only problems without a fix are reported.
+// Fixes are applied only if the vet tool supports writing them;
+// other tools report all problems as usual.
+// The -diff flag is like -fix but prints the fixes as a unified diff
+// instead of applying them.
+// Fixes from different checks and packages that edit the same text
+// in different ways are not applied; the problems they address are
+// reported as usual.
+//
 // The -vettool=prog flag selects a different analysis tool with alternative
 // or additional checks.
 // For example, the 'shadow' analyzer can be built and run using these commands:
@@ -1446,6 +1484,66 @@
 // See also: go fmt, go fix.
 //
 //
+// Report reachable known vulnerabilities
+//
+// Usage:
+//
+// 	go vuln [-db database] [-mode source|binary] [build flags] [packages | files]
+//
+// Vuln reports known vulnerabilities that affect the named packages
+// or executables, as described by an offline vulnerability database.
+//
+// In the default source mode, vuln loads the named packages and their
+// dependencies, determines the version of the module providing each
+// package, and looks up those versions in the database. For entries that
+// name specific functions or methods, it then type-checks the program
+// from source and computes which functions are reachable from its entry
+// points: main.main for commands, and the exported functions and methods
+// for other packages, as well as package initialization. Only entries with
+// a reachable affected symbol are reported, together with a chain of
+// calls by which the symbol can be reached. Calls through interfaces are
+// assumed to reach the methods of any type that implements the interface
+// and is used by reachable code.
+//
+// In binary mode (-mode=binary), the arguments are executables built by
+// the go command. Vuln reads the module versions and Go version recorded
+// in each executable and looks them up in the database. Entries that name
+// affected symbols are reported only if one of those symbols appears in
+// the executable's symbol table, or if the symbol table has been stripped.
+// Functions that have been inlined at every call site do not appear in
+// the symbol table, so binary mode can miss some uses that source mode
+// would find.
+//
+// The -db flag is required and names the database: a local directory or
+// JSON file, or a file:// URL referring to one. A database file holds a
+// JSON-encoded entry or a JSON array of entries; a database directory
+// holds any number of database files with names ending in ".json".
+// Each entry has the form:
+//
+// 	{
+// 		"ID": "GO-2019-0001",          // identifier of the vulnerability
+// 		"Summary": "...",              // one-line description
+// 		"Module": "example.com/mod",   // module path, or "std" for the standard library
+// 		"Package": "example.com/mod/p", // affected package; omit for the whole module
+// 		"Introduced": "v1.1.0",        // first affected version; omit for all earlier versions
+// 		"Fixed": "v1.2.3",             // first fixed version; omit if not fixed
+// 		"Symbols": ["F", "T.M"]        // affected functions and methods of Package;
+// 		                               // omit for the whole package
+// 	}
+//
+// Versions are semantic versions. Versions of the standard library are
+// those of the Go release, so that go1.13.1 is v1.13.1. An entry may be
+// repeated with the same ID to describe several affected version ranges.
+//
+// The -v flag also reports vulnerable modules that are used, but
+// whose affected symbols are not reachable.
+//
+// Vuln exits with a non-zero status if it reports any vulnerability.
+//
+// For more about build flags, see 'go help build'.
+// For more about specifying packages, see 'go help packages'.
+//
+//
 // Build modes
 //
 // The 'go build' and 'go install' commands take a -buildmode argument which
@@ -2956,7 +3054,7 @@
 // 	    coverage enabled may report line numbers that don't correspond
 // 	    to the original sources.
 //
-// 	-covermode set,count,atomic
+// 	-covermode set,count,atomic,branch
 // 	    Set the mode for coverage analysis for the package[s]
 // 	    being tested. The default is "set" unless -race is enabled,
 // 	    in which case it is "atomic".
@@ -2965,6 +3063,9 @@
 // 		count: int: how many times does this statement run?
 // 		atomic: int: count, but correct in multithreaded tests;
 // 			significantly more expensive.
+// 		branch: int: count, and also record the outcomes of each
+// 			operand of && and || in if and for conditions and
+// 			the clause taken by each switch statement.
 // 	    Sets -cover.
 //
 // 	-coverpkg pattern1,pattern2,pattern3
@@ -2981,6 +3082,13 @@
 // 	-failfast
 // 	    Do not start new tests after the first test failure.
 //
+// 	-goroutineleak
+// 	    Fail if the tests leave leaked goroutines behind: goroutines
+// 	    blocked forever on channels or other synchronization objects
+// 	    that no other goroutine can reach. The stacks of the leaked
+// 	    goroutines are printed, as in the goroutineleak profile of
+// 	    runtime/pprof.
+//
 // 	-list regexp
 // 	    List tests, benchmarks, or examples matching the regular expression.
 // 	    No tests, benchmarks or examples will be run. This will only
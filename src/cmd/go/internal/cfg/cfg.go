@@ -27,6 +27,9 @@ var (
 	BuildBuildmode         string // -buildmode flag
 	BuildContext           = defaultContext()
 	BuildMod               string             // -mod flag
+	BuildCover             bool               // -cover flag
+	BuildCoverMode         string             // -covermode flag
+	BuildCoverPkg          []string           // -coverpkg flag
 	BuildI                 bool               // -i flag
 	BuildLinkshared        bool               // -linkshared flag
 	BuildMSan              bool               // -msan flag
@@ -34,6 +37,7 @@ var (
 	BuildO                 string             // -o flag
 	BuildP                 = runtime.NumCPU() // -p flag
 	BuildPkgdir            string             // -pkgdir flag
+	BuildPGO               string             // -pgo flag
 	BuildRace              bool               // -race flag
 	BuildToolexec          []string           // -toolexec flag
 	BuildToolchainName     string
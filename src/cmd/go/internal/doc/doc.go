@@ -114,6 +114,13 @@ Flags:
 		Treat a command (package main) like a regular package.
 		Otherwise package main's exported symbols are hidden
 		when showing the package's top-level documentation.
+	-http addr
+		Instead of printing documentation, serve the documentation
+		of all packages as HTML on the given address, such as
+		localhost:6060, with their examples, source code and an
+		index of packages. In module mode these are the packages of
+		the main module, of its dependencies and of the standard
+		library, read from the module cache without network access.
 	-short
 		One-line representation for each symbol.
 	-src
@@ -659,12 +659,19 @@ func init() {
 var (
 	coverCounters = make(map[string][]uint32)
 	coverBlocks = make(map[string][]testing.CoverBlock)
+{{if eq .Cover.Mode "branch"}}
+	coverBranchCounters = make(map[string][]uint32)
+	coverBranches = make(map[string][]testing.CoverBranch)
+{{end}}
 )
 
 func init() {
 	{{range $i, $p := .Cover.Vars}}
 	{{range $file, $cover := $p.Vars}}
 	coverRegisterFile({{printf "%q" $cover.File}}, _cover{{$i}}.{{$cover.Var}}.Count[:], _cover{{$i}}.{{$cover.Var}}.Pos[:], _cover{{$i}}.{{$cover.Var}}.NumStmt[:])
+	{{if eq $.Cover.Mode "branch"}}
+	coverRegisterBranches({{printf "%q" $cover.File}}, _cover{{$i}}.{{$cover.Var}}.BranchCount[:], _cover{{$i}}.{{$cover.Var}}.BranchPos[:], _cover{{$i}}.{{$cover.Var}}.BranchOutcomes[:])
+	{{end}}
 	{{end}}
 	{{end}}
 }
@@ -690,6 +697,29 @@ func coverRegisterFile(fileName string, counter []uint32, pos []uint32, numStmts
 	}
 	coverBlocks[fileName] = block
 }
+{{if eq .Cover.Mode "branch"}}
+func coverRegisterBranches(fileName string, counter []uint32, pos []uint32, outcomes []uint16) {
+	if 3*len(outcomes) != len(pos) {
+		panic("coverage: mismatched sizes")
+	}
+	if coverBranchCounters[fileName] != nil {
+		// Already registered.
+		return
+	}
+	coverBranchCounters[fileName] = counter
+	branch := make([]testing.CoverBranch, len(outcomes))
+	for i := range outcomes {
+		branch[i] = testing.CoverBranch{
+			Line0: pos[3*i+0],
+			Col0: uint16(pos[3*i+2]),
+			Line1: pos[3*i+1],
+			Col1: uint16(pos[3*i+2]>>16),
+			Outcomes: outcomes[i],
+		}
+	}
+	coverBranches[fileName] = branch
+}
+{{end}}
 {{end}}
 
 func main() {
@@ -698,6 +728,10 @@ func main() {
 		Mode: {{printf "%q" .Cover.Mode}},
 		Counters: coverCounters,
 		Blocks: coverBlocks,
+{{if eq .Cover.Mode "branch"}}
+		BranchCounters: coverBranchCounters,
+		Branches: coverBranches,
+{{end}}
 		CoveredPackages: {{printf "%q" .Covered}},
 	})
 {{end}}
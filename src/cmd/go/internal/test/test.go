@@ -6,7 +6,6 @@ package test
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"go/build"
@@ -14,7 +13,6 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -214,7 +212,7 @@ control the execution of any test:
 	    coverage enabled may report line numbers that don't correspond
 	    to the original sources.
 
-	-covermode set,count,atomic
+	-covermode set,count,atomic,branch
 	    Set the mode for coverage analysis for the package[s]
 	    being tested. The default is "set" unless -race is enabled,
 	    in which case it is "atomic".
@@ -223,6 +221,9 @@ control the execution of any test:
 		count: int: how many times does this statement run?
 		atomic: int: count, but correct in multithreaded tests;
 			significantly more expensive.
+		branch: int: count, and also record the outcomes of each
+			operand of && and || in if and for conditions and
+			the clause taken by each switch statement.
 	    Sets -cover.
 
 	-coverpkg pattern1,pattern2,pattern3
@@ -239,6 +240,13 @@ control the execution of any test:
 	-failfast
 	    Do not start new tests after the first test failure.
 
+	-goroutineleak
+	    Fail if the tests leave leaked goroutines behind: goroutines
+	    blocked forever on channels or other synchronization objects
+	    that no other goroutine can reach. The stacks of the leaked
+	    goroutines are printed, as in the goroutineleak profile of
+	    runtime/pprof.
+
 	-list regexp
 	    List tests, benchmarks, or examples matching the regular expression.
 	    No tests, benchmarks or examples will be run. This will only
@@ -712,9 +720,9 @@ func runTest(cmd *base.Command, args []string) {
 			coverFiles = append(coverFiles, p.GoFiles...)
 			coverFiles = append(coverFiles, p.CgoFiles...)
 			coverFiles = append(coverFiles, p.TestGoFiles...)
-			p.Internal.CoverVars = declareCoverVars(p, coverFiles...)
+			p.Internal.CoverVars = load.DeclareCoverVars(p, coverFiles...)
 			if testCover && testCoverMode == "atomic" {
-				ensureImport(p, "sync/atomic")
+				load.EnsureImport(p, "sync/atomic")
 			}
 		}
 	}
@@ -723,7 +731,7 @@ func runTest(cmd *base.Command, args []string) {
 	for _, p := range pkgs {
 		// sync/atomic import is inserted by the cover tool. See #18486
 		if testCover && testCoverMode == "atomic" {
-			ensureImport(p, "sync/atomic")
+			load.EnsureImport(p, "sync/atomic")
 		}
 
 		buildTest, runTest, printTest, err := builderTest(&b, p)
@@ -771,21 +779,6 @@ func runTest(cmd *base.Command, args []string) {
 }
 
 // ensures that package p imports the named package
-func ensureImport(p *load.Package, pkg string) {
-	for _, d := range p.Internal.Imports {
-		if d.Name == pkg {
-			return
-		}
-	}
-
-	p1 := load.LoadImportWithFlags(pkg, p.Dir, p, &load.ImportStack{}, nil, 0)
-	if p1.Error != nil {
-		base.Fatalf("load %s: %v", pkg, p1.Error)
-	}
-
-	p.Internal.Imports = append(p.Internal.Imports, p1)
-}
-
 var windowsBadWords = []string{
 	"install",
 	"patch",
@@ -813,7 +806,7 @@ func builderTest(b *work.Builder, p *load.Package) (buildAction, runAction, prin
 			Local:    testCover && testCoverPaths == nil,
 			Pkgs:     testCoverPkgs,
 			Paths:    testCoverPaths,
-			DeclVars: declareCoverVars,
+			DeclVars: load.DeclareCoverVars,
 		}
 	}
 	pmain, ptest, pxtest, err := load.TestPackagesFor(p, cover)
@@ -979,50 +972,6 @@ func addTestVet(b *work.Builder, p *load.Package, runAction, installAction *work
 	}
 }
 
-// isTestFile reports whether the source file is a set of tests and should therefore
-// be excluded from coverage analysis.
-func isTestFile(file string) bool {
-	// We don't cover tests, only the code they test.
-	return strings.HasSuffix(file, "_test.go")
-}
-
-// declareCoverVars attaches the required cover variables names
-// to the files, to be used when annotating the files.
-func declareCoverVars(p *load.Package, files ...string) map[string]*load.CoverVar {
-	coverVars := make(map[string]*load.CoverVar)
-	coverIndex := 0
-	// We create the cover counters as new top-level variables in the package.
-	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
-	// and more importantly with dot imports of other covered packages,
-	// so we append 12 hex digits from the SHA-256 of the import path.
-	// The point is only to avoid accidents, not to defeat users determined to
-	// break things.
-	sum := sha256.Sum256([]byte(p.ImportPath))
-	h := fmt.Sprintf("%x", sum[:6])
-	for _, file := range files {
-		if isTestFile(file) {
-			continue
-		}
-		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
-		// we record the full path to the file name.
-		// Otherwise we record the import path, then a forward slash, then the file name.
-		// This makes profiles within GOPATH file system-independent.
-		// These names appear in the cmd/cover HTML interface.
-		var longFile string
-		if p.Internal.Local {
-			longFile = filepath.Join(p.Dir, file)
-		} else {
-			longFile = path.Join(p.ImportPath, file)
-		}
-		coverVars[file] = &load.CoverVar{
-			File: longFile,
-			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
-		}
-		coverIndex++
-	}
-	return coverVars
-}
-
 var noTestsToRun = []byte("\ntesting: warning: no tests to run\n")
 
 type runCache struct {
@@ -47,6 +47,7 @@ var testFlagDefn = []*cmdflag.Defn{
 	{Name: "cpu", PassToTest: true},
 	{Name: "cpuprofile", PassToTest: true},
 	{Name: "failfast", BoolVar: new(bool), PassToTest: true},
+	{Name: "goroutineleak", BoolVar: new(bool), PassToTest: true},
 	{Name: "list", PassToTest: true},
 	{Name: "memprofile", PassToTest: true},
 	{Name: "memprofilerate", PassToTest: true},
@@ -174,7 +175,7 @@ func testFlags(usage func(), args []string) (packageNames, passToTest []string)
 				testCoverProfile = value
 			case "covermode":
 				switch value {
-				case "set", "count", "atomic":
+				case "set", "count", "atomic", "branch":
 					testCoverMode = value
 				default:
 					base.Fatalf("invalid flag argument for -covermode: %q", value)
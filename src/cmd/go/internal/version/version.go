@@ -8,6 +8,7 @@ package version
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -135,6 +136,24 @@ func scanFile(file string, info os.FileInfo, mustPrint bool) {
 	}
 }
 
+// ReadExe returns the Go version and the module version information
+// embedded in the named executable. The module information is in the
+// format printed by 'go version -m', without the indentation, and is
+// empty if the executable was not built in module mode.
+func ReadExe(file string) (vers, mod string, err error) {
+	x, err := openExe(file)
+	if err != nil {
+		return "", "", err
+	}
+	defer x.Close()
+
+	vers, mod = findVers(x)
+	if vers == "" {
+		return "", "", errors.New("go version not found")
+	}
+	return vers, mod, nil
+}
+
 // The build info blob left by the linker is identified by
 // a 16-byte header, consisting of buildInfoMagic (14 bytes),
 // the binary's pointer size (1 byte),
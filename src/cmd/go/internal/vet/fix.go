@@ -0,0 +1,178 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/work"
+	"cmd/internal/diff"
+	"cmd/internal/edit"
+)
+
+// A suggestedFix is a suggested fix written by the vet tool when
+// its configuration sets FixesOutput. The tool writes a JSON list
+// of fixes to that file, and reports only the problems that have
+// no fix. A tool that does not know about FixesOutput writes no
+// such file, and no fixes are applied.
+type suggestedFix struct {
+	Analyzer string // name of the analyzer that suggested the fix
+	Posn     string // position of the diagnostic
+	Message  string // message of the diagnostic
+	Fix      string // description of the fix
+	Edits    []textEdit
+}
+
+// A textEdit replaces the bytes [Offset, End) of File with New.
+type textEdit struct {
+	File   string
+	Offset int
+	End    int
+	New    string
+}
+
+// overlaps reports whether e and x cannot both be applied.
+// Insertions at the same offset conflict unless they are identical.
+func (e textEdit) overlaps(x textEdit) bool {
+	if e.Offset == e.End && x.Offset == x.End {
+		return e.Offset == x.Offset && e.New != x.New
+	}
+	return e.Offset < x.End && x.Offset < e.End
+}
+
+// applyFixes reads the suggested fixes written by the vet actions
+// that root depends on, merges them, and either applies them to the
+// source files or, if diffOnly is set, prints them as a diff.
+//
+// The same file may be vetted more than once, as part of a package
+// and again as part of its test variant, so identical edits are
+// merged. A fix that conflicts with one already accepted, or that
+// edits a file outside the vetted package's directory (such as a
+// file generated by cgo), is not applied; its diagnostic is reported
+// instead.
+func applyFixes(root *work.Action, diffOnly bool) {
+	var fixes []suggestedFix
+	for _, a := range root.Deps {
+		if a.Mode != "vet" || a.VetFixes == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(a.VetFixes)
+		if err != nil {
+			base.Errorf("go vet: %v", err)
+			continue
+		}
+		var list []suggestedFix
+		if err := json.Unmarshal(data, &list); err != nil {
+			base.Errorf("go vet: reading suggested fixes for %s: %v", a.Package.ImportPath, err)
+			continue
+		}
+	List:
+		for _, f := range list {
+			for _, e := range f.Edits {
+				if filepath.Dir(e.File) != a.Package.Dir {
+					reportFix(f)
+					continue List
+				}
+			}
+			fixes = append(fixes, f)
+		}
+	}
+
+	// Accept fixes in order of position
+	// so that the outcome of a conflict is deterministic.
+	sort.SliceStable(fixes, func(i, j int) bool {
+		ei, ej := fixes[i].Edits[0], fixes[j].Edits[0]
+		if ei.File != ej.File {
+			return ei.File < ej.File
+		}
+		return ei.Offset < ej.Offset
+	})
+
+	accepted := make(map[string][]textEdit)
+Fixes:
+	for _, f := range fixes {
+		var add []textEdit
+	Edits:
+		for _, e := range f.Edits {
+			for _, x := range accepted[e.File] {
+				if e == x {
+					continue Edits
+				}
+				if e.overlaps(x) {
+					reportFix(f)
+					continue Fixes
+				}
+			}
+			for _, x := range add {
+				if e.overlaps(x) {
+					reportFix(f)
+					continue Fixes
+				}
+			}
+			add = append(add, e)
+		}
+		for _, e := range add {
+			accepted[e.File] = append(accepted[e.File], e)
+		}
+	}
+
+	var files []string
+	for file := range accepted {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		old, err := ioutil.ReadFile(file)
+		if err != nil {
+			base.Errorf("go vet: %v", err)
+			continue
+		}
+		buf := edit.NewBuffer(old)
+		ok := true
+		for _, e := range accepted[file] {
+			if e.Offset < 0 || e.Offset > e.End || e.End > len(old) {
+				ok = false
+				break
+			}
+			buf.Replace(e.Offset, e.End, e.New)
+		}
+		if !ok {
+			base.Errorf("go vet: %s changed during vet; not applying fixes", base.ShortPath(file))
+			continue
+		}
+		new := buf.Bytes()
+
+		if diffOnly {
+			name := base.ShortPath(file)
+			d, err := diff.Diff(name+".orig", old, name, new)
+			if err != nil {
+				base.Errorf("go vet: computing diff: %v", err)
+				continue
+			}
+			os.Stdout.Write(d)
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			base.Errorf("go vet: %v", err)
+			continue
+		}
+		if err := ioutil.WriteFile(file, new, fi.Mode().Perm()); err != nil {
+			base.Errorf("go vet: %v", err)
+		}
+	}
+}
+
+// reportFix reports the diagnostic of a fix that could not be applied.
+func reportFix(f suggestedFix) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", base.ShortPath(f.Posn), f.Message)
+	base.SetExitStatus(1)
+}
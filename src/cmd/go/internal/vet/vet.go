@@ -7,6 +7,7 @@ package vet
 
 import (
 	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
 	"cmd/go/internal/load"
 	"cmd/go/internal/modload"
 	"cmd/go/internal/work"
@@ -16,7 +17,7 @@ import (
 var CmdVet = &base.Command{
 	Run:         runVet,
 	CustomFlags: true,
-	UsageLine:   "go vet [-n] [-x] [-vettool prog] [build flags] [vet flags] [packages]",
+	UsageLine:   "go vet [-n] [-x] [-fix] [-diff] [-vettool prog] [build flags] [vet flags] [packages]",
 	Short:       "report likely mistakes in packages",
 	Long: `
 Vet runs the Go vet command on the packages named by the import paths.
@@ -29,6 +30,18 @@ For details of a specific checker such as 'printf', see 'go tool vet help printf
 The -n flag prints commands that would be executed.
 The -x flag prints commands as they are executed.
 
+Some checks suggest fixes for the problems they report, such as
+removing a redundant newline from a Println call.
+The -fix flag applies those fixes to the source files instead of
+reporting the problems; only problems without a fix are reported.
+Fixes are applied only if the vet tool supports writing them;
+other tools report all problems as usual.
+The -diff flag is like -fix but prints the fixes as a unified diff
+instead of applying them.
+Fixes from different checks and packages that edit the same text
+in different ways are not applied; the problems they address are
+reported as usual.
+
 The -vettool=prog flag selects a different analysis tool with alternative
 or additional checks.
 For example, the 'shadow' analyzer can be built and run using these commands:
@@ -51,6 +64,7 @@ func runVet(cmd *base.Command, args []string) {
 
 	work.BuildInit()
 	work.VetFlags = vetFlags
+	work.VetFix = vetFix || vetDiff
 	if vetTool != "" {
 		var err error
 		work.VetTool, err = filepath.Abs(vetTool)
@@ -86,4 +100,8 @@ func runVet(cmd *base.Command, args []string) {
 		}
 	}
 	b.Do(root)
+
+	if work.VetFix && !cfg.BuildN {
+		applyFixes(root, vetDiff)
+	}
 }
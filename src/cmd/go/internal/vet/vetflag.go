@@ -38,6 +38,11 @@ import (
 //
 var vetTool string // -vettool
 
+var (
+	vetFix  bool // -fix
+	vetDiff bool // -diff
+)
+
 func init() {
 	// Extract -vettool by ad hoc flag processing:
 	// its value is needed even before we can declare
@@ -118,6 +123,8 @@ func vetFlags(usage func(), args []string) (passToVet, packageNames []string) {
 	// This flag declaration is a placeholder:
 	// -vettool is actually parsed by the init function above.
 	cmd.Flag.StringVar(new(string), "vettool", "", "path to vet tool binary")
+	cmd.Flag.BoolVar(&vetFix, "fix", false, "apply suggested fixes")
+	cmd.Flag.BoolVar(&vetDiff, "diff", false, "print suggested fixes as a diff")
 	cmd.Flag.VisitAll(func(f *flag.Flag) {
 		vetFlagDefn = append(vetFlagDefn, &cmdflag.Defn{
 			Name:  f.Name,
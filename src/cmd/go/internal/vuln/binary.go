@@ -0,0 +1,104 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"strings"
+
+	"cmd/go/internal/module"
+	"cmd/go/internal/version"
+	"cmd/internal/objabi"
+)
+
+// binaryModules returns the Go version and the modules recorded in
+// the build information of the named executable, with replacements
+// applied. Modules replaced by directories, which have no version,
+// are omitted.
+func binaryModules(file string) (goVersion string, mods []module.Version, err error) {
+	goVersion, info, err := version.ReadExe(file)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) < 3 {
+			continue
+		}
+		switch f[0] {
+		case "dep":
+			mods = append(mods, module.Version{Path: f[1], Version: f[2]})
+		case "=>":
+			if len(mods) > 0 {
+				mods[len(mods)-1] = module.Version{Path: f[1], Version: f[2]}
+			}
+		}
+	}
+	var valid []module.Version
+	for _, m := range mods {
+		if m.Version != "" && m.Version != "(devel)" {
+			valid = append(valid, m)
+		}
+	}
+	return goVersion, valid, nil
+}
+
+// errNoSymbols is returned by binarySymbols for executables
+// whose symbol table has been stripped.
+var errNoSymbols = errors.New("no symbol table")
+
+// binarySymbols returns the set of symbol names in the named executable.
+func binarySymbols(file string) (map[string]bool, error) {
+	var names []string
+	if f, err := elf.Open(file); err == nil {
+		defer f.Close()
+		syms, err := f.Symbols()
+		if err != nil && err != elf.ErrNoSymbols {
+			return nil, err
+		}
+		for _, s := range syms {
+			names = append(names, s.Name)
+		}
+	} else if f, err := macho.Open(file); err == nil {
+		defer f.Close()
+		if f.Symtab != nil {
+			for _, s := range f.Symtab.Syms {
+				names = append(names, strings.TrimPrefix(s.Name, "_"))
+			}
+		}
+	} else if f, err := pe.Open(file); err == nil {
+		defer f.Close()
+		for _, s := range f.Symbols {
+			names = append(names, s.Name)
+		}
+	} else {
+		return nil, errors.New("unrecognized executable format")
+	}
+	if len(names) == 0 {
+		return nil, errNoSymbols
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set, nil
+}
+
+// linkerNames returns the names the linker gives to the symbol sym
+// of the package with the given import path.
+func linkerNames(path, sym string) []string {
+	prefix := objabi.PathToPrefix(path) + "."
+	if i := strings.Index(sym, "."); i >= 0 {
+		typ, name := sym[:i], sym[i+1:]
+		return []string{
+			prefix + typ + "." + name,
+			prefix + "(*" + typ + ")." + name,
+		}
+	}
+	return []string{prefix + sym}
+}
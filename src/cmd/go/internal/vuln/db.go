@@ -0,0 +1,176 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"cmd/go/internal/semver"
+)
+
+// An entry is a single vulnerability database entry.
+// Its JSON encoding is documented in 'go help vuln'.
+type entry struct {
+	ID         string   // identifier, such as "GO-2019-0001"
+	Summary    string   // one-line description
+	Module     string   // module path, or "std" for the standard library
+	Package    string   // affected package; empty means the whole module
+	Introduced string   // first affected version; empty means all earlier versions
+	Fixed      string   // first fixed version; empty means no fix yet
+	Symbols    []string // affected functions and methods; empty means the whole package
+}
+
+// affects reports whether e applies to the given version of its module.
+func (e *entry) affects(version string) bool {
+	if !semver.IsValid(version) {
+		return false
+	}
+	if e.Introduced != "" && semver.Compare(version, e.Introduced) < 0 {
+		return false
+	}
+	if e.Fixed != "" && semver.Compare(version, e.Fixed) >= 0 {
+		return false
+	}
+	return true
+}
+
+// matchesPackage reports whether e applies to the package with the given path.
+func (e *entry) matchesPackage(path string) bool {
+	return e.Package == "" || e.Package == path
+}
+
+// A database is a vulnerability database, indexed by module path.
+type database struct {
+	byModule map[string][]*entry
+}
+
+// lookup returns the entries affecting the given version of module mod.
+func (db *database) lookup(mod, version string) []*entry {
+	var list []*entry
+	for _, e := range db.byModule[mod] {
+		if e.affects(version) {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// openDatabase reads the database named by src, which is a local directory
+// or file name or a file:// URL referring to one.
+// A file holds either a single JSON-encoded entry or a JSON array of them.
+// A directory holds any number of such files, with names ending in ".json".
+func openDatabase(src string) (*database, error) {
+	path := src
+	if strings.HasPrefix(src, "file:") {
+		u, err := url.Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database URL %s: %v", src, err)
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, fmt.Errorf("invalid database URL %s: non-local host", src)
+		}
+		path = filepath.FromSlash(u.Path)
+		if runtime.GOOS == "windows" {
+			// file:///C:/db has path /C:/db.
+			path = strings.TrimPrefix(path, `\`)
+		}
+	} else if strings.Contains(src, "://") {
+		return nil, fmt.Errorf("unsupported database URL %s: only file:// URLs are supported", src)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	db := &database{byModule: make(map[string][]*entry)}
+	for _, file := range files {
+		entries, err := readEntries(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			db.byModule[e.Module] = append(db.byModule[e.Module], e)
+		}
+	}
+	return db, nil
+}
+
+// readEntries reads the entries in a single database file.
+func readEntries(file string) ([]*entry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*entry
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		e := new(entry)
+		err = json.Unmarshal(data, e)
+		entries = []*entry{e}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", file, err)
+	}
+	for _, e := range entries {
+		if e.ID == "" || e.Module == "" {
+			return nil, fmt.Errorf("reading %s: entry missing ID or Module", file)
+		}
+		if len(e.Symbols) > 0 && e.Package == "" {
+			return nil, fmt.Errorf("reading %s: %s: Symbols without Package", file, e.ID)
+		}
+		for _, v := range []string{e.Introduced, e.Fixed} {
+			if v != "" && !semver.IsValid(v) {
+				return nil, fmt.Errorf("reading %s: %s: invalid version %q", file, e.ID, v)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// goSemver converts a Go release version such as "go1.13.1"
+// to the semantic version used for the "std" module, "v1.13.1".
+// It returns the empty string for development versions.
+func goSemver(v string) string {
+	if !strings.HasPrefix(v, "go") {
+		return ""
+	}
+	v = "v" + strings.TrimPrefix(v, "go")
+	// Release candidates and betas: go1.13rc1 -> v1.13.0-rc1.
+	for _, pre := range []string{"beta", "rc"} {
+		if i := strings.Index(v, pre); i >= 0 {
+			v = v[:i] + "-" + v[i:]
+			if strings.Count(v[:i], ".") == 1 {
+				v = v[:i] + ".0" + v[i:]
+			}
+			break
+		}
+	}
+	if !strings.Contains(v, "-") && strings.Count(v, ".") == 1 {
+		v += ".0"
+	}
+	if !semver.IsValid(v) {
+		return ""
+	}
+	return v
+}
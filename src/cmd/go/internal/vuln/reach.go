@@ -0,0 +1,384 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/load"
+	"cmd/go/internal/str"
+)
+
+// A program is a set of packages type-checked from source,
+// together with the functions reachable from its entry points.
+//
+// Reachability is computed using a form of rapid type analysis:
+// a call through an interface method is assumed to reach the
+// corresponding method of every concrete type that implements
+// the interface and that appears in some reachable function.
+// The analysis is conservative in the sense that a function it
+// deems unreachable cannot be called, except through reflection,
+// linkname or assembly.
+type program struct {
+	fset  *token.FileSet
+	pkgs  map[string]*types.Package // by import path
+	infos map[*types.Package]*types.Info
+	funcs map[*types.Func]*node
+
+	reached  map[*node]*node           // reachable node -> node it was first reached from
+	queue    []*node                   // reachable nodes not yet scanned
+	live     map[*types.Named]bool     // named types of values in reachable code
+	livePtr  map[*types.Named]bool     // likewise, for pointers to named types
+	byMethod map[string][]types.Type   // live types by method name
+	dynamic  map[string][]*dynamicCall // interface calls by method name
+	called   map[*types.Func]bool      // interface methods called
+	initPkg  map[*types.Package]*node  // initialization node for each package
+}
+
+// A node is a function in the call graph.
+// Its body is the set of syntax trees to scan for calls.
+type node struct {
+	pkg  *types.Package
+	name string // for display, such as "pkg.T.M"
+	pos  token.Pos
+	body []ast.Node
+}
+
+// A dynamicCall is a call through an interface method.
+type dynamicCall struct {
+	caller *node
+	method *types.Func
+}
+
+// loadProgram parses and type-checks pkgs and all their dependencies
+// from source.
+func loadProgram(pkgs []*load.Package) *program {
+	prog := &program{
+		fset:     token.NewFileSet(),
+		pkgs:     make(map[string]*types.Package),
+		infos:    make(map[*types.Package]*types.Info),
+		funcs:    make(map[*types.Func]*node),
+		reached:  make(map[*node]*node),
+		live:     make(map[*types.Named]bool),
+		livePtr:  make(map[*types.Named]bool),
+		byMethod: make(map[string][]types.Type),
+		dynamic:  make(map[string][]*dynamicCall),
+		called:   make(map[*types.Func]bool),
+		initPkg:  make(map[*types.Package]*node),
+	}
+
+	// PackageList returns the packages in dependency order,
+	// so every import has been checked by the time it is needed.
+	for _, p := range load.PackageList(pkgs) {
+		if p.ImportPath == "unsafe" {
+			prog.pkgs[p.ImportPath] = types.Unsafe
+			continue
+		}
+		var files []*ast.File
+		for _, name := range str.StringList(p.GoFiles, p.CgoFiles) {
+			f, err := parser.ParseFile(prog.fset, filepath.Join(p.Dir, name), nil, 0)
+			if err != nil {
+				continue // the type checker will do its best
+			}
+			files = append(files, f)
+		}
+
+		// Map import paths as they appear in the source
+		// to the packages they resolve to.
+		importMap := make(map[string]string)
+		for i, raw := range p.Internal.RawImports {
+			if i < len(p.Imports) {
+				importMap[raw] = p.Imports[i]
+			}
+		}
+		conf := &types.Config{
+			Importer: importerFunc(func(path string) (*types.Package, error) {
+				if resolved, ok := importMap[path]; ok {
+					path = resolved
+				}
+				if pkg := prog.pkgs[path]; pkg != nil {
+					return pkg, nil
+				}
+				return nil, fmt.Errorf("can't find package %q", path)
+			}),
+			FakeImportC: true,
+			Sizes:       types.SizesFor("gc", cfg.BuildContext.GOARCH),
+			Error:       func(error) {}, // report nothing; check as much as possible
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		pkg, _ := conf.Check(p.ImportPath, prog.fset, files, info)
+		prog.pkgs[p.ImportPath] = pkg
+		prog.infos[pkg] = info
+		prog.addFuncs(pkg, files, info)
+	}
+	return prog
+}
+
+// addFuncs creates call graph nodes for the functions declared in files.
+// Package initialization, including init functions and the initializers
+// of package-level variables, is represented by a single node.
+func (prog *program) addFuncs(pkg *types.Package, files []*ast.File, info *types.Info) {
+	init := &node{pkg: pkg, name: pkg.Path() + ".init"}
+	prog.initPkg[pkg] = init
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Body == nil {
+					continue
+				}
+				if decl.Recv == nil && decl.Name.Name == "init" {
+					init.body = append(init.body, decl.Body)
+					continue
+				}
+				fn, ok := info.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				prog.funcs[fn] = &node{
+					pkg:  pkg,
+					name: funcName(fn),
+					pos:  decl.Name.Pos(),
+					body: []ast.Node{decl.Body},
+				}
+			case *ast.GenDecl:
+				if decl.Tok == token.VAR {
+					init.body = append(init.body, decl)
+				}
+			}
+		}
+	}
+}
+
+// node returns the call graph node for fn,
+// creating an empty one for functions without a body.
+func (prog *program) node(fn *types.Func) *node {
+	n := prog.funcs[fn]
+	if n == nil {
+		n = &node{pkg: fn.Pkg(), name: funcName(fn), pos: fn.Pos()}
+		prog.funcs[fn] = n
+	}
+	return n
+}
+
+// reach marks n as reachable from the given caller, which is nil for
+// an entry point.
+func (prog *program) reach(n, caller *node) {
+	if _, ok := prog.reached[n]; ok {
+		return
+	}
+	prog.reached[n] = caller
+	prog.queue = append(prog.queue, n)
+}
+
+// analyze computes the functions reachable from the given roots.
+func (prog *program) analyze(roots []*node) {
+	for _, n := range roots {
+		prog.reach(n, nil)
+	}
+	for len(prog.queue) > 0 {
+		n := prog.queue[0]
+		prog.queue = prog.queue[1:]
+		prog.scan(n)
+	}
+}
+
+// scan records the calls and live types in the body of n.
+func (prog *program) scan(n *node) {
+	info := prog.infos[n.pkg]
+	if info == nil {
+		return
+	}
+	for _, body := range n.body {
+		ast.Inspect(body, func(x ast.Node) bool {
+			if id, ok := x.(*ast.Ident); ok {
+				if fn, ok := info.Uses[id].(*types.Func); ok {
+					if recv := fn.Type().(*types.Signature).Recv(); recv != nil && types.IsInterface(recv.Type()) {
+						prog.dynamicCall(n, fn)
+					} else {
+						prog.reach(prog.node(fn), n)
+					}
+				}
+			}
+			if e, ok := x.(ast.Expr); ok {
+				if tv, ok := info.Types[e]; ok && tv.Type != nil && !tv.IsType() {
+					prog.addLive(tv.Type)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// dynamicCall records a call from caller through the interface method fn.
+// Only the first call through each method is recorded: later callers
+// cannot reach anything new.
+func (prog *program) dynamicCall(caller *node, fn *types.Func) {
+	if prog.called[fn] {
+		return
+	}
+	prog.called[fn] = true
+	call := &dynamicCall{caller, fn}
+	prog.dynamic[fn.Name()] = append(prog.dynamic[fn.Name()], call)
+	for _, T := range prog.byMethod[fn.Name()] {
+		prog.dispatch(call, T)
+	}
+}
+
+// addLive records that values of type T appear in reachable code.
+// Only named types and pointers to them can have methods.
+func (prog *program) addLive(T types.Type) {
+	// Named types are canonical but pointer types are not,
+	// so record pointers by their element type.
+	switch t := T.(type) {
+	case *types.Named:
+		if prog.live[t] || types.IsInterface(t) {
+			return
+		}
+		prog.live[t] = true
+	case *types.Pointer:
+		elem, ok := t.Elem().(*types.Named)
+		if !ok || prog.livePtr[elem] || types.IsInterface(elem) {
+			return
+		}
+		prog.livePtr[elem] = true
+	default:
+		return
+	}
+	mset := types.NewMethodSet(T)
+	for i := 0; i < mset.Len(); i++ {
+		name := mset.At(i).Obj().Name()
+		prog.byMethod[name] = append(prog.byMethod[name], T)
+		for _, call := range prog.dynamic[name] {
+			prog.dispatch(call, T)
+		}
+	}
+}
+
+// dispatch adds the call edge for call to the concrete type T,
+// if T implements the called interface.
+func (prog *program) dispatch(call *dynamicCall, T types.Type) {
+	iface, ok := call.method.Type().(*types.Signature).Recv().Type().Underlying().(*types.Interface)
+	if !ok || !types.Implements(T, iface) {
+		return
+	}
+	obj, _, _ := types.LookupFieldOrMethod(T, false, call.method.Pkg(), call.method.Name())
+	if fn, ok := obj.(*types.Func); ok {
+		prog.reach(prog.node(fn), call.caller)
+	}
+}
+
+// lookup returns the function or method named sym in the package
+// with the given import path, or nil if there is none.
+// Methods are named "T.M", whether the receiver is T or *T.
+func (prog *program) lookup(path, sym string) *types.Func {
+	pkg := prog.pkgs[path]
+	if pkg == nil {
+		return nil
+	}
+	typ, name := "", sym
+	if i := strings.Index(sym, "."); i >= 0 {
+		typ, name = sym[:i], sym[i+1:]
+	}
+	if typ == "" {
+		fn, _ := pkg.Scope().Lookup(name).(*types.Func)
+		return fn
+	}
+	tn, ok := pkg.Scope().Lookup(typ).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, pkg, name)
+	fn, _ := obj.(*types.Func)
+	return fn
+}
+
+// path returns the chain of calls by which n was reached,
+// starting at an entry point.
+func (prog *program) path(n *node) []*node {
+	var list []*node
+	for ; n != nil; n = prog.reached[n] {
+		list = append([]*node{n}, list...)
+	}
+	return list
+}
+
+// funcName returns the display name of fn, such as "pkg.F" or "pkg.T.M".
+func funcName(fn *types.Func) string {
+	name := fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		T := recv.Type()
+		if ptr, ok := T.(*types.Pointer); ok {
+			T = ptr.Elem()
+		}
+		if named, ok := T.(*types.Named); ok {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	if fn.Pkg() != nil {
+		name = fn.Pkg().Path() + "." + name
+	}
+	return name
+}
+
+// roots returns the entry points of the program for the named packages:
+// package initialization for every package, and either the main function
+// of a command or the functions and methods of a library.
+func (prog *program) roots(pkgs []*load.Package) []*node {
+	var roots []*node
+	for _, p := range load.PackageList(pkgs) {
+		if n := prog.initPkg[prog.pkgs[p.ImportPath]]; n != nil {
+			roots = append(roots, n)
+		}
+	}
+	for _, p := range pkgs {
+		pkg := prog.pkgs[p.ImportPath]
+		if pkg == nil {
+			continue
+		}
+		if p.Name == "main" {
+			if fn, ok := pkg.Scope().Lookup("main").(*types.Func); ok {
+				roots = append(roots, prog.node(fn))
+			}
+			continue
+		}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			switch obj := scope.Lookup(name).(type) {
+			case *types.Func:
+				if obj.Exported() {
+					roots = append(roots, prog.node(obj))
+				}
+			case *types.TypeName:
+				if !obj.Exported() || types.IsInterface(obj.Type()) {
+					break
+				}
+				mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+				for i := 0; i < mset.Len(); i++ {
+					if fn, ok := mset.At(i).Obj().(*types.Func); ok && fn.Exported() {
+						roots = append(roots, prog.node(fn))
+					}
+				}
+			}
+		}
+	}
+	return roots
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
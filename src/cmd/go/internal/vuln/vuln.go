@@ -0,0 +1,285 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vuln implements the ``go vuln'' command.
+package vuln
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/load"
+	"cmd/go/internal/module"
+	"cmd/go/internal/work"
+	"cmd/internal/objabi"
+)
+
+var CmdVuln = &base.Command{
+	UsageLine: "go vuln [-db database] [-mode source|binary] [build flags] [packages | files]",
+	Short:     "report reachable known vulnerabilities",
+	Long: `
+Vuln reports known vulnerabilities that affect the named packages
+or executables, as described by an offline vulnerability database.
+
+In the default source mode, vuln loads the named packages and their
+dependencies, determines the version of the module providing each
+package, and looks up those versions in the database. For entries that
+name specific functions or methods, it then type-checks the program
+from source and computes which functions are reachable from its entry
+points: main.main for commands, and the exported functions and methods
+for other packages, as well as package initialization. Only entries with
+a reachable affected symbol are reported, together with a chain of
+calls by which the symbol can be reached. Calls through interfaces are
+assumed to reach the methods of any type that implements the interface
+and is used by reachable code.
+
+In binary mode (-mode=binary), the arguments are executables built by
+the go command. Vuln reads the module versions and Go version recorded
+in each executable and looks them up in the database. Entries that name
+affected symbols are reported only if one of those symbols appears in
+the executable's symbol table, or if the symbol table has been stripped.
+Functions that have been inlined at every call site do not appear in
+the symbol table, so binary mode can miss some uses that source mode
+would find.
+
+The -db flag is required and names the database: a local directory or
+JSON file, or a file:// URL referring to one. A database file holds a
+JSON-encoded entry or a JSON array of entries; a database directory
+holds any number of database files with names ending in ".json".
+Each entry has the form:
+
+	{
+		"ID": "GO-2019-0001",          // identifier of the vulnerability
+		"Summary": "...",              // one-line description
+		"Module": "example.com/mod",   // module path, or "std" for the standard library
+		"Package": "example.com/mod/p", // affected package; omit for the whole module
+		"Introduced": "v1.1.0",        // first affected version; omit for all earlier versions
+		"Fixed": "v1.2.3",             // first fixed version; omit if not fixed
+		"Symbols": ["F", "T.M"]        // affected functions and methods of Package;
+		                               // omit for the whole package
+	}
+
+Versions are semantic versions. Versions of the standard library are
+those of the Go release, so that go1.13.1 is v1.13.1. An entry may be
+repeated with the same ID to describe several affected version ranges.
+
+The -v flag also reports vulnerable modules that are used, but
+whose affected symbols are not reachable.
+
+Vuln exits with a non-zero status if it reports any vulnerability.
+
+For more about build flags, see 'go help build'.
+For more about specifying packages, see 'go help packages'.
+	`,
+}
+
+var (
+	vulnDB   = CmdVuln.Flag.String("db", "", "")
+	vulnMode = CmdVuln.Flag.String("mode", "source", "")
+)
+
+func init() {
+	CmdVuln.Run = runVuln // break init cycle
+	work.AddBuildFlags(CmdVuln)
+}
+
+func runVuln(cmd *base.Command, args []string) {
+	if *vulnDB == "" {
+		base.Fatalf("go vuln: -db flag is required")
+	}
+	db, err := openDatabase(*vulnDB)
+	if err != nil {
+		base.Fatalf("go vuln: %v", err)
+	}
+
+	switch *vulnMode {
+	case "source":
+		work.BuildInit()
+		vulnSource(db, args)
+	case "binary":
+		if len(args) == 0 {
+			base.Fatalf("go vuln: no executables named")
+		}
+		for _, file := range args {
+			vulnBinary(db, file)
+		}
+	default:
+		base.Fatalf("go vuln: unknown -mode %q", *vulnMode)
+	}
+	base.ExitIfErrors()
+}
+
+// A use is a package of the program that is affected by an entry.
+type use struct {
+	entry *entry
+	mod   module.Version // module providing the package
+	pkg   string         // import path of the package
+}
+
+// vulnSource reports the vulnerabilities reachable
+// from the packages named by args.
+func vulnSource(db *database, args []string) {
+	pkgs := load.PackagesForBuild(args)
+	goVersion := goSemver(runtime.Version())
+
+	var uses []use
+	needProgram := false
+	for _, p := range load.PackageList(pkgs) {
+		var mod module.Version
+		switch {
+		case p.Standard:
+			mod = module.Version{Path: "std", Version: goVersion}
+		case p.Module != nil:
+			m := p.Module
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			mod = module.Version{Path: m.Path, Version: m.Version}
+		}
+		if mod.Version == "" {
+			continue
+		}
+		for _, e := range db.lookup(mod.Path, mod.Version) {
+			if e.matchesPackage(p.ImportPath) {
+				uses = append(uses, use{e, mod, p.ImportPath})
+				if len(e.Symbols) > 0 {
+					needProgram = true
+				}
+			}
+		}
+	}
+	if len(uses) == 0 {
+		return
+	}
+
+	var prog *program
+	if needProgram {
+		prog = loadProgram(pkgs)
+		prog.analyze(prog.roots(pkgs))
+	}
+
+	sort.SliceStable(uses, func(i, j int) bool { return uses[i].entry.ID < uses[j].entry.ID })
+	reported := make(map[*entry]bool)
+	for _, u := range uses {
+		if reported[u.entry] {
+			continue
+		}
+		if len(u.entry.Symbols) == 0 {
+			reported[u.entry] = true
+			printEntry("", u.entry, u.mod)
+			fmt.Printf("\tpackage %s is imported\n", u.pkg)
+			base.SetExitStatus(1)
+			continue
+		}
+
+		var paths [][]*node
+		for _, sym := range u.entry.Symbols {
+			fn := prog.lookup(u.pkg, sym)
+			if fn == nil {
+				continue
+			}
+			if n := prog.funcs[fn]; n != nil {
+				if _, ok := prog.reached[n]; ok {
+					paths = append(paths, prog.path(n))
+				}
+			}
+		}
+		if len(paths) == 0 {
+			if cfg.BuildV {
+				printEntry("", u.entry, u.mod)
+				fmt.Printf("\tpackage %s is imported, but affected symbols are not reachable\n", u.pkg)
+			}
+			continue
+		}
+		reported[u.entry] = true
+		printEntry("", u.entry, u.mod)
+		for _, path := range paths {
+			fmt.Printf("\t%s is reachable:\n", path[len(path)-1].name)
+			for _, n := range path[:len(path)-1] {
+				fmt.Printf("\t\t%s", n.name)
+				if n.pos.IsValid() {
+					fmt.Printf(" (%s)", base.ShortPath(prog.fset.Position(n.pos).String()))
+				}
+				fmt.Printf("\n")
+			}
+		}
+		base.SetExitStatus(1)
+	}
+}
+
+// vulnBinary reports the vulnerabilities affecting the named executable.
+func vulnBinary(db *database, file string) {
+	goVersion, mods, err := binaryModules(file)
+	if err != nil {
+		base.Errorf("go vuln: %s: %v", file, err)
+		return
+	}
+	if v := goSemver(goVersion); v != "" {
+		mods = append(mods, module.Version{Path: "std", Version: v})
+	}
+	syms, err := binarySymbols(file)
+	if err != nil && err != errNoSymbols {
+		base.Errorf("go vuln: %s: %v", file, err)
+		return
+	}
+
+	for _, mod := range mods {
+		for _, e := range db.lookup(mod.Path, mod.Version) {
+			if syms == nil {
+				// Without symbols, assume the worst.
+				printEntry(file+": ", e, mod)
+				fmt.Printf("\tsymbol table not available; symbols not checked\n")
+				base.SetExitStatus(1)
+				continue
+			}
+			var found []string
+			switch {
+			case e.Package == "":
+				found = []string{"module " + mod.Path}
+			case len(e.Symbols) == 0:
+				prefix := objabi.PathToPrefix(e.Package) + "."
+				for name := range syms {
+					if strings.HasPrefix(name, prefix) {
+						found = []string{"package " + e.Package}
+						break
+					}
+				}
+			default:
+				for _, sym := range e.Symbols {
+					for _, name := range linkerNames(e.Package, sym) {
+						if syms[name] {
+							found = append(found, e.Package+"."+sym)
+							break
+						}
+					}
+				}
+			}
+			if len(found) == 0 {
+				if cfg.BuildV {
+					printEntry(file+": ", e, mod)
+					fmt.Printf("\taffected symbols not found\n")
+				}
+				continue
+			}
+			printEntry(file+": ", e, mod)
+			for _, f := range found {
+				fmt.Printf("\t%s is linked in\n", f)
+			}
+			base.SetExitStatus(1)
+		}
+	}
+}
+
+// printEntry prints the heading line for a report of e affecting mod.
+func printEntry(prefix string, e *entry, mod module.Version) {
+	fixed := "not fixed"
+	if e.Fixed != "" {
+		fixed = "fixed in " + e.Fixed
+	}
+	fmt.Printf("%s%s %s@%s: %s (%s)\n", prefix, e.ID, mod.Path, mod.Version, e.Summary, fixed)
+}
@@ -85,6 +85,7 @@ type Action struct {
 	buildID  string         // build ID of action output
 
 	VetxOnly  bool       // Mode=="vet": only being called to supply info about dependencies
+	VetFixes  string     // Mode=="vet": file of suggested fixes written by vet, if any
 	needVet   bool       // Mode=="build": need to fill in vet config
 	needBuild bool       // Mode=="build": need to do actual build (can be false if needVet is true)
 	vetCfg    *vetConfig // vet config
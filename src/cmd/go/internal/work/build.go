@@ -49,6 +49,18 @@ then any resulting executables will be written to that directory.
 
 The -i flag installs the packages that are dependencies of the target.
 
+The -cover flag builds the target with coverage instrumentation.
+When a program built with -cover exits, by returning from main.main
+or calling os.Exit, it writes a coverage counter file to the directory
+named by the GOCOVERDIR environment variable. Use 'go tool covdata' to
+merge counter files and to convert them to a coverage profile for
+'go tool cover'. By default, the packages named on the command line
+and, in module mode, all packages in the main module are instrumented.
+The -covermode and -coverpkg flags set the coverage mode and the
+packages to instrument as for 'go test' (see 'go help testflag'), and
+imply -cover. The coverage flags are accepted by the build, install,
+and run commands.
+
 The build flags are shared by the build, clean, get, install, list, run,
 and test commands:
 
@@ -106,6 +118,12 @@ and test commands:
 		install and load all packages from dir instead of the usual locations.
 		For example, when building with a non-standard configuration,
 		use -pkgdir to keep generated packages in a separate location.
+	-pgo file
+		use the CPU profile in file, in the format written by runtime/pprof,
+		for profile-guided optimization. The gc compiler uses the profile
+		to inline and devirtualize hot calls and to lay out hot code paths.
+		The profile is applied to all packages in the build, and its
+		content is part of each package's build cache key.
 	-tags tag,list
 		a comma-separated list of build tags to consider satisfied during the
 		build. For more information about build tags, see the description of
@@ -169,6 +187,8 @@ func init() {
 
 	AddBuildFlags(CmdBuild)
 	AddBuildFlags(CmdInstall)
+	AddCoverFlags(CmdBuild)
+	AddCoverFlags(CmdInstall)
 }
 
 // Note that flags consulted by other parts of the code
@@ -235,6 +255,7 @@ func AddBuildFlags(cmd *base.Command) {
 	cmd.Flag.Var(&load.BuildLdflags, "ldflags", "")
 	cmd.Flag.BoolVar(&cfg.BuildLinkshared, "linkshared", false, "")
 	cmd.Flag.StringVar(&cfg.BuildPkgdir, "pkgdir", "", "")
+	cmd.Flag.StringVar(&cfg.BuildPGO, "pgo", "", "")
 	cmd.Flag.BoolVar(&cfg.BuildRace, "race", false, "")
 	cmd.Flag.BoolVar(&cfg.BuildMSan, "msan", false, "")
 	cmd.Flag.Var((*tagsFlag)(&cfg.BuildContext.BuildTags), "tags", "")
@@ -246,6 +267,32 @@ func AddBuildFlags(cmd *base.Command) {
 	cmd.Flag.StringVar(&cfg.DebugActiongraph, "debug-actiongraph", "", "")
 }
 
+// AddCoverFlags adds the coverage flags to the flag set of cmd.
+// They are separate from the build flags because 'go test' has
+// its own -cover flags.
+func AddCoverFlags(cmd *base.Command) {
+	cmd.Flag.BoolVar(&cfg.BuildCover, "cover", false, "")
+	cmd.Flag.StringVar(&cfg.BuildCoverMode, "covermode", "", "")
+	cmd.Flag.Var((*coverPkgFlag)(&cfg.BuildCoverPkg), "coverpkg", "")
+}
+
+// coverPkgFlag is the implementation of the -coverpkg flag.
+type coverPkgFlag []string
+
+func (v *coverPkgFlag) Set(s string) error {
+	*v = []string{}
+	for _, s := range strings.Split(s, ",") {
+		if s != "" {
+			*v = append(*v, s)
+		}
+	}
+	return nil
+}
+
+func (v *coverPkgFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
 // tagsFlag is the implementation of the -tags flag.
 type tagsFlag []string
 
@@ -345,6 +392,9 @@ func runBuild(cmd *base.Command, args []string) {
 	}
 
 	pkgs = omitTestOnly(pkgsFilter(load.Packages(args)))
+	if cfg.BuildCover {
+		PrepareCoverageBuild(pkgs)
+	}
 
 	// Special case -o /dev/null by not writing at all.
 	if cfg.BuildO == os.DevNull {
@@ -534,6 +584,10 @@ func InstallPackages(patterns []string, pkgs []*load.Package) {
 	}
 	base.ExitIfErrors()
 
+	if cfg.BuildCover {
+		PrepareCoverageBuild(pkgs)
+	}
+
 	var b Builder
 	b.Init()
 	depMode := ModeBuild
@@ -0,0 +1,141 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Support for 'go build -cover'.
+
+package work
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/load"
+	"cmd/go/internal/str"
+)
+
+func coverInit() {
+	if cfg.BuildCoverMode != "" || cfg.BuildCoverPkg != nil {
+		cfg.BuildCover = true
+	}
+	if !cfg.BuildCover {
+		return
+	}
+	switch cfg.BuildCoverMode {
+	case "":
+		cfg.BuildCoverMode = "set"
+		if cfg.BuildRace {
+			// Default coverage mode is atomic when -race is set.
+			cfg.BuildCoverMode = "atomic"
+		}
+	case "set", "count", "atomic", "branch":
+	default:
+		base.Fatalf("go %s: invalid flag argument for -covermode: %q", cfg.CmdName, cfg.BuildCoverMode)
+	}
+	if cfg.BuildRace && cfg.BuildCoverMode != "atomic" {
+		base.Fatalf(`go %s: -covermode must be "atomic", not %q, when -race is enabled`, cfg.CmdName, cfg.BuildCoverMode)
+	}
+	if cfg.BuildToolchainName == "gccgo" {
+		base.Fatalf("go %s: -cover is not supported by gccgo", cfg.CmdName)
+	}
+}
+
+// PrepareCoverageBuild marks for coverage instrumentation the packages
+// in the build of roots selected by the -coverpkg flag or, by default,
+// the packages named on the command line and the packages in the main
+// module. Each marked package registers its counters with
+// internal/coverage so that the program writes them when it exits.
+func PrepareCoverageBuild(roots []*load.Package) {
+	var match []func(*load.Package) bool
+	matched := make([]bool, len(cfg.BuildCoverPkg))
+	for _, pattern := range cfg.BuildCoverPkg {
+		match = append(match, load.MatchPackage(pattern, base.Cwd))
+	}
+	selected := func(p *load.Package) bool {
+		if match == nil {
+			return p.Internal.CmdlinePkg || p.Module != nil && p.Module.Main
+		}
+		sel := false
+		for i := range match {
+			if match[i](p) {
+				matched[i] = true
+				sel = true
+			}
+		}
+		return sel
+	}
+
+	// The packages that internal/coverage depends on,
+	// including sync/atomic, cannot themselves be covered.
+	covpkg := load.LoadImportWithFlags("internal/coverage", base.Cwd, nil, &load.ImportStack{}, nil, 0)
+	if covpkg.Error != nil {
+		base.Fatalf("load internal/coverage: %v", covpkg.Error)
+	}
+	exclude := make(map[*load.Package]bool)
+	for _, p := range load.PackageList([]*load.Package{covpkg}) {
+		exclude[p] = true
+	}
+
+	for _, p := range load.PackageList(roots) {
+		if !selected(p) || exclude[p] || p.ImportPath == "unsafe" || len(p.GoFiles)+len(p.CgoFiles) == 0 {
+			continue
+		}
+		// If using the race detector, silently ignore
+		// attempts to run coverage on the runtime
+		// packages. It will cause the race detector
+		// to be invoked before it has been initialized.
+		if cfg.BuildRace && p.Standard && (p.ImportPath == "runtime" || str.HasPathPrefix(p.ImportPath, "runtime/internal")) {
+			continue
+		}
+		p.Internal.CoverMode = cfg.BuildCoverMode
+		p.Internal.CoverVars = load.DeclareCoverVars(p, str.StringList(p.GoFiles, p.CgoFiles)...)
+		p.Internal.CoverInit = true
+		if cfg.BuildCoverMode == "atomic" {
+			// The cover tool inserts an import of sync/atomic.
+			load.EnsureImport(p, "sync/atomic")
+		}
+		load.EnsureImport(p, "internal/coverage")
+		if p.Name != "main" {
+			// Don't install instrumented libraries.
+			p.Target = ""
+		}
+	}
+
+	// Warn about -coverpkg arguments that are not actually used.
+	for i, pattern := range cfg.BuildCoverPkg {
+		if !matched[i] {
+			fmt.Fprintf(os.Stderr, "warning: no packages being built depend on matches for pattern %s\n", pattern)
+		}
+	}
+}
+
+// coverInitProg returns the source of a file that registers the
+// coverage variables of package p with internal/coverage.
+func coverInitProg(p *load.Package) []byte {
+	var files []string
+	for file := range p.Internal.CoverVars {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by 'go build -cover'. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", p.Name)
+	fmt.Fprintf(&buf, "import _cover_ %q\n\n", "internal/coverage")
+	fmt.Fprintf(&buf, "func init() {\n")
+	for _, file := range files {
+		cv := p.Internal.CoverVars[file]
+		fmt.Fprintf(&buf, "\t_cover_.RegisterFile(%q, %q, %s.Count[:], %s.Pos[:], %s.NumStmt[:])\n",
+			p.Internal.CoverMode, cv.File, cv.Var, cv.Var, cv.Var)
+		if p.Internal.CoverMode == "branch" {
+			fmt.Fprintf(&buf, "\t_cover_.RegisterBranches(%q, %s.BranchCount[:], %s.BranchPos[:], %s.BranchOutcomes[:])\n",
+				cv.File, cv.Var, cv.Var, cv.Var)
+		}
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return buf.Bytes()
+}
@@ -232,6 +232,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	}
 	if p.Internal.CoverMode != "" {
 		fmt.Fprintf(h, "cover %q %q\n", p.Internal.CoverMode, b.toolID("cover"))
+		if p.Internal.CoverInit {
+			fmt.Fprintf(h, "coverinit\n")
+		}
 	}
 	fmt.Fprintf(h, "modinfo %q\n", p.Internal.BuildInfo)
 
@@ -241,6 +244,15 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 		base.Fatalf("buildActionID: unknown build toolchain %q", cfg.BuildToolchainName)
 	case "gc":
 		fmt.Fprintf(h, "compile %s %q %q\n", b.toolID("compile"), forcedGcflags, p.Internal.Gcflags)
+		if cfg.BuildPGO != "" {
+			// Hash the profile content, not its name, so that
+			// updating the profile invalidates the cached results.
+			fh, err := cache.FileHash(cfg.BuildPGO)
+			if err != nil {
+				base.Fatalf("go: hashing profile: %v", err)
+			}
+			fmt.Fprintf(h, "pgo %x\n", fh)
+		}
 		if len(p.SFiles) > 0 {
 			fmt.Fprintf(h, "asm %q %q %q\n", b.toolID("asm"), forcedAsmflags, p.Internal.Asmflags)
 		}
@@ -669,6 +681,13 @@ func (b *Builder) build(a *Action) (err error) {
 		gofiles = append(gofiles, objdir+"_gomod_.go")
 	}
 
+	if p.Internal.CoverInit {
+		if err := b.writeFile(objdir+"_covinit_.go", coverInitProg(p)); err != nil {
+			return err
+		}
+		gofiles = append(gofiles, objdir+"_covinit_.go")
+	}
+
 	// Compile Go.
 	objpkg := objdir + "_pkg_.a"
 	ofile, out, err := BuildToolchain.gc(b, a, objpkg, icfg.Bytes(), symabis, len(sfiles) > 0, gofiles)
@@ -905,6 +924,7 @@ type vetConfig struct {
 	PackageVetx map[string]string // map package path to vetx data from earlier vet run
 	VetxOnly    bool              // only compute vetx data; don't report detected problems
 	VetxOutput  string            // write vetx data to this output file
+	FixesOutput string            // write suggested fixes to this output file
 
 	SucceedOnTypecheckFailure bool // awful hack; see #18395 and below
 }
@@ -979,6 +999,11 @@ var VetFlags []string
 // VetExplicit records whether the vet flags were set explicitly on the command line.
 var VetExplicit bool
 
+// VetFix records whether vet should write the suggested fixes it finds
+// for the caller to apply, rather than report them as problems.
+// The caller is expected to set it before executing any vet actions.
+var VetFix bool
+
 func (b *Builder) vet(a *Action) error {
 	// a.Deps[0] is the build of the package being vetted.
 	// a.Deps[1] is the build of the "fmt" package.
@@ -1000,6 +1025,10 @@ func (b *Builder) vet(a *Action) error {
 
 	vcfg.VetxOnly = a.VetxOnly
 	vcfg.VetxOutput = a.Objdir + "vet.out"
+	vcfg.FixesOutput = ""
+	if VetFix && !a.VetxOnly {
+		vcfg.FixesOutput = a.Objdir + "vet.fix"
+	}
 	vcfg.PackageVetx = make(map[string]string)
 
 	h := cache.NewHash("vet " + a.Package.ImportPath)
@@ -1087,6 +1116,13 @@ func (b *Builder) vet(a *Action) error {
 		f.Close()
 	}
 
+	// If vet wrote suggested fixes, record them for the caller.
+	if vcfg.FixesOutput != "" {
+		if _, err := os.Stat(vcfg.FixesOutput); err == nil {
+			a.VetFixes = vcfg.FixesOutput
+		}
+	}
+
 	return runErr
 }
 
@@ -79,7 +79,7 @@ func (gcToolchain) gc(b *Builder, a *Action, archive string, importcfg []byte, s
 	extFiles := len(p.CgoFiles) + len(p.CFiles) + len(p.CXXFiles) + len(p.MFiles) + len(p.FFiles) + len(p.SFiles) + len(p.SysoFiles) + len(p.SwigFiles) + len(p.SwigCXXFiles)
 	if p.Standard {
 		switch p.ImportPath {
-		case "bytes", "internal/poll", "net", "os", "runtime/pprof", "runtime/trace", "sync", "syscall", "time":
+		case "bytes", "internal/coverage", "internal/poll", "net", "os", "runtime/pprof", "runtime/trace", "sync", "syscall", "testing", "time":
 			extFiles++
 		}
 	}
@@ -102,6 +102,9 @@ func (gcToolchain) gc(b *Builder, a *Action, archive string, importcfg []byte, s
 	if symabis != "" {
 		gcargs = append(gcargs, "-symabis", symabis)
 	}
+	if cfg.BuildPGO != "" {
+		gcargs = append(gcargs, "-pgoprofile", cfg.BuildPGO)
+	}
 
 	gcflags := str.StringList(forcedGcflags, p.Internal.Gcflags)
 	if compilingRuntime {
@@ -22,6 +22,7 @@ func BuildInit() {
 	load.ModInit()
 	instrumentInit()
 	buildModeInit()
+	coverInit()
 
 	// Make sure -pkgdir is absolute, because we run commands
 	// in different directories.
@@ -34,6 +35,20 @@ func BuildInit() {
 		}
 		cfg.BuildPkgdir = p
 	}
+
+	// Likewise for -pgo.
+	if cfg.BuildPGO != "" {
+		p, err := filepath.Abs(cfg.BuildPGO)
+		if err == nil {
+			_, err = os.Stat(p)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "go %s: -pgo: %v\n", flag.Args()[0], err)
+			base.SetExitStatus(2)
+			base.Exit()
+		}
+		cfg.BuildPGO = p
+	}
 }
 
 func instrumentInit() {
@@ -36,6 +36,7 @@ import (
 	"cmd/go/internal/tool"
 	"cmd/go/internal/version"
 	"cmd/go/internal/vet"
+	"cmd/go/internal/vuln"
 	"cmd/go/internal/work"
 )
 
@@ -58,6 +59,7 @@ func init() {
 		tool.CmdTool,
 		version.CmdVersion,
 		vet.CmdVet,
+		vuln.CmdVuln,
 
 		help.HelpBuildmode,
 		help.HelpC,
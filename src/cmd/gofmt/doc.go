@@ -16,6 +16,9 @@ Usage:
 	gofmt [flags] [path ...]
 
 The flags are:
+	-c
+		Reformat doc comments in the canonical form described in
+		package go/doc/comment.
 	-d
 		Do not print reformatted sources to standard output.
 		If a file's formatting is different than gofmt's, print diffs
@@ -96,6 +99,16 @@ When invoked with -s gofmt will make the following source transformations where
 		for range v {...}
 
 This may result in changes that are incompatible with earlier versions of Go.
+
+Doc comments
+
+When invoked with -c gofmt reformats the doc comments of the package
+clause and of top-level declarations, as described in package
+go/doc/comment: it turns old-style headings into "# Heading" lines,
+indents lists and code blocks consistently, separates headings, lists
+and code blocks from the surrounding text by blank lines, and moves
+link definitions to the end of the comment. Directives such as
+//go:noinline stay at the end of the comment.
 */
 package main
 
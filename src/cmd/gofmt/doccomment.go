@@ -0,0 +1,177 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/doc/comment"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// formatDocComments reformats the doc comments of the package clause
+// and the top-level declarations of the formatted source file src in
+// the canonical form printed by package go/doc/comment.
+//
+// The comments are rewritten in the source text rather than in the
+// AST, because the printer places the code that follows a comment
+// according to the comment's position and length in the original
+// source. The result is then parsed and printed again.
+func formatDocComments(fset *token.FileSet, filename string, src []byte) ([]byte, error) {
+	file, err := parser.ParseFile(fset, filename, src, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	tf := fset.File(file.Pos())
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	docs := []*ast.CommentGroup{file.Doc}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			docs = append(docs, d.Doc)
+		case *ast.GenDecl:
+			docs = append(docs, d.Doc)
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					docs = append(docs, s.Doc)
+				case *ast.ValueSpec:
+					docs = append(docs, s.Doc)
+				}
+			}
+		}
+	}
+	for _, g := range docs {
+		if g == nil {
+			continue
+		}
+		start, end := tf.Offset(g.Pos()), tf.Offset(g.End())
+		line := bytes.LastIndexByte(src[:start], '\n') + 1
+		indent := string(src[line:start])
+		if strings.TrimLeft(indent, " \t") != "" {
+			continue // not on a line of its own
+		}
+		if text, ok := formatDocComment(g.List, indent); ok {
+			edits = append(edits, edit{start, end, text})
+		}
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.start < last {
+			continue // the same comment group, seen twice
+		}
+		buf.Write(src[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.Write(src[last:])
+
+	file, err = parser.ParseFile(fset, filename, buf.Bytes(), parserMode)
+	if err != nil {
+		return nil, err
+	}
+	var res bytes.Buffer
+	cfg := printer.Config{Mode: printerMode, Tabwidth: tabWidth}
+	if err := cfg.Fprint(&res, fset, file); err != nil {
+		return nil, err
+	}
+	return res.Bytes(), nil
+}
+
+// formatDocComment returns the canonical form of the doc comment made
+// of the comments in list, with lines after the first indented by indent.
+// Directives like //go:noinline are not part of the text of the comment;
+// they are kept unchanged after it. The result is false if the comment
+// should be left alone: if it mixes // and /* */ comments, or is a /* */
+// comment that does not span lines.
+func formatDocComment(list []*ast.Comment, indent string) (string, bool) {
+	var text, directives []*ast.Comment
+	for _, c := range list {
+		if isDirective(c.Text) {
+			directives = append(directives, c)
+			continue
+		}
+		if len(text) > 0 && c.Text[1] != text[0].Text[1] {
+			return "", false
+		}
+		text = append(text, c)
+	}
+	if len(text) == 0 || text[0].Text[1] == '*' && (len(text) > 1 || !strings.HasPrefix(text[0].Text, "/*\n")) {
+		return "", false
+	}
+	body := (&ast.CommentGroup{List: text}).Text()
+	if body == "" {
+		return "", false
+	}
+
+	var p comment.Parser
+	var pr comment.Printer
+	body = string(pr.Comment(p.Parse(body)))
+
+	var lines []string
+	if text[0].Text[1] == '*' {
+		lines = append(lines, "/*\n"+body+"*/")
+	} else {
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+			switch {
+			case line == "":
+				line = "//"
+			case strings.HasPrefix(line, "\t"):
+				line = "//" + line
+			default:
+				line = "// " + line
+			}
+			lines = append(lines, line)
+		}
+	}
+	for _, c := range directives {
+		lines = append(lines, c.Text)
+	}
+	return strings.Join(lines, "\n"+indent), true
+}
+
+// isDirective reports whether c is a comment directive such as
+// //go:noinline, //line, //export or //extern, which is not part
+// of the text of a doc comment.
+func isDirective(c string) bool {
+	if !strings.HasPrefix(c, "//") {
+		return false
+	}
+	c = c[2:]
+	if strings.HasPrefix(c, "line ") || strings.HasPrefix(c, "extern ") || strings.HasPrefix(c, "export ") {
+		return true
+	}
+
+	// "//[a-z0-9]+:[a-z0-9]"
+	colon := strings.Index(c, ":")
+	if colon <= 0 || colon+1 >= len(c) {
+		return false
+	}
+	for i := 0; i <= colon+1; i++ {
+		if i == colon {
+			continue
+		}
+		b := c[i]
+		if !('a' <= b && b <= 'z' || '0' <= b && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -29,6 +29,7 @@ var (
 	write       = flag.Bool("w", false, "write result to (source) file instead of stdout")
 	rewriteRule = flag.String("r", "", "rewrite rule (e.g., 'a[b:len(a)] -> a[b:]')")
 	simplifyAST = flag.Bool("s", false, "simplify code")
+	docComments = flag.Bool("c", false, "reformat doc comments")
 	doDiff      = flag.Bool("d", false, "display diffs instead of rewriting files")
 	allErrors   = flag.Bool("e", false, "report all errors (not just the first 10 on different lines)")
 
@@ -119,6 +120,13 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 		return err
 	}
 
+	if *docComments && sourceAdj == nil {
+		res, err = formatDocComments(fileSet, filename, res)
+		if err != nil {
+			return err
+		}
+	}
+
 	if !bytes.Equal(src, res) {
 		// formatting has changed
 		if *list {
@@ -59,6 +59,7 @@ func gofmtFlags(filename string, maxLines int) string {
 func runTest(t *testing.T, in, out string) {
 	// process flags
 	*simplifyAST = false
+	*docComments = false
 	*rewriteRule = ""
 	stdin := false
 	for _, flag := range strings.Split(gofmtFlags(in, 20), " ") {
@@ -75,6 +76,8 @@ func runTest(t *testing.T, in, out string) {
 			*rewriteRule = value
 		case "-s":
 			*simplifyAST = true
+		case "-c":
+			*docComments = true
 		case "-stdin":
 			// fake flag - pretend input is from stdin
 			stdin = true
@@ -0,0 +1,39 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Heapview analyzes the heap dumps written by runtime/debug.WriteHeapDump,
+to find out offline what uses memory in a program and why. Usage:
+
+	go tool heapview [flags] [binary] heap.dump
+
+By default, heapview prints the types whose objects retain the most
+memory. An object retains the objects that are reachable only through
+it: the ones it dominates in the object graph, which would be freed if
+it were. The retained size of a type counts each object only once, even
+if objects of the type dominate other objects of the same type.
+
+The -dom flag prints the dominator tree of the heap instead, largest
+subtrees first, with at most -n children per node, to a depth of -depth.
+
+The -why=addr flag prints what keeps the object that contains addr
+alive: a shortest path of pointers from a root to it, with the offset
+of each pointer in the object that holds it, and the objects that
+dominate it. If the binary that wrote the dump is given, roots in
+global variables are named after the variable.
+
+The runtime does not record the type of each heap object. Heapview
+finds the types of the objects stored in interface values and of
+objects with finalizers in the type information of the dump, those of
+the objects that global variables point to in the debugging information
+of the binary, if given, and the types of the objects that those point
+to in turn. Other objects are named by their size, as in
+"<unknown 64 bytes>". "[...]T" is the backing array of a slice of T,
+"map.hdr[K]V" and "map.bucket[K]V" are the header and buckets of a map,
+and "string data" holds the bytes of strings.
+
+The format of heap dumps is described in the documentation of
+package internal/heapdump.
+*/
+package main
@@ -0,0 +1,405 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"internal/heapdump"
+	"sort"
+	"strings"
+)
+
+// A heapGraph is the object graph of a heap dump.
+// Node 0 is a pseudo-node for the roots; node i+1 is objs[i].
+type heapGraph struct {
+	d     *heapdump.Dump
+	objs  []*heapdump.Object
+	roots []*heapdump.Root
+
+	// Edges in compressed form: the successors of node v
+	// are edges[start[v]:start[v+1]].
+	start []int32
+	edges []int32
+
+	typ     []*heapdump.Type // inferred type of each node, or nil
+	array   []bool           // the node holds several values of typ
+	mapHdrs map[*heapdump.Type]*heapdump.Type
+
+	idom     []int32  // immediate dominator of each node, -1 if unreachable
+	retained []uint64 // size of the objects that each node dominates, itself included
+}
+
+// newHeapGraph builds the object graph of d and computes its dominator tree.
+// The symbols of the binary that wrote the dump, if not nil, give the
+// types of global variables.
+func newHeapGraph(d *heapdump.Dump, syms *symbols) *heapGraph {
+	g := &heapGraph{d: d, objs: d.Objects, roots: d.Roots()}
+	n := len(g.objs) + 1
+	g.start = make([]int32, 0, n+1)
+	g.start = append(g.start, 0)
+	for _, r := range g.roots {
+		if v := g.node(r.To); v > 0 {
+			g.edges = append(g.edges, v)
+		}
+	}
+	for i, o := range g.objs {
+		g.start = append(g.start, int32(len(g.edges)))
+		for _, off := range o.Ptrs {
+			if v := g.node(g.word(o.Data, off)); v > 0 && v != int32(i+1) {
+				g.edges = append(g.edges, v)
+			}
+		}
+	}
+	g.start = append(g.start, int32(len(g.edges)))
+
+	g.inferTypes(syms)
+	g.idom = dominators(n, g.succ)
+	g.retained = retainedSizes(g.idom, g.size)
+	return g
+}
+
+// node returns the node of the object that contains addr, or -1.
+func (g *heapGraph) node(addr uint64) int32 {
+	i := sort.Search(len(g.objs), func(i int) bool {
+		return g.objs[i].Addr > addr
+	})
+	if i == 0 || addr >= g.objs[i-1].Addr+g.objs[i-1].Size() {
+		return -1
+	}
+	return int32(i)
+}
+
+func (g *heapGraph) succ(v int32) []int32 {
+	return g.edges[g.start[v]:g.start[v+1]]
+}
+
+func (g *heapGraph) obj(v int32) *heapdump.Object {
+	return g.objs[v-1]
+}
+
+func (g *heapGraph) size(v int32) uint64 {
+	if v == 0 {
+		return 0
+	}
+	return g.obj(v).Size()
+}
+
+// word returns the pointer at offset off of b, or 0 if it is out of range.
+func (g *heapGraph) word(b []byte, off uint64) uint64 {
+	if off+uint64(g.d.Params.PtrSize) > uint64(len(b)) {
+		return 0
+	}
+	return g.d.Word(b, off)
+}
+
+// typeName returns the name of the type of node v.
+// Objects of unknown type are named by their size.
+func (g *heapGraph) typeName(v int32) string {
+	t := g.typ[v]
+	switch {
+	case v == 0:
+		return "<roots>"
+	case t == nil:
+		return fmt.Sprintf("<unknown %d bytes>", g.size(v))
+	case g.array[v]:
+		return "[...]" + t.Name
+	}
+	return t.Name
+}
+
+// Kinds of the types that heapview makes up for runtime data structures.
+const (
+	kindMapHeader  = -1 - iota // runtime.hmap; Elem is the bucket type
+	kindStringData             // the bytes of strings
+)
+
+var stringData = &heapdump.Type{Name: "string data", Kind: kindStringData}
+
+// mapHeader returns the made up type of the runtime.hmap of maps of type t.
+func (g *heapGraph) mapHeader(t *heapdump.Type) *heapdump.Type {
+	h := g.mapHdrs[t]
+	if h == nil {
+		h = &heapdump.Type{
+			Name:    "map.hdr" + strings.TrimPrefix(t.Name, "map"),
+			Kind:    kindMapHeader,
+			PtrData: uint64(g.d.Params.PtrSize),
+			Elem:    t.Elem,
+		}
+		g.mapHdrs[t] = h
+	}
+	return h
+}
+
+// inferTypes computes the types of the objects. The runtime does not
+// record the type of each object, but it records the types in
+// interface values and of objects with finalizers, the binary records
+// the types of global variables, and the layout of those types gives
+// the types of the objects that they point to.
+// Objects that cannot be reached that way have an unknown type.
+func (g *heapGraph) inferTypes(syms *symbols) {
+	n := len(g.objs) + 1
+	g.typ = make([]*heapdump.Type, n)
+	g.array = make([]bool, n)
+	g.mapHdrs = make(map[*heapdump.Type]*heapdump.Type)
+	if g.d.Version < 14 {
+		return
+	}
+	var queue []int32
+	set := func(addr uint64, t *heapdump.Type, array bool) {
+		v := g.node(addr)
+		if v <= 0 || t == nil || g.typ[v] != nil || g.obj(v).Addr != addr || t.Size > g.size(v) {
+			return
+		}
+		g.typ[v] = t
+		g.array[v] = array && t.Size > 0
+		queue = append(queue, v)
+	}
+
+	if syms != nil && len(syms.globals) > 0 {
+		byName := make(map[string]*heapdump.Type)
+		for _, t := range g.d.Types {
+			byName[t.Name] = t
+		}
+		for _, s := range g.d.Segments {
+			for _, gl := range syms.globals {
+				if t := byName[gl.typ]; t != nil && gl.addr >= s.Addr && gl.addr < s.Addr+uint64(len(s.Data)) {
+					g.walk(s.Data, gl.addr-s.Addr, t, set)
+				}
+			}
+		}
+	}
+
+	for _, f := range g.d.Finalizers {
+		if pt := g.d.Types[f.ObjType]; pt != nil {
+			set(f.Obj, g.d.Types[pt.Elem], false)
+		}
+	}
+
+	// Interface values are a pair of a type or itab and a data word.
+	// Only the data word is a pointer in the dump.
+	iface := func(b []byte, ptrs []uint64) {
+		ptrSize := uint64(g.d.Params.PtrSize)
+		for _, off := range ptrs {
+			if off < ptrSize {
+				continue
+			}
+			w := g.word(b, off-ptrSize)
+			t := g.d.Types[w]
+			if t == nil {
+				if it, ok := g.d.Itabs[w]; ok {
+					t = g.d.Types[it]
+				}
+			}
+			if t == nil {
+				continue
+			}
+			data := g.word(b, off)
+			if !t.Direct {
+				set(data, t, false)
+			} else if t.Kind == heapdump.KindPtr {
+				set(data, g.d.Types[t.Elem], false)
+			} else if t.Kind == heapdump.KindMap {
+				set(data, g.mapHeader(t), false)
+			}
+		}
+	}
+	for _, s := range g.d.Segments {
+		iface(s.Data, s.Ptrs)
+	}
+	for _, gr := range g.d.Goroutines {
+		for _, f := range gr.Frames {
+			iface(f.Data, f.Ptrs)
+		}
+	}
+	for _, o := range g.objs {
+		iface(o.Data, o.Ptrs)
+	}
+
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		o, t := g.obj(v), g.typ[v]
+		if t.PtrData == 0 {
+			continue
+		}
+		n := uint64(1)
+		if g.array[v] {
+			n = o.Size() / t.Size
+		}
+		for i := uint64(0); i < n; i++ {
+			g.walk(o.Data, i*t.Size, t, set)
+		}
+	}
+}
+
+// walk calls set for the pointers in the value of type t at offset off of b.
+func (g *heapGraph) walk(b []byte, off uint64, t *heapdump.Type, set func(uint64, *heapdump.Type, bool)) {
+	if t == nil || t.PtrData == 0 {
+		return
+	}
+	switch t.Kind {
+	case heapdump.KindPtr:
+		set(g.word(b, off), g.d.Types[t.Elem], false)
+	case heapdump.KindSlice:
+		set(g.word(b, off), g.d.Types[t.Elem], true)
+	case heapdump.KindString:
+		set(g.word(b, off), stringData, false)
+	case heapdump.KindMap:
+		set(g.word(b, off), g.mapHeader(t), false)
+	case kindMapHeader:
+		// The buckets and the old buckets of a runtime.hmap
+		// follow its count, flags, B, noverflow and hash0 fields.
+		ptrSize := uint64(g.d.Params.PtrSize)
+		bucket := g.d.Types[t.Elem]
+		set(g.word(b, off+ptrSize+8), bucket, true)
+		set(g.word(b, off+2*ptrSize+8), bucket, true)
+	case heapdump.KindArray:
+		elem := g.d.Types[t.Elem]
+		if elem == nil || elem.Size == 0 {
+			return
+		}
+		for i := uint64(0); i < t.Len && off+i*elem.Size < uint64(len(b)); i++ {
+			g.walk(b, off+i*elem.Size, elem, set)
+		}
+	case heapdump.KindStruct:
+		for _, f := range t.Fields {
+			g.walk(b, off+f.Offset, g.d.Types[f.Type], set)
+		}
+	}
+}
+
+// dominators returns the immediate dominator of each of the n nodes
+// of a graph, with respect to node 0, using the Lengauer-Tarjan
+// algorithm. The immediate dominator of node 0 is 0 and that of
+// the nodes that are not reachable from node 0 is -1.
+func dominators(n int, succ func(int32) []int32) []int32 {
+	var (
+		dfnum    = make([]int32, n) // preorder number, -1 if not visited
+		vertex   = make([]int32, 0, n)
+		parent   = make([]int32, n)
+		semi     = make([]int32, n) // preorder number of the semidominator
+		ancestor = make([]int32, n)
+		label    = make([]int32, n)
+		idom     = make([]int32, n)
+		preds    = make([][]int32, n)
+	)
+	for i := range dfnum {
+		dfnum[i] = -1
+		ancestor[i] = -1
+		idom[i] = -1
+	}
+
+	// Number the nodes in depth-first preorder.
+	type frame struct {
+		v int32
+		i int
+	}
+	stack := []frame{{0, 0}}
+	dfnum[0] = 0
+	vertex = append(vertex, 0)
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		s := succ(f.v)
+		if f.i == len(s) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		w := s[f.i]
+		f.i++
+		preds[w] = append(preds[w], f.v)
+		if dfnum[w] < 0 {
+			dfnum[w] = int32(len(vertex))
+			vertex = append(vertex, w)
+			parent[w] = f.v
+			stack = append(stack, frame{w, 0})
+		}
+	}
+	for _, v := range vertex {
+		semi[v] = dfnum[v]
+		label[v] = v
+	}
+
+	var path []int32
+	eval := func(v int32) int32 {
+		if ancestor[v] < 0 {
+			return v
+		}
+		// Compress the path from v to the root of its tree in the forest.
+		path = path[:0]
+		for x := v; ancestor[ancestor[x]] >= 0; x = ancestor[x] {
+			path = append(path, x)
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			x := path[i]
+			a := ancestor[x]
+			if semi[label[a]] < semi[label[x]] {
+				label[x] = label[a]
+			}
+			ancestor[x] = ancestor[a]
+		}
+		return label[v]
+	}
+
+	buckets := make([][]int32, n)
+	for i := len(vertex) - 1; i > 0; i-- {
+		w := vertex[i]
+		for _, v := range preds[w] {
+			if u := eval(v); semi[u] < semi[w] {
+				semi[w] = semi[u]
+			}
+		}
+		sd := vertex[semi[w]]
+		buckets[sd] = append(buckets[sd], w)
+		p := parent[w]
+		ancestor[w] = p
+		for _, v := range buckets[p] {
+			if u := eval(v); semi[u] < semi[v] {
+				idom[v] = u
+			} else {
+				idom[v] = p
+			}
+		}
+		buckets[p] = nil
+	}
+	for _, w := range vertex[1:] {
+		if idom[w] != vertex[semi[w]] {
+			idom[w] = idom[idom[w]]
+		}
+	}
+	idom[0] = 0
+	return idom
+}
+
+// domChildren returns the children of each node in the dominator tree.
+func domChildren(idom []int32) [][]int32 {
+	children := make([][]int32, len(idom))
+	for v, d := range idom {
+		if v != 0 && d >= 0 {
+			children[d] = append(children[d], int32(v))
+		}
+	}
+	return children
+}
+
+// retainedSizes returns the total size of the nodes that each node
+// dominates, given the immediate dominators of the nodes.
+func retainedSizes(idom []int32, size func(int32) uint64) []uint64 {
+	children := domChildren(idom)
+	retained := make([]uint64, len(idom))
+	// Visit the dominator tree in preorder, then add up
+	// the sizes in reverse, so that children come first.
+	order := []int32{0}
+	for i := 0; i < len(order); i++ {
+		order = append(order, children[order[i]]...)
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		v := order[i]
+		retained[v] += size(v)
+		if v != 0 {
+			retained[idom[v]] += retained[v]
+		}
+	}
+	return retained
+}
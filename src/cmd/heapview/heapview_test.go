@@ -0,0 +1,166 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"internal/heapdump"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestDominators(t *testing.T) {
+	//     0
+	//    / \
+	//   1   2
+	//   |\ /
+	//   | 3    5 (unreachable)
+	//   |/ \
+	//   4   6 -> 1
+	succ := [][]int32{
+		0: {1, 2},
+		1: {3, 4},
+		2: {3},
+		3: {4, 6},
+		4: {},
+		5: {4},
+		6: {1},
+	}
+	idom := dominators(len(succ), func(v int32) []int32 { return succ[v] })
+	want := []int32{0, 0, 0, 0, 0, -1, 3}
+	if !reflect.DeepEqual(idom, want) {
+		t.Errorf("got dominators %v, want %v", idom, want)
+	}
+
+	retained := retainedSizes(idom, func(int32) uint64 { return 1 })
+	if want := []uint64{6, 1, 1, 2, 1, 0, 1}; !reflect.DeepEqual(retained, want) {
+		t.Errorf("got retained sizes %v, want %v", retained, want)
+	}
+}
+
+type tree struct {
+	left, right *tree
+	items       []*item
+}
+
+type item struct {
+	data [64]byte
+}
+
+// leak is found through the interface value that holds it.
+var leak interface{}
+
+func newTree(depth int) *tree {
+	t := new(tree)
+	for i := 0; i < 3; i++ {
+		t.items = append(t.items, new(item))
+	}
+	if depth > 0 {
+		t.left = newTree(depth - 1)
+		t.right = newTree(depth - 1)
+	}
+	return t
+}
+
+func TestHeapView(t *testing.T) {
+	if runtime.GOOS == "js" || runtime.GOOS == "nacl" {
+		t.Skipf("WriteHeapDump is not available on %s", runtime.GOOS)
+	}
+	root := newTree(4)
+	leak = root
+	defer func() { leak = nil }()
+
+	f, err := ioutil.TempFile("", "heapview")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	debug.WriteHeapDump(f.Fd())
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	d, err := heapdump.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newHeapGraph(d, nil)
+
+	node := func(addr interface{}) int32 {
+		t.Helper()
+		v := g.node(uint64(reflect.ValueOf(addr).Pointer()))
+		if v <= 0 {
+			t.Fatalf("object %p not found in the dump", addr)
+		}
+		return v
+	}
+	// The runtime names unnamed types after the package name
+	// and named types after the package path.
+	for _, c := range []struct {
+		addr interface{}
+		typ  string
+	}{
+		{root, "cmd/heapview.tree"},
+		{root.left.right, "cmd/heapview.tree"},
+		{root.left.items[1], "cmd/heapview.item"},
+		{&root.right.items[0], "[...]*main.item"},
+	} {
+		if got := g.typeName(node(c.addr)); got != c.typ {
+			t.Errorf("object %p has type %s, want %s", c.addr, got, c.typ)
+		}
+	}
+
+	// The root of the tree dominates all other trees and items.
+	const ntrees = 1<<5 - 1
+	itemSize, treeSize := g.size(node(root.items[0])), g.size(node(root))
+	min := ntrees * (treeSize + 3*itemSize)
+	if r := g.retained[node(root)]; r < min {
+		t.Errorf("tree retains %d bytes, want at least %d", r, min)
+	}
+	for _, s := range typeStats(g) {
+		switch s.name {
+		case "cmd/heapview.tree":
+			if s.count != ntrees || s.retained != g.retained[node(root)] {
+				t.Errorf("type tree: got %d objects retaining %d bytes, want %d objects retaining %d bytes", s.count, s.retained, ntrees, g.retained[node(root)])
+			}
+		case "cmd/heapview.item":
+			if s.count != 3*ntrees || s.retained != 3*ntrees*itemSize {
+				t.Errorf("type item: got %d objects retaining %d bytes, want %d objects retaining %d bytes", s.count, s.retained, 3*ntrees, 3*ntrees*itemSize)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	target := root.left.left.items[2]
+	if err := whyReport(&buf, g, uint64(reflect.ValueOf(target).Pointer()), nil); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "-> "); n != 5 {
+		t.Errorf("path to %p has %d steps, want 5:\n%s", target, n, out)
+	}
+	for _, want := range []string{
+		fmt.Sprintf("%p cmd/heapview.tree +0x0", root.left),
+		fmt.Sprintf("%p [...]*main.item +0x10", &root.left.left.items[0]),
+		fmt.Sprintf("%p cmd/heapview.tree, which retains", root.left.left),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("-why output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	domReport(&buf, g, 1, len(g.objs))
+	if !strings.Contains(buf.String(), fmt.Sprintf("%p cmd/heapview.tree", root)) {
+		t.Errorf("dominator tree does not contain the tree root %p:\n%s", root, buf.String())
+	}
+	runtime.KeepAlive(root)
+}
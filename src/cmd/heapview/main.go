@@ -0,0 +1,463 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"debug/dwarf"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"internal/heapdump"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"cmd/internal/objabi"
+	"cmd/internal/objfile"
+)
+
+const usageMessage = "" +
+	`Usage of 'go tool heapview':
+Given a heap dump written by runtime/debug.WriteHeapDump:
+	go tool heapview [flags] [binary] heap.dump
+
+Print the types that retain the most memory:
+	go tool heapview heap.dump
+Print the dominator tree of the heap:
+	go tool heapview -dom heap.dump
+Print what keeps the object at address 0xc000123000 alive:
+	go tool heapview -why=0xc000123000 prog heap.dump
+
+The optional binary is the program that wrote the dump. It is used
+to name the global variables that keep objects alive, and to find
+the types of the objects they point to.
+`
+
+var (
+	domFlag   = flag.Bool("dom", false, "print the dominator tree")
+	depthFlag = flag.Int("depth", 4, "maximum depth of the dominator tree")
+	nFlag     = flag.Int("n", 20, "number of types, or of children of each node of the dominator tree, to print")
+	whyFlag   = flag.String("why", "", "print why the object at this address is alive")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, usageMessage)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	objabi.AddVersionFlag()
+	flag.Usage = usage
+	flag.Parse()
+
+	var binary, dumpFile string
+	switch flag.NArg() {
+	case 1:
+		dumpFile = flag.Arg(0)
+	case 2:
+		binary, dumpFile = flag.Arg(0), flag.Arg(1)
+	default:
+		usage()
+	}
+
+	f, err := os.Open(dumpFile)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	d, err := heapdump.Read(f)
+	f.Close()
+	if err != nil {
+		fatalf("%s: %v", dumpFile, err)
+	}
+	var syms *symbols
+	if binary != "" {
+		if syms, err = readSymbols(binary, d.Params); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	g := newHeapGraph(d, syms)
+	w := bufio.NewWriter(os.Stdout)
+	switch {
+	case *whyFlag != "":
+		addr, err := strconv.ParseUint(*whyFlag, 0, 64)
+		if err != nil {
+			fatalf("invalid address %q", *whyFlag)
+		}
+		err = whyReport(w, g, addr, syms)
+		if err != nil {
+			w.Flush()
+			fatalf("%v", err)
+		}
+	case *domFlag:
+		domReport(w, g, *depthFlag, *nFlag)
+	default:
+		typeReport(w, g, *nFlag)
+	}
+	if err := w.Flush(); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "heapview: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// typeStat holds the statistics of the objects of a type.
+type typeStat struct {
+	name     string
+	count    int
+	size     uint64
+	retained uint64 // size of the objects that the objects of the type dominate
+}
+
+// typeStats returns the statistics of the types of the reachable
+// objects of g, sorted by decreasing retained size.
+func typeStats(g *heapGraph) []*typeStat {
+	byName := make(map[string]*typeStat)
+	stat := func(v int32) *typeStat {
+		name := g.typeName(v)
+		s := byName[name]
+		if s == nil {
+			s = &typeStat{name: name}
+			byName[name] = s
+		}
+		return s
+	}
+
+	// The objects that an object of a type dominates may include
+	// other objects of that type; count only the outermost ones.
+	children := domChildren(g.idom)
+	active := make(map[*typeStat]int)
+	type frame struct {
+		v int32
+		s *typeStat
+		i int
+	}
+	stack := []frame{{v: 0}}
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		if f.i == len(children[f.v]) {
+			if f.s != nil {
+				active[f.s]--
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		v := children[f.v][f.i]
+		f.i++
+		s := stat(v)
+		s.count++
+		s.size += g.size(v)
+		if active[s] == 0 {
+			s.retained += g.retained[v]
+		}
+		active[s]++
+		stack = append(stack, frame{v: v, s: s})
+	}
+
+	stats := make([]*typeStat, 0, len(byName))
+	for _, s := range byName {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].retained != stats[j].retained {
+			return stats[i].retained > stats[j].retained
+		}
+		return stats[i].name < stats[j].name
+	})
+	return stats
+}
+
+// typeReport prints the n types that retain the most memory.
+func typeReport(w io.Writer, g *heapGraph, n int) {
+	var reachable uint64
+	var count int
+	for v := 1; v < len(g.idom); v++ {
+		if g.idom[v] >= 0 {
+			reachable += g.size(int32(v))
+			count++
+		}
+	}
+	fmt.Fprintf(w, "%d reachable objects, %s; %d unreachable objects\n\n", count, formatSize(reachable), len(g.objs)-count)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "retained\tretained%%\tsize\tcount\t %s\n", "type")
+	for i, s := range typeStats(g) {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%.2f%%\t%s\t%d\t %s\n", formatSize(s.retained), percent(s.retained, reachable), formatSize(s.size), s.count, s.name)
+	}
+	tw.Flush()
+}
+
+// domReport prints the dominator tree of g to the given depth, with
+// at most n children of each node, largest first.
+func domReport(w io.Writer, g *heapGraph, depth, n int) {
+	children := domChildren(g.idom)
+	var visit func(v int32, level int)
+	visit = func(v int32, level int) {
+		indent := fmt.Sprintf("%*s", 2*level, "")
+		if v == 0 {
+			fmt.Fprintf(w, "%s %s\n", formatSize(g.retained[0]), g.typeName(0))
+		} else {
+			fmt.Fprintf(w, "%s%s %#x %s (%s)\n", indent, formatSize(g.retained[v]), g.obj(v).Addr, g.typeName(v), formatSize(g.size(v)))
+		}
+		if level == depth {
+			return
+		}
+		c := children[v]
+		sort.Slice(c, func(i, j int) bool { return g.retained[c[i]] > g.retained[c[j]] })
+		for i, u := range c {
+			if i == n {
+				var rest uint64
+				for _, u := range c[i:] {
+					rest += g.retained[u]
+				}
+				fmt.Fprintf(w, "%s  %s in %d more\n", indent, formatSize(rest), len(c)-i)
+				break
+			}
+			visit(u, level+1)
+		}
+	}
+	visit(0, 0)
+}
+
+// whyReport prints a shortest path from a root to the object at addr,
+// and the objects that dominate it.
+func whyReport(w io.Writer, g *heapGraph, addr uint64, syms *symbols) error {
+	target := g.node(addr)
+	if target <= 0 {
+		return fmt.Errorf("no heap object at %#x", addr)
+	}
+	o := g.obj(target)
+	fmt.Fprintf(w, "%#x %s (%s", o.Addr, g.typeName(target), formatSize(o.Size()))
+	if addr != o.Addr {
+		fmt.Fprintf(w, ", contains %#x", addr)
+	}
+	fmt.Fprintf(w, ")\n")
+	if g.idom[target] < 0 {
+		fmt.Fprintf(w, "is not reachable; it will be freed by the next garbage collection\n")
+		return nil
+	}
+
+	// Find a shortest path from the roots with a breadth-first search.
+	parent := make([]int32, len(g.idom))
+	for i := range parent {
+		parent[i] = -1
+	}
+	queue := []int32{0}
+	for len(queue) > 0 && parent[target] < 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, u := range g.succ(v) {
+			if parent[u] < 0 && u != 0 {
+				parent[u] = v
+				queue = append(queue, u)
+			}
+		}
+	}
+	var path []int32
+	for v := target; v != 0; v = parent[v] {
+		path = append(path, v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	fmt.Fprintf(w, "is reachable from:\n")
+	for _, r := range g.roots {
+		if g.node(r.To) == path[0] {
+			fmt.Fprintf(w, "\t%s\n", rootName(r, syms))
+			break
+		}
+	}
+	for i, v := range path {
+		fmt.Fprintf(w, "\t-> %#x %s", g.obj(v).Addr, g.typeName(v))
+		if i+1 < len(path) {
+			fmt.Fprintf(w, " +%#x", g.pointerTo(v, path[i+1]))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	fmt.Fprintf(w, "is retained by:\n")
+	var doms []int32
+	for v := g.idom[target]; v != 0; v = g.idom[v] {
+		doms = append(doms, v)
+	}
+	if len(doms) == 0 {
+		fmt.Fprintf(w, "\tonly the roots\n")
+	}
+	for i := len(doms) - 1; i >= 0; i-- {
+		v := doms[i]
+		fmt.Fprintf(w, "\t%#x %s, which retains %s\n", g.obj(v).Addr, g.typeName(v), formatSize(g.retained[v]))
+	}
+	return nil
+}
+
+// pointerTo returns the offset in node v of a pointer to node u.
+func (g *heapGraph) pointerTo(v, u int32) uint64 {
+	o := g.obj(v)
+	for _, off := range o.Ptrs {
+		if g.node(g.word(o.Data, off)) == u {
+			return off
+		}
+	}
+	return 0
+}
+
+// rootName describes r, naming the global variable that holds it
+// if syms is not nil.
+func rootName(r *heapdump.Root, syms *symbols) string {
+	name := r.String()
+	if r.Kind == heapdump.RootData || r.Kind == heapdump.RootBSS {
+		if s := syms.lookup(r.Addr); s != "" {
+			name += " (" + s + ")"
+		}
+	}
+	return name
+}
+
+// symbols holds the data symbols of a binary, sorted by address,
+// and its global variables.
+type symbols struct {
+	syms    []objfile.Sym
+	globals []global
+}
+
+// A global is a global variable whose type is known from
+// the debugging information of a binary.
+type global struct {
+	addr uint64
+	typ  string // name of the type
+}
+
+// readSymbols reads the symbols of the binary that wrote a heap dump
+// with parameters p.
+func readSymbols(binary string, p heapdump.Params) (*symbols, error) {
+	f, err := objfile.Open(binary)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	all, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	s := new(symbols)
+	for _, sym := range all {
+		switch sym.Code {
+		case 'D', 'd', 'B', 'b':
+			s.syms = append(s.syms, sym)
+		}
+	}
+	sort.Slice(s.syms, func(i, j int) bool { return s.syms[i].Addr < s.syms[j].Addr })
+
+	// Binaries built with -ldflags=-w have no debugging information;
+	// their globals are only named.
+	if d, err := f.DWARF(); err == nil {
+		s.globals = readGlobals(d, p)
+	}
+	return s, nil
+}
+
+// readGlobals returns the global variables described by d.
+func readGlobals(d *dwarf.Data, p heapdump.Params) []global {
+	var order binary.ByteOrder = binary.LittleEndian
+	if p.BigEndian {
+		order = binary.BigEndian
+	}
+	typeNames := make(map[dwarf.Offset]string)
+	typeName := func(off dwarf.Offset) string {
+		if name, ok := typeNames[off]; ok {
+			return name
+		}
+		r := d.Reader()
+		r.Seek(off)
+		name := ""
+		if e, err := r.Next(); err == nil && e != nil {
+			name, _ = e.Val(dwarf.AttrName).(string)
+		}
+		typeNames[off] = name
+		return name
+	}
+
+	var globals []global
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			continue
+		case dwarf.TagVariable:
+			// The location of a global is DW_OP_addr followed by its address.
+			loc, _ := e.Val(dwarf.AttrLocation).([]byte)
+			off, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+			if !ok || len(loc) != 1+p.PtrSize || loc[0] != 0x03 {
+				break
+			}
+			addr := uint64(order.Uint32(loc[1:]))
+			if p.PtrSize == 8 {
+				addr = order.Uint64(loc[1:])
+			}
+			if name := typeName(off); name != "" {
+				globals = append(globals, global{addr: addr, typ: name})
+			}
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+	}
+	return globals
+}
+
+// lookup returns the symbol that contains addr, with the offset
+// into it if it is not 0, or "" if there is none.
+func (s *symbols) lookup(addr uint64) string {
+	if s == nil {
+		return ""
+	}
+	i := sort.Search(len(s.syms), func(i int) bool { return s.syms[i].Addr > addr })
+	if i == 0 {
+		return ""
+	}
+	sym := s.syms[i-1]
+	if addr >= sym.Addr+uint64(sym.Size) {
+		return ""
+	}
+	if addr == sym.Addr {
+		return sym.Name
+	}
+	return fmt.Sprintf("%s+%#x", sym.Name, addr-sym.Addr)
+}
+
+func percent(x, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(x) / float64(total)
+}
+
+func formatSize(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2fkB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package diff implements a Diff function that compares two inputs
+// using the 'diff' tool.
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Diff returns a unified diff of old and new, labeling them
+// oldName and newName in the diff header.
+// It returns an empty slice if old and new are identical.
+func Diff(oldName string, old []byte, newName string, new []byte) ([]byte, error) {
+	f1, err := writeTempFile("diff", old)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTempFile("diff", new)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	cmd := "diff"
+	if runtime.GOOS == "plan9" {
+		cmd = "/bin/ape/diff"
+	}
+
+	data, err := exec.Command(cmd, "-u", f1, f2).CombinedOutput()
+	if len(data) == 0 {
+		return nil, err
+	}
+
+	// diff exits with a non-zero status when the files don't match.
+	// Ignore that failure as long as we get output, and replace
+	// the temporary file names and timestamps in the header:
+	//
+	//	--- /tmp/diff316145376	2017-02-03 19:13:00.280468375 -0500
+	//	+++ /tmp/diff617882815	2017-02-03 19:13:00.280468375 -0500
+	//
+	// becomes
+	//
+	//	--- oldName
+	//	+++ newName
+	lines := bytes.SplitN(data, []byte{'\n'}, 3)
+	if len(lines) < 3 || !bytes.HasPrefix(lines[0], []byte("--- ")) || !bytes.HasPrefix(lines[1], []byte("+++ ")) {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString("--- " + oldName + "\n")
+	buf.WriteString("+++ " + newName + "\n")
+	buf.Write(lines[2])
+	return buf.Bytes(), nil
+}
+
+func writeTempFile(prefix string, data []byte) (string, error) {
+	file, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
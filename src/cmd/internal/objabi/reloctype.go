@@ -221,6 +221,13 @@ const (
 	// of a symbol. This isn't a real relocation, it can be placed in anywhere
 	// in a symbol and target any symbols.
 	R_XCOFFREF
+
+	// R_USENAMEDMETHOD marks that the function calls a method of a
+	// reflect.Value by a constant name, as in v.MethodByName("String").
+	// Its symbol is the string symbol holding the method name.
+	// No relocation is created; the linker uses this as a signal that
+	// exported methods with that name must be kept in reachable types.
+	R_USENAMEDMETHOD
 )
 
 // IsDirectJump reports whether r is a relocation for a direct jump.
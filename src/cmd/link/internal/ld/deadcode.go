@@ -27,7 +27,7 @@ import (
 //
 //	1. direct call
 //	2. through a reachable interface type
-//	3. reflect.Value.Call, .Method, or reflect.Method.Func
+//	3. reflect.Value.Method or MethodByName, or reflect.Method.Func
 //
 // The first case is handled by the flood fill, a directly called method
 // is marked as reachable.
@@ -37,12 +37,19 @@ import (
 // against the interface method signatures, if it matches it is marked
 // as reachable. This is extremely conservative, but easy and correct.
 //
-// The third case is handled by looking to see if any of:
-//	- reflect.Value.Call is reachable
-//	- reflect.Value.Method is reachable
-// 	- reflect.Type.Method or MethodByName is called.
-// If any of these happen, all bets are off and all exported methods
-// of reachable types are marked reachable.
+// The third case is handled by the compiler, which records how
+// reachable functions use reflection to find methods. A function that
+// calls reflect.Type.MethodByName or reflect.Value.MethodByName with a
+// constant name carries an R_USENAMEDMETHOD relocation for that name,
+// and exported methods with that name are marked reachable.
+// A function that calls reflect.Type.Method or reflect.Value.Method,
+// or MethodByName with a name that is not constant, is marked with
+// AttrReflectMethod. If such a function is reachable, all bets are off
+// and all exported methods of reachable types are marked reachable.
+// The compiler only sees direct calls, so the same happens if
+// reflect.Value.Method or MethodByName is reached in any other way:
+// as a method, through an interface or reflection, or as a function
+// value, through a method expression.
 //
 // Any unreached text symbols are removed from ctxt.Textp.
 func deadcode(ctxt *Link) {
@@ -53,6 +60,7 @@ func deadcode(ctxt *Link) {
 	d := &deadcodepass{
 		ctxt:        ctxt,
 		ifaceMethod: make(map[methodsig]bool),
+		namedMethod: make(map[string]bool),
 	}
 
 	// First, flood fill any symbols directly reachable in the call
@@ -60,8 +68,6 @@ func deadcode(ctxt *Link) {
 	d.init()
 	d.flood()
 
-	callSym := ctxt.Syms.ROLookup("reflect.Value.Call", sym.SymVerABIInternal)
-	methSym := ctxt.Syms.ROLookup("reflect.Value.Method", sym.SymVerABIInternal)
 	reflectSeen := false
 
 	if ctxt.DynlinkingGo() {
@@ -72,7 +78,7 @@ func deadcode(ctxt *Link) {
 
 	for {
 		if !reflectSeen {
-			if d.reflectMethod || (callSym != nil && callSym.Attr.Reachable()) || (methSym != nil && methSym.Attr.Reachable()) {
+			if d.reflectMethod {
 				// Methods might be called via reflection. Give up on
 				// static analysis, mark all exported methods of
 				// all reachable types as reachable.
@@ -81,12 +87,13 @@ func deadcode(ctxt *Link) {
 		}
 
 		// Mark all methods that could satisfy a discovered
-		// interface as reachable. We recheck old marked interfaces
+		// interface or be found by a discovered MethodByName call
+		// as reachable. We recheck old marked interfaces and names
 		// as new types (with new methods) may have been discovered
 		// in the last pass.
 		var rem []methodref
 		for _, m := range d.markableMethods {
-			if (reflectSeen && m.isExported()) || d.ifaceMethod[m.m] {
+			if ((reflectSeen || d.namedMethod[m.name()]) && m.isExported()) || d.ifaceMethod[m.m] {
 				d.markMethod(m)
 			} else {
 				rem = append(rem, m)
@@ -111,6 +118,15 @@ func deadcode(ctxt *Link) {
 	if ctxt.BuildMode != BuildModeShared {
 		// Keep a itablink if the symbol it points at is being kept.
 		// (When BuildModeShared, always keep itablinks.)
+		if ctxt.loader != nil {
+			// Nothing refers to itablinks, so load those
+			// whose itabs have been loaded.
+			for _, name := range ctxt.loader.Unloaded("go.itablink.", 0) {
+				if !ctxt.loader.Defines("go.itab."+name[len("go.itablink."):], 0) {
+					ctxt.Syms.Lookup(name, 0)
+				}
+			}
+		}
 		for _, s := range ctxt.Syms.Allsym {
 			if strings.HasPrefix(s.Name, "go.itablink.") {
 				s.Attr.Set(sym.AttrReachable, len(s.R) == 1 && s.R[0].Sym.Attr.Reachable())
@@ -118,6 +134,12 @@ func deadcode(ctxt *Link) {
 		}
 	}
 
+	if ctxt.loader != nil {
+		// All reachable text symbols have been loaded.
+		ctxt.loader.SetText()
+		ctxt.loadTextp()
+	}
+
 	for _, lib := range ctxt.Library {
 		lib.Textp = lib.Textp[:0]
 	}
@@ -147,6 +169,32 @@ type methodref struct {
 
 func (m methodref) ifn() *sym.Symbol { return m.r[1].Sym }
 
+// name returns the name of the method, without its signature.
+func (m methodref) name() string {
+	s := string(m.m)
+	return s[:strings.Index(s, "(")]
+}
+
+// isReflectMethodLookup reports whether m is the Method or MethodByName
+// method of reflect.Value or *reflect.Value.
+func (m methodref) isReflectMethodLookup() bool {
+	if m.src.Name != "type.reflect.Value" && m.src.Name != "type.*reflect.Value" {
+		return false
+	}
+	name := m.name()
+	return name == "Method" || name == "MethodByName"
+}
+
+// reflectMethodFuncs holds the names of the function value symbols
+// of the methods that look up methods by reflection, which method
+// expressions refer to.
+var reflectMethodFuncs = map[string]bool{
+	"reflect.Value.Method·f":          true,
+	"reflect.Value.MethodByName·f":    true,
+	"reflect.(*Value).Method·f":       true,
+	"reflect.(*Value).MethodByName·f": true,
+}
+
 func (m methodref) isExported() bool {
 	for _, r := range m.m {
 		return unicode.IsUpper(r)
@@ -159,6 +207,7 @@ type deadcodepass struct {
 	ctxt            *Link
 	markQueue       []*sym.Symbol      // symbols to flood fill in next pass
 	ifaceMethod     map[methodsig]bool // methods declared in reached interfaces
+	namedMethod     map[string]bool    // method names passed to MethodByName by reached functions
 	markableMethods []methodref        // methods of reached types
 	reflectMethod   bool
 }
@@ -199,6 +248,11 @@ func (d *deadcodepass) mark(s, parent *sym.Symbol) {
 
 // markMethod marks a method as reachable.
 func (d *deadcodepass) markMethod(m methodref) {
+	if m.isReflectMethodLookup() {
+		// The method may be called through an interface or by
+		// reflection, with any argument.
+		d.reflectMethod = true
+	}
 	for _, r := range m.r {
 		d.mark(r.Sym, m.src)
 		r.Type = objabi.R_ADDROFF
@@ -271,6 +325,11 @@ func (d *deadcodepass) flood() {
 				d.ctxt.Logf("marktext %s\n", s.Name)
 			}
 		}
+		if reflectMethodFuncs[s.Name] {
+			// A method expression of reflect.Value.Method
+			// or MethodByName may be called with any argument.
+			d.reflectMethod = true
+		}
 
 		if strings.HasPrefix(s.Name, "type.") && s.Name[5] != '.' {
 			if len(s.P) == 0 {
@@ -301,6 +360,18 @@ func (d *deadcodepass) flood() {
 				// reachable.
 				continue
 			}
+			if r.Type == objabi.R_USENAMEDMETHOD {
+				// The method name is all that matters;
+				// the string symbol holding it need not
+				// be reachable.
+				if name := string(r.Sym.P); !d.namedMethod[name] {
+					if d.ctxt.Debugvlog > 1 {
+						d.ctxt.Logf("reached named method: %s\n", name)
+					}
+					d.namedMethod[name] = true
+				}
+				continue
+			}
 			if r.Sym.Type == sym.SABIALIAS {
 				// Patch this relocation through the
 				// ABI alias before marking.
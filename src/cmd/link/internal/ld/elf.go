@@ -1026,20 +1026,20 @@ func elfdynhash(ctxt *Link) {
 	buckets := make([]uint32, nbucket)
 
 	for _, sy := range ctxt.Syms.Allsym {
-		if sy.Dynid <= 0 {
+		if sy.Dynid() <= 0 {
 			continue
 		}
 
 		if sy.Dynimpvers() != "" {
-			need[sy.Dynid] = addelflib(&needlib, sy.Dynimplib(), sy.Dynimpvers())
+			need[sy.Dynid()] = addelflib(&needlib, sy.Dynimplib(), sy.Dynimpvers())
 		}
 
 		name := sy.Extname()
 		hc := elfhash(name)
 
 		b := hc % uint32(nbucket)
-		chain[sy.Dynid] = buckets[b]
-		buckets[b] = uint32(sy.Dynid)
+		chain[sy.Dynid()] = buckets[b]
+		buckets[b] = uint32(sy.Dynid())
 	}
 
 	// s390x (ELF64) hash table entries are 8 bytes
@@ -2263,7 +2263,7 @@ elfobj:
 
 func elfadddynsym(ctxt *Link, s *sym.Symbol) {
 	if elf64 {
-		s.Dynid = int32(Nelfsym)
+		s.SetDynid(int32(Nelfsym))
 		Nelfsym++
 
 		d := ctxt.Syms.Lookup(".dynsym", 0)
@@ -2305,7 +2305,7 @@ func elfadddynsym(ctxt *Link, s *sym.Symbol) {
 			Elfwritedynent(ctxt, ctxt.Syms.Lookup(".dynamic", 0), DT_NEEDED, uint64(Addstring(ctxt.Syms.Lookup(".dynstr", 0), s.Dynimplib())))
 		}
 	} else {
-		s.Dynid = int32(Nelfsym)
+		s.SetDynid(int32(Nelfsym))
 		Nelfsym++
 
 		d := ctxt.Syms.Lookup(".dynsym", 0)
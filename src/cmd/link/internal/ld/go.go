@@ -286,7 +286,7 @@ func adddynlib(ctxt *Link, lib string) {
 }
 
 func Adddynsym(ctxt *Link, s *sym.Symbol) {
-	if s.Dynid >= 0 || ctxt.LinkMode == LinkExternal {
+	if s.Dynid() >= 0 || ctxt.LinkMode == LinkExternal {
 		return
 	}
 
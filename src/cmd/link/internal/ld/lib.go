@@ -375,6 +375,13 @@ func (ctxt *Link) findLibPath(libname string) string {
 }
 
 func (ctxt *Link) loadlib() {
+	// Executables need only the symbols they refer to. Shared
+	// libraries and plugins export all of theirs, and field tracking
+	// and -strictdups look at all symbols.
+	if *flagLazyLoad && (ctxt.BuildMode == BuildModeExe || ctxt.BuildMode == BuildModePIE) && !ctxt.linkShared && objabi.Fieldtrack_enabled == 0 && *FlagStrictDups == 0 {
+		ctxt.loader = objfile.NewLoader(ctxt.Syms)
+	}
+
 	switch ctxt.BuildMode {
 	case BuildModeCShared, BuildModePlugin:
 		s := ctxt.Syms.Lookup("runtime.islibrary", 0)
@@ -601,6 +608,11 @@ func (ctxt *Link) loadlib() {
 
 	// Record whether we can use plugins.
 	ctxt.canUsePlugins = (ctxt.Syms.ROLookup("plugin.Open", sym.SymVerABIInternal) != nil)
+	if ctxt.loader != nil && ctxt.canUsePlugins {
+		// Plugins may use any symbol, and
+		// mangleTypeSym renames all type symbols.
+		ctxt.loader.LoadAll()
+	}
 
 	// If there are no dynamic libraries needed, gcc disables dynamic linking.
 	// Because of this, glibc's dynamic ELF loader occasionally (like in version 2.13)
@@ -638,10 +650,31 @@ func (ctxt *Link) loadlib() {
 
 	importcycles()
 
-	// put symbols into Textp
-	// do it in postorder so that packages are laid down in dependency order
-	// internal first, then everything else
 	ctxt.Library = postorder(ctxt.Library)
+	if ctxt.loader == nil {
+		// With a loader, deadcode adds the text symbols
+		// once the reachable ones have been loaded.
+		ctxt.loadTextp()
+	}
+
+	// Resolve ABI aliases in the list of cgo-exported functions.
+	// This is necessary because we load the ABI0 symbol for all
+	// cgo exports.
+	for i, s := range dynexp {
+		if s.Type != sym.SABIALIAS {
+			continue
+		}
+		t := resolveABIAlias(s)
+		t.Attr |= s.Attr
+		t.SetExtname(s.Extname())
+		dynexp[i] = t
+	}
+}
+
+// loadTextp puts the text symbols of the Go libraries into Textp,
+// in postorder so that packages are laid down in dependency order,
+// internal first, then everything else.
+func (ctxt *Link) loadTextp() {
 	for _, doInternal := range [2]bool{true, false} {
 		for _, lib := range ctxt.Library {
 			if isRuntimeDepPkg(lib.Pkg) != doInternal {
@@ -675,19 +708,6 @@ func (ctxt *Link) loadlib() {
 		}
 		ctxt.Textp = textp
 	}
-
-	// Resolve ABI aliases in the list of cgo-exported functions.
-	// This is necessary because we load the ABI0 symbol for all
-	// cgo exports.
-	for i, s := range dynexp {
-		if s.Type != sym.SABIALIAS {
-			continue
-		}
-		t := resolveABIAlias(s)
-		t.Attr |= s.Attr
-		t.SetExtname(s.Extname())
-		dynexp[i] = t
-	}
 }
 
 // mangleTypeSym shortens the names of symbols that represent Go types
@@ -35,6 +35,7 @@ import (
 	"cmd/internal/obj"
 	"cmd/internal/objabi"
 	"cmd/internal/sys"
+	"cmd/link/internal/objfile"
 	"cmd/link/internal/sym"
 	"debug/elf"
 	"fmt"
@@ -61,6 +62,10 @@ type Link struct {
 
 	Loaded bool // set after all inputs have been loaded as symbols
 
+	// If loader is set, the symbols of Go object files
+	// are loaded when they are first looked up.
+	loader *objfile.Loader
+
 	IsELF    bool
 	HeadType objabi.HeadType
 
@@ -830,7 +830,7 @@ func machosymorder(ctxt *Link) {
 	machogenasmsym(ctxt)
 	sort.Sort(machoscmp(sortsym[:nsortsym]))
 	for i := 0; i < nsortsym; i++ {
-		sortsym[i].Dynid = int32(i)
+		sortsym[i].SetDynid(int32(i))
 	}
 }
 
@@ -553,7 +553,7 @@ func (f *peFile) emitRelocations(ctxt *Link) {
 					Errorf(sym, "missing xsym in relocation")
 					continue
 				}
-				if r.Xsym.Dynid < 0 {
+				if r.Xsym.Dynid() < 0 {
 					Errorf(sym, "reloc %d to non-coff symbol %s (outer=%s) %d", r.Type, r.Sym.Name, r.Xsym.Name, r.Sym.Type)
 				}
 				if !thearch.PEreloc1(ctxt.Arch, ctxt.Out, sym, r, int64(uint64(sym.Value+int64(r.Off))-base)) {
@@ -601,7 +601,7 @@ dwarfLoop:
 	f.ctorsSect.emitRelocations(ctxt.Out, func() int {
 		dottext := ctxt.Syms.Lookup(".text", 0)
 		ctxt.Out.Write32(0)
-		ctxt.Out.Write32(uint32(dottext.Dynid))
+		ctxt.Out.Write32(uint32(dottext.Dynid()))
 		switch objabi.GOARCH {
 		default:
 			Errorf(dottext, "unknown architecture for PE: %q\n", objabi.GOARCH)
@@ -631,7 +631,7 @@ func (f *peFile) writeSymbol(out *OutBuf, s *sym.Symbol, value int64, sectidx in
 	out.Write8(class)
 	out.Write8(0) // no aux entries
 
-	s.Dynid = int32(f.symbolCount)
+	s.SetDynid(int32(f.symbolCount))
 
 	f.symbolCount++
 }
@@ -0,0 +1,345 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package objfile
+
+import (
+	"cmd/link/internal/sym"
+	"log"
+	"sort"
+	"strings"
+)
+
+// A Loader loads the symbols defined in Go object files on demand.
+//
+// Object files read by Load with a Loader are only scanned: the
+// Loader records where each symbol is defined, and loads the
+// definition, and those of the symbols it refers to, when the symbol
+// is first looked up. The linker looks up the symbols it starts dead
+// code elimination from, so the symbols of a program that it does not
+// refer to, which are most of the symbols of large programs, are never
+// loaded, and cost neither the memory of their sym.Symbol, relocations
+// and function information nor the time to allocate them.
+//
+// Definitions are loaded with the same rules for duplicate symbols
+// as without a Loader, except that the contents of duplicates are not
+// compared, so a Loader must not be used with the StrictDups flags.
+type Loader struct {
+	syms    *sym.Symbols
+	objs    []*objReader
+	defs    []lazyDef
+	pending []map[string]int32 // indexes of defs not yet loaded, by version and name
+	queue   []int32            // indexes of defs to load
+	loading bool               // the queue is being drained
+
+	// Storage for the slices of the symbols being loaded, allocated
+	// in batches of loaderBatch elements and shared by all objects.
+	reloc       []sym.Reloc
+	pcdata      []sym.Pcdata
+	funcdata    []*sym.Symbol
+	funcdataoff []int64
+	file        []*sym.Symbol
+}
+
+// loaderBatch is the number of elements by which a Loader
+// grows the storage of the slices of the symbols it loads.
+const loaderBatch = 1000
+
+// A lazyDef is a symbol definition in an object file.
+type lazyDef struct {
+	sym     *sym.Symbol // the symbol, once loaded
+	obj     int32       // index of the object in Loader.objs
+	off     uint32      // offset of the definition in the object
+	dataOff uint32      // offset of its data in the data section
+	hasData bool        // the definition has data
+}
+
+// A textDef is a definition of a text symbol: either the loaded
+// symbol, or the index of a definition in Loader.defs.
+type textDef struct {
+	sym   *sym.Symbol
+	def   int32
+	dupok bool
+}
+
+// NewLoader returns a Loader that loads symbols into syms,
+// and makes it the loader of syms.
+func NewLoader(syms *sym.Symbols) *Loader {
+	l := &Loader{
+		syms:    syms,
+		pending: []map[string]int32{make(map[string]int32, 100000)},
+	}
+	syms.Loader = l
+	return l
+}
+
+// Defines reports whether l has a definition, not yet loaded,
+// of the symbol with the given name and version.
+func (l *Loader) Defines(name string, v int) bool {
+	_, ok := l.lookup(name, v)
+	return ok
+}
+
+// lookup returns the index of the definition of the symbol with
+// the given name and version, if it has not been loaded.
+func (l *Loader) lookup(name string, v int) (int32, bool) {
+	if v >= len(l.pending) {
+		return 0, false
+	}
+	i, ok := l.pending[v][name]
+	return i, ok
+}
+
+// Load loads the definition of s, if l has one, and those of the
+// symbols it refers to.
+func (l *Loader) Load(s *sym.Symbol) {
+	v := int(s.Version)
+	i, ok := l.lookup(s.Name, v)
+	if !ok {
+		return
+	}
+	delete(l.pending[v], s.Name)
+	l.defs[i].sym = s
+	l.queue = append(l.queue, i)
+	l.drain()
+}
+
+// drain loads the queued definitions. If l is already loading a
+// definition, which refers to the symbols queued, drain returns, and
+// they are loaded when that definition is.
+func (l *Loader) drain() {
+	if l.loading {
+		return
+	}
+	l.loading = true
+	for len(l.queue) > 0 {
+		i := l.queue[len(l.queue)-1]
+		l.queue = l.queue[:len(l.queue)-1]
+		l.load(i)
+	}
+	l.loading = false
+}
+
+// load loads the definition l.defs[i] into its symbol.
+func (l *Loader) load(i int32) {
+	d := &l.defs[i]
+	r := l.objs[d.obj]
+	off, data := r.roOffset, r.data
+	r.roOffset, r.data = int64(d.off), r.allData[d.dataOff:]
+	l.readSym(r)
+	r.roOffset, r.data = off, data
+}
+
+// readSym reads the symbol definition at the current offset of r,
+// allocating the slices of the symbol from the storage of l.
+func (l *Loader) readSym(r *objReader) {
+	loading := l.loading
+	l.loading = true // the storage is in use until readSym returns
+	r.reloc, r.pcdata, r.funcdata, r.funcdataoff, r.file = l.reloc, l.pcdata, l.funcdata, l.funcdataoff, l.file
+	r.readSym()
+	l.reloc, l.pcdata, l.funcdata, l.funcdataoff, l.file = r.reloc, r.pcdata, r.funcdata, r.funcdataoff, r.file
+	r.reloc, r.pcdata, r.funcdata, r.funcdataoff, r.file = nil, nil, nil, nil, nil
+	l.loading = loading
+	l.drain()
+}
+
+// LoadAll loads all the definitions that have not been loaded,
+// in the order in which they were read.
+func (l *Loader) LoadAll() {
+	type def struct {
+		name string
+		v    int
+		i    int32
+	}
+	var defs []def
+	for v, m := range l.pending {
+		for name, i := range m {
+			defs = append(defs, def{name, v, i})
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].i < defs[j].i })
+	for _, d := range defs {
+		l.syms.Lookup(d.name, d.v)
+	}
+}
+
+// Unloaded returns the names of the symbols with version v and the
+// given name prefix whose definitions have not been loaded, sorted.
+func (l *Loader) Unloaded(prefix string, v int) []string {
+	if v >= len(l.pending) {
+		return nil
+	}
+	var names []string
+	for name := range l.pending[v] {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetText sets the Textp and DupTextSyms of the libraries of the object
+// files to the text symbols that have been loaded, in the order in which
+// they are defined, as Load does for all the symbols without a Loader.
+func (l *Loader) SetText() {
+	for _, r := range l.objs {
+		r.lib.Textp = r.lib.Textp[:0]
+		r.lib.DupTextSyms = r.lib.DupTextSyms[:0]
+	}
+	for _, r := range l.objs {
+		for _, t := range r.text {
+			s := t.sym
+			if s == nil {
+				s = l.defs[t.def].sym
+			}
+			if s == nil {
+				continue
+			}
+			if t.dupok {
+				r.lib.DupTextSyms = append(r.lib.DupTextSyms, s)
+				continue
+			}
+			if s.Attr.OnList() {
+				log.Fatalf("symbol %s listed multiple times", s.Name)
+			}
+			s.Attr |= sym.AttrOnList
+			r.lib.Textp = append(r.lib.Textp, s)
+		}
+	}
+}
+
+// skipRef skips over a symbol reference, recording where it is
+// so that it can be resolved when it is used.
+func (r *objReader) skipRef() {
+	if len(r.refOff) == 0 {
+		r.refOff = append(r.refOff, 0) // zeroth ref is nil
+	}
+	r.refOff = append(r.refOff, uint32(r.roOffset))
+	r.refs = append(r.refs, nil)
+	r.readRefName()
+}
+
+// refName returns the name and version of the symbol
+// that the i'th reference refers to.
+func (r *objReader) refName(i int) (string, int) {
+	off := r.roOffset
+	r.roOffset = int64(r.refOff[i])
+	name, v := r.readRefName()
+	r.roOffset = off
+	return name, v
+}
+
+// scanSym reads the header of a symbol definition and, unless the
+// symbol has been loaded, records the definition for r.loader and skips
+// over it. It applies the definitions of loaded symbols as readSym does.
+func (r *objReader) scanSym() {
+	off := r.roOffset
+	dataOff := len(r.allData) - len(r.data)
+	if c, err := r.readByte(); c != symPrefix || err != nil {
+		log.Fatalln("readSym out of sync")
+	}
+	c, err := r.readByte()
+	if err != nil {
+		log.Fatalln("error reading input: ", err)
+	}
+	t := sym.AbiSymKindToSymKind[c]
+	name, v := r.refName(r.readInt())
+	flags := r.readInt()
+	dupok := flags&1 != 0
+	r.readInt() // size
+	typ := r.readInt()
+	ndata := len(r.readData())
+	nreloc := r.readInt()
+
+	// A definition can be skipped if readSym would read it
+	// into r.dupSym and discard it.
+	empty := (t == sym.SDATA || t == sym.SBSS || t == sym.SNOPTRBSS) && ndata == 0 && nreloc == 0
+	dupOf := func(hasData bool) bool {
+		return hasData && dupok && typ == 0 && !empty
+	}
+
+	l := r.loader
+	var s *sym.Symbol
+	if i, ok := l.lookup(name, v); ok {
+		if dupOf(l.defs[i].hasData) {
+			r.skipSym(t, nreloc)
+			if t == sym.STEXT {
+				r.text = append(r.text, textDef{def: i, dupok: true})
+			}
+			return
+		}
+		// Load the symbol to apply this definition to it.
+		s = l.syms.Lookup(name, v)
+	} else {
+		s = l.syms.ROLookup(name, v)
+	}
+
+	if s == nil {
+		i := int32(len(l.defs))
+		l.defs = append(l.defs, lazyDef{
+			obj:     r.obj,
+			off:     uint32(off),
+			dataOff: uint32(dataOff),
+			hasData: ndata > 0,
+		})
+		for v >= len(l.pending) {
+			l.pending = append(l.pending, nil)
+		}
+		if l.pending[v] == nil {
+			l.pending[v] = make(map[string]int32)
+		}
+		l.pending[v][name] = i
+		r.skipSym(t, nreloc)
+		if t == sym.STEXT {
+			r.text = append(r.text, textDef{def: i, dupok: dupok})
+		}
+		return
+	}
+
+	if s.Type != 0 && s.Type != sym.SXREF && dupOf(len(s.P) > 0) {
+		r.skipSym(t, nreloc)
+	} else {
+		r.roOffset, r.data = off, r.allData[dataOff:]
+		l.readSym(r)
+	}
+	if t == sym.STEXT {
+		r.text = append(r.text, textDef{sym: s, dupok: dupok})
+	}
+}
+
+// skipSym skips over the rest of a symbol definition,
+// whose header scanSym has read. It must match readSym.
+func (r *objReader) skipSym(t sym.SymKind, nreloc int) {
+	r.skipInts(5 * nreloc)
+	if t != sym.STEXT {
+		return
+	}
+	r.skipInts(5) // args, locals, nosplit, flags, autom count
+	for i := 0; i < 4; i++ {
+		r.readData() // pcsp, pcfile, pcline, pcinline
+	}
+	n := r.readInt()
+	for i := 0; i < n; i++ {
+		r.readData()
+	}
+	n = r.readInt()
+	r.skipInts(2 * n) // funcdata, funcdataoff
+	n = r.readInt()
+	r.skipInts(n) // file
+	n = r.readInt()
+	r.skipInts(5 * n) // inlining tree
+}
+
+// skipInts skips over n varint-encoded integers.
+func (r *objReader) skipInts(n int) {
+	b, off := r.roObject, r.roOffset
+	for n > 0 {
+		if b[off]&0x80 == 0 {
+			n--
+		}
+		off++
+	}
+	r.roOffset = off
+}
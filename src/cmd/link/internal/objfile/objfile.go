@@ -62,8 +62,17 @@ type objReader struct {
 
 	roObject []byte // from read-only mmap of object file (may be nil)
 	roOffset int64  // offset into readonly object data examined so far
+	roCopy   bool   // roObject was read into memory rather than mapped
 
 	dataReadOnly bool // whether data is backed by read-only memory
+
+	// If loader is set, the symbols are defined on demand, and the
+	// references are resolved as they are used. See Loader.
+	loader  *Loader
+	obj     int32     // index of the reader in loader.objs
+	refOff  []uint32  // offsets of the references in roObject
+	allData []byte    // the data section
+	text    []textDef // the definitions of text symbols, in order
 }
 
 // Flags to enable optional behavior during object loading/reading.
@@ -81,9 +90,22 @@ const (
 
 // Load loads an object file f into library lib.
 // The symbols loaded are added to syms.
+// If syms.Loader is a Loader, the symbols are only recorded, and
+// they are loaded when they are first looked up.
 func Load(arch *sys.Arch, syms *sym.Symbols, f *bio.Reader, lib *sym.Library, unit *sym.CompilationUnit, length int64, pn string, flags int) int {
 	start := f.Offset()
+	l, _ := syms.Loader.(*Loader)
 	roObject := f.SliceRO(uint64(length))
+	roCopy := false
+	if roObject == nil && l != nil {
+		// The loader reads the object out of order, so it needs
+		// all of it. Small objects are not mapped; read them.
+		roObject = make([]byte, length)
+		if _, err := io.ReadFull(f, roObject); err != nil {
+			log.Fatalf("%s: error reading %s", pn, err)
+		}
+		roCopy = true
+	}
 	if roObject != nil {
 		f.MustSeek(int64(-length), os.SEEK_CUR)
 	}
@@ -98,8 +120,14 @@ func Load(arch *sys.Arch, syms *sym.Symbols, f *bio.Reader, lib *sym.Library, un
 		localSymVersion: syms.IncVersion(),
 		flags:           flags,
 		roObject:        roObject,
+		roCopy:          roCopy,
 		pkgpref:         objabi.PathToPrefix(lib.Pkg) + ".",
 	}
+	if l != nil {
+		r.loader = l
+		r.obj = int32(len(l.objs))
+		l.objs = append(l.objs, r)
+	}
 	r.loadObjFile()
 	if roObject != nil {
 		if r.roOffset != length {
@@ -154,7 +182,11 @@ func (r *objReader) loadObjFile() {
 			r.readByte()
 			break
 		}
-		r.readRef()
+		if r.loader != nil {
+			r.skipRef()
+		} else {
+			r.readRef()
+		}
 	}
 
 	// Lengths
@@ -175,7 +207,11 @@ func (r *objReader) loadObjFile() {
 		if c[0] == 0xff {
 			break
 		}
-		r.readSym()
+		if r.loader != nil {
+			r.scanSym()
+		} else {
+			r.readSym()
+		}
 	}
 
 	// Magic footer
@@ -188,6 +224,13 @@ func (r *objReader) loadObjFile() {
 
 func (r *objReader) readSlices() {
 	r.dataSize = r.readInt()
+	if r.loader != nil {
+		// The slices of each symbol are allocated when it is loaded.
+		for i := 0; i < 5; i++ {
+			r.readInt()
+		}
+		return
+	}
 	n := r.readInt()
 	r.reloc = make([]sym.Reloc, n)
 	n = r.readInt()
@@ -203,8 +246,9 @@ func (r *objReader) readSlices() {
 func (r *objReader) readDataSection() (err error) {
 	if r.roObject != nil {
 		r.data, r.dataReadOnly, err =
-			r.roObject[r.roOffset:r.roOffset+int64(r.dataSize)], true, nil
+			r.roObject[r.roOffset:r.roOffset+int64(r.dataSize)], !r.roCopy, nil
 		r.roOffset += int64(r.dataSize)
+		r.allData = r.data
 		return
 	}
 	r.data, r.dataReadOnly, err = r.rd.Slice(uint64(r.dataSize))
@@ -290,6 +334,9 @@ overwrite:
 	s.P = data
 	s.Attr.Set(sym.AttrReadOnly, r.dataReadOnly)
 	if nreloc > 0 {
+		if r.loader != nil && len(r.reloc) < nreloc {
+			r.reloc = make([]sym.Reloc, nreloc+loaderBatch)
+		}
 		s.R = r.reloc[:nreloc:nreloc]
 		if !isdup {
 			r.reloc = r.reloc[nreloc:]
@@ -335,6 +382,9 @@ overwrite:
 		pc.Pcline.P = r.readData()
 		pc.Pcinline.P = r.readData()
 		n = r.readInt()
+		if r.loader != nil && len(r.pcdata) < n {
+			r.pcdata = make([]sym.Pcdata, n+loaderBatch)
+		}
 		pc.Pcdata = r.pcdata[:n:n]
 		if !isdup {
 			r.pcdata = r.pcdata[n:]
@@ -343,6 +393,10 @@ overwrite:
 			pc.Pcdata[i].P = r.readData()
 		}
 		n = r.readInt()
+		if r.loader != nil && len(r.funcdata) < n {
+			r.funcdata = make([]*sym.Symbol, n+loaderBatch)
+			r.funcdataoff = make([]int64, n+loaderBatch)
+		}
 		pc.Funcdata = r.funcdata[:n:n]
 		pc.Funcdataoff = r.funcdataoff[:n:n]
 		if !isdup {
@@ -356,6 +410,9 @@ overwrite:
 			pc.Funcdataoff[i] = r.readInt64()
 		}
 		n = r.readInt()
+		if r.loader != nil && len(r.file) < n {
+			r.file = make([]*sym.Symbol, n+loaderBatch)
+		}
 		pc.File = r.file[:n:n]
 		if !isdup {
 			r.file = r.file[n:]
@@ -373,7 +430,10 @@ overwrite:
 			pc.InlTree[i].ParentPC = r.readInt32()
 		}
 
-		if !dupok {
+		if r.loader != nil {
+			// The loader lists text symbols as it reads their
+			// definitions.
+		} else if !dupok {
 			if s.Attr.OnList() {
 				log.Fatalf("symbol %s listed multiple times", s.Name)
 			}
@@ -478,6 +538,12 @@ func (r *objReader) peek(n int) ([]byte, error) {
 }
 
 func (r *objReader) readRef() {
+	r.refs = append(r.refs, r.lookupRef(r.readRefName()))
+}
+
+// readRefName reads a symbol reference and returns the name and
+// version of the symbol.
+func (r *objReader) readRefName() (string, int) {
 	if c, err := r.readByte(); c != symPrefix || err != nil {
 		log.Fatalf("readSym out of sync")
 	}
@@ -492,11 +558,14 @@ func (r *objReader) readRef() {
 	} else {
 		log.Fatalf("invalid symbol ABI for %q: %d", name, abi)
 	}
-	s := r.syms.Lookup(name, v)
-	r.refs = append(r.refs, s)
+	return name, v
+}
 
+// lookupRef looks up the symbol a reference refers to.
+func (r *objReader) lookupRef(name string, v int) *sym.Symbol {
+	s := r.syms.Lookup(name, v)
 	if s == nil || v == r.localSymVersion {
-		return
+		return s
 	}
 	if s.Name[0] == '$' && len(s.Name) > 5 && s.Type == 0 && len(s.P) == 0 {
 		x, err := strconv.ParseUint(s.Name[5:], 16, 64)
@@ -521,6 +590,7 @@ func (r *objReader) readRef() {
 	if strings.HasPrefix(s.Name, "runtime.gcbits.") {
 		s.Attr |= sym.AttrLocal
 	}
+	return s
 }
 
 func (r *objReader) readInt64() int64 {
@@ -655,5 +725,8 @@ func (r *objReader) readSymName() string {
 // Reads the index of a symbol reference and resolves it to a symbol
 func (r *objReader) readSymIndex() *sym.Symbol {
 	i := r.readInt()
+	if r.refs[i] == nil && i != 0 && r.loader != nil {
+		r.refs[i] = r.lookupRef(r.refName(i))
+	}
 	return r.refs[i]
 }
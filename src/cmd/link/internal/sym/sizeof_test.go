@@ -23,7 +23,7 @@ func TestSizeof(t *testing.T) {
 		_32bit uintptr     // size on 32bit platforms
 		_64bit uintptr     // size on 64bit platforms
 	}{
-		{Symbol{}, 108, 176},
+		{Symbol{}, 100, 168},
 	}
 
 	for _, tt := range tests {
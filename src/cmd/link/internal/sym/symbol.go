@@ -15,24 +15,22 @@ import (
 
 // Symbol is an entry in the symbol table.
 type Symbol struct {
-	Name        string
-	Type        SymKind
-	Version     int16
-	Attr        Attribute
-	Dynid       int32
-	Align       int32
-	Elfsym      int32
-	LocalElfsym int32
-	Value       int64
-	Size        int64
-	Sub         *Symbol
-	Outer       *Symbol
-	Gotype      *Symbol
-	File        string // actually package!
-	auxinfo     *AuxSymbol
-	Sect        *Section
-	FuncInfo    *FuncInfo
-	Unit        *CompilationUnit
+	Name     string
+	Type     SymKind
+	Version  int16
+	Attr     Attribute
+	Align    int32
+	Elfsym   int32
+	Value    int64
+	Size     int64
+	Sub      *Symbol
+	Outer    *Symbol
+	Gotype   *Symbol
+	File     string // actually package!
+	auxinfo  *AuxSymbol
+	Sect     *Section
+	FuncInfo *FuncInfo
+	Unit     *CompilationUnit
 	// P contains the raw symbol data.
 	P []byte
 	R []Reloc
@@ -46,6 +44,12 @@ type AuxSymbol struct {
 	localentry uint8
 	plt        int32
 	got        int32
+	// dynid is the index of the symbol in the dynamic symbol table
+	// or, for PE, XCOFF and Mach-O, in the output symbol table.
+	dynid int32
+	// localElfsym is the index of the local copy of the symbol that
+	// putelfsym creates for some symbols when linking externally.
+	localElfsym int32
 	// ElfType is set for symbols read from shared libraries by ldshlibsyms. It
 	// is not set for symbols defined by the packages being linked or by symbols
 	// read by ldelf (and so is left as elf.STT_NOTYPE).
@@ -92,8 +96,8 @@ func (s *Symbol) IsFileLocal() bool {
 func (s *Symbol) ElfsymForReloc() int32 {
 	// If putelfsym created a local version of this symbol, use that in all
 	// relocations.
-	if s.LocalElfsym != 0 {
-		return s.LocalElfsym
+	if s.auxinfo != nil && s.auxinfo.localElfsym != 0 {
+		return s.auxinfo.localElfsym
 	} else {
 		return s.Elfsym
 	}
@@ -317,7 +321,7 @@ func (s *Symbol) setUintXX(arch *sys.Arch, off int64, v uint64, wid int64) int64
 
 func (s *Symbol) makeAuxInfo() {
 	if s.auxinfo == nil {
-		s.auxinfo = &AuxSymbol{extname: s.Name, plt: -1, got: -1}
+		s.auxinfo = &AuxSymbol{extname: s.Name, plt: -1, got: -1, dynid: -1}
 	}
 }
 
@@ -390,6 +394,36 @@ func (s *Symbol) SetLocalentry(val uint8) {
 	s.auxinfo.localentry = val
 }
 
+// Dynid returns the index of s in the dynamic symbol table
+// or, for PE, XCOFF and Mach-O, in the output symbol table,
+// or -1 if s has not been assigned one.
+func (s *Symbol) Dynid() int32 {
+	if s.auxinfo == nil {
+		return -1
+	}
+	return s.auxinfo.dynid
+}
+
+func (s *Symbol) SetDynid(val int32) {
+	if s.auxinfo == nil {
+		if val == -1 {
+			return
+		}
+		s.makeAuxInfo()
+	}
+	s.auxinfo.dynid = val
+}
+
+func (s *Symbol) SetLocalElfsym(val int32) {
+	if s.auxinfo == nil {
+		if val == 0 {
+			return
+		}
+		s.makeAuxInfo()
+	}
+	s.auxinfo.localElfsym = val
+}
+
 func (s *Symbol) Plt() int32 {
 	if s.auxinfo == nil {
 		return -1
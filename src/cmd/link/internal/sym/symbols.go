@@ -36,9 +36,25 @@ type Symbols struct {
 	// Symbol lookup based on name and indexed by version.
 	hash []map[string]*Symbol
 
+	// If Loader is set, symbols are defined when they are first
+	// looked up, rather than when their object files are read.
+	Loader Loader
+
 	Allsym []*Symbol
 }
 
+// A Loader defines symbols on demand, from object files it has read
+// but whose definitions it has not loaded.
+type Loader interface {
+	// Defines reports whether the loader has a definition for
+	// the symbol with the given name and version.
+	Defines(name string, v int) bool
+
+	// Load loads the definition of s, if the loader has one.
+	// Symbols that s refers to are loaded too.
+	Load(s *Symbol)
+}
+
 func NewSymbols() *Symbols {
 	hash := make([]map[string]*Symbol, SymVerStatic)
 	// Preallocate about 2mb for hash of non static symbols
@@ -59,7 +75,6 @@ func (syms *Symbols) Newsym(name string, v int) *Symbol {
 	s := &batch[0]
 	syms.symbolBatch = batch[1:]
 
-	s.Dynid = -1
 	s.Name = name
 	s.Version = int16(v)
 	syms.Allsym = append(syms.Allsym, s)
@@ -77,13 +92,20 @@ func (syms *Symbols) Lookup(name string, v int) *Symbol {
 	}
 	s = syms.Newsym(name, v)
 	m[name] = s
+	if syms.Loader != nil {
+		syms.Loader.Load(s)
+	}
 	return s
 }
 
 // Look up the symbol with the given name and version, returning nil
 // if it is not found.
 func (syms *Symbols) ROLookup(name string, v int) *Symbol {
-	return syms.hash[v][name]
+	s := syms.hash[v][name]
+	if s == nil && syms.Loader != nil && syms.Loader.Defines(name, v) {
+		s = syms.Lookup(name, v)
+	}
+	return s
 }
 
 // Allocate a new version (i.e. symbol namespace).
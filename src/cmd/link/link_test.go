@@ -2,6 +2,7 @@ package main
 
 import (
 	"debug/macho"
+	"fmt"
 	"internal/testenv"
 	"io/ioutil"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -315,3 +317,69 @@ func TestMacOSVersion(t *testing.T) {
 		t.Errorf("no LC_VERSION_MIN_MACOSX load command found")
 	}
 }
+
+const testLazyLoadSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+type T struct{ s string }
+
+func (t T) String() string { return strings.ToUpper(t.s) }
+func (t T) Unused() string { return t.s }
+
+func main() {
+	var x fmt.Stringer = T{"hello"}
+	fmt.Fprintln(os.Stderr, x, reflect.TypeOf(x).NumMethod())
+}
+`
+
+// TestLazyLoad checks that the linker produces the same symbols
+// whether it loads the symbols of the program on demand or not.
+func TestLazyLoad(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+
+	tmpdir, err := ioutil.TempDir("", "TestLazyLoad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	src := filepath.Join(tmpdir, "main.go")
+	err = ioutil.WriteFile(src, []byte(testLazyLoadSrc), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nm returns the symbols of the program linked with the
+	// given -lazyload flag, sorted.
+	nm := func(lazy bool) []string {
+		exe := filepath.Join(tmpdir, fmt.Sprintf("main-%v", lazy))
+		cmd := exec.Command(testenv.GoToolPath(t), "build", fmt.Sprintf("-ldflags=-lazyload=%v", lazy), "-o", exe, src)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v:\n%s", cmd.Args, err, out)
+		}
+		cmd = exec.Command(testenv.GoToolPath(t), "tool", "nm", exe)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %v:\n%s", cmd.Args, err, out)
+		}
+		syms := strings.Split(strings.TrimSpace(string(out)), "\n")
+		sort.Strings(syms)
+		return syms
+	}
+	lazy, eager := nm(true), nm(false)
+	if len(lazy) != len(eager) {
+		t.Errorf("got %d symbols with -lazyload=true, %d with -lazyload=false", len(lazy), len(eager))
+	}
+	for i := 0; i < len(lazy) && i < len(eager); i++ {
+		if lazy[i] != eager[i] {
+			t.Fatalf("got symbol %q with -lazyload=true, %q with -lazyload=false", lazy[i], eager[i])
+		}
+	}
+}
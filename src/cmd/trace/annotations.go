@@ -245,9 +245,11 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 	// Emit table.
 	err = templUserTaskType.Execute(w, struct {
 		Name  string
+		Query template.URL
 		Entry []entry
 	}{
 		Name:  filter.name,
+		Query: template.URL(r.URL.RawQuery),
 		Entry: data,
 	})
 	if err != nil {
@@ -694,6 +696,12 @@ func newTaskFilter(r *http.Request) (*taskFilter, error) {
 	var conditions []func(*taskDesc) bool
 
 	param := r.Form
+	if id, err := strconv.ParseUint(r.FormValue("taskid"), 10, 64); err == nil {
+		name = append(name, fmt.Sprintf("task %d", id))
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return t.id == id
+		})
+	}
 	if typ, ok := param["type"]; ok && len(typ) > 0 {
 		name = append(name, "type="+typ[0])
 		conditions = append(conditions, func(t *taskDesc) bool {
@@ -990,12 +998,14 @@ Search log text: <form action="/usertask"><input name="logtext" type="text"><inp
 <th>Task type</th>
 <th>Count</th>
 <th>Duration distribution (complete tasks)</th>
+<th>Critical path</th>
 </tr>
 {{range $}}
   <tr>
     <td>{{.Type}}</td>
     <td><a href="/usertask?type={{.Type}}">{{.Count}}</a></td>
     <td>{{.Histogram.ToHTML (.UserTaskURL true)}}</td>
+    <td><a href="/criticalpath?type={{.Type}}">analysis</a></td>
   </tr>
 {{end}}
 </table>
@@ -1051,6 +1061,13 @@ var templUserTaskType = template.Must(template.New("userTask").Funcs(template.Fu
 
 <h2>User Task: {{.Name}}</h2>
 
+Profiles of the tasks' regions:
+<a href="/taskcpu?{{.Query}}">CPU</a>
+<a href="/taskio?{{.Query}}">Network</a>
+<a href="/taskblock?{{.Query}}">Synchronization</a>
+<a href="/tasksyscall?{{.Query}}">Syscall</a>
+<a href="/tasksched?{{.Query}}">Scheduler latency</a><br><br>
+
 Search log text: <form onsubmit="window.location.search+='&logtext='+window.logtextinput.value; return false">
 <input name="logtext" id="logtextinput" type="text"><input type="submit">
 </form><br>
@@ -1062,7 +1079,7 @@ Search log text: <form onsubmit="window.location.search+='&logtext='+window.logt
                 <td class="when">{{$el.WhenString}}</td>
                 <td class="elapsed">{{$el.Duration}}</td>
 		<td></td>
-                <td><a href="/trace?taskid={{$el.ID}}#{{asMillisecond $el.Start}}:{{asMillisecond $el.End}}">Task {{$el.ID}}</a> ({{if .Complete}}complete{{else}}incomplete{{end}})</td>
+                <td><a href="/trace?taskid={{$el.ID}}#{{asMillisecond $el.Start}}:{{asMillisecond $el.End}}">Task {{$el.ID}}</a> ({{if .Complete}}complete, <a href="/criticalpath?taskid={{$el.ID}}">critical path</a>{{else}}incomplete{{end}})</td>
         </tr>
         {{range $el.Events}}
         <tr>
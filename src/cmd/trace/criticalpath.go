@@ -0,0 +1,417 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Critical path analysis of user-defined tasks.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"internal/trace"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/criticalpath", httpCriticalPath)
+}
+
+// pathCategory classifies the time on the critical path of a task.
+type pathCategory int
+
+const (
+	pathExec     pathCategory = iota // running
+	pathSched                        // runnable, waiting to be scheduled
+	pathSyscall                      // blocked in a syscall
+	pathNetwork                      // blocked on network
+	pathGCAssist                     // assisting GC or blocked on GC assist
+	pathLock                         // blocked on sync.Mutex or sync.RWMutex
+	pathSleep                        // sleeping or waiting for a timer
+	pathBlocked                      // blocked and woken up outside of any goroutine
+	pathUnknown                      // the goroutine is not in the trace
+	numPathCategories
+)
+
+var pathCategoryNames = [numPathCategories]string{
+	pathExec:     "Execution",
+	pathSched:    "Scheduler wait",
+	pathSyscall:  "Syscall",
+	pathNetwork:  "Network wait",
+	pathGCAssist: "GC assist",
+	pathLock:     "Lock contention",
+	pathSleep:    "Sleep",
+	pathBlocked:  "Other wait",
+	pathUnknown:  "Unknown",
+}
+
+func (c pathCategory) String() string {
+	return pathCategoryNames[c]
+}
+
+// gSegment is a time interval during which a goroutine was in one state.
+type gSegment struct {
+	start, end int64
+	category   pathCategory
+	// wakeup is the event of another goroutine that ended
+	// a blocked segment by unblocking this goroutine.
+	// It is nil if the goroutine was not unblocked by a goroutine,
+	// for example by a timer or the network poller.
+	wakeup *trace.Event
+}
+
+// gTimeline is the sequence of states of a goroutine.
+type gTimeline struct {
+	segs    []gSegment
+	created *trace.Event // creation event, if in the trace
+
+	// State of the current segment during analyzeTimelines.
+	open   bool
+	start  int64
+	cat    pathCategory
+	assist bool // in GC mark assist
+}
+
+var (
+	timelinesInit sync.Once
+	timelines     map[uint64]*gTimeline
+)
+
+// analyzeTimelines builds the timelines of all goroutines and stores them in timelines.
+func analyzeTimelines(events []*trace.Event) {
+	timelinesInit.Do(func() {
+		timelines = buildTimelines(events)
+	})
+}
+
+// buildTimelines returns the timelines of the goroutines in events,
+// keyed by goroutine id.
+func buildTimelines(events []*trace.Event) map[uint64]*gTimeline {
+	tls := make(map[uint64]*gTimeline)
+	get := func(g uint64) *gTimeline {
+		tl := tls[g]
+		if tl == nil {
+			tl = &gTimeline{}
+			tls[g] = tl
+		}
+		return tl
+	}
+	// transition ends the current segment of g at ts, and starts
+	// a new segment in category c unless the goroutine ended.
+	transition := func(g uint64, ts int64, c pathCategory, end bool, wakeup *trace.Event) {
+		tl := get(g)
+		if tl.open {
+			tl.segs = append(tl.segs, gSegment{start: tl.start, end: ts, category: tl.cat, wakeup: wakeup})
+		}
+		tl.open, tl.start, tl.cat = !end, ts, c
+	}
+	running := func(g uint64) pathCategory {
+		if get(g).assist {
+			return pathGCAssist
+		}
+		return pathExec
+	}
+
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvGoCreate:
+			get(ev.Args[0]).created = ev
+			transition(ev.Args[0], ev.Ts, pathSched, false, nil)
+		case trace.EvGoWaiting:
+			transition(ev.G, ev.Ts, pathBlocked, false, nil)
+		case trace.EvGoInSyscall:
+			transition(ev.G, ev.Ts, pathSyscall, false, nil)
+		case trace.EvGoStart, trace.EvGoStartLabel:
+			transition(ev.G, ev.Ts, running(ev.G), false, nil)
+		case trace.EvGCMarkAssistStart:
+			get(ev.G).assist = true
+			transition(ev.G, ev.Ts, pathGCAssist, false, nil)
+		case trace.EvGCMarkAssistDone:
+			get(ev.G).assist = false
+			transition(ev.G, ev.Ts, pathExec, false, nil)
+		case trace.EvGoEnd, trace.EvGoStop:
+			transition(ev.G, ev.Ts, 0, true, nil)
+		case trace.EvGoSched, trace.EvGoPreempt:
+			transition(ev.G, ev.Ts, pathSched, false, nil)
+		case trace.EvGoSleep:
+			transition(ev.G, ev.Ts, pathSleep, false, nil)
+		case trace.EvGoBlockNet:
+			transition(ev.G, ev.Ts, pathNetwork, false, nil)
+		case trace.EvGoBlockSync:
+			transition(ev.G, ev.Ts, pathLock, false, nil)
+		case trace.EvGoBlockGC:
+			transition(ev.G, ev.Ts, pathGCAssist, false, nil)
+		case trace.EvGoSysBlock:
+			transition(ev.G, ev.Ts, pathSyscall, false, nil)
+		case trace.EvGoBlock, trace.EvGoBlockSend, trace.EvGoBlockRecv,
+			trace.EvGoBlockSelect, trace.EvGoBlockCond:
+			transition(ev.G, ev.Ts, pathBlocked, false, nil)
+		case trace.EvGoUnblock:
+			g := ev.Args[0]
+			var wakeup *trace.Event
+			if tl := get(g); tl.open && tl.cat == pathBlocked {
+				switch {
+				case ev.P == trace.TimerP:
+					tl.cat = pathSleep
+				case ev.P != trace.NetpollP && ev.G != 0:
+					wakeup = ev
+				}
+			}
+			transition(g, ev.Ts, pathSched, false, wakeup)
+		case trace.EvGoSysExit:
+			transition(ev.G, ev.Ts, pathSched, false, nil)
+		}
+	}
+	for _, tl := range tls {
+		if tl.open {
+			tl.segs = append(tl.segs, gSegment{start: tl.start, end: lastTimestamp(), category: tl.cat})
+		}
+	}
+	return tls
+}
+
+// pathStep is a part of the critical path of a task spent
+// by a goroutine in one category.
+type pathStep struct {
+	G          uint64
+	Start, End int64
+	Category   pathCategory
+}
+
+func (s pathStep) Duration() time.Duration {
+	return time.Duration(s.End-s.Start) * time.Nanosecond
+}
+
+// criticalPath returns the critical path of a complete task in time order.
+// The critical path starts at the end of the task and walks back in time
+// to the creation of the task. When it reaches a goroutine that was blocked
+// until another goroutine unblocked it, or the creation of a goroutine,
+// it continues in the goroutine that unblocked or created it.
+func criticalPath(task *taskDesc, tls map[uint64]*gTimeline) []pathStep {
+	var steps []pathStep // in reverse time order
+	add := func(g uint64, start, end int64, c pathCategory) {
+		if start >= end {
+			return
+		}
+		if n := len(steps); n > 0 && steps[n-1].G == g && steps[n-1].Category == c && steps[n-1].Start == end {
+			steps[n-1].Start = start
+			return
+		}
+		steps = append(steps, pathStep{G: g, Start: start, End: end, Category: c})
+	}
+
+	begin := task.create.Ts
+	g, t := task.end.G, task.end.Ts
+	// Every iteration moves back in time, or moves to another goroutine
+	// in a segment that starts earlier, so the walk terminates.
+	// The limit only guards against inconsistent traces.
+	for n := 0; t > begin && n < 1e6; n++ {
+		tl := tls[g]
+		var seg *gSegment
+		if tl != nil {
+			// Find the last segment that starts before t.
+			i := sort.Search(len(tl.segs), func(i int) bool { return tl.segs[i].start >= t })
+			if i > 0 {
+				seg = &tl.segs[i-1]
+			}
+		}
+		if seg == nil {
+			// The critical path reached the creation of g.
+			if tl != nil && tl.created != nil && tl.created.Ts > begin {
+				g, t = tl.created.G, tl.created.Ts
+				continue
+			}
+			break
+		}
+		if seg.end < t {
+			add(g, seg.end, t, pathUnknown)
+			t = seg.end
+			continue
+		}
+		start := seg.start
+		if start < begin {
+			start = begin
+		}
+		if seg.wakeup != nil && seg.wakeup.G != g {
+			g = seg.wakeup.G
+			continue
+		}
+		add(g, start, t, seg.category)
+		t = start
+	}
+	if t > begin {
+		add(g, begin, t, pathUnknown)
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+// pathSummary is the time spent in each category on a critical path.
+type pathSummary [numPathCategories]time.Duration
+
+func (s *pathSummary) add(steps []pathStep) {
+	for _, step := range steps {
+		s[step.Category] += step.Duration()
+	}
+}
+
+func (s *pathSummary) total() (total time.Duration) {
+	for _, d := range s {
+		total += d
+	}
+	return total
+}
+
+// httpCriticalPath serves the critical path analysis of the complete
+// tasks matching the task filter.
+func httpCriticalPath(w http.ResponseWriter, r *http.Request) {
+	filter, err := newTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := analyzeAnnotations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events, err := parseEvents()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	analyzeTimelines(events)
+
+	type category struct {
+		Name    string
+		Total   time.Duration
+		Mean    time.Duration
+		Percent float64
+	}
+	type step struct {
+		pathStep
+		When string
+	}
+	type entry struct {
+		ID       uint64
+		Duration time.Duration
+		Times    pathSummary
+		Steps    []step
+	}
+
+	base := firstTimestamp()
+	var data []entry
+	var summary pathSummary
+	for _, task := range res.tasks {
+		if !filter.match(task) || !task.complete() {
+			continue
+		}
+		steps := criticalPath(task, timelines)
+		e := entry{ID: task.id, Duration: task.duration()}
+		e.Times.add(steps)
+		summary.add(steps)
+		for _, s := range steps {
+			e.Steps = append(e.Steps, step{
+				pathStep: s,
+				When:     fmt.Sprintf("%2.9f", time.Duration(s.Start-base).Seconds()),
+			})
+		}
+		data = append(data, e)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Duration > data[j].Duration
+	})
+
+	var categories []category
+	total := summary.total()
+	for c, d := range summary {
+		cat := category{Name: pathCategory(c).String(), Total: d}
+		if len(data) > 0 {
+			cat.Mean = d / time.Duration(len(data))
+		}
+		if total > 0 {
+			cat.Percent = 100 * float64(d) / float64(total)
+		}
+		categories = append(categories, cat)
+	}
+
+	err = templCriticalPath.Execute(w, struct {
+		Name       string
+		Categories []category
+		Entry      []entry
+		ShowSteps  bool
+	}{
+		Name:       filter.name,
+		Categories: categories,
+		Entry:      data,
+		ShowSteps:  len(data) == 1,
+	})
+	if err != nil {
+		log.Printf("failed to execute template: %v", err)
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+var templCriticalPath = template.Must(template.New("").Parse(`
+<html>
+<head>
+<title>Critical path: {{.Name}}</title>
+<style type="text/css">
+table td {
+	font-family: monospace;
+	text-align: right;
+	padding: 0 0.5em;
+}
+table td.name {
+	text-align: left;
+}
+</style>
+</head>
+<body>
+<h2>Critical path: {{.Name}}</h2>
+
+<p>
+The critical path of a task walks back from the end of the task to its
+creation. Where a goroutine on the path was blocked until another
+goroutine unblocked it, or was created, the path continues in the
+goroutine that unblocked or created it. Only complete tasks are analyzed.
+</p>
+
+<h3>Summary of {{len .Entry}} tasks</h3>
+<table border="1">
+<tr><th>Category</th><th>Total</th><th>Mean per task</th><th>%</th></tr>
+{{range .Categories}}
+<tr><td class="name">{{.Name}}</td><td>{{.Total}}</td><td>{{.Mean}}</td><td>{{printf "%.1f" .Percent}}</td></tr>
+{{end}}
+</table>
+
+<h3>Tasks</h3>
+<table border="1">
+<tr><th>Task</th><th>Duration</th>{{range .Categories}}<th>{{.Name}}</th>{{end}}</tr>
+{{range .Entry}}
+<tr><td class="name"><a href="/criticalpath?taskid={{.ID}}">Task {{.ID}}</a></td><td>{{.Duration}}</td>{{range .Times}}<td>{{.}}</td>{{end}}</tr>
+{{end}}
+</table>
+
+{{if .ShowSteps}}
+{{range .Entry}}
+<h3>Critical path of task {{.ID}}</h3>
+<table border="1">
+<tr><th>When</th><th>Duration</th><th>Goroutine</th><th>Category</th></tr>
+{{range .Steps}}
+<tr><td>{{.When}}</td><td>{{.Duration}}</td><td><a href="/trace?goid={{.G}}">{{.G}}</a></td><td class="name">{{.Category}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
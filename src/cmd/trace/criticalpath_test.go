@@ -0,0 +1,97 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !js
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// progCriticalPath runs a task that waits for a goroutine that
+// computes and sleeps, and then waits for a lock.
+func progCriticalPath() {
+	_, task := trace.NewTask(context.Background(), "request")
+	done := make(chan bool)
+	go func() {
+		cpuHog(50 * time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		done <- true
+	}()
+	<-done
+
+	var mu sync.Mutex
+	mu.Lock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mu.Unlock()
+	}()
+	mu.Lock()
+	task.End()
+}
+
+func TestCriticalPath(t *testing.T) {
+	if err := traceProgram(t, progCriticalPath, "TestCriticalPath"); err != nil {
+		t.Fatalf("failed to trace the program: %v", err)
+	}
+	res, err := analyzeAnnotations()
+	if err != nil {
+		t.Fatalf("failed to analyze annotations: %v", err)
+	}
+	events, err := parseEvents()
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	tls := buildTimelines(events)
+
+	var task *taskDesc
+	for _, tsk := range res.tasks {
+		if tsk.name == "request" {
+			task = tsk
+		}
+	}
+	if task == nil || !task.complete() {
+		t.Fatalf("complete task \"request\" not found in the trace")
+	}
+
+	steps := criticalPath(task, tls)
+	var s pathSummary
+	s.add(steps)
+	if got, want := s.total(), task.duration(); got != want {
+		t.Errorf("critical path takes %v, want the task duration %v; path:\n%v", got, want, steps)
+	}
+	for i := 1; i < len(steps); i++ {
+		if steps[i-1].End != steps[i].Start {
+			t.Errorf("critical path has a gap between steps %v and %v", steps[i-1], steps[i])
+		}
+	}
+	gs := make(map[uint64]bool)
+	for _, step := range steps {
+		gs[step.G] = true
+	}
+	if len(gs) < 2 {
+		t.Errorf("critical path does not follow wakeups: goroutines %v", gs)
+	}
+	for _, c := range []pathCategory{pathExec, pathSleep, pathLock} {
+		if s[c] < 30*time.Millisecond {
+			t.Errorf("%v on the critical path is %v, want at least 30ms; path summary %v", c, s[c], s)
+		}
+	}
+
+	for _, url := range []string{"/criticalpath?type=request", "/criticalpath?taskid=" + fmt.Sprint(task.id)} {
+		w := httptest.NewRecorder()
+		httpCriticalPath(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != 200 || !strings.Contains(w.Body.String(), "Lock contention") {
+			t.Errorf("%s: got status %d, body:\n%s", url, w.Code, w.Body)
+		}
+	}
+}
@@ -23,6 +23,26 @@ Supported profile types are:
 	- sync: synchronization blocking profile
 	- syscall: syscall blocking profile
 	- sched: scheduler latency profile
+	- cpu: CPU profile
+
+The CPU profile is built from the CPU profiling samples recorded in the
+trace, so it is empty unless CPU profiling (for example, 'go test -cpuprofile')
+was on while tracing.
+The samples of all profiles are labeled with the runtime/pprof labels of
+their goroutine and, if the goroutine was in a region of a task at the time,
+with a "task" label holding the name of the task.
+
+In the web interface, the profiles of the regions of tasks matching the
+filters of the user-defined task pages are served as /taskcpu, /taskio,
+/taskblock, /tasksyscall and /tasksched, and all profiles can be restricted
+to the times goroutines had given profiler labels by adding parameters
+of the form label=key=value.
+
+The critical path page of user-defined tasks explains why tasks took as
+long as they did. It walks back from the end of each task to its creation,
+following the goroutines that unblocked or created the goroutines on the
+path, and attributes the time to execution, scheduler wait, syscalls,
+network wait, GC assists, lock contention and sleeping.
 
 Then, you can use the pprof tool to analyze the profile:
 	go tool pprof TYPE.pprof
@@ -0,0 +1,182 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Profiler labels of goroutines.
+
+package main
+
+import (
+	"fmt"
+	"internal/trace"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// labelChange records that the profiler labels of a goroutine,
+// set with runtime/pprof.Do or SetGoroutineLabels, changed at time ts.
+type labelChange struct {
+	ts     int64
+	labels map[string]string // nil if the goroutine has no labels
+}
+
+// goroutineLabels holds the label changes of each goroutine,
+// sorted by time.
+type goroutineLabels map[uint64][]labelChange
+
+// analyzeLabels returns the label changes of all goroutines in events.
+func analyzeLabels(events []*trace.Event) goroutineLabels {
+	gl := make(goroutineLabels)
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvGoCreate:
+			// A goroutine has no labels when it is created,
+			// unless an EvGoLabels event follows.
+			// EvGoCreate is also emitted for every goroutine
+			// at the start of each generation of the trace.
+			if len(gl[ev.Args[0]]) > 0 {
+				gl[ev.Args[0]] = append(gl[ev.Args[0]], labelChange{ts: ev.Ts})
+			}
+		case trace.EvGoLabels:
+			var labels map[string]string
+			if len(ev.SArgs) > 0 {
+				labels = make(map[string]string)
+				for i := 0; i+1 < len(ev.SArgs); i += 2 {
+					labels[ev.SArgs[i]] = ev.SArgs[i+1]
+				}
+			}
+			gl[ev.G] = append(gl[ev.G], labelChange{ts: ev.Ts, labels: labels})
+		}
+	}
+	return gl
+}
+
+// at returns the labels of goroutine g at time ts.
+func (gl goroutineLabels) at(g uint64, ts int64) map[string]string {
+	changes := gl[g]
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].ts > ts })
+	if i == 0 {
+		return nil
+	}
+	return changes[i-1].labels
+}
+
+// intervals returns the time intervals during which the labels of
+// each goroutine matched all key, value pairs of filter.
+func (gl goroutineLabels) intervals(filter map[string]string) map[uint64][]interval {
+	match := func(labels map[string]string) bool {
+		for k, v := range filter {
+			if lv, ok := labels[k]; !ok || lv != v {
+				return false
+			}
+		}
+		return true
+	}
+	res := make(map[uint64][]interval)
+	for g, changes := range gl {
+		for i, c := range changes {
+			if !match(c.labels) {
+				continue
+			}
+			end := lastTimestamp()
+			if i+1 < len(changes) {
+				end = changes[i+1].ts
+			}
+			res[g] = append(res[g], interval{begin: c.ts, end: end})
+		}
+	}
+	return res
+}
+
+// labelFilter returns the labels that the "label" parameters of r,
+// of the form key=value, require. It returns nil if there are none.
+func labelFilter(r *http.Request) (map[string]string, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	var filter map[string]string
+	for _, l := range r.Form["label"] {
+		i := strings.Index(l, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid label filter %q, want key=value", l)
+		}
+		if filter == nil {
+			filter = make(map[string]string)
+		}
+		filter[l[:i]] = l[i+1:]
+	}
+	return filter, nil
+}
+
+// sampleLabels computes the labels attached to the samples of
+// pprof-like profiles: the profiler labels of the goroutine and,
+// if the goroutine was in a region of a task, a "task" label
+// with the name of the task.
+type sampleLabels struct {
+	goroutines goroutineLabels
+	tasks      map[uint64][]taskInterval // by goroutine id
+}
+
+// taskInterval is the time interval of a region of a task.
+type taskInterval struct {
+	interval
+	task string
+}
+
+func newSampleLabels(events []*trace.Event) *sampleLabels {
+	sl := &sampleLabels{
+		goroutines: analyzeLabels(events),
+		tasks:      make(map[uint64][]taskInterval),
+	}
+	if res, err := analyzeAnnotations(); err == nil {
+		for _, task := range res.tasks {
+			for _, s := range task.regions {
+				sl.tasks[s.G] = append(sl.tasks[s.G], taskInterval{
+					interval: interval{begin: s.firstTimestamp(), end: s.lastTimestamp()},
+					task:     task.name,
+				})
+			}
+		}
+	}
+	return sl
+}
+
+// at returns the labels of the sample of goroutine g at time ts.
+func (sl *sampleLabels) at(g uint64, ts int64) map[string][]string {
+	var labels map[string][]string
+	for k, v := range sl.goroutines.at(g, ts) {
+		if labels == nil {
+			labels = make(map[string][]string)
+		}
+		labels[k] = []string{v}
+	}
+	// Use the innermost region, which starts last.
+	var task *taskInterval
+	for i, t := range sl.tasks[g] {
+		if t.begin <= ts && ts <= t.end && (task == nil || t.begin >= task.begin) {
+			task = &sl.tasks[g][i]
+		}
+	}
+	if task != nil && task.task != "" {
+		if labels == nil {
+			labels = make(map[string][]string)
+		}
+		labels["task"] = []string{task.task}
+	}
+	return labels
+}
+
+// labelsKey returns a string that uniquely identifies labels.
+func labelsKey(labels map[string][]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%q=%q,", k, labels[k])
+	}
+	return b.String()
+}
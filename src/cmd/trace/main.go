@@ -42,6 +42,7 @@ Supported profile types are:
     - sync: synchronization blocking profile
     - syscall: syscall blocking profile
     - sched: scheduler latency profile
+    - cpu: CPU profile (requires CPU profiling during tracing)
 
 Flags:
 	-http=addr: HTTP service address (e.g., ':6060')
@@ -93,6 +94,8 @@ func main() {
 		pprofFunc = pprofByGoroutine(computePprofSyscall)
 	case "sched":
 		pprofFunc = pprofByGoroutine(computePprofSched)
+	case "cpu":
+		pprofFunc = pprofByGoroutine(computePprofCPU)
 	}
 	if pprofFunc != nil {
 		if err := pprofFunc(os.Stdout, &http.Request{}); err != nil {
@@ -103,27 +106,26 @@ func main() {
 	if *pprofFlag != "" {
 		dief("unknown pprof type %s\n", *pprofFlag)
 	}
+	if *debugFlag {
+		if err := dumpTrace(); err != nil {
+			dief("%v\n", err)
+		}
+		os.Exit(0)
+	}
 
 	ln, err := net.Listen("tcp", *httpFlag)
 	if err != nil {
 		dief("failed to create server socket: %v\n", err)
 	}
 
-	log.Print("Parsing trace...")
-	res, err := parseTrace()
+	// The trace is read as it is split, without holding it in memory.
+	// The pages that analyze the whole trace parse it when they are
+	// first requested.
+	log.Print("Splitting trace...")
+	ranges, err = splitTrace()
 	if err != nil {
 		dief("%v\n", err)
 	}
-
-	if *debugFlag {
-		trace.Print(res.Events)
-		os.Exit(0)
-	}
-	reportMemoryUsage("after parsing trace")
-	debug.FreeOSMemory()
-
-	log.Print("Splitting trace...")
-	ranges = splitTrace(res)
 	reportMemoryUsage("after spliting trace")
 	debug.FreeOSMemory()
 
@@ -175,6 +177,51 @@ func parseTrace() (trace.ParseResult, error) {
 	return loader.res, loader.err
 }
 
+// readTrace calls f with a reader of the events of the trace. The
+// events are read from the trace file as f reads them, without
+// holding the whole trace in memory, unless the trace must be
+// symbolized as a whole.
+func readTrace(f func(eventReader) error) error {
+	if programBinary != "" {
+		// Go 1.5 traces must be symbolized as a whole.
+		res, err := parseTrace()
+		if err != nil {
+			return err
+		}
+		return f(&parsedReader{res: res})
+	}
+	tracef, err := os.Open(traceFile)
+	if err != nil {
+		return fmt.Errorf("failed to open trace file: %v", err)
+	}
+	defer tracef.Close()
+	r, err := trace.NewReader(bufio.NewReader(tracef))
+	if err != nil {
+		return fmt.Errorf("failed to parse trace: %v", err)
+	}
+	// Read a generation ahead, so that the links between
+	// events, such as the arrows of unblocks, cross generations.
+	r.Lookahead = true
+	return f(r)
+}
+
+// dumpTrace prints the events of the trace as they are read,
+// without holding the whole trace in memory.
+func dumpTrace() error {
+	return readTrace(func(r eventReader) error {
+		for {
+			ev, err := r.ReadEvent()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to parse trace: %v", err)
+			}
+			trace.PrintEvent(ev)
+		}
+	})
+}
+
 // httpMain serves the starting page.
 func httpMain(w http.ResponseWriter, r *http.Request) {
 	if err := templMain.Execute(w, ranges); err != nil {
@@ -199,6 +246,7 @@ var templMain = template.Must(template.New("").Parse(`
 <a href="/block">Synchronization blocking profile</a> (<a href="/block?raw=1" download="block.profile">⬇</a>)<br>
 <a href="/syscall">Syscall blocking profile</a> (<a href="/syscall?raw=1" download="syscall.profile">⬇</a>)<br>
 <a href="/sched">Scheduler latency profile</a> (<a href="/sche?raw=1" download="sched.profile">⬇</a>)<br>
+<a href="/cpu">CPU profile</a> (<a href="/cpu?raw=1" download="cpu.profile">⬇</a>)<br>
 <a href="/usertasks">User-defined tasks</a><br>
 <a href="/userregions">User-defined regions</a><br>
 <a href="/mmu">Minimum mutator utilization</a><br>
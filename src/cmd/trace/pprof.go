@@ -41,18 +41,33 @@ func init() {
 	http.HandleFunc("/block", serveSVGProfile(pprofByGoroutine(computePprofBlock)))
 	http.HandleFunc("/syscall", serveSVGProfile(pprofByGoroutine(computePprofSyscall)))
 	http.HandleFunc("/sched", serveSVGProfile(pprofByGoroutine(computePprofSched)))
+	http.HandleFunc("/cpu", serveSVGProfile(pprofByGoroutine(computePprofCPU)))
 
 	http.HandleFunc("/regionio", serveSVGProfile(pprofByRegion(computePprofIO)))
 	http.HandleFunc("/regionblock", serveSVGProfile(pprofByRegion(computePprofBlock)))
 	http.HandleFunc("/regionsyscall", serveSVGProfile(pprofByRegion(computePprofSyscall)))
 	http.HandleFunc("/regionsched", serveSVGProfile(pprofByRegion(computePprofSched)))
+	http.HandleFunc("/regioncpu", serveSVGProfile(pprofByRegion(computePprofCPU)))
+
+	http.HandleFunc("/taskio", serveSVGProfile(pprofByTask(computePprofIO)))
+	http.HandleFunc("/taskblock", serveSVGProfile(pprofByTask(computePprofBlock)))
+	http.HandleFunc("/tasksyscall", serveSVGProfile(pprofByTask(computePprofSyscall)))
+	http.HandleFunc("/tasksched", serveSVGProfile(pprofByTask(computePprofSched)))
+	http.HandleFunc("/taskcpu", serveSVGProfile(pprofByTask(computePprofCPU)))
 }
 
 // Record represents one entry in pprof-like profiles.
 type Record struct {
-	stk  []*trace.Frame
-	n    uint64
-	time int64
+	stk    []*trace.Frame
+	labels map[string][]string
+	n      uint64
+	time   int64
+}
+
+// recordKey identifies the Record of a stack and labels.
+type recordKey struct {
+	stkID  uint64
+	labels string // see labelsKey
 }
 
 // interval represents a time interval in the trace.
@@ -71,6 +86,10 @@ func pprofByGoroutine(compute func(io.Writer, map[uint64][]interval, []*trace.Ev
 		if err != nil {
 			return err
 		}
+		gToIntervals, err = pprofMatchingLabels(r, gToIntervals, events)
+		if err != nil {
+			return err
+		}
 		return compute(w, gToIntervals, events)
 	}
 }
@@ -86,6 +105,30 @@ func pprofByRegion(compute func(io.Writer, map[uint64][]interval, []*trace.Event
 			return err
 		}
 		events, _ := parseEvents()
+		gToIntervals, err = pprofMatchingLabels(r, gToIntervals, events)
+		if err != nil {
+			return err
+		}
+
+		return compute(w, gToIntervals, events)
+	}
+}
+
+func pprofByTask(compute func(io.Writer, map[uint64][]interval, []*trace.Event) error) func(w io.Writer, r *http.Request) error {
+	return func(w io.Writer, r *http.Request) error {
+		filter, err := newTaskFilter(r)
+		if err != nil {
+			return err
+		}
+		gToIntervals, err := pprofMatchingTasks(filter)
+		if err != nil {
+			return err
+		}
+		events, _ := parseEvents()
+		gToIntervals, err = pprofMatchingLabels(r, gToIntervals, events)
+		if err != nil {
+			return err
+		}
 
 		return compute(w, gToIntervals, events)
 	}
@@ -143,6 +186,70 @@ func pprofMatchingRegions(filter *regionFilter) (map[uint64][]interval, error) {
 		}
 	}
 
+	selectOutermost(gToIntervals)
+	return gToIntervals, nil
+}
+
+// pprofMatchingTasks returns the time intervals of the regions of
+// matching tasks and their subtasks, grouped by the goroutine id.
+func pprofMatchingTasks(filter *taskFilter) (map[uint64][]interval, error) {
+	res, err := analyzeAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	gToIntervals := make(map[uint64][]interval)
+	for _, task := range res.tasks {
+		if !filter.match(task) {
+			continue
+		}
+		for _, t := range task.descendants() {
+			for _, s := range t.regions {
+				gToIntervals[s.G] = append(gToIntervals[s.G], interval{begin: s.firstTimestamp(), end: s.lastTimestamp()})
+			}
+		}
+	}
+	selectOutermost(gToIntervals)
+	return gToIntervals, nil
+}
+
+// pprofMatchingLabels restricts gToIntervals to the time intervals
+// during which the goroutines had the profiler labels given by the
+// "label" parameters of r. If there are no such parameters,
+// it returns gToIntervals.
+func pprofMatchingLabels(r *http.Request, gToIntervals map[uint64][]interval, events []*trace.Event) (map[uint64][]interval, error) {
+	filter, err := labelFilter(r)
+	if err != nil || filter == nil {
+		return gToIntervals, err
+	}
+	labelIntervals := analyzeLabels(events).intervals(filter)
+	if gToIntervals == nil {
+		return labelIntervals, nil
+	}
+
+	res := make(map[uint64][]interval)
+	for g, intervals := range gToIntervals {
+		for _, i := range intervals {
+			for _, l := range labelIntervals[g] {
+				begin, end := i.begin, i.end
+				if l.begin > begin {
+					begin = l.begin
+				}
+				if l.end < end {
+					end = l.end
+				}
+				if begin < end {
+					res[g] = append(res[g], interval{begin: begin, end: end})
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// selectOutermost removes the nested intervals of each goroutine
+// in gToIntervals.
+func selectOutermost(gToIntervals map[uint64][]interval) {
 	for g, intervals := range gToIntervals {
 		// in order to remove nested regions and
 		// consider only the outermost regions,
@@ -168,23 +275,19 @@ func pprofMatchingRegions(filter *regionFilter) (map[uint64][]interval, error) {
 		}
 		gToIntervals[g] = intervals[:n]
 	}
-	return gToIntervals, nil
 }
 
 // computePprofIO generates IO pprof-like profile (time spent in IO wait, currently only network blocking event).
 func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
+	prof := make(map[recordKey]Record)
+	labels := newSampleLabels(events)
 	for _, ev := range events {
 		if ev.Type != trace.EvGoBlockNet || ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
 			continue
 		}
 		overlapping := pprofOverlappingDuration(gToIntervals, ev)
 		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+			addRecord(prof, labels, ev, overlapping)
 		}
 	}
 	return buildProfile(prof).Write(w)
@@ -192,7 +295,8 @@ func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval, events []*t
 
 // computePprofBlock generates blocking pprof-like profile (time spent blocked on synchronization primitives).
 func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
+	prof := make(map[recordKey]Record)
+	labels := newSampleLabels(events)
 	for _, ev := range events {
 		switch ev.Type {
 		case trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect,
@@ -208,11 +312,7 @@ func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events [
 		}
 		overlapping := pprofOverlappingDuration(gToIntervals, ev)
 		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+			addRecord(prof, labels, ev, overlapping)
 		}
 	}
 	return buildProfile(prof).Write(w)
@@ -220,18 +320,15 @@ func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events [
 
 // computePprofSyscall generates syscall pprof-like profile (time spent blocked in syscalls).
 func computePprofSyscall(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
+	prof := make(map[recordKey]Record)
+	labels := newSampleLabels(events)
 	for _, ev := range events {
 		if ev.Type != trace.EvGoSysCall || ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
 			continue
 		}
 		overlapping := pprofOverlappingDuration(gToIntervals, ev)
 		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+			addRecord(prof, labels, ev, overlapping)
 		}
 	}
 	return buildProfile(prof).Write(w)
@@ -240,7 +337,8 @@ func computePprofSyscall(w io.Writer, gToIntervals map[uint64][]interval, events
 // computePprofSched generates scheduler latency pprof-like profile
 // (time between a goroutine become runnable and actually scheduled for execution).
 func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
+	prof := make(map[recordKey]Record)
+	labels := newSampleLabels(events)
 	for _, ev := range events {
 		if (ev.Type != trace.EvGoUnblock && ev.Type != trace.EvGoCreate) ||
 			ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
@@ -248,16 +346,61 @@ func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events [
 		}
 		overlapping := pprofOverlappingDuration(gToIntervals, ev)
 		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+			addRecord(prof, labels, ev, overlapping)
 		}
 	}
 	return buildProfile(prof).Write(w)
 }
 
+// computePprofCPU generates CPU pprof-like profile from the CPU profiling
+// samples in the trace, which are recorded while runtime/pprof CPU profiling is on.
+func computePprofCPU(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
+	prof := make(map[recordKey]Record)
+	labels := newSampleLabels(events)
+	for _, ev := range events {
+		if ev.Type != trace.EvCPUSample || ev.StkID == 0 || len(ev.Stk) == 0 {
+			continue
+		}
+		if pprofInIntervals(gToIntervals, ev) {
+			addRecord(prof, labels, ev, 0)
+		}
+	}
+	p := buildProfile(prof)
+	p.SampleType = []*profile.ValueType{{Type: "samples", Unit: "count"}}
+	for _, s := range p.Sample {
+		s.Value = s.Value[:1]
+	}
+	return p.Write(w)
+}
+
+// addRecord adds the stack of ev to prof, labeled with the labels
+// of its goroutine at the time of ev (see sampleLabels).
+func addRecord(prof map[recordKey]Record, labels *sampleLabels, ev *trace.Event, d time.Duration) {
+	l := labels.at(ev.G, ev.Ts)
+	key := recordKey{stkID: ev.StkID, labels: labelsKey(l)}
+	rec := prof[key]
+	rec.stk = ev.Stk
+	rec.labels = l
+	rec.n++
+	rec.time += d.Nanoseconds()
+	prof[key] = rec
+}
+
+// pprofInIntervals reports whether the instantaneous event ev
+// is in the time intervals of its goroutine in gToIntervals.
+// If gToIntervals is nil, this simply returns true.
+func pprofInIntervals(gToIntervals map[uint64][]interval, ev *trace.Event) bool {
+	if gToIntervals == nil { // No filtering.
+		return true
+	}
+	for _, i := range gToIntervals[ev.G] {
+		if i.begin <= ev.Ts && ev.Ts <= i.end {
+			return true
+		}
+	}
+	return false
+}
+
 // pprofOverlappingDuration returns the overlapping duration between
 // the time intervals in gToIntervals and the specified event.
 // If gToIntervals is nil, this simply returns the event's duration.
@@ -327,7 +470,7 @@ func serveSVGProfile(prof func(w io.Writer, r *http.Request) error) http.Handler
 	}
 }
 
-func buildProfile(prof map[uint64]Record) *profile.Profile {
+func buildProfile(prof map[recordKey]Record) *profile.Profile {
 	p := &profile.Profile{
 		PeriodType: &profile.ValueType{Type: "trace", Unit: "count"},
 		Period:     1,
@@ -372,6 +515,7 @@ func buildProfile(prof map[uint64]Record) *profile.Profile {
 		p.Sample = append(p.Sample, &profile.Sample{
 			Value:    []int64{int64(rec.n), rec.time},
 			Location: sloc,
+			Label:    rec.labels,
 		})
 	}
 	return p
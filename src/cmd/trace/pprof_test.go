@@ -0,0 +1,106 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"runtime/trace"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+func cpuHog(d time.Duration) int {
+	x := 0
+	for start := time.Now(); time.Since(start) < d; {
+		for i := 0; i < 1e5; i++ {
+			x = x*31 + i
+		}
+	}
+	return x
+}
+
+// prog3 burns CPU with profiler labels request=a in task "task0"
+// and request=b outside of any task.
+func prog3() {
+	ctx, task := trace.NewTask(context.Background(), "task0")
+	trace.WithRegion(ctx, "region0", func() {
+		pprof.Do(ctx, pprof.Labels("request", "a"), func(context.Context) {
+			cpuHog(300 * time.Millisecond)
+		})
+	})
+	task.End()
+	pprof.Do(context.Background(), pprof.Labels("request", "b"), func(context.Context) {
+		cpuHog(300 * time.Millisecond)
+	})
+}
+
+func TestPprofLabels(t *testing.T) {
+	if err := pprof.StartCPUProfile(ioutil.Discard); err != nil {
+		t.Skipf("failed to start CPU profiling: %v", err)
+	}
+	err := traceProgram(t, prog3, "TestPprofLabels")
+	pprof.StopCPUProfile()
+	if err != nil {
+		t.Fatalf("failed to trace the program: %v", err)
+	}
+
+	getProfile := func(url string, handler func(io.Writer, *http.Request) error) *profile.Profile {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := handler(&buf, httptest.NewRequest("GET", url, nil)); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		p, err := profile.Parse(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to parse profile: %v", url, err)
+		}
+		return p
+	}
+	count := func(p *profile.Profile, key, value string) (match, total int64) {
+		for _, s := range p.Sample {
+			total += s.Value[0]
+			if len(s.Label[key]) == 1 && s.Label[key][0] == value {
+				match += s.Value[0]
+			}
+		}
+		return match, total
+	}
+
+	p := getProfile("/cpu", pprofByGoroutine(computePprofCPU))
+	if n, _ := count(p, "request", "a"); n == 0 {
+		t.Errorf("/cpu: no samples labeled request=a")
+	}
+	if n, _ := count(p, "request", "b"); n == 0 {
+		t.Errorf("/cpu: no samples labeled request=b")
+	}
+	if n, _ := count(p, "task", "task0"); n == 0 {
+		t.Errorf("/cpu: no samples labeled task=task0")
+	}
+
+	p = getProfile("/cpu?label=request=a", pprofByGoroutine(computePprofCPU))
+	if n, total := count(p, "request", "a"); n == 0 || n != total {
+		t.Errorf("/cpu?label=request=a: %d of %d samples labeled request=a, want all and more than 0", n, total)
+	}
+
+	p = getProfile("/taskcpu?type=task0", pprofByTask(computePprofCPU))
+	if n, total := count(p, "task", "task0"); n == 0 || n != total {
+		t.Errorf("/taskcpu?type=task0: %d of %d samples labeled task=task0, want all and more than 0", n, total)
+	}
+
+	p = getProfile("/taskcpu?type=task0&label=request=b", pprofByTask(computePprofCPU))
+	if _, total := count(p, "request", "b"); total != 0 {
+		t.Errorf("/taskcpu?type=task0&label=request=b: got %d samples, want 0", total)
+	}
+}
@@ -28,12 +28,8 @@ func init() {
 }
 
 // httpTrace serves either whole trace (goid==0) or trace for goid goroutine.
+// The trace has been read by splitTrace already, so it is not parsed here.
 func httpTrace(w http.ResponseWriter, r *http.Request) {
-	_, err := parseTrace()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -170,17 +166,25 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 	defer debug.FreeOSMemory()
 	defer reportMemoryUsage("after httpJsonTrace")
 	// This is an AJAX handler, so instead of http.Error we use log.Printf to log errors.
-	res, err := parseTrace()
-	if err != nil {
-		log.Printf("failed to parse trace: %v", err)
-		return
-	}
-
 	params := &traceParams{
-		parsed:  res,
 		endTime: math.MaxInt64,
 	}
 
+	// The goroutine and task views need the whole trace to find the
+	// goroutines and tasks to show. The other views read the events
+	// from the trace file as they are needed.
+	var res trace.ParseResult
+	whole := r.FormValue("goid") != "" || r.FormValue("taskid") != "" || r.FormValue("focustask") != ""
+	if whole {
+		var err error
+		res, err = parseTrace()
+		if err != nil {
+			log.Printf("failed to parse trace: %v", err)
+			return
+		}
+		params.parsed = res
+	}
+
 	if goids := r.FormValue("goid"); goids != "" {
 		// If goid argument is present, we are rendering a trace for this particular goroutine.
 		goid, err := strconv.ParseUint(goids, 10, 64)
@@ -247,6 +251,7 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 		params.tasks = task.descendants()
 	}
 
+	var err error
 	start := int64(0)
 	end := int64(math.MaxInt64)
 	if startStr, endStr := r.FormValue("start"), r.FormValue("end"); startStr != "" && endStr != "" {
@@ -264,7 +269,15 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := viewerDataTraceConsumer(w, start, end)
-	if err := generateTrace(params, c); err != nil {
+	if whole {
+		err = generateTrace(params, c)
+	} else {
+		err = readTrace(func(r eventReader) error {
+			params.reader = r
+			return generateTrace(params, c)
+		})
+	}
+	if err != nil {
 		log.Printf("failed to generate trace: %v", err)
 		return
 	}
@@ -285,16 +298,19 @@ func (r Range) URL() string {
 // splitTrace splits the trace into a number of ranges,
 // each resulting in approx 100MB of json output
 // (trace viewer can hardly handle more).
-func splitTrace(res trace.ParseResult) []Range {
-	params := &traceParams{
-		parsed:  res,
-		endTime: math.MaxInt64,
-	}
+func splitTrace() ([]Range, error) {
 	s, c := splittingTraceConsumer(100 << 20) // 100M
-	if err := generateTrace(params, c); err != nil {
-		dief("%v\n", err)
+	err := readTrace(func(r eventReader) error {
+		params := &traceParams{
+			reader:  r,
+			endTime: math.MaxInt64,
+		}
+		return generateTrace(params, c)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return s.Ranges
+	return s.Ranges, nil
 }
 
 type splitter struct {
@@ -395,6 +411,7 @@ func (cw *countingWriter) Write(data []byte) (int, error) {
 
 type traceParams struct {
 	parsed    trace.ParseResult
+	reader    eventReader // if non-nil, the events are read from it rather than parsed
 	mode      traceviewMode
 	startTime int64
 	endTime   int64
@@ -403,6 +420,32 @@ type traceParams struct {
 	tasks     []*taskDesc     // Tasks to be displayed. tasks[0] is the top-most task
 }
 
+// An eventReader returns the events of a trace one at a time, like a
+// trace.Reader, and the stacks they refer to by ID.
+type eventReader interface {
+	ReadEvent() (*trace.Event, error)
+	Stack(id uint64) []*trace.Frame
+}
+
+// parsedReader is an eventReader of the events of a parsed trace.
+type parsedReader struct {
+	res trace.ParseResult
+	i   int
+}
+
+func (r *parsedReader) ReadEvent() (*trace.Event, error) {
+	if r.i == len(r.res.Events) {
+		return nil, io.EOF
+	}
+	ev := r.res.Events[r.i]
+	r.i++
+	return ev, nil
+}
+
+func (r *parsedReader) Stack(id uint64) []*trace.Frame {
+	return r.res.Stacks[id]
+}
+
 type traceviewMode uint
 
 const (
@@ -539,7 +582,10 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 	ctx.consumer.consumeTimeUnit("ns")
 	maxProc := 0
 	ginfos := make(map[uint64]*gInfo)
-	stacks := params.parsed.Stacks
+	events := params.reader
+	if events == nil {
+		events = &parsedReader{res: params.parsed}
+	}
 
 	getGInfo := func(g uint64) *gInfo {
 		info, ok := ginfos[g]
@@ -567,7 +613,15 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 		info.state = newState
 	}
 
-	for _, ev := range ctx.parsed.Events {
+	for {
+		ev, err := events.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse trace: %v", err)
+		}
+
 		// Handle state transitions before we filter out events.
 		switch ev.Type {
 		case trace.EvGoStart, trace.EvGoStartLabel:
@@ -585,8 +639,8 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 				return fmt.Errorf("duplicate go create event for go id=%d detected at offset %d", newG, ev.Off)
 			}
 
-			stk, ok := stacks[ev.Args[1]]
-			if !ok || len(stk) == 0 {
+			stk := events.Stack(ev.Args[1])
+			if len(stk) == 0 {
 				return fmt.Errorf("invalid go create event: missing stack information for go id=%d at offset %d", newG, ev.Off)
 			}
 
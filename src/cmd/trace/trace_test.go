@@ -7,12 +7,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"internal/trace"
 	"io/ioutil"
+	"reflect"
 	rtrace "runtime/trace"
 	"strings"
 	"testing"
+	"time"
 )
 
 // stacks is a fake stack map populated for test.
@@ -233,3 +237,69 @@ func TestFoo(t *testing.T) {
 	}
 
 }
+
+// TestStreamingTrace tests that generateTrace produces the same
+// trace from the events read from a trace.Reader as from the parsed
+// trace, when the trace has more than one generation.
+func TestStreamingTrace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	if rtrace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := rtrace.Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	// Keep goroutines blocking and unblocking each other
+	// across the ends of generations, about a second apart.
+	ping, pong := make(chan bool), make(chan bool)
+	go func() {
+		for range ping {
+			pong <- true
+		}
+	}()
+	for deadline := time.Now().Add(1500 * time.Millisecond); time.Now().Before(deadline); {
+		ping <- true
+		<-pong
+		time.Sleep(time.Millisecond)
+	}
+	close(ping)
+	rtrace.Stop()
+	data := buf.Bytes()
+
+	generate := func(params *traceParams) []string {
+		var events []string
+		c := viewerDataTraceConsumer(ioutil.Discard, 0, 1<<63-1)
+		c.consumeViewerEvent = func(ev *ViewerEvent, _ bool) {
+			b, err := json.Marshal(ev)
+			if err != nil {
+				t.Fatalf("failed to encode %v: %v", ev, err)
+			}
+			events = append(events, string(b))
+		}
+		params.endTime = 1<<63 - 1
+		if err := generateTrace(params, c); err != nil {
+			t.Fatalf("generateTrace failed: %v", err)
+		}
+		return events
+	}
+
+	res, err := trace.Parse(bytes.NewReader(data), "")
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	want := generate(&traceParams{parsed: res})
+
+	r, err := trace.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to read trace: %v", err)
+	}
+	r.Lookahead = true
+	got := generate(&traceParams{reader: r})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d viewer events from the streamed trace, want the %d from the parsed trace", len(got), len(want))
+	}
+}
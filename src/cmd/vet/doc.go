@@ -37,12 +37,15 @@ To list the available checks, run "go tool vet help":
     composites   check for unkeyed composite literals
     copylocks    check for locks erroneously passed by value
     httpresponse check for mistakes using HTTP responses
+    ifaceassert  detect impossible interface-to-interface type assertions
     loopclosure  check references to loop variables from within nested functions
     lostcancel   check cancel func returned by context.WithCancel is called
     nilfunc      check for useless comparisons between functions and nil
+    nilness      check for redundant or impossible nil comparisons
     printf       check consistency of Printf format strings and arguments
     shift        check for shifts that equal or exceed the width of the integer
     stdmethods   check signature of methods of well-known interfaces
+    stringintconv check for string(int) conversions
     structtag    check that struct field tags conform to reflect.StructTag.Get
     tests        check for common mistaken usages of tests and examples
     unmarshal    report passing non-pointer or non-interface values to unmarshal
@@ -52,11 +55,19 @@ To list the available checks, run "go tool vet help":
 
 For details and flags of a particular check, such as printf, run "go tool vet help printf".
 
-By default, all checks are performed.
+By default, all checks except stringintconv are performed.
 If any flags are explicitly set to true, only those tests are run.
 Conversely, if any flag is explicitly set to false, only those tests are disabled.
 Thus -printf=true runs the printf check,
 and -printf=false runs all checks except the printf check.
+The stringintconv check only runs when it is named: -stringintconv
+runs it alone, and -stringintconv=false runs all other checks.
+
+Some checks suggest fixes for the problems they report: printf removes
+redundant newlines and replaces the verbs of directives whose arguments
+have the wrong type with %v, structtag quotes struct tag values, and
+stringintconv converts integers to strings through rune. "go vet -fix"
+applies those fixes, and "go vet -diff" prints them as a diff.
 
 For information on writing a new check, see golang.org/x/tools/go/analysis.
 
@@ -0,0 +1,425 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// Suggested fixes.
+//
+// When go vet is run with -fix or -diff, the go command sets
+// FixesOutput in the configuration of each package it vets. Vet then
+// writes the suggested fixes for the problems it finds to that file,
+// as a JSON list of the form read by cmd/go/internal/vet, and reports
+// only the problems that have no fix.
+//
+// The fixes are those suggested by the analyzers themselves and, for
+// analyzers that do not suggest fixes of their own, those computed
+// by the fixers below from a diagnostic and the code it refers to.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A suggestedFix is a fix as written to FixesOutput.
+type suggestedFix struct {
+	Analyzer string // name of the analyzer that suggested the fix
+	Posn     string // position of the diagnostic
+	Message  string // message of the diagnostic
+	Fix      string // description of the fix
+	Edits    []textEdit
+}
+
+// A textEdit replaces the bytes [Offset, End) of File with New.
+type textEdit struct {
+	File   string
+	Offset int
+	End    int
+	New    string
+}
+
+// A fixWriter collects the suggested fixes for a package
+// and writes them to a file.
+type fixWriter struct {
+	file string
+
+	mu    sync.Mutex
+	fixes []suggestedFix
+}
+
+// withFixes returns analyzers unchanged unless vet was invoked with a
+// configuration file that sets FixesOutput. In that case it returns
+// copies of analyzers that write the fixes for the problems they find
+// to FixesOutput instead of reporting those problems.
+func withFixes(analyzers []*analysis.Analyzer) []*analysis.Analyzer {
+	args := os.Args[1:]
+	if len(args) == 0 || !strings.HasSuffix(args[len(args)-1], ".cfg") {
+		return analyzers
+	}
+	// Errors are left for unitchecker to report.
+	data, err := ioutil.ReadFile(args[len(args)-1])
+	if err != nil {
+		return analyzers
+	}
+	var cfg struct {
+		FixesOutput string
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg.FixesOutput == "" {
+		return analyzers
+	}
+
+	w := &fixWriter{file: cfg.FixesOutput}
+	wrapped := make([]*analysis.Analyzer, len(analyzers))
+	for i, a := range analyzers {
+		wrapped[i] = w.wrap(a)
+	}
+	return wrapped
+}
+
+// wrap returns a copy of a that records the diagnostics with a
+// suggested fix in w, and writes all fixes recorded so far to the
+// file of w when it is done.
+func (w *fixWriter) wrap(a *analysis.Analyzer) *analysis.Analyzer {
+	wa := *a
+	wa.Run = func(pass *analysis.Pass) (interface{}, error) {
+		p := *pass
+		p.Report = func(d analysis.Diagnostic) {
+			if len(d.SuggestedFixes) == 0 {
+				if fix := fixers[a.Name]; fix != nil {
+					d.SuggestedFixes = fix(pass, d)
+				}
+			}
+			if len(d.SuggestedFixes) == 0 {
+				pass.Report(d)
+				return
+			}
+			w.add(pass.Fset, a.Name, d)
+		}
+		result, err := a.Run(&p)
+		if err := w.write(); err != nil {
+			return nil, err
+		}
+		return result, err
+	}
+	return &wa
+}
+
+// add records the first suggested fix of d, reported by the analyzer name.
+func (w *fixWriter) add(fset *token.FileSet, name string, d analysis.Diagnostic) {
+	sf := d.SuggestedFixes[0]
+	f := suggestedFix{
+		Analyzer: name,
+		Posn:     fset.Position(d.Pos).String(),
+		Message:  d.Message,
+		Fix:      sf.Message,
+	}
+	for _, e := range sf.TextEdits {
+		end := e.End
+		if end == token.NoPos {
+			end = e.Pos
+		}
+		start := fset.Position(e.Pos)
+		f.Edits = append(f.Edits, textEdit{
+			File:   start.Filename,
+			Offset: start.Offset,
+			End:    fset.Position(end).Offset,
+			New:    string(e.NewText),
+		})
+	}
+
+	w.mu.Lock()
+	w.fixes = append(w.fixes, f)
+	w.mu.Unlock()
+}
+
+// write writes the fixes recorded so far to the file of w,
+// sorted by position.
+func (w *fixWriter) write() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fixes := append([]suggestedFix{}, w.fixes...)
+	sort.SliceStable(fixes, func(i, j int) bool {
+		ei, ej := fixes[i].Edits[0], fixes[j].Edits[0]
+		if ei.File != ej.File {
+			return ei.File < ej.File
+		}
+		return ei.Offset < ej.Offset
+	})
+	data, err := json.Marshal(fixes)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(w.file, data, 0666)
+}
+
+// fixers compute suggested fixes for the diagnostics of analyzers
+// that do not suggest fixes themselves. A fixer returns no fixes for
+// a diagnostic it does not know how to fix.
+var fixers = map[string]func(*analysis.Pass, analysis.Diagnostic) []analysis.SuggestedFix{
+	"printf":    printfFix,
+	"structtag": structtagFix,
+}
+
+// printfFix removes redundant newlines at the end of the arguments of
+// Println-like calls, and replaces the verb of a Printf directive
+// whose argument has the wrong type with %v.
+func printfFix(pass *analysis.Pass, d analysis.Diagnostic) []analysis.SuggestedFix {
+	for _, call := range callsAt(pass, d.Pos) {
+		if strings.HasSuffix(d.Message, " arg list ends with redundant newline") {
+			if fix := newlineFix(call); fix != nil {
+				return fix
+			}
+			continue
+		}
+		if strings.Contains(d.Message, " of wrong type ") {
+			if fix := verbFix(pass, call, d.Message); fix != nil {
+				return fix
+			}
+		}
+	}
+	return nil
+}
+
+// newlineFix removes the \n at the end of the last argument of call.
+func newlineFix(call *ast.CallExpr) []analysis.SuggestedFix {
+	if len(call.Args) == 0 {
+		return nil
+	}
+	lit, ok := call.Args[len(call.Args)-1].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING || !strings.HasSuffix(lit.Value, `\n"`) {
+		return nil
+	}
+	old, err1 := strconv.Unquote(lit.Value)
+	new, err2 := strconv.Unquote(lit.Value[:len(lit.Value)-3] + `"`)
+	if err1 != nil || err2 != nil || new+"\n" != old {
+		return nil
+	}
+	end := lit.End() - 1 // closing quote
+	return []analysis.SuggestedFix{{
+		Message:   "Remove redundant newline",
+		TextEdits: []analysis.TextEdit{{Pos: end - 2, End: end}},
+	}}
+}
+
+// verbFix replaces the verb of the directive of call that msg reports
+// as having an argument of the wrong type with v.
+func verbFix(pass *analysis.Pass, call *ast.CallExpr, msg string) []analysis.SuggestedFix {
+	for i, arg := range call.Args {
+		lit, ok := arg.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			continue
+		}
+		// The directives are found in the source text of the literal,
+		// so give up if an escape sequence denotes a %.
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil || strings.Count(s, "%") != strings.Count(lit.Value, "%") {
+			continue
+		}
+		argNum := i + 1
+		for _, dir := range directives(lit.Value) {
+			if dir.verb < 0 {
+				// Explicit argument indexes and * widths
+				// are too complicated to follow.
+				break
+			}
+			if argNum >= len(call.Args) {
+				break
+			}
+			arg := call.Args[argNum]
+			argNum++
+			typ := pass.TypesInfo.TypeOf(arg)
+			if typ == nil {
+				continue
+			}
+			want := fmt.Sprintf(" format %s has arg %s of wrong type %s", lit.Value[dir.start:dir.verb+1], formatExpr(pass.Fset, arg), typ)
+			if !strings.HasSuffix(msg, want) || lit.Value[dir.verb] == 'v' {
+				continue
+			}
+			pos := lit.Pos() + token.Pos(dir.verb)
+			return []analysis.SuggestedFix{{
+				Message:   "Format with %v",
+				TextEdits: []analysis.TextEdit{{Pos: pos, End: pos + 1, NewText: []byte("v")}},
+			}}
+		}
+	}
+	return nil
+}
+
+// A directive is a formatting directive such as %-8.3f in the text of
+// a format string, starting at byte offset start, with its verb at
+// offset verb. verb is -1 for a directive with an explicit argument
+// index or a * width or precision.
+type directive struct {
+	start, verb int
+}
+
+// directives returns the formatting directives in format,
+// other than %%.
+func directives(format string) []directive {
+	var dirs []directive
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && ('0' <= format[i] && format[i] <= '9' || format[i] == '.') {
+			i++
+		}
+		if i >= len(format) {
+			break
+		}
+		switch format[i] {
+		case '%':
+			continue
+		case '*', '[':
+			dirs = append(dirs, directive{start, -1})
+			return dirs
+		}
+		dirs = append(dirs, directive{start, i})
+	}
+	return dirs
+}
+
+// formatExpr returns the source text of x as printed by the printf
+// analyzer.
+func formatExpr(fset *token.FileSet, x ast.Expr) string {
+	var b bytes.Buffer
+	printer.Fprint(&b, fset, x)
+	return b.String()
+}
+
+// callsAt returns the calls in the files of pass that start at pos.
+func callsAt(pass *analysis.Pass, pos token.Pos) []*ast.CallExpr {
+	var calls []*ast.CallExpr
+	for _, f := range pass.Files {
+		if pos < f.Pos() || pos >= f.End() {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil || pos < n.Pos() || pos >= n.End() {
+				return false
+			}
+			if call, ok := n.(*ast.CallExpr); ok && call.Pos() == pos {
+				calls = append(calls, call)
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// structtagFix rewrites a struct tag that reflect.StructTag.Get cannot
+// parse as a list of space-separated key:"value" pairs, if quoting its
+// values and separating its pairs with spaces is all that is needed.
+func structtagFix(pass *analysis.Pass, d analysis.Diagnostic) []analysis.SuggestedFix {
+	if !strings.Contains(d.Message, " not compatible with reflect.StructTag.Get: ") {
+		return nil
+	}
+	var field *ast.Field
+	for _, f := range pass.Files {
+		if d.Pos < f.Pos() || d.Pos >= f.End() {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil || field != nil || d.Pos < n.Pos() || d.Pos >= n.End() {
+				return false
+			}
+			if f, ok := n.(*ast.Field); ok && f.Tag != nil {
+				if len(f.Names) == 0 && f.Type.Pos() == d.Pos {
+					field = f
+				}
+				for _, id := range f.Names {
+					if id.Pos() == d.Pos {
+						field = f
+					}
+				}
+			}
+			return true
+		})
+	}
+	if field == nil {
+		return nil
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return nil
+	}
+	fixed, ok := canonicalTag(tag)
+	if !ok || fixed == tag {
+		return nil
+	}
+	lit := strconv.Quote(fixed)
+	if field.Tag.Value[0] == '`' && !strings.Contains(fixed, "`") {
+		lit = "`" + fixed + "`"
+	}
+	return []analysis.SuggestedFix{{
+		Message:   "Quote struct tag values",
+		TextEdits: []analysis.TextEdit{{Pos: field.Tag.Pos(), End: field.Tag.End(), NewText: []byte(lit)}},
+	}}
+}
+
+// canonicalTag rewrites tag as a list of key:"value" pairs separated
+// by single spaces. The values in tag may be quoted or not, and the
+// pairs may be separated by spaces or commas. canonicalTag reports
+// false if tag is not of that form.
+func canonicalTag(tag string) (string, bool) {
+	var pairs []string
+	for {
+		tag = strings.TrimLeft(tag, " ,")
+		if tag == "" {
+			break
+		}
+		i := strings.IndexByte(tag, ':')
+		if i <= 0 || strings.IndexFunc(tag[:i], func(r rune) bool { return r <= ' ' || r == '"' || r == '`' || r == ',' || r == 0x7f }) >= 0 {
+			return "", false
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		var value string
+		if strings.HasPrefix(tag, `"`) {
+			j := 1
+			for j < len(tag) && tag[j] != '"' {
+				if tag[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(tag) {
+				return "", false
+			}
+			v, err := strconv.Unquote(tag[:j+1])
+			if err != nil {
+				return "", false
+			}
+			value, tag = v, tag[j+1:]
+		} else {
+			j := strings.IndexByte(tag, ' ')
+			if j < 0 {
+				j = len(tag)
+			}
+			value, tag = tag[:j], tag[j:]
+			if value == "" || strings.ContainsAny(value, "\"`") {
+				return "", false
+			}
+		}
+		pairs = append(pairs, key+":"+strconv.Quote(value))
+	}
+	return strings.Join(pairs, " "), len(pairs) > 0
+}
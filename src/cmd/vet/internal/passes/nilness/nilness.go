@@ -0,0 +1,493 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nilness defines an Analyzer that checks for nil pointer
+// dereferences and degenerate nil pointer comparisons.
+package nilness
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+)
+
+const Doc = `check for redundant or impossible nil comparisons
+
+The nilness checker inspects the control-flow graph of each function in
+a package and reports nil pointer dereferences and degenerate nil
+pointer comparisons. For example:
+
+	if p == nil {
+		print(*p)   // nil dereference in load
+	}
+
+	if p != nil {
+		if p == nil { // impossible condition: non-nil == nil
+			...
+		}
+	}
+
+The checker tracks the local pointer variables of each function whose
+address is not taken and that are not referenced by function literals.
+Variables that are declared without a value and never assigned are not
+tracked: dereferencing such a variable is taken to be a deliberate crash.
+A variable is known to be nil or non-nil after it is assigned nil, the
+address of a variable, or the result of new, and on the branches of
+conditions that compare it with nil, following the short-circuit
+semantics of the && and || operators.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "nilness",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var g *cfg.CFG
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			g, body = cfgs.FuncDecl(n), n.Body
+		case *ast.FuncLit:
+			g, body = cfgs.FuncLit(n), n.Body
+		}
+		if g == nil {
+			return
+		}
+		c := &checker{pass: pass, tracked: trackedVars(pass, n, body), conds: make(map[ast.Expr]bool)}
+		if len(c.tracked) == 0 {
+			return
+		}
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.IfStmt:
+				c.conds[n.Cond] = true
+			case *ast.ForStmt:
+				if n.Cond != nil {
+					c.conds[n.Cond] = true
+				}
+			}
+			return true
+		})
+		c.checkFunc(g)
+	})
+	return nil, nil
+}
+
+// nilness is what is known about a pointer variable at some point.
+type nilness int
+
+const (
+	isnil nilness = iota
+	isnonnil
+)
+
+func (n nilness) String() string {
+	if n == isnil {
+		return "nil"
+	}
+	return "non-nil"
+}
+
+// A state maps each tracked variable whose nilness is known to it.
+type state map[*types.Var]nilness
+
+func (s state) copy() state {
+	t := make(state, len(s))
+	for v, n := range s {
+		t[v] = n
+	}
+	return t
+}
+
+// meet returns the facts that hold in both s and t.
+func meet(s, t state) state {
+	u := make(state)
+	for v, n := range s {
+		if m, ok := t[v]; ok && m == n {
+			u[v] = n
+		}
+	}
+	return u
+}
+
+type checker struct {
+	pass    *analysis.Pass
+	tracked map[*types.Var]bool
+	conds   map[ast.Expr]bool // conditions of if and for statements
+}
+
+// trackedVars returns the pointer variables declared by the function fn
+// with the given body whose nilness can be determined from its body alone.
+func trackedVars(pass *analysis.Pass, fn ast.Node, body *ast.BlockStmt) map[*types.Var]bool {
+	vars := make(map[*types.Var]bool)
+	for id, obj := range pass.TypesInfo.Defs {
+		v, ok := obj.(*types.Var)
+		if !ok || v.IsField() || id.Pos() < fn.Pos() || id.Pos() >= fn.End() {
+			continue
+		}
+		if _, ok := v.Type().Underlying().(*types.Pointer); ok {
+			vars[v] = true
+		}
+	}
+
+	// Exclude variables whose address is taken or that are
+	// referenced by a function literal, including the variables
+	// declared in nested function literals, which are analyzed
+	// on their own.
+	//
+	// Also exclude variables that are declared without a value and
+	// never assigned. They are always nil, and dereferencing one,
+	// as in
+	//
+	//	var x *int
+	//	*x = 0
+	//
+	// is the usual way to crash on purpose.
+	unset := make(map[*types.Var]bool)
+	assigned := make(map[*types.Var]bool)
+	setAssigned := func(e ast.Expr) {
+		if id, ok := unparen(e).(*ast.Ident); ok {
+			if v := objectOf(pass, id); v != nil {
+				assigned[v] = true
+			}
+		}
+	}
+	var inspect func(n ast.Node, inLit bool)
+	inspect = func(n ast.Node, inLit bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if !inLit {
+					inspect(n, true)
+					return false
+				}
+			case *ast.ValueSpec:
+				for _, id := range n.Names {
+					if v := objectOf(pass, id); v != nil && len(n.Values) == 0 {
+						unset[v] = true
+					}
+				}
+			case *ast.AssignStmt:
+				for _, e := range n.Lhs {
+					setAssigned(e)
+				}
+			case *ast.RangeStmt:
+				if n.Key != nil {
+					setAssigned(n.Key)
+				}
+				if n.Value != nil {
+					setAssigned(n.Value)
+				}
+			case *ast.UnaryExpr:
+				if id, ok := unparen(n.X).(*ast.Ident); ok && n.Op == token.AND {
+					delete(vars, objectOf(pass, id))
+				}
+			case *ast.Ident:
+				if inLit {
+					delete(vars, objectOf(pass, n))
+				}
+			}
+			return true
+		})
+	}
+	inspect(body, false)
+	for v := range unset {
+		if !assigned[v] {
+			delete(vars, v)
+		}
+	}
+	return vars
+}
+
+func objectOf(pass *analysis.Pass, id *ast.Ident) *types.Var {
+	v, _ := pass.TypesInfo.ObjectOf(id).(*types.Var)
+	return v
+}
+
+// checkFunc computes the nilness of the tracked variables at the start
+// of each block of g and then reports the problems in each block.
+func (c *checker) checkFunc(g *cfg.CFG) {
+	in := make([]state, len(g.Blocks)) // nil for blocks not yet reached
+	in[0] = make(state)
+	work := []*cfg.Block{g.Blocks[0]}
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+		s := in[b.Index].copy()
+		for _, n := range b.Nodes {
+			c.transfer(s, n)
+		}
+		cond := c.cond(b)
+		for i, succ := range b.Succs {
+			out := s
+			if cond != nil {
+				out = s.copy()
+				if !c.refine(out, cond, i == 0) {
+					// The edge is infeasible.
+					continue
+				}
+			}
+			old := in[succ.Index]
+			if old == nil {
+				in[succ.Index] = out.copy()
+				work = append(work, succ)
+			} else if u := meet(old, out); len(u) != len(old) {
+				in[succ.Index] = u
+				work = append(work, succ)
+			}
+		}
+	}
+
+	for _, b := range g.Blocks {
+		if in[b.Index] == nil {
+			continue
+		}
+		s := in[b.Index].copy()
+		for _, n := range b.Nodes {
+			c.check(s, n)
+			c.transfer(s, n)
+		}
+	}
+}
+
+// cond returns the condition that selects between the two successors
+// of b, or nil.
+func (c *checker) cond(b *cfg.Block) ast.Expr {
+	if len(b.Succs) != 2 || len(b.Nodes) == 0 {
+		return nil
+	}
+	if e, ok := b.Nodes[len(b.Nodes)-1].(ast.Expr); ok && c.conds[e] {
+		return e
+	}
+	return nil
+}
+
+// transfer updates s for the assignments made by the node n.
+func (c *checker) transfer(s state, n ast.Node) {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if len(n.Lhs) == len(n.Rhs) {
+			c.assign(s, n.Lhs, n.Rhs)
+		} else {
+			c.assign(s, n.Lhs, nil)
+		}
+	case *ast.ValueSpec:
+		if len(n.Values) == 0 {
+			for _, id := range n.Names {
+				if v := c.trackedVar(id); v != nil {
+					s[v] = isnil
+				}
+			}
+			break
+		}
+		lhs := make([]ast.Expr, len(n.Names))
+		for i, id := range n.Names {
+			lhs[i] = id
+		}
+		if len(n.Names) == len(n.Values) {
+			c.assign(s, lhs, n.Values)
+		} else {
+			c.assign(s, lhs, nil)
+		}
+	case *ast.Ident:
+		// The key or value of a range statement.
+		if v := c.trackedVar(n); v != nil {
+			delete(s, v)
+		}
+	}
+}
+
+// assign updates s for the assignment of rhs to lhs.
+// If rhs is nil, the values assigned are unknown.
+func (c *checker) assign(s state, lhs, rhs []ast.Expr) {
+	known := make([]bool, len(lhs))
+	values := make([]nilness, len(lhs))
+	if rhs != nil {
+		for i, e := range rhs {
+			values[i], known[i] = c.value(s, e)
+		}
+	}
+	for i, e := range lhs {
+		id, ok := unparen(e).(*ast.Ident)
+		if !ok {
+			continue
+		}
+		if v := c.trackedVar(id); v != nil {
+			if known[i] {
+				s[v] = values[i]
+			} else {
+				delete(s, v)
+			}
+		}
+	}
+}
+
+// value reports the nilness of the value of e, if known.
+func (c *checker) value(s state, e ast.Expr) (nilness, bool) {
+	e = unparen(e)
+	if c.pass.TypesInfo.Types[e].IsNil() {
+		return isnil, true
+	}
+	switch e := e.(type) {
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return isnonnil, true
+		}
+	case *ast.CallExpr:
+		if id, ok := unparen(e.Fun).(*ast.Ident); ok {
+			if _, ok := c.pass.TypesInfo.Uses[id].(*types.Builtin); ok && id.Name == "new" {
+				return isnonnil, true
+			}
+		}
+	case *ast.Ident:
+		if v := c.trackedVar(e); v != nil {
+			n, ok := s[v]
+			return n, ok
+		}
+	}
+	return 0, false
+}
+
+// refine adds to s the facts implied by cond having the value truth.
+// It reports whether that is consistent with s.
+func (c *checker) refine(s state, cond ast.Expr, truth bool) bool {
+	switch e := unparen(cond).(type) {
+	case *ast.UnaryExpr:
+		if e.Op == token.NOT {
+			return c.refine(s, e.X, !truth)
+		}
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.LAND, token.LOR:
+			if truth == (e.Op == token.LAND) {
+				return c.refine(s, e.X, truth) && c.refine(s, e.Y, truth)
+			}
+		case token.EQL, token.NEQ:
+			if v := c.nilComparison(e); v != nil {
+				n := isnonnil
+				if (e.Op == token.EQL) == truth {
+					n = isnil
+				}
+				if old, ok := s[v]; ok && old != n {
+					return false
+				}
+				s[v] = n
+			}
+		}
+	}
+	return true
+}
+
+// nilComparison returns the tracked variable compared with nil by e, or nil.
+func (c *checker) nilComparison(e *ast.BinaryExpr) *types.Var {
+	x, y := e.X, e.Y
+	if c.pass.TypesInfo.Types[x].IsNil() {
+		x, y = y, x
+	}
+	if !c.pass.TypesInfo.Types[y].IsNil() {
+		return nil
+	}
+	if id, ok := unparen(x).(*ast.Ident); ok {
+		return c.trackedVar(id)
+	}
+	return nil
+}
+
+// check reports the nil dereferences and degenerate nil comparisons
+// in n, given the facts s that hold before it.
+func (c *checker) check(s state, n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			// The operands of calls such as unsafe.Sizeof(*p)
+			// and len(*p) with constant results are not evaluated.
+			if c.pass.TypesInfo.Types[n].Value != nil {
+				return false
+			}
+		case *ast.BinaryExpr:
+			switch n.Op {
+			case token.LAND, token.LOR:
+				// Check the right operand only under the facts
+				// that hold when it is evaluated.
+				c.check(s, n.X)
+				t := s.copy()
+				if c.refine(t, n.X, n.Op == token.LAND) {
+					c.check(t, n.Y)
+				}
+				return false
+			case token.EQL, token.NEQ:
+				if v := c.nilComparison(n); v != nil {
+					if old, ok := s[v]; ok {
+						adj := "impossible"
+						if (old == isnil) == (n.Op == token.EQL) {
+							adj = "tautological"
+						}
+						c.pass.Reportf(n.Pos(), "%s condition: %s %s nil", adj, old, n.Op)
+					}
+				}
+			}
+		case *ast.StarExpr:
+			if c.isNil(s, n.X) {
+				c.pass.Reportf(n.Pos(), "nil dereference in load")
+			}
+		case *ast.SelectorExpr:
+			if sel := c.pass.TypesInfo.Selections[n]; sel != nil && sel.Kind() == types.FieldVal && c.isNil(s, n.X) {
+				c.pass.Reportf(n.Pos(), "nil dereference in field selection")
+			}
+		case *ast.IndexExpr:
+			if c.isNil(s, n.X) {
+				c.pass.Reportf(n.Pos(), "nil dereference in index operation")
+			}
+		}
+		return true
+	})
+}
+
+// isNil reports whether e is a tracked variable known to be nil.
+func (c *checker) isNil(s state, e ast.Expr) bool {
+	id, ok := unparen(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	n, ok := s[c.trackedVar(id)]
+	return ok && n == isnil
+}
+
+// trackedVar returns the tracked variable denoted by id, or nil.
+func (c *checker) trackedVar(id *ast.Ident) *types.Var {
+	if v := objectOf(c.pass, id); v != nil && c.tracked[v] {
+		return v
+	}
+	return nil
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package stringintconv defines an Analyzer that flags type
+// conversions from integers to strings.
+package stringintconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check for string(int) conversions
+
+This checker flags conversions of the form string(x) where x is an integer
+(but not byte or rune) type. Such a conversion yields the UTF-8 encoding
+of the code point x, not the decimal representation of x, which is
+rarely what was intended. Use string(rune(x)) if the code point is
+wanted, and strconv.Itoa or fmt.Sprint otherwise.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "stringintconv",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.CallExpr)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+
+		// Only want conversions to a string type.
+		if len(call.Args) != 1 {
+			return
+		}
+		tv, ok := pass.TypesInfo.Types[call.Fun]
+		if !ok || !tv.IsType() {
+			return
+		}
+		T := tv.Type
+		if !isBasic(T.Underlying(), types.IsString) {
+			return
+		}
+
+		// Only want conversions from typed integers,
+		// other than the byte and rune aliases.
+		arg := call.Args[0]
+		V := pass.TypesInfo.TypeOf(arg)
+		if V == nil || !isBasic(V.Underlying(), types.IsInteger) {
+			return
+		}
+		if b, ok := V.(*types.Basic); ok && (b == byteType || b == runeType || b.Info()&types.IsUntyped != 0) {
+			return
+		}
+
+		qf := types.RelativeTo(pass.Pkg)
+		diag := analysis.Diagnostic{
+			Pos: call.Pos(),
+			Message: fmt.Sprintf("conversion from %s to %s yields a string of one rune, not a string of digits (did you mean fmt.Sprint(x)?)",
+				types.TypeString(V, qf), types.TypeString(T, qf)),
+		}
+
+		// Suggest making the existing behavior explicit with
+		// string(rune(x)), provided rune is not shadowed.
+		if scope := pass.Pkg.Scope().Innermost(call.Pos()); scope != nil {
+			if _, obj := scope.LookupParent("rune", call.Pos()); obj == types.Universe.Lookup("rune") {
+				diag.SuggestedFixes = []analysis.SuggestedFix{{
+					Message: "Convert a single rune to a string",
+					TextEdits: []analysis.TextEdit{
+						{Pos: arg.Pos(), End: arg.Pos(), NewText: []byte("rune(")},
+						{Pos: arg.End(), End: arg.End(), NewText: []byte(")")},
+					},
+				}}
+			}
+		}
+		pass.Report(diag)
+	})
+	return nil, nil
+}
+
+var (
+	byteType = types.Universe.Lookup("byte").Type()
+	runeType = types.Universe.Lookup("rune").Type()
+)
+
+// isBasic reports whether t is a basic type with the given property.
+func isBasic(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Info()&info != 0
+}
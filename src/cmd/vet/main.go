@@ -1,8 +1,15 @@
 package main
 
 import (
+	"os"
+	"strings"
+
 	"cmd/internal/objabi"
+	"cmd/vet/internal/passes/ifaceassert"
+	"cmd/vet/internal/passes/nilness"
+	"cmd/vet/internal/passes/stringintconv"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/unitchecker"
 
 	"golang.org/x/tools/go/analysis/passes/asmdecl"
@@ -32,7 +39,7 @@ import (
 func main() {
 	objabi.AddVersionFlag()
 
-	unitchecker.Main(
+	analyzers := []*analysis.Analyzer{
 		asmdecl.Analyzer,
 		assign.Analyzer,
 		atomic.Analyzer,
@@ -43,9 +50,11 @@ func main() {
 		copylock.Analyzer,
 		errorsas.Analyzer,
 		httpresponse.Analyzer,
+		ifaceassert.Analyzer,
 		loopclosure.Analyzer,
 		lostcancel.Analyzer,
 		nilfunc.Analyzer,
+		nilness.Analyzer,
 		printf.Analyzer,
 		shift.Analyzer,
 		stdmethods.Analyzer,
@@ -55,5 +64,31 @@ func main() {
 		unreachable.Analyzer,
 		unsafeptr.Analyzer,
 		unusedresult.Analyzer,
-	)
+	}
+
+	// The stringintconv check is not run by default: vendored packages
+	// in the standard library still contain the conversions it reports,
+	// and they can only be fixed upstream. It runs when it is named on
+	// the command line, as in "go vet -stringintconv".
+	if named(stringintconv.Analyzer) {
+		analyzers = append(analyzers, stringintconv.Analyzer)
+	}
+
+	unitchecker.Main(withFixes(analyzers)...)
+}
+
+// named reports whether the command line names the analyzer a with a
+// flag such as -NAME=false or -NAME.flag, or asks for the descriptions
+// of all flags or analyzers.
+func named(a *analysis.Analyzer) bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "help" {
+			return true
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == "flags" || arg == a.Name || strings.HasPrefix(arg, a.Name+"=") || strings.HasPrefix(arg, a.Name+".") {
+			return true
+		}
+	}
+	return false
 }
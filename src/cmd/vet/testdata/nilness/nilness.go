@@ -0,0 +1,109 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the nilness checker.
+
+package nilness
+
+import "unsafe"
+
+type T struct{ f int }
+
+func Deref(p *int, t *T, a *[4]int) {
+	if p == nil {
+		print(*p) // ERROR "nil dereference in load"
+	}
+	if t == nil {
+		print(t.f) // ERROR "nil dereference in field selection"
+		return
+	}
+	print(t.f)
+	if a != nil {
+		print(a[0])
+	} else {
+		print(a[1]) // ERROR "nil dereference in index operation"
+	}
+	if p != nil && *p > 0 {
+		print(*p)
+	}
+	if p == nil || *p > 0 {
+		print(p)
+	}
+	if p == nil && *p > 0 { // ERROR "nil dereference in load"
+		print(p)
+	}
+}
+
+func Assign() {
+	var p *int
+	*p = 1 // ERROR "nil dereference in load"
+
+	q := new(int)
+	*q = 2
+	p = q
+	*p = 3
+
+	var r *T
+	if r != nil { // ERROR "impossible condition: nil != nil"
+		print(r.f)
+	}
+	r = &T{}
+	if r == nil { // ERROR "impossible condition: non-nil == nil"
+		print(r.f)
+	}
+	if r != nil { // ERROR "tautological condition: non-nil != nil"
+		print(r.f)
+	}
+	_ = unsafe.Sizeof(*p)
+}
+
+func Branches(p *int) {
+	if p != nil {
+		if p == nil { // ERROR "impossible condition: non-nil == nil"
+			print(*p)
+		}
+	}
+	if p == nil {
+		p = new(int)
+	}
+	print(*p)
+	if !(p != nil) { // ERROR "tautological condition: non-nil != nil"
+		return
+	}
+}
+
+func Loop(t *T, next func(*T) *T) {
+	for t != nil {
+		print(t.f)
+		t = next(t)
+	}
+	print(t.f) // ERROR "nil dereference in field selection"
+}
+
+func Unknown(p *int, f func()) {
+	var q *int
+	f = func() { q = p }
+	f()
+	print(*q)
+
+	var r *int
+	setInt(&r)
+	print(*r)
+
+	if p != nil {
+		return
+	}
+	p = get()
+	print(*p)
+}
+
+func Crash() {
+	// A deliberate crash.
+	var x *int
+	*x = 0
+}
+
+func setInt(pp **int) { *pp = new(int) }
+
+func get() *int { return new(int) }
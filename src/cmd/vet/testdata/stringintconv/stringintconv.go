@@ -0,0 +1,35 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the stringintconv checker.
+
+package stringintconv
+
+type Code int
+
+type Name string
+
+func StringTest() {
+	var (
+		i    int
+		j    rune
+		k    byte
+		u    uint64
+		c    Code
+		n    Name
+		bs   []byte
+		char = 'x'
+	)
+	_ = string(i) // ERROR "conversion from int to string yields a string of one rune"
+	_ = string(j)
+	_ = string(k)
+	_ = string(u) // ERROR "conversion from uint64 to string yields a string of one rune"
+	_ = string(c) // ERROR "conversion from Code to string yields a string of one rune"
+	_ = Name(i)   // ERROR "conversion from int to Name yields a string of one rune"
+	_ = string(rune(i))
+	_ = string(0x263a)
+	_ = string(char)
+	_ = string(n)
+	_ = string(bs)
+}
@@ -89,12 +89,15 @@ func TestVet(t *testing.T) {
 		"copylock",
 		"deadcode",
 		"httpresponse",
+		"ifaceassert",
 		"lostcancel",
 		"method",
 		"nilfunc",
+		"nilness",
 		"print",
 		"rangeloop",
 		"shift",
+		"stringintconv",
 		"structtag",
 		"testingpkg",
 		// "testtag" has its own test
@@ -113,6 +116,11 @@ func TestVet(t *testing.T) {
 
 			cmd := vetCmd(t, "-printfuncs=Warn,Warnf", pkg)
 
+			// stringintconv does not run by default.
+			if pkg == "stringintconv" {
+				cmd = vetCmd(t, "-stringintconv", pkg)
+			}
+
 			// The asm test assumes amd64.
 			if pkg == "asm" {
 				cmd.Env = append(cmd.Env, "GOOS=linux", "GOARCH=amd64")
@@ -0,0 +1,215 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Check that PublicKey and PrivateKey implement the interfaces documented in
+// crypto.PublicKey and crypto.PrivateKey.
+var _ interface {
+	Equal(x crypto.PublicKey) bool
+} = &ecdh.PublicKey{}
+var _ interface {
+	Public() crypto.PublicKey
+	Equal(x crypto.PrivateKey) bool
+} = &ecdh.PrivateKey{}
+
+var curves = []ecdh.Curve{ecdh.P256(), ecdh.P384(), ecdh.P521(), ecdh.X25519()}
+
+func TestECDH(t *testing.T) {
+	for _, curve := range curves {
+		curve := curve
+		t.Run(fmt.Sprint(curve), func(t *testing.T) {
+			aliceKey, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bobKey, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			alicePubKey, err := curve.NewPublicKey(aliceKey.PublicKey().Bytes())
+			if err != nil {
+				t.Error(err)
+			}
+			if !alicePubKey.Equal(aliceKey.PublicKey()) {
+				t.Error("encoded and decoded public keys are different")
+			}
+			if !alicePubKey.Equal(aliceKey.Public()) {
+				t.Error("encoded and decoded public keys are different")
+			}
+
+			alicePrivKey, err := curve.NewPrivateKey(aliceKey.Bytes())
+			if err != nil {
+				t.Error(err)
+			}
+			if !alicePrivKey.Equal(aliceKey) {
+				t.Error("encoded and decoded private keys are different")
+			}
+			if alicePrivKey.Equal(bobKey) {
+				t.Error("different private keys are equal")
+			}
+
+			bobSecret, err := bobKey.ECDH(aliceKey.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			aliceSecret, err := aliceKey.ECDH(bobKey.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bobSecret, aliceSecret) {
+				t.Error("two ECDH computations came out different")
+			}
+
+			if curve != ecdh.X25519() {
+				if _, err := aliceKey.ECDH(mustGenerate(t, ecdh.X25519()).PublicKey()); err == nil {
+					t.Error("ECDH succeeded with keys on different curves")
+				}
+			}
+		})
+	}
+}
+
+func mustGenerate(t *testing.T, curve ecdh.Curve) *ecdh.PrivateKey {
+	t.Helper()
+	k, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func hexDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal("invalid hex string:", s)
+	}
+	return b
+}
+
+var vectors = map[ecdh.Curve]struct {
+	PrivateKey, PublicKey string
+	PeerPublicKey         string
+	SharedSecret          string
+}{
+	// RFC 5903, Section 8.1
+	ecdh.P256(): {
+		PrivateKey: "c88f01f510d9ac3f70a292daa2316de544e9aab8afe84049c62a9c57862d1433",
+		PublicKey: "04dad0b65394221cf9b051e1feca5787d098dfe637fc90b9ef945d0c3772581180" +
+			"5271a0461cdb8252d61f1c456fa3e59ab1f45b33accf5f58389e0577b8990bb3",
+		PeerPublicKey: "04d12dfb5289c8d4f81208b70270398c342296970a0bccb74c736fc7554494bf63" +
+			"56fbf3ca366cc23e8157854c13c58d6aac23f046ada30f8353e74f33039872ab",
+		SharedSecret: "d6840f6b42f6edafd13116e0e12565202fef8e9ece7dce03812464d04b9442de",
+	},
+	// RFC 7748, Section 6.1
+	ecdh.X25519(): {
+		PrivateKey:    "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a",
+		PublicKey:     "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a",
+		PeerPublicKey: "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f",
+		SharedSecret:  "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742",
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for curve, v := range vectors {
+		curve, v := curve, v
+		t.Run(fmt.Sprint(curve), func(t *testing.T) {
+			key, err := curve.NewPrivateKey(hexDecode(t, v.PrivateKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(key.PublicKey().Bytes()); got != v.PublicKey {
+				t.Errorf("public key: got %s, want %s", got, v.PublicKey)
+			}
+			peer, err := curve.NewPublicKey(hexDecode(t, v.PeerPublicKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret, err := key.ECDH(peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(secret); got != v.SharedSecret {
+				t.Errorf("shared secret: got %s, want %s", got, v.SharedSecret)
+			}
+		})
+	}
+}
+
+func TestInvalidPrivateKeys(t *testing.T) {
+	// The order of each NIST curve, which is the first invalid scalar.
+	orders := map[ecdh.Curve]string{
+		ecdh.P256(): "ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551",
+		ecdh.P384(): "ffffffffffffffffffffffffffffffffffffffffffffffffc7634d81f4372ddf" +
+			"581a0db248b0a77aecec196accc52973",
+		ecdh.P521(): "01ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+			"fa51868783bf2f966b7fcc0148f709a5d03bb5c9b8899c47aebb6fb71e91386409",
+	}
+	for curve, order := range orders {
+		n := hexDecode(t, order)
+		if _, err := curve.NewPrivateKey(n); err == nil {
+			t.Errorf("%v: NewPrivateKey accepted the curve order", curve)
+		}
+		if _, err := curve.NewPrivateKey(make([]byte, len(n))); err == nil {
+			t.Errorf("%v: NewPrivateKey accepted zero", curve)
+		}
+		n[len(n)-1]--
+		if _, err := curve.NewPrivateKey(n); err != nil {
+			t.Errorf("%v: NewPrivateKey rejected the largest scalar: %v", curve, err)
+		}
+		if _, err := curve.NewPrivateKey(n[1:]); err == nil {
+			t.Errorf("%v: NewPrivateKey accepted a short key", curve)
+		}
+	}
+	if _, err := ecdh.X25519().NewPrivateKey(make([]byte, 31)); err == nil {
+		t.Error("X25519: NewPrivateKey accepted a short key")
+	}
+}
+
+func TestInvalidPublicKeys(t *testing.T) {
+	for _, curve := range curves {
+		valid := mustGenerate(t, curve).PublicKey().Bytes()
+		if _, err := curve.NewPublicKey(valid[1:]); err == nil {
+			t.Errorf("%v: NewPublicKey accepted a short key", curve)
+		}
+		if curve == ecdh.X25519() {
+			continue
+		}
+		invalid := map[string][]byte{
+			"infinity":   {0},
+			"compressed": append([]byte{2 | valid[len(valid)-1]&1}, valid[1:len(valid)/2+1]...),
+			"off curve":  append(append([]byte{}, valid[:len(valid)-1]...), valid[len(valid)-1]^1),
+		}
+		for name, key := range invalid {
+			if _, err := curve.NewPublicKey(key); err == nil {
+				t.Errorf("%v: NewPublicKey accepted %s point", curve, name)
+			}
+		}
+	}
+}
+
+func TestX25519LowOrderPoint(t *testing.T) {
+	key := mustGenerate(t, ecdh.X25519())
+	peer, err := ecdh.X25519().NewPublicKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = key.ECDH(peer)
+	if err == nil || !strings.Contains(err.Error(), "low order") {
+		t.Errorf("expected a low order point error, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"crypto/elliptic"
+	"crypto/internal/randutil"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// nistCurve implements a NIST curve. Keys are validated with crypto/elliptic,
+// which only ever handles public values in variable time, while the scalar
+// multiplications are performed by ops, which must be constant-time.
+type nistCurve struct {
+	name  string
+	curve func() elliptic.Curve
+	ops   nistOps
+}
+
+// nistOps performs the scalar multiplications of a NIST curve on encoded,
+// validated points and scalars.
+type nistOps interface {
+	// scalarBaseMult returns the uncompressed encoding of scalar * G.
+	scalarBaseMult(scalar []byte) []byte
+	// ecdh returns the encoded x-coordinate of scalar * point, or an error
+	// if the result is the point at infinity.
+	ecdh(scalar, point []byte) ([]byte, error)
+}
+
+// ellipticOps implements nistOps with crypto/elliptic, for the curves whose
+// crypto/elliptic implementation is constant-time.
+type ellipticOps struct {
+	curve func() elliptic.Curve
+}
+
+func (o ellipticOps) scalarBaseMult(scalar []byte) []byte {
+	x, y := o.curve().ScalarBaseMult(scalar)
+	return elliptic.Marshal(o.curve(), x, y)
+}
+
+func (o ellipticOps) ecdh(scalar, point []byte) ([]byte, error) {
+	x, y := elliptic.Unmarshal(o.curve(), point)
+	if x == nil {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	x, y = o.curve().ScalarMult(x, y, scalar)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.New("crypto/ecdh: ECDH result is the point at infinity")
+	}
+	// The shared secret is the x-coordinate, encoded as a fixed length
+	// big endian integer as specified in SEC 1, Version 2.0, Section 2.3.5.
+	shared := make([]byte, (o.curve().Params().BitSize+7)/8)
+	xBytes := x.Bytes()
+	copy(shared[len(shared)-len(xBytes):], xBytes)
+	return shared, nil
+}
+
+func (c *nistCurve) String() string {
+	return c.name
+}
+
+// scalarSize returns the length of the fixed size encoding of a scalar.
+func (c *nistCurve) scalarSize() int {
+	return (c.curve().Params().N.BitLen() + 7) / 8
+}
+
+// pointSize returns the length of the encoding of an uncompressed point.
+func (c *nistCurve) pointSize() int {
+	return 1 + 2*((c.curve().Params().BitSize+7)/8)
+}
+
+var errInvalidPrivateKey = errors.New("crypto/ecdh: invalid private key")
+
+func (c *nistCurve) GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	key := make([]byte, c.scalarSize())
+	randutil.MaybeReadByte(rand)
+	for {
+		if _, err := io.ReadFull(rand, key); err != nil {
+			return nil, err
+		}
+
+		// Mask off any excess bits if the size of the underlying field is not
+		// a whole number of bytes, which is only the case for P-521.
+		if excess := len(key)*8 - c.curve().Params().N.BitLen(); excess > 0 {
+			key[0] &= 0xff >> uint(excess)
+		}
+
+		// This is a rejection sampling loop: for P-521 it has a ~50%
+		// chance of failing, for the other curves it almost never does.
+		k, err := c.NewPrivateKey(key)
+		if err == errInvalidPrivateKey {
+			continue
+		}
+		return k, err
+	}
+}
+
+func (c *nistCurve) NewPrivateKey(key []byte) (*PrivateKey, error) {
+	if len(key) != c.scalarSize() {
+		return nil, errors.New("crypto/ecdh: invalid private key size")
+	}
+	k := new(big.Int).SetBytes(key)
+	if k.Sign() == 0 || k.Cmp(c.curve().Params().N) >= 0 {
+		return nil, errInvalidPrivateKey
+	}
+	return &PrivateKey{
+		curve:      c,
+		privateKey: append([]byte{}, key...),
+	}, nil
+}
+
+func (c *nistCurve) privateKeyToPublicKey(key *PrivateKey) *PublicKey {
+	if key.curve != c {
+		panic("crypto/ecdh: internal error: converting the wrong key type")
+	}
+	return &PublicKey{
+		curve:     c,
+		publicKey: c.ops.scalarBaseMult(key.privateKey),
+	}
+}
+
+func (c *nistCurve) NewPublicKey(key []byte) (*PublicKey, error) {
+	// Reject the point at infinity and compressed encodings.
+	if len(key) != c.pointSize() || key[0] != 4 {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	// Unmarshal checks that the point is on the curve.
+	if x, _ := elliptic.Unmarshal(c.curve(), key); x == nil {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	return &PublicKey{
+		curve:     c,
+		publicKey: append([]byte{}, key...),
+	}, nil
+}
+
+func (c *nistCurve) ecdh(local *PrivateKey, remote *PublicKey) ([]byte, error) {
+	// SEC 1, Version 2.0, Section 3.3.1 requires the result not to be the
+	// point at infinity. The curves have prime order and the private and
+	// public keys are validated, so that can't happen.
+	return c.ops.ecdh(local.privateKey, remote.publicKey)
+}
+
+// P256 returns a Curve which implements NIST P-256 (FIPS 186-3, section D.2.3),
+// also known as secp256r1 or prime256v1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P256() Curve { return p256 }
+
+var p256 = &nistCurve{"P-256", elliptic.P256, ellipticOps{elliptic.P256}}
+
+// P384 returns a Curve which implements NIST P-384 (FIPS 186-3, section D.2.4),
+// also known as secp384r1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P384() Curve { return p384 }
+
+var p384 = &nistCurve{"P-384", elliptic.P384, &nistec{curve: elliptic.P384}}
+
+// P521 returns a Curve which implements NIST P-521 (FIPS 186-3, section D.2.5),
+// also known as secp521r1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P521() Curve { return p521 }
+
+var p521 = &nistCurve{"P-521", elliptic.P521, &nistec{curve: elliptic.P521}}
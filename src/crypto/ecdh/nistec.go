@@ -0,0 +1,471 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"crypto/elliptic"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+// This file implements constant-time scalar multiplication for the NIST
+// curves whose crypto/elliptic implementation uses variable-time big.Int
+// arithmetic, P-384 and P-521. It favors simplicity over speed: the field
+// arithmetic is generic over the number of limbs, and the point arithmetic
+// uses the complete projective formulas for short Weierstrass curves with
+// a = -3 from Renes, Costello and Batina, "Complete addition formulas for
+// prime order elliptic curves" (https://eprint.iacr.org/2015/1060).
+
+// maxLimbs is the number of 64-bit limbs of the largest field, P-521's.
+const maxLimbs = 9
+
+// A fieldElement is an integer modulo the prime of a field, in the
+// Montgomery domain, as little-endian 64-bit limbs. Only the first n limbs
+// of the field are used, the others are always zero.
+type fieldElement [maxLimbs]uint64
+
+// A field implements arithmetic modulo an odd prime p. The Montgomery
+// constant is R = 2^(64*n).
+//
+// The number of limbs and the exponents are public, everything else is
+// handled in constant time.
+type field struct {
+	n    int // number of limbs
+	size int // length of the fixed size big endian encoding
+	p    fieldElement
+	pInv uint64       // -p⁻¹ mod 2⁶⁴
+	rr   fieldElement // R² mod p, outside the Montgomery domain
+	one  fieldElement // R mod p, which is one in the Montgomery domain
+	pm2  []byte       // p - 2, the inversion exponent, big endian
+}
+
+func newField(p *big.Int) *field {
+	f := &field{size: (p.BitLen() + 7) / 8}
+	f.n = (p.BitLen() + 63) / 64
+	f.p = limbsFromBig(p)
+
+	// Newton's iteration doubles the number of correct low bits of the
+	// inverse each step, and p[0] is its own inverse modulo 8.
+	inv := f.p[0]
+	for i := 0; i < 5; i++ {
+		inv *= 2 - f.p[0]*inv
+	}
+	f.pInv = -inv
+
+	r := new(big.Int).Lsh(big.NewInt(1), uint(64*f.n))
+	f.one = limbsFromBig(new(big.Int).Mod(r, p))
+	f.rr = limbsFromBig(new(big.Int).Mod(new(big.Int).Mul(r, r), p))
+	f.pm2 = new(big.Int).Sub(p, big.NewInt(2)).Bytes()
+	return f
+}
+
+// limbsFromBig returns the limbs of x, which must fit in maxLimbs. It is
+// only used with public constants.
+func limbsFromBig(x *big.Int) fieldElement {
+	var l fieldElement
+	b := x.Bytes()
+	for i := range b {
+		l[i/8] |= uint64(b[len(b)-1-i]) << (8 * uint(i%8))
+	}
+	return l
+}
+
+// mul sets z = x * y / R mod p, using coarsely integrated operand scanning.
+func (f *field) mul(z, x, y *fieldElement) {
+	n := f.n
+	var t [maxLimbs + 2]uint64
+	for i := 0; i < n; i++ {
+		// t += x * y[i]
+		var c uint64
+		for j := 0; j < n; j++ {
+			hi, lo := bits.Mul64(x[j], y[i])
+			var cc uint64
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[n], c = bits.Add64(t[n], c, 0)
+		t[n+1] = c
+
+		// t = (t + m * p) / 2⁶⁴, where m makes the division exact.
+		m := t[0] * f.pInv
+		hi, lo := bits.Mul64(m, f.p[0])
+		_, cc := bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < n; j++ {
+			hi, lo := bits.Mul64(m, f.p[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[n-1], cc = bits.Add64(t[n], c, 0)
+		t[n] = t[n+1] + cc
+	}
+
+	// t < 2p, so a single conditional subtraction reduces it.
+	var r fieldElement
+	var b uint64
+	for j := 0; j < n; j++ {
+		r[j], b = bits.Sub64(t[j], f.p[j], b)
+	}
+	_, b = bits.Sub64(t[n], 0, b)
+	// If the subtraction borrowed, t was already reduced.
+	mask := -b
+	for j := 0; j < n; j++ {
+		z[j] = t[j]&mask | r[j]&^mask
+	}
+}
+
+// square sets z = x * x / R mod p.
+func (f *field) square(z, x *fieldElement) {
+	f.mul(z, x, x)
+}
+
+// add sets z = x + y mod p.
+func (f *field) add(z, x, y *fieldElement) {
+	var s, r fieldElement
+	var c, b uint64
+	for j := 0; j < f.n; j++ {
+		s[j], c = bits.Add64(x[j], y[j], c)
+	}
+	for j := 0; j < f.n; j++ {
+		r[j], b = bits.Sub64(s[j], f.p[j], b)
+	}
+	_, b = bits.Sub64(c, 0, b)
+	mask := -b
+	for j := 0; j < f.n; j++ {
+		z[j] = s[j]&mask | r[j]&^mask
+	}
+}
+
+// sub sets z = x - y mod p.
+func (f *field) sub(z, x, y *fieldElement) {
+	var d fieldElement
+	var b, c uint64
+	for j := 0; j < f.n; j++ {
+		d[j], b = bits.Sub64(x[j], y[j], b)
+	}
+	// If the subtraction borrowed, add p back.
+	mask := -b
+	for j := 0; j < f.n; j++ {
+		z[j], c = bits.Add64(d[j], f.p[j]&mask, c)
+	}
+}
+
+// invert sets z = 1 / x mod p, and z = 0 if x = 0.
+func (f *field) invert(z, x *fieldElement) {
+	// Fermat's little theorem: x⁻¹ = x^(p-2). The exponent is public, so it's
+	// fine to branch on its bits.
+	r := f.one
+	for _, b := range f.pm2 {
+		for i := 7; i >= 0; i-- {
+			f.square(&r, &r)
+			if b>>uint(i)&1 == 1 {
+				f.mul(&r, &r, x)
+			}
+		}
+	}
+	*z = r
+}
+
+// isZero returns 1 if x = 0, and 0 otherwise.
+func (f *field) isZero(x *fieldElement) int {
+	var acc uint64
+	for j := 0; j < f.n; j++ {
+		acc |= x[j]
+	}
+	return int(1 ^ (acc|-acc)>>63)
+}
+
+// equal returns 1 if x = y, and 0 otherwise.
+func (f *field) equal(x, y *fieldElement) int {
+	var d fieldElement
+	for j := 0; j < f.n; j++ {
+		d[j] = x[j] ^ y[j]
+	}
+	return f.isZero(&d)
+}
+
+// choose sets z = x if cond is 1, and z = y if cond is 0.
+func (f *field) choose(z, x, y *fieldElement, cond int) {
+	mask := -uint64(cond)
+	for j := 0; j < f.n; j++ {
+		z[j] = x[j]&mask | y[j]&^mask
+	}
+}
+
+// setBytes sets z to the big endian encoding b, converted to the Montgomery
+// domain. It returns an error if b is not the canonical encoding of a field
+// element.
+func (f *field) setBytes(z *fieldElement, b []byte) error {
+	if len(b) != f.size {
+		return errors.New("invalid field element length")
+	}
+	var l fieldElement
+	for i := range b {
+		l[i/8] |= uint64(b[len(b)-1-i]) << (8 * uint(i%8))
+	}
+	var bb uint64
+	for j := 0; j < f.n; j++ {
+		_, bb = bits.Sub64(l[j], f.p[j], bb)
+	}
+	if bb == 0 {
+		return errors.New("invalid field element encoding")
+	}
+	f.mul(z, &l, &f.rr)
+	return nil
+}
+
+// bytes returns the fixed size big endian encoding of x.
+func (f *field) bytes(x *fieldElement) []byte {
+	var l, one fieldElement
+	one[0] = 1
+	f.mul(&l, x, &one)
+	b := make([]byte, f.size)
+	for i := range b {
+		b[len(b)-1-i] = byte(l[i/8] >> (8 * uint(i%8)))
+	}
+	return b
+}
+
+// A projectivePoint is a point in projective coordinates (X:Y:Z), which
+// correspond to the affine point (X/Z, Y/Z). The point at infinity is (0:1:0).
+type projectivePoint struct {
+	x, y, z fieldElement
+}
+
+// nistec implements constant-time scalar multiplication on a NIST curve. It
+// is initialized on first use from the crypto/elliptic curve parameters.
+type nistec struct {
+	curve func() elliptic.Curve
+
+	once sync.Once
+	f    *field
+	b    fieldElement
+	g    projectivePoint
+}
+
+func (c *nistec) init() {
+	c.once.Do(func() {
+		params := c.curve().Params()
+		c.f = newField(params.P)
+		c.b = c.montgomery(params.B)
+		c.g = projectivePoint{
+			x: c.montgomery(params.Gx),
+			y: c.montgomery(params.Gy),
+			z: c.f.one,
+		}
+	})
+}
+
+// montgomery returns the public constant x in the Montgomery domain.
+func (c *nistec) montgomery(x *big.Int) fieldElement {
+	var z fieldElement
+	l := limbsFromBig(x)
+	c.f.mul(&z, &l, &c.f.rr)
+	return z
+}
+
+func (c *nistec) scalarBaseMult(scalar []byte) []byte {
+	c.init()
+	var q projectivePoint
+	c.scalarMult(&q, &c.g, scalar)
+	b, err := c.encode(&q)
+	if err != nil {
+		// The private key is validated to be in [1, N-1].
+		panic("crypto/ecdh: internal error: scalar base multiplication is the point at infinity")
+	}
+	return b
+}
+
+func (c *nistec) ecdh(scalar, point []byte) ([]byte, error) {
+	c.init()
+	p, err := c.decode(point)
+	if err != nil {
+		return nil, err
+	}
+	var q projectivePoint
+	c.scalarMult(&q, p, scalar)
+	b, err := c.encode(&q)
+	if err != nil {
+		return nil, err
+	}
+	return b[1 : 1+c.f.size], nil
+}
+
+// decode parses an uncompressed point and checks that it is on the curve.
+func (c *nistec) decode(b []byte) (*projectivePoint, error) {
+	f := c.f
+	if len(b) != 1+2*f.size || b[0] != 4 {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	p := &projectivePoint{z: f.one}
+	if f.setBytes(&p.x, b[1:1+f.size]) != nil || f.setBytes(&p.y, b[1+f.size:]) != nil {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+
+	// y² = x³ - 3x + b
+	var lhs, rhs, t fieldElement
+	f.square(&lhs, &p.y)
+	f.square(&rhs, &p.x)
+	f.mul(&rhs, &rhs, &p.x)
+	f.add(&t, &p.x, &p.x)
+	f.add(&t, &t, &p.x)
+	f.sub(&rhs, &rhs, &t)
+	f.add(&rhs, &rhs, &c.b)
+	if f.equal(&lhs, &rhs) != 1 {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	return p, nil
+}
+
+// encode returns the uncompressed encoding of p, or an error if p is the
+// point at infinity.
+func (c *nistec) encode(p *projectivePoint) ([]byte, error) {
+	f := c.f
+	if f.isZero(&p.z) == 1 {
+		return nil, errors.New("crypto/ecdh: ECDH result is the point at infinity")
+	}
+	var zinv, x, y fieldElement
+	f.invert(&zinv, &p.z)
+	f.mul(&x, &p.x, &zinv)
+	f.mul(&y, &p.y, &zinv)
+	b := make([]byte, 1, 1+2*f.size)
+	b[0] = 4
+	b = append(b, f.bytes(&x)...)
+	return append(b, f.bytes(&y)...), nil
+}
+
+// add sets q = p1 + p2. The points may overlap.
+func (c *nistec) add(q, p1, p2 *projectivePoint) {
+	// Algorithm 4 of the paper, for a = -3.
+	f := c.f
+	var t0, t1, t2, t3, t4, x3, y3, z3 fieldElement
+	f.mul(&t0, &p1.x, &p2.x) // t0 := X1 * X2
+	f.mul(&t1, &p1.y, &p2.y) // t1 := Y1 * Y2
+	f.mul(&t2, &p1.z, &p2.z) // t2 := Z1 * Z2
+	f.add(&t3, &p1.x, &p1.y) // t3 := X1 + Y1
+	f.add(&t4, &p2.x, &p2.y) // t4 := X2 + Y2
+	f.mul(&t3, &t3, &t4)     // t3 := t3 * t4
+	f.add(&t4, &t0, &t1)     // t4 := t0 + t1
+	f.sub(&t3, &t3, &t4)     // t3 := t3 - t4
+	f.add(&t4, &p1.y, &p1.z) // t4 := Y1 + Z1
+	f.add(&x3, &p2.y, &p2.z) // X3 := Y2 + Z2
+	f.mul(&t4, &t4, &x3)     // t4 := t4 * X3
+	f.add(&x3, &t1, &t2)     // X3 := t1 + t2
+	f.sub(&t4, &t4, &x3)     // t4 := t4 - X3
+	f.add(&x3, &p1.x, &p1.z) // X3 := X1 + Z1
+	f.add(&y3, &p2.x, &p2.z) // Y3 := X2 + Z2
+	f.mul(&x3, &x3, &y3)     // X3 := X3 * Y3
+	f.add(&y3, &t0, &t2)     // Y3 := t0 + t2
+	f.sub(&y3, &x3, &y3)     // Y3 := X3 - Y3
+	f.mul(&z3, &c.b, &t2)    // Z3 := b * t2
+	f.sub(&x3, &y3, &z3)     // X3 := Y3 - Z3
+	f.add(&z3, &x3, &x3)     // Z3 := X3 + X3
+	f.add(&x3, &x3, &z3)     // X3 := X3 + Z3
+	f.sub(&z3, &t1, &x3)     // Z3 := t1 - X3
+	f.add(&x3, &t1, &x3)     // X3 := t1 + X3
+	f.mul(&y3, &c.b, &y3)    // Y3 := b * Y3
+	f.add(&t1, &t2, &t2)     // t1 := t2 + t2
+	f.add(&t2, &t1, &t2)     // t2 := t1 + t2
+	f.sub(&y3, &y3, &t2)     // Y3 := Y3 - t2
+	f.sub(&y3, &y3, &t0)     // Y3 := Y3 - t0
+	f.add(&t1, &y3, &y3)     // t1 := Y3 + Y3
+	f.add(&y3, &t1, &y3)     // Y3 := t1 + Y3
+	f.add(&t1, &t0, &t0)     // t1 := t0 + t0
+	f.add(&t0, &t1, &t0)     // t0 := t1 + t0
+	f.sub(&t0, &t0, &t2)     // t0 := t0 - t2
+	f.mul(&t1, &t4, &y3)     // t1 := t4 * Y3
+	f.mul(&t2, &t0, &y3)     // t2 := t0 * Y3
+	f.mul(&y3, &x3, &z3)     // Y3 := X3 * Z3
+	f.add(&y3, &y3, &t2)     // Y3 := Y3 + t2
+	f.mul(&x3, &t3, &x3)     // X3 := t3 * X3
+	f.sub(&x3, &x3, &t1)     // X3 := X3 - t1
+	f.mul(&z3, &t4, &z3)     // Z3 := t4 * Z3
+	f.mul(&t1, &t3, &t0)     // t1 := t3 * t0
+	f.add(&z3, &z3, &t1)     // Z3 := Z3 + t1
+	q.x, q.y, q.z = x3, y3, z3
+}
+
+// double sets q = p + p. The points may overlap.
+func (c *nistec) double(q, p *projectivePoint) {
+	// Algorithm 6 of the paper, for a = -3.
+	f := c.f
+	var t0, t1, t2, t3, x3, y3, z3 fieldElement
+	f.square(&t0, &p.x)    // t0 := X ^ 2
+	f.square(&t1, &p.y)    // t1 := Y ^ 2
+	f.square(&t2, &p.z)    // t2 := Z ^ 2
+	f.mul(&t3, &p.x, &p.y) // t3 := X * Y
+	f.add(&t3, &t3, &t3)   // t3 := t3 + t3
+	f.mul(&z3, &p.x, &p.z) // Z3 := X * Z
+	f.add(&z3, &z3, &z3)   // Z3 := Z3 + Z3
+	f.mul(&y3, &c.b, &t2)  // Y3 := b * t2
+	f.sub(&y3, &y3, &z3)   // Y3 := Y3 - Z3
+	f.add(&x3, &y3, &y3)   // X3 := Y3 + Y3
+	f.add(&y3, &x3, &y3)   // Y3 := X3 + Y3
+	f.sub(&x3, &t1, &y3)   // X3 := t1 - Y3
+	f.add(&y3, &t1, &y3)   // Y3 := t1 + Y3
+	f.mul(&y3, &x3, &y3)   // Y3 := X3 * Y3
+	f.mul(&x3, &x3, &t3)   // X3 := X3 * t3
+	f.add(&t3, &t2, &t2)   // t3 := t2 + t2
+	f.add(&t2, &t2, &t3)   // t2 := t2 + t3
+	f.mul(&z3, &c.b, &z3)  // Z3 := b * Z3
+	f.sub(&z3, &z3, &t2)   // Z3 := Z3 - t2
+	f.sub(&z3, &z3, &t0)   // Z3 := Z3 - t0
+	f.add(&t3, &z3, &z3)   // t3 := Z3 + Z3
+	f.add(&z3, &z3, &t3)   // Z3 := Z3 + t3
+	f.add(&t3, &t0, &t0)   // t3 := t0 + t0
+	f.add(&t0, &t3, &t0)   // t0 := t3 + t0
+	f.sub(&t0, &t0, &t2)   // t0 := t0 - t2
+	f.mul(&t0, &t0, &z3)   // t0 := t0 * Z3
+	f.add(&y3, &y3, &t0)   // Y3 := Y3 + t0
+	f.mul(&t0, &p.y, &p.z) // t0 := Y * Z
+	f.add(&t0, &t0, &t0)   // t0 := t0 + t0
+	f.mul(&z3, &t0, &z3)   // Z3 := t0 * Z3
+	f.sub(&x3, &x3, &z3)   // X3 := X3 - Z3
+	f.mul(&z3, &t0, &t1)   // Z3 := t0 * t1
+	f.add(&z3, &z3, &z3)   // Z3 := Z3 + Z3
+	f.add(&z3, &z3, &z3)   // Z3 := Z3 + Z3
+	q.x, q.y, q.z = x3, y3, z3
+}
+
+// scalarMult sets q = scalar * p, where scalar is big endian, with a fixed
+// 4-bit window and a constant-time table lookup.
+func (c *nistec) scalarMult(q, p *projectivePoint, scalar []byte) {
+	f := c.f
+	var table [16]projectivePoint
+	table[0].y = f.one
+	table[1] = *p
+	for i := 2; i < 16; i++ {
+		c.add(&table[i], &table[i-1], p)
+	}
+
+	r := projectivePoint{y: f.one}
+	var t projectivePoint
+	for _, b := range scalar {
+		for _, w := range [2]byte{b >> 4, b & 0xf} {
+			c.double(&r, &r)
+			c.double(&r, &r)
+			c.double(&r, &r)
+			c.double(&r, &r)
+			t = projectivePoint{}
+			for j := range table {
+				cond := subtle.ConstantTimeByteEq(uint8(j), w)
+				f.choose(&t.x, &table[j].x, &t.x, cond)
+				f.choose(&t.y, &table[j].y, &t.y, cond)
+				f.choose(&t.z, &table[j].z, &t.z, cond)
+			}
+			c.add(&r, &r, &t)
+		}
+	}
+	*q = r
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// TestNistecMatchesElliptic checks the constant-time implementation against
+// the generic big.Int one in crypto/elliptic.
+func TestNistecMatchesElliptic(t *testing.T) {
+	for _, c := range []*nistCurve{p384, p521} {
+		ops := c.ops.(*nistec)
+		curve := c.curve()
+		n := curve.Params().N
+
+		one := big.NewInt(1)
+		scalars := []*big.Int{
+			one,
+			big.NewInt(2),
+			big.NewInt(15),
+			big.NewInt(16),
+			new(big.Int).Sub(n, one),
+			new(big.Int).Sub(n, big.NewInt(2)),
+		}
+		iters := 20
+		if testing.Short() {
+			iters = 5
+		}
+		for i := 0; i < iters; i++ {
+			k, err := rand.Int(rand.Reader, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scalars = append(scalars, k)
+		}
+
+		for _, k := range scalars {
+			scalar := make([]byte, c.scalarSize())
+			kb := k.Bytes()
+			copy(scalar[len(scalar)-len(kb):], kb)
+
+			x, y := curve.ScalarBaseMult(scalar)
+			if got, want := ops.scalarBaseMult(scalar), elliptic.Marshal(curve, x, y); !bytes.Equal(got, want) {
+				t.Errorf("%v: scalarBaseMult(%x) = %x, want %x", c, scalar, got, want)
+			}
+
+			px, py := curve.ScalarBaseMult(scalars[len(scalars)-1].Bytes())
+			point := elliptic.Marshal(curve, px, py)
+			x, _ = curve.ScalarMult(px, py, scalar)
+			got, err := ops.ecdh(scalar, point)
+			if err != nil {
+				t.Fatalf("%v: ecdh(%x): %v", c, scalar, err)
+			}
+			want := make([]byte, (curve.Params().BitSize+7)/8)
+			xb := x.Bytes()
+			copy(want[len(want)-len(xb):], xb)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%v: ecdh(%x) = %x, want %x", c, scalar, got, want)
+			}
+		}
+
+		// The order times a point is the point at infinity.
+		scalar := make([]byte, c.scalarSize())
+		nb := n.Bytes()
+		copy(scalar[len(scalar)-len(nb):], nb)
+		if _, err := ops.ecdh(scalar, ops.scalarBaseMult([]byte{1})); err == nil {
+			t.Errorf("%v: ecdh returned the point at infinity", c)
+		}
+	}
+}
+
+func TestNistecDouble(t *testing.T) {
+	for _, c := range []*nistCurve{p384, p521} {
+		ops := c.ops.(*nistec)
+		ops.init()
+		var p, d, a projectivePoint
+		ops.scalarMult(&p, &ops.g, []byte{7})
+		ops.double(&d, &p)
+		ops.add(&a, &p, &p)
+		da, _ := ops.encode(&d)
+		aa, _ := ops.encode(&a)
+		if !bytes.Equal(da, aa) {
+			t.Errorf("%v: double(P) = %x, P + P = %x", c, da, aa)
+		}
+	}
+}
+
+func TestNistecInvalidPoints(t *testing.T) {
+	for _, c := range []*nistCurve{p384, p521} {
+		ops := c.ops.(*nistec)
+		valid := ops.scalarBaseMult([]byte{3})
+		if _, err := ops.decode(valid); err != nil {
+			t.Errorf("%v: decode rejected a valid point: %v", c, err)
+		}
+
+		offCurve := append([]byte{}, valid...)
+		offCurve[len(offCurve)-1] ^= 1
+		// A coordinate equal to p is not a canonical encoding.
+		nonCanonical := append([]byte{}, valid...)
+		pb := c.curve().Params().P.Bytes()
+		copy(nonCanonical[1+ops.f.size-len(pb):], pb)
+		for _, p := range [][]byte{offCurve, nonCanonical, valid[1:], {0}} {
+			if _, err := ops.decode(p); err == nil {
+				t.Errorf("%v: decode accepted invalid point %x", c, p)
+			}
+		}
+	}
+}
@@ -365,3 +365,46 @@ func TestZeroHashSignature(t *testing.T) {
 		}
 	}
 }
+
+func TestECDH(t *testing.T) {
+	for _, c := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		priv, err := GenerateKey(c, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ecdhPriv, err := priv.ECDH()
+		if err != nil {
+			t.Fatalf("%s: %v", c.Params().Name, err)
+		}
+		ecdhPub, err := priv.PublicKey.ECDH()
+		if err != nil {
+			t.Fatalf("%s: %v", c.Params().Name, err)
+		}
+		if !ecdhPriv.PublicKey().Equal(ecdhPub) {
+			t.Errorf("%s: converted public keys don't match", c.Params().Name)
+		}
+		if x, y := elliptic.Unmarshal(c, ecdhPub.Bytes()); x.Cmp(priv.X) != 0 || y.Cmp(priv.Y) != 0 {
+			t.Errorf("%s: converted public key encodes a different point", c.Params().Name)
+		}
+		if new(big.Int).SetBytes(ecdhPriv.Bytes()).Cmp(priv.D) != 0 {
+			t.Errorf("%s: converted private key encodes a different scalar", c.Params().Name)
+		}
+	}
+
+	priv, err := GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := priv.ECDH(); err == nil {
+		t.Error("converted a P-224 key, which is not supported by crypto/ecdh")
+	}
+
+	priv, err = GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv.X = new(big.Int).Add(priv.X, big.NewInt(1))
+	if _, err := priv.PublicKey.ECDH(); err == nil {
+		t.Error("converted an invalid public key")
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke_test
+
+import (
+	"crypto/hpke"
+	"crypto/rand"
+	"fmt"
+)
+
+func Example() {
+	suite := hpke.Suite{
+		KEM:  hpke.DHKEM_X25519_HKDF_SHA256,
+		KDF:  hpke.KDF_HKDF_SHA256,
+		AEAD: hpke.AEAD_ChaCha20Poly1305,
+	}
+	info := []byte("example application")
+
+	// The recipient generates a key pair and publishes the public key.
+	publicKey, privateKey, err := suite.KEM.GenerateKeyPair(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	// The sender encrypts a message to the public key, and sends the
+	// encapsulated key along with the ciphertext.
+	enc, sender, err := suite.SetupBaseSender(rand.Reader, publicKey, info)
+	if err != nil {
+		panic(err)
+	}
+	ciphertext, err := sender.Seal(nil, []byte("hello, world"))
+	if err != nil {
+		panic(err)
+	}
+
+	// The recipient uses its private key and the encapsulated key to set up
+	// the matching context and decrypt the message.
+	recipient, err := suite.SetupBaseRecipient(enc, privateKey, info)
+	if err != nil {
+		panic(err)
+	}
+	plaintext, err := recipient.Open(nil, ciphertext)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", plaintext)
+	// Output: hello, world
+}
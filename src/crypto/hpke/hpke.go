@@ -0,0 +1,433 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements Hybrid Public Key Encryption, as specified in
+// RFC 9180.
+//
+// All four modes are supported: base, PSK, auth and auth PSK. The supported
+// algorithms are DHKEM(P-256, HKDF-SHA256) and DHKEM(X25519, HKDF-SHA256),
+// HKDF-SHA256, HKDF-SHA384 and HKDF-SHA512, and AES-128-GCM, AES-256-GCM
+// and ChaCha20-Poly1305. The export-only AEAD is also supported.
+//
+// Public and private keys are byte slices in the SerializePublicKey and
+// SerializePrivateKey encodings of the KEM.
+package hpke
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"math/bits"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// A KDF is a key derivation function identifier, as defined in RFC 9180,
+// Section 7.2.
+type KDF uint16
+
+const (
+	KDF_HKDF_SHA256 KDF = 0x0001
+	KDF_HKDF_SHA384 KDF = 0x0002
+	KDF_HKDF_SHA512 KDF = 0x0003
+)
+
+// Available reports whether the given KDF is implemented by this package.
+func (k KDF) Available() bool {
+	return k.hash() != nil
+}
+
+func (k KDF) hash() func() hash.Hash {
+	switch k {
+	case KDF_HKDF_SHA256:
+		return sha256.New
+	case KDF_HKDF_SHA384:
+		return sha512.New384
+	case KDF_HKDF_SHA512:
+		return sha512.New
+	}
+	return nil
+}
+
+// An AEAD is an authenticated encryption identifier, as defined in
+// RFC 9180, Section 7.3.
+type AEAD uint16
+
+const (
+	AEAD_AES_128_GCM      AEAD = 0x0001
+	AEAD_AES_256_GCM      AEAD = 0x0002
+	AEAD_ChaCha20Poly1305 AEAD = 0x0003
+
+	// AEAD_ExportOnly is used when the context is only used to export
+	// secrets. Seal and Open return an error with this AEAD.
+	AEAD_ExportOnly AEAD = 0xffff
+)
+
+// Available reports whether the given AEAD is implemented by this package.
+func (a AEAD) Available() bool {
+	return a.keySize() != 0 || a == AEAD_ExportOnly
+}
+
+func (a AEAD) keySize() int {
+	switch a {
+	case AEAD_AES_128_GCM:
+		return 16
+	case AEAD_AES_256_GCM, AEAD_ChaCha20Poly1305:
+		return 32
+	}
+	return 0
+}
+
+func (a AEAD) newCipher(key []byte) (cipher.AEAD, error) {
+	switch a {
+	case AEAD_AES_128_GCM, AEAD_AES_256_GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AEAD_ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, errors.New("hpke: unsupported AEAD")
+}
+
+// A Suite is a combination of a KEM, a KDF and an AEAD.
+type Suite struct {
+	KEM  KEM
+	KDF  KDF
+	AEAD AEAD
+}
+
+func (s Suite) check() error {
+	if !s.KEM.Available() || !s.KDF.Available() || !s.AEAD.Available() {
+		return errors.New("hpke: unsupported suite")
+	}
+	return nil
+}
+
+func (s Suite) id() []byte {
+	sid := make([]byte, 0, 4+2+2+2)
+	sid = append(sid, "HPKE"...)
+	sid = append(sid, byte(s.KEM>>8), byte(s.KEM))
+	sid = append(sid, byte(s.KDF>>8), byte(s.KDF))
+	sid = append(sid, byte(s.AEAD>>8), byte(s.AEAD))
+	return sid
+}
+
+// Modes, from RFC 9180, Section 5.
+const (
+	modeBase    uint8 = 0x00
+	modePSK     uint8 = 0x01
+	modeAuth    uint8 = 0x02
+	modeAuthPSK uint8 = 0x03
+)
+
+// hkdfSuite implements the LabeledExtract and LabeledExpand functions of
+// RFC 9180, Section 4, for a given suite_id.
+type hkdfSuite struct {
+	hash    func() hash.Hash
+	suiteID []byte
+}
+
+func (kdf *hkdfSuite) labeledExtract(salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, 7+len(kdf.suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, "HPKE-v1"...)
+	labeledIKM = append(labeledIKM, kdf.suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	return hkdf.Extract(kdf.hash, labeledIKM, salt)
+}
+
+func (kdf *hkdfSuite) labeledExpand(prk []byte, label string, info []byte, length int) []byte {
+	labeledInfo := make([]byte, 0, 2+7+len(kdf.suiteID)+len(label)+len(info))
+	labeledInfo = append(labeledInfo, byte(length>>8), byte(length))
+	labeledInfo = append(labeledInfo, "HPKE-v1"...)
+	labeledInfo = append(labeledInfo, kdf.suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+	out := make([]byte, length)
+	r := hkdf.Expand(kdf.hash, prk, labeledInfo)
+	if _, err := io.ReadFull(r, out); err != nil {
+		panic("hpke: internal error: " + err.Error())
+	}
+	return out
+}
+
+type context struct {
+	kdf            *hkdfSuite
+	aead           cipher.AEAD // nil for AEAD_ExportOnly
+	baseNonce      []byte
+	exporterSecret []byte
+	seqNum         uint128
+}
+
+// Sender is the sending side of an HPKE context. It is not safe for
+// concurrent use.
+type Sender struct {
+	context
+}
+
+// Recipient is the receiving side of an HPKE context. It is not safe for
+// concurrent use.
+type Recipient struct {
+	context
+}
+
+// verifyPSKInputs implements VerifyPSKInputs, as specified in RFC 9180,
+// Section 5.1.
+func verifyPSKInputs(mode uint8, psk, pskID []byte) error {
+	gotPSK, gotPSKID := len(psk) != 0, len(pskID) != 0
+	if gotPSK != gotPSKID {
+		return errors.New("hpke: inconsistent PSK inputs")
+	}
+	if gotPSK && (mode == modeBase || mode == modeAuth) {
+		return errors.New("hpke: PSK input provided when not needed")
+	}
+	if !gotPSK && (mode == modePSK || mode == modeAuthPSK) {
+		return errors.New("hpke: missing required PSK input")
+	}
+	// RFC 9180, Section 5.1.2, requires at least 32 bytes of entropy.
+	if gotPSK && len(psk) < 32 {
+		return errors.New("hpke: PSK must be at least 32 bytes")
+	}
+	return nil
+}
+
+// newContext implements KeySchedule, as specified in RFC 9180, Section 5.1.
+func newContext(suite Suite, mode uint8, sharedSecret, info, psk, pskID []byte) (*context, error) {
+	kdf := &hkdfSuite{hash: suite.KDF.hash(), suiteID: suite.id()}
+
+	pskIDHash := kdf.labeledExtract(nil, "psk_id_hash", pskID)
+	infoHash := kdf.labeledExtract(nil, "info_hash", info)
+	ksContext := append([]byte{mode}, pskIDHash...)
+	ksContext = append(ksContext, infoHash...)
+
+	secret := kdf.labeledExtract(sharedSecret, "secret", psk)
+
+	c := &context{kdf: kdf}
+	if suite.AEAD != AEAD_ExportOnly {
+		key := kdf.labeledExpand(secret, "key", ksContext, suite.AEAD.keySize())
+		aead, err := suite.AEAD.newCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		c.aead = aead
+		c.baseNonce = kdf.labeledExpand(secret, "base_nonce", ksContext, aead.NonceSize())
+	}
+	c.exporterSecret = kdf.labeledExpand(secret, "exp", ksContext, kdf.hash().Size())
+	return c, nil
+}
+
+// SetupBaseSender sets up a context for encrypting messages to the public
+// key pkR, and returns it along with the encapsulated key to send to the
+// recipient.
+func (s Suite) SetupBaseSender(rand io.Reader, pkR, info []byte) (enc []byte, sender *Sender, err error) {
+	return s.setupSender(rand, modeBase, pkR, info, nil, nil, nil)
+}
+
+// SetupPSKSender is like SetupBaseSender, but additionally authenticates
+// the sender with the pre-shared key psk, identified by pskID.
+func (s Suite) SetupPSKSender(rand io.Reader, pkR, info, psk, pskID []byte) (enc []byte, sender *Sender, err error) {
+	return s.setupSender(rand, modePSK, pkR, info, psk, pskID, nil)
+}
+
+// SetupAuthSender is like SetupBaseSender, but additionally authenticates
+// the sender as the holder of the private key skS.
+func (s Suite) SetupAuthSender(rand io.Reader, pkR, info, skS []byte) (enc []byte, sender *Sender, err error) {
+	if skS == nil {
+		return nil, nil, errInvalidPrivateKey
+	}
+	return s.setupSender(rand, modeAuth, pkR, info, nil, nil, skS)
+}
+
+// SetupAuthPSKSender combines SetupPSKSender and SetupAuthSender.
+func (s Suite) SetupAuthPSKSender(rand io.Reader, pkR, info, psk, pskID, skS []byte) (enc []byte, sender *Sender, err error) {
+	if skS == nil {
+		return nil, nil, errInvalidPrivateKey
+	}
+	return s.setupSender(rand, modeAuthPSK, pkR, info, psk, pskID, skS)
+}
+
+func (s Suite) setupSender(rand io.Reader, mode uint8, pkR, info, psk, pskID, skS []byte) ([]byte, *Sender, error) {
+	if err := s.check(); err != nil {
+		return nil, nil, err
+	}
+	_, skE, err := s.KEM.GenerateKeyPair(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.setupSenderWithEphemeral(mode, pkR, info, psk, pskID, skS, skE)
+}
+
+func (s Suite) setupSenderWithEphemeral(mode uint8, pkR, info, psk, pskID, skS, skE []byte) ([]byte, *Sender, error) {
+	if err := verifyPSKInputs(mode, psk, pskID); err != nil {
+		return nil, nil, err
+	}
+	kem := &dhKEM{id: s.KEM, dh: s.KEM.dh()}
+	if skS != nil {
+		if err := kem.dh.checkPrivateKey(skS); err != nil {
+			return nil, nil, err
+		}
+	}
+	sharedSecret, enc, err := kem.encap(pkR, skS, skE)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := newContext(s, mode, sharedSecret, info, psk, pskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{*c}, nil
+}
+
+// SetupBaseRecipient sets up a context for decrypting messages sent to the
+// private key skR, given the encapsulated key enc sent by the sender.
+func (s Suite) SetupBaseRecipient(enc, skR, info []byte) (*Recipient, error) {
+	return s.setupRecipient(modeBase, enc, skR, info, nil, nil, nil)
+}
+
+// SetupPSKRecipient is like SetupBaseRecipient, but additionally requires
+// the sender to know the pre-shared key psk, identified by pskID.
+func (s Suite) SetupPSKRecipient(enc, skR, info, psk, pskID []byte) (*Recipient, error) {
+	return s.setupRecipient(modePSK, enc, skR, info, psk, pskID, nil)
+}
+
+// SetupAuthRecipient is like SetupBaseRecipient, but additionally requires
+// the sender to hold the private key for the public key pkS.
+func (s Suite) SetupAuthRecipient(enc, skR, info, pkS []byte) (*Recipient, error) {
+	if pkS == nil {
+		return nil, errInvalidPublicKey
+	}
+	return s.setupRecipient(modeAuth, enc, skR, info, nil, nil, pkS)
+}
+
+// SetupAuthPSKRecipient combines SetupPSKRecipient and SetupAuthRecipient.
+func (s Suite) SetupAuthPSKRecipient(enc, skR, info, psk, pskID, pkS []byte) (*Recipient, error) {
+	if pkS == nil {
+		return nil, errInvalidPublicKey
+	}
+	return s.setupRecipient(modeAuthPSK, enc, skR, info, psk, pskID, pkS)
+}
+
+func (s Suite) setupRecipient(mode uint8, enc, skR, info, psk, pskID, pkS []byte) (*Recipient, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if err := verifyPSKInputs(mode, psk, pskID); err != nil {
+		return nil, err
+	}
+	kem := &dhKEM{id: s.KEM, dh: s.KEM.dh()}
+	if err := kem.dh.checkPrivateKey(skR); err != nil {
+		return nil, err
+	}
+	sharedSecret, err := kem.decap(enc, skR, pkS)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newContext(s, mode, sharedSecret, info, psk, pskID)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{*c}, nil
+}
+
+var errExportOnly = errors.New("hpke: export-only context")
+
+func (c *context) nextNonce() ([]byte, error) {
+	// The sequence number must not wrap around and cause nonce reuse,
+	// see RFC 9180, Section 5.2.
+	if c.seqNum.addOne().bitLen() > c.aead.NonceSize()*8 {
+		return nil, errors.New("hpke: message limit reached")
+	}
+	nonce := c.seqNum.bytes()[16-c.aead.NonceSize():]
+	for i := range c.baseNonce {
+		nonce[i] ^= c.baseNonce[i]
+	}
+	return nonce, nil
+}
+
+// Seal encrypts and authenticates plaintext with the additional data aad.
+// Each call uses the next sequence number, so messages must be opened by
+// the recipient in the order they were sealed.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	if s.aead == nil {
+		return nil, errExportOnly
+	}
+	nonce, err := s.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := s.aead.Seal(nil, nonce, plaintext, aad)
+	s.seqNum = s.seqNum.addOne()
+	return ciphertext, nil
+}
+
+// Open decrypts and authenticates ciphertext with the additional data aad.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	if r.aead == nil {
+		return nil, errExportOnly
+	}
+	nonce, err := r.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	r.seqNum = r.seqNum.addOne()
+	return plaintext, nil
+}
+
+// Export derives a secret of the given length from the context, bound to
+// exporterContext, as specified in RFC 9180, Section 5.3. The recipient
+// derives the same secrets.
+func (s *Sender) Export(exporterContext []byte, length int) ([]byte, error) {
+	return s.export(exporterContext, length)
+}
+
+// Export derives a secret of the given length from the context, bound to
+// exporterContext, as specified in RFC 9180, Section 5.3. The sender
+// derives the same secrets.
+func (r *Recipient) Export(exporterContext []byte, length int) ([]byte, error) {
+	return r.export(exporterContext, length)
+}
+
+func (c *context) export(exporterContext []byte, length int) ([]byte, error) {
+	if length < 0 || length > 255*c.kdf.hash().Size() {
+		return nil, errors.New("hpke: invalid export length")
+	}
+	return c.kdf.labeledExpand(c.exporterSecret, "sec", exporterContext, length), nil
+}
+
+// uint128 is a 128-bit sequence number.
+type uint128 struct {
+	hi, lo uint64
+}
+
+func (u uint128) addOne() uint128 {
+	lo, carry := bits.Add64(u.lo, 1, 0)
+	return uint128{u.hi + carry, lo}
+}
+
+func (u uint128) bitLen() int {
+	if u.hi != 0 {
+		return 64 + bits.Len64(u.hi)
+	}
+	return bits.Len64(u.lo)
+}
+
+func (u uint128) bytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[0:], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	return b
+}
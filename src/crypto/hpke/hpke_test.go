@@ -0,0 +1,363 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, in string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testVector is a test vector in the format of the RFC 9180 test vectors,
+// see https://github.com/cfrg/draft-irtf-cfrg-hpke. Only some of the
+// encryptions of each vector are kept.
+type testVector struct {
+	Mode        uint8  `json:"mode"`
+	KEM         KEM    `json:"kem_id"`
+	KDF         KDF    `json:"kdf_id"`
+	AEAD        AEAD   `json:"aead_id"`
+	Info        string `json:"info"`
+	IkmE        string `json:"ikmE"`
+	IkmR        string `json:"ikmR"`
+	IkmS        string `json:"ikmS"`
+	SkRm        string `json:"skRm"`
+	SkSm        string `json:"skSm"`
+	PkRm        string `json:"pkRm"`
+	PkSm        string `json:"pkSm"`
+	PSK         string `json:"psk"`
+	PSKID       string `json:"psk_id"`
+	Enc         string `json:"enc"`
+	Encryptions []struct {
+		Seq        uint64 `json:"sequence_number"`
+		Plaintext  string `json:"pt"`
+		AAD        string `json:"aad"`
+		Ciphertext string `json:"ct"`
+	} `json:"encryptions"`
+	Exports []struct {
+		Context string `json:"exporter_context"`
+		Length  int    `json:"L"`
+		Value   string `json:"exported_value"`
+	} `json:"exports"`
+}
+
+func TestRFC9180Vectors(t *testing.T) {
+	testVectors(t, "testdata/rfc9180-vectors.json")
+}
+
+// The RFC 9180 vectors don't cover HKDF-SHA384, so these were generated
+// with github.com/cloudflare/circl from the inputs of the HKDF-SHA256
+// vectors. circl reproduces the RFC 9180 vectors exactly, but doesn't
+// implement the export-only AEAD.
+func TestHKDFSHA384Vectors(t *testing.T) {
+	testVectors(t, "testdata/hkdf-sha384-vectors.json")
+}
+
+func testVectors(t *testing.T, file string) {
+	vectorsJSON, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []testVector
+	if err := json.Unmarshal(vectorsJSON, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors {
+		vector := vector
+		suite := Suite{vector.KEM, vector.KDF, vector.AEAD}
+		t.Run(fmt.Sprintf("%04x-%04x-%04x-mode%d", vector.KEM, vector.KDF, vector.AEAD, vector.Mode), func(t *testing.T) {
+			pkR, skR, err := vector.KEM.DeriveKeyPair(mustDecodeHex(t, vector.IkmR))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := mustDecodeHex(t, vector.SkRm); !bytes.Equal(skR, expected) {
+				t.Errorf("unexpected derived private key: got %x, want %x", skR, expected)
+			}
+			if expected := mustDecodeHex(t, vector.PkRm); !bytes.Equal(pkR, expected) {
+				t.Errorf("unexpected derived public key: got %x, want %x", pkR, expected)
+			}
+
+			var pkS, skS []byte
+			if vector.IkmS != "" {
+				pkS, skS, err = vector.KEM.DeriveKeyPair(mustDecodeHex(t, vector.IkmS))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := mustDecodeHex(t, vector.SkSm); !bytes.Equal(skS, expected) {
+					t.Errorf("unexpected derived sender private key: got %x, want %x", skS, expected)
+				}
+				if expected := mustDecodeHex(t, vector.PkSm); !bytes.Equal(pkS, expected) {
+					t.Errorf("unexpected derived sender public key: got %x, want %x", pkS, expected)
+				}
+			}
+
+			_, skE, err := vector.KEM.DeriveKeyPair(mustDecodeHex(t, vector.IkmE))
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := mustDecodeHex(t, vector.Info)
+			psk := mustDecodeHex(t, vector.PSK)
+			pskID := mustDecodeHex(t, vector.PSKID)
+			enc, sender, err := suite.setupSenderWithEphemeral(vector.Mode, pkR, info, psk, pskID, skS, skE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := mustDecodeHex(t, vector.Enc); !bytes.Equal(enc, expected) {
+				t.Errorf("unexpected encapsulated key: got %x, want %x", enc, expected)
+			}
+
+			recipient, err := suite.setupRecipient(vector.Mode, enc, skR, info, psk, pskID, pkS)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if vector.AEAD == AEAD_ExportOnly {
+				if _, err := sender.Seal(nil, nil); err == nil {
+					t.Error("Seal succeeded with the export-only AEAD")
+				}
+				if _, err := recipient.Open(nil, nil); err == nil {
+					t.Error("Open succeeded with the export-only AEAD")
+				}
+			}
+
+			for _, enc := range vector.Encryptions {
+				sender.seqNum = uint128{lo: enc.Seq}
+				recipient.seqNum = uint128{lo: enc.Seq}
+
+				plaintext := mustDecodeHex(t, enc.Plaintext)
+				aad := mustDecodeHex(t, enc.AAD)
+				expected := mustDecodeHex(t, enc.Ciphertext)
+
+				ciphertext, err := sender.Seal(aad, plaintext)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(ciphertext, expected) {
+					t.Errorf("sequence number %d: unexpected ciphertext: got %x, want %x", enc.Seq, ciphertext, expected)
+				}
+				got, err := recipient.Open(aad, ciphertext)
+				if err != nil {
+					t.Fatalf("sequence number %d: %v", enc.Seq, err)
+				}
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("sequence number %d: unexpected plaintext: got %x, want %x", enc.Seq, got, plaintext)
+				}
+			}
+
+			for _, exp := range vector.Exports {
+				context := mustDecodeHex(t, exp.Context)
+				expected := mustDecodeHex(t, exp.Value)
+				for _, c := range []interface {
+					Export([]byte, int) ([]byte, error)
+				}{sender, recipient} {
+					got, err := c.Export(context, exp.Length)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, expected) {
+						t.Errorf("%T: unexpected exported value for context %q: got %x, want %x", c, exp.Context, got, expected)
+					}
+				}
+			}
+		})
+	}
+}
+
+var testPSK = []byte("0123456789abcdef0123456789abcdef")
+var testPSKID = []byte("test psk")
+
+// setup sets up a sender and a recipient in the given mode.
+func setup(t *testing.T, suite Suite, mode uint8, info []byte) (*Sender, *Recipient) {
+	t.Helper()
+	pkR, skR, err := suite.KEM.GenerateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkS, skS, err := suite.KEM.GenerateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var enc []byte
+	var sender *Sender
+	var recipient *Recipient
+	switch mode {
+	case modeBase:
+		enc, sender, err = suite.SetupBaseSender(rand.Reader, pkR, info)
+		if err == nil {
+			recipient, err = suite.SetupBaseRecipient(enc, skR, info)
+		}
+	case modePSK:
+		enc, sender, err = suite.SetupPSKSender(rand.Reader, pkR, info, testPSK, testPSKID)
+		if err == nil {
+			recipient, err = suite.SetupPSKRecipient(enc, skR, info, testPSK, testPSKID)
+		}
+	case modeAuth:
+		enc, sender, err = suite.SetupAuthSender(rand.Reader, pkR, info, skS)
+		if err == nil {
+			recipient, err = suite.SetupAuthRecipient(enc, skR, info, pkS)
+		}
+	case modeAuthPSK:
+		enc, sender, err = suite.SetupAuthPSKSender(rand.Reader, pkR, info, testPSK, testPSKID, skS)
+		if err == nil {
+			recipient, err = suite.SetupAuthPSKRecipient(enc, skR, info, testPSK, testPSKID, pkS)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender, recipient
+}
+
+func TestModes(t *testing.T) {
+	for _, kem := range []KEM{DHKEM_P256_HKDF_SHA256, DHKEM_X25519_HKDF_SHA256} {
+		for _, kdf := range []KDF{KDF_HKDF_SHA256, KDF_HKDF_SHA384, KDF_HKDF_SHA512} {
+			for _, aead := range []AEAD{AEAD_AES_128_GCM, AEAD_AES_256_GCM, AEAD_ChaCha20Poly1305} {
+				for mode := modeBase; mode <= modeAuthPSK; mode++ {
+					suite := Suite{kem, kdf, aead}
+					t.Run(fmt.Sprintf("%04x-%04x-%04x-mode%d", kem, kdf, aead, mode), func(t *testing.T) {
+						sender, recipient := setup(t, suite, mode, []byte("info"))
+						for i := 0; i < 3; i++ {
+							msg := []byte(fmt.Sprintf("message %d", i))
+							aad := []byte(fmt.Sprintf("aad %d", i))
+							ct, err := sender.Seal(aad, msg)
+							if err != nil {
+								t.Fatal(err)
+							}
+							pt, err := recipient.Open(aad, ct)
+							if err != nil {
+								t.Fatal(err)
+							}
+							if !bytes.Equal(pt, msg) {
+								t.Errorf("got %q, want %q", pt, msg)
+							}
+						}
+						s, err := sender.Export([]byte("context"), 42)
+						if err != nil {
+							t.Fatal(err)
+						}
+						r, err := recipient.Export([]byte("context"), 42)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if !bytes.Equal(s, r) || len(s) != 42 {
+							t.Errorf("exported secrets differ: %x != %x", s, r)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	suite := Suite{DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM}
+	info := []byte("info")
+	pkR, skR, _ := suite.KEM.GenerateKeyPair(rand.Reader)
+	_, skS, _ := suite.KEM.GenerateKeyPair(rand.Reader)
+	pkOther, _, _ := suite.KEM.GenerateKeyPair(rand.Reader)
+
+	check := func(name string, sender *Sender, err error, recipient *Recipient, rerr error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		ct, err := sender.Seal(nil, []byte("message"))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rerr != nil {
+			return
+		}
+		if _, err := recipient.Open(nil, ct); err == nil {
+			t.Errorf("%s: Open succeeded", name)
+		}
+	}
+
+	enc, sender, err := suite.SetupAuthSender(rand.Reader, pkR, info, skS)
+	recipient, rerr := suite.SetupAuthRecipient(enc, skR, info, pkOther)
+	check("wrong sender public key", sender, err, recipient, rerr)
+
+	enc, sender, err = suite.SetupPSKSender(rand.Reader, pkR, info, testPSK, testPSKID)
+	otherPSK := append([]byte{}, testPSK...)
+	otherPSK[0] ^= 1
+	recipient, rerr = suite.SetupPSKRecipient(enc, skR, info, otherPSK, testPSKID)
+	check("wrong PSK", sender, err, recipient, rerr)
+
+	enc, sender, err = suite.SetupPSKSender(rand.Reader, pkR, info, testPSK, testPSKID)
+	recipient, rerr = suite.SetupBaseRecipient(enc, skR, info)
+	check("mode mismatch", sender, err, recipient, rerr)
+
+	enc, sender, err = suite.SetupBaseSender(rand.Reader, pkR, info)
+	recipient, rerr = suite.SetupBaseRecipient(enc, skR, []byte("other info"))
+	check("wrong info", sender, err, recipient, rerr)
+}
+
+func TestInvalidInputs(t *testing.T) {
+	suite := Suite{DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_ChaCha20Poly1305}
+	pkR, skR, _ := suite.KEM.GenerateKeyPair(rand.Reader)
+
+	if _, _, err := suite.SetupPSKSender(rand.Reader, pkR, nil, testPSK, nil); err == nil {
+		t.Error("SetupPSKSender accepted a PSK without an ID")
+	}
+	if _, _, err := suite.SetupPSKSender(rand.Reader, pkR, nil, testPSK[:16], testPSKID); err == nil {
+		t.Error("SetupPSKSender accepted a short PSK")
+	}
+	if _, _, err := suite.SetupAuthSender(rand.Reader, pkR, nil, nil); err == nil {
+		t.Error("SetupAuthSender accepted a nil sender key")
+	}
+	if _, err := suite.SetupBaseRecipient(make([]byte, 32), skR, nil); err == nil {
+		t.Error("SetupBaseRecipient accepted a low order encapsulated key")
+	}
+	if _, _, err := (Suite{0x0012, KDF_HKDF_SHA256, AEAD_AES_128_GCM}).SetupBaseSender(rand.Reader, pkR, nil); err == nil {
+		t.Error("SetupBaseSender accepted an unsupported KEM")
+	}
+
+	p256 := Suite{DHKEM_P256_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM}
+	pk, _, _ := p256.KEM.GenerateKeyPair(rand.Reader)
+	pk[len(pk)-1] ^= 1
+	if _, _, err := p256.SetupBaseSender(rand.Reader, pk, nil); err == nil {
+		t.Error("SetupBaseSender accepted a P-256 public key not on the curve")
+	}
+}
+
+func TestExportOnly(t *testing.T) {
+	suite := Suite{DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_ExportOnly}
+	sender, recipient := setup(t, suite, modeBase, nil)
+	if _, err := sender.Seal(nil, []byte("message")); err == nil {
+		t.Error("Seal succeeded with the export-only AEAD")
+	}
+	if _, err := recipient.Open(nil, []byte("message")); err == nil {
+		t.Error("Open succeeded with the export-only AEAD")
+	}
+	if _, err := sender.Export(nil, 255*32+1); err == nil {
+		t.Error("Export accepted a length over 255*Nh")
+	}
+}
+
+func TestMessageLimit(t *testing.T) {
+	suite := Suite{DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM}
+	sender, _ := setup(t, suite, modeBase, nil)
+	// The last allowed sequence number is 2^96 - 2.
+	sender.seqNum = uint128{hi: 1<<32 - 1, lo: 1<<64 - 2}
+	if _, err := sender.Seal(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.Seal(nil, nil); err == nil {
+		t.Error("Seal succeeded past the message limit")
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// A KEM is a key encapsulation mechanism identifier, as defined in
+// RFC 9180, Section 7.1.
+type KEM uint16
+
+const (
+	DHKEM_P256_HKDF_SHA256   KEM = 0x0010
+	DHKEM_X25519_HKDF_SHA256 KEM = 0x0020
+)
+
+// Available reports whether the given KEM is implemented by this package.
+func (k KEM) Available() bool {
+	return k.dh() != nil
+}
+
+// GenerateKeyPair generates a new key pair for the KEM, reading randomness
+// from rand. The public key is returned in the SerializePublicKey encoding,
+// and the private key in the SerializePrivateKey encoding, as specified in
+// RFC 9180, Section 7.1.1.
+func (k KEM) GenerateKeyPair(rand io.Reader) (pub, priv []byte, err error) {
+	dh := k.dh()
+	if dh == nil {
+		return nil, nil, errors.New("hpke: unsupported KEM")
+	}
+	return dh.generateKey(rand)
+}
+
+// DeriveKeyPair deterministically derives a key pair for the KEM from the
+// input keying material ikm, as specified in RFC 9180, Section 7.1.3. ikm
+// must be at least as long as the private key.
+func (k KEM) DeriveKeyPair(ikm []byte) (pub, priv []byte, err error) {
+	dh := k.dh()
+	if dh == nil {
+		return nil, nil, errors.New("hpke: unsupported KEM")
+	}
+	if len(ikm) < dh.privateKeySize() {
+		return nil, nil, errors.New("hpke: input keying material too short")
+	}
+	kem := &dhKEM{id: k, dh: dh}
+	priv, err = kem.deriveKeyPair(ikm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dh.publicKey(priv), priv, nil
+}
+
+func (k KEM) dh() dhGroup {
+	switch k {
+	case DHKEM_P256_HKDF_SHA256:
+		return p256Group{}
+	case DHKEM_X25519_HKDF_SHA256:
+		return x25519Group{}
+	}
+	return nil
+}
+
+// dhGroup is the Diffie-Hellman group underlying a DHKEM.
+type dhGroup interface {
+	generateKey(rand io.Reader) (pub, priv []byte, err error)
+	// deriveCandidate returns the private key DeriveKeyPair derives from
+	// dkpPRK, as specified in RFC 9180, Section 7.1.3.
+	deriveCandidate(kdf *hkdfSuite, dkpPRK []byte) ([]byte, error)
+	publicKey(priv []byte) []byte
+	privateKeySize() int
+	checkPrivateKey(priv []byte) error
+	// dh computes the Diffie-Hellman shared secret between priv and the
+	// serialized public key pub.
+	dh(priv, pub []byte) ([]byte, error)
+}
+
+// dhKEM implements DHKEM, as specified in RFC 9180, Section 4.1. Both the
+// supported KEMs use HKDF-SHA256.
+type dhKEM struct {
+	id KEM
+	dh dhGroup
+}
+
+func (kem *dhKEM) kdf() *hkdfSuite {
+	return &hkdfSuite{
+		hash:    sha256.New,
+		suiteID: []byte{'K', 'E', 'M', byte(kem.id >> 8), byte(kem.id)},
+	}
+}
+
+func (kem *dhKEM) deriveKeyPair(ikm []byte) ([]byte, error) {
+	kdf := kem.kdf()
+	dkpPRK := kdf.labeledExtract(nil, "dkp_prk", ikm)
+	return kem.dh.deriveCandidate(kdf, dkpPRK)
+}
+
+func (kem *dhKEM) extractAndExpand(dh, kemContext []byte) []byte {
+	kdf := kem.kdf()
+	eaePRK := kdf.labeledExtract(nil, "eae_prk", dh)
+	return kdf.labeledExpand(eaePRK, "shared_secret", kemContext, sha256.Size)
+}
+
+// encap implements Encap and AuthEncap with the ephemeral private key skE.
+// If skS is nil, the sender is not authenticated.
+func (kem *dhKEM) encap(pkR, skS, skE []byte) (sharedSecret, enc []byte, err error) {
+	dh, err := kem.dh.dh(skE, pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc = kem.dh.publicKey(skE)
+	kemContext := append(append([]byte{}, enc...), pkR...)
+	if skS != nil {
+		dhS, err := kem.dh.dh(skS, pkR)
+		if err != nil {
+			return nil, nil, err
+		}
+		dh = append(dh, dhS...)
+		kemContext = append(kemContext, kem.dh.publicKey(skS)...)
+	}
+	return kem.extractAndExpand(dh, kemContext), enc, nil
+}
+
+// decap implements Decap and AuthDecap. If pkS is nil, the sender is not
+// authenticated.
+func (kem *dhKEM) decap(enc, skR, pkS []byte) ([]byte, error) {
+	dh, err := kem.dh.dh(skR, enc)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(append([]byte{}, enc...), kem.dh.publicKey(skR)...)
+	if pkS != nil {
+		dhS, err := kem.dh.dh(skR, pkS)
+		if err != nil {
+			return nil, err
+		}
+		dh = append(dh, dhS...)
+		kemContext = append(kemContext, pkS...)
+	}
+	return kem.extractAndExpand(dh, kemContext), nil
+}
+
+var errInvalidPublicKey = errors.New("hpke: invalid public key")
+var errInvalidPrivateKey = errors.New("hpke: invalid private key")
+
+type x25519Group struct{}
+
+const x25519Size = 32
+
+func (x25519Group) generateKey(rand io.Reader) (pub, priv []byte, err error) {
+	priv = make([]byte, x25519Size)
+	if _, err := io.ReadFull(rand, priv); err != nil {
+		return nil, nil, err
+	}
+	return x25519Group{}.publicKey(priv), priv, nil
+}
+
+func (x25519Group) deriveCandidate(kdf *hkdfSuite, dkpPRK []byte) ([]byte, error) {
+	return kdf.labeledExpand(dkpPRK, "sk", nil, x25519Size), nil
+}
+
+func (x25519Group) publicKey(priv []byte) []byte {
+	var dst, scalar [32]byte
+	copy(scalar[:], priv)
+	curve25519.ScalarBaseMult(&dst, &scalar)
+	return dst[:]
+}
+
+func (x25519Group) privateKeySize() int { return x25519Size }
+
+func (x25519Group) checkPrivateKey(priv []byte) error {
+	if len(priv) != x25519Size {
+		return errInvalidPrivateKey
+	}
+	return nil
+}
+
+func (x25519Group) dh(priv, pub []byte) ([]byte, error) {
+	if len(pub) != x25519Size {
+		return nil, errInvalidPublicKey
+	}
+	var dst, scalar, point, zero [32]byte
+	copy(scalar[:], priv)
+	copy(point[:], pub)
+	curve25519.ScalarMult(&dst, &scalar, &point)
+	// Reject low order points, see RFC 9180, Section 7.1.4.
+	if dst == zero {
+		return nil, errInvalidPublicKey
+	}
+	return dst[:], nil
+}
+
+type p256Group struct{}
+
+const p256ScalarSize = 32
+
+func (p256Group) generateKey(rand io.Reader) (pub, priv []byte, err error) {
+	priv, x, y, err := elliptic.GenerateKey(elliptic.P256(), rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return elliptic.Marshal(elliptic.P256(), x, y), priv, nil
+}
+
+func (p256Group) deriveCandidate(kdf *hkdfSuite, dkpPRK []byte) ([]byte, error) {
+	for counter := 0; counter < 256; counter++ {
+		sk := kdf.labeledExpand(dkpPRK, "candidate", []byte{byte(counter)}, p256ScalarSize)
+		if (p256Group{}).checkPrivateKey(sk) == nil {
+			return sk, nil
+		}
+	}
+	return nil, errors.New("hpke: failed to derive a P-256 key pair")
+}
+
+func (p256Group) publicKey(priv []byte) []byte {
+	x, y := elliptic.P256().ScalarBaseMult(priv)
+	return elliptic.Marshal(elliptic.P256(), x, y)
+}
+
+func (p256Group) privateKeySize() int { return p256ScalarSize }
+
+func (p256Group) checkPrivateKey(priv []byte) error {
+	if len(priv) != p256ScalarSize {
+		return errInvalidPrivateKey
+	}
+	k := new(big.Int).SetBytes(priv)
+	if k.Sign() == 0 || k.Cmp(elliptic.P256().Params().N) >= 0 {
+		return errInvalidPrivateKey
+	}
+	return nil
+}
+
+func (p256Group) dh(priv, pub []byte) ([]byte, error) {
+	x, y := elliptic.Unmarshal(elliptic.P256(), pub)
+	if x == nil {
+		return nil, errInvalidPublicKey
+	}
+	x, _ = elliptic.P256().ScalarMult(x, y, priv)
+	// The shared secret is the serialized x-coordinate, see RFC 9180,
+	// Section 7.1.1.
+	shared := make([]byte, p256ScalarSize)
+	xBytes := x.Bytes()
+	copy(shared[len(shared)-len(xBytes):], xBytes)
+	return shared, nil
+}
@@ -95,7 +95,10 @@ const (
 	extensionCertificateAuthorities  uint16 = 47
 	extensionSignatureAlgorithmsCert uint16 = 50
 	extensionKeyShare                uint16 = 51
+	extensionQUICTransportParameters uint16 = 57
 	extensionNextProtoNeg            uint16 = 13172 // not IANA assigned
+	extensionECHOuterExtensions      uint16 = 0xfd00
+	extensionEncryptedClientHello    uint16 = 0xfe0d
 	extensionRenegotiationInfo       uint16 = 0xff01
 )
 
@@ -232,6 +235,7 @@ type ConnectionState struct {
 	VerifiedChains              [][]*x509.Certificate // verified chains built from PeerCertificates
 	SignedCertificateTimestamps [][]byte              // SCTs from the peer, if any
 	OCSPResponse                []byte                // stapled OCSP response from peer, if any
+	ECHAccepted                 bool                  // Encrypted Client Hello was offered by the client and accepted by the server
 
 	// ekm is a closure exposed via ExportKeyingMaterial.
 	ekm func(label string, context []byte, length int) ([]byte, error)
@@ -288,9 +292,11 @@ type ClientSessionState struct {
 	receivedAt         time.Time             // When the session ticket was received from the server
 
 	// TLS 1.3 fields.
-	nonce  []byte    // Ticket nonce sent by the server, to derive PSK
-	useBy  time.Time // Expiration of the ticket lifetime as set by the server
-	ageAdd uint32    // Random obfuscation factor for sending the ticket age
+	nonce        []byte    // Ticket nonce sent by the server, to derive PSK
+	useBy        time.Time // Expiration of the ticket lifetime as set by the server
+	ageAdd       uint32    // Random obfuscation factor for sending the ticket age
+	earlyData    bool      // Whether the ticket allows 0-RTT in a QUIC connection
+	alpnProtocol string    // ALPN protocol negotiated for the session, for 0-RTT
 }
 
 // ClientSessionCache is a cache of ClientSessionState objects that can be used
@@ -577,6 +583,43 @@ type Config struct {
 	// connections using that key might be compromised.
 	SessionTicketKey [32]byte
 
+	// UnwrapSession is called on the server to turn a ticket or PSK
+	// identity sent by the client, as previously produced by WrapSession,
+	// into a usable session.
+	//
+	// UnwrapSession will usually either decrypt a session state contained
+	// in the ticket (for example with Config.DecryptTicket), or use the
+	// ticket as a handle to recover a previously stored state. It must use
+	// ParseSessionState to deserialize the session state.
+	//
+	// If UnwrapSession returns an error, the connection is terminated. If it
+	// returns (nil, nil), the session is ignored. crypto/tls may still choose
+	// not to resume the returned session, for example if it was created
+	// for a different protocol version or cipher suite, or has expired.
+	//
+	// If UnwrapSession is nil, Config.DecryptTicket is used.
+	UnwrapSession func(identity []byte, cs ConnectionState) (*SessionState, error)
+
+	// WrapSession is called on the server to produce a session ticket or
+	// PSK identity for a session, to be sent to the client.
+	//
+	// WrapSession must serialize the session state with SessionState.Bytes.
+	// It may then encrypt the serialized state (for example with
+	// Config.EncryptTicket) and use it as the ticket, or store the state and
+	// return a handle for it. The ConnectionState reflects the handshake
+	// that produced the session.
+	//
+	// If WrapSession returns an error, the connection is terminated.
+	//
+	// Warning: the return value will be exposed on the wire and to clients
+	// in plaintext. The application is in charge of encrypting and
+	// authenticating it (and rotating keys) or returning high-entropy
+	// identifiers. Failing to do so correctly can compromise current,
+	// previous, and future connections depending on the protocol version.
+	//
+	// If WrapSession is nil, Config.EncryptTicket is used.
+	WrapSession func(ConnectionState, *SessionState) ([]byte, error)
+
 	// ClientSessionCache is a cache of ClientSessionState entries for TLS
 	// session resumption. It is only used by clients.
 	ClientSessionCache ClientSessionCache
@@ -614,6 +657,26 @@ type Config struct {
 	// used for debugging.
 	KeyLogWriter io.Writer
 
+	// EncryptedClientHelloConfigList is a serialized ECHConfigList, as
+	// defined in RFC 9849, Section 4. If set, clients encrypt the real
+	// ClientHello, including the ServerName, to the first supported
+	// ECHConfig in the list, and will only negotiate TLS 1.3. MinVersion,
+	// if set, must be VersionTLS13.
+	//
+	// If the server rejects ECH, the handshake is completed with the public
+	// name of the ECHConfig, verifying the server certificate for that name
+	// even if InsecureSkipVerify is set, and then fails with an
+	// ECHRejectionError.
+	EncryptedClientHelloConfigList []byte
+
+	// EncryptedClientHelloKeys are the ECH keys a server uses to decrypt
+	// client hellos. Keys are tried in order among those whose config_id
+	// matches the one chosen by the client, so keys can be rotated by
+	// adding the new key and keeping the old one until clients stop
+	// using it. If a client attempts ECH but none of the keys work, the
+	// configs of the keys with SendAsRetry set are sent to it.
+	EncryptedClientHelloKeys []EncryptedClientHelloKey
+
 	serverInitOnce sync.Once // guards calling (*Config).serverInit
 
 	// mutex protects sessionTicketKeys.
@@ -625,6 +688,20 @@ type Config struct {
 	sessionTicketKeys []ticketKey
 }
 
+// EncryptedClientHelloKey holds an ECH private key and the ECHConfig that
+// was published for it.
+type EncryptedClientHelloKey struct {
+	// Config is the serialized ECHConfig, as defined in RFC 9849,
+	// Section 4, that the key belongs to.
+	Config []byte
+	// PrivateKey is the private key of the KEM selected by Config, in the
+	// encoding used by package crypto/hpke.
+	PrivateKey []byte
+	// SendAsRetry reports whether Config should be sent to clients whose
+	// ECH attempt was rejected, for them to retry with.
+	SendAsRetry bool
+}
+
 // ticketKeyNameLen is the number of bytes of identifier that is prepended to
 // an encrypted session ticket in order to identify the key used to encrypt it.
 const ticketKeyNameLen = 16
@@ -666,32 +743,36 @@ func (c *Config) Clone() *Config {
 	c.mutex.RUnlock()
 
 	return &Config{
-		Rand:                        c.Rand,
-		Time:                        c.Time,
-		Certificates:                c.Certificates,
-		NameToCertificate:           c.NameToCertificate,
-		GetCertificate:              c.GetCertificate,
-		GetClientCertificate:        c.GetClientCertificate,
-		GetConfigForClient:          c.GetConfigForClient,
-		VerifyPeerCertificate:       c.VerifyPeerCertificate,
-		RootCAs:                     c.RootCAs,
-		NextProtos:                  c.NextProtos,
-		ServerName:                  c.ServerName,
-		ClientAuth:                  c.ClientAuth,
-		ClientCAs:                   c.ClientCAs,
-		InsecureSkipVerify:          c.InsecureSkipVerify,
-		CipherSuites:                c.CipherSuites,
-		PreferServerCipherSuites:    c.PreferServerCipherSuites,
-		SessionTicketsDisabled:      c.SessionTicketsDisabled,
-		SessionTicketKey:            c.SessionTicketKey,
-		ClientSessionCache:          c.ClientSessionCache,
-		MinVersion:                  c.MinVersion,
-		MaxVersion:                  c.MaxVersion,
-		CurvePreferences:            c.CurvePreferences,
-		DynamicRecordSizingDisabled: c.DynamicRecordSizingDisabled,
-		Renegotiation:               c.Renegotiation,
-		KeyLogWriter:                c.KeyLogWriter,
-		sessionTicketKeys:           sessionTicketKeys,
+		Rand:                           c.Rand,
+		Time:                           c.Time,
+		Certificates:                   c.Certificates,
+		NameToCertificate:              c.NameToCertificate,
+		GetCertificate:                 c.GetCertificate,
+		GetClientCertificate:           c.GetClientCertificate,
+		GetConfigForClient:             c.GetConfigForClient,
+		VerifyPeerCertificate:          c.VerifyPeerCertificate,
+		RootCAs:                        c.RootCAs,
+		NextProtos:                     c.NextProtos,
+		ServerName:                     c.ServerName,
+		ClientAuth:                     c.ClientAuth,
+		ClientCAs:                      c.ClientCAs,
+		InsecureSkipVerify:             c.InsecureSkipVerify,
+		CipherSuites:                   c.CipherSuites,
+		PreferServerCipherSuites:       c.PreferServerCipherSuites,
+		SessionTicketsDisabled:         c.SessionTicketsDisabled,
+		SessionTicketKey:               c.SessionTicketKey,
+		UnwrapSession:                  c.UnwrapSession,
+		WrapSession:                    c.WrapSession,
+		ClientSessionCache:             c.ClientSessionCache,
+		MinVersion:                     c.MinVersion,
+		MaxVersion:                     c.MaxVersion,
+		CurvePreferences:               c.CurvePreferences,
+		DynamicRecordSizingDisabled:    c.DynamicRecordSizingDisabled,
+		Renegotiation:                  c.Renegotiation,
+		KeyLogWriter:                   c.KeyLogWriter,
+		EncryptedClientHelloConfigList: c.EncryptedClientHelloConfigList,
+		EncryptedClientHelloKeys:       c.EncryptedClientHelloKeys,
+		sessionTicketKeys:              sessionTicketKeys,
 	}
 }
 
@@ -918,6 +999,7 @@ func (c *Config) BuildNameToCertificate() {
 
 const (
 	keyLogLabelTLS12           = "CLIENT_RANDOM"
+	keyLogLabelEarlyTraffic    = "CLIENT_EARLY_TRAFFIC_SECRET"
 	keyLogLabelClientHandshake = "CLIENT_HANDSHAKE_TRAFFIC_SECRET"
 	keyLogLabelServerHandshake = "SERVER_HANDSHAKE_TRAFFIC_SECRET"
 	keyLogLabelClientTraffic   = "CLIENT_TRAFFIC_SECRET_0"
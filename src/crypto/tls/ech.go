@@ -0,0 +1,597 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/hpke"
+	"errors"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// This file implements Encrypted Client Hello, as specified in RFC 9849.
+
+type echCipher struct {
+	KDFID  uint16
+	AEADID uint16
+}
+
+// supported reports whether cs can be used to encrypt ClientHellos. The
+// export-only AEAD is not usable for ECH.
+func (cs echCipher) supported() bool {
+	return hpke.KDF(cs.KDFID).Available() && hpke.AEAD(cs.AEADID).Available() &&
+		hpke.AEAD(cs.AEADID) != hpke.AEAD_ExportOnly
+}
+
+func (cs echCipher) suite(kemID uint16) hpke.Suite {
+	return hpke.Suite{KEM: hpke.KEM(kemID), KDF: hpke.KDF(cs.KDFID), AEAD: hpke.AEAD(cs.AEADID)}
+}
+
+type echExtension struct {
+	Type uint16
+	Data []byte
+}
+
+// echConfig is a parsed ECHConfig, as defined in RFC 9849, Section 4.
+type echConfig struct {
+	raw []byte
+
+	Version uint16
+	Length  uint16
+
+	ConfigID             uint8
+	KemID                uint16
+	PublicKey            []byte
+	SymmetricCipherSuite []echCipher
+
+	MaxNameLength uint8
+	PublicName    []byte
+	Extensions    []echExtension
+}
+
+var errMalformedECHConfig = errors.New("tls: malformed ECHConfig")
+
+// parseECHConfig parses the first ECHConfig in enc. If the ECHConfig has an
+// unknown version, skip is true and the returned config is empty.
+func parseECHConfig(enc []byte) (skip bool, ec echConfig, err error) {
+	s := cryptobyte.String(enc)
+	ec.raw = enc
+	if !s.ReadUint16(&ec.Version) || !s.ReadUint16(&ec.Length) ||
+		len(ec.raw) < int(ec.Length)+4 {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.raw = ec.raw[:ec.Length+4]
+	if ec.Version != extensionEncryptedClientHello {
+		return true, echConfig{}, nil
+	}
+	var cipherSuites, publicName, extensions cryptobyte.String
+	if !s.ReadUint8(&ec.ConfigID) ||
+		!s.ReadUint16(&ec.KemID) ||
+		!readUint16LengthPrefixed(&s, &ec.PublicKey) ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		cipherSuites.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !cipherSuites.Empty() {
+		var c echCipher
+		if !cipherSuites.ReadUint16(&c.KDFID) || !cipherSuites.ReadUint16(&c.AEADID) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.SymmetricCipherSuite = append(ec.SymmetricCipherSuite, c)
+	}
+	if !s.ReadUint8(&ec.MaxNameLength) ||
+		!s.ReadUint8LengthPrefixed(&publicName) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.PublicName = publicName
+	for !extensions.Empty() {
+		var e echExtension
+		if !extensions.ReadUint16(&e.Type) ||
+			!readUint16LengthPrefixed(&extensions, &e.Data) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.Extensions = append(ec.Extensions, e)
+	}
+
+	return false, ec, nil
+}
+
+// parseECHConfigList parses an ECHConfigList, returning the ECHConfigs with
+// a known version in the order they appear in the list.
+func parseECHConfigList(data []byte) ([]echConfig, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() {
+		return nil, errors.New("tls: malformed ECHConfigList")
+	}
+	var configs []echConfig
+	for !list.Empty() {
+		skip, ec, err := parseECHConfig(list)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			configs = append(configs, ec)
+		}
+		if !list.Skip(4 + int(uint16(list[2])<<8|uint16(list[3]))) {
+			return nil, errMalformedECHConfig
+		}
+	}
+	return configs, nil
+}
+
+// pickECHConfig returns the first config in list, and its first cipher suite,
+// that are supported by this implementation.
+func pickECHConfig(list []echConfig) (*echConfig, echCipher) {
+	for i := range list {
+		ec := &list[i]
+		if !hpke.KEM(ec.KemID).Available() || !validDNSName(string(ec.PublicName)) {
+			continue
+		}
+		mandatoryExtension := false
+		for _, ext := range ec.Extensions {
+			// We don't support any extensions, so skip configs with
+			// mandatory ones. See RFC 9849, Section 4.2.
+			if ext.Type&0x8000 != 0 {
+				mandatoryExtension = true
+			}
+		}
+		if mandatoryExtension {
+			continue
+		}
+		for _, cs := range ec.SymmetricCipherSuite {
+			if cs.supported() {
+				return ec, cs
+			}
+		}
+	}
+	return nil, echCipher{}
+}
+
+// validDNSName is a rudimentary check for the validity of a DNS name, used to
+// discard ECHConfigs with an invalid public_name. See RFC 9849, Section 6.1.7.
+func validDNSName(name string) bool {
+	if len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) <= 1 {
+		return false
+	}
+	for _, l := range labels {
+		if len(l) == 0 || len(l) > 63 {
+			return false
+		}
+		for i, r := range l {
+			if r == '-' && (i == 0 || i == len(l)-1) {
+				return false
+			}
+			if (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// echClientContext is the client state of an ECH handshake.
+type echClientContext struct {
+	config          *echConfig
+	kdfID, aeadID   uint16
+	hpkeContext     *hpke.Sender
+	encapsulatedKey []byte
+
+	innerHello      *clientHelloMsg
+	innerTranscript hash.Hash
+
+	hrrAccepted  bool // the server accepted ECH in a HelloRetryRequest
+	rejected     bool
+	retryConfigs []byte
+}
+
+// encodeInnerClientHello returns the EncodedClientHelloInner for inner, padded
+// as recommended by RFC 9849, Section 6.1.3.
+func encodeInnerClientHello(inner *clientHelloMsg, maxNameLength int) []byte {
+	// The legacy_session_id is omitted, and copied from the outer
+	// ClientHello by the server.
+	encoded := *inner
+	encoded.raw = nil
+	encoded.sessionId = nil
+	h := encoded.marshal()[4:] // strip the message header
+
+	var paddingLen int
+	if inner.serverName != "" {
+		paddingLen = maxNameLength - len(inner.serverName)
+		if paddingLen < 0 {
+			paddingLen = 0
+		}
+	} else {
+		paddingLen = maxNameLength + 9
+	}
+	paddingLen += 31 - ((len(h) + paddingLen - 1) % 32)
+
+	return append(h, make([]byte, paddingLen)...)
+}
+
+func marshalOuterECHExt(configID uint8, cs echCipher, encapsulatedKey, payload []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(uint8(outerECHExt))
+	b.AddUint16(cs.KDFID)
+	b.AddUint16(cs.AEADID)
+	b.AddUint8(configID)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(encapsulatedKey)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(payload)
+	})
+	return b.BytesOrPanic()
+}
+
+// updateOuterECHExtension encrypts inner into the encrypted_client_hello
+// extension of outer. The encapsulated key is only sent in the first
+// ClientHello, see RFC 9849, Section 6.1.5.
+func (ech *echClientContext) updateOuterECHExtension(outer, inner *clientHelloMsg, sendKey bool) error {
+	var encapsulatedKey []byte
+	if sendKey {
+		encapsulatedKey = ech.encapsulatedKey
+	}
+	cs := echCipher{ech.kdfID, ech.aeadID}
+	encodedInner := encodeInnerClientHello(inner, int(ech.config.MaxNameLength))
+
+	// The AAD is the ClientHelloOuter with the payload replaced by zeroes,
+	// see RFC 9849, Section 5.2. All supported AEADs have a 16 byte tag.
+	outer.encryptedClientHello = marshalOuterECHExt(ech.config.ConfigID, cs,
+		encapsulatedKey, make([]byte, len(encodedInner)+16))
+	outer.raw = nil
+	aad := outer.marshal()[4:]
+
+	payload, err := ech.hpkeContext.Seal(aad, encodedInner)
+	if err != nil {
+		return err
+	}
+	outer.encryptedClientHello = marshalOuterECHExt(ech.config.ConfigID, cs,
+		encapsulatedKey, payload)
+	outer.raw = nil
+	return nil
+}
+
+// echAcceptConfirmation computes the ECH acceptance signal of RFC 9849,
+// Section 7.2, over the transcript, which must already include the
+// ServerHello or HelloRetryRequest with the confirmation bytes set to zero.
+func echAcceptConfirmation(suite *cipherSuiteTLS13, innerRandom []byte, label string, transcript hash.Hash) []byte {
+	return suite.expandLabel(suite.extract(innerRandom, nil), label, transcript.Sum(nil), 8)
+}
+
+const (
+	echAcceptConfirmationLabel    = "ech accept confirmation"
+	echHRRAcceptConfirmationLabel = "hrr ech accept confirmation"
+)
+
+// ECHRejectionError is the error returned by a client handshake when the
+// server rejected Encrypted Client Hello. The handshake was completed with
+// the ECHConfig public name, but no application data may be exchanged.
+//
+// If the server provided new ECHConfigs to retry with, RetryConfigList
+// contains them as a serialized ECHConfigList. A rejection with an empty
+// RetryConfigList is an authenticated signal that the server does not
+// support ECH at all.
+type ECHRejectionError struct {
+	RetryConfigList []byte
+}
+
+func (e *ECHRejectionError) Error() string {
+	return "tls: server rejected ECH"
+}
+
+type echExtType uint8
+
+const (
+	outerECHExt echExtType = 0
+	innerECHExt echExtType = 1
+)
+
+var errInvalidECHExt = errors.New("tls: client sent invalid encrypted_client_hello extension")
+
+func parseECHExt(ext []byte) (echType echExtType, cs echCipher, configID uint8, encapsulatedKey, payload []byte, ok bool) {
+	s := cryptobyte.String(ext)
+	var t uint8
+	if !s.ReadUint8(&t) {
+		return 0, echCipher{}, 0, nil, nil, false
+	}
+	echType = echExtType(t)
+	if echType == innerECHExt {
+		return echType, echCipher{}, 0, nil, nil, s.Empty()
+	}
+	if echType != outerECHExt ||
+		!s.ReadUint16(&cs.KDFID) ||
+		!s.ReadUint16(&cs.AEADID) ||
+		!s.ReadUint8(&configID) ||
+		!readUint16LengthPrefixed(&s, &encapsulatedKey) ||
+		!readUint16LengthPrefixed(&s, &payload) ||
+		len(payload) == 0 || !s.Empty() {
+		return 0, echCipher{}, 0, nil, nil, false
+	}
+	return echType, cs, configID, encapsulatedKey, payload, true
+}
+
+// echServerContext is the server state of an accepted ECH handshake.
+type echServerContext struct {
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	cipherSuite echCipher
+}
+
+// processECHClientHello attempts to decrypt the ClientHelloInner in outer
+// using one of keys. It returns the ClientHello to continue the handshake
+// with, and a non-nil echServerContext if ECH was accepted.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg, keys []EncryptedClientHelloKey) (*clientHelloMsg, *echServerContext, error) {
+	echType, cs, configID, encapsulatedKey, payload, ok := parseECHExt(outer.encryptedClientHello)
+	if !ok {
+		c.sendAlert(alertDecodeError)
+		return nil, nil, errInvalidECHExt
+	}
+	if echType != outerECHExt {
+		// A ClientHelloInner should only ever be sent encrypted.
+		c.sendAlert(alertIllegalParameter)
+		return nil, nil, errInvalidECHExt
+	}
+
+	// Try every key with a matching config_id, so that during a key rotation
+	// clients using either the old or the new ECHConfig are served.
+	for _, key := range keys {
+		skip, config, err := parseECHConfig(key.Config)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: invalid EncryptedClientHelloKey Config: " + err.Error())
+		}
+		if skip || config.ConfigID != configID || !config.supportsCipher(cs) {
+			continue
+		}
+		info := append([]byte("tls ech\x00"), config.raw...)
+		hpkeContext, err := cs.suite(config.KemID).SetupBaseRecipient(encapsulatedKey, key.PrivateKey, info)
+		if err != nil {
+			continue
+		}
+		encodedInner, err := decryptECHPayload(hpkeContext, outer.marshal(), payload)
+		if err != nil {
+			continue
+		}
+
+		inner, err := decodeInnerClientHello(outer, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+
+		c.echAccepted = true
+		return inner, &echServerContext{
+			hpkeContext: hpkeContext,
+			configID:    configID,
+			cipherSuite: cs,
+		}, nil
+	}
+
+	// ECH is rejected, and the handshake continues with ClientHelloOuter.
+	return outer, nil, nil
+}
+
+// processSecondClientHello decrypts the second ClientHelloInner after a
+// HelloRetryRequest, using the HPKE context established by the first one.
+// See RFC 9849, Section 7.1.
+func (ech *echServerContext) processSecondClientHello(c *Conn, outer *clientHelloMsg) (*clientHelloMsg, error) {
+	if len(outer.encryptedClientHello) == 0 {
+		c.sendAlert(alertMissingExtension)
+		return nil, errors.New("tls: client did not send encrypted_client_hello in second ClientHello")
+	}
+	echType, cs, configID, encapsulatedKey, payload, ok := parseECHExt(outer.encryptedClientHello)
+	if !ok {
+		c.sendAlert(alertDecodeError)
+		return nil, errInvalidECHExt
+	}
+	if echType != outerECHExt || cs != ech.cipherSuite ||
+		configID != ech.configID || len(encapsulatedKey) != 0 {
+		c.sendAlert(alertIllegalParameter)
+		return nil, errInvalidECHExt
+	}
+	encodedInner, err := decryptECHPayload(ech.hpkeContext, outer.marshal(), payload)
+	if err != nil {
+		c.sendAlert(alertDecryptError)
+		return nil, errors.New("tls: failed to decrypt second ClientHelloInner")
+	}
+	inner, err := decodeInnerClientHello(outer, encodedInner)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return nil, err
+	}
+	return inner, nil
+}
+
+func (ec *echConfig) supportsCipher(cs echCipher) bool {
+	if !cs.supported() {
+		return false
+	}
+	for _, c := range ec.SymmetricCipherSuite {
+		if c == cs {
+			return true
+		}
+	}
+	return false
+}
+
+func decryptECHPayload(context *hpke.Recipient, outer, payload []byte) ([]byte, error) {
+	aad := bytes.Replace(outer[4:], payload, make([]byte, len(payload)), 1)
+	return context.Open(aad, payload)
+}
+
+type rawExtension struct {
+	extType uint16
+	data    []byte
+}
+
+func extractRawExtensions(hello []byte) ([]rawExtension, bool) {
+	s := cryptobyte.String(hello)
+	var sessionID, cipherSuites, compressionMethods []byte
+	var extensions cryptobyte.String
+	if !s.Skip(4+2+32) || // header, version and random
+		!readUint8LengthPrefixed(&s, &sessionID) ||
+		!readUint16LengthPrefixed(&s, &cipherSuites) ||
+		!readUint8LengthPrefixed(&s, &compressionMethods) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, false
+	}
+	var exts []rawExtension
+	for !extensions.Empty() {
+		var ext rawExtension
+		if !extensions.ReadUint16(&ext.extType) ||
+			!readUint16LengthPrefixed(&extensions, &ext.data) {
+			return nil, false
+		}
+		exts = append(exts, ext)
+	}
+	return exts, true
+}
+
+// decodeInnerClientHello reconstructs a ClientHelloInner from its encoded
+// form, restoring the legacy_session_id and any extensions referenced by
+// ech_outer_extensions from outer. See RFC 9849, Section 5.1.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	errInvalid := errors.New("tls: invalid ClientHelloInner")
+
+	s := cryptobyte.String(encoded)
+	var versionAndRandom, sessionID, cipherSuites, compressionMethods []byte
+	var extensions cryptobyte.String
+	if !s.ReadBytes(&versionAndRandom, 2+32) ||
+		!readUint8LengthPrefixed(&s, &sessionID) || len(sessionID) != 0 ||
+		!readUint16LengthPrefixed(&s, &cipherSuites) ||
+		!readUint8LengthPrefixed(&s, &compressionMethods) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errInvalid
+	}
+	// The padding must be all zeroes.
+	for _, b := range s {
+		if b != 0 {
+			return nil, errInvalid
+		}
+	}
+
+	outerExts, ok := extractRawExtensions(outer.marshal())
+	if !ok {
+		return nil, errInvalid
+	}
+
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(typeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(versionAndRandom)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(outer.sessionId)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(cipherSuites)
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(compressionMethods)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			// Referenced outer extensions must appear in the same relative
+			// order as in ClientHelloOuter, so i only moves forward.
+			i := 0
+			for !extensions.Empty() {
+				var extType uint16
+				var extData cryptobyte.String
+				if !extensions.ReadUint16(&extType) ||
+					!extensions.ReadUint16LengthPrefixed(&extData) {
+					b.SetError(errInvalid)
+					return
+				}
+				if extType != extensionECHOuterExtensions {
+					b.AddUint16(extType)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(extData)
+					})
+					continue
+				}
+				var refs cryptobyte.String
+				if !extData.ReadUint8LengthPrefixed(&refs) || refs.Empty() || !extData.Empty() {
+					b.SetError(errInvalid)
+					return
+				}
+				for !refs.Empty() {
+					var ref uint16
+					if !refs.ReadUint16(&ref) || ref == extensionEncryptedClientHello {
+						b.SetError(errInvalid)
+						return
+					}
+					for i < len(outerExts) && outerExts[i].extType != ref {
+						i++
+					}
+					if i == len(outerExts) {
+						b.SetError(errInvalid)
+						return
+					}
+					ext := outerExts[i]
+					b.AddUint16(ext.extType)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(ext.data)
+					})
+					i++
+				}
+			}
+		})
+	})
+	raw, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	inner := new(clientHelloMsg)
+	if !inner.unmarshal(raw) {
+		return nil, errInvalid
+	}
+	if !bytes.Equal(inner.encryptedClientHello, []byte{uint8(innerECHExt)}) {
+		return nil, errInvalidECHExt
+	}
+	// ECH requires TLS 1.3, see RFC 9849, Section 6.1.
+	offersTLS13 := false
+	for _, v := range inner.supportedVersions {
+		if v&0x0f0f == 0x0a0a && v>>8 == v&0xff {
+			continue // GREASE, see RFC 8701
+		}
+		if v < VersionTLS13 {
+			return nil, errors.New("tls: ClientHelloInner offers versions older than TLS 1.3")
+		}
+		if v == VersionTLS13 {
+			offersTLS13 = true
+		}
+	}
+	if !offersTLS13 {
+		return nil, errors.New("tls: ClientHelloInner does not offer TLS 1.3")
+	}
+	return inner, nil
+}
+
+// buildRetryConfigList returns an ECHConfigList of the keys marked
+// SendAsRetry, or nil if there are none.
+func buildRetryConfigList(keys []EncryptedClientHelloKey) []byte {
+	var b cryptobyte.Builder
+	var found bool
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, key := range keys {
+			if key.SendAsRetry {
+				found = true
+				b.AddBytes(key.Config)
+			}
+		}
+	})
+	if !found {
+		return nil
+	}
+	return b.BytesOrPanic()
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hpke"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	echPublicName = "public.example.golang"
+	echSecretName = "secret.example.golang"
+)
+
+// newTestECHKey generates an X25519 ECH key and its ECHConfig.
+func newTestECHKey(t *testing.T, configID uint8, publicName string, sendAsRetry bool) EncryptedClientHelloKey {
+	pub, priv, err := hpke.DHKEM_X25519_HKDF_SHA256.GenerateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b cryptobyte.Builder
+	b.AddUint16(extensionEncryptedClientHello)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(configID)
+		b.AddUint16(uint16(hpke.DHKEM_X25519_HKDF_SHA256))
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(pub)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(uint16(hpke.KDF_HKDF_SHA256))
+			b.AddUint16(uint16(hpke.AEAD_AES_128_GCM))
+			b.AddUint16(uint16(hpke.KDF_HKDF_SHA256))
+			b.AddUint16(uint16(hpke.AEAD_ChaCha20Poly1305))
+		})
+		b.AddUint8(32) // maximum_name_length
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(publicName))
+		})
+		b.AddUint16(0) // extensions
+	})
+	return EncryptedClientHelloKey{
+		Config:      b.BytesOrPanic(),
+		PrivateKey:  priv,
+		SendAsRetry: sendAsRetry,
+	}
+}
+
+func echConfigList(keys ...EncryptedClientHelloKey) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, key := range keys {
+			b.AddBytes(key.Config)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+// echTestConfigs returns a client and server Config pair that trust a freshly
+// generated certificate valid for both the public and the secret name.
+func echTestConfigs(t *testing.T) (client, server *Config) {
+	now := time.Now()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ECH test"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{echPublicName, echSecretName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	client = &Config{
+		ServerName: echSecretName,
+		RootCAs:    roots,
+		MinVersion: VersionTLS13,
+	}
+	server = &Config{
+		Certificates: []Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}},
+		MinVersion: VersionTLS13,
+	}
+	return client, server
+}
+
+func TestECHAccepted(t *testing.T) {
+	clientConfig, serverConfig := echTestConfigs(t)
+	key := newTestECHKey(t, 1, echPublicName, true)
+	clientConfig.EncryptedClientHelloConfigList = echConfigList(key)
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{key}
+
+	ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cs.ECHAccepted {
+		t.Error("client did not report ECH as accepted")
+	}
+	if !ss.ECHAccepted {
+		t.Error("server did not report ECH as accepted")
+	}
+	if ss.ServerName != echSecretName {
+		t.Errorf("server saw ServerName %q, want %q", ss.ServerName, echSecretName)
+	}
+}
+
+func TestECHAcceptedHelloRetryRequest(t *testing.T) {
+	clientConfig, serverConfig := echTestConfigs(t)
+	key := newTestECHKey(t, 1, echPublicName, true)
+	clientConfig.EncryptedClientHelloConfigList = echConfigList(key)
+	clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{key}
+	serverConfig.CurvePreferences = []CurveID{CurveP256}
+
+	ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cs.ECHAccepted || !ss.ECHAccepted {
+		t.Errorf("ECH not accepted after HelloRetryRequest: client %v, server %v", cs.ECHAccepted, ss.ECHAccepted)
+	}
+	if ss.ServerName != echSecretName {
+		t.Errorf("server saw ServerName %q, want %q", ss.ServerName, echSecretName)
+	}
+}
+
+func TestECHRejectedRetry(t *testing.T) {
+	clientConfig, serverConfig := echTestConfigs(t)
+	stale := newTestECHKey(t, 1, echPublicName, false)
+	current := newTestECHKey(t, 2, echPublicName, true)
+	clientConfig.EncryptedClientHelloConfigList = echConfigList(stale)
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{current}
+
+	c, s := localPipe(t)
+	done := make(chan error)
+	var ss ConnectionState
+	go func() {
+		defer close(done)
+		server := Server(s, serverConfig)
+		defer server.Close()
+		err := server.Handshake()
+		ss = server.ConnectionState()
+		done <- err
+	}()
+	client := Client(c, clientConfig)
+	err := client.Handshake()
+	client.Close()
+	<-done
+
+	rejection, ok := err.(*ECHRejectionError)
+	if !ok {
+		t.Fatalf("expected an ECHRejectionError, got %v", err)
+	}
+	if ss.ECHAccepted {
+		t.Error("server reported ECH as accepted")
+	}
+	if ss.ServerName != echPublicName {
+		t.Errorf("server saw ServerName %q, want %q", ss.ServerName, echPublicName)
+	}
+	list, err := parseECHConfigList(rejection.RetryConfigList)
+	if err != nil {
+		t.Fatalf("failed to parse retry configs: %v", err)
+	}
+	if len(list) != 1 || list[0].ConfigID != 2 {
+		t.Fatalf("unexpected retry configs: %+v", list)
+	}
+
+	clientConfig.EncryptedClientHelloConfigList = rejection.RetryConfigList
+	ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if !cs.ECHAccepted || !ss.ECHAccepted {
+		t.Errorf("ECH not accepted on retry: client %v, server %v", cs.ECHAccepted, ss.ECHAccepted)
+	}
+}
+
+func TestECHRejectedBadCertificate(t *testing.T) {
+	clientConfig, serverConfig := echTestConfigs(t)
+	key := newTestECHKey(t, 1, "wrong.example.golang", false)
+	clientConfig.EncryptedClientHelloConfigList = echConfigList(key)
+	// InsecureSkipVerify must not disable verification of the public name.
+	clientConfig.InsecureSkipVerify = true
+
+	_, _, err := testHandshake(t, clientConfig, serverConfig)
+	if err == nil {
+		t.Fatal("handshake succeeded with a certificate not valid for the public name")
+	}
+}
+
+func TestECHKeyRotation(t *testing.T) {
+	clientConfig, serverConfig := echTestConfigs(t)
+	oldKey := newTestECHKey(t, 1, echPublicName, false)
+	newKey := newTestECHKey(t, 1, echPublicName, true)
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{newKey, oldKey}
+
+	for _, key := range []EncryptedClientHelloKey{oldKey, newKey} {
+		clientConfig.EncryptedClientHelloConfigList = echConfigList(key)
+		ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cs.ECHAccepted || !ss.ECHAccepted {
+			t.Errorf("ECH not accepted: client %v, server %v", cs.ECHAccepted, ss.ECHAccepted)
+		}
+	}
+}
+
+func TestECHMinVersion(t *testing.T) {
+	clientConfig, _ := echTestConfigs(t)
+	key := newTestECHKey(t, 1, echPublicName, true)
+	clientConfig.EncryptedClientHelloConfigList = echConfigList(key)
+	clientConfig.MinVersion = VersionTLS12
+
+	c, s := localPipe(t)
+	defer c.Close()
+	defer s.Close()
+	err := Client(c, clientConfig).Handshake()
+	if err == nil || !strings.Contains(err.Error(), "MinVersion") {
+		t.Fatalf("expected a MinVersion error, got %v", err)
+	}
+}
+
+func TestParseECHConfigList(t *testing.T) {
+	key := newTestECHKey(t, 7, echPublicName, false)
+	unknown := []byte{0xfe, 0x0c, 0x00, 0x02, 0xaa, 0xbb}
+	list, err := parseECHConfigList(echConfigList(EncryptedClientHelloKey{Config: unknown}, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ConfigID != 7 || string(list[0].PublicName) != echPublicName {
+		t.Fatalf("unexpected configs: %+v", list)
+	}
+	if _, err := parseECHConfigList(key.Config); err == nil {
+		t.Error("parsed an ECHConfig without the list length prefix")
+	}
+}
@@ -32,27 +32,39 @@ type clientHandshakeState struct {
 	session      *ClientSessionState
 }
 
-func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
+func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, *echClientContext, error) {
 	config := c.config
 	if len(config.ServerName) == 0 && !config.InsecureSkipVerify {
-		return nil, nil, errors.New("tls: either ServerName or InsecureSkipVerify must be specified in the tls.Config")
+		return nil, nil, nil, errors.New("tls: either ServerName or InsecureSkipVerify must be specified in the tls.Config")
 	}
 
 	nextProtosLength := 0
 	for _, proto := range config.NextProtos {
 		if l := len(proto); l == 0 || l > 255 {
-			return nil, nil, errors.New("tls: invalid NextProtos value")
+			return nil, nil, nil, errors.New("tls: invalid NextProtos value")
 		} else {
 			nextProtosLength += 1 + l
 		}
 	}
 	if nextProtosLength > 0xffff {
-		return nil, nil, errors.New("tls: NextProtos values too large")
+		return nil, nil, nil, errors.New("tls: NextProtos values too large")
 	}
 
 	supportedVersions := config.supportedVersions()
+	if config.EncryptedClientHelloConfigList != nil {
+		// ECH requires TLS 1.3, see RFC 9849, Section 6.1.
+		if config.MinVersion != 0 && config.MinVersion < VersionTLS13 {
+			return nil, nil, nil, errors.New("tls: MinVersion must be VersionTLS13 if EncryptedClientHelloConfigList is set")
+		}
+		supportedVersions = nil
+		for _, v := range config.supportedVersions() {
+			if v >= VersionTLS13 {
+				supportedVersions = append(supportedVersions, v)
+			}
+		}
+	}
 	if len(supportedVersions) == 0 {
-		return nil, nil, errors.New("tls: no supported versions satisfy MinVersion and MaxVersion")
+		return nil, nil, nil, errors.New("tls: no supported versions satisfy MinVersion and MaxVersion")
 	}
 
 	clientHelloVersion := supportedVersions[0]
@@ -103,14 +115,17 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 
 	_, err := io.ReadFull(config.rand(), hello.random)
 	if err != nil {
-		return nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+		return nil, nil, nil, errors.New("tls: short read from Rand: " + err.Error())
 	}
 
 	// A random session ID is used to detect when the server accepted a ticket
 	// and is resuming a session (see RFC 5077). In TLS 1.3, it's always set as
-	// a compatibility measure (see RFC 8446, Section 4.1.2).
-	if _, err := io.ReadFull(config.rand(), hello.sessionId); err != nil {
-		return nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+	// a compatibility measure (see RFC 8446, Section 4.1.2), except over QUIC
+	// which doesn't use the compatibility mode (see RFC 9001, Section 8.4).
+	if c.quic != nil {
+		hello.sessionId = nil
+	} else if _, err := io.ReadFull(config.rand(), hello.sessionId); err != nil {
+		return nil, nil, nil, errors.New("tls: short read from Rand: " + err.Error())
 	}
 
 	if hello.vers >= VersionTLS12 {
@@ -123,16 +138,70 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 
 		curveID := config.curvePreferences()[0]
 		if _, ok := curveForCurveID(curveID); curveID != X25519 && !ok {
-			return nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
+			return nil, nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
 		}
 		params, err = generateECDHEParameters(config.rand(), curveID)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
 	}
 
-	return hello, params, nil
+	if c.quic != nil {
+		p, err := c.quicGetTransportParameters()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if p == nil {
+			p = []byte{}
+		}
+		hello.quicTransportParameters = p
+	}
+
+	var ech *echClientContext
+	if config.EncryptedClientHelloConfigList != nil {
+		echConfigs, err := parseECHConfigList(config.EncryptedClientHelloConfigList)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		echConfig, cs := pickECHConfig(echConfigs)
+		if echConfig == nil {
+			return nil, nil, nil, errors.New("tls: EncryptedClientHelloConfigList contains no supported configs")
+		}
+		ech = &echClientContext{config: echConfig, kdfID: cs.KDFID, aeadID: cs.AEADID}
+		info := append([]byte("tls ech\x00"), echConfig.raw...)
+		ech.encapsulatedKey, ech.hpkeContext, err = cs.suite(echConfig.KemID).SetupBaseSender(
+			config.rand(), echConfig.PublicKey, info)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hello.encryptedClientHello = []byte{uint8(innerECHExt)}
+		// Until the server accepts ECH, the server is authenticated with
+		// the public name. See RFC 9849, Section 6.1.6.
+		c.serverName = string(echConfig.PublicName)
+	}
+
+	return hello, params, ech, nil
+}
+
+// makeOuterClientHello returns the ClientHelloOuter that carries inner
+// encrypted, see RFC 9849, Section 6.1. inner must be complete, including
+// its PSK binders.
+func (c *Conn) makeOuterClientHello(inner *clientHelloMsg, ech *echClientContext) (*clientHelloMsg, error) {
+	outer := *inner
+	outer.raw = nil
+	outer.random = make([]byte, 32)
+	if _, err := io.ReadFull(c.config.rand(), outer.random); err != nil {
+		return nil, errors.New("tls: short read from Rand: " + err.Error())
+	}
+	outer.serverName = string(ech.config.PublicName)
+	outer.earlyData = false
+	outer.pskIdentities = nil
+	outer.pskBinders = nil
+	if err := ech.updateOuterECHExtension(&outer, inner, true); err != nil {
+		return nil, err
+	}
+	return &outer, nil
 }
 
 func (c *Conn) clientHandshake() (err error) {
@@ -144,7 +213,7 @@ func (c *Conn) clientHandshake() (err error) {
 	// need to be reset.
 	c.didResume = false
 
-	hello, ecdheParams, err := c.makeClientHello()
+	hello, ecdheParams, ech, err := c.makeClientHello()
 	if err != nil {
 		return err
 	}
@@ -164,10 +233,35 @@ func (c *Conn) clientHandshake() (err error) {
 		}()
 	}
 
+	// With ECH, hello becomes the ClientHelloOuter that is sent on the wire,
+	// while the handshake state tracks the ClientHelloInner separately until
+	// the server signals whether it accepted ECH.
+	if ech != nil {
+		ech.innerHello = hello
+		if hello, err = c.makeOuterClientHello(hello, ech); err != nil {
+			return err
+		}
+	}
+
 	if _, err := c.writeRecord(recordTypeHandshake, hello.marshal()); err != nil {
 		return err
 	}
 
+	earlyDataHello := hello
+	if ech != nil {
+		earlyDataHello = ech.innerHello
+	}
+	if earlyDataHello.earlyData {
+		suite := cipherSuiteTLS13ByID(session.cipherSuite)
+		transcript := suite.hash.New()
+		transcript.Write(earlyDataHello.marshal())
+		earlyTrafficSecret := suite.deriveSecret(earlySecret, clientEarlyTrafficLabel, transcript)
+		if err := c.config.writeKeyLog(keyLogLabelEarlyTraffic, earlyDataHello.random, earlyTrafficSecret); err != nil {
+			return err
+		}
+		c.quicSetWriteSecret(QUICEncryptionLevelEarly, suite.id, earlyTrafficSecret)
+	}
+
 	msg, err := c.readHandshake()
 	if err != nil {
 		return err
@@ -192,6 +286,7 @@ func (c *Conn) clientHandshake() (err error) {
 			session:     session,
 			earlySecret: earlySecret,
 			binderKey:   binderKey,
+			echContext:  ech,
 		}
 
 		// In TLS 1.3, session tickets are delivered after the handshake.
@@ -240,7 +335,10 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (cacheKey string,
 	}
 
 	// Try to resume a previously negotiated TLS session, if available.
-	cacheKey = clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
+	cacheKey = c.clientSessionCacheKey()
+	if cacheKey == "" {
+		return "", nil, nil, nil
+	}
 	session, ok := c.config.ClientSessionCache.Get(cacheKey)
 	if !ok || session == nil {
 		return cacheKey, nil, nil, nil
@@ -312,6 +410,19 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (cacheKey string,
 		return cacheKey, nil, nil, nil
 	}
 
+	// 0-RTT requires the same cipher suite and ALPN protocol as the original
+	// connection. See RFC 8446, Section 4.2.10.
+	if c.quic != nil && session.earlyData &&
+		mutualCipherSuiteTLS13(hello.cipherSuites, session.cipherSuite) != nil {
+		hello.earlyData = session.alpnProtocol == ""
+		for _, proto := range hello.alpnProtocols {
+			if proto == session.alpnProtocol {
+				hello.earlyData = true
+				break
+			}
+		}
+	}
+
 	// Set the pre_shared_key extension. See RFC 8446, Section 4.2.11.1.
 	ticketAge := uint32(c.config.time().Sub(session.receivedAt) / time.Millisecond)
 	identity := pskIdentity{
@@ -820,11 +931,19 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		certs[i] = cert
 	}
 
-	if !c.config.InsecureSkipVerify {
+	// If ECH was rejected, the certificate must be valid for the public name
+	// of the ECHConfig, regardless of InsecureSkipVerify, as it's used to
+	// authenticate the retry configs. See RFC 9849, Section 6.1.7.
+	echRejected := c.config.EncryptedClientHelloConfigList != nil && !c.echAccepted
+	if !c.config.InsecureSkipVerify || echRejected {
+		dnsName := c.config.ServerName
+		if echRejected {
+			dnsName = c.serverName
+		}
 		opts := x509.VerifyOptions{
 			Roots:         c.config.RootCAs,
 			CurrentTime:   c.config.time(),
-			DNSName:       c.config.ServerName,
+			DNSName:       dnsName,
 			Intermediates: x509.NewCertPool(),
 		}
 		for _, cert := range certs[1:] {
@@ -838,7 +957,7 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		}
 	}
 
-	if c.config.VerifyPeerCertificate != nil {
+	if c.config.VerifyPeerCertificate != nil && !echRejected {
 		if err := c.config.VerifyPeerCertificate(certificates, c.verifiedChains); err != nil {
 			c.sendAlert(alertBadCertificate)
 			return err
@@ -968,11 +1087,14 @@ func (c *Conn) getClientCertificate(cri *CertificateRequestInfo) (*Certificate,
 
 // clientSessionCacheKey returns a key used to cache sessionTickets that could
 // be used to resume previously negotiated TLS sessions with a server.
-func clientSessionCacheKey(serverAddr net.Addr, config *Config) string {
-	if len(config.ServerName) > 0 {
-		return config.ServerName
+func (c *Conn) clientSessionCacheKey() string {
+	if len(c.config.ServerName) > 0 {
+		return c.config.ServerName
+	}
+	if c.conn != nil {
+		return c.conn.RemoteAddr().String()
 	}
-	return serverAddr.String()
+	return ""
 }
 
 // mutualProtocol finds the mutual Next Protocol Negotiation or ALPN protocol
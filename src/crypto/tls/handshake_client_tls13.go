@@ -9,6 +9,7 @@ import (
 	"crypto"
 	"crypto/hmac"
 	"crypto/rsa"
+	"crypto/subtle"
 	"errors"
 	"hash"
 	"sync/atomic"
@@ -25,6 +26,8 @@ type clientHandshakeStateTLS13 struct {
 	earlySecret []byte
 	binderKey   []byte
 
+	echContext *echClientContext
+
 	certReq       *certificateRequestMsgTLS13
 	usingPSK      bool
 	sentDummyCCS  bool
@@ -35,7 +38,8 @@ type clientHandshakeStateTLS13 struct {
 }
 
 // handshake requires hs.c, hs.hello, hs.serverHello, hs.ecdheParams, and,
-// optionally, hs.session, hs.earlySecret and hs.binderKey to be set.
+// optionally, hs.session, hs.earlySecret, hs.binderKey and hs.echContext to
+// be set. If hs.echContext is set, hs.hello is the ClientHelloOuter.
 func (hs *clientHandshakeStateTLS13) handshake() error {
 	c := hs.c
 
@@ -58,6 +62,11 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 	hs.transcript = hs.suite.hash.New()
 	hs.transcript.Write(hs.hello.marshal())
 
+	if hs.echContext != nil {
+		hs.echContext.innerTranscript = hs.suite.hash.New()
+		hs.echContext.innerTranscript.Write(hs.echContext.innerHello.marshal())
+	}
+
 	if bytes.Equal(hs.serverHello.random, helloRetryRequestRandom) {
 		if err := hs.sendDummyChangeCipherSpec(); err != nil {
 			return err
@@ -67,6 +76,12 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		}
 	}
 
+	if hs.echContext != nil && !hs.echContext.rejected {
+		if err := hs.checkECHAcceptance(); err != nil {
+			return err
+		}
+	}
+
 	hs.transcript.Write(hs.serverHello.marshal())
 
 	c.buffering = true
@@ -98,11 +113,62 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		return err
 	}
 
+	// If ECH was rejected, the handshake was only completed to authenticate
+	// the retry configs, and the connection must not be used. See RFC 9849,
+	// Section 6.1.6.
+	if hs.echContext != nil && hs.echContext.rejected {
+		c.sendAlert(alertECHRequired)
+		return &ECHRejectionError{RetryConfigList: hs.echContext.retryConfigs}
+	}
+
 	atomic.StoreUint32(&c.handshakeStatus, 1)
 
 	return nil
 }
 
+// checkECHAcceptance checks the ECH acceptance signal in the ServerHello, and
+// if present switches the handshake over to the ClientHelloInner. See RFC
+// 9849, Section 6.1.4.
+func (hs *clientHandshakeStateTLS13) checkECHAcceptance() error {
+	c := hs.c
+	ech := hs.echContext
+
+	// The signal is the last 8 bytes of ServerHello.random, and is computed
+	// with them set to zero.
+	raw := hs.serverHello.marshal()
+	confTranscript := cloneHash(ech.innerTranscript, hs.suite.hash)
+	if confTranscript == nil {
+		return c.sendAlert(alertInternalError)
+	}
+	confTranscript.Write(raw[:30])
+	confTranscript.Write(make([]byte, 8))
+	confTranscript.Write(raw[38:])
+	confirmation := echAcceptConfirmation(hs.suite, ech.innerHello.random,
+		echAcceptConfirmationLabel, confTranscript)
+	if subtle.ConstantTimeCompare(confirmation, hs.serverHello.random[24:]) != 1 {
+		if ech.hrrAccepted {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: server rejected ECH after accepting it in a HelloRetryRequest")
+		}
+		hs.rejectECH()
+		return nil
+	}
+
+	hs.hello = ech.innerHello
+	hs.transcript = ech.innerTranscript
+	c.echAccepted = true
+	c.serverName = ""
+	return nil
+}
+
+// rejectECH continues the handshake with the ClientHelloOuter.
+func (hs *clientHandshakeStateTLS13) rejectECH() {
+	hs.echContext.rejected = true
+	if hs.echContext.innerHello.earlyData {
+		hs.c.quicRejectedEarlyData()
+	}
+}
+
 // checkServerHelloOrHRR does validity checks that apply to both ServerHello and
 // HelloRetryRequest messages. It sets hs.suite.
 func (hs *clientHandshakeStateTLS13) checkServerHelloOrHRR() error {
@@ -163,6 +229,9 @@ func (hs *clientHandshakeStateTLS13) checkServerHelloOrHRR() error {
 // sendDummyChangeCipherSpec sends a ChangeCipherSpec record for compatibility
 // with middleboxes that didn't implement TLS correctly. See RFC 8446, Appendix D.4.
 func (hs *clientHandshakeStateTLS13) sendDummyChangeCipherSpec() error {
+	if hs.c.quic != nil {
+		return nil
+	}
 	if hs.sentDummyCCS {
 		return nil
 	}
@@ -185,6 +254,40 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	hs.transcript.Write(chHash)
 	hs.transcript.Write(hs.serverHello.marshal())
 
+	// With ECH, the HelloRetryRequest signals whether the server accepted the
+	// ClientHelloInner, which is then the one that gets updated and encrypted
+	// into the second ClientHelloOuter. See RFC 9849, Section 6.1.5.
+	hello := hs.hello
+	if ech := hs.echContext; ech != nil {
+		innerCHHash := ech.innerTranscript.Sum(nil)
+		ech.innerTranscript.Reset()
+		ech.innerTranscript.Write([]byte{typeMessageHash, 0, 0, uint8(len(innerCHHash))})
+		ech.innerTranscript.Write(innerCHHash)
+
+		if hs.serverHello.encryptedClientHello != nil {
+			confTranscript := cloneHash(ech.innerTranscript, hs.suite.hash)
+			if confTranscript == nil {
+				return c.sendAlert(alertInternalError)
+			}
+			confTranscript.Write(bytes.Replace(hs.serverHello.marshal(),
+				hs.serverHello.encryptedClientHello, make([]byte, 8), 1))
+			confirmation := echAcceptConfirmation(hs.suite, ech.innerHello.random,
+				echHRRAcceptConfirmationLabel, confTranscript)
+			ech.hrrAccepted = subtle.ConstantTimeCompare(confirmation, hs.serverHello.encryptedClientHello) == 1
+		}
+
+		if ech.hrrAccepted {
+			ech.innerTranscript.Write(hs.serverHello.marshal())
+			hello = ech.innerHello
+			chHash = innerCHHash
+		} else {
+			hs.rejectECH()
+		}
+	} else if hs.serverHello.encryptedClientHello != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an unexpected encrypted_client_hello extension")
+	}
+
 	if hs.serverHello.serverShare.group != 0 {
 		c.sendAlert(alertDecodeError)
 		return errors.New("tls: received malformed key_share extension")
@@ -196,7 +299,7 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 		return errors.New("tls: received HelloRetryRequest without selected group")
 	}
 	curveOK := false
-	for _, id := range hs.hello.supportedCurves {
+	for _, id := range hello.supportedCurves {
 		if id == curveID {
 			curveOK = true
 			break
@@ -220,12 +323,19 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 		return err
 	}
 	hs.ecdheParams = params
-	hs.hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
+	hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
 
-	hs.hello.cookie = hs.serverHello.cookie
+	hello.cookie = hs.serverHello.cookie
 
-	hs.hello.raw = nil
-	if len(hs.hello.pskIdentities) > 0 {
+	// Early data is not allowed in the second ClientHello, and the first one
+	// is implicitly rejected. See RFC 8446, Section 4.2.10.
+	if hello.earlyData {
+		hello.earlyData = false
+		c.quicRejectedEarlyData()
+	}
+
+	hello.raw = nil
+	if len(hello.pskIdentities) > 0 {
 		pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite)
 		if pskSuite == nil {
 			return c.sendAlert(alertInternalError)
@@ -233,19 +343,29 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 		if pskSuite.hash == hs.suite.hash {
 			// Update binders and obfuscated_ticket_age.
 			ticketAge := uint32(c.config.time().Sub(hs.session.receivedAt) / time.Millisecond)
-			hs.hello.pskIdentities[0].obfuscatedTicketAge = ticketAge + hs.session.ageAdd
+			hello.pskIdentities[0].obfuscatedTicketAge = ticketAge + hs.session.ageAdd
 
 			transcript := hs.suite.hash.New()
 			transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
 			transcript.Write(chHash)
 			transcript.Write(hs.serverHello.marshal())
-			transcript.Write(hs.hello.marshalWithoutBinders())
+			transcript.Write(hello.marshalWithoutBinders())
 			pskBinders := [][]byte{hs.suite.finishedHash(hs.binderKey, transcript)}
-			hs.hello.updateBinders(pskBinders)
+			hello.updateBinders(pskBinders)
 		} else {
 			// Server selected a cipher suite incompatible with the PSK.
-			hs.hello.pskIdentities = nil
-			hs.hello.pskBinders = nil
+			hello.pskIdentities = nil
+			hello.pskBinders = nil
+		}
+	}
+
+	if hello != hs.hello {
+		hs.echContext.innerTranscript.Write(hello.marshal())
+		hs.hello.keyShares = hello.keyShares
+		hs.hello.cookie = hello.cookie
+		if err := hs.echContext.updateOuterECHExtension(hs.hello, hello, false); err != nil {
+			c.sendAlert(alertInternalError)
+			return err
 		}
 	}
 
@@ -291,6 +411,11 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		return errors.New("tls: malformed key_share extension")
 	}
 
+	if hs.serverHello.encryptedClientHello != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an encrypted_client_hello extension in a normal ServerHello")
+	}
+
 	if hs.serverHello.serverShare.group == 0 {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server did not send a key share")
@@ -346,10 +471,15 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 
 	clientSecret := hs.suite.deriveSecret(handshakeSecret,
 		clientHandshakeTrafficLabel, hs.transcript)
-	c.out.setTrafficSecret(hs.suite, clientSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, clientSecret)
 	serverSecret := hs.suite.deriveSecret(handshakeSecret,
 		serverHandshakeTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, serverSecret)
+
+	if c.quic != nil {
+		c.quicSetWriteSecret(QUICEncryptionLevelHandshake, hs.suite.id, clientSecret)
+		c.quicSetReadSecret(QUICEncryptionLevelHandshake, hs.suite.id, serverSecret)
+	}
 
 	err := c.config.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret)
 	if err != nil {
@@ -389,6 +519,32 @@ func (hs *clientHandshakeStateTLS13) readServerParameters() error {
 	}
 	c.clientProtocol = encryptedExtensions.alpnProtocol
 
+	if hs.echContext != nil && hs.echContext.rejected {
+		hs.echContext.retryConfigs = encryptedExtensions.echRetryConfigs
+	}
+
+	if c.quic != nil {
+		if encryptedExtensions.quicTransportParameters == nil {
+			// RFC 9001, Section 8.2.
+			c.sendAlert(alertMissingExtension)
+			return errors.New("tls: server did not send a quic_transport_parameters extension")
+		}
+		c.quicSetTransportParameters(encryptedExtensions.quicTransportParameters)
+	} else if encryptedExtensions.quicTransportParameters != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an unexpected quic_transport_parameters extension")
+	}
+
+	if encryptedExtensions.earlyData {
+		if !hs.hello.earlyData || !hs.usingPSK || hs.serverHello.selectedIdentity != 0 ||
+			hs.session.cipherSuite != hs.suite.id || hs.session.alpnProtocol != c.clientProtocol {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: server accepted 0-RTT with the wrong parameters")
+		}
+	} else if hs.hello.earlyData {
+		c.quicRejectedEarlyData()
+	}
+
 	return nil
 }
 
@@ -502,7 +658,7 @@ func (hs *clientHandshakeStateTLS13) readServerFinished() error {
 		clientApplicationTrafficLabel, hs.transcript)
 	serverSecret := hs.suite.deriveSecret(hs.masterSecret,
 		serverApplicationTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, serverSecret)
 
 	err = c.config.writeKeyLog(keyLogLabelClientTraffic, hs.hello.random, hs.trafficSecret)
 	if err != nil {
@@ -527,12 +683,20 @@ func (hs *clientHandshakeStateTLS13) sendClientCertificate() error {
 		return nil
 	}
 
-	cert, err := c.getClientCertificate(&CertificateRequestInfo{
-		AcceptableCAs:    hs.certReq.certificateAuthorities,
-		SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
-	})
-	if err != nil {
-		return err
+	var cert *Certificate
+	if hs.echContext != nil && hs.echContext.rejected {
+		// Don't authenticate to a server that rejected ECH, as it might not
+		// be the intended one. See RFC 9849, Section 6.1.7.
+		cert = new(Certificate)
+	} else {
+		var err error
+		cert, err = c.getClientCertificate(&CertificateRequestInfo{
+			AcceptableCAs:    hs.certReq.certificateAuthorities,
+			SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	certMsg := new(certificateMsgTLS13)
@@ -612,7 +776,11 @@ func (hs *clientHandshakeStateTLS13) sendClientFinished() error {
 		return err
 	}
 
-	c.out.setTrafficSecret(hs.suite, hs.trafficSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, hs.trafficSecret)
+
+	if c.quic != nil {
+		c.quicSetWriteSecret(QUICEncryptionLevelApplication, hs.suite.id, hs.trafficSecret)
+	}
 
 	if !c.config.SessionTicketsDisabled && c.config.ClientSessionCache != nil {
 		c.resumptionSecret = hs.suite.deriveSecret(hs.masterSecret,
@@ -636,6 +804,11 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 	if msg.lifetime == 0 {
 		return nil
 	}
+	// See RFC 9001, Section 4.6.1.
+	if c.quic != nil && msg.maxEarlyData != 0 && msg.maxEarlyData != 0xffffffff {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: invalid early data for QUIC connection")
+	}
 	lifetime := time.Duration(msg.lifetime) * time.Second
 	if lifetime > maxSessionTicketLifetime {
 		c.sendAlert(alertIllegalParameter)
@@ -662,9 +835,14 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 		nonce:              msg.nonce,
 		useBy:              c.config.time().Add(lifetime),
 		ageAdd:             msg.ageAdd,
+		earlyData:          c.quic != nil && msg.maxEarlyData == 0xffffffff,
+		alpnProtocol:       c.clientProtocol,
 	}
 
-	cacheKey := clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
+	cacheKey := c.clientSessionCacheKey()
+	if cacheKey == "" {
+		return nil
+	}
 	c.config.ClientSessionCache.Put(cacheKey, session)
 
 	return nil
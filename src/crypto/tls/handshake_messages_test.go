@@ -28,8 +28,6 @@ var tests = []interface{}{
 	&clientKeyExchangeMsg{},
 	&nextProtoMsg{},
 	&newSessionTicketMsg{},
-	&sessionState{},
-	&sessionStateTLS13{},
 	&encryptedExtensionsMsg{},
 	&endOfEarlyDataMsg{},
 	&keyUpdateMsg{},
@@ -194,6 +192,12 @@ func (*clientHelloMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 	if rand.Intn(10) > 5 {
 		m.earlyData = true
 	}
+	if rand.Intn(10) > 5 {
+		m.quicTransportParameters = randomBytes(rand.Intn(500), rand)
+	}
+	if rand.Intn(10) > 5 {
+		m.encryptedClientHello = randomBytes(rand.Intn(500)+1, rand)
+	}
 
 	return reflect.ValueOf(m)
 }
@@ -249,6 +253,9 @@ func (*serverHelloMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 		m.selectedIdentityPresent = true
 		m.selectedIdentity = uint16(rand.Intn(0xffff))
 	}
+	if rand.Intn(10) > 5 {
+		m.encryptedClientHello = randomBytes(8, rand)
+	}
 
 	return reflect.ValueOf(m)
 }
@@ -259,6 +266,15 @@ func (*encryptedExtensionsMsg) Generate(rand *rand.Rand, size int) reflect.Value
 	if rand.Intn(10) > 5 {
 		m.alpnProtocol = randomString(rand.Intn(32)+1, rand)
 	}
+	if rand.Intn(10) > 5 {
+		m.earlyData = true
+	}
+	if rand.Intn(10) > 5 {
+		m.quicTransportParameters = randomBytes(rand.Intn(500), rand)
+	}
+	if rand.Intn(10) > 5 {
+		m.echRetryConfigs = randomBytes(rand.Intn(500)+1, rand)
+	}
 
 	return reflect.ValueOf(m)
 }
@@ -320,37 +336,37 @@ func (*newSessionTicketMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 	return reflect.ValueOf(m)
 }
 
-func (*sessionState) Generate(rand *rand.Rand, size int) reflect.Value {
-	s := &sessionState{}
-	s.vers = uint16(rand.Intn(10000))
+func (*SessionState) Generate(rand *rand.Rand, size int) reflect.Value {
+	s := &SessionState{}
+	s.version = []uint16{VersionTLS10, VersionTLS12, VersionTLS13}[rand.Intn(3)]
 	s.cipherSuite = uint16(rand.Intn(10000))
-	s.masterSecret = randomBytes(rand.Intn(100), rand)
-	numCerts := rand.Intn(20)
-	s.certificates = make([][]byte, numCerts)
-	for i := 0; i < numCerts; i++ {
-		s.certificates[i] = randomBytes(rand.Intn(10)+1, rand)
+	if s.version == VersionTLS13 {
+		s.createdAt = uint64(rand.Int63())
 	}
-	return reflect.ValueOf(s)
-}
-
-func (*sessionStateTLS13) Generate(rand *rand.Rand, size int) reflect.Value {
-	s := &sessionStateTLS13{}
-	s.cipherSuite = uint16(rand.Intn(10000))
-	s.resumptionSecret = randomBytes(rand.Intn(100)+1, rand)
-	s.createdAt = uint64(rand.Int63())
-	for i := 0; i < rand.Intn(2)+1; i++ {
+	s.secret = randomBytes(rand.Intn(100)+1, rand)
+	for i := 0; i < rand.Intn(3); i++ {
+		s.Extra = append(s.Extra, randomBytes(rand.Intn(100), rand))
+	}
+	for i := 0; i < rand.Intn(3); i++ {
 		s.certificate.Certificate = append(
 			s.certificate.Certificate, randomBytes(rand.Intn(500)+1, rand))
 	}
-	if rand.Intn(10) > 5 {
+	if s.version != VersionTLS13 {
+		return reflect.ValueOf(s)
+	}
+	if len(s.certificate.Certificate) > 0 && rand.Intn(10) > 5 {
 		s.certificate.OCSPStaple = randomBytes(rand.Intn(100)+1, rand)
 	}
-	if rand.Intn(10) > 5 {
+	if len(s.certificate.Certificate) > 0 && rand.Intn(10) > 5 {
 		for i := 0; i < rand.Intn(2)+1; i++ {
 			s.certificate.SignedCertificateTimestamps = append(
 				s.certificate.SignedCertificateTimestamps, randomBytes(rand.Intn(500)+1, rand))
 		}
 	}
+	if rand.Intn(10) > 5 {
+		s.EarlyData = true
+		s.alpnProtocol = randomString(rand.Intn(20), rand)
+	}
 	return reflect.ValueOf(s)
 }
 
@@ -480,3 +496,29 @@ func TestRejectEmptySCT(t *testing.T) {
 		t.Fatal("Unmarshaled ServerHello with zero-length SCT")
 	}
 }
+
+func TestSessionStateRoundTrip(t *testing.T) {
+	rand := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ty := reflect.TypeOf(&SessionState{})
+	for i := 0; i < 100; i++ {
+		v, ok := quick.Value(ty, rand)
+		if !ok {
+			t.Fatal("failed to create value")
+		}
+		s1 := v.Interface().(*SessionState)
+		b, err := s1.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s2, err := ParseSessionState(b)
+		if err != nil {
+			t.Fatalf("failed to parse %#v: %v", s1, err)
+		}
+		if !reflect.DeepEqual(s1, s2) {
+			t.Fatalf("got:%#v want:%#v %x", s2, s1, b)
+		}
+		if _, err := ParseSessionState(b[:len(b)-1]); err == nil {
+			t.Fatalf("parsed a truncated encoding of %#v", s1)
+		}
+	}
+}
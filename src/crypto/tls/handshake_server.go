@@ -28,7 +28,7 @@ type serverHandshakeState struct {
 	ecSignOk     bool
 	rsaDecryptOk bool
 	rsaSignOk    bool
-	sessionState *sessionState
+	sessionState *SessionState
 	finishedHash finishedHash
 	masterSecret []byte
 	cert         *Certificate
@@ -40,7 +40,7 @@ func (c *Conn) serverHandshake() error {
 	// encrypt the tickets with.
 	c.config.serverInitOnce.Do(func() { c.config.serverInit(nil) })
 
-	clientHello, err := c.readClientHello()
+	clientHello, ech, err := c.readClientHello()
 	if err != nil {
 		return err
 	}
@@ -49,6 +49,7 @@ func (c *Conn) serverHandshake() error {
 		hs := serverHandshakeStateTLS13{
 			c:           c,
 			clientHello: clientHello,
+			echContext:  ech,
 		}
 		return hs.handshake()
 	}
@@ -69,7 +70,11 @@ func (hs *serverHandshakeState) handshake() error {
 
 	// For an overview of TLS handshaking, see RFC 5246, Section 7.3.
 	c.buffering = true
-	if hs.checkForResumption() {
+	resume, err := hs.checkForResumption()
+	if err != nil {
+		return err
+	}
+	if resume {
 		// The client has included a session ticket and so we do an abbreviated handshake.
 		if err := hs.doResumeHandshake(); err != nil {
 			return err
@@ -131,22 +136,34 @@ func (hs *serverHandshakeState) handshake() error {
 }
 
 // readClientHello reads a ClientHello message and selects the protocol version.
-func (c *Conn) readClientHello() (*clientHelloMsg, error) {
+// If the client used Encrypted Client Hello and the server accepted it, the
+// returned ClientHello is the decrypted ClientHelloInner and the
+// echServerContext is not nil.
+func (c *Conn) readClientHello() (*clientHelloMsg, *echServerContext, error) {
 	msg, err := c.readHandshake()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	clientHello, ok := msg.(*clientHelloMsg)
 	if !ok {
 		c.sendAlert(alertUnexpectedMessage)
-		return nil, unexpectedMessageError(clientHello, msg)
+		return nil, nil, unexpectedMessageError(clientHello, msg)
+	}
+
+	var ech *echServerContext
+	if len(clientHello.encryptedClientHello) != 0 && len(c.config.EncryptedClientHelloKeys) != 0 &&
+		c.config.maxSupportedVersion() >= VersionTLS13 {
+		clientHello, ech, err = c.processECHClientHello(clientHello, c.config.EncryptedClientHelloKeys)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if c.config.GetConfigForClient != nil {
 		chi := clientHelloInfo(c, clientHello)
 		if newConfig, err := c.config.GetConfigForClient(chi); err != nil {
 			c.sendAlert(alertInternalError)
-			return nil, err
+			return nil, nil, err
 		} else if newConfig != nil {
 			newConfig.serverInitOnce.Do(func() { newConfig.serverInit(c.config) })
 			c.config = newConfig
@@ -160,13 +177,13 @@ func (c *Conn) readClientHello() (*clientHelloMsg, error) {
 	c.vers, ok = c.config.mutualVersion(clientVersions)
 	if !ok {
 		c.sendAlert(alertProtocolVersion)
-		return nil, fmt.Errorf("tls: client offered only unsupported versions: %x", clientVersions)
+		return nil, nil, fmt.Errorf("tls: client offered only unsupported versions: %x", clientVersions)
 	}
 	c.haveVers = true
 	c.in.version = c.vers
 	c.out.version = c.vers
 
-	return clientHello, nil
+	return clientHello, ech, nil
 }
 
 func (hs *serverHandshakeState) processClientHello() error {
@@ -328,55 +345,51 @@ func (hs *serverHandshakeState) pickCipherSuite() error {
 }
 
 // checkForResumption reports whether we should perform resumption on this connection.
-func (hs *serverHandshakeState) checkForResumption() bool {
+func (hs *serverHandshakeState) checkForResumption() (bool, error) {
 	c := hs.c
 
-	if c.config.SessionTicketsDisabled {
-		return false
+	if c.config.SessionTicketsDisabled || len(hs.clientHello.sessionTicket) == 0 {
+		return false, nil
 	}
 
-	plaintext, usedOldKey := c.decryptTicket(hs.clientHello.sessionTicket)
-	if plaintext == nil {
-		return false
-	}
-	hs.sessionState = &sessionState{usedOldKey: usedOldKey}
-	ok := hs.sessionState.unmarshal(plaintext)
-	if !ok {
-		return false
+	sessionState, err := c.unwrapSession(hs.clientHello.sessionTicket)
+	if err != nil || sessionState == nil {
+		return false, err
 	}
 
 	// Never resume a session for a different TLS version.
-	if c.vers != hs.sessionState.vers {
-		return false
+	if c.vers != sessionState.version {
+		return false, nil
 	}
 
 	cipherSuiteOk := false
 	// Check that the client is still offering the ciphersuite in the session.
 	for _, id := range hs.clientHello.cipherSuites {
-		if id == hs.sessionState.cipherSuite {
+		if id == sessionState.cipherSuite {
 			cipherSuiteOk = true
 			break
 		}
 	}
 	if !cipherSuiteOk {
-		return false
+		return false, nil
 	}
 
 	// Check that we also support the ciphersuite from the session.
-	if !hs.setCipherSuite(hs.sessionState.cipherSuite, c.config.cipherSuites(), hs.sessionState.vers) {
-		return false
+	if !hs.setCipherSuite(sessionState.cipherSuite, c.config.cipherSuites(), sessionState.version) {
+		return false, nil
 	}
 
-	sessionHasClientCerts := len(hs.sessionState.certificates) != 0
+	sessionHasClientCerts := len(sessionState.certificate.Certificate) != 0
 	needClientCerts := requiresClientCert(c.config.ClientAuth)
 	if needClientCerts && !sessionHasClientCerts {
-		return false
+		return false, nil
 	}
 	if sessionHasClientCerts && c.config.ClientAuth == NoClientCert {
-		return false
+		return false, nil
 	}
 
-	return true
+	hs.sessionState = sessionState
+	return true, nil
 }
 
 func (hs *serverHandshakeState) doResumeHandshake() error {
@@ -396,12 +409,12 @@ func (hs *serverHandshakeState) doResumeHandshake() error {
 	}
 
 	if err := c.processCertsFromClient(Certificate{
-		Certificate: hs.sessionState.certificates,
+		Certificate: hs.sessionState.certificate.Certificate,
 	}); err != nil {
 		return err
 	}
 
-	hs.masterSecret = hs.sessionState.masterSecret
+	hs.masterSecret = hs.sessionState.secret
 
 	return nil
 }
@@ -662,18 +675,8 @@ func (hs *serverHandshakeState) sendSessionTicket() error {
 	c := hs.c
 	m := new(newSessionTicketMsg)
 
-	var certsFromClient [][]byte
-	for _, cert := range c.peerCertificates {
-		certsFromClient = append(certsFromClient, cert.Raw)
-	}
-	state := sessionState{
-		vers:         c.vers,
-		cipherSuite:  hs.suite.id,
-		masterSecret: hs.masterSecret,
-		certificates: certsFromClient,
-	}
 	var err error
-	m.ticket, err = c.encryptTicket(state.marshal())
+	m.ticket, err = c.wrapSession(c.sessionState(hs.suite.id, hs.masterSecret))
 	if err != nil {
 		return err
 	}
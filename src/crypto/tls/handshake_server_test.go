@@ -36,7 +36,7 @@ func testClientHelloFailure(t *testing.T, serverConfig *Config, m handshakeMessa
 		c.Close()
 	}()
 	conn := Server(s, serverConfig)
-	ch, err := conn.readClientHello()
+	ch, _, err := conn.readClientHello()
 	hs := serverHandshakeState{
 		c:           conn,
 		clientHello: ch,
@@ -1351,7 +1351,7 @@ func TestSNIGivenOnFailure(t *testing.T) {
 		c.Close()
 	}()
 	conn := Server(s, serverConfig)
-	ch, err := conn.readClientHello()
+	ch, _, err := conn.readClientHello()
 	hs := serverHandshakeState{
 		c:           conn,
 		clientHello: ch,
@@ -1595,3 +1595,100 @@ T+E0J8wlH24pgwQHzy7Ko2qLwn1b5PW8ecrlvP1g
 	serverConfig.MaxVersion = VersionTLS12
 	testHandshake(t, testConfig, serverConfig)
 }
+
+func TestSessionStateHooks(t *testing.T) {
+	t.Run("TLSv12", func(t *testing.T) { testSessionStateHooks(t, VersionTLS12) })
+	t.Run("TLSv13", func(t *testing.T) { testSessionStateHooks(t, VersionTLS13) })
+}
+
+func testSessionStateHooks(t *testing.T, version uint16) {
+	// Two servers sharing session ticket keys, as in a fleet of processes:
+	// the first one adds application data to the sessions it issues, the
+	// second one resumes them and checks the data.
+	serverConfig1 := testConfig.Clone()
+	serverConfig1.MaxVersion = version
+	serverConfig1.SetSessionTicketKeys([][32]byte{{1}})
+	serverConfig1.WrapSession = func(cs ConnectionState, s *SessionState) ([]byte, error) {
+		if cs.Version != version {
+			t.Errorf("WrapSession: got version %x, want %x", cs.Version, version)
+		}
+		s.Extra = append(s.Extra, []byte("server one"))
+		return serverConfig1.EncryptTicket(cs, s)
+	}
+
+	var extra [][]byte
+	serverConfig2 := testConfig.Clone()
+	serverConfig2.MaxVersion = version
+	// The key used by the first server is now the old key.
+	serverConfig2.SetSessionTicketKeys([][32]byte{{2}, {1}})
+	serverConfig2.UnwrapSession = func(identity []byte, cs ConnectionState) (*SessionState, error) {
+		s, err := serverConfig2.DecryptTicket(identity, cs)
+		if s != nil {
+			extra = s.Extra
+		}
+		return s, err
+	}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.MaxVersion = version
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+
+	if _, cs, err := testHandshake(t, clientConfig, serverConfig1); err != nil {
+		t.Fatal(err)
+	} else if cs.DidResume {
+		t.Fatal("first handshake resumed a session")
+	}
+	if _, cs, err := testHandshake(t, clientConfig, serverConfig2); err != nil {
+		t.Fatal(err)
+	} else if !cs.DidResume {
+		t.Fatal("second handshake did not resume the session")
+	}
+	if len(extra) != 1 || string(extra[0]) != "server one" {
+		t.Errorf("got extra data %q, want [\"server one\"]", extra)
+	}
+
+	// A server storing sessions itself, and returning opaque handles.
+	store := make(map[string][]byte)
+	serverConfig3 := testConfig.Clone()
+	serverConfig3.MaxVersion = version
+	serverConfig3.WrapSession = func(cs ConnectionState, s *SessionState) ([]byte, error) {
+		b, err := s.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		id := fmt.Sprintf("session %d", len(store))
+		store[id] = b
+		return []byte(id), nil
+	}
+	serverConfig3.UnwrapSession = func(identity []byte, cs ConnectionState) (*SessionState, error) {
+		b, ok := store[string(identity)]
+		if !ok {
+			return nil, nil
+		}
+		return ParseSessionState(b)
+	}
+
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	if _, cs, err := testHandshake(t, clientConfig, serverConfig3); err != nil {
+		t.Fatal(err)
+	} else if cs.DidResume {
+		t.Fatal("first handshake resumed a session")
+	}
+	if len(store) == 0 {
+		t.Fatal("WrapSession was not called")
+	}
+	if _, cs, err := testHandshake(t, clientConfig, serverConfig3); err != nil {
+		t.Fatal(err)
+	} else if !cs.DidResume {
+		t.Fatal("handshake did not resume the stored session")
+	}
+
+	// An UnwrapSession error aborts the handshake.
+	serverConfig3.UnwrapSession = func(identity []byte, cs ConnectionState) (*SessionState, error) {
+		return nil, errors.New("session store unavailable")
+	}
+	if _, _, err := testHandshake(t, clientConfig, serverConfig3); err == nil ||
+		!strings.Contains(err.Error(), "session store unavailable") {
+		t.Errorf("got error %v, want session store error", err)
+	}
+}
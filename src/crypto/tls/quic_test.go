@@ -0,0 +1,390 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testQUICConn struct {
+	t           *testing.T
+	conn        *QUICConn
+	readSecret  map[QUICEncryptionLevel]suiteSecret
+	writeSecret map[QUICEncryptionLevel]suiteSecret
+	gotParams   []byte
+	complete    bool
+	rejected    bool // got a QUICRejectedEarlyData event
+
+	// ticketEarlyData is passed to SendSessionTicket by servers.
+	ticketEarlyData bool
+}
+
+type suiteSecret struct {
+	suite  uint16
+	secret []byte
+}
+
+func newTestQUICClient(t *testing.T, config *Config) *testQUICConn {
+	q := &testQUICConn{t: t}
+	q.conn = QUICClient(&QUICConfig{TLSConfig: config})
+	return q
+}
+
+func newTestQUICServer(t *testing.T, config *Config) *testQUICConn {
+	q := &testQUICConn{t: t}
+	q.conn = QUICServer(&QUICConfig{TLSConfig: config})
+	return q
+}
+
+func (q *testQUICConn) setReadSecret(level QUICEncryptionLevel, suite uint16, secret []byte) {
+	if _, ok := q.writeSecret[level]; !ok && level != QUICEncryptionLevelEarly {
+		q.t.Errorf("SetReadSecret for level %v called before SetWriteSecret", level)
+	}
+	if level == QUICEncryptionLevelApplication && !q.complete {
+		q.t.Errorf("SetReadSecret for level %v called before HandshakeComplete", level)
+	}
+	if _, ok := q.readSecret[level]; ok {
+		q.t.Errorf("SetReadSecret for level %v called twice", level)
+	}
+	if q.readSecret == nil {
+		q.readSecret = map[QUICEncryptionLevel]suiteSecret{}
+	}
+	switch level {
+	case QUICEncryptionLevelHandshake, QUICEncryptionLevelApplication, QUICEncryptionLevelEarly:
+		q.readSecret[level] = suiteSecret{suite, append([]byte(nil), secret...)}
+	default:
+		q.t.Errorf("SetReadSecret for unexpected level %v", level)
+	}
+}
+
+func (q *testQUICConn) setWriteSecret(level QUICEncryptionLevel, suite uint16, secret []byte) {
+	if _, ok := q.writeSecret[level]; ok {
+		q.t.Errorf("SetWriteSecret for level %v called twice", level)
+	}
+	if q.writeSecret == nil {
+		q.writeSecret = map[QUICEncryptionLevel]suiteSecret{}
+	}
+	switch level {
+	case QUICEncryptionLevelHandshake, QUICEncryptionLevelApplication, QUICEncryptionLevelEarly:
+		q.writeSecret[level] = suiteSecret{suite, append([]byte(nil), secret...)}
+	default:
+		q.t.Errorf("SetWriteSecret for unexpected level %v", level)
+	}
+}
+
+var errTransportParametersRequired = errors.New("transport parameters required")
+
+func runTestQUICConnection(ctx context.Context, cli, srv *testQUICConn, onEvent func(e QUICEvent, src, dst *testQUICConn) bool) error {
+	a, b := cli, srv
+	for _, c := range []*testQUICConn{a, b} {
+		if !c.conn.conn.quic.started {
+			if err := c.conn.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	idleCount := 0
+	for {
+		e := a.conn.NextEvent()
+		if onEvent != nil && onEvent(e, a, b) {
+			continue
+		}
+		switch e.Kind {
+		case QUICNoEvent:
+			idleCount++
+			if idleCount == 2 {
+				if !a.complete || !b.complete {
+					return errors.New("handshake incomplete")
+				}
+				return nil
+			}
+			a, b = b, a
+		case QUICSetReadSecret:
+			a.setReadSecret(e.Level, e.Suite, e.Data)
+		case QUICSetWriteSecret:
+			a.setWriteSecret(e.Level, e.Suite, e.Data)
+		case QUICWriteData:
+			if err := b.conn.HandleData(e.Level, e.Data); err != nil {
+				return err
+			}
+		case QUICTransportParameters:
+			a.gotParams = append([]byte{}, e.Data...)
+		case QUICTransportParametersRequired:
+			return errTransportParametersRequired
+		case QUICRejectedEarlyData:
+			a.rejected = true
+		case QUICHandshakeDone:
+			a.complete = true
+			if a == srv {
+				opts := QUICSessionTicketOptions{EarlyData: a.ticketEarlyData}
+				if err := srv.conn.SendSessionTicket(opts); err != nil {
+					return err
+				}
+			}
+		}
+		if e.Kind != QUICNoEvent {
+			idleCount = 0
+		}
+	}
+}
+
+func testQUICConfig() *Config {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	config.ServerName = "example.golang"
+	return config
+}
+
+func TestQUICConnection(t *testing.T) {
+	config := testQUICConfig()
+
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+
+	srv := newTestQUICServer(t, config)
+	srv.conn.SetTransportParameters(nil)
+
+	if err := runTestQUICConnection(context.Background(), cli, srv, nil); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+	defer cli.conn.Close()
+	defer srv.conn.Close()
+
+	if _, ok := cli.readSecret[QUICEncryptionLevelHandshake]; !ok {
+		t.Errorf("client has no Handshake secret")
+	}
+	if _, ok := cli.readSecret[QUICEncryptionLevelApplication]; !ok {
+		t.Errorf("client has no Application secret")
+	}
+	if _, ok := srv.readSecret[QUICEncryptionLevelHandshake]; !ok {
+		t.Errorf("server has no Handshake secret")
+	}
+	if _, ok := srv.readSecret[QUICEncryptionLevelApplication]; !ok {
+		t.Errorf("server has no Application secret")
+	}
+	for _, level := range []QUICEncryptionLevel{QUICEncryptionLevelHandshake, QUICEncryptionLevelApplication} {
+		if _, ok := cli.readSecret[level]; !ok {
+			t.Errorf("client has no %v read secret", level)
+		}
+		if _, ok := srv.readSecret[level]; !ok {
+			t.Errorf("server has no %v read secret", level)
+		}
+		if !reflect.DeepEqual(cli.readSecret[level], srv.writeSecret[level]) {
+			t.Errorf("client read secret does not match server write secret for level %v", level)
+		}
+		if !reflect.DeepEqual(cli.writeSecret[level], srv.readSecret[level]) {
+			t.Errorf("client write secret does not match server read secret for level %v", level)
+		}
+	}
+
+	cs := cli.conn.ConnectionState()
+	if cs.Version != VersionTLS13 || !cs.HandshakeComplete || cs.DidResume {
+		t.Errorf("unexpected client ConnectionState: %+v", cs)
+	}
+}
+
+func TestQUICSessionResumption(t *testing.T) {
+	clientConfig := testQUICConfig()
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+
+	serverConfig := testQUICConfig()
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli, srv, nil); err != nil {
+		t.Fatalf("error during first connection handshake: %v", err)
+	}
+	if cli.conn.ConnectionState().DidResume {
+		t.Errorf("first connection unexpectedly used session resumption")
+	}
+
+	cli2 := newTestQUICClient(t, clientConfig)
+	cli2.conn.SetTransportParameters(nil)
+	srv2 := newTestQUICServer(t, serverConfig)
+	srv2.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli2, srv2, nil); err != nil {
+		t.Fatalf("error during second connection handshake: %v", err)
+	}
+	if !cli2.conn.ConnectionState().DidResume {
+		t.Errorf("second connection did not use session resumption")
+	}
+	if cli2.rejected {
+		t.Errorf("early data was rejected, but was never offered")
+	}
+}
+
+func TestQUICEarlyData(t *testing.T) {
+	clientConfig := testQUICConfig()
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	clientConfig.NextProtos = []string{"h3"}
+
+	serverConfig := testQUICConfig()
+	serverConfig.NextProtos = []string{"h3"}
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(nil)
+	srv.ticketEarlyData = true
+	if err := runTestQUICConnection(context.Background(), cli, srv, nil); err != nil {
+		t.Fatalf("error during first connection handshake: %v", err)
+	}
+	if cli.conn.ConnectionState().DidResume {
+		t.Errorf("first connection unexpectedly used session resumption")
+	}
+
+	cli2 := newTestQUICClient(t, clientConfig)
+	cli2.conn.SetTransportParameters(nil)
+	srv2 := newTestQUICServer(t, serverConfig)
+	srv2.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli2, srv2, nil); err != nil {
+		t.Fatalf("error during second connection handshake: %v", err)
+	}
+	if !cli2.conn.ConnectionState().DidResume {
+		t.Errorf("second connection did not use session resumption")
+	}
+	if cli2.rejected {
+		t.Errorf("client reports early data was rejected")
+	}
+	early, ok := cli2.writeSecret[QUICEncryptionLevelEarly]
+	if !ok {
+		t.Fatalf("client has no Early write secret")
+	}
+	if !reflect.DeepEqual(early, srv2.readSecret[QUICEncryptionLevelEarly]) {
+		t.Errorf("client Early write secret does not match server Early read secret")
+	}
+	if cs := cli2.conn.ConnectionState(); cs.NegotiatedProtocol != "h3" {
+		t.Errorf("got ALPN protocol %q, want %q", cs.NegotiatedProtocol, "h3")
+	}
+}
+
+func TestQUICEarlyDataDeclined(t *testing.T) {
+	clientConfig := testQUICConfig()
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+
+	serverConfig := testQUICConfig()
+	serverConfig.UnwrapSession = func(identity []byte, cs ConnectionState) (*SessionState, error) {
+		s, err := serverConfig.DecryptTicket(identity, cs)
+		if s != nil {
+			if !s.EarlyData {
+				t.Errorf("session does not allow early data")
+			}
+			s.EarlyData = false
+		}
+		return s, err
+	}
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(nil)
+	srv.ticketEarlyData = true
+	if err := runTestQUICConnection(context.Background(), cli, srv, nil); err != nil {
+		t.Fatalf("error during first connection handshake: %v", err)
+	}
+
+	cli2 := newTestQUICClient(t, clientConfig)
+	cli2.conn.SetTransportParameters(nil)
+	srv2 := newTestQUICServer(t, serverConfig)
+	srv2.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli2, srv2, nil); err != nil {
+		t.Fatalf("error during second connection handshake: %v", err)
+	}
+	if !cli2.conn.ConnectionState().DidResume {
+		t.Errorf("second connection did not use session resumption")
+	}
+	if _, ok := cli2.writeSecret[QUICEncryptionLevelEarly]; !ok {
+		t.Errorf("client did not offer early data")
+	}
+	if !cli2.rejected {
+		t.Errorf("client did not receive QUICRejectedEarlyData")
+	}
+	if _, ok := srv2.readSecret[QUICEncryptionLevelEarly]; ok {
+		t.Errorf("server accepted early data it declined")
+	}
+}
+
+func TestQUICTransportParameters(t *testing.T) {
+	config := testQUICConfig()
+
+	cliParams := "client params"
+	srvParams := "server params"
+
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters([]byte(cliParams))
+	srv := newTestQUICServer(t, config)
+	if err := runTestQUICConnection(context.Background(), cli, srv, nil); err != errTransportParametersRequired {
+		t.Fatalf("server handshake: got %v, want errTransportParametersRequired", err)
+	}
+	if string(srv.gotParams) != cliParams {
+		t.Errorf("server got client params %q, want %q", srv.gotParams, cliParams)
+	}
+
+	srv.conn.SetTransportParameters([]byte(srvParams))
+	if err := runTestQUICConnection(context.Background(), cli, srv, nil); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+	if string(cli.gotParams) != srvParams {
+		t.Errorf("client got server params %q, want %q", cli.gotParams, srvParams)
+	}
+}
+
+func TestQUICHandshakeError(t *testing.T) {
+	clientConfig := testQUICConfig()
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "name"
+
+	serverConfig := testQUICConfig()
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(nil)
+	err := runTestQUICConnection(context.Background(), cli, srv, nil)
+	if err == nil {
+		t.Fatal("unexpectedly completed a handshake with an untrusted certificate")
+	}
+	var alert AlertError
+	if !errors.As(err, &alert) || alert != AlertError(alertBadCertificate) {
+		t.Errorf("got error %v (alert %v), want alert %v", err, alert, AlertError(alertBadCertificate))
+	}
+}
+
+func TestQUICRejectsOldVersion(t *testing.T) {
+	config := testQUICConfig()
+	config.MinVersion = VersionTLS12
+	cli := newTestQUICClient(t, config)
+	if err := cli.conn.Start(context.Background()); err == nil {
+		t.Fatal("Start succeeded with a TLS 1.2 MinVersion")
+	}
+	if err := cli.conn.HandleData(QUICEncryptionLevelInitial, []byte{1}); err == nil {
+		t.Fatal("HandleData succeeded after a failed Start")
+	}
+}
+
+func TestQUICCanceledHandshake(t *testing.T) {
+	config := testQUICConfig()
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := cli.conn.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e := cli.conn.NextEvent(); e.Kind != QUICWriteData || e.Level != QUICEncryptionLevelInitial {
+		t.Fatalf("got first event %v at level %v, want a ClientHello", e.Kind, e.Level)
+	} else if !bytes.HasPrefix(e.Data, []byte{typeClientHello}) {
+		t.Fatalf("first handshake message is not a ClientHello")
+	}
+	cancel()
+	if err := cli.conn.Close(); err == nil {
+		t.Fatal("Close of a canceled handshake returned no error")
+	}
+}
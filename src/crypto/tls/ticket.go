@@ -14,149 +14,293 @@ import (
 	"errors"
 	"golang.org/x/crypto/cryptobyte"
 	"io"
+	"time"
 )
 
-// sessionState contains the information that is serialized into a session
-// ticket in order to later resume a connection.
-type sessionState struct {
-	vers         uint16
-	cipherSuite  uint16
-	masterSecret []byte
-	certificates [][]byte
+// A SessionState is a resumable session, as stored by a server in a session
+// ticket (TLS 1.2 and earlier) or in a pre-shared key identity (TLS 1.3).
+//
+// A SessionState is produced by a server at the end of a full handshake,
+// passed to Config.WrapSession, and later recovered by Config.UnwrapSession
+// when a client attempts to resume. It can be serialized with Bytes and
+// parsed with ParseSessionState, so that the storage of session state can
+// be shared by multiple processes or machines.
+type SessionState struct {
+	// The encoding depends on the protocol version. For TLS 1.2 and
+	// earlier it is
+	//
+	//   uint16 version;
+	//   uint16 cipher_suite;
+	//   opaque master_secret<0..2^16-1>;
+	//   uint16 certificate_count;
+	//   opaque certificate<0..2^32-1>[certificate_count];
+	//   Extra extra<0..2^24-1>;  /* only if not empty */
+	//
+	// and for TLS 1.3, in the language of RFC 8446, Section 3,
+	//
+	//   uint16 version = 0x0304;
+	//   uint8 revision;  /* 1 if early_data is allowed, 0 otherwise */
+	//   uint16 cipher_suite;
+	//   uint64 created_at;
+	//   opaque resumption_master_secret<1..2^8-1>;
+	//   CertificateEntry certificate_list<0..2^24-1>;
+	//   select (revision) {
+	//       case 0: Empty;
+	//       case 1: opaque alpn<0..2^8-1>;
+	//   };
+	//   Extra extra<0..2^24-1>;  /* only if not empty */
+	//
+	// where Extra is opaque<0..2^24-1>. Omitting an empty extra list keeps
+	// the encoding identical to the one used by previous versions of this
+	// package, so that tickets they issued can still be resumed.
+
+	// Extra is ignored by crypto/tls, but is encoded by Bytes and parsed by
+	// ParseSessionState.
+	//
+	// This allows Config.WrapSession and Config.UnwrapSession, or other
+	// users of Config.EncryptTicket and Config.DecryptTicket, to attach
+	// application data to a session, such as an authorization context or
+	// the identity of the key used to protect it. Each application should
+	// append its own entries rather than overwrite existing ones.
+	Extra [][]byte
+
+	// EarlyData indicates whether the ticket can be used for 0-RTT in a QUIC
+	// connection. The application may set this to false, if it is true, to
+	// decline to offer 0-RTT even if supported.
+	EarlyData bool
+
+	version     uint16
+	cipherSuite uint16
+	createdAt   uint64 // Only for TLS 1.3.
+	secret      []byte // Master secret, or resumption master secret in TLS 1.3.
+	certificate Certificate
+
+	// alpnProtocol is the protocol negotiated in the original connection,
+	// which must match for early data to be accepted. Only for TLS 1.3.
+	alpnProtocol string
+
 	// usedOldKey is true if the ticket from which this session came from
 	// was encrypted with an older key and thus should be refreshed.
 	usedOldKey bool
 }
 
-func (s *sessionState) marshal() []byte {
-	length := 2 + 2 + 2 + len(s.masterSecret) + 2
-	for _, cert := range s.certificates {
-		length += 4 + len(cert)
-	}
-
-	ret := make([]byte, length)
-	x := ret
-	x[0] = byte(s.vers >> 8)
-	x[1] = byte(s.vers)
-	x[2] = byte(s.cipherSuite >> 8)
-	x[3] = byte(s.cipherSuite)
-	x[4] = byte(len(s.masterSecret) >> 8)
-	x[5] = byte(len(s.masterSecret))
-	x = x[6:]
-	copy(x, s.masterSecret)
-	x = x[len(s.masterSecret):]
-
-	x[0] = byte(len(s.certificates) >> 8)
-	x[1] = byte(len(s.certificates))
-	x = x[2:]
-
-	for _, cert := range s.certificates {
-		x[0] = byte(len(cert) >> 24)
-		x[1] = byte(len(cert) >> 16)
-		x[2] = byte(len(cert) >> 8)
-		x[3] = byte(len(cert))
-		copy(x[4:], cert)
-		x = x[4+len(cert):]
-	}
-
-	return ret
-}
-
-func (s *sessionState) unmarshal(data []byte) bool {
-	if len(data) < 8 {
-		return false
+// Bytes encodes the session, including any private fields, so that it can be
+// parsed by ParseSessionState. The encoding contains secret values critical
+// to the security of future and possibly past sessions.
+//
+// The specific encoding should be considered opaque and may change
+// incompatibly between Go versions.
+func (s *SessionState) Bytes() ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint16(s.version)
+	if s.version == VersionTLS13 {
+		if s.EarlyData {
+			b.AddUint8(1) // revision
+		} else {
+			b.AddUint8(0) // revision
+		}
+		b.AddUint16(s.cipherSuite)
+		addUint64(&b, s.createdAt)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(s.secret)
+		})
+		marshalCertificate(&b, s.certificate)
+		if s.EarlyData {
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes([]byte(s.alpnProtocol))
+			})
+		}
+	} else {
+		b.AddUint16(s.cipherSuite)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(s.secret)
+		})
+		if len(s.certificate.Certificate) > 0xffff {
+			b.SetError(errors.New("tls: too many certificates in session"))
+		}
+		b.AddUint16(uint16(len(s.certificate.Certificate)))
+		for _, cert := range s.certificate.Certificate {
+			b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(cert)
+			})
+		}
 	}
-
-	s.vers = uint16(data[0])<<8 | uint16(data[1])
-	s.cipherSuite = uint16(data[2])<<8 | uint16(data[3])
-	masterSecretLen := int(data[4])<<8 | int(data[5])
-	data = data[6:]
-	if len(data) < masterSecretLen {
-		return false
+	if len(s.Extra) > 0 {
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, extra := range s.Extra {
+				b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(extra)
+				})
+			}
+		})
 	}
+	return b.Bytes()
+}
 
-	s.masterSecret = data[:masterSecretLen]
-	data = data[masterSecretLen:]
-
-	if len(data) < 2 {
+func (s *SessionState) unmarshal(data []byte) bool {
+	*s = SessionState{}
+	str := cryptobyte.String(data)
+	if !str.ReadUint16(&s.version) {
 		return false
 	}
-
-	numCerts := int(data[0])<<8 | int(data[1])
-	data = data[2:]
-
-	s.certificates = make([][]byte, numCerts)
-	for i := range s.certificates {
-		if len(data) < 4 {
+	if s.version == VersionTLS13 {
+		var revision uint8
+		if !str.ReadUint8(&revision) ||
+			revision > 1 ||
+			!str.ReadUint16(&s.cipherSuite) ||
+			!readUint64(&str, &s.createdAt) ||
+			!readUint8LengthPrefixed(&str, &s.secret) ||
+			len(s.secret) == 0 ||
+			!unmarshalCertificate(&str, &s.certificate) {
 			return false
 		}
-		certLen := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
-		data = data[4:]
-		if certLen < 0 {
+		if revision == 1 {
+			var alpn []byte
+			if !readUint8LengthPrefixed(&str, &alpn) {
+				return false
+			}
+			s.EarlyData = true
+			s.alpnProtocol = string(alpn)
+		}
+	} else {
+		var numCerts uint16
+		if !str.ReadUint16(&s.cipherSuite) ||
+			!readUint16LengthPrefixed(&str, &s.secret) ||
+			!str.ReadUint16(&numCerts) {
 			return false
 		}
-		if len(data) < certLen {
+		for i := 0; i < int(numCerts); i++ {
+			var certLen uint32
+			var cert []byte
+			if !str.ReadUint32(&certLen) || int(certLen) < 0 ||
+				!str.ReadBytes(&cert, int(certLen)) {
+				return false
+			}
+			s.certificate.Certificate = append(s.certificate.Certificate, cert)
+		}
+	}
+	if str.Empty() {
+		return true
+	}
+	var extra cryptobyte.String
+	if !str.ReadUint24LengthPrefixed(&extra) || extra.Empty() || !str.Empty() {
+		return false
+	}
+	for !extra.Empty() {
+		var e []byte
+		if !readUint24LengthPrefixed(&extra, &e) {
 			return false
 		}
-		s.certificates[i] = data[:certLen]
-		data = data[certLen:]
+		s.Extra = append(s.Extra, e)
+	}
+	return true
+}
+
+// ParseSessionState parses a SessionState encoded by SessionState.Bytes.
+func ParseSessionState(data []byte) (*SessionState, error) {
+	s := new(SessionState)
+	if !s.unmarshal(data) {
+		return nil, errors.New("tls: invalid session encoding")
+	}
+	switch s.version {
+	case VersionTLS10, VersionTLS11, VersionTLS12, VersionTLS13:
+	default:
+		return nil, errors.New("tls: invalid session encoding")
 	}
+	return s, nil
+}
 
-	return len(data) == 0
+// sessionState returns a SessionState for the current connection, which
+// must have completed its handshake as a server. secret is the master secret
+// for TLS 1.2 and earlier, and the resumption master secret for TLS 1.3.
+func (c *Conn) sessionState(suite uint16, secret []byte) *SessionState {
+	var certsFromClient [][]byte
+	for _, cert := range c.peerCertificates {
+		certsFromClient = append(certsFromClient, cert.Raw)
+	}
+	s := &SessionState{
+		version:     c.vers,
+		cipherSuite: suite,
+		secret:      secret,
+		certificate: Certificate{Certificate: certsFromClient},
+	}
+	if c.vers == VersionTLS13 {
+		s.createdAt = uint64(c.config.time().Unix())
+		s.certificate.OCSPStaple = c.ocspResponse
+		s.certificate.SignedCertificateTimestamps = c.scts
+		s.alpnProtocol = c.clientProtocol
+	}
+	return s
 }
 
-// sessionStateTLS13 is the content of a TLS 1.3 session ticket. Its first
-// version (revision = 0) doesn't carry any of the information needed for 0-RTT
-// validation and the nonce is always empty.
-type sessionStateTLS13 struct {
-	// uint8 version  = 0x0304;
-	// uint8 revision = 0;
-	cipherSuite      uint16
-	createdAt        uint64
-	resumptionSecret []byte      // opaque resumption_master_secret<1..2^8-1>;
-	certificate      Certificate // CertificateEntry certificate_list<0..2^24-1>;
+// wrapSession produces the ticket or PSK identity sent to the client for
+// the session state s, using Config.WrapSession if set.
+func (c *Conn) wrapSession(s *SessionState) ([]byte, error) {
+	if c.config.WrapSession != nil {
+		return c.config.WrapSession(c.connectionStateLocked(), s)
+	}
+	return c.config.encryptTicket(s, c.config.ticketKeys())
 }
 
-func (m *sessionStateTLS13) marshal() []byte {
-	var b cryptobyte.Builder
-	b.AddUint16(VersionTLS13)
-	b.AddUint8(0) // revision
-	b.AddUint16(m.cipherSuite)
-	addUint64(&b, m.createdAt)
-	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
-		b.AddBytes(m.resumptionSecret)
-	})
-	marshalCertificate(&b, m.certificate)
-	return b.BytesOrPanic()
+// unwrapSession recovers the session state from a ticket or PSK identity
+// sent by the client, using Config.UnwrapSession if set. It returns nil if
+// the session can't be recovered and the handshake should proceed without
+// resumption.
+func (c *Conn) unwrapSession(identity []byte) (*SessionState, error) {
+	if c.config.UnwrapSession != nil {
+		s, err := c.config.UnwrapSession(identity, c.connectionStateLocked())
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, err
+		}
+		return s, nil
+	}
+	return c.config.decryptTicket(identity, c.config.ticketKeys()), nil
+}
+
+// EncryptTicket encrypts a session with the Config's session ticket keys,
+// as configured by SessionTicketKey or SetSessionTicketKeys (or generated at
+// random when the Config is first used by a server), for use as a session
+// ticket or PSK identity.
+//
+// EncryptTicket can be used as a Config.WrapSession implementation, for
+// example after adding entries to the session's Extra field.
+func (c *Config) EncryptTicket(cs ConnectionState, s *SessionState) ([]byte, error) {
+	c.serverInitOnce.Do(func() { c.serverInit(nil) })
+	keys := c.ticketKeys()
+	if len(keys) == 0 {
+		return nil, errors.New("tls: no session ticket keys available")
+	}
+	return c.encryptTicket(s, keys)
 }
 
-func (m *sessionStateTLS13) unmarshal(data []byte) bool {
-	*m = sessionStateTLS13{}
-	s := cryptobyte.String(data)
-	var version uint16
-	var revision uint8
-	return s.ReadUint16(&version) &&
-		version == VersionTLS13 &&
-		s.ReadUint8(&revision) &&
-		revision == 0 &&
-		s.ReadUint16(&m.cipherSuite) &&
-		readUint64(&s, &m.createdAt) &&
-		readUint8LengthPrefixed(&s, &m.resumptionSecret) &&
-		len(m.resumptionSecret) != 0 &&
-		unmarshalCertificate(&s, &m.certificate) &&
-		s.Empty()
+// DecryptTicket decrypts a ticket encrypted by Config.EncryptTicket, using
+// any of the Config's current session ticket keys. It can be used as a
+// Config.UnwrapSession implementation.
+//
+// If the ticket can't be decrypted or parsed, DecryptTicket returns
+// (nil, nil). Sessions encrypted with a key other than the first are
+// resumed, but the client is sent a fresh ticket.
+func (c *Config) DecryptTicket(identity []byte, cs ConnectionState) (*SessionState, error) {
+	c.serverInitOnce.Do(func() { c.serverInit(nil) })
+	return c.decryptTicket(identity, c.ticketKeys()), nil
 }
 
-func (c *Conn) encryptTicket(state []byte) ([]byte, error) {
+func (c *Config) encryptTicket(s *SessionState, keys []ticketKey) ([]byte, error) {
+	state, err := s.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
 	encrypted := make([]byte, ticketKeyNameLen+aes.BlockSize+len(state)+sha256.Size)
 	keyName := encrypted[:ticketKeyNameLen]
 	iv := encrypted[ticketKeyNameLen : ticketKeyNameLen+aes.BlockSize]
 	macBytes := encrypted[len(encrypted)-sha256.Size:]
 
-	if _, err := io.ReadFull(c.config.rand(), iv); err != nil {
+	if _, err := io.ReadFull(c.rand(), iv); err != nil {
 		return nil, err
 	}
-	key := c.config.ticketKeys()[0]
+	key := keys[0]
 	copy(keyName, key.keyName[:])
 	block, err := aes.NewCipher(key.aesKey[:])
 	if err != nil {
@@ -171,9 +315,9 @@ func (c *Conn) encryptTicket(state []byte) ([]byte, error) {
 	return encrypted, nil
 }
 
-func (c *Conn) decryptTicket(encrypted []byte) (plaintext []byte, usedOldKey bool) {
+func (c *Config) decryptTicket(encrypted []byte, keys []ticketKey) *SessionState {
 	if len(encrypted) < ticketKeyNameLen+aes.BlockSize+sha256.Size {
-		return nil, false
+		return nil
 	}
 
 	keyName := encrypted[:ticketKeyNameLen]
@@ -181,7 +325,6 @@ func (c *Conn) decryptTicket(encrypted []byte) (plaintext []byte, usedOldKey boo
 	macBytes := encrypted[len(encrypted)-sha256.Size:]
 	ciphertext := encrypted[ticketKeyNameLen+aes.BlockSize : len(encrypted)-sha256.Size]
 
-	keys := c.config.ticketKeys()
 	keyIndex := -1
 	for i, candidateKey := range keys {
 		if bytes.Equal(keyName, candidateKey.keyName[:]) {
@@ -191,7 +334,7 @@ func (c *Conn) decryptTicket(encrypted []byte) (plaintext []byte, usedOldKey boo
 	}
 
 	if keyIndex == -1 {
-		return nil, false
+		return nil
 	}
 	key := &keys[keyIndex]
 
@@ -200,15 +343,27 @@ func (c *Conn) decryptTicket(encrypted []byte) (plaintext []byte, usedOldKey boo
 	expected := mac.Sum(nil)
 
 	if subtle.ConstantTimeCompare(macBytes, expected) != 1 {
-		return nil, false
+		return nil
 	}
 
 	block, err := aes.NewCipher(key.aesKey[:])
 	if err != nil {
-		return nil, false
+		return nil
 	}
-	plaintext = make([]byte, len(ciphertext))
+	plaintext := make([]byte, len(ciphertext))
 	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
 
-	return plaintext, keyIndex > 0
+	s, err := ParseSessionState(plaintext)
+	if err != nil {
+		return nil
+	}
+	s.usedOldKey = keyIndex > 0
+	return s
+}
+
+// sessionExpired reports whether the TLS 1.3 session s is older than the
+// maximum lifetime of a session ticket.
+func (c *Config) sessionExpired(s *SessionState) bool {
+	createdAt := time.Unix(int64(s.createdAt), 0)
+	return c.time().Sub(createdAt) > maxSessionTicketLifetime
 }
@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pbe implements the block ciphers and padding shared by the
+// password-based encryption of PEM blocks in crypto/x509 and of PKCS #12
+// files in crypto/x509/pkcs12.
+package pbe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+)
+
+// A Cipher is a block cipher used in CBC mode.
+type Cipher struct {
+	// Name is the name of the cipher in the DEK-Info header
+	// of encrypted PEM blocks.
+	Name      string
+	New       func(key []byte) (cipher.Block, error)
+	KeySize   int
+	BlockSize int
+}
+
+var (
+	DESCBC     = &Cipher{"DES-CBC", des.NewCipher, 8, des.BlockSize}
+	DESEDE3CBC = &Cipher{"DES-EDE3-CBC", des.NewTripleDESCipher, 24, des.BlockSize}
+	AES128CBC  = &Cipher{"AES-128-CBC", aes.NewCipher, 16, aes.BlockSize}
+	AES192CBC  = &Cipher{"AES-192-CBC", aes.NewCipher, 24, aes.BlockSize}
+	AES256CBC  = &Cipher{"AES-256-CBC", aes.NewCipher, 32, aes.BlockSize}
+)
+
+// Encrypt pads data and encrypts it with block in CBC mode.
+//
+// The data is padded with n bytes of value n, for n between 1 and
+// the block size inclusive, as described in RFC 1423, section 1.1,
+// and RFC 8018, section 6.1.1. For example:
+//	[x y z 2 2]
+//	[x y 7 7 7 7 7 7 7]
+func Encrypt(block cipher.Block, iv, data []byte) []byte {
+	blockSize := block.BlockSize()
+	pad := blockSize - len(data)%blockSize
+	encrypted := make([]byte, len(data), len(data)+pad)
+	// We could save this copy by encrypting all the whole blocks in
+	// the data separately, but it doesn't seem worth the additional
+	// code.
+	copy(encrypted, data)
+	for i := 0; i < pad; i++ {
+		encrypted = append(encrypted, byte(pad))
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+	return encrypted
+}
+
+// Decrypt decrypts data, which must be a non-empty multiple of the
+// block size, with block in CBC mode and removes the padding added
+// by Encrypt. It reports whether the padding was valid; invalid
+// padding usually means that the key, and so the password, is wrong.
+func Decrypt(block cipher.Block, iv, data []byte) ([]byte, bool) {
+	blockSize := block.BlockSize()
+	dlen := len(data)
+	if dlen == 0 || dlen%blockSize != 0 {
+		panic("pbe: input not a non-empty multiple of the block size")
+	}
+	decrypted := make([]byte, dlen)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	last := int(decrypted[dlen-1])
+	if last == 0 || last > blockSize {
+		return nil, false
+	}
+	for _, val := range decrypted[dlen-last:] {
+		if int(val) != last {
+			return nil, false
+		}
+	}
+	return decrypted[:dlen-last], true
+}
@@ -9,10 +9,8 @@ package x509
 // implementation.
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/des"
 	"crypto/md5"
+	"crypto/x509/internal/pbe"
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
@@ -34,46 +32,18 @@ const (
 
 // rfc1423Algo holds a method for enciphering a PEM block.
 type rfc1423Algo struct {
-	cipher     PEMCipher
-	name       string
-	cipherFunc func(key []byte) (cipher.Block, error)
-	keySize    int
-	blockSize  int
+	cipher PEMCipher
+	*pbe.Cipher
 }
 
 // rfc1423Algos holds a slice of the possible ways to encrypt a PEM
 // block. The ivSize numbers were taken from the OpenSSL source.
-var rfc1423Algos = []rfc1423Algo{{
-	cipher:     PEMCipherDES,
-	name:       "DES-CBC",
-	cipherFunc: des.NewCipher,
-	keySize:    8,
-	blockSize:  des.BlockSize,
-}, {
-	cipher:     PEMCipher3DES,
-	name:       "DES-EDE3-CBC",
-	cipherFunc: des.NewTripleDESCipher,
-	keySize:    24,
-	blockSize:  des.BlockSize,
-}, {
-	cipher:     PEMCipherAES128,
-	name:       "AES-128-CBC",
-	cipherFunc: aes.NewCipher,
-	keySize:    16,
-	blockSize:  aes.BlockSize,
-}, {
-	cipher:     PEMCipherAES192,
-	name:       "AES-192-CBC",
-	cipherFunc: aes.NewCipher,
-	keySize:    24,
-	blockSize:  aes.BlockSize,
-}, {
-	cipher:     PEMCipherAES256,
-	name:       "AES-256-CBC",
-	cipherFunc: aes.NewCipher,
-	keySize:    32,
-	blockSize:  aes.BlockSize,
-},
+var rfc1423Algos = []rfc1423Algo{
+	{PEMCipherDES, pbe.DESCBC},
+	{PEMCipher3DES, pbe.DESEDE3CBC},
+	{PEMCipherAES128, pbe.AES128CBC},
+	{PEMCipherAES192, pbe.AES192CBC},
+	{PEMCipherAES256, pbe.AES256CBC},
 }
 
 // deriveKey uses a key derivation function to stretch the password into a key
@@ -81,7 +51,7 @@ var rfc1423Algos = []rfc1423Algo{{
 // the OpenSSL source.
 func (c rfc1423Algo) deriveKey(password, salt []byte) []byte {
 	hash := md5.New()
-	out := make([]byte, c.keySize)
+	out := make([]byte, c.KeySize)
 	var digest []byte
 
 	for i := 0; i < len(out); i += len(digest) {
@@ -132,14 +102,14 @@ func DecryptPEMBlock(b *pem.Block, password []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(iv) != ciph.blockSize {
+	if len(iv) != ciph.BlockSize {
 		return nil, errors.New("x509: incorrect IV size")
 	}
 
 	// Based on the OpenSSL implementation. The salt is the first 8 bytes
 	// of the initialization vector.
 	key := ciph.deriveKey(password, iv[:8])
-	block, err := ciph.cipherFunc(key)
+	block, err := ciph.New(key)
 	if err != nil {
 		return nil, err
 	}
@@ -148,33 +118,17 @@ func DecryptPEMBlock(b *pem.Block, password []byte) ([]byte, error) {
 		return nil, errors.New("x509: encrypted PEM data is not a multiple of the block size")
 	}
 
-	data := make([]byte, len(b.Bytes))
-	dec := cipher.NewCBCDecrypter(block, iv)
-	dec.CryptBlocks(data, b.Bytes)
-
 	// Blocks are padded using a scheme where the last n bytes of padding are all
 	// equal to n. It can pad from 1 to blocksize bytes inclusive. See RFC 1423.
-	// For example:
-	//	[x y z 2 2]
-	//	[x y 7 7 7 7 7 7 7]
 	// If we detect a bad padding, we assume it is an invalid password.
-	dlen := len(data)
-	if dlen == 0 || dlen%ciph.blockSize != 0 {
+	if len(b.Bytes) == 0 {
 		return nil, errors.New("x509: invalid padding")
 	}
-	last := int(data[dlen-1])
-	if dlen < last {
-		return nil, IncorrectPasswordError
-	}
-	if last == 0 || last > ciph.blockSize {
+	data, ok := pbe.Decrypt(block, iv, b.Bytes)
+	if !ok {
 		return nil, IncorrectPasswordError
 	}
-	for _, val := range data[dlen-last:] {
-		if int(val) != last {
-			return nil, IncorrectPasswordError
-		}
-	}
-	return data[:dlen-last], nil
+	return data, nil
 }
 
 // EncryptPEMBlock returns a PEM block of the specified type holding the
@@ -185,35 +139,25 @@ func EncryptPEMBlock(rand io.Reader, blockType string, data, password []byte, al
 	if ciph == nil {
 		return nil, errors.New("x509: unknown encryption mode")
 	}
-	iv := make([]byte, ciph.blockSize)
+	iv := make([]byte, ciph.BlockSize)
 	if _, err := io.ReadFull(rand, iv); err != nil {
 		return nil, errors.New("x509: cannot generate IV: " + err.Error())
 	}
 	// The salt is the first 8 bytes of the initialization vector,
 	// matching the key derivation in DecryptPEMBlock.
 	key := ciph.deriveKey(password, iv[:8])
-	block, err := ciph.cipherFunc(key)
+	block, err := ciph.New(key)
 	if err != nil {
 		return nil, err
 	}
-	enc := cipher.NewCBCEncrypter(block, iv)
-	pad := ciph.blockSize - len(data)%ciph.blockSize
-	encrypted := make([]byte, len(data), len(data)+pad)
-	// We could save this copy by encrypting all the whole blocks in
-	// the data separately, but it doesn't seem worth the additional
-	// code.
-	copy(encrypted, data)
 	// See RFC 1423, Section 1.1.
-	for i := 0; i < pad; i++ {
-		encrypted = append(encrypted, byte(pad))
-	}
-	enc.CryptBlocks(encrypted, encrypted)
+	encrypted := pbe.Encrypt(block, iv, data)
 
 	return &pem.Block{
 		Type: blockType,
 		Headers: map[string]string{
 			"Proc-Type": "4,ENCRYPTED",
-			"DEK-Info":  ciph.name + "," + hex.EncodeToString(iv),
+			"DEK-Info":  ciph.Name + "," + hex.EncodeToString(iv),
 		},
 		Bytes: encrypted,
 	}, nil
@@ -222,7 +166,7 @@ func EncryptPEMBlock(rand io.Reader, blockType string, data, password []byte, al
 func cipherByName(name string) *rfc1423Algo {
 	for i := range rfc1423Algos {
 		alg := &rfc1423Algos[i]
-		if alg.name == name {
+		if alg.Name == name {
 			return alg
 		}
 	}
@@ -0,0 +1,380 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkcs12
+
+import (
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/internal/pbe"
+	"crypto/x509/pkcs12/internal/rc2"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+)
+
+var (
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 12, 1, 3})
+	oidPBEWithSHAAnd128BitRC2CBC     = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 12, 1, 5})
+	oidPBEWithSHAAnd40BitRC2CBC      = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 12, 1, 6})
+
+	oidPBES2  = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 5, 13})
+	oidPBKDF2 = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 5, 12})
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 2, 7})
+	oidHMACWithSHA256 = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 2, 9})
+	oidHMACWithSHA384 = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 2, 10})
+	oidHMACWithSHA512 = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 2, 11})
+
+	oidDESEDE3CBC = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 3, 7})
+	oidAES128CBC  = asn1.ObjectIdentifier([]int{2, 16, 840, 1, 101, 3, 4, 1, 2})
+	oidAES192CBC  = asn1.ObjectIdentifier([]int{2, 16, 840, 1, 101, 3, 4, 1, 22})
+	oidAES256CBC  = asn1.ObjectIdentifier([]int{2, 16, 840, 1, 101, 3, 4, 1, 42})
+)
+
+// maxIterations is the largest iteration count accepted for the key
+// derivations of encrypted and MAC-protected data. The iteration counts
+// come from the input, and an attacker could otherwise make decoding a
+// small file take arbitrarily long. Encode uses at most 2048 iterations;
+// other tools use up to a few hundred thousand.
+const maxIterations = 1 << 20
+
+// checkIterations returns an error if the iteration count n is outside
+// the range accepted by Decode.
+func checkIterations(n int) error {
+	if n < 1 || n > maxIterations {
+		return errors.New("pkcs12: iteration count " + strconv.Itoa(n) + " out of range")
+	}
+	return nil
+}
+
+// pbeCipher is an abstraction of a PKCS #12 password-based cipher, defined
+// in RFC 7292, appendix C.
+type pbeCipher interface {
+	// create returns a cipher.Block given a key.
+	create(key []byte) (cipher.Block, error)
+	// deriveKey returns a key derived from the given password and salt.
+	deriveKey(salt, password []byte, iterations int) []byte
+	// deriveIV returns an IV derived from the given password and salt.
+	deriveIV(salt, password []byte, iterations int) []byte
+}
+
+type shaWithTripleDESCBC struct{}
+
+func (shaWithTripleDESCBC) create(key []byte) (cipher.Block, error) {
+	return pbe.DESEDE3CBC.New(key)
+}
+
+func (shaWithTripleDESCBC) deriveKey(salt, password []byte, iterations int) []byte {
+	return pbkdf(sha1.New, salt, password, iterations, keyID, 24)
+}
+
+func (shaWithTripleDESCBC) deriveIV(salt, password []byte, iterations int) []byte {
+	return pbkdf(sha1.New, salt, password, iterations, ivID, 8)
+}
+
+type shaWithRC2CBC struct {
+	keyLen int
+}
+
+func (c shaWithRC2CBC) create(key []byte) (cipher.Block, error) {
+	return rc2.New(key, len(key)*8)
+}
+
+func (c shaWithRC2CBC) deriveKey(salt, password []byte, iterations int) []byte {
+	return pbkdf(sha1.New, salt, password, iterations, keyID, c.keyLen)
+}
+
+func (shaWithRC2CBC) deriveIV(salt, password []byte, iterations int) []byte {
+	return pbkdf(sha1.New, salt, password, iterations, ivID, 8)
+}
+
+// pbeParams is the parameter structure of the PKCS #12 password-based
+// encryption algorithms.
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// pbes2Params reflects the ASN.1 PBES2-params structure of RFC 8018,
+// appendix A.4.
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params reflects the ASN.1 PBKDF2-params structure of RFC 8018,
+// appendix A.2. The salt is always the specified (OCTET STRING) choice.
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// pbes2Cipher describes a symmetric cipher usable as a PBES2 encryption
+// scheme.
+type pbes2Cipher struct {
+	oid asn1.ObjectIdentifier
+	*pbe.Cipher
+}
+
+var pbes2Ciphers = []pbes2Cipher{
+	{oidAES128CBC, pbe.AES128CBC},
+	{oidAES192CBC, pbe.AES192CBC},
+	{oidAES256CBC, pbe.AES256CBC},
+	{oidDESEDE3CBC, pbe.DESEDE3CBC},
+}
+
+func pbes2CipherByOID(oid asn1.ObjectIdentifier) *pbes2Cipher {
+	for i := range pbes2Ciphers {
+		if pbes2Ciphers[i].oid.Equal(oid) {
+			return &pbes2Ciphers[i]
+		}
+	}
+	return nil
+}
+
+// hmacHashes maps the PBKDF2 pseudo-random function identifiers to the
+// hash function underlying the HMAC.
+var hmacHashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash func() hash.Hash
+}{
+	{oidHMACWithSHA1, sha1.New},
+	{oidHMACWithSHA256, sha256.New},
+	{oidHMACWithSHA384, sha512.New384},
+	{oidHMACWithSHA512, sha512.New},
+}
+
+func hmacHashByOID(oid asn1.ObjectIdentifier) func() hash.Hash {
+	for _, h := range hmacHashes {
+		if h.oid.Equal(oid) {
+			return h.hash
+		}
+	}
+	return nil
+}
+
+// encodedPassword holds the two encodings of a password used by PKCS #12: the
+// zero-terminated BMPString required by the PKCS #12 key derivation and
+// the raw UTF-8 bytes used by PBKDF2.
+type encodedPassword struct {
+	bmp []byte
+	raw []byte
+}
+
+func newPassword(s string) (encodedPassword, error) {
+	bmp, err := bmpString(s)
+	if err != nil {
+		return encodedPassword{}, err
+	}
+	return encodedPassword{bmp: bmp, raw: []byte(s)}, nil
+}
+
+// pbDecrypterFor returns the block cipher and IV for decrypting data
+// encrypted with the given algorithm identifier and password.
+func pbDecrypterFor(algorithm pkix.AlgorithmIdentifier, pw encodedPassword) (cipher.Block, []byte, error) {
+	if algorithm.Algorithm.Equal(oidPBES2) {
+		return pbes2DecrypterFor(algorithm, pw)
+	}
+
+	var c pbeCipher
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+		c = shaWithTripleDESCBC{}
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd128BitRC2CBC):
+		c = shaWithRC2CBC{keyLen: 16}
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+		c = shaWithRC2CBC{keyLen: 5}
+	default:
+		return nil, nil, NotImplementedError("algorithm " + algorithm.Algorithm.String() + " is not supported")
+	}
+
+	var params pbeParams
+	if err := unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+	if err := checkIterations(params.Iterations); err != nil {
+		return nil, nil, err
+	}
+
+	key := c.deriveKey(params.Salt, pw.bmp, params.Iterations)
+	iv := c.deriveIV(params.Salt, pw.bmp, params.Iterations)
+
+	block, err := c.create(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, iv, nil
+}
+
+func pbes2DecrypterFor(algorithm pkix.AlgorithmIdentifier, pw encodedPassword) (cipher.Block, []byte, error) {
+	var params pbes2Params
+	if err := unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, NotImplementedError("PBES2 key derivation function " + params.KeyDerivationFunc.Algorithm.String() + " is not supported")
+	}
+	var kdfParams pbkdf2Params
+	if err := unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, nil, err
+	}
+	if err := checkIterations(kdfParams.Iterations); err != nil {
+		return nil, nil, err
+	}
+	h := sha1.New
+	if len(kdfParams.PRF.Algorithm) > 0 {
+		if h = hmacHashByOID(kdfParams.PRF.Algorithm); h == nil {
+			return nil, nil, NotImplementedError("PBKDF2 pseudo-random function " + kdfParams.PRF.Algorithm.String() + " is not supported")
+		}
+	}
+
+	c := pbes2CipherByOID(params.EncryptionScheme.Algorithm)
+	if c == nil {
+		return nil, nil, NotImplementedError("PBES2 encryption scheme " + params.EncryptionScheme.Algorithm.String() + " is not supported")
+	}
+	if kdfParams.KeyLength != 0 && kdfParams.KeyLength != c.KeySize {
+		return nil, nil, errors.New("pkcs12: PBKDF2 key length does not match the encryption scheme")
+	}
+	var iv []byte
+	if err := unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, err
+	}
+
+	key := pbkdf2(h, pw.raw, kdfParams.Salt, kdfParams.Iterations, c.KeySize)
+	block, err := c.New(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, errors.New("pkcs12: incorrect IV size")
+	}
+
+	return block, iv, nil
+}
+
+// pbDecrypt decrypts the data held by info, which must use one of the
+// supported password-based encryption algorithms, and removes its padding.
+func pbDecrypt(info decryptable, pw encodedPassword) ([]byte, error) {
+	block, iv, err := pbDecrypterFor(info.Algorithm(), pw)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := info.Data()
+	if len(encrypted) == 0 {
+		return nil, errors.New("pkcs12: empty encrypted data")
+	}
+	if len(encrypted)%block.BlockSize() != 0 {
+		return nil, errors.New("pkcs12: input is not a multiple of the block size")
+	}
+	decrypted, ok := pbe.Decrypt(block, iv, encrypted)
+	if !ok {
+		return nil, ErrDecryption
+	}
+	return decrypted, nil
+}
+
+// decryptable abstracts an object that contains ciphertext.
+type decryptable interface {
+	Algorithm() pkix.AlgorithmIdentifier
+	Data() []byte
+}
+
+// pbEncrypt encrypts plaintext with a freshly generated salt (and IV, for
+// PBES2) using the given algorithm and password. It returns the algorithm
+// identifier, including parameters, and the ciphertext.
+func pbEncrypt(rand io.Reader, alg asn1.ObjectIdentifier, plaintext []byte, pw encodedPassword, iterations, saltLen int) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, errors.New("pkcs12: cannot generate salt: " + err.Error())
+	}
+
+	var (
+		algorithm pkix.AlgorithmIdentifier
+		block     cipher.Block
+		iv        []byte
+		err       error
+	)
+	switch {
+	case alg.Equal(oidPBES2):
+		c := pbes2CipherByOID(oidAES256CBC)
+		key := pbkdf2(sha256.New, pw.raw, salt, iterations, c.KeySize)
+		if block, err = c.New(key); err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		iv = make([]byte, block.BlockSize())
+		if _, err := io.ReadFull(rand, iv); err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, errors.New("pkcs12: cannot generate IV: " + err.Error())
+		}
+
+		kdfParams, err := asn1.Marshal(pbkdf2Params{
+			Salt:       salt,
+			Iterations: iterations,
+			PRF: pkix.AlgorithmIdentifier{
+				Algorithm:  oidHMACWithSHA256,
+				Parameters: asn1.NullRawValue,
+			},
+		})
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		ivBytes, err := asn1.Marshal(iv)
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		params, err := asn1.Marshal(pbes2Params{
+			KeyDerivationFunc: pkix.AlgorithmIdentifier{
+				Algorithm:  oidPBKDF2,
+				Parameters: asn1.RawValue{FullBytes: kdfParams},
+			},
+			EncryptionScheme: pkix.AlgorithmIdentifier{
+				Algorithm:  c.oid,
+				Parameters: asn1.RawValue{FullBytes: ivBytes},
+			},
+		})
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		algorithm = pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: params},
+		}
+
+	default:
+		var c pbeCipher
+		switch {
+		case alg.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+			c = shaWithTripleDESCBC{}
+		case alg.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+			c = shaWithRC2CBC{keyLen: 5}
+		default:
+			return pkix.AlgorithmIdentifier{}, nil, NotImplementedError("algorithm " + alg.String() + " is not supported")
+		}
+		if block, err = c.create(c.deriveKey(salt, pw.bmp, iterations)); err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		iv = c.deriveIV(salt, pw.bmp, iterations)
+
+		params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: iterations})
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+		algorithm = pkix.AlgorithmIdentifier{
+			Algorithm:  alg,
+			Parameters: asn1.RawValue{FullBytes: params},
+		}
+	}
+
+	return algorithm, pbe.Encrypt(block, iv, plaintext), nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rc2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 2268, section 5.
+var rc2Tests = []struct {
+	key       string
+	bits      int
+	plaintext string
+	output    string
+}{
+	{"0000000000000000", 63, "0000000000000000", "ebb773f993278eff"},
+	{"ffffffffffffffff", 64, "ffffffffffffffff", "278b27e42e2f0d49"},
+	{"3000000000000000", 64, "1000000000000001", "30649edf9be7d2c2"},
+	{"88", 64, "0000000000000000", "61a8a244adacccf0"},
+	{"88bca90e90875a", 64, "0000000000000000", "6ccf4308974c267f"},
+	{"88bca90e90875a7f0f79c384627bafb2", 64, "0000000000000000", "1a807d272bbe5db1"},
+	{"88bca90e90875a7f0f79c384627bafb2", 128, "0000000000000000", "2269552ab0f85ca6"},
+	{"88bca90e90875a7f0f79c384627bafb216f80a6f85920584c42fceb0be255daf1e", 129, "0000000000000000", "5b78d3a43dfff1f1"},
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, tt := range rc2Tests {
+		key, _ := hex.DecodeString(tt.key)
+		plaintext, _ := hex.DecodeString(tt.plaintext)
+		want, _ := hex.DecodeString(tt.output)
+
+		c, err := New(key, tt.bits)
+		if err != nil {
+			t.Fatalf("New(%s, %d): %v", tt.key, tt.bits, err)
+		}
+
+		got := make([]byte, BlockSize)
+		c.Encrypt(got, plaintext)
+		if !bytes.Equal(got, want) {
+			t.Errorf("Encrypt(key=%s, bits=%d): got %x, want %x", tt.key, tt.bits, got, want)
+		}
+
+		c.Decrypt(got, got)
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Decrypt(key=%s, bits=%d): got %x, want %x", tt.key, tt.bits, got, plaintext)
+		}
+	}
+}
+
+func TestPiTableIsPermutation(t *testing.T) {
+	var seen [256]bool
+	for _, b := range piTable {
+		if seen[b] {
+			t.Fatalf("value %#x appears twice in piTable", b)
+		}
+		seen[b] = true
+	}
+}
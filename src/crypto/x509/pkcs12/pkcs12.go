@@ -0,0 +1,613 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pkcs12 implements encoding and decoding of PKCS #12 (also known as
+// PFX or P12) files, as defined in RFC 7292.
+//
+// Decoding supports certificates, PKCS #8 private keys (both plain and
+// password-encrypted), and the friendlyName and localKeyId bag attributes.
+// Content may be protected with the legacy PKCS #12 algorithms (RC2 and
+// triple DES with SHA-1 key derivation) or with PBES2, using PBKDF2 and AES
+// or triple DES, as produced by modern tools.
+//
+// Encoding produces files that are readable by OpenSSL, Java and Windows.
+//
+// This package is not intended to be a complete implementation of PKCS #12:
+// it only supports password integrity mode, and neither public-key privacy
+// mode nor CRL and secret bags.
+package pkcs12
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+)
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 7, 1})
+	oidEncryptedDataContentType = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 7, 6})
+
+	oidFriendlyName     = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 9, 20})
+	oidLocalKeyID       = asn1.ObjectIdentifier([]int{1, 2, 840, 113549, 1, 9, 21})
+	oidMicrosoftCSPName = asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 17, 1})
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+func (i encryptedContentInfo) Algorithm() pkix.AlgorithmIdentifier {
+	return i.ContentEncryptionAlgorithm
+}
+
+func (i encryptedContentInfo) Data() []byte { return i.EncryptedContent }
+
+type safeBag struct {
+	Id         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	Id    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+// unmarshal calls asn1.Unmarshal, but also returns an error if there is any
+// trailing data after unmarshaling.
+func unmarshal(in []byte, out interface{}) error {
+	trailing, err := asn1.Unmarshal(in, out)
+	if err != nil {
+		return err
+	}
+	if len(trailing) != 0 {
+		return errors.New("pkcs12: trailing data found")
+	}
+	return nil
+}
+
+// ToPEM converts all "safe bags" contained in pfxData to PEM blocks.
+// Bag attributes are stored as headers of the blocks; the friendlyName and
+// localKeyId attributes are recognized. Unknown attributes cause an error.
+func ToPEM(pfxData []byte, password string) ([]*pem.Block, error) {
+	bags, pw, err := getSafeContents(pfxData, password)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*pem.Block, 0, len(bags))
+	for _, bag := range bags {
+		block, err := convertBag(&bag, pw)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+func convertBag(bag *safeBag, pw encodedPassword) (*pem.Block, error) {
+	block := &pem.Block{
+		Headers: make(map[string]string),
+	}
+
+	for _, attribute := range bag.Attributes {
+		k, v, err := convertAttribute(&attribute)
+		if err != nil {
+			return nil, err
+		}
+		block.Headers[k] = v
+	}
+
+	switch {
+	case bag.Id.Equal(oidCertBag):
+		block.Type = "CERTIFICATE"
+		certsData, err := decodeCertBag(bag.Value.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		block.Bytes = certsData
+	case bag.Id.Equal(oidKeyBag), bag.Id.Equal(oidPKCS8ShroundedKeyBag):
+		block.Type = "PRIVATE KEY"
+
+		key, err := decodeKeyBag(bag, pw)
+		if err != nil {
+			return nil, err
+		}
+
+		if block.Bytes, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("pkcs12: don't know how to convert a safe bag of type " + bag.Id.String())
+	}
+	return block, nil
+}
+
+func decodeKeyBag(bag *safeBag, pw encodedPassword) (interface{}, error) {
+	if bag.Id.Equal(oidKeyBag) {
+		key, err := x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+		if err != nil {
+			return nil, errors.New("pkcs12: error parsing PKCS#8 private key: " + err.Error())
+		}
+		return key, nil
+	}
+	return decodePkcs8ShroudedKeyBag(bag.Value.Bytes, pw)
+}
+
+func convertAttribute(attribute *pkcs12Attribute) (key, value string, err error) {
+	isString := false
+
+	switch {
+	case attribute.Id.Equal(oidFriendlyName):
+		key = "friendlyName"
+		isString = true
+	case attribute.Id.Equal(oidLocalKeyID):
+		key = "localKeyId"
+	case attribute.Id.Equal(oidMicrosoftCSPName):
+		// This key is chosen to match OpenSSL.
+		key = "Microsoft CSP Name"
+		isString = true
+	default:
+		return "", "", errors.New("pkcs12: unknown attribute with OID " + attribute.Id.String())
+	}
+
+	if isString {
+		var v asn1.RawValue
+		if err := unmarshal(attribute.Value.Bytes, &v); err != nil {
+			return "", "", err
+		}
+		if value, err = decodeBMPString(v.Bytes); err != nil {
+			return "", "", err
+		}
+	} else {
+		var id []byte
+		if err := unmarshal(attribute.Value.Bytes, &id); err != nil {
+			return "", "", err
+		}
+		value = hex.EncodeToString(id)
+	}
+
+	return key, value, nil
+}
+
+// Decode extracts a certificate and private key from pfxData. This function
+// assumes that there is only one certificate and only one private key in the
+// pfxData; if there are more use DecodeChain.
+func Decode(pfxData []byte, password string) (privateKey interface{}, certificate *x509.Certificate, err error) {
+	privateKey, certificate, caCerts, err := DecodeChain(pfxData, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(caCerts) != 0 {
+		return nil, nil, errors.New("pkcs12: expected exactly two safe bags in the PFX PDU")
+	}
+	return privateKey, certificate, nil
+}
+
+// DecodeChain extracts a certificate, a CA certificate chain, and a private
+// key from pfxData. It expects exactly one private key; the certificate is
+// the one whose public key matches it, or the first one if none does.
+func DecodeChain(pfxData []byte, password string) (privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, err error) {
+	bags, pw, err := getSafeContents(pfxData, password)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var certs []*x509.Certificate
+	var keyID []byte
+	for i := range bags {
+		bag := &bags[i]
+		switch {
+		case bag.Id.Equal(oidCertBag):
+			certsData, err := decodeCertBag(bag.Value.Bytes)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			cs, err := x509.ParseCertificates(certsData)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if len(cs) != 1 {
+				return nil, nil, nil, errors.New("pkcs12: expected exactly one certificate in the certBag")
+			}
+			certs = append(certs, cs[0])
+
+		case bag.Id.Equal(oidKeyBag), bag.Id.Equal(oidPKCS8ShroundedKeyBag):
+			if privateKey != nil {
+				return nil, nil, nil, errors.New("pkcs12: expected exactly one key bag")
+			}
+			if privateKey, err = decodeKeyBag(bag, pw); err != nil {
+				return nil, nil, nil, err
+			}
+			keyID = localKeyID(bag)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, nil, errors.New("pkcs12: certificate missing")
+	}
+	if privateKey == nil {
+		return nil, nil, nil, errors.New("pkcs12: private key missing")
+	}
+
+	// Prefer the certificate that shares a localKeyId with the key, then
+	// the first one.
+	leaf := 0
+	if keyID != nil {
+		for i, bag := range bags {
+			if bag.Id.Equal(oidCertBag) && string(localKeyID(&bag)) == string(keyID) {
+				leaf = certIndex(bags, i)
+				break
+			}
+		}
+	}
+	certificate = certs[leaf]
+	caCerts = append(certs[:leaf:leaf], certs[leaf+1:]...)
+
+	return privateKey, certificate, caCerts, nil
+}
+
+// certIndex returns the index among the certificate bags of bags[i].
+func certIndex(bags []safeBag, i int) int {
+	n := 0
+	for _, bag := range bags[:i] {
+		if bag.Id.Equal(oidCertBag) {
+			n++
+		}
+	}
+	return n
+}
+
+// localKeyID returns the value of the localKeyId attribute of bag, if any.
+func localKeyID(bag *safeBag) []byte {
+	for _, attr := range bag.Attributes {
+		if !attr.Id.Equal(oidLocalKeyID) {
+			continue
+		}
+		var id []byte
+		if err := unmarshal(attr.Value.Bytes, &id); err == nil {
+			return id
+		}
+	}
+	return nil
+}
+
+func getSafeContents(p12Data []byte, pwString string) (bags []safeBag, pw encodedPassword, err error) {
+	pfx := new(pfxPdu)
+	if err := unmarshal(p12Data, pfx); err != nil {
+		return nil, encodedPassword{}, errors.New("pkcs12: error reading P12 data: " + err.Error())
+	}
+
+	if pfx.Version != 3 {
+		return nil, encodedPassword{}, NotImplementedError("can only decode v3 PFX PDU's")
+	}
+
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, encodedPassword{}, NotImplementedError("only password-protected PFX is implemented")
+	}
+
+	// unmarshal the explicit bytes in the content for type 'data'
+	var authenticatedSafe []byte
+	if err := unmarshal(pfx.AuthSafe.Content.Bytes, &authenticatedSafe); err != nil {
+		return nil, encodedPassword{}, err
+	}
+
+	if pw, err = newPassword(pwString); err != nil {
+		return nil, encodedPassword{}, err
+	}
+
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) == 0 {
+		if pwString != "" {
+			return nil, encodedPassword{}, errors.New("pkcs12: no MAC in data")
+		}
+	} else if err := verifyMac(&pfx.MacData, authenticatedSafe, pw.bmp); err != nil {
+		// Some implementations use an empty byte array for the empty
+		// string password, rather than the terminator alone.
+		if err != ErrIncorrectPassword || pwString != "" {
+			return nil, encodedPassword{}, err
+		}
+		if verifyMac(&pfx.MacData, authenticatedSafe, nil) != nil {
+			return nil, encodedPassword{}, err
+		}
+		pw.bmp = nil
+	}
+
+	var authenticatedSafes []contentInfo
+	if err := unmarshal(authenticatedSafe, &authenticatedSafes); err != nil {
+		return nil, encodedPassword{}, err
+	}
+
+	if len(authenticatedSafes) != 2 && len(authenticatedSafes) != 1 {
+		return nil, encodedPassword{}, NotImplementedError("expected exactly one or two items in the authenticated safe")
+	}
+
+	for _, ci := range authenticatedSafes {
+		var data []byte
+
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if err := unmarshal(ci.Content.Bytes, &data); err != nil {
+				return nil, encodedPassword{}, err
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var encryptedData encryptedData
+			if err := unmarshal(ci.Content.Bytes, &encryptedData); err != nil {
+				return nil, encodedPassword{}, err
+			}
+			if encryptedData.Version != 0 {
+				return nil, encodedPassword{}, NotImplementedError("only version 0 of EncryptedData is supported")
+			}
+			if data, err = pbDecrypt(encryptedData.EncryptedContentInfo, pw); err != nil {
+				return nil, encodedPassword{}, err
+			}
+		default:
+			return nil, encodedPassword{}, NotImplementedError("only data and encryptedData content types are supported in authenticated safe")
+		}
+
+		var safeContents []safeBag
+		if err := unmarshal(data, &safeContents); err != nil {
+			return nil, encodedPassword{}, err
+		}
+		bags = append(bags, safeContents...)
+	}
+
+	return bags, pw, nil
+}
+
+// Cipher selects the algorithms used by Encode to protect certificates,
+// private keys and the integrity of the encoded data.
+type Cipher int
+
+const (
+	// CipherAES256 encrypts both certificates and private keys with
+	// AES-256-CBC using PBES2 and PBKDF2-HMAC-SHA-256, and uses an
+	// HMAC-SHA-256 MAC. It is the default of OpenSSL 3 and requires
+	// reasonably recent software to decode. It is the zero value of Cipher.
+	CipherAES256 Cipher = iota
+
+	// CipherLegacyDES encrypts both certificates and private keys with
+	// triple DES, and uses an HMAC-SHA-1 MAC. It is understood by most
+	// software, including versions of Java and Windows that do not support
+	// RC2 or PBES2.
+	CipherLegacyDES
+
+	// CipherLegacyRC2 encrypts certificates with 40-bit RC2 and private keys
+	// with triple DES, and uses an HMAC-SHA-1 MAC. This is the traditional
+	// choice of OpenSSL and is understood by virtually all software, but
+	// offers little protection for the certificates. Use it only when the
+	// data must be read by software that supports nothing else.
+	CipherLegacyRC2
+)
+
+// encodeParams holds the algorithms and parameters used by a Cipher.
+type encodeParams struct {
+	macHash       asn1.ObjectIdentifier
+	certAlgorithm asn1.ObjectIdentifier
+	keyAlgorithm  asn1.ObjectIdentifier
+	macIterations int
+	encIterations int
+	saltLen       int
+}
+
+func (c Cipher) params() (*encodeParams, error) {
+	switch c {
+	case CipherAES256:
+		return &encodeParams{
+			macHash:       oidSHA256,
+			certAlgorithm: oidPBES2,
+			keyAlgorithm:  oidPBES2,
+			macIterations: 2048,
+			encIterations: 2048,
+			saltLen:       16,
+		}, nil
+	case CipherLegacyDES:
+		return &encodeParams{
+			macHash:       oidSHA1,
+			certAlgorithm: oidPBEWithSHAAnd3KeyTripleDESCBC,
+			keyAlgorithm:  oidPBEWithSHAAnd3KeyTripleDESCBC,
+			macIterations: 2048,
+			encIterations: 2048,
+			saltLen:       8,
+		}, nil
+	case CipherLegacyRC2:
+		return &encodeParams{
+			macHash:       oidSHA1,
+			certAlgorithm: oidPBEWithSHAAnd40BitRC2CBC,
+			keyAlgorithm:  oidPBEWithSHAAnd3KeyTripleDESCBC,
+			macIterations: 2048,
+			encIterations: 2048,
+			saltLen:       8,
+		}, nil
+	}
+	return nil, errors.New("pkcs12: unknown cipher")
+}
+
+// Encode produces pfxData containing one private key, its certificate, and
+// an optional chain of CA certificates, protected by password and
+// encrypted with the algorithms selected by alg. The data is suitable for
+// import by other tools. The zero Cipher, CipherAES256, is the right choice
+// unless the data must be read by older software.
+//
+// The private key must be of a type supported by x509.MarshalPKCS8PrivateKey.
+// The certificate and key share a localKeyId attribute, the SHA-1 hash of
+// the certificate, so that tools can pair them.
+//
+// The rand argument is used as a source of entropy for the salts and IVs.
+func Encode(rand io.Reader, privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, password string, alg Cipher) (pfxData []byte, err error) {
+	params, err := alg.params()
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		return nil, errors.New("pkcs12: certificate missing")
+	}
+	pw, err := newPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var pfx pfxPdu
+	pfx.Version = 3
+
+	keyID := sha1.Sum(certificate.Raw)
+	localKeyIDAttr, err := newLocalKeyIDAttribute(keyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var certBags []safeBag
+	certBag, err := makeCertBag(certificate.Raw, []pkcs12Attribute{localKeyIDAttr})
+	if err != nil {
+		return nil, err
+	}
+	certBags = append(certBags, *certBag)
+
+	for _, cert := range caCerts {
+		certBag, err := makeCertBag(cert.Raw, nil)
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, *certBag)
+	}
+
+	var keyBag safeBag
+	keyBag.Id = oidPKCS8ShroundedKeyBag
+	keyBag.Value.Class = asn1.ClassContextSpecific
+	keyBag.Value.Tag = 0
+	keyBag.Value.IsCompound = true
+	if keyBag.Value.Bytes, err = encodePkcs8ShroudedKeyBag(rand, privateKey, params.keyAlgorithm, pw, params.encIterations, params.saltLen); err != nil {
+		return nil, err
+	}
+	keyBag.Attributes = append(keyBag.Attributes, localKeyIDAttr)
+
+	// Construct an authenticated safe with two SafeContents.
+	// The first SafeContents is encrypted and contains the cert bags.
+	// The second SafeContents is unencrypted and contains the shrouded key bag.
+	var authenticatedSafe [2]contentInfo
+	if authenticatedSafe[0], err = makeSafeContents(rand, certBags, params.certAlgorithm, pw, params.encIterations, params.saltLen); err != nil {
+		return nil, err
+	}
+	if authenticatedSafe[1], err = makeSafeContents(rand, []safeBag{keyBag}, nil, encodedPassword{}, 0, 0); err != nil {
+		return nil, err
+	}
+
+	var authenticatedSafeBytes []byte
+	if authenticatedSafeBytes, err = asn1.Marshal(authenticatedSafe[:]); err != nil {
+		return nil, err
+	}
+
+	// compute the MAC
+	pfx.MacData.Mac.Algorithm.Algorithm = params.macHash
+	pfx.MacData.Mac.Algorithm.Parameters = asn1.NullRawValue
+	pfx.MacData.MacSalt = make([]byte, params.saltLen)
+	if _, err = io.ReadFull(rand, pfx.MacData.MacSalt); err != nil {
+		return nil, errors.New("pkcs12: cannot generate MAC salt: " + err.Error())
+	}
+	pfx.MacData.Iterations = params.macIterations
+	pfx.MacData.Mac.Digest = computeMac(macHash(params.macHash), authenticatedSafeBytes, pfx.MacData.MacSalt, pfx.MacData.Iterations, pw.bmp)
+
+	pfx.AuthSafe.ContentType = oidDataContentType
+	pfx.AuthSafe.Content.Class = asn1.ClassContextSpecific
+	pfx.AuthSafe.Content.Tag = 0
+	pfx.AuthSafe.Content.IsCompound = true
+	if pfx.AuthSafe.Content.Bytes, err = asn1.Marshal(authenticatedSafeBytes); err != nil {
+		return nil, err
+	}
+
+	if pfxData, err = asn1.Marshal(pfx); err != nil {
+		return nil, errors.New("pkcs12: error writing P12 data: " + err.Error())
+	}
+	return pfxData, nil
+}
+
+func newLocalKeyIDAttribute(id []byte) (pkcs12Attribute, error) {
+	value, err := asn1.Marshal(id)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{
+		Id: oidLocalKeyID,
+		Value: asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      value,
+		},
+	}, nil
+}
+
+func makeCertBag(certBytes []byte, attributes []pkcs12Attribute) (certBag *safeBag, err error) {
+	certBag = new(safeBag)
+	certBag.Id = oidCertBag
+	certBag.Value.Class = asn1.ClassContextSpecific
+	certBag.Value.Tag = 0
+	certBag.Value.IsCompound = true
+	if certBag.Value.Bytes, err = encodeCertBag(certBytes); err != nil {
+		return nil, err
+	}
+	certBag.Attributes = attributes
+	return
+}
+
+// makeSafeContents wraps bags in a ContentInfo, encrypted with alg if it is
+// not nil.
+func makeSafeContents(rand io.Reader, bags []safeBag, alg asn1.ObjectIdentifier, pw encodedPassword, iterations, saltLen int) (ci contentInfo, err error) {
+	var data []byte
+	if data, err = asn1.Marshal(bags); err != nil {
+		return
+	}
+
+	if alg == nil {
+		ci.ContentType = oidDataContentType
+		ci.Content.Class = asn1.ClassContextSpecific
+		ci.Content.Tag = 0
+		ci.Content.IsCompound = true
+		if ci.Content.Bytes, err = asn1.Marshal(data); err != nil {
+			return
+		}
+		return ci, nil
+	}
+
+	var encryptedData encryptedData
+	encryptedData.Version = 0
+	encryptedData.EncryptedContentInfo.ContentType = oidDataContentType
+	encryptedData.EncryptedContentInfo.ContentEncryptionAlgorithm, encryptedData.EncryptedContentInfo.EncryptedContent, err = pbEncrypt(rand, alg, data, pw, iterations, saltLen)
+	if err != nil {
+		return
+	}
+
+	ci.ContentType = oidEncryptedDataContentType
+	ci.Content.Class = asn1.ClassContextSpecific
+	ci.Content.Tag = 0
+	ci.Content.IsCompound = true
+	if ci.Content.Bytes, err = asn1.Marshal(encryptedData); err != nil {
+		return
+	}
+	return ci, nil
+}
@@ -0,0 +1,414 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkcs12
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test files were generated with OpenSSL 3.0, using a P-256 key and a
+// certificate for leaf.example.com issued by "Test CA":
+//
+//	openssl pkcs12 -export -legacy -in leaf.crt -inkey leaf.key -certfile ca.crt -name leaf -passout pass:password
+//	openssl pkcs12 -export -in leaf.crt -inkey leaf.key -certfile ca.crt -name leaf -passout pass:password
+var testFiles = map[string]string{
+	// pbeWithSHA1And40BitRC2-CBC certificates, pbeWithSHA1And3-KeyTripleDES-CBC
+	// key and an HMAC-SHA-1 MAC.
+	"legacy": `MIIFDwIBAzCCBNUGCSqGSIb3DQEHAaCCBMYEggTCMIIEvjCCA5cGCSqGSIb3DQEH
+BqCCA4gwggOEAgEAMIIDfQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQYwDgQIi1n8
+QtwqM9kCAggAgIIDUN3TTM1dFyef6OjsaloJYsqQpvYDw3EHBsDBQyCtuM2Ag2v/
+STmWl5MFQN/112GX9RZJkHG4jYp58yVValR3pnWU0fyZfdFcRAQ/m7Rub9crabqz
+DyajpK2KBQXSs8p907ehtz82Nr3WDeGsCw0ucOl7JDR3J5c7W3czGH2qn9Rlfspr
+r7+GJiLbDoDX3SnHIAuIeEwVqbL2zlFesY2p9gw6inhq9yWaDTz5fPtFL2rV1YGG
+Vixed66wRYpBNj+R/95UkKT3pmMSXltQFEhZnmxb1llvXiSoEy3218FsPNJn/V82
+td9njaivU5nOTZ4ztnMMIXX5JvhY+UACe/pY0J1fMgNEz0Tmxg7Hbv1giijW/nCm
+s3XA+4k0/KLWj34uUmLCtUqswSYsY5WEVniQA8qf473rTdHt3AGtdtIZdoFWi82h
+1glAq9qSUb8WQUFnoQCqJTNQhMRPZ9HKyu1kKG7TNRZKFCtuQiTbsu2zQZz/USjs
+Ffujo2S5/UB8bQjsUZbSjI/1kE8KOSCYAHI6mb4vokk3JwVCZAyslgUU9OXsHwN4
+SygmpjIfms1ZBYELnqeWyexrMHk66VYmyz7qtxMeXzqpaUnlXFYr+JgpVVO2lNuY
+pijm/bvSXxodC5+fIwv0YN8/zMr4YEEIZ41wlHgRJmOn50oH4YMAeybDej+dxeBg
+3m7BBFjbmd/vqqrSjgDDcy0dy7O9lOiDXn1RbNPBsq7MVzHwCUcAVywHpGtYb1jS
+bVYWQbZZ8vgcPDx/r1a1UBcTrwn4Cwt/bH3hTavqpl0PvnWCAufoujfg7QBg53WR
+0gsxZbq2uzKXW6EwB48yz8Obw1M4gURcDdxalBgHBgRIRS+XGy1DlObHje2+1tpB
+aGbk6UmFPNJLPBuAqwEMDlma00cYmRx+uvxexZQmVKpxx27Z/2MgId0dojQ2VH9V
+CuJWJpA4ozN+yQkRG+JqE/Sk73jqLlzXkLciPro6QF0jofDJ5EzryFbAtJsUqnpY
+/BBXYRj1UKktMulKSD1nwEtGkRy5eN/Hm3Cv95jpo8z9WGgrPXfYjewhpier9aS3
+ZY9cSUM6Wzean0HAuoIqiXerqOqzK98dD/x04bBg6M5yFBVoTrBh8haWEnR4MIIB
+HwYJKoZIhvcNAQcBoIIBEASCAQwwggEIMIIBBAYLKoZIhvcNAQwKAQKggbQwgbEw
+HAYKKoZIhvcNAQwBAzAOBAiixltsItCacQICCAAEgZBXqDAw2ZJJ0xNM0ZZbaPNl
+LVD1SbWBBQMDKOC+QSnq/GugaxjAPCZjKjklZdAe0sqBYD+ozpsNTKACK4/EeJPB
+iX6ZcEGx8LheXkPNt3SjIRtxosHQ2Un2mLcwcgF5y1rx7RV+MMgHdtBrPsohmWu3
+6cjiBI9Pq1Lsuiib4UrVFJfUPxwOYzynYozX55eY5+0xPjAXBgkqhkiG9w0BCRQx
+Ch4IAGwAZQBhAGYwIwYJKoZIhvcNAQkVMRYEFPx59tj+17eU0RC1amZcC7YN+ivp
+MDEwITAJBgUrDgMCGgUABBSfFQ84DBCGgKTbNfiFH1g6n9E4ewQI3CdBeGMibKUC
+AggA`,
+	// PBES2 with PBKDF2-HMAC-SHA-256 and AES-256-CBC for both certificates
+	// and key, and an HMAC-SHA-256 MAC.
+	"modern": `MIIFlQIBAzCCBUsGCSqGSIb3DQEHAaCCBTwEggU4MIIFNDCCA9IGCSqGSIb3DQEH
+BqCCA8MwggO/AgEAMIIDuAYJKoZIhvcNAQcBMFcGCSqGSIb3DQEFDTBKMCkGCSqG
+SIb3DQEFDDAcBAjOggPgR+oEzwICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQME
+ASoEEHmjRiOJIjOU0hrhf1tAICyAggNQDuY+Ma8uxbNwgYieGfBHPcrI8DOkmpba
+I30lNMawCXIMhHpLRyPtu087p18GkxQPsJD9rzD5loTLMRMoog9oMyjj8XElS0W0
+bOilyqAgGK7NQs6Ez/M1UcDIngJMI4ntsSeGmgDBEhlG66LCXlJxfXy8N4huzBL+
+Sp8r8j6vrRfFoF1POKs0UzQ1dPi7vCfhxmmMiQm35cnX5MBkZZDXfjAh3Ua1AFVG
+OJ99Fntg78t0aNkvHVlRGrJlUIJXNUpBAH96pAETuCLWoWPYbVP6u4LLFdRIWIkn
+gF3D1uea2uDTnQmIqt9i/WBVapG98iMV8Yuen10EDMJcQeBnhPBGcJ8wyjMTH2vz
+iAvIM2v8XWl2PuaX9NkOgRYTO52Z/UgP5eVL1PS3TDpy7aepFfUD2tnAkCTzbEln
+d1ORweSMoDiieyvgKU2Pw5dPbcwGysj71AeOKJf9/3ialGVcqAYcVRwQ/jpTqpL2
+2+gpPNo6o6bipFQWtyB9jVhTVy0rPXyX/89tv9PCdrqE/T9hxigtYTw5VtU6yeya
+QZclMN7IHE8TWSPuyX4RITByR+yEVbz45tEY6RJnwCUlaAPSl5+JMIJCE5YxW7b0
++JhwQZuPqhVlAatQIDVzIfDGKnED6P5nfyBy/BSk9/4JmCgMWGGqcYW9sxGPQFVg
+CeI2LyT+w+/NRnJ2ndFOoJWUweJzrbYAuVbppFAAQK/cSy50VI5OiUgPyAuDFzdO
+faEmY7rl8eACUNOgt+4vUxJYTrtiSbeXbH1SvoCCeqTMtir16K8vL6jPLqhz93Fr
+mZxVlN7SmIw714OMUCx0jevSbDrDaGuwVU1FEXOgtovYkY6s5VxEfnFJZfnyccWB
+OAUbsxnX0BvHGQ+RNAZK1Mcrz4KuRs7pnxvfwOsXplf6HtBt77OJliGBREl5y2VP
+b9e6LhxR8QXoPRzuYpk1aL/bVCQczbUjzbxk4BwPNWYkTygYw2zNpprIfwrwWuPu
+mZpeOW3n+pXWSaUd2td4PBAp2lVa2Sqsb/cNi4Imbx3ATsnCG4JUBDQfRa0dl70I
+ydzg299Smul7YcWW4Mr2e6cSYorC+Ni7B709COC+KAjj8WlZ0/0sYYePzm8sPqJi
+K+0+juUG5A0wggFaBgkqhkiG9w0BBwGgggFLBIIBRzCCAUMwggE/BgsqhkiG9w0B
+DAoBAqCB7zCB7DBXBgkqhkiG9w0BBQ0wSjApBgkqhkiG9w0BBQwwHAQIzhCEIqA4
+wOQCAggAMAwGCCqGSIb3DQIJBQAwHQYJYIZIAWUDBAEqBBDA16heCISQJxSBzTFt
+4mhXBIGQYTejf58WGNDK8QyHG1KnohDx/h0PQYRhu+f5ctBjkD+2LLEKPO6sN6ak
+8icByQKUHF+RssCW2HnGP9lVQScuQzcqfAa12BIH2BoVzaPemBlICaL3fwQky0UA
+xEt+2tr5bRuh4C6ds0E2Qqm7bXt7wpse8X1FkoKqcsZ9xYNggrTZeWrAzbhjbcK2
+hykEzcrZMT4wFwYJKoZIhvcNAQkUMQoeCABsAGUAYQBmMCMGCSqGSIb3DQEJFTEW
+BBT8efbY/te3lNEQtWpmXAu2Dfor6TBBMDEwDQYJYIZIAWUDBAIBBQAEIBOyf3Cu
+0fEclBXgKXIn+doe45rFeTMP2mus5hS8mi5jBAjjMUnDMHRoRwICCAA=`,
+}
+
+const testLeafKeyID = "fc79f6d8fed7b794d110b56a665c0bb60dfa2be9"
+
+func decodeTestFile(t *testing.T, name string) []byte {
+	p12, err := base64.StdEncoding.DecodeString(strings.Replace(testFiles[name], "\n", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p12
+}
+
+func TestDecodeChain(t *testing.T) {
+	for name := range testFiles {
+		t.Run(name, func(t *testing.T) {
+			p12 := decodeTestFile(t, name)
+
+			priv, cert, caCerts, err := DecodeChain(p12, "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, ok := priv.(*ecdsa.PrivateKey)
+			if !ok {
+				t.Fatalf("got private key of type %T, want *ecdsa.PrivateKey", priv)
+			}
+			if cert.Subject.CommonName != "leaf.example.com" {
+				t.Errorf("got certificate for %q, want leaf.example.com", cert.Subject.CommonName)
+			}
+			pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+			if !ok || pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+				t.Errorf("certificate public key does not match the private key")
+			}
+			if len(caCerts) != 1 || caCerts[0].Subject.CommonName != "Test CA" {
+				t.Errorf("unexpected CA certificates: %v", caCerts)
+			}
+			if err := cert.CheckSignatureFrom(caCerts[0]); err != nil {
+				t.Errorf("certificate is not signed by the CA: %v", err)
+			}
+
+			if _, _, err := Decode(p12, "password"); err == nil {
+				t.Errorf("Decode did not reject a file with a CA certificate")
+			}
+		})
+	}
+}
+
+func TestIncorrectPassword(t *testing.T) {
+	for name := range testFiles {
+		p12 := decodeTestFile(t, name)
+		if _, _, _, err := DecodeChain(p12, "wrong password"); err != ErrIncorrectPassword {
+			t.Errorf("%s: got error %v, want ErrIncorrectPassword", name, err)
+		}
+	}
+}
+
+func TestIterationLimit(t *testing.T) {
+	for _, n := range []int{0, -1, maxIterations + 1} {
+		mac := &macData{Iterations: n}
+		mac.Mac.Algorithm.Algorithm = oidSHA1
+		if err := verifyMac(mac, nil, nil); err == nil || err == ErrIncorrectPassword {
+			t.Errorf("verifyMac with %d iterations: got error %v, want iteration count error", n, err)
+		}
+
+		params, err := asn1.Marshal(pbeParams{Salt: []byte("salt"), Iterations: n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		alg := pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBEWithSHAAnd3KeyTripleDESCBC,
+			Parameters: asn1.RawValue{FullBytes: params},
+		}
+		if _, _, err := pbDecrypterFor(alg, encodedPassword{}); err == nil {
+			t.Errorf("PBE with %d iterations: no error", n)
+		}
+
+		kdfParams, err := asn1.Marshal(pbkdf2Params{Salt: []byte("salt"), Iterations: n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, err = asn1.Marshal(pbes2Params{
+			KeyDerivationFunc: pkix.AlgorithmIdentifier{
+				Algorithm:  oidPBKDF2,
+				Parameters: asn1.RawValue{FullBytes: kdfParams},
+			},
+			EncryptionScheme: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		alg = pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: params},
+		}
+		if _, _, err := pbDecrypterFor(alg, encodedPassword{}); err == nil || !strings.Contains(err.Error(), "iteration count") {
+			t.Errorf("PBES2 with %d iterations: got error %v, want iteration count error", n, err)
+		}
+	}
+}
+
+func TestToPEM(t *testing.T) {
+	for name := range testFiles {
+		t.Run(name, func(t *testing.T) {
+			blocks, err := ToPEM(decodeTestFile(t, name), "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var types []string
+			for _, b := range blocks {
+				types = append(types, b.Type)
+				switch b.Type {
+				case "PRIVATE KEY":
+					if _, err := x509.ParsePKCS8PrivateKey(b.Bytes); err != nil {
+						t.Errorf("failed to parse private key: %v", err)
+					}
+					if got := b.Headers["friendlyName"]; got != "leaf" {
+						t.Errorf("got friendlyName %q, want %q", got, "leaf")
+					}
+					if got := b.Headers["localKeyId"]; got != testLeafKeyID {
+						t.Errorf("got localKeyId %q, want %q", got, testLeafKeyID)
+					}
+				case "CERTIFICATE":
+					if _, err := x509.ParseCertificate(b.Bytes); err != nil {
+						t.Errorf("failed to parse certificate: %v", err)
+					}
+				}
+			}
+			want := []string{"CERTIFICATE", "CERTIFICATE", "PRIVATE KEY"}
+			if !reflect.DeepEqual(types, want) {
+				t.Errorf("got blocks %v, want %v", types, want)
+			}
+		})
+	}
+}
+
+func newTestCertificate(t *testing.T, priv, parentPriv interface{}, parent *x509.Certificate, name string) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Unix(1000, 0),
+		NotAfter:              time.Unix(100000, 0),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentPriv = template, priv
+	}
+	pub := priv.(interface{ Public() crypto.PublicKey }).Public()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := newTestCertificate(t, caKey, nil, nil, "Test CA")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := newTestCertificate(t, rsaKey, caKey, ca, "leaf")
+
+	for _, alg := range []Cipher{CipherAES256, CipherLegacyDES, CipherLegacyRC2} {
+		for _, password := range []string{"", "password", "pässwörd"} {
+			pfx, err := Encode(rand.Reader, rsaKey, leaf, []*x509.Certificate{ca}, password, alg)
+			if err != nil {
+				t.Fatalf("Encode(%v, %q): %v", alg, password, err)
+			}
+
+			priv, cert, caCerts, err := DecodeChain(pfx, password)
+			if err != nil {
+				t.Fatalf("DecodeChain(%v, %q): %v", alg, password, err)
+			}
+			if !reflect.DeepEqual(priv, rsaKey) {
+				t.Errorf("%v, %q: private key does not round-trip", alg, password)
+			}
+			if !cert.Equal(leaf) {
+				t.Errorf("%v, %q: certificate does not round-trip", alg, password)
+			}
+			if len(caCerts) != 1 || !caCerts[0].Equal(ca) {
+				t.Errorf("%v, %q: CA certificates do not round-trip", alg, password)
+			}
+
+			if password != "" {
+				if _, _, _, err := DecodeChain(pfx, password+"x"); err != ErrIncorrectPassword {
+					t.Errorf("%v, %q: got error %v for wrong password, want ErrIncorrectPassword", alg, password, err)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeMAC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := newTestCertificate(t, key, nil, nil, "self-signed")
+
+	tests := []struct {
+		alg  Cipher
+		hash asn1.ObjectIdentifier
+	}{
+		{0, oidSHA256},
+		{CipherLegacyDES, oidSHA1},
+		{CipherLegacyRC2, oidSHA1},
+	}
+	for _, tt := range tests {
+		pfx, err := Encode(rand.Reader, key, cert, nil, "password", tt.alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pdu pfxPdu
+		if err := unmarshal(pfx, &pdu); err != nil {
+			t.Fatal(err)
+		}
+		if got := pdu.MacData.Mac.Algorithm.Algorithm; !got.Equal(tt.hash) {
+			t.Errorf("%v: got MAC algorithm %v, want %v", tt.alg, got, tt.hash)
+		}
+		if got := pdu.MacData.Iterations; got < 2048 {
+			t.Errorf("%v: got %d MAC iterations, want at least 2048", tt.alg, got)
+		}
+	}
+}
+
+func TestEncodeLocalKeyID(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := newTestCertificate(t, key, nil, nil, "self-signed")
+
+	pfx, err := Encode(rand.Reader, key, cert, nil, "password", CipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := ToPEM(pfx, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum(cert.Raw)
+	want := hex.EncodeToString(sum[:])
+	for _, b := range blocks {
+		if got := b.Headers["localKeyId"]; got != want {
+			t.Errorf("%s: got localKeyId %q, want %q", b.Type, got, want)
+		}
+	}
+}
+
+func TestBMPString(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"", "0000"},
+		// Example from https://tools.ietf.org/html/rfc7292#appendix-B.
+		{"Beavis", "0042006500610076006900730000"},
+		// Some characters from the "Letterlike Symbols Unicode block".
+		{"\u2115 - Double-struck N", "21150020002d00200044006f00750062006c0065002d00730074007200750063006b0020004e0000"},
+	}
+	for _, tt := range tests {
+		got, err := bmpString(tt.in)
+		if err != nil {
+			t.Errorf("bmpString(%q): %v", tt.in, err)
+			continue
+		}
+		if hex.EncodeToString(got) != tt.out {
+			t.Errorf("bmpString(%q) = %x, want %s", tt.in, got, tt.out)
+		}
+		back, err := decodeBMPString(got)
+		if err != nil || back != tt.in {
+			t.Errorf("decodeBMPString(%x) = %q, %v; want %q", got, back, err, tt.in)
+		}
+	}
+
+	// Characters outside the BMP cannot be encoded.
+	if _, err := bmpString("\U0001f000"); err == nil {
+		t.Errorf("bmpString accepted a character outside the BMP")
+	}
+}
+
+func TestPBKDF(t *testing.T) {
+	// Test vectors from OpenSSL's PKCS #12 key derivation.
+	password, _ := bmpString("sesame")
+	salt := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	key := pbkdf(sha1.New, salt, password, 2048, keyID, 24)
+	if want := []byte{0x7c, 0xd9, 0xfd, 0x3e, 0x2b, 0x3b, 0xe7, 0x69, 0x1a, 0x44, 0xe3, 0xbe, 0xf0, 0xf9, 0xea, 0x0f, 0xb9, 0xb8, 0x97, 0xd4, 0xe3, 0x25, 0xd9, 0xd1}; !bytes.Equal(key, want) {
+		t.Errorf("got key %x, want %x", key, want)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors from RFC 6070.
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		out            string
+	}{
+		{"password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+	}
+	for _, tt := range tests {
+		got := pbkdf2(sha1.New, []byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen)
+		if hex.EncodeToString(got) != tt.out {
+			t.Errorf("pbkdf2(%q, %q, %d) = %x, want %s", tt.password, tt.salt, tt.iter, got, tt.out)
+		}
+	}
+}
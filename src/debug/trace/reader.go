@@ -0,0 +1,233 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package trace reads execution traces written by the runtime/trace
+// package and by 'go test -trace'.
+//
+// A Reader returns the events of a trace one at a time, in the order
+// in which they happened. A trace is written in generations of about
+// a second each, and a Reader holds only the generation it is reading
+// in memory, so tools can process traces of any length with bounded
+// memory.
+package trace
+
+import (
+	"internal/trace"
+	"io"
+)
+
+// An EventType is the type of an event.
+// The arguments of the events of each type are listed in brackets.
+type EventType uint8
+
+const (
+	EventNone              EventType = iota
+	EventGomaxprocs                  // current value of GOMAXPROCS [GOMAXPROCS]
+	EventProcStart                   // start of P [thread id]
+	EventProcStop                    // stop of P []
+	EventGCStart                     // GC start [GC sequence number]
+	EventGCDone                      // GC done []
+	EventGCSTWStart                  // stop the world start [], SArgs: [kind]
+	EventGCSTWDone                   // stop the world done []
+	EventGCSweepStart                // GC sweep start []
+	EventGCSweepDone                 // GC sweep done [bytes swept, bytes reclaimed]
+	EventGoCreate                    // goroutine creation [new goroutine id]
+	EventGoStart                     // goroutine starts running [goroutine id]
+	EventGoEnd                       // goroutine ends []
+	EventGoStop                      // goroutine stops, as in select{} []
+	EventGoSched                     // goroutine calls Gosched []
+	EventGoPreempt                   // goroutine is preempted []
+	EventGoSleep                     // goroutine calls Sleep []
+	EventGoBlock                     // goroutine blocks []
+	EventGoUnblock                   // goroutine is unblocked [goroutine id]
+	EventGoBlockSend                 // goroutine blocks on chan send []
+	EventGoBlockRecv                 // goroutine blocks on chan recv []
+	EventGoBlockSelect               // goroutine blocks on select []
+	EventGoBlockSync                 // goroutine blocks on Mutex/RWMutex []
+	EventGoBlockCond                 // goroutine blocks on Cond []
+	EventGoBlockNet                  // goroutine blocks on network []
+	EventGoBlockGC                   // goroutine blocks on GC assist []
+	EventGoSysCall                   // syscall enter []
+	EventGoSysExit                   // syscall exit [goroutine id]
+	EventGoSysBlock                  // syscall blocks []
+	EventGoWaiting                   // goroutine is blocked when tracing starts [goroutine id]
+	EventGoInSyscall                 // goroutine is in syscall when tracing starts [goroutine id]
+	EventGoStartLabel                // goroutine starts running with label [goroutine id], SArgs: [label]
+	EventHeapAlloc                   // live heap size change [bytes]
+	EventNextGC                      // heap goal change [bytes]
+	EventGCMarkAssistStart           // GC mark assist start []
+	EventGCMarkAssistDone            // GC mark assist done []
+	EventUserTaskCreate              // runtime/trace.NewTask [task id, parent task id], SArgs: [name]
+	EventUserTaskEnd                 // end of task [task id]
+	EventUserRegion                  // runtime/trace.WithRegion [task id, mode (0: start, 1: end)], SArgs: [name]
+	EventUserLog                     // runtime/trace.Log [task id], SArgs: [category, message]
+	EventGoLabels                    // goroutine's profiler labels [goroutine id], SArgs: [key, value, ...]
+	EventCPUSample                   // CPU profiling sample []
+	eventCount
+)
+
+// eventTypes describes the event types: their names, the type
+// of the corresponding events of internal/trace, and the number of
+// leading arguments of those events that are public.
+var eventTypes = [eventCount]struct {
+	name  string
+	ev    byte
+	nargs int
+}{
+	EventNone:              {"None", trace.EvNone, 0},
+	EventGomaxprocs:        {"Gomaxprocs", trace.EvGomaxprocs, 1},
+	EventProcStart:         {"ProcStart", trace.EvProcStart, 1},
+	EventProcStop:          {"ProcStop", trace.EvProcStop, 0},
+	EventGCStart:           {"GCStart", trace.EvGCStart, 1},
+	EventGCDone:            {"GCDone", trace.EvGCDone, 0},
+	EventGCSTWStart:        {"GCSTWStart", trace.EvGCSTWStart, 0},
+	EventGCSTWDone:         {"GCSTWDone", trace.EvGCSTWDone, 0},
+	EventGCSweepStart:      {"GCSweepStart", trace.EvGCSweepStart, 0},
+	EventGCSweepDone:       {"GCSweepDone", trace.EvGCSweepDone, 2},
+	EventGoCreate:          {"GoCreate", trace.EvGoCreate, 1},
+	EventGoStart:           {"GoStart", trace.EvGoStart, 1},
+	EventGoEnd:             {"GoEnd", trace.EvGoEnd, 0},
+	EventGoStop:            {"GoStop", trace.EvGoStop, 0},
+	EventGoSched:           {"GoSched", trace.EvGoSched, 0},
+	EventGoPreempt:         {"GoPreempt", trace.EvGoPreempt, 0},
+	EventGoSleep:           {"GoSleep", trace.EvGoSleep, 0},
+	EventGoBlock:           {"GoBlock", trace.EvGoBlock, 0},
+	EventGoUnblock:         {"GoUnblock", trace.EvGoUnblock, 1},
+	EventGoBlockSend:       {"GoBlockSend", trace.EvGoBlockSend, 0},
+	EventGoBlockRecv:       {"GoBlockRecv", trace.EvGoBlockRecv, 0},
+	EventGoBlockSelect:     {"GoBlockSelect", trace.EvGoBlockSelect, 0},
+	EventGoBlockSync:       {"GoBlockSync", trace.EvGoBlockSync, 0},
+	EventGoBlockCond:       {"GoBlockCond", trace.EvGoBlockCond, 0},
+	EventGoBlockNet:        {"GoBlockNet", trace.EvGoBlockNet, 0},
+	EventGoBlockGC:         {"GoBlockGC", trace.EvGoBlockGC, 0},
+	EventGoSysCall:         {"GoSysCall", trace.EvGoSysCall, 0},
+	EventGoSysExit:         {"GoSysExit", trace.EvGoSysExit, 1},
+	EventGoSysBlock:        {"GoSysBlock", trace.EvGoSysBlock, 0},
+	EventGoWaiting:         {"GoWaiting", trace.EvGoWaiting, 1},
+	EventGoInSyscall:       {"GoInSyscall", trace.EvGoInSyscall, 1},
+	EventGoStartLabel:      {"GoStartLabel", trace.EvGoStartLabel, 1},
+	EventHeapAlloc:         {"HeapAlloc", trace.EvHeapAlloc, 1},
+	EventNextGC:            {"NextGC", trace.EvNextGC, 1},
+	EventGCMarkAssistStart: {"GCMarkAssistStart", trace.EvGCMarkAssistStart, 0},
+	EventGCMarkAssistDone:  {"GCMarkAssistDone", trace.EvGCMarkAssistDone, 0},
+	EventUserTaskCreate:    {"UserTaskCreate", trace.EvUserTaskCreate, 2},
+	EventUserTaskEnd:       {"UserTaskEnd", trace.EvUserTaskEnd, 1},
+	EventUserRegion:        {"UserRegion", trace.EvUserRegion, 2},
+	EventUserLog:           {"UserLog", trace.EvUserLog, 1},
+	EventGoLabels:          {"GoLabels", trace.EvGoLabels, 1},
+	EventCPUSample:         {"CPUSample", trace.EvCPUSample, 0},
+}
+
+// publicTypes maps the event types of internal/trace to EventTypes.
+var publicTypes [trace.EvCount]EventType
+
+func init() {
+	for typ := range eventTypes {
+		publicTypes[eventTypes[typ].ev] = EventType(typ)
+	}
+}
+
+func (t EventType) String() string {
+	if t >= eventCount {
+		return "Unknown"
+	}
+	return eventTypes[t].name
+}
+
+// Special values of Event.P for the events that do not happen on a P.
+const (
+	TimerP   = trace.TimerP   // unblocks by timers
+	NetpollP = trace.NetpollP // unblocks by the network poller
+	SyscallP = trace.SyscallP // returns from syscalls
+	GCP      = trace.GCP      // GC state changes
+)
+
+// An Event is an event of a trace.
+type Event struct {
+	Type  EventType
+	Ts    int64    // time since the start of the trace, in nanoseconds
+	P     int      // P on which the event happened, -1 if none, or TimerP, NetpollP, SyscallP or GCP
+	G     uint64   // goroutine on which the event happened, or 0 if none
+	Args  []uint64 // arguments, as listed for the EventType
+	SArgs []string // string arguments, as listed for the EventType
+
+	// StackID identifies the stack trace of the event in the whole
+	// trace, or is 0 if the event has no stack trace.
+	StackID uint64
+
+	// Stack is the stack trace of the event, innermost frame first.
+	// It is shared by the events with the same StackID and must not
+	// be modified.
+	Stack []Frame
+}
+
+// A Frame is a frame of a stack trace.
+type Frame struct {
+	PC   uint64
+	Fn   string // function name
+	File string
+	Line int
+}
+
+// A Reader reads the events of a trace.
+type Reader struct {
+	r      *trace.Reader
+	stacks map[uint64][]Frame // recently used stacks, by ID
+}
+
+// maxCachedStacks is the number of stacks a Reader
+// caches before it discards them.
+const maxCachedStacks = 1 << 12
+
+// NewReader returns a Reader for the trace read from r.
+// It reads and validates the header of the trace.
+func NewReader(r io.Reader) (*Reader, error) {
+	tr, err := trace.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: tr, stacks: make(map[uint64][]Frame)}, nil
+}
+
+// ReadEvent returns the next event of the trace.
+// At the end of the trace, ReadEvent returns nil, io.EOF.
+// Traces written by Go 1.6 or below are not symbolized.
+func (r *Reader) ReadEvent() (*Event, error) {
+	ev, err := r.r.ReadEvent()
+	if err != nil {
+		return nil, err
+	}
+	typ := publicTypes[ev.Type]
+	e := &Event{
+		Type:    typ,
+		Ts:      ev.Ts,
+		P:       ev.P,
+		G:       ev.G,
+		SArgs:   ev.SArgs,
+		StackID: ev.StkID,
+	}
+	if n := eventTypes[typ].nargs; n > 0 {
+		e.Args = append([]uint64(nil), ev.Args[:n]...)
+	}
+	if ev.StkID != 0 {
+		e.Stack = r.stack(ev)
+	}
+	return e, nil
+}
+
+// stack returns the stack trace of ev.
+func (r *Reader) stack(ev *trace.Event) []Frame {
+	if stk, ok := r.stacks[ev.StkID]; ok {
+		return stk
+	}
+	if len(r.stacks) >= maxCachedStacks {
+		r.stacks = make(map[uint64][]Frame)
+	}
+	stk := make([]Frame, len(ev.Stk))
+	for i, f := range ev.Stk {
+		stk[i] = Frame{PC: f.PC, Fn: f.Fn, File: f.File, Line: f.Line}
+	}
+	r.stacks[ev.StkID] = stk
+	return stk
+}
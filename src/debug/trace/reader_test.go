@@ -0,0 +1,88 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	. "debug/trace"
+	"io"
+	rtrace "runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	if rtrace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := rtrace.Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	ctx, task := rtrace.NewTask(context.Background(), "task")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rtrace.Log(ctx, "category", "message")
+	}()
+	wg.Wait()
+	task.End()
+	rtrace.Stop()
+
+	r, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var (
+		last    int64
+		created = make(map[uint64]bool)
+		log     *Event
+	)
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent: %v", err)
+		}
+		if ev.Ts < last {
+			t.Errorf("event %v at %v is before the previous event at %v", ev.Type, ev.Ts, last)
+		}
+		last = ev.Ts
+		switch ev.Type {
+		case EventNone:
+			t.Errorf("event at %v has no type", ev.Ts)
+		case EventGoCreate:
+			created[ev.Args[0]] = true
+		case EventUserLog:
+			log = ev
+		}
+	}
+	if log == nil {
+		t.Fatalf("no %v event", EventUserLog)
+	}
+	if !created[log.G] {
+		t.Errorf("goroutine %v of %v was not created", log.G, EventUserLog)
+	}
+	if len(log.Args) != 1 || len(log.SArgs) != 2 || log.SArgs[0] != "category" || log.SArgs[1] != "message" {
+		t.Errorf("got %v event with args %v %q, want 1 arg and [category message]", log.Type, log.Args, log.SArgs)
+	}
+	if log.StackID == 0 || len(log.Stack) == 0 || !strings.HasSuffix(log.Stack[0].Fn, "TestReader.func1") {
+		t.Errorf("got %v event with stack %v, want one in TestReader.func1", log.Type, log.Stack)
+	}
+}
+
+func TestEventTypeString(t *testing.T) {
+	if got := EventGoCreate.String(); got != "GoCreate" {
+		t.Errorf("EventGoCreate.String() = %q, want GoCreate", got)
+	}
+	if got := EventType(255).String(); got != "Unknown" {
+		t.Errorf("EventType(255).String() = %q, want Unknown", got)
+	}
+}
@@ -353,7 +353,7 @@ func (dec *Decoder) tokenValueEnd() {
 type Delim rune
 
 func (d Delim) String() string {
-	return string(d)
+	return string(rune(d))
 }
 
 // Token returns the next JSON token in the input stream.
@@ -196,9 +196,12 @@ var pkgDeps = map[string][]string{
 	"regexp/syntax":  {"L2"},
 	"runtime/debug":  {"L2", "fmt", "io/ioutil", "os", "time"},
 	"runtime/pprof":  {"L2", "compress/gzip", "context", "encoding/binary", "fmt", "io/ioutil", "os", "text/tabwriter", "time"},
-	"runtime/trace":  {"L0", "context", "fmt"},
+	"runtime/trace":  {"L0", "context", "fmt", "time"},
 	"text/tabwriter": {"L2"},
 
+	// Packages used by programs built with 'go build -cover' must be low-level (L2).
+	"internal/coverage": {"L2", "os", "path/filepath", "time"},
+
 	"testing":               {"L2", "flag", "fmt", "internal/race", "os", "runtime/debug", "runtime/pprof", "runtime/trace", "time"},
 	"testing/iotest":        {"L2", "log"},
 	"testing/quick":         {"L2", "flag", "fmt", "reflect", "time"},
@@ -216,12 +219,13 @@ var pkgDeps = map[string][]string{
 	},
 
 	// Go parser.
-	"go/ast":     {"L4", "OS", "go/scanner", "go/token"},
-	"go/doc":     {"L4", "OS", "go/ast", "go/token", "regexp", "internal/lazyregexp", "text/template"},
-	"go/parser":  {"L4", "OS", "go/ast", "go/scanner", "go/token"},
-	"go/printer": {"L4", "OS", "go/ast", "go/scanner", "go/token", "text/tabwriter"},
-	"go/scanner": {"L4", "OS", "go/token"},
-	"go/token":   {"L4"},
+	"go/ast":         {"L4", "OS", "go/scanner", "go/token"},
+	"go/doc":         {"L4", "OS", "go/ast", "go/doc/comment", "go/token", "regexp", "internal/lazyregexp", "text/template"},
+	"go/doc/comment": {"L4", "internal/lazyregexp"},
+	"go/parser":      {"L4", "OS", "go/ast", "go/scanner", "go/token"},
+	"go/printer":     {"L4", "OS", "go/ast", "go/scanner", "go/token", "text/tabwriter"},
+	"go/scanner":     {"L4", "OS", "go/token"},
+	"go/token":       {"L4"},
 
 	"GOPARSER": {
 		"go/ast",
@@ -241,6 +245,7 @@ var pkgDeps = map[string][]string{
 	"go/internal/gcimporter":    {"L4", "OS", "go/build", "go/constant", "go/token", "go/types", "text/scanner"},
 	"go/internal/gccgoimporter": {"L4", "OS", "debug/elf", "go/constant", "go/token", "go/types", "internal/xcoff", "text/scanner"},
 	"go/internal/srcimporter":   {"L4", "OS", "fmt", "go/ast", "go/build", "go/parser", "go/token", "go/types", "path/filepath"},
+	"go/packages":               {"L4", "OS", "GOPARSER", "context", "encoding/json", "fmt", "go/internal/gcimporter", "go/types", "os/exec", "path/filepath"},
 	"go/types":                  {"L4", "GOPARSER", "container/heap", "go/constant"},
 
 	// One of a kind.
@@ -261,6 +266,7 @@ var pkgDeps = map[string][]string{
 	"debug/macho":                    {"L4", "OS", "debug/dwarf", "compress/zlib"},
 	"debug/pe":                       {"L4", "OS", "debug/dwarf", "compress/zlib"},
 	"debug/plan9obj":                 {"L4", "OS"},
+	"debug/trace":                    {"L4", "internal/trace"},
 	"encoding":                       {"L4"},
 	"encoding/ascii85":               {"L4"},
 	"encoding/asn1":                  {"L4", "math/big"},
@@ -280,6 +286,7 @@ var pkgDeps = map[string][]string{
 	"image/png":                      {"L4", "compress/zlib"},
 	"index/suffixarray":              {"L4", "regexp"},
 	"internal/goroot":                {"L4", "OS"},
+	"internal/heapdump":              {"L4"},
 	"internal/singleflight":          {"sync"},
 	"internal/trace":                 {"L4", "OS", "container/heap"},
 	"internal/xcoff":                 {"L4", "OS", "debug/dwarf"},
@@ -384,13 +391,15 @@ var pkgDeps = map[string][]string{
 	// Mathematical crypto: dependencies on fmt (L4) and math/big.
 	// We could avoid some of the fmt, but math/big imports fmt anyway.
 	"crypto/dsa":      {"L4", "CRYPTO", "math/big"},
-	"crypto/ecdsa":    {"L4", "CRYPTO", "crypto/elliptic", "math/big", "encoding/asn1"},
+	"crypto/ecdh":     {"L4", "CRYPTO", "crypto/elliptic", "math/big"},
+	"crypto/ecdsa":    {"L4", "CRYPTO", "crypto/ecdh", "crypto/elliptic", "math/big", "encoding/asn1"},
 	"crypto/elliptic": {"L4", "CRYPTO", "math/big"},
 	"crypto/rsa":      {"L4", "CRYPTO", "crypto/rand", "math/big"},
 
 	"CRYPTO-MATH": {
 		"CRYPTO",
 		"crypto/dsa",
+		"crypto/ecdh",
 		"crypto/ecdsa",
 		"crypto/elliptic",
 		"crypto/rand",
@@ -399,17 +408,28 @@ var pkgDeps = map[string][]string{
 		"math/big",
 	},
 
+	// Hybrid public key encryption, used by crypto/tls.
+	"crypto/hpke": {"L4", "CRYPTO-MATH", "golang.org/x/crypto/hkdf"},
+
 	// SSL/TLS.
 	"crypto/tls": {
 		"L4", "CRYPTO-MATH", "OS", "golang.org/x/crypto/cryptobyte", "golang.org/x/crypto/hkdf",
-		"container/list", "crypto/x509", "encoding/pem", "net", "syscall", "crypto/ed25519",
+		"container/list", "context", "crypto/x509", "encoding/pem", "net", "syscall", "crypto/ed25519",
+		"crypto/hpke",
 	},
 	"crypto/x509": {
 		"L4", "CRYPTO-MATH", "OS", "CGO", "crypto/ed25519",
 		"crypto/x509/pkix", "encoding/pem", "encoding/hex", "net", "os/user", "syscall", "net/url",
+		"crypto/x509/internal/pbe",
 		"golang.org/x/crypto/cryptobyte", "golang.org/x/crypto/cryptobyte/asn1",
 	},
 	"crypto/x509/pkix": {"L4", "CRYPTO-MATH", "encoding/hex"},
+	"crypto/x509/pkcs12": {
+		"L4", "CRYPTO-MATH", "crypto/x509", "crypto/x509/internal/pbe", "crypto/x509/pkix",
+		"crypto/x509/pkcs12/internal/rc2", "encoding/hex", "encoding/pem",
+	},
+	"crypto/x509/pkcs12/internal/rc2": {"L3"},
+	"crypto/x509/internal/pbe":        {"L3", "crypto/aes", "crypto/des"},
 
 	// Simple net+crypto-aware packages.
 	"mime/multipart": {"L4", "OS", "mime", "crypto/rand", "net/textproto", "mime/quotedprintable"},
@@ -303,6 +303,10 @@ func anchorID(line string) string {
 // Go identifiers that appear in the words map are italicized; if the corresponding
 // map value is not the empty string, it is considered a URL and the word is converted
 // into a link.
+//
+// ToHTML does not recognize the explicit headings, lists and links of
+// the doc comment syntax described in package go/doc/comment;
+// Package.HTML does.
 func ToHTML(w io.Writer, text string, words map[string]string) {
 	for _, b := range blocks(text) {
 		switch b.op {
@@ -417,6 +421,9 @@ func blocks(text string) []block {
 // It wraps paragraphs of text to width or fewer Unicode code points
 // and then prefixes each line with the indent. In preformatted sections
 // (such as program text), it prefixes each non-blank line with preIndent.
+//
+// Like ToHTML, ToText predates the doc comment syntax described in
+// package go/doc/comment; Package.Text implements it.
 func ToText(w io.Writer, text string, indent, preIndent string, width int) {
 	l := lineWrapper{
 		out:    w,
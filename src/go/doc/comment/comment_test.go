@@ -0,0 +1,134 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package comment
+
+import "testing"
+
+var printTests = []struct {
+	in       string
+	comment  string
+	text     string
+	html     string
+	markdown string
+}{
+	{
+		in:       "Hello, world.\nSee https://golang.org/doc/ (docs).\n",
+		comment:  "Hello, world.\nSee https://golang.org/doc/ (docs).\n",
+		text:     "Hello, world. See https://golang.org/doc/ (docs).\n",
+		html:     "<p>Hello, world.\nSee <a href=\"https://golang.org/doc/\">https://golang.org/doc/</a> (docs).</p>\n",
+		markdown: "Hello, world. See <https://golang.org/doc/> (docs).\n",
+	},
+	{
+		in:       "Intro.\n\n# Syntax\n\nOld Style Heading\n\nBody text.\n",
+		comment:  "Intro.\n\n# Syntax\n\n# Old Style Heading\n\nBody text.\n",
+		text:     "Intro.\n\n# Syntax\n\n# Old Style Heading\n\nBody text.\n",
+		html:     "<p>Intro.</p>\n<h3 id=\"hdr-Syntax\">Syntax</h3>\n<h3 id=\"hdr-Old_Style_Heading\">Old Style Heading</h3>\n<p>Body text.</p>\n",
+		markdown: "Intro.\n\n### Syntax {#hdr-Syntax}\n\n### Old Style Heading {#hdr-Old_Style_Heading}\n\nBody text.\n",
+	},
+	{
+		in:       "Options are:\n  - one\n  - two,\n    continued\n\nSteps:\n\n 1. first\n\n 2. second\n\n    more second\nDone.\n",
+		comment:  "Options are:\n  - one\n  - two,\n    continued\n\nSteps:\n\n 1. first\n\n 2. second\n\n    more second\n\nDone.\n",
+		text:     "Options are:\n  - one\n  - two, continued\n\nSteps:\n\n 1. first\n\n 2. second\n\n    more second\n\nDone.\n",
+		html:     "<p>Options are:</p>\n<ul>\n<li>one</li>\n<li>two,\ncontinued</li>\n</ul>\n<p>Steps:</p>\n<ol>\n<li>\n<p>first</p>\n</li>\n<li>\n<p>second</p>\n<p>more second</p>\n</li>\n</ol>\n<p>Done.</p>\n",
+		markdown: "Options are:\n\n  - one\n  - two, continued\n\nSteps:\n\n 1. first\n\n 2. second\n\n    more second\n\nDone.\n",
+	},
+	{
+		in:       "Use it like this:\n\n\tx := f()\n\n\tif x {\n\t\tg()\n\t}\n\nThen stop.\n",
+		comment:  "Use it like this:\n\n\tx := f()\n\n\tif x {\n\t\tg()\n\t}\n\nThen stop.\n",
+		text:     "Use it like this:\n\n\tx := f()\n\n\tif x {\n\t\tg()\n\t}\n\nThen stop.\n",
+		html:     "<p>Use it like this:</p>\n<pre>x := f()\n\nif x {\n\tg()\n}\n</pre>\n<p>Then stop.</p>\n",
+		markdown: "Use it like this:\n\n\tx := f()\n\n\tif x {\n\t\tg()\n\t}\n\nThen stop.\n",
+	},
+	{
+		in:       "Read uses [io.Reader], [Buffer.Reset] and [*Buffer], see [rand.Int] or [the spec].\nNot map[Key]Value, [Unknown] or [encoding/json.Decoder.Decode].\n\n[the spec]: https://golang.org/ref/spec\n",
+		comment:  "Read uses [io.Reader], [Buffer.Reset] and [*Buffer], see [rand.Int] or [the spec].\nNot map[Key]Value, [Unknown] or [encoding/json.Decoder.Decode].\n\n[the spec]: https://golang.org/ref/spec\n",
+		text:     "Read uses io.Reader, Buffer.Reset and *Buffer, see rand.Int or [the spec]. Not\nmap[Key]Value, [Unknown] or encoding/json.Decoder.Decode.\n\n[the spec]: https://golang.org/ref/spec\n",
+		html:     "<p>Read uses <a href=\"/io#Reader\">io.Reader</a>, <a href=\"#Buffer.Reset\">Buffer.Reset</a> and <a href=\"#Buffer\">*Buffer</a>, see <a href=\"/math/rand#Int\">rand.Int</a> or <a href=\"https://golang.org/ref/spec\">the spec</a>.\nNot map[Key]Value, [Unknown] or <a href=\"/encoding/json#Decoder.Decode\">encoding/json.Decoder.Decode</a>.</p>\n",
+		markdown: "Read uses [io.Reader](/io#Reader), [Buffer.Reset](#Buffer.Reset) and [\\*Buffer](#Buffer), see [rand.Int](/math/rand#Int) or [the spec](https://golang.org/ref/spec). Not map\\[Key]Value, \\[Unknown] or [encoding/json.Decoder.Decode](/encoding/json#Decoder.Decode).\n",
+	},
+}
+
+func testParser() *Parser {
+	return &Parser{
+		LookupPackage: func(name string) (string, bool) {
+			if name == "rand" {
+				return "math/rand", true
+			}
+			return "", false
+		},
+		LookupSym: func(recv, name string) bool {
+			return recv == "" && name == "Buffer" || recv == "Buffer" && name == "Reset"
+		},
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var pr Printer
+	for _, tt := range printTests {
+		d := testParser().Parse(tt.in)
+		if out := string(pr.Comment(d)); out != tt.comment {
+			t.Errorf("Comment(%q):\nhave %q\nwant %q", tt.in, out, tt.comment)
+		}
+		if out := string(pr.Text(d)); out != tt.text {
+			t.Errorf("Text(%q):\nhave %q\nwant %q", tt.in, out, tt.text)
+		}
+		if out := string(pr.HTML(d)); out != tt.html {
+			t.Errorf("HTML(%q):\nhave %q\nwant %q", tt.in, out, tt.html)
+		}
+		if out := string(pr.Markdown(d)); out != tt.markdown {
+			t.Errorf("Markdown(%q):\nhave %q\nwant %q", tt.in, out, tt.markdown)
+		}
+
+		// Reformatting a formatted comment must not change it.
+		if out := string(pr.Comment(testParser().Parse(tt.comment))); out != tt.comment {
+			t.Errorf("Comment(%q) is not canonical:\nhave %q\nwant %q", tt.in, out, tt.comment)
+		}
+	}
+}
+
+var docLinkURLTests = []struct {
+	link DocLink
+	base string
+	url  string
+}{
+	{DocLink{ImportPath: "io"}, "", "/io"},
+	{DocLink{ImportPath: "io", Name: "Reader"}, "/pkg", "/pkg/io#Reader"},
+	{DocLink{ImportPath: "bytes", Recv: "Buffer", Name: "Reset"}, "/pkg/", "/pkg/bytes/#Buffer.Reset"},
+	{DocLink{Name: "Reader"}, "/pkg", "#Reader"},
+	{DocLink{Recv: "Buffer", Name: "Reset"}, "/pkg", "#Buffer.Reset"},
+}
+
+func TestDocLinkURL(t *testing.T) {
+	for _, tt := range docLinkURLTests {
+		if url := tt.link.DefaultURL(tt.base); url != tt.url {
+			t.Errorf("%+v.DefaultURL(%q) = %q, want %q", tt.link, tt.base, url, tt.url)
+		}
+	}
+}
+
+var listMarkerTests = []struct {
+	line string
+	num  string
+	ok   bool
+}{
+	{"- item", "", true},
+	{"\t* item", "", true},
+	{"• item", "", true},
+	{"12. item", "12", true},
+	{"3) item", "3", true},
+	{"-flag", "", false},
+	{"1.5 + x", "", false},
+	{"-", "", false},
+	{"item", "", false},
+}
+
+func TestListMarker(t *testing.T) {
+	for _, tt := range listMarkerTests {
+		num, _, ok := listMarker(tt.line)
+		if num != tt.num || ok != tt.ok {
+			t.Errorf("listMarker(%q) = %q, %v, want %q, %v", tt.line, num, ok, tt.num, tt.ok)
+		}
+	}
+}
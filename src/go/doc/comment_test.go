@@ -6,6 +6,9 @@ package doc
 
 import (
 	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"reflect"
 	"strings"
 	"testing"
@@ -234,3 +237,47 @@ func TestCommentEscape(t *testing.T) {
 		}
 	}
 }
+
+const linkSrc = `// Package p reads from a [Buffer], see [Buffer.Reset] and [rand.Int].
+package p
+
+import (
+	"math/rand"
+	r2 "crypto/rand"
+)
+
+type Buffer struct{}
+
+func (b *Buffer) Reset() {}
+
+var _ = rand.Int
+var _ = r2.Reader
+`
+
+func TestPackageHTML(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", linkSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(&ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": f}}, "example.com/p", 0)
+
+	const want = `<p>Package p reads from a <a href="#Buffer">Buffer</a>, see <a href="#Buffer.Reset">Buffer.Reset</a> and <a href="/math/rand#Int">rand.Int</a>.</p>
+`
+	if out := string(p.HTML(p.Doc)); out != want {
+		t.Errorf("HTML of package doc:\nhave %q\nwant %q", out, want)
+	}
+
+	const text = "See [r2.Reader], [p.Buffer] and [math.Pi]. No link to [Missing].\n"
+	const wantText = `<p>See <a href="/crypto/rand#Reader">r2.Reader</a>, <a href="/example.com/p#Buffer">p.Buffer</a> and <a href="/math#Pi">math.Pi</a>. No link to [Missing].</p>
+`
+	if out := string(p.HTML(text)); out != wantText {
+		t.Errorf("HTML(%q):\nhave %q\nwant %q", text, out, wantText)
+	}
+	if out := string(p.Markdown(text)); !strings.Contains(out, "[r2.Reader](/crypto/rand#Reader)") {
+		t.Errorf("Markdown(%q) = %q, want link to crypto/rand.Reader", text, out)
+	}
+	if out, want := string(p.Text(text)), "See r2.Reader, p.Buffer and math.Pi. No link to [Missing].\n"; out != want {
+		t.Errorf("Text(%q):\nhave %q\nwant %q", text, out, want)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package packages loads Go packages for inspection and analysis.
+
+The Load function takes as input a list of patterns and returns a list
+of Package structs describing individual packages matched by those
+patterns. The patterns are those accepted by the go command, such as
+"fmt", "./...", or "example.com/m/...". A pattern of the form
+"file=path" matches the package containing the named file.
+
+Load runs the go command's own package loader, through 'go list', so
+packages are found and resolved exactly as the go command does it:
+in GOPATH mode or module mode, with or without vendoring, and with the
+build flags, build tags and environment given in the Config.
+
+The Config's Mode selects the information Load computes for each
+package, trading cost for detail:
+
+  - NeedName, NeedFiles, NeedCompiledGoFiles, NeedImports,
+    NeedExportFile and NeedModule describe the package and its
+    files and imports, as reported by the go command.
+
+  - NeedSyntax parses the package's files into syntax trees.
+
+  - NeedTypes computes the type information of the package,
+    and NeedTypesInfo records the type of each expression and the
+    object of each identifier in its syntax trees.
+
+  - NeedDeps does the same for all the dependencies of the
+    packages matched by the patterns, and not just for the
+    packages themselves.
+
+The type information of a dependency comes from its export data, built
+by the go command, unless NeedDeps asks for the dependencies to be
+loaded in full; then Load type-checks every package from source. Either
+way, packages loaded together share their *types.Package objects, so
+that types from different packages may be compared.
+
+Errors in the packages, whether reported by the go command, the parser
+or the type checker, are recorded in the Errors field of each Package.
+Load itself returns an error only if it could not run the go command,
+for example because the Config is invalid or the go command was not
+found.
+
+	cfg := &packages.Config{Mode: packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		os.Exit(1)
+	}
+	for _, pkg := range pkgs {
+		// Inspect pkg.Syntax and pkg.TypesInfo.
+	}
+*/
+package packages
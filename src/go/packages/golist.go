@@ -0,0 +1,240 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A jsonPackage is the subset of the package information
+// printed by 'go list -json' that the loader uses.
+type jsonPackage struct {
+	ImportPath      string
+	Dir             string
+	Name            string
+	Export          string
+	GoFiles         []string
+	CompiledGoFiles []string
+	CgoFiles        []string
+	CFiles          []string
+	CXXFiles        []string
+	MFiles          []string
+	HFiles          []string
+	FFiles          []string
+	SFiles          []string
+	SwigFiles       []string
+	SwigCXXFiles    []string
+	SysoFiles       []string
+	Imports         []string
+	ImportMap       map[string]string
+	Module          *Module
+	DepOnly         bool
+	Error           *jsonPackageError
+}
+
+type jsonPackageError struct {
+	ImportStack []string
+	Pos         string
+	Err         string
+}
+
+// goList runs 'go list' on the patterns, asking for the information
+// needed by the mode, and returns the packages it prints, dependencies
+// (if any) first.
+func (ld *loader) goList(patterns []string) ([]*jsonPackage, error) {
+	args := []string{"list", "-e", "-json"}
+	if ld.Mode&(NeedCompiledGoFiles|NeedSyntax|NeedTypes|NeedTypesInfo|NeedTypesSizes) != 0 {
+		args = append(args, "-compiled")
+	}
+	if ld.Tests {
+		args = append(args, "-test")
+	}
+	if ld.usesExportData() {
+		args = append(args, "-export")
+	}
+	if ld.Mode&(NeedDeps|NeedTypes|NeedTypesInfo) != 0 {
+		args = append(args, "-deps")
+	}
+	if ld.Mode&(NeedImports|NeedDeps|NeedTypes|NeedTypesInfo) == 0 && !ld.Tests {
+		// Only the packages themselves are wanted:
+		// skip resolving their imports.
+		args = append(args, "-find")
+	}
+	args = append(args, ld.BuildFlags...)
+	args = append(args, "--")
+	args = append(args, patterns...)
+
+	out, stderr, err := ld.invokeGo(args...)
+	if err != nil {
+		// With -export, the go command fails if any package does
+		// not build, but still prints the information about all of
+		// them. Keep the build errors for the packages that have no
+		// export data.
+		if !ld.usesExportData() || out.Len() == 0 || ld.Context.Err() != nil {
+			return nil, err
+		}
+		ld.buildErrs = parseBuildErrors(stderr.String())
+	}
+
+	var list []*jsonPackage
+	dec := json.NewDecoder(out)
+	for {
+		p := new(jsonPackage)
+		if err := dec.Decode(p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("go list: decoding output: %v", err)
+		}
+		if p.ImportPath == "" {
+			// Without an import path the package cannot be
+			// identified; the go command reported it as an error.
+			if p.Error != nil {
+				return nil, fmt.Errorf("go list: %s", p.Error.Err)
+			}
+			return nil, fmt.Errorf("go list: package without import path in output")
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// goSizes returns the sizes of types for the target of the build,
+// as configured by the environment and build flags.
+func (ld *loader) goSizes() (types.Sizes, error) {
+	args := []string{"list", "-f", "{{context.GOARCH}} {{context.Compiler}}"}
+	args = append(args, ld.BuildFlags...)
+	args = append(args, "--", "unsafe")
+	out, _, err := ld.invokeGo(args...)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(out.String())
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("go list: unexpected output %q for target", out.String())
+	}
+	goarch, compiler := fields[0], fields[1]
+	sizes := types.SizesFor(compiler, goarch)
+	if sizes == nil {
+		return nil, fmt.Errorf("no type sizes for %s/%s", compiler, goarch)
+	}
+	return sizes, nil
+}
+
+// invokeGo runs the go command with the given arguments
+// and returns its standard output and standard error.
+// If the command fails, the error includes the standard error.
+func (ld *loader) invokeGo(args ...string) (stdout, stderr *bytes.Buffer, err error) {
+	stdout = new(bytes.Buffer)
+	stderr = new(bytes.Buffer)
+	cmd := exec.CommandContext(ld.Context, "go", args...)
+	env := ld.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// The go command uses $PWD, if it names the working directory,
+	// to report file names relative to a symlinked directory.
+	if ld.Dir != "" {
+		env = append(env[:len(env):len(env)], "PWD="+ld.Dir)
+	}
+	cmd.Env = env
+	cmd.Dir = ld.Dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ld.Context.Err(); ctxErr != nil {
+			return stdout, stderr, ctxErr
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return stdout, stderr, fmt.Errorf("running go command: %v", err)
+		}
+		// With -e, the go command reports problems with particular
+		// packages in its output and exits successfully; a failure
+		// means the patterns or flags themselves were invalid,
+		// or that building export data failed.
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout, stderr, fmt.Errorf("go %s: %s", args[0], msg)
+	}
+	return stdout, stderr, nil
+}
+
+// parseBuildErrors parses the errors printed by the go command
+// when building packages fails, which are grouped under a
+// "# importpath" line for each package, and returns them by
+// import path.
+func parseBuildErrors(stderr string) map[string]string {
+	errs := make(map[string]string)
+	var path string
+	for _, line := range strings.SplitAfter(stderr, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			path = strings.TrimSpace(line[2:])
+			continue
+		}
+		if path != "" && strings.TrimSpace(line) != "" {
+			errs[path] += line
+		}
+	}
+	for path, msg := range errs {
+		errs[path] = strings.TrimSpace(msg)
+	}
+	return errs
+}
+
+// pkgPath returns the package path of the package with the given ID,
+// which omits the bracketed suffix of test variants.
+func pkgPath(id string) string {
+	if i := strings.Index(id, " ["); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// otherFiles returns the lists of non-Go source files in p.
+func otherFiles(p *jsonPackage) [][]string {
+	return [][]string{p.CFiles, p.CXXFiles, p.MFiles, p.HFiles, p.FFiles, p.SFiles, p.SwigFiles, p.SwigCXXFiles, p.SysoFiles}
+}
+
+// compiledGoFiles returns the files in compiled that are not in other.
+// The go command lists the assembly files of a package among its
+// CompiledGoFiles, but they are not for the type checker. The files
+// generated by cgo, which are, need not have names ending in ".go".
+func compiledGoFiles(compiled, other []string) []string {
+	isOther := make(map[string]bool)
+	for _, file := range other {
+		isOther[file] = true
+	}
+	var res []string
+	for _, file := range compiled {
+		if !isOther[file] {
+			res = append(res, file)
+		}
+	}
+	return res
+}
+
+// absJoin returns the concatenation of the lists of files,
+// each made absolute by joining it to dir if it is not already.
+func absJoin(dir string, lists ...[]string) []string {
+	var res []string
+	for _, list := range lists {
+		for _, file := range list {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			res = append(res, file)
+		}
+	}
+	return res
+}
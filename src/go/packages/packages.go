@@ -0,0 +1,686 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/internal/gcimporter"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A LoadMode controls the amount of detail to return when loading.
+// The bits below can be combined to specify which fields should be
+// filled in the result packages.
+type LoadMode int
+
+const (
+	// NeedName adds Name and PkgPath.
+	NeedName LoadMode = 1 << iota
+
+	// NeedFiles adds GoFiles and OtherFiles.
+	NeedFiles
+
+	// NeedCompiledGoFiles adds CompiledGoFiles.
+	NeedCompiledGoFiles
+
+	// NeedImports adds Imports. If NeedDeps is not set, the Imports
+	// field contains placeholder Packages with only ID and PkgPath set.
+	NeedImports
+
+	// NeedDeps adds the fields requested by the LoadMode in the
+	// packages in Imports, and loads the types of all packages
+	// from source rather than from export data.
+	NeedDeps
+
+	// NeedExportFile adds ExportFile.
+	NeedExportFile
+
+	// NeedTypes adds Types, Fset, and IllTyped.
+	NeedTypes
+
+	// NeedSyntax adds Syntax and Fset.
+	NeedSyntax
+
+	// NeedTypesInfo adds TypesInfo and Fset.
+	NeedTypesInfo
+
+	// NeedTypesSizes adds TypesSizes.
+	NeedTypesSizes
+
+	// NeedModule adds Module.
+	NeedModule
+)
+
+// A Config specifies details about how packages should be loaded.
+// The zero value is a valid configuration.
+// Calls to Load do not modify this struct.
+type Config struct {
+	// Mode controls the level of information returned for each package.
+	Mode LoadMode
+
+	// Context specifies the context for the load operation.
+	// If the context is cancelled, the loader may stop early
+	// and return a context error.
+	// If Context is nil, the load cannot be cancelled.
+	Context context.Context
+
+	// Dir is the directory in which to run the go command.
+	// If Dir is empty, the go command runs in the current directory.
+	Dir string
+
+	// Env is the environment to use when invoking the go command.
+	// If Env is nil, the current environment is used.
+	// As in os/exec's Cmd, only the last value in the slice for
+	// each environment key is used. To specify the setting of only
+	// a few variables, append to the current environment, as in:
+	//
+	//	opt.Env = append(os.Environ(), "GOOS=plan9", "GOARCH=386")
+	//
+	Env []string
+
+	// BuildFlags is a list of command-line flags to be passed through
+	// to the go command, such as -tags or -mod=vendor.
+	BuildFlags []string
+
+	// Fset provides source position information for syntax trees and types.
+	// If Fset is nil, Load creates a new fileset.
+	Fset *token.FileSet
+
+	// ParseFile is called to read and parse each file
+	// when preparing a package's type-checked syntax tree.
+	// It must be safe to call ParseFile simultaneously from multiple goroutines.
+	// If ParseFile is nil, the loader uses parser.ParseFile
+	// with the parser.AllErrors and parser.ParseComments modes.
+	ParseFile func(fset *token.FileSet, filename string, src []byte) (*ast.File, error)
+
+	// If Tests is set, the loader includes not just the packages
+	// matching a particular pattern but also any related test packages,
+	// as 'go list -test' does: for package p, the test variant
+	// "p [p.test]" with the package's own tests, the external test
+	// package "p_test [p.test]" and the test main package "p.test".
+	Tests bool
+}
+
+// A Package describes a loaded Go package.
+type Package struct {
+	// ID is a unique identifier for a package,
+	// in a syntax provided by the go command: the import path of
+	// the package, followed for test variants by the name of the
+	// test binary in brackets, as in "fmt [fmt.test]".
+	ID string
+
+	// Name is the package name as it appears in the package source code.
+	Name string
+
+	// PkgPath is the package path as used by the go/types package.
+	PkgPath string
+
+	// Errors contains any errors encountered querying the metadata
+	// of the package, or while parsing or type-checking its files.
+	Errors []Error
+
+	// GoFiles lists the absolute file paths of the package's Go source files.
+	GoFiles []string
+
+	// CompiledGoFiles lists the absolute file paths of the package's source
+	// files that are suitable for type checking.
+	// This may differ from GoFiles if files are processed before compilation,
+	// as cgo files are.
+	CompiledGoFiles []string
+
+	// OtherFiles lists the absolute file paths of the package's non-Go source files,
+	// including assembly, C, C++, Fortran, Objective-C, SWIG, and so on.
+	OtherFiles []string
+
+	// ExportFile is the absolute path to a file containing type
+	// information for the package, as built by the go command.
+	ExportFile string
+
+	// Imports maps import paths appearing in the package's Go source files
+	// to corresponding loaded Packages.
+	Imports map[string]*Package
+
+	// Types provides type information for the package.
+	Types *types.Package
+
+	// Fset provides position information for Types, TypesInfo, and Syntax.
+	Fset *token.FileSet
+
+	// IllTyped indicates whether the package or any dependency contains errors.
+	// It is set only when Types is set.
+	IllTyped bool
+
+	// Syntax is the package's syntax trees, for the files listed in CompiledGoFiles.
+	Syntax []*ast.File
+
+	// TypesInfo provides type information about the package's syntax trees.
+	// It is set only when Syntax is set.
+	TypesInfo *types.Info
+
+	// TypesSizes provides the effective size function for types in TypesInfo.
+	TypesSizes types.Sizes
+
+	// Module is the module information for the package if it exists.
+	Module *Module
+}
+
+func (p *Package) String() string { return p.ID }
+
+// A Module describes a module, as reported by 'go list -m'.
+type Module struct {
+	Path      string       // module path
+	Version   string       // module version
+	Replace   *Module      // replaced by this module
+	Time      *time.Time   // time version was created
+	Main      bool         // is this the main module?
+	Indirect  bool         // is this module only an indirect dependency of main module?
+	Dir       string       // directory holding files for this module, if any
+	GoMod     string       // path to go.mod file used when loading this module, if any
+	GoVersion string       // go version used in module
+	Error     *ModuleError // error loading module
+}
+
+// A ModuleError holds errors loading a module.
+type ModuleError struct {
+	Err string // the error itself
+}
+
+// An Error describes a problem with a package's metadata, syntax, or types.
+type Error struct {
+	Pos  string // "file:line:col" or "file:line" or "" or "-"
+	Msg  string
+	Kind ErrorKind
+}
+
+// ErrorKind describes the source of the error, allowing the user to
+// differentiate between errors generated by the go command, the parser,
+// and the type checker.
+type ErrorKind int
+
+const (
+	UnknownError ErrorKind = iota
+	ListError
+	ParseError
+	TypeError
+)
+
+func (err Error) Error() string {
+	pos := err.Pos
+	if pos == "" {
+		pos = "-" // like token.Position{}.String()
+	}
+	return pos + ": " + err.Msg
+}
+
+// Load loads and returns the Go packages named by the given patterns.
+//
+// Config specifies loading options; nil behaves the same as an empty Config.
+//
+// Load returns an error if any of the patterns was invalid
+// as defined by the go command, or if the go command failed.
+// Errors associated with a particular package are recorded in the
+// corresponding Package's Errors list, and do not cause Load to
+// return an error. Clients may need to handle such errors before
+// proceeding with further analysis. The PrintErrors function is
+// provided for convenient display of all errors.
+func Load(cfg *Config, patterns ...string) ([]*Package, error) {
+	ld := newLoader(cfg)
+	list, err := ld.goList(patterns)
+	if err != nil {
+		return nil, err
+	}
+	if ld.Mode&(NeedTypes|NeedTypesInfo|NeedTypesSizes) != 0 {
+		if ld.sizes, err = ld.goSizes(); err != nil {
+			return nil, err
+		}
+	}
+	return ld.refine(list)
+}
+
+// A loader holds the state of a single call to Load.
+type loader struct {
+	Config
+	pkgs      map[string]*loaderPackage // by ID
+	sizes     types.Sizes
+	buildErrs map[string]string // errors building export data, by ID
+	exportMu  sync.Mutex        // serializes the reading of export data
+}
+
+// A loaderPackage is a Package with the state needed to load it.
+type loaderPackage struct {
+	*Package
+	root      bool // matched by a pattern
+	needtypes bool // type information is either requested or depended on
+	needsrc   bool // load from source (Mode >= NeedTypesInfo)
+	loadOnce  sync.Once
+}
+
+func newLoader(cfg *Config) *loader {
+	ld := new(loader)
+	if cfg != nil {
+		ld.Config = *cfg
+	}
+	if ld.Context == nil {
+		ld.Context = context.Background()
+	}
+	if ld.Fset == nil {
+		ld.Fset = token.NewFileSet()
+	}
+	if ld.ParseFile == nil {
+		ld.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		}
+	}
+	return ld
+}
+
+// usesExportData reports whether the types of some packages
+// come from their export data, which the go command must build.
+func (ld *loader) usesExportData() bool {
+	return ld.Mode&NeedExportFile != 0 || ld.Mode&(NeedTypes|NeedTypesInfo) != 0 && ld.Mode&NeedDeps == 0
+}
+
+// refine builds the package graph from the output of 'go list',
+// loads the syntax and types requested by the mode, and returns
+// the packages matched by the patterns.
+func (ld *loader) refine(list []*jsonPackage) ([]*Package, error) {
+	ld.pkgs = make(map[string]*loaderPackage)
+	var roots []*loaderPackage
+	for _, p := range list {
+		lpkg := &loaderPackage{Package: ld.newPackage(p), root: !p.DepOnly}
+		ld.pkgs[lpkg.ID] = lpkg
+		if lpkg.root {
+			roots = append(roots, lpkg)
+		}
+	}
+
+	// Resolve the imports of each package to the packages themselves,
+	// now that all are known. Without -deps, the imported packages were
+	// not listed; placeholders stand in for them.
+	for _, p := range list {
+		lpkg := ld.pkgs[p.ImportPath]
+		if len(p.Imports) == 0 || ld.Mode&(NeedImports|NeedDeps|NeedTypes|NeedTypesInfo) == 0 {
+			continue
+		}
+		byID := make(map[string]string)
+		for path, id := range p.ImportMap {
+			byID[id] = path
+		}
+		lpkg.Imports = make(map[string]*Package)
+		for _, id := range p.Imports {
+			if id == "C" {
+				continue
+			}
+			path := id
+			if src, ok := byID[id]; ok {
+				path = src
+			}
+			if ipkg := ld.pkgs[id]; ipkg != nil {
+				lpkg.Imports[path] = ipkg.Package
+			} else {
+				lpkg.Imports[path] = &Package{ID: id, PkgPath: pkgPath(id)}
+			}
+		}
+	}
+
+	// Decide how to load each package, then load them
+	// all in parallel, each after its dependencies.
+	if ld.Mode&(NeedSyntax|NeedTypes|NeedTypesInfo) != 0 {
+		for _, lpkg := range ld.pkgs {
+			lpkg.needtypes = ld.Mode&(NeedTypes|NeedTypesInfo) != 0
+			lpkg.needsrc = lpkg.root && ld.Mode&(NeedSyntax|NeedTypesInfo) != 0 ||
+				ld.Mode&NeedDeps != 0 && ld.Mode&(NeedSyntax|NeedTypes|NeedTypesInfo) != 0
+		}
+		var wg sync.WaitGroup
+		for _, lpkg := range roots {
+			wg.Add(1)
+			go func(lpkg *loaderPackage) {
+				ld.loadRecursive(lpkg)
+				wg.Done()
+			}(lpkg)
+		}
+		wg.Wait()
+		if err := ld.Context.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop the information that was not requested,
+	// but was needed to compute what was.
+	for _, lpkg := range ld.pkgs {
+		ld.trim(lpkg)
+	}
+
+	result := make([]*Package, len(roots))
+	for i, lpkg := range roots {
+		result[i] = lpkg.Package
+	}
+	return result, nil
+}
+
+// newPackage returns the Package described by the output p of 'go list'.
+func (ld *loader) newPackage(p *jsonPackage) *Package {
+	other := absJoin(p.Dir, otherFiles(p)...)
+	pkg := &Package{
+		ID:              p.ImportPath,
+		Name:            p.Name,
+		PkgPath:         pkgPath(p.ImportPath),
+		GoFiles:         absJoin(p.Dir, p.GoFiles, p.CgoFiles),
+		CompiledGoFiles: compiledGoFiles(absJoin(p.Dir, p.CompiledGoFiles), other),
+		OtherFiles:      other,
+		ExportFile:      p.Export,
+		Module:          p.Module,
+	}
+	if p.Error != nil {
+		pkg.Errors = append(pkg.Errors, Error{
+			Pos:  p.Error.Pos,
+			Msg:  p.Error.Err,
+			Kind: ListError,
+		})
+	}
+	// Without -compiled, type check the Go files as they are.
+	if len(pkg.CompiledGoFiles) == 0 {
+		pkg.CompiledGoFiles = pkg.GoFiles
+	}
+	return pkg
+}
+
+// trim clears the fields of lpkg that were not requested by the mode.
+func (ld *loader) trim(lpkg *loaderPackage) {
+	full := lpkg.root || ld.Mode&NeedDeps != 0
+	if ld.Mode&NeedName == 0 {
+		lpkg.Name = ""
+		lpkg.PkgPath = ""
+	}
+	if ld.Mode&NeedFiles == 0 {
+		lpkg.GoFiles = nil
+		lpkg.OtherFiles = nil
+	}
+	if ld.Mode&NeedCompiledGoFiles == 0 {
+		lpkg.CompiledGoFiles = nil
+	}
+	if ld.Mode&NeedImports == 0 {
+		lpkg.Imports = nil
+	}
+	if ld.Mode&NeedExportFile == 0 {
+		lpkg.ExportFile = ""
+	}
+	if ld.Mode&NeedTypes == 0 || !full {
+		lpkg.Types = nil
+		lpkg.IllTyped = false
+	}
+	if ld.Mode&NeedSyntax == 0 || !full {
+		lpkg.Syntax = nil
+	}
+	if ld.Mode&NeedTypesInfo == 0 || !full {
+		lpkg.TypesInfo = nil
+	}
+	if ld.Mode&NeedTypesSizes == 0 || !full {
+		lpkg.TypesSizes = nil
+	}
+	if lpkg.Types == nil && lpkg.Syntax == nil && lpkg.TypesInfo == nil {
+		lpkg.Fset = nil
+	}
+	if ld.Mode&NeedModule == 0 {
+		lpkg.Module = nil
+	}
+}
+
+// loadRecursive loads the dependencies of lpkg, in parallel,
+// and then lpkg itself. Each package is loaded only once.
+func (ld *loader) loadRecursive(lpkg *loaderPackage) {
+	lpkg.loadOnce.Do(func() {
+		if lpkg.needtypes {
+			var wg sync.WaitGroup
+			for _, ipkg := range lpkg.Imports {
+				imp := ld.pkgs[ipkg.ID]
+				if imp == nil {
+					continue
+				}
+				wg.Add(1)
+				go func(imp *loaderPackage) {
+					ld.loadRecursive(imp)
+					wg.Done()
+				}(imp)
+			}
+			wg.Wait()
+		}
+		if ld.Context.Err() == nil {
+			ld.loadPackage(lpkg)
+		}
+	})
+}
+
+// loadPackage loads the syntax and type information of lpkg,
+// whose dependencies are loaded already.
+func (ld *loader) loadPackage(lpkg *loaderPackage) {
+	if lpkg.PkgPath == "unsafe" {
+		lpkg.Types = types.Unsafe
+		lpkg.Fset = ld.Fset
+		lpkg.TypesSizes = ld.sizes
+		return
+	}
+	if !lpkg.needsrc {
+		if lpkg.needtypes {
+			ld.loadFromExportData(lpkg)
+		}
+		return
+	}
+
+	lpkg.Fset = ld.Fset
+	lpkg.Syntax = ld.parseFiles(lpkg)
+	if !lpkg.needtypes {
+		return
+	}
+
+	lpkg.Types = types.NewPackage(lpkg.PkgPath, lpkg.Name)
+	lpkg.TypesSizes = ld.sizes
+	if ld.Mode&NeedTypesInfo != 0 && (lpkg.root || ld.Mode&NeedDeps != 0) {
+		lpkg.TypesInfo = &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Scopes:     make(map[ast.Node]*types.Scope),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+	}
+
+	importer := importerFunc(func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		ipkg := lpkg.Imports[path]
+		if ipkg == nil {
+			return nil, fmt.Errorf("no metadata for %s", path)
+		}
+		if ipkg.Types == nil || !ipkg.Types.Complete() {
+			return nil, fmt.Errorf("could not load %s", ipkg.ID)
+		}
+		return ipkg.Types, nil
+	})
+	tc := &types.Config{
+		Importer: importer,
+		Sizes:    ld.sizes,
+		// The function bodies of dependencies do not affect the
+		// types of the packages that import them.
+		IgnoreFuncBodies: !lpkg.root && lpkg.TypesInfo == nil,
+		Error: func(err error) {
+			lpkg.Errors = append(lpkg.Errors, typeError(err))
+		},
+	}
+	types.NewChecker(tc, ld.Fset, lpkg.Types, lpkg.TypesInfo).Files(lpkg.Syntax)
+	ld.setIllTyped(lpkg)
+}
+
+// loadFromExportData loads the type information of lpkg
+// from the export data built by the go command.
+func (ld *loader) loadFromExportData(lpkg *loaderPackage) {
+	lpkg.Fset = ld.Fset
+	lpkg.TypesSizes = ld.sizes
+	if lpkg.ExportFile == "" {
+		if msg := ld.buildErrs[lpkg.ID]; msg != "" {
+			lpkg.Errors = append(lpkg.Errors, Error{Msg: msg, Kind: ListError})
+		} else if len(lpkg.Errors) == 0 {
+			lpkg.Errors = append(lpkg.Errors, Error{Msg: "no export data for " + lpkg.ID, Kind: ListError})
+		}
+		lpkg.Types = types.NewPackage(lpkg.PkgPath, lpkg.Name)
+		lpkg.IllTyped = true
+		return
+	}
+
+	// The export data refers to the packages that lpkg depends on,
+	// which must be the ones loaded already: give the importer a
+	// view of them by package path.
+	view := make(map[string]*types.Package)
+	var visit func(imports map[string]*Package)
+	visit = func(imports map[string]*Package) {
+		for _, p := range imports {
+			if p.Types != nil && view[p.PkgPath] == nil {
+				view[p.PkgPath] = p.Types
+				visit(p.Imports)
+			}
+		}
+	}
+	visit(lpkg.Imports)
+
+	// The importer adds the objects it reads to the packages
+	// in the view, which other packages share.
+	ld.exportMu.Lock()
+	defer ld.exportMu.Unlock()
+	tpkg, err := gcimporter.Import(ld.Fset, view, lpkg.PkgPath, "", func(string) (io.ReadCloser, error) {
+		return os.Open(lpkg.ExportFile)
+	})
+	if err != nil {
+		lpkg.Errors = append(lpkg.Errors, Error{Msg: fmt.Sprintf("reading export data for %s: %v", lpkg.ID, err), Kind: ListError})
+		tpkg = types.NewPackage(lpkg.PkgPath, lpkg.Name)
+	}
+	lpkg.Types = tpkg
+	ld.setIllTyped(lpkg)
+}
+
+// setIllTyped sets lpkg.IllTyped if lpkg or any of its dependencies has errors.
+func (ld *loader) setIllTyped(lpkg *loaderPackage) {
+	lpkg.IllTyped = len(lpkg.Errors) > 0
+	for _, ipkg := range lpkg.Imports {
+		if ipkg.IllTyped {
+			lpkg.IllTyped = true
+		}
+	}
+}
+
+// ioLimit limits the number of files being read in parallel.
+var ioLimit = make(chan bool, 20)
+
+// parseFiles reads and parses the CompiledGoFiles of lpkg in parallel,
+// recording the errors in lpkg.Errors.
+func (ld *loader) parseFiles(lpkg *loaderPackage) []*ast.File {
+	files := make([]*ast.File, len(lpkg.CompiledGoFiles))
+	errs := make([]error, len(lpkg.CompiledGoFiles))
+	var wg sync.WaitGroup
+	for i, filename := range lpkg.CompiledGoFiles {
+		wg.Add(1)
+		go func(i int, filename string) {
+			defer wg.Done()
+			ioLimit <- true
+			src, err := ioutil.ReadFile(filename)
+			<-ioLimit
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			files[i], errs[i] = ld.ParseFile(ld.Fset, filename, src)
+		}(i, filename)
+	}
+	wg.Wait()
+
+	var parsed []*ast.File
+	for i, f := range files {
+		if err := errs[i]; err != nil {
+			if list, ok := err.(scanner.ErrorList); ok {
+				for _, err := range list {
+					lpkg.Errors = append(lpkg.Errors, Error{Pos: err.Pos.String(), Msg: err.Msg, Kind: ParseError})
+				}
+			} else {
+				lpkg.Errors = append(lpkg.Errors, Error{Pos: lpkg.CompiledGoFiles[i], Msg: err.Error(), Kind: ParseError})
+			}
+		}
+		if f != nil {
+			parsed = append(parsed, f)
+		}
+	}
+	return parsed
+}
+
+// typeError converts an error reported by the type checker to an Error.
+func typeError(err error) Error {
+	if err, ok := err.(types.Error); ok {
+		return Error{Pos: err.Fset.Position(err.Pos).String(), Msg: err.Msg, Kind: TypeError}
+	}
+	return Error{Msg: err.Error(), Kind: TypeError}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// Visit visits all the packages in the import graph whose roots are
+// pkgs, calling the optional pre function the first time each package
+// is encountered (preorder), and the optional post function after a
+// package's dependencies have been visited (postorder).
+// The boolean result of pre(pkg) determines whether
+// the imports of package pkg are visited.
+func Visit(pkgs []*Package, pre func(*Package) bool, post func(*Package)) {
+	seen := make(map[*Package]bool)
+	var visit func(*Package)
+	visit = func(pkg *Package) {
+		if !seen[pkg] {
+			seen[pkg] = true
+
+			if pre == nil || pre(pkg) {
+				paths := make([]string, 0, len(pkg.Imports))
+				for path := range pkg.Imports {
+					paths = append(paths, path)
+				}
+				sort.Strings(paths) // Imports is a map, this makes visit stable
+				for _, path := range paths {
+					visit(pkg.Imports[path])
+				}
+			}
+
+			if post != nil {
+				post(pkg)
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+}
+
+// PrintErrors prints to os.Stderr the accumulated errors of all
+// packages in the import graph rooted at pkgs, dependencies first.
+// PrintErrors returns the number of errors printed.
+func PrintErrors(pkgs []*Package) int {
+	var n int
+	Visit(pkgs, nil, func(pkg *Package) {
+		for _, err := range pkg.Errors {
+			fmt.Fprintln(os.Stderr, err)
+			n++
+		}
+	})
+	return n
+}
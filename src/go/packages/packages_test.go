@@ -0,0 +1,281 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"go/types"
+	"internal/testenv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTree writes the files, named by slash-separated paths,
+// to a new temporary directory and returns the directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "packages_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The go command reports file names under the real directory.
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testEnv returns the environment in which to run the go command
+// of the tree under test, with the given settings added.
+func testEnv(t *testing.T, vars ...string) []string {
+	gotool := testenv.GoToolPath(t)
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOFLAGS=") && !strings.HasPrefix(kv, "PATH=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, "GOFLAGS=", "PATH="+filepath.Dir(gotool)+string(filepath.ListSeparator)+os.Getenv("PATH"))
+	return append(env, vars...)
+}
+
+var gopathTree = map[string]string{
+	"src/a/a.go": `package a
+
+import "b"
+
+func F(x b.T) int { return int(x) + b.N }
+`,
+	"src/a/a.s": "",
+	"src/b/b.go": `package b
+
+type T int
+
+const N = 1
+`,
+	"src/b/b_test.go": `package b
+
+import "testing"
+
+func TestB(t *testing.T) {}
+`,
+	"src/b/x_test.go": `package b_test
+
+import (
+	"b"
+	"testing"
+)
+
+func TestX(t *testing.T) { _ = b.N }
+`,
+	"src/c/c.go": `package c
+
+func F() {
+`,
+	"src/d/d.go": `package d
+
+var X int = "x"
+`,
+	"src/e/e.go": `package e
+
+import "d"
+
+var Y = d.X
+`,
+}
+
+func loadGOPATH(t *testing.T, cfg *Config, patterns ...string) []*Package {
+	t.Helper()
+	testenv.MustHaveGoBuild(t)
+	dir := writeTree(t, gopathTree)
+	defer os.RemoveAll(dir)
+	cfg.Dir = filepath.Join(dir, "src")
+	cfg.Env = testEnv(t, "GOPATH="+dir, "GO111MODULE=off")
+	pkgs, err := Load(cfg, patterns...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgs
+}
+
+func TestLoadFiles(t *testing.T) {
+	pkgs := loadGOPATH(t, &Config{Mode: NeedName | NeedFiles | NeedImports}, "a")
+	if len(pkgs) != 1 {
+		t.Fatalf("Load returned %d packages, want 1", len(pkgs))
+	}
+	a := pkgs[0]
+	if a.ID != "a" || a.Name != "a" || a.PkgPath != "a" {
+		t.Errorf("ID, Name, PkgPath = %q, %q, %q, want a, a, a", a.ID, a.Name, a.PkgPath)
+	}
+	if len(a.GoFiles) != 1 || !filepath.IsAbs(a.GoFiles[0]) || filepath.Base(a.GoFiles[0]) != "a.go" {
+		t.Errorf("GoFiles = %q, want absolute a.go", a.GoFiles)
+	}
+	if len(a.OtherFiles) != 1 || filepath.Base(a.OtherFiles[0]) != "a.s" {
+		t.Errorf("OtherFiles = %q, want a.s", a.OtherFiles)
+	}
+	if a.CompiledGoFiles != nil || a.Types != nil || a.Syntax != nil {
+		t.Errorf("Load returned fields not requested by the mode")
+	}
+	b := a.Imports["b"]
+	if b == nil || b.ID != "b" {
+		t.Fatalf("Imports = %v, want b", a.Imports)
+	}
+	if b.Name != "" || b.GoFiles != nil {
+		t.Errorf("import b loaded without NeedDeps")
+	}
+}
+
+func TestLoadExportData(t *testing.T) {
+	pkgs := loadGOPATH(t, &Config{Mode: NeedName | NeedTypes}, "a")
+	a := pkgs[0]
+	if len(a.Errors) > 0 {
+		t.Fatal(a.Errors)
+	}
+	if a.Types == nil || !a.Types.Complete() {
+		t.Fatal("Types not loaded")
+	}
+	if a.Syntax != nil || a.TypesInfo != nil {
+		t.Errorf("Syntax and TypesInfo set without NeedSyntax and NeedTypesInfo")
+	}
+	f := a.Types.Scope().Lookup("F")
+	if f == nil {
+		t.Fatal("a.F not found")
+	}
+	if got, want := f.Type().String(), "func(x b.T) int"; got != want {
+		t.Errorf("a.F has type %s, want %s", got, want)
+	}
+}
+
+func TestLoadDeps(t *testing.T) {
+	pkgs := loadGOPATH(t, &Config{Mode: NeedName | NeedImports | NeedDeps | NeedTypes | NeedSyntax | NeedTypesInfo}, "a")
+	a := pkgs[0]
+	b := a.Imports["b"]
+	for _, pkg := range []*Package{a, b} {
+		if len(pkg.Errors) > 0 {
+			t.Fatal(pkg.Errors)
+		}
+		if pkg.Types == nil || len(pkg.Syntax) != 1 || pkg.TypesInfo == nil {
+			t.Fatalf("package %s not loaded from source", pkg)
+		}
+	}
+	if b.Name != "b" {
+		t.Errorf("b.Name = %q, want b", b.Name)
+	}
+
+	// The parameter of a.F has the type declared by b.
+	sig := a.Types.Scope().Lookup("F").Type().(*types.Signature)
+	if got, want := sig.Params().At(0).Type(), b.Types.Scope().Lookup("T").Type(); got != want {
+		t.Errorf("a.F parameter has type %v, not b.T", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	pkgs := loadGOPATH(t, &Config{Mode: NeedName | NeedImports | NeedTypes | NeedSyntax | NeedTypesInfo}, "c", "e", "nonexistent")
+	if len(pkgs) != 3 {
+		t.Fatalf("Load returned %d packages, want 3", len(pkgs))
+	}
+	c, e, nonexistent := pkgs[0], pkgs[1], pkgs[2]
+	if len(c.Errors) == 0 || c.Errors[0].Kind != ParseError {
+		t.Errorf("c.Errors = %v, want parse error", c.Errors)
+	}
+	if len(e.Errors) != 1 || e.Errors[0].Kind != TypeError || !strings.Contains(e.Errors[0].Msg, "could not import d") || !e.IllTyped {
+		t.Errorf("e.Errors, e.IllTyped = %v, %v, want import error and ill typed", e.Errors, e.IllTyped)
+	}
+	d := e.Imports["d"]
+	if len(d.Errors) != 1 || d.Errors[0].Kind != ListError || !strings.Contains(d.Errors[0].Msg, "cannot use") {
+		t.Errorf("d.Errors = %v, want build error from go command", d.Errors)
+	}
+	if len(nonexistent.Errors) == 0 || nonexistent.Errors[0].Kind != ListError {
+		t.Errorf("nonexistent.Errors = %v, want list error", nonexistent.Errors)
+	}
+}
+
+func TestLoadTypeErrors(t *testing.T) {
+	pkgs := loadGOPATH(t, &Config{Mode: NeedName | NeedImports | NeedDeps | NeedTypes}, "e")
+	e := pkgs[0]
+	d := e.Imports["d"]
+	if len(d.Errors) != 1 || d.Errors[0].Kind != TypeError || !strings.Contains(d.Errors[0].Pos, "d.go:3:") {
+		t.Errorf("d.Errors = %v, want type error at d.go:3", d.Errors)
+	}
+	if !d.IllTyped || !e.IllTyped {
+		t.Errorf("d.IllTyped, e.IllTyped = %v, %v, want true, true", d.IllTyped, e.IllTyped)
+	}
+	if n := PrintErrors(nil); n != 0 {
+		t.Errorf("PrintErrors(nil) = %d, want 0", n)
+	}
+}
+
+func TestLoadTests(t *testing.T) {
+	pkgs := loadGOPATH(t, &Config{Mode: NeedName | NeedFiles | NeedImports, Tests: true}, "b")
+	var ids []string
+	byID := make(map[string]*Package)
+	for _, pkg := range pkgs {
+		ids = append(ids, pkg.ID)
+		byID[pkg.ID] = pkg
+	}
+	sort.Strings(ids)
+	if got, want := strings.Join(ids, ", "), "b, b [b.test], b.test, b_test [b.test]"; got != want {
+		t.Fatalf("Load returned %s, want %s", got, want)
+	}
+	if pkg := byID["b [b.test]"]; pkg.PkgPath != "b" || len(pkg.GoFiles) != 2 {
+		t.Errorf("b [b.test] has PkgPath %q, GoFiles %q", pkg.PkgPath, pkg.GoFiles)
+	}
+	if pkg := byID["b_test [b.test]"]; pkg.Imports["b"].ID != "b [b.test]" {
+		t.Errorf("b_test imports %s, want b [b.test]", pkg.Imports["b"].ID)
+	}
+}
+
+func TestLoadModuleVendor(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	dir := writeTree(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.13\n\nrequire example.com/v v1.0.0\n",
+		"m.go": `package m
+
+import "example.com/v"
+
+var X = v.X
+`,
+		"vendor/modules.txt":        "# example.com/v v1.0.0\nexample.com/v\n",
+		"vendor/example.com/v/v.go": "package v\n\nvar X = 1\n",
+	})
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{
+		Mode:       NeedName | NeedImports | NeedDeps | NeedTypes | NeedModule,
+		Dir:        dir,
+		Env:        testEnv(t, "GO111MODULE=on", "GOPROXY=off", "GOPATH="+filepath.Join(dir, "gopath")),
+		BuildFlags: []string{"-mod=vendor"},
+	}
+	pkgs, err := Load(cfg, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := pkgs[0]
+	if m.ID != "example.com/m" || len(m.Errors) > 0 {
+		t.Fatalf("loaded %s with errors %v", m.ID, m.Errors)
+	}
+	if m.Module == nil || !m.Module.Main || m.Module.Path != "example.com/m" {
+		t.Errorf("m.Module = %+v, want main module example.com/m", m.Module)
+	}
+	v := m.Imports["example.com/v"]
+	if v == nil || v.Types == nil || v.Types.Scope().Lookup("X") == nil {
+		t.Fatalf("vendored package example.com/v not loaded")
+	}
+	if v.Module == nil || v.Module.Path != "example.com/v" || v.Module.Version != "v1.0.0" {
+		t.Errorf("v.Module = %+v, want example.com/v v1.0.0", v.Module)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage collects the coverage counters of a program built
+// with 'go build -cover' and writes them when the program exits.
+//
+// The go command generates, for each package built with coverage,
+// an init function that registers the package's counters by calling
+// RegisterFile and, in branch mode, RegisterBranches. When the program
+// returns from main.main or calls os.Exit, the counters are written to
+// a new file in the directory named by the GOCOVERDIR environment
+// variable. Each file is named
+//
+//	covcounters.<pid>.<nanoseconds>
+//
+// and holds a coverage profile in the text format read by
+// 'go tool cover'. The files written by one or more runs can be
+// merged with 'go tool covdata'.
+package coverage
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// CounterFilePrefix is the prefix of the name of each counter file.
+const CounterFilePrefix = "covcounters."
+
+type block struct {
+	line0, col0, line1, col1 uint32
+	stmts                    uint16
+}
+
+type branch struct {
+	line0, col0, line1, col1 uint32
+	outcomes                 uint16
+}
+
+type file struct {
+	name          string
+	counter       []uint32
+	blocks        []block
+	branchCounter []uint32
+	branches      []branch
+}
+
+var (
+	mode  string
+	files []file
+	seen  = make(map[string]bool)
+)
+
+// runtime_addExitHook is provided by the runtime.
+func runtime_addExitHook(f func())
+
+// RegisterFile records the counters of one source file, as declared
+// by 'go tool cover' with the given mode. The arguments follow the
+// layout of the generated coverage variable: for block i, pos holds
+// the start line, end line and packed columns at 3*i, and numStmts
+// holds the number of statements.
+//
+// RegisterFile is called from package initialization, and it is not
+// safe to call concurrently.
+func RegisterFile(coverMode, fileName string, counter []uint32, pos []uint32, numStmts []uint16) {
+	if 3*len(counter) != len(pos) || len(counter) != len(numStmts) {
+		panic("coverage: mismatched sizes")
+	}
+	if mode == "" {
+		mode = coverMode
+		runtime_addExitHook(writeCounters)
+	} else if mode != coverMode {
+		panic("coverage: mode " + coverMode + " does not match " + mode)
+	}
+	if seen[fileName] {
+		return
+	}
+	seen[fileName] = true
+	f := file{name: fileName, counter: counter, blocks: make([]block, len(counter))}
+	for i := range counter {
+		f.blocks[i] = block{
+			line0: pos[3*i+0],
+			col0:  pos[3*i+2] & 0xFFFF,
+			line1: pos[3*i+1],
+			col1:  pos[3*i+2] >> 16,
+			stmts: numStmts[i],
+		}
+	}
+	files = append(files, f)
+}
+
+// RegisterBranches records the branch counters of a source file
+// already registered with RegisterFile, as declared by 'go tool cover'
+// in branch mode. For decision i, pos holds the position as for blocks
+// and outcomes holds the number of outcomes, whose counters are
+// consecutive in counter.
+func RegisterBranches(fileName string, counter []uint32, pos []uint32, outcomes []uint16) {
+	if 3*len(outcomes) != len(pos) {
+		panic("coverage: mismatched sizes")
+	}
+	for i := range files {
+		f := &files[i]
+		if f.name != fileName || f.branchCounter != nil {
+			continue
+		}
+		f.branchCounter = counter
+		f.branches = make([]branch, len(outcomes))
+		for i := range outcomes {
+			f.branches[i] = branch{
+				line0:    pos[3*i+0],
+				col0:     pos[3*i+2] & 0xFFFF,
+				line1:    pos[3*i+1],
+				col1:     pos[3*i+2] >> 16,
+				outcomes: outcomes[i],
+			}
+		}
+		return
+	}
+}
+
+// writeCounters writes the counters to a new file in $GOCOVERDIR.
+func writeCounters() {
+	dir := os.Getenv("GOCOVERDIR")
+	if dir == "" {
+		os.Stderr.WriteString("warning: GOCOVERDIR not set, no coverage data emitted\n")
+		return
+	}
+	name := CounterFilePrefix + strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := WriteProfile(filepath.Join(dir, name)); err != nil {
+		os.Stderr.WriteString("error: coverage data: " + err.Error() + "\n")
+	}
+}
+
+// WriteProfile writes the current values of the registered counters
+// to the named file, in the text profile format.
+func WriteProfile(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString("mode: " + mode + "\n")
+	var buf []byte
+	for _, cf := range files {
+		for i, b := range cf.blocks {
+			buf = appendPos(append(buf[:0], cf.name...), b.line0, b.col0, b.line1, b.col1)
+			buf = append(buf, ' ')
+			buf = strconv.AppendUint(buf, uint64(b.stmts), 10)
+			buf = append(buf, ' ')
+			buf = strconv.AppendUint(buf, uint64(atomic.LoadUint32(&cf.counter[i])), 10) // for -covermode=atomic
+			buf = append(buf, '\n')
+			w.Write(buf)
+		}
+		k := 0
+		for _, b := range cf.branches {
+			buf = appendPos(append(buf[:0], cf.name...), b.line0, b.col0, b.line1, b.col1)
+			buf = append(buf, " br"...)
+			for i := 0; i < int(b.outcomes); i++ {
+				buf = append(buf, ' ')
+				buf = strconv.AppendUint(buf, uint64(cf.branchCounter[k]), 10)
+				k++
+			}
+			buf = append(buf, '\n')
+			w.Write(buf)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// appendPos appends the position ":line0.col0,line1.col1" to buf.
+func appendPos(buf []byte, line0, col0, line1, col1 uint32) []byte {
+	buf = append(buf, ':')
+	buf = strconv.AppendUint(buf, uint64(line0), 10)
+	buf = append(buf, '.')
+	buf = strconv.AppendUint(buf, uint64(col0), 10)
+	buf = append(buf, ',')
+	buf = strconv.AppendUint(buf, uint64(line1), 10)
+	buf = append(buf, '.')
+	buf = strconv.AppendUint(buf, uint64(col1), 10)
+	return buf
+}
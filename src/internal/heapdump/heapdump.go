@@ -0,0 +1,571 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package heapdump reads the heap dumps written by
+// runtime/debug.WriteHeapDump.
+//
+// A heap dump starts with a header line that identifies the version
+// of the format, "go1.14 heap dump\n", followed by a sequence of
+// records. Each record starts with a tag and ends where the next
+// record starts. The last record has tag 0 (EOF).
+//
+// Integers are unsigned varints, as written by encoding/binary.PutUvarint.
+// Booleans are integers that are 0 or 1. Strings and byte sequences
+// are an integer length followed by that many bytes. A field list is
+// a sequence of (kind, offset) integer pairs, terminated by kind 0;
+// kind 1 is a pointer at the offset in the enclosing memory.
+//
+// The records are:
+//
+//	1 object:       address, contents, field list
+//	2 other root:   description, pointer
+//	3 type:         address, size, name, whether the data word of an
+//	                interface holding the type is a pointer,
+//	                whether the type is stored directly in interfaces,
+//	                reflect.Kind, size of the prefix that holds pointers,
+//	                element type address (arrays, pointers and slices;
+//	                the bucket type for maps; else 0),
+//	                array length, number of fields,
+//	                (field offset, field type address) for each struct field
+//	4 goroutine:    address of the G, stack pointer, goroutine ID, PC of
+//	                the go statement, status, system goroutine, unused,
+//	                wait start time, wait reason, context pointer,
+//	                address of the M, top defer, top panic
+//	5 stack frame:  stack pointer, depth, stack pointer of the callee frame,
+//	                contents, entry PC, PC, continuation PC, function name,
+//	                field list
+//	6 parameters:   big endian, pointer size, heap start, heap end,
+//	                GOARCH, GOEXPERIMENT, number of CPUs
+//	7 finalizer:    object, closure, code pointer of the closure,
+//	                argument type, object pointer type
+//	8 itab:         address, type address
+//	9 OS thread:    address of the M, M ID, OS thread ID
+//	10 memstats:    the fields of runtime.MemStats from Alloc to
+//	                PauseTotalNs, 256 pause times, NumGC
+//	11 queued finalizer: as finalizer
+//	12 data segment: address, contents, field list
+//	13 BSS segment:  address, contents, field list
+//	14 defer:       address, goroutine, stack pointer, PC, closure,
+//	                code pointer of the closure, next defer
+//	15 panic:       address, goroutine, type of the argument, data word of
+//	                the argument, 0, next panic
+//	16 memprof:     bucket, size, stack depth, (function, file, line) for
+//	                each frame, allocations, frees
+//	17 alloc sample: object address, memprof bucket
+//
+// Stack frames follow the goroutine they belong to, innermost first.
+// Type records precede the records that refer to the type, except for
+// the element and field types of other types.
+//
+// The previous version of the format, "go1.7 heap dump\n", is also
+// accepted. Its type records end after the interface data word flag.
+package heapdump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Record tags.
+const (
+	tagEOF             = 0
+	tagObject          = 1
+	tagOtherRoot       = 2
+	tagType            = 3
+	tagGoroutine       = 4
+	tagStackFrame      = 5
+	tagParams          = 6
+	tagFinalizer       = 7
+	tagItab            = 8
+	tagOSThread        = 9
+	tagMemStats        = 10
+	tagQueuedFinalizer = 11
+	tagData            = 12
+	tagBSS             = 13
+	tagDefer           = 14
+	tagPanic           = 15
+	tagMemProf         = 16
+	tagAllocSample     = 17
+)
+
+// Field kinds.
+const (
+	fieldKindEol   = 0
+	fieldKindPtr   = 1
+	fieldKindIface = 2
+	fieldKindEface = 3
+)
+
+// Kinds of types, as in reflect.Kind.
+const (
+	KindArray  = 17
+	KindMap    = 21
+	KindPtr    = 22
+	KindSlice  = 23
+	KindString = 24
+	KindStruct = 25
+)
+
+// A Dump is the contents of a heap dump.
+type Dump struct {
+	Version    int // minor version of the format: 7 or 14
+	Params     Params
+	Types      map[uint64]*Type  // by address
+	Itabs      map[uint64]uint64 // type address by itab address
+	Objects    []*Object         // sorted by address
+	Goroutines []*Goroutine
+	Segments   []*Segment // data and BSS
+	Finalizers []*Finalizer
+	OtherRoots []*OtherRoot
+	MemStats   MemStats
+}
+
+// Params describes the process that wrote the dump.
+type Params struct {
+	BigEndian    bool
+	PtrSize      int
+	HeapStart    uint64
+	HeapEnd      uint64
+	GOARCH       string
+	GOEXPERIMENT string
+	NCPU         int
+}
+
+// A Type is a Go type known to the runtime.
+// Only Addr, Size, Name and EfacePtr are set in version 7 dumps.
+type Type struct {
+	Addr     uint64
+	Size     uint64
+	Name     string
+	EfacePtr bool // the data word of an interface holding the type is a pointer
+	Direct   bool // values of the type are stored directly in interfaces
+	Kind     int
+	PtrData  uint64 // size of the prefix of values that holds pointers
+	Elem     uint64 // element type of arrays, pointers and slices; bucket type of maps
+	Len      uint64 // length of arrays
+	Fields   []Field
+}
+
+// A Field is a field of a struct type.
+type Field struct {
+	Offset uint64
+	Type   uint64
+}
+
+// An Object is an allocated heap object.
+type Object struct {
+	Addr uint64
+	Data []byte
+	Ptrs []uint64 // offsets of the pointers in Data
+}
+
+// Size returns the size of the object, which is the size of the
+// allocation and may be larger than the size of the value in it.
+func (o *Object) Size() uint64 {
+	return uint64(len(o.Data))
+}
+
+// A Goroutine is a goroutine that was not running or dead.
+type Goroutine struct {
+	Addr       uint64 // address of the G
+	ID         uint64
+	Status     uint64
+	System     bool
+	WaitReason string
+	Frames     []*Frame // innermost first
+}
+
+// A Frame is a stack frame of a goroutine.
+type Frame struct {
+	SP    uint64
+	Depth int
+	Func  string
+	PC    uint64
+	Data  []byte
+	Ptrs  []uint64 // offsets of the pointers in Data
+}
+
+// A Segment is the data or BSS segment of the executable.
+type Segment struct {
+	Name string // "data" or "bss"
+	Addr uint64
+	Data []byte
+	Ptrs []uint64 // offsets of the pointers in Data
+}
+
+// A Finalizer is a finalizer set on an object with runtime.SetFinalizer.
+type Finalizer struct {
+	Obj     uint64
+	Fn      uint64 // closure
+	ObjType uint64 // pointer type of the object
+	Queued  bool   // the object is unreachable and the finalizer is ready to run
+}
+
+// An OtherRoot is a root that does not fit any of the other categories.
+type OtherRoot struct {
+	Desc string
+	To   uint64
+}
+
+// MemStats holds some of the runtime.MemStats when the dump was written.
+type MemStats struct {
+	Alloc       uint64
+	TotalAlloc  uint64
+	Sys         uint64
+	HeapAlloc   uint64
+	HeapObjects uint64
+	NumGC       uint64
+}
+
+// RootKind is the kind of a Root.
+type RootKind int
+
+const (
+	RootData      RootKind = iota // pointer in the data segment
+	RootBSS                       // pointer in the BSS segment
+	RootStack                     // pointer in a stack frame
+	RootFinalizer                 // finalizer closure or object
+	RootOther                     // other root
+)
+
+// A Root is a pointer from outside of the heap that keeps objects alive.
+type Root struct {
+	Kind RootKind
+	Addr uint64 // address of the pointer, if it is in memory
+	To   uint64 // value of the pointer
+
+	G     *Goroutine // for RootStack
+	Frame *Frame     // for RootStack
+	Desc  string     // for RootOther
+}
+
+func (r *Root) String() string {
+	switch r.Kind {
+	case RootData:
+		return fmt.Sprintf("data %#x", r.Addr)
+	case RootBSS:
+		return fmt.Sprintf("bss %#x", r.Addr)
+	case RootStack:
+		return fmt.Sprintf("goroutine %d frame %s", r.G.ID, r.Frame.Func)
+	case RootFinalizer:
+		return "finalizer"
+	default:
+		return r.Desc
+	}
+}
+
+// Read reads a heap dump.
+func Read(r io.Reader) (*Dump, error) {
+	p := &parser{r: bufio.NewReader(r)}
+	d, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("heap dump: %v", err)
+	}
+	return d, nil
+}
+
+type parser struct {
+	r       *bufio.Reader
+	err     error
+	ptrSize uint64
+}
+
+var errHeader = errors.New("not a heap dump or unsupported version")
+
+func (p *parser) parse() (*Dump, error) {
+	hdr, err := p.r.ReadString('\n')
+	if err != nil {
+		return nil, errHeader
+	}
+	d := &Dump{
+		Types: make(map[uint64]*Type),
+		Itabs: make(map[uint64]uint64),
+	}
+	switch hdr {
+	case "go1.7 heap dump\n":
+		d.Version = 7
+	case "go1.14 heap dump\n":
+		d.Version = 14
+	default:
+		return nil, errHeader
+	}
+
+	var g *Goroutine
+	for p.err == nil {
+		tag := p.int()
+		switch tag {
+		case tagEOF:
+			sort.Slice(d.Objects, func(i, j int) bool {
+				return d.Objects[i].Addr < d.Objects[j].Addr
+			})
+			return d, nil
+		case tagObject:
+			o := &Object{Addr: p.int(), Data: p.bytes()}
+			o.Ptrs = p.fields()
+			d.Objects = append(d.Objects, o)
+		case tagOtherRoot:
+			d.OtherRoots = append(d.OtherRoots, &OtherRoot{Desc: p.string(), To: p.int()})
+		case tagType:
+			t := &Type{Addr: p.int(), Size: p.int(), Name: p.string(), EfacePtr: p.bool()}
+			if d.Version >= 14 {
+				t.Direct = p.bool()
+				t.Kind = int(p.int())
+				t.PtrData = p.int()
+				t.Elem = p.int()
+				t.Len = p.int()
+				n := p.int()
+				for i := uint64(0); i < n && p.err == nil; i++ {
+					t.Fields = append(t.Fields, Field{Offset: p.int(), Type: p.int()})
+				}
+			}
+			d.Types[t.Addr] = t
+		case tagGoroutine:
+			g = &Goroutine{Addr: p.int()}
+			p.int() // sp
+			g.ID = p.int()
+			p.int() // gopc
+			g.Status = p.int()
+			g.System = p.bool()
+			p.bool() // isbackground
+			p.int()  // waitsince
+			g.WaitReason = p.string()
+			p.ints(4) // ctxt, m, defer, panic
+			d.Goroutines = append(d.Goroutines, g)
+		case tagStackFrame:
+			f := &Frame{SP: p.int(), Depth: int(p.int())}
+			p.int() // child sp
+			f.Data = p.bytes()
+			p.int() // entry
+			f.PC = p.int()
+			p.int() // continpc
+			f.Func = p.string()
+			f.Ptrs = p.fields()
+			if g == nil {
+				return nil, errors.New("stack frame outside of goroutine")
+			}
+			g.Frames = append(g.Frames, f)
+		case tagParams:
+			d.Params.BigEndian = p.bool()
+			d.Params.PtrSize = int(p.int())
+			d.Params.HeapStart = p.int()
+			d.Params.HeapEnd = p.int()
+			d.Params.GOARCH = p.string()
+			d.Params.GOEXPERIMENT = p.string()
+			d.Params.NCPU = int(p.int())
+			if d.Params.PtrSize != 4 && d.Params.PtrSize != 8 {
+				return nil, fmt.Errorf("bad pointer size %d", d.Params.PtrSize)
+			}
+			p.ptrSize = uint64(d.Params.PtrSize)
+		case tagFinalizer, tagQueuedFinalizer:
+			f := &Finalizer{Obj: p.int(), Fn: p.int(), Queued: tag == tagQueuedFinalizer}
+			p.ints(2) // fn.fn, fint
+			f.ObjType = p.int()
+			d.Finalizers = append(d.Finalizers, f)
+		case tagItab:
+			addr := p.int()
+			d.Itabs[addr] = p.int()
+		case tagOSThread:
+			p.ints(3)
+		case tagMemStats:
+			var s [24 + 256 + 1]uint64
+			for i := range s {
+				s[i] = p.int()
+			}
+			d.MemStats = MemStats{
+				Alloc:       s[0],
+				TotalAlloc:  s[1],
+				Sys:         s[2],
+				HeapAlloc:   s[6],
+				HeapObjects: s[11],
+				NumGC:       s[len(s)-1],
+			}
+		case tagData, tagBSS:
+			s := &Segment{Name: "data", Addr: p.int(), Data: p.bytes()}
+			if tag == tagBSS {
+				s.Name = "bss"
+			}
+			s.Ptrs = p.fields()
+			d.Segments = append(d.Segments, s)
+		case tagDefer:
+			p.ints(7)
+		case tagPanic:
+			p.ints(6)
+		case tagMemProf:
+			p.ints(2) // bucket, size
+			n := p.int()
+			for i := uint64(0); i < n && p.err == nil; i++ {
+				p.string()
+				p.string()
+				p.int()
+			}
+			p.ints(2) // allocs, frees
+		case tagAllocSample:
+			p.ints(2)
+		default:
+			return nil, fmt.Errorf("unknown record tag %d", tag)
+		}
+	}
+	if p.err == io.EOF {
+		p.err = io.ErrUnexpectedEOF
+	}
+	return nil, p.err
+}
+
+func (p *parser) int() uint64 {
+	if p.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		p.err = err
+	}
+	return v
+}
+
+func (p *parser) ints(n int) {
+	for i := 0; i < n; i++ {
+		p.int()
+	}
+}
+
+func (p *parser) bool() bool {
+	return p.int() != 0
+}
+
+func (p *parser) bytes() []byte {
+	n := p.int()
+	if p.err != nil {
+		return nil
+	}
+	if n > 1<<40 {
+		p.err = fmt.Errorf("bad length %d", n)
+		return nil
+	}
+	// Grow the buffer as the data arrives, so that a corrupt
+	// length does not make us allocate a lot of memory.
+	var b []byte
+	for uint64(len(b)) < n {
+		chunk := n - uint64(len(b))
+		if chunk > 1<<20 {
+			chunk = 1 << 20
+		}
+		i := len(b)
+		b = append(b, make([]byte, chunk)...)
+		if _, err := io.ReadFull(p.r, b[i:]); err != nil {
+			p.err = err
+			return nil
+		}
+	}
+	return b
+}
+
+func (p *parser) string() string {
+	return string(p.bytes())
+}
+
+// fields reads a field list and returns the offsets of its pointers.
+// Interface fields count as two pointers.
+func (p *parser) fields() []uint64 {
+	var ptrs []uint64
+	for p.err == nil {
+		kind, off := p.int(), uint64(0)
+		if kind == fieldKindEol {
+			break
+		}
+		off = p.int()
+		switch kind {
+		case fieldKindPtr:
+			ptrs = append(ptrs, off)
+		case fieldKindIface, fieldKindEface:
+			ptrs = append(ptrs, off, off+p.ptrSize)
+		default:
+			p.err = fmt.Errorf("unknown field kind %d", kind)
+		}
+	}
+	return ptrs
+}
+
+// Word returns the pointer-sized word at offset off of b.
+func (d *Dump) Word(b []byte, off uint64) uint64 {
+	if d.Params.PtrSize == 4 {
+		if d.Params.BigEndian {
+			return uint64(binary.BigEndian.Uint32(b[off:]))
+		}
+		return uint64(binary.LittleEndian.Uint32(b[off:]))
+	}
+	if d.Params.BigEndian {
+		return binary.BigEndian.Uint64(b[off:])
+	}
+	return binary.LittleEndian.Uint64(b[off:])
+}
+
+// FindObject returns the object that contains addr, or nil.
+func (d *Dump) FindObject(addr uint64) *Object {
+	i := sort.Search(len(d.Objects), func(i int) bool {
+		return d.Objects[i].Addr > addr
+	})
+	if i == 0 {
+		return nil
+	}
+	o := d.Objects[i-1]
+	if addr >= o.Addr+o.Size() {
+		return nil
+	}
+	return o
+}
+
+// Roots returns the roots of the heap: the pointers in the data and
+// BSS segments and in stack frames, the finalizer closures, the
+// objects with queued finalizers, and the other roots.
+//
+// The runtime also treats the objects that an object with a
+// finalizer points to as reachable, so that the finalizer can use
+// them; these pointers are returned as finalizer roots too.
+func (d *Dump) Roots() []*Root {
+	var roots []*Root
+	for _, s := range d.Segments {
+		kind := RootData
+		if s.Name == "bss" {
+			kind = RootBSS
+		}
+		for _, off := range s.Ptrs {
+			if off+uint64(d.Params.PtrSize) > uint64(len(s.Data)) {
+				continue
+			}
+			roots = append(roots, &Root{Kind: kind, Addr: s.Addr + off, To: d.Word(s.Data, off)})
+		}
+	}
+	for _, g := range d.Goroutines {
+		for _, f := range g.Frames {
+			for _, off := range f.Ptrs {
+				if off+uint64(d.Params.PtrSize) > uint64(len(f.Data)) {
+					continue
+				}
+				roots = append(roots, &Root{Kind: RootStack, Addr: f.SP + off, To: d.Word(f.Data, off), G: g, Frame: f})
+			}
+		}
+	}
+	for _, f := range d.Finalizers {
+		roots = append(roots, &Root{Kind: RootFinalizer, To: f.Fn})
+		if f.Queued {
+			roots = append(roots, &Root{Kind: RootFinalizer, To: f.Obj})
+			continue
+		}
+		if o := d.FindObject(f.Obj); o != nil {
+			for _, off := range o.Ptrs {
+				if off+uint64(d.Params.PtrSize) <= o.Size() {
+					roots = append(roots, &Root{Kind: RootFinalizer, Addr: o.Addr + off, To: d.Word(o.Data, off)})
+				}
+			}
+		}
+	}
+	for _, r := range d.OtherRoots {
+		roots = append(roots, &Root{Kind: RootOther, To: r.To, Desc: r.Desc})
+	}
+	return roots
+}
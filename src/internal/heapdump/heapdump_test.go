@@ -0,0 +1,142 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump_test
+
+import (
+	. "internal/heapdump"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+type node struct {
+	next *node
+	data []byte
+	meta *meta
+}
+
+// meta is only found through the type of node.
+type meta struct {
+	tags [2]string
+}
+
+// label is only found through the signature of a method of node.
+type label struct {
+	text string
+}
+
+func (n *node) Label() label { return label{} }
+
+type labeler interface {
+	Label() label
+}
+
+var list *node
+
+func TestRead(t *testing.T) {
+	if runtime.GOOS == "js" || runtime.GOOS == "nacl" {
+		t.Skipf("WriteHeapDump is not available on %s", runtime.GOOS)
+	}
+	for i := 0; i < 10; i++ {
+		list = &node{next: list, data: make([]byte, 100)}
+	}
+	var l labeler = list
+	l.Label()
+	runtime.SetFinalizer(list, func(*node) {})
+	defer runtime.SetFinalizer(list, nil)
+
+	f, err := ioutil.TempFile("", "heapdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	debug.WriteHeapDump(f.Fd())
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Version != 14 {
+		t.Errorf("got version %d, want 14", d.Version)
+	}
+	if d.Params.GOARCH != runtime.GOARCH {
+		t.Errorf("got GOARCH %q, want %q", d.Params.GOARCH, runtime.GOARCH)
+	}
+
+	var nodeType *Type
+	for _, typ := range d.Types {
+		if typ.Name == "internal/heapdump_test.node" {
+			nodeType = typ
+		}
+	}
+	if nodeType == nil {
+		t.Fatalf("type node not found in the dump")
+	}
+	if nodeType.Kind != KindStruct || len(nodeType.Fields) != 3 || nodeType.Fields[1].Offset != uint64(d.Params.PtrSize) {
+		t.Errorf("bad layout of type node: %+v", nodeType)
+	}
+	if next := d.Types[nodeType.Fields[0].Type]; next == nil || next.Kind != KindPtr || next.Elem != nodeType.Addr {
+		t.Errorf("field next of type node has type %+v, want *node", next)
+	}
+	if mp := d.Types[nodeType.Fields[2].Type]; mp == nil || d.Types[mp.Elem] == nil || d.Types[mp.Elem].Name != "internal/heapdump_test.meta" {
+		t.Errorf("field meta of type node has type %+v, want *meta", mp)
+	}
+
+	// The types that types refer to are dumped, too,
+	// including the types in method signatures.
+	var labelFound bool
+	for _, typ := range d.Types {
+		if typ.Name == "internal/heapdump_test.label" {
+			labelFound = true
+		}
+		if typ.Elem != 0 && d.Types[typ.Elem] == nil {
+			t.Errorf("element type %#x of %s not in the dump", typ.Elem, typ.Name)
+		}
+		for _, f := range typ.Fields {
+			if d.Types[f.Type] == nil {
+				t.Errorf("field type %#x of %s not in the dump", f.Type, typ.Name)
+			}
+		}
+	}
+	if !labelFound {
+		t.Errorf("type label, used only in a method signature, not in the dump")
+	}
+
+	var fin *Finalizer
+	for _, f := range d.Finalizers {
+		if pt := d.Types[f.ObjType]; pt != nil && pt.Elem == nodeType.Addr {
+			fin = f
+		}
+	}
+	if fin == nil {
+		t.Fatalf("finalizer of list not found in the dump")
+	}
+	o := d.FindObject(fin.Obj)
+	if o == nil || o.Addr != fin.Obj {
+		t.Fatalf("object %#x of the finalizer not found in the dump", fin.Obj)
+	}
+	if len(o.Ptrs) != 3 || o.Ptrs[0] != 0 {
+		t.Errorf("object %#x has pointers at %v, want 3 pointers at 0, %d and %d", o.Addr, o.Ptrs, d.Params.PtrSize, 4*d.Params.PtrSize)
+	}
+	if next := d.FindObject(d.Word(o.Data, 0)); next == nil {
+		t.Errorf("next node %#x of %#x not found in the dump", d.Word(o.Data, 0), o.Addr)
+	}
+
+	var rooted bool
+	for _, r := range d.Roots() {
+		if r.To == fin.Obj && (r.Kind == RootData || r.Kind == RootBSS) {
+			rooted = true
+		}
+	}
+	if !rooted {
+		t.Errorf("no global root points to list %#x", fin.Obj)
+	}
+}
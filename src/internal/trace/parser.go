@@ -14,6 +14,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	_ "unsafe"
@@ -100,68 +101,129 @@ func Parse(r io.Reader, bin string) (ParseResult, error) {
 // parse parses, post-processes and verifies the trace. It returns the
 // trace version and the list of events.
 func parse(r io.Reader, bin string) (int, ParseResult, error) {
-	ver, rawEvents, strings, err := readTrace(r)
+	rd, err := NewReader(r)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
-	events, stacks, err := parseEvents(ver, rawEvents, strings)
-	if err != nil {
-		return 0, ParseResult{}, err
-	}
-	events = removeFutile(events)
-	err = postProcessTrace(ver, events)
-	if err != nil {
-		return 0, ParseResult{}, err
-	}
-	// Attach stack traces.
-	for _, ev := range events {
-		if ev.StkID != 0 {
-			ev.Stk = stacks[ev.StkID]
+	res := ParseResult{Stacks: make(map[uint64][]*Frame)}
+	rd.stacks = res.Stacks
+	for {
+		ev, err := rd.ReadEvent()
+		if err == io.EOF {
+			break
 		}
+		if err != nil {
+			return 0, ParseResult{}, err
+		}
+		res.Events = append(res.Events, ev)
 	}
-	if ver < 1007 && bin != "" {
-		if err := symbolize(events, bin); err != nil {
+	if rd.ver < 1007 && bin != "" {
+		if err := symbolize(res.Events, bin); err != nil {
 			return 0, ParseResult{}, err
 		}
 	}
-	return ver, ParseResult{Events: events, Stacks: stacks}, nil
+	return rd.ver, res, nil
+}
+
+// A generation is a self-contained part of a trace: the data written
+// between starting and stopping the tracer. A trace usually consists
+// of a single generation, but the data written by a flight recorder
+// (see runtime/trace.FlightRecorder) is a sequence of generations,
+// each beginning with its own trace header.
+//
+// Each generation has its own string and stack tables, and its events
+// are ordered independently of other generations, so a trace can be
+// read one generation at a time (see Reader).
+type generation struct {
+	events  []rawEvent
+	strings map[uint64]string
 }
 
 // rawEvent is a helper type used during parsing.
 type rawEvent struct {
-	off   int
-	typ   byte
-	args  []uint64
-	sargs []string
+	off    int
+	typ    byte
+	args   []uint64
+	sargs  []string
+	labels []uint64 // key and value string ids of EvGoLabels
 }
 
-// readTrace does wire-format parsing and verification.
+// A rawReader does wire-format parsing and verification of a trace,
+// one generation at a time.
 // It does not care about specific event types and argument meaning.
-func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]string, err error) {
-	// Read and validate trace header.
+type rawReader struct {
+	r       *bufio.Reader
+	ver     int
+	off     int  // offset of the next byte of r in the trace
+	started bool // the first generation has been read
+}
+
+// newRawReader reads and validates the trace header of r.
+func newRawReader(r io.Reader) (*rawReader, error) {
+	rr := &rawReader{r: bufio.NewReader(r)}
 	var buf [16]byte
-	off, err := io.ReadFull(r, buf[:])
+	off, err := io.ReadFull(rr.r, buf[:])
 	if err != nil {
-		err = fmt.Errorf("failed to read header: read %v, err %v", off, err)
-		return
+		return nil, fmt.Errorf("failed to read header: read %v, err %v", off, err)
 	}
-	ver, err = parseHeader(buf[:])
+	rr.off = off
+	rr.ver, err = parseHeader(buf[:])
 	if err != nil {
-		return
+		return nil, err
 	}
-	switch ver {
-	case 1005, 1007, 1008, 1009, 1010, 1011:
+	switch rr.ver {
+	case 1005, 1007, 1008, 1009, 1010, 1011, 1014, 1015:
 		// Note: When adding a new version, add canned traces
 		// from the old version to the test suite using mkcanned.bash.
 		break
 	default:
-		err = fmt.Errorf("unsupported trace file version %v.%v (update Go toolchain) %v", ver/1000, ver%1000, ver)
-		return
+		return nil, fmt.Errorf("unsupported trace file version %v.%v (update Go toolchain) %v", rr.ver/1000, rr.ver%1000, rr.ver)
 	}
+	return rr, nil
+}
+
+// header reports whether the unread part of the trace begins
+// with a trace header, and if so returns its version.
+func (rr *rawReader) header() (ver int, ok bool) {
+	hdr, _ := rr.r.Peek(16)
+	if len(hdr) != 16 || hdr[0] != 'g' {
+		return 0, false
+	}
+	ver, err := parseHeader(hdr)
+	return ver, err == nil
+}
+
+// next reads the next generation of the trace.
+// It returns io.EOF when there are no more generations.
+func (rr *rawReader) next() (gen *generation, err error) {
+	if rr.started {
+		if _, err := rr.r.Peek(1); err == io.EOF {
+			return nil, io.EOF
+		}
+		// The previous generation ended at a header.
+		ver, _ := rr.header()
+		if ver != rr.ver {
+			return nil, fmt.Errorf("trace generation at offset 0x%x has version %v, want %v", rr.off, ver, rr.ver)
+		}
+		rr.r.Discard(16)
+		rr.off += 16
+	}
+	rr.started = true
 
-	// Read events.
-	strings = make(map[uint64]string)
+	var r io.Reader = rr.r
+	ver, off := rr.ver, rr.off
+	defer func() { rr.off = off }()
+	var buf [16]byte
+	gen = &generation{strings: make(map[uint64]string)}
+	strings := gen.strings
 	for {
+		// A header in place of an event starts the next generation.
+		// It cannot be mistaken for an event: read as one,
+		// it is followed by an invalid event type.
+		if _, ok := rr.header(); ok {
+			return
+		}
+
 		// Read event type and number of arguments (1 byte).
 		off0 := off
 		var n int
@@ -264,8 +326,18 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 			var s string
 			s, off, err = readStr(r, off)
 			ev.sargs = append(ev.sargs, s)
+		case EvGoLabels: // EvGoLabels records are followed by the number of labels and their key and value string ids
+			var n, id uint64
+			n, off, err = readVal(r, off)
+			if err == nil && n > 1000 {
+				err = fmt.Errorf("event at offset 0x%x has too many labels: %v", off0, n)
+			}
+			for i := uint64(0); err == nil && i < 2*n; i++ {
+				id, off, err = readVal(r, off)
+				ev.labels = append(ev.labels, id)
+			}
 		}
-		events = append(events, ev)
+		gen.events = append(gen.events, ev)
 	}
 	return
 }
@@ -313,14 +385,19 @@ func parseHeader(buf []byte) (int, error) {
 
 // Parse events transforms raw events into events.
 // It does analyze and verify per-event-type arguments.
-func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, err error) {
-	var ticksPerSec, lastSeq, lastTs int64
+// Event timestamps are left in cpu ticks; ticksPerSec is their frequency.
+func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, ticksPerSec int64, err error) {
+	var lastSeq, lastTs int64
 	var lastG uint64
 	var lastP int
 	timerGoids := make(map[uint64]bool)
 	lastGs := make(map[int]uint64) // last goroutine running on P
 	stacks = make(map[uint64][]*Frame)
 	batches := make(map[int][]*Event) // events by P
+	var cpuSamples []*Event
+	if ver >= 1014 {
+		rawEvents = sortBatches(rawEvents)
+	}
 	for _, raw := range rawEvents {
 		desc := EventDescriptions[raw.typ]
 		if desc.Name == "" {
@@ -448,6 +525,22 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 			case EvUserLog:
 				// e.Args 0: taskID, 1:keyID, 2: stackID
 				e.SArgs = []string{strings[e.Args[1]], raw.sargs[0]}
+			case EvGoLabels:
+				// e.Args 0: goroutine id
+				e.G = e.Args[0]
+				e.SArgs = make([]string, len(raw.labels))
+				for i, id := range raw.labels {
+					e.SArgs[i] = strings[id]
+				}
+			case EvCPUSample:
+				// e.Args 0: real timestamp, 1: P id, 2: goroutine id
+				// CPU samples are not ordered with the other events
+				// of the batch; they are merged in by their real timestamp.
+				e.Ts = int64(e.Args[0])
+				e.P = int(e.Args[1])
+				e.G = e.Args[2]
+				cpuSamples = append(cpuSamples, e)
+				continue
 			}
 			batches[lastP] = append(batches[lastP], e)
 		}
@@ -478,13 +571,12 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 	if err != nil {
 		return
 	}
+	if len(cpuSamples) > 0 {
+		events = append(events, cpuSamples...)
+		sort.Stable(eventList(events))
+	}
 
-	// Translate cpu ticks to real time.
-	minTs := events[0].Ts
-	// Use floating point to avoid integer overflows.
-	freq := 1e9 / float64(ticksPerSec)
 	for _, ev := range events {
-		ev.Ts = int64(float64(ev.Ts-minTs) * freq)
 		// Move timers and syscalls to separate fake Ps.
 		if timerGoids[ev.G] && ev.Type == EvGoUnblock {
 			ev.P = TimerP
@@ -556,39 +648,129 @@ func removeFutile(events []*Event) []*Event {
 // time stamps that do not respect actual event ordering.
 var ErrTimeOrder = fmt.Errorf("time stamps out of order")
 
+type goroutineState struct {
+	state        gStatus
+	ev           *Event
+	evStart      *Event
+	evCreate     *Event
+	evMarkAssist *Event
+}
+
+type procState struct {
+	running bool
+	g       uint64
+	evSTW   *Event
+	evSweep *Event
+}
+
+// A postProcessor does inter-event verification and information
+// restoration for the generations of a trace, in order. The state of
+// goroutines, Ps, tasks and regions carries over from one generation
+// to the next, so links between events cross generations.
+type postProcessor struct {
+	ver           int
+	started       bool // a generation has been processed
+	gs            map[uint64]goroutineState
+	ps            map[int]procState
+	tasks         map[uint64]*Event   // task id to task creation events
+	activeRegions map[uint64][]*Event // goroutine id to stack of regions
+	evGC, evSTW   *Event
+}
+
+func newPostProcessor(ver int) *postProcessor {
+	pp := &postProcessor{
+		ver:           ver,
+		gs:            make(map[uint64]goroutineState),
+		ps:            make(map[int]procState),
+		tasks:         make(map[uint64]*Event),
+		activeRegions: make(map[uint64][]*Event),
+	}
+	pp.gs[0] = goroutineState{state: gRunning}
+	return pp
+}
+
+// dropRestated returns the events of a generation other than the
+// first without the events that restate the state of goroutines and
+// Ps at its start: the EvGoCreate of existing goroutines, with their
+// EvGoWaiting or EvGoInSyscall, and the EvProcStart of the P that
+// started the generation, which was running already. It updates the
+// state of the goroutines to the restated one. The goroutine that
+// starts a generation may allocate before its EvProcStart, so the
+// first EvProcStart of a running P is dropped unless the P stops
+// before it.
+func (pp *postProcessor) dropRestated(events []*Event) []*Event {
+	restated := make(map[uint64]bool) // goroutines whose EvGoCreate was dropped
+	settled := make(map[int]bool)     // Ps that stopped, or whose EvProcStart was dropped
+	kept := events[:0]
+	for _, ev := range events {
+		switch ev.Type {
+		case EvGoCreate:
+			if g, ok := pp.gs[ev.Args[0]]; ok && g.state != gDead {
+				// A goroutine restated without EvGoWaiting or
+				// EvGoInSyscall is runnable. It may have left a
+				// syscall without an event, while the world was
+				// stopped to start the generation.
+				if g.state == gWaiting {
+					g.state = gRunnable
+					pp.gs[ev.Args[0]] = g
+				}
+				restated[ev.Args[0]] = true
+				continue
+			}
+		case EvGoWaiting, EvGoInSyscall:
+			if restated[ev.G] {
+				g := pp.gs[ev.G]
+				g.state = gWaiting
+				pp.gs[ev.G] = g
+				continue
+			}
+		case EvProcStart:
+			if !settled[ev.P] && pp.ps[ev.P].running {
+				settled[ev.P] = true
+				continue
+			}
+		case EvProcStop:
+			settled[ev.P] = true
+		}
+		kept = append(kept, ev)
+	}
+	return kept
+}
+
+// process verifies the events of the next generation of the trace and
+// restores information in them. It returns the events, without those
+// that only restate the state at the start of the generation.
+func (pp *postProcessor) process(events []*Event) ([]*Event, error) {
+	if pp.started {
+		events = pp.dropRestated(events)
+	}
+	pp.started = true
+	if err := postProcessTrace(pp, events); err != nil {
+		return nil, err
+	}
+	// Forget the goroutines that ended.
+	for id, g := range pp.gs {
+		if id != 0 && g.state == gDead {
+			delete(pp.gs, id)
+		}
+	}
+	return events, nil
+}
+
 // postProcessTrace does inter-event verification and information restoration.
 // The resulting trace is guaranteed to be consistent
 // (for example, a P does not run two Gs at the same time, or a G is indeed
 // blocked before an unblock event).
-func postProcessTrace(ver int, events []*Event) error {
-	const (
-		gDead = iota
-		gRunnable
-		gRunning
-		gWaiting
-	)
-	type gdesc struct {
-		state        int
-		ev           *Event
-		evStart      *Event
-		evCreate     *Event
-		evMarkAssist *Event
-	}
-	type pdesc struct {
-		running bool
-		g       uint64
-		evSTW   *Event
-		evSweep *Event
-	}
+func postProcessTrace(pp *postProcessor, events []*Event) error {
+	ver := pp.ver
+	gs := pp.gs
+	ps := pp.ps
+	tasks := pp.tasks
+	activeRegions := pp.activeRegions
+	evGC, evSTW := pp.evGC, pp.evSTW
+	defer func() { pp.evGC, pp.evSTW = evGC, evSTW }()
 
-	gs := make(map[uint64]gdesc)
-	ps := make(map[int]pdesc)
-	tasks := make(map[uint64]*Event)           // task id to task creation events
-	activeRegions := make(map[uint64][]*Event) // goroutine id to stack of regions
-	gs[0] = gdesc{state: gRunning}
-	var evGC, evSTW *Event
-
-	checkRunning := func(p pdesc, g gdesc, ev *Event, allowG0 bool) error {
+	checkRunning := func(p procState, g goroutineState, ev *Event, allowG0 bool) error {
 		name := EventDescriptions[ev.Type].Name
 		if g.state != gRunning {
 			return fmt.Errorf("g %v is not running while %v (offset %v, time %v)", ev.G, name, ev.Off, ev.Ts)
@@ -603,6 +785,10 @@ func postProcessTrace(ver int, events []*Event) error {
 	}
 
 	for _, ev := range events {
+		if ev.Type == EvCPUSample {
+			// CPU samples do not change the state of goroutines or Ps.
+			continue
+		}
 		g := gs[ev.G]
 		p := ps[ev.P]
 
@@ -696,7 +882,7 @@ func postProcessTrace(ver int, events []*Event) error {
 			if _, ok := gs[ev.Args[0]]; ok {
 				return fmt.Errorf("g %v already exists (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
 			}
-			gs[ev.Args[0]] = gdesc{state: gRunnable, ev: ev, evCreate: ev}
+			gs[ev.Args[0]] = goroutineState{state: gRunnable, ev: ev, evCreate: ev}
 		case EvGoStart, EvGoStartLabel:
 			if g.state != gRunnable {
 				return fmt.Errorf("g %v is not runnable before start (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
@@ -964,6 +1150,40 @@ func (ev *Event) String() string {
 	return w.String()
 }
 
+// sortBatches returns the events of rawEvents with their batches
+// in the order of their sequence numbers.
+//
+// Since 1.14 the runtime writes the events of a P to the buffer of the
+// M running it, so the batches of a P are not in the order of their
+// events in the trace, but their sequence numbers are.
+func sortBatches(rawEvents []rawEvent) []rawEvent {
+	type batch struct {
+		seq    uint64
+		events []rawEvent
+	}
+	var batches []batch
+	for i := 0; i < len(rawEvents); {
+		j := i + 1
+		for j < len(rawEvents) && rawEvents[j].typ != EvBatch {
+			j++
+		}
+		var seq uint64
+		if raw := rawEvents[i]; raw.typ == EvBatch && len(raw.args) == 3 {
+			seq = raw.args[2]
+		}
+		batches = append(batches, batch{seq, rawEvents[i:j]})
+		i = j
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		return batches[i].seq < batches[j].seq
+	})
+	sorted := make([]rawEvent, 0, len(rawEvents))
+	for _, b := range batches {
+		sorted = append(sorted, b.events...)
+	}
+	return sorted
+}
+
 // argNum returns total number of args for the event accounting for timestamps,
 // sequence numbers and differences between trace format versions.
 func argNum(raw rawEvent, ver int) int {
@@ -980,6 +1200,9 @@ func argNum(raw rawEvent, ver int) int {
 		if ver < 1007 {
 			narg++ // there was an unused arg before 1.7
 		}
+		if raw.typ == EvBatch && ver >= 1014 {
+			narg++ // 1.14 added the batch sequence number
+		}
 		return narg
 	}
 	narg++ // timestamp
@@ -1010,7 +1233,7 @@ var BreakTimestampsForTesting bool
 // Verbatim copy from src/runtime/trace.go with the "trace" prefix removed.
 const (
 	EvNone              = 0  // unused
-	EvBatch             = 1  // start of per-P batch of events [pid, timestamp]
+	EvBatch             = 1  // start of per-P batch of events [pid, timestamp, batch sequence number (since 1.14)]
 	EvFrequency         = 2  // contains tracer timer frequency [frequency (ticks per second)]
 	EvStack             = 3  // stack [stack id, number of PCs, array of {PC, func string ID, file string ID, line}]
 	EvGomaxprocs        = 4  // current value of GOMAXPROCS [timestamp, GOMAXPROCS, stack id]
@@ -1058,7 +1281,9 @@ const (
 	EvUserTaskEnd       = 46 // end of task [timestamp, internal task id, stack]
 	EvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	EvUserLog           = 48 // trace.Log [timestamp, internal id, key string id, stack, value string]
-	EvCount             = 49
+	EvGoLabels          = 49 // goroutine's profiler labels [timestamp, goroutine id, labels]
+	EvCPUSample         = 50 // CPU profiling sample [timestamp, real timestamp, real P id (-1 when absent), goroutine id, stack id]
+	EvCount             = 51
 )
 
 var EventDescriptions = [EvCount]struct {
@@ -1117,4 +1342,6 @@ var EventDescriptions = [EvCount]struct {
 	EvUserTaskEnd:       {"UserTaskEnd", 1011, true, []string{"taskid"}, nil},
 	EvUserRegion:        {"UserRegion", 1011, true, []string{"taskid", "mode", "typeid"}, []string{"name"}},
 	EvUserLog:           {"UserLog", 1011, true, []string{"id", "keyid"}, []string{"category", "message"}},
+	EvGoLabels:          {"GoLabels", 1015, false, []string{"g"}, nil}, // SArgs are key, value pairs
+	EvCPUSample:         {"CPUSample", 1015, true, []string{"ts", "p", "g"}, nil},
 }
@@ -6,6 +6,7 @@ package trace
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -109,3 +110,217 @@ func TestTimestampOverflow(t *testing.T) {
 		t.Fatalf("failed to parse: %v", err)
 	}
 }
+
+func TestParseLabelsAndCPUSamples(t *testing.T) {
+	labelsTrace := func(header string) *Writer {
+		w := new(Writer)
+		w.Write([]byte(header))
+		w.Emit(EvBatch, 0, 0, 1)
+		w.Emit(EvFrequency, 1e9)
+		w.Emit(EvString, 1, 3)
+		w.Write([]byte("key"))
+		w.Emit(EvString, 2, 5)
+		w.Write([]byte("value"))
+		w.Emit(EvGoCreate, 1, 2, 0, 0) // ts=1
+		w.Emit(EvGoLabels, 2, 2)       // ts=3
+		w.Write(appendVarint(appendVarint(appendVarint(nil, 1), 1), 2))
+		w.Emit(EvCPUSample, 1, 2, 0, 2, 0) // ts=4, real ts=2
+		return w
+	}
+	// The events were added in version 1.15.
+	if _, err := Parse(labelsTrace("go 1.14 trace\x00\x00\x00"), ""); err == nil {
+		t.Errorf("parsed labels and CPU samples in a 1.14 trace")
+	}
+	res, err := Parse(labelsTrace("go 1.15 trace\x00\x00\x00"), "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	var types []byte
+	for _, ev := range res.Events {
+		types = append(types, ev.Type)
+		switch ev.Type {
+		case EvGoLabels:
+			if ev.G != 2 || len(ev.SArgs) != 2 || ev.SArgs[0] != "key" || ev.SArgs[1] != "value" {
+				t.Errorf("got labels event %v with labels %q, want g=2 labels [key value]", ev, ev.SArgs)
+			}
+		case EvCPUSample:
+			if ev.G != 2 || ev.P != 0 || ev.Ts != 1 {
+				t.Errorf("got CPU sample %v, want g=2 p=0 at 1ns", ev)
+			}
+		}
+	}
+	// The CPU sample is ordered by the time it was taken.
+	want := []byte{EvGoCreate, EvCPUSample, EvGoLabels}
+	if !bytes.Equal(types, want) {
+		t.Errorf("got event types %v, want %v", types, want)
+	}
+}
+
+func TestParseBatchOrder(t *testing.T) {
+	// Test that the batches of a P are ordered by their sequence
+	// numbers rather than by their position in the trace.
+	w := new(Writer)
+	w.Write([]byte("go 1.14 trace\x00\x00\x00"))
+	w.Emit(EvBatch, 0, 2, 2)
+	w.Emit(EvGoStart, 1, 1, 1) // ts=3
+	w.Emit(EvBatch, 0, 0, 1)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGoCreate, 1, 1, 0, 0) // ts=1
+	res, err := Parse(w, "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	var types []byte
+	for _, ev := range res.Events {
+		types = append(types, ev.Type)
+	}
+	want := []byte{EvGoCreate, EvGoStart}
+	if !bytes.Equal(types, want) {
+		t.Errorf("got event types %v, want %v", types, want)
+	}
+}
+
+func TestParseGenerations(t *testing.T) {
+	// Test that a sequence of traces, as written by a flight
+	// recorder, is parsed as a single trace, in which goroutines
+	// are created once.
+	var buf bytes.Buffer
+	for gen := uint64(0); gen < 3; gen++ {
+		w := NewWriter()
+		w.Emit(EvBatch, 0, gen*100)
+		w.Emit(EvFrequency, 1e9)
+		w.Emit(EvGoCreate, 1, 1, 0, 0)
+		w.Emit(EvGoCreate, 1, 2, 0, 0)
+		buf.Write(w.Bytes())
+	}
+	res, err := Parse(&buf, "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	creates := 0
+	var last int64
+	for _, ev := range res.Events {
+		if ev.Type == EvGoCreate {
+			creates++
+		}
+		if ev.Ts < last {
+			t.Errorf("event %v out of order", ev)
+		}
+		last = ev.Ts
+	}
+	if creates != 2 {
+		t.Errorf("got %d EvGoCreate events, want 2", creates)
+	}
+
+	// All generations must have the same version.
+	w := NewWriter()
+	w.Emit(EvBatch, 0, 0)
+	w.Emit(EvFrequency, 1e9)
+	w.Write([]byte("go 1.11 trace\x00\x00\x00"))
+	w.Emit(EvBatch, 0, 0)
+	if _, err := Parse(w, ""); err == nil {
+		t.Errorf("parsed generations with different versions")
+	}
+}
+
+// readerTestTrace returns a trace of two generations, which
+// TestReader and TestReaderLookahead read.
+func readerTestTrace() *bytes.Buffer {
+	var buf bytes.Buffer
+	for gen := uint64(0); gen < 2; gen++ {
+		w := new(Writer)
+		w.Write([]byte("go 1.14 trace\x00\x00\x00"))
+		w.Emit(EvBatch, 0, gen*100, 1)
+		w.Emit(EvFrequency, 1e9)
+		w.Emit(EvString, 1, 6)
+		w.Write([]byte("main.f"))
+		w.Emit(EvStack, 1, 1, 0x1000+gen, 1, 1, 10)
+		w.Emit(EvGoCreate, 1, 1, 0, 0) // restated in the second generation
+		if gen > 0 {
+			w.Emit(EvHeapAlloc, 1, 1<<20)
+		}
+		w.Emit(EvProcStart, 1, 0) // restated in the second generation
+		w.Emit(EvGoStart, 1, 1, 1)
+		if gen == 0 {
+			w.Emit(EvGoSched, 1, 1)
+		} else {
+			w.Emit(EvGoCreate, 1, 2, 1, 1)
+			w.Emit(EvGoBlock, 1, 1)
+		}
+		buf.Write(w.Bytes())
+	}
+	return &buf
+}
+
+func TestReader(t *testing.T) {
+	// Test that the generations of a trace are read as a single trace:
+	// the events that restate the state at the start of a generation
+	// are dropped, even if the P allocates before it is restated,
+	// links cross generations, and stack IDs are unique.
+	r, err := NewReader(readerTestTrace())
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var events []*Event
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent: %v", err)
+		}
+		events = append(events, ev)
+	}
+	var types []byte
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	want := []byte{EvGoCreate, EvProcStart, EvGoStart, EvGoSched, EvHeapAlloc, EvGoStart, EvGoCreate, EvGoBlock}
+	if !bytes.Equal(types, want) {
+		t.Fatalf("got event types %v, want %v", types, want)
+	}
+	if events[3].Link != events[5] {
+		t.Errorf("GoSched is linked to %v, want the GoStart of the next generation", events[3].Link)
+	}
+	if events[5].Link != events[7] {
+		t.Errorf("GoStart is linked to %v, want GoBlock", events[5].Link)
+	}
+	if events[3].StkID != 1 || events[7].StkID != 2 || events[6].Args[1] != 2 {
+		t.Errorf("got stack IDs %v, %v and %v, want 1, 2 and 2", events[3].StkID, events[7].StkID, events[6].Args[1])
+	}
+	if len(events[7].Stk) != 1 || events[7].Stk[0].PC != 0x1001 {
+		t.Errorf("got stack %v, want the stack of the second generation", events[7].Stk)
+	}
+	if stk := r.Stack(2); len(stk) != 1 || stk[0].PC != 0x1001 {
+		t.Errorf("got stack %v for ID 2, want the stack of the second generation", stk)
+	}
+}
+
+func TestReaderLookahead(t *testing.T) {
+	// Test that with Lookahead, the links to the next generation
+	// are set when an event is returned.
+	r, err := NewReader(readerTestTrace())
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	r.Lookahead = true
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			t.Fatalf("no GoSched event")
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent: %v", err)
+		}
+		if ev.Type == EvGoSched {
+			if ev.Link == nil || ev.Link.Type != EvGoStart {
+				t.Errorf("GoSched is linked to %v, want the GoStart of the next generation", ev.Link)
+			}
+			if stk := r.Stack(ev.StkID); len(stk) != 1 || stk[0].PC != 0x1000 {
+				t.Errorf("got stack %v for ID %v, want the stack of the first generation", stk, ev.StkID)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import "io"
+
+// A Reader reads the events of a trace incrementally.
+//
+// Unlike Parse, which holds the whole trace in memory, a Reader holds
+// only the generation of the trace it is reading (see
+// runtime/trace.FlightRecorder) and the state of the goroutines and
+// Ps, so the memory it needs is bounded by the size of the largest
+// generation rather than the whole trace.
+// Events are returned in the same order as by Parse, with the same
+// timestamps, and stack IDs are unique in the whole trace.
+//
+// After the first generation, the events that begin a generation by
+// restating the state of goroutines and Ps are dropped, so the
+// generations read like a single trace, and links between events,
+// such as Event.Link, may cross generations. The Link of an event
+// returned by ReadEvent may therefore be set only when a later
+// generation is read, unless Lookahead is set.
+type Reader struct {
+	// If Lookahead is set, the Reader returns the events of a
+	// generation only after reading the next one, so that the links
+	// from the events of a generation to the next one are set, at
+	// the cost of holding two generations in memory. It must be set
+	// before the first call to ReadEvent.
+	Lookahead bool
+
+	raw    *rawReader
+	ver    int
+	pp     *postProcessor
+	events []*Event             // events of the current generation not yet returned
+	stks   map[uint64][]*Frame  // stacks of the current generation
+	queue  []*decodedGeneration // generations read ahead of the current one
+	eof    bool                 // all generations are read
+	err    error                // sticky error
+
+	minTs    int64  // timestamp of the first event, in ticks
+	started  bool   // minTs is set
+	maxStkID uint64 // largest stack ID returned so far
+
+	// If stacks is non-nil, the stacks of all
+	// generations are added to it as they are read.
+	stacks map[uint64][]*Frame
+}
+
+// NewReader returns a Reader for the trace read from r.
+// It reads and validates the trace header.
+func NewReader(r io.Reader) (*Reader, error) {
+	raw, err := newRawReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{raw: raw, ver: raw.ver, pp: newPostProcessor(raw.ver)}, nil
+}
+
+// ReadEvent returns the next event of the trace.
+// At the end of the trace, ReadEvent returns nil, io.EOF.
+// Traces produced by go 1.6 or below are not symbolized.
+func (r *Reader) ReadEvent() (*Event, error) {
+	for r.err == nil && len(r.events) == 0 {
+		r.err = r.advance()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	ev := r.events[0]
+	r.events[0] = nil
+	r.events = r.events[1:]
+	return ev, nil
+}
+
+// Stack returns the stack with the given ID, such as the StkID of an
+// event or the stack argument of an EvGoCreate event, if it belongs to
+// the generation of the last event returned by ReadEvent.
+func (r *Reader) Stack(id uint64) []*Frame {
+	return r.stks[id]
+}
+
+// A decodedGeneration is a generation of the trace that has been
+// read, post-processed and verified.
+type decodedGeneration struct {
+	events []*Event
+	stacks map[uint64][]*Frame // by unique stack ID
+}
+
+// advance makes the next generation of the trace the current one,
+// reading ahead if r.Lookahead is set.
+func (r *Reader) advance() error {
+	want := 1
+	if r.Lookahead {
+		want = 2
+	}
+	for !r.eof && len(r.queue) < want {
+		gen, err := r.nextGeneration()
+		if err == io.EOF {
+			r.eof = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+		r.queue = append(r.queue, gen)
+	}
+	if len(r.queue) == 0 {
+		return io.EOF
+	}
+	gen := r.queue[0]
+	r.queue[0] = nil
+	r.queue = r.queue[1:]
+	r.events, r.stks = gen.events, gen.stacks
+	return nil
+}
+
+// nextGeneration reads, post-processes and verifies
+// the next generation of the trace.
+func (r *Reader) nextGeneration() (*decodedGeneration, error) {
+	raw, err := r.raw.next()
+	if err != nil {
+		return nil, err
+	}
+	events, stacks, ticksPerSec, err := parseEvents(r.ver, raw.events, raw.strings)
+	if err != nil {
+		return nil, err
+	}
+	// Translate cpu ticks to real time, relative to the start
+	// of the first generation. The ticks of all generations
+	// come from the same clock.
+	if !r.started {
+		r.minTs = events[0].Ts
+		r.started = true
+	}
+	// Use floating point to avoid integer overflows.
+	freq := 1e9 / float64(ticksPerSec)
+	for _, ev := range events {
+		ev.Ts = int64(float64(ev.Ts-r.minTs) * freq)
+	}
+	events = removeFutile(events)
+	events, err = r.pp.process(events)
+	if err != nil {
+		return nil, err
+	}
+	// Each generation numbers its stacks from 1.
+	// Renumber them to be unique in the whole trace.
+	base := r.maxStkID
+	gen := &decodedGeneration{events: events, stacks: make(map[uint64][]*Frame, len(stacks))}
+	for id, stk := range stacks {
+		if base+id > r.maxStkID {
+			r.maxStkID = base + id
+		}
+		gen.stacks[base+id] = stk
+		if r.stacks != nil {
+			r.stacks[base+id] = stk
+		}
+	}
+	// Attach stack traces.
+	for _, ev := range events {
+		if ev.StkID != 0 {
+			ev.Stk = stacks[ev.StkID]
+			ev.StkID += base
+		}
+		if ev.Type == EvGoCreate && ev.Args[1] != 0 {
+			ev.Args[1] += base
+		}
+	}
+	return gen, nil
+}
@@ -50,7 +50,8 @@ var cpuprof cpuProfile
 //
 // Most clients should use the runtime/pprof package or
 // the testing package's -test.cpuprofile flag instead of calling
-// SetCPUProfileRate directly.
+// SetCPUProfileRate directly. To profile at a rate other than the
+// default, use runtime/pprof.StartCPUProfileRate.
 func SetCPUProfileRate(hz int) {
 	// Clamp hz to something reasonable.
 	if hz < 0 {
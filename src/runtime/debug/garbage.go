@@ -153,7 +153,9 @@ func SetPanicOnFault(enabled bool) bool {
 // connected to a pipe or socket whose other end is in the same Go
 // process; instead, use a temporary file or network socket.
 //
-// The heap dump format is defined at https://golang.org/s/go15heapdump.
+// The heap dump can be analyzed with 'go tool heapview'.
+// Its format extends the one defined at https://golang.org/s/go15heapdump
+// with the layout of types.
 func WriteHeapDump(fd uintptr)
 
 // SetTraceback sets the amount of detail printed by the runtime in
@@ -112,6 +112,10 @@ const (
 	ITIMER_REAL    = C.ITIMER_REAL
 	ITIMER_PROF    = C.ITIMER_PROF
 	ITIMER_VIRTUAL = C.ITIMER_VIRTUAL
+
+	CLOCK_THREAD_CPUTIME_ID = C.CLOCK_THREAD_CPUTIME_ID
+
+	SIGEV_THREAD_ID = C.SIGEV_THREAD_ID
 )
 
 type Timespec C.struct_timespec
@@ -120,5 +124,6 @@ type Sigcontext C.struct_sigcontext
 type Ucontext C.struct_ucontext
 type Timeval C.struct_timeval
 type Itimerval C.struct_itimerval
+type Itimerspec C.struct_itimerspec
 type Siginfo C.struct_xsiginfo
 type Sigaction C.struct_xsigaction
@@ -107,6 +107,10 @@ const (
 	ITIMER_VIRTUAL = C.ITIMER_VIRTUAL
 	ITIMER_PROF    = C.ITIMER_PROF
 
+	CLOCK_THREAD_CPUTIME_ID = C.CLOCK_THREAD_CPUTIME_ID
+
+	SIGEV_THREAD_ID = C.SIGEV_THREAD_ID
+
 	EPOLLIN       = C.POLLIN
 	EPOLLOUT      = C.POLLOUT
 	EPOLLERR      = C.POLLERR
@@ -125,4 +129,5 @@ type Timeval C.struct_timeval
 type Sigaction C.struct_sigaction
 type Siginfo C.siginfo_t
 type Itimerval C.struct_itimerval
+type Itimerspec C.struct_itimerspec
 type EpollEvent C.struct_epoll_event
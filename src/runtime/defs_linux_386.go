@@ -3,6 +3,8 @@
 
 package runtime
 
+import "unsafe"
+
 const (
 	_EINTR  = 0x4
 	_EAGAIN = 0xb
@@ -78,6 +80,12 @@ const (
 	_ITIMER_VIRTUAL = 0x1
 	_ITIMER_PROF    = 0x2
 
+	_CLOCK_THREAD_CPUTIME_ID = 0x3
+
+	_SIGEV_THREAD_ID = 0x4
+
+	_sigev_max_size = 64
+
 	_O_RDONLY  = 0x0
 	_O_CLOEXEC = 0x80000
 
@@ -216,6 +224,26 @@ type itimerval struct {
 	it_value    timeval
 }
 
+type itimerspec struct {
+	it_interval timespec
+	it_value    timespec
+}
+
+type sigeventFields struct {
+	value  uintptr
+	signo  int32
+	notify int32
+	// below here is a union; sigev_notify_thread_id is the only field we use
+	sigev_notify_thread_id int32
+}
+
+type sigevent struct {
+	sigeventFields
+
+	// Pad struct to the max size in the kernel.
+	_ [_sigev_max_size - unsafe.Sizeof(sigeventFields{})]byte
+}
+
 type epollevent struct {
 	events uint32
 	data   [8]byte // to match amd64
@@ -1,5 +1,7 @@
 package runtime
 
+import "unsafe"
+
 // Constants
 const (
 	_EINTR  = 0x4
@@ -73,6 +75,12 @@ const (
 	_O_RDONLY       = 0
 	_O_CLOEXEC      = 0x80000
 
+	_CLOCK_THREAD_CPUTIME_ID = 0x3
+
+	_SIGEV_THREAD_ID = 0x4
+
+	_sigev_max_size = 64
+
 	_EPOLLIN       = 0x1
 	_EPOLLOUT      = 0x4
 	_EPOLLERR      = 0x8
@@ -153,6 +161,26 @@ type itimerval struct {
 	it_value    timeval
 }
 
+type itimerspec struct {
+	it_interval timespec
+	it_value    timespec
+}
+
+type sigeventFields struct {
+	value  uintptr
+	signo  int32
+	notify int32
+	// below here is a union; sigev_notify_thread_id is the only field we use
+	sigev_notify_thread_id int32
+}
+
+type sigevent struct {
+	sigeventFields
+
+	// Pad struct to the max size in the kernel.
+	_ [_sigev_max_size - unsafe.Sizeof(sigeventFields{})]byte
+}
+
 type siginfo struct {
 	si_signo int32
 	si_errno int32
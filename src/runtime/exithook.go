@@ -0,0 +1,47 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import _ "unsafe" // for go:linkname
+
+// Exit hooks are functions run when the program exits, either by
+// returning from main.main or by calling os.Exit. They are used by
+// internal/coverage to write coverage counters for programs built
+// with 'go build -cover'.
+//
+// Hooks are not run when the program exits because of a panic or a
+// fatal error.
+
+var exitHooks struct {
+	lock    mutex
+	hooks   []func()
+	running bool
+}
+
+// coverage_addExitHook registers f to be run when the program exits.
+//go:linkname coverage_addExitHook internal/coverage.runtime_addExitHook
+func coverage_addExitHook(f func()) {
+	lock(&exitHooks.lock)
+	exitHooks.hooks = append(exitHooks.hooks, f)
+	unlock(&exitHooks.lock)
+}
+
+// runExitHooks runs the registered exit hooks, most recently
+// registered first. If a hook itself exits the program, the
+// remaining hooks are not run again.
+func runExitHooks() {
+	lock(&exitHooks.lock)
+	if exitHooks.running {
+		unlock(&exitHooks.lock)
+		return
+	}
+	exitHooks.running = true
+	hooks := exitHooks.hooks
+	unlock(&exitHooks.lock)
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
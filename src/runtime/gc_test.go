@@ -28,6 +28,20 @@ func TestGcSys(t *testing.T) {
 	}
 }
 
+func TestGcStopTheWorld(t *testing.T) {
+	// With GOMAXPROCS=1, a GC cycle run with the world stopped
+	// deadlocked if the goroutine that started it held worldsema
+	// while it waited for the mark phase.
+	if os.Getenv("GOGC") == "off" {
+		t.Skip("skipping test; GOGC=off in environment")
+	}
+	got := runTestProg(t, "testprog", "GCSys", "GODEBUG=gcstoptheworld=1")
+	want := "OK\n"
+	if got != want {
+		t.Fatalf("expected %q, but got %q", want, got)
+	}
+}
+
 func TestGcDeepNesting(t *testing.T) {
 	type T [2][2][2][2][2][2][2][2][2][2]*int
 	a := new(T)
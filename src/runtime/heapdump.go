@@ -6,8 +6,10 @@
 // objects in the heap plus additional info (roots, threads,
 // finalizers, etc.) to a file.
 
-// The format of the dumped file is described at
-// https://golang.org/s/go15heapdump.
+// The format of the dumped file is described in the documentation
+// of package internal/heapdump, which reads it. It extends the
+// format described at https://golang.org/s/go15heapdump with
+// the layout of types.
 
 package runtime
 
@@ -92,22 +94,86 @@ func flush() {
 	nbuf = 0
 }
 
-// Cache of types that have been serialized already.
-// We use a type's hash field to pick a bucket.
-// Inside a bucket, we keep a list of types that
-// have been serialized so far, most recently used first.
-// Note: when a bucket overflows we may end up
-// serializing a type more than once. That's ok.
-const (
-	typeCacheBuckets = 256
-	typeCacheAssoc   = 4
+// Types that have been serialized already, and types still to be
+// serialized. dumpedTypes is a set of types, an open addressing hash
+// table indexed by the types' hash fields. Both are allocated with
+// sysAlloc, as the dump must not allocate from the heap it is dumping,
+// and freed by freeTypeTables at the end of the dump.
+var (
+	dumpedTypes     []*_type
+	dumpedTypeCount int
+	typeStack       []*_type
 )
 
-type typeCacheBucket struct {
-	t [typeCacheAssoc]*_type
+// addDumpedType adds t to dumpedTypes and reports whether it was
+// not in the set yet.
+func addDumpedType(t *_type) bool {
+	if dumpedTypeCount >= len(dumpedTypes)/2 {
+		old := dumpedTypes
+		dumpedTypes = allocTypeTable(2 * len(old))
+		dumpedTypeCount = 0
+		for _, t := range old {
+			if t != nil {
+				addDumpedType(t)
+			}
+		}
+		freeTypeTable(old)
+	}
+	mask := uintptr(len(dumpedTypes) - 1)
+	for i := uintptr(t.hash) & mask; ; i = (i + 1) & mask {
+		switch dumpedTypes[i] {
+		case t:
+			return false
+		case nil:
+			dumpedTypes[i] = t
+			dumpedTypeCount++
+			return true
+		}
+	}
 }
 
-var typecache [typeCacheBuckets]typeCacheBucket
+// pushType pushes t onto typeStack to be dumped,
+// unless it has been dumped or pushed before.
+func pushType(t *_type) {
+	if t == nil || !addDumpedType(t) {
+		return
+	}
+	if len(typeStack) == cap(typeStack) {
+		old := typeStack
+		typeStack = allocTypeTable(2 * cap(old))[:len(old)]
+		copy(typeStack, old)
+		freeTypeTable(old)
+	}
+	typeStack = append(typeStack, t)
+}
+
+// allocTypeTable returns a zeroed slice of at least n types
+// (a power of two) allocated with sysAlloc.
+func allocTypeTable(n int) []*_type {
+	if n < 1024 {
+		n = 1024
+	}
+	p := sysAlloc(uintptr(n)*sys.PtrSize, &memstats.other_sys)
+	if p == nil {
+		throw("heapdump: out of memory")
+	}
+	var tab []*_type
+	*(*slice)(unsafe.Pointer(&tab)) = slice{p, n, n}
+	return tab
+}
+
+func freeTypeTable(tab []*_type) {
+	if cap(tab) > 0 {
+		sysFree(unsafe.Pointer(&tab[:1][0]), uintptr(cap(tab))*sys.PtrSize, &memstats.other_sys)
+	}
+}
+
+// freeTypeTables frees dumpedTypes and typeStack.
+func freeTypeTables() {
+	freeTypeTable(dumpedTypes)
+	freeTypeTable(typeStack)
+	dumpedTypes, dumpedTypeCount, typeStack = nil, 0, nil
+}
 
 // dump a uint64 in a varint format parseable by encoding/binary
 func dumpint(v uint64) {
@@ -149,41 +215,22 @@ func dumpstr(s string) {
 	dumpmemrange(sp.str, uintptr(sp.len))
 }
 
-// dump information for a type
+// dump information for a type, and for the types it refers to
+// that have not been dumped yet
 func dumptype(t *_type) {
-	if t == nil {
-		return
+	pushType(t)
+	for len(typeStack) > 0 {
+		t := typeStack[len(typeStack)-1]
+		typeStack = typeStack[:len(typeStack)-1]
+		dumptyperecord(t)
 	}
+}
 
-	// If we've definitely serialized the type before,
-	// no need to do it again.
-	b := &typecache[t.hash&(typeCacheBuckets-1)]
-	if t == b.t[0] {
-		return
-	}
-	for i := 1; i < typeCacheAssoc; i++ {
-		if t == b.t[i] {
-			// Move-to-front
-			for j := i; j > 0; j-- {
-				b.t[j] = b.t[j-1]
-			}
-			b.t[0] = t
-			return
-		}
-	}
-
-	// Might not have been dumped yet. Dump it and
-	// remember we did so.
-	for j := typeCacheAssoc - 1; j > 0; j-- {
-		b.t[j] = b.t[j-1]
-	}
-	b.t[0] = t
-
-	// dump the type
+func dumptyperecord(t *_type) {
 	dumpint(tagType)
 	dumpint(uint64(uintptr(unsafe.Pointer(t))))
 	dumpint(uint64(t.size))
-	if x := t.uncommon(); x == nil || t.nameOff(x.pkgpath).name() == "" {
+	if x := t.uncommon(); x == nil || t.tflag&tflagNamed == 0 || t.nameOff(x.pkgpath).name() == "" {
 		dumpstr(t.string())
 	} else {
 		pkgpathstr := t.nameOff(x.pkgpath).name()
@@ -196,6 +243,83 @@ func dumptype(t *_type) {
 		dwrite(name.str, uintptr(name.len))
 	}
 	dumpbool(t.kind&kindDirectIface == 0 || t.ptrdata != 0)
+
+	// dump the layout of the type
+	dumpbool(t.kind&kindDirectIface != 0)
+	dumpint(uint64(t.kind & kindMask))
+	dumpint(uint64(t.ptrdata))
+	var elem *_type
+	var n uintptr
+	switch t.kind & kindMask {
+	case kindArray:
+		at := (*arraytype)(unsafe.Pointer(t))
+		elem, n = at.elem, at.len
+	case kindMap:
+		elem = (*maptype)(unsafe.Pointer(t)).bucket
+	case kindPtr:
+		elem = (*ptrtype)(unsafe.Pointer(t)).elem
+	case kindSlice:
+		elem = (*slicetype)(unsafe.Pointer(t)).elem
+	}
+	dumpint(uint64(uintptr(unsafe.Pointer(elem))))
+	dumpint(uint64(n))
+	var fields []structfield
+	if t.kind&kindMask == kindStruct {
+		fields = (*structtype)(unsafe.Pointer(t)).fields
+	}
+	dumpint(uint64(len(fields)))
+	for i := range fields {
+		dumpint(uint64(fields[i].offset()))
+		dumpint(uint64(uintptr(unsafe.Pointer(fields[i].typ))))
+	}
+
+	// dump the types it refers to
+	pushType(elem)
+	for i := range fields {
+		pushType(fields[i].typ)
+	}
+	switch t.kind & kindMask {
+	case kindChan:
+		pushType((*chantype)(unsafe.Pointer(t)).elem)
+	case kindFunc:
+		ft := (*functype)(unsafe.Pointer(t))
+		for _, t := range ft.in() {
+			pushType(t)
+		}
+		for _, t := range ft.out() {
+			pushType(t)
+		}
+	case kindInterface:
+		it := (*interfacetype)(unsafe.Pointer(t))
+		for i := range it.mhdr {
+			pushType(resolveTypeOff(unsafe.Pointer(it), it.mhdr[i].ityp))
+		}
+	case kindMap:
+		mt := (*maptype)(unsafe.Pointer(t))
+		pushType(mt.key)
+		pushType(mt.elem)
+	}
+	pushType(resolveTypeOff(unsafe.Pointer(t), t.ptrToThis))
+	if x := t.uncommon(); x != nil {
+		// The method types of methods removed by the linker are 0.
+		ms := (*[1 << 16]method)(add(unsafe.Pointer(x), uintptr(x.moff)))[:x.mcount:x.mcount]
+		for i := range ms {
+			pushType(resolveTypeOff(unsafe.Pointer(t), ms[i].mtyp))
+		}
+	}
+}
+
+// dumptypes dumps the types in the typelinks of all modules, and the
+// types they refer to, so that readers of the dump can find the types
+// of objects referred to by interface values. That covers the types
+// of all values that can be converted to interfaces by the program
+// and the types in the signatures of their methods.
+func dumptypes() {
+	for _, md := range activeModules() {
+		for _, off := range md.typelinks {
+			dumptype((*_type)(unsafe.Pointer(md.types + uintptr(off))))
+		}
+	}
 }
 
 // dump an object
@@ -213,6 +337,7 @@ func dumpotherroot(description string, to unsafe.Pointer) {
 }
 
 func dumpfinalizer(obj unsafe.Pointer, fn *funcval, fint *_type, ot *ptrtype) {
+	dumptype(&ot.typ)
 	dumpint(tagFinalizer)
 	dumpint(uint64(uintptr(obj)))
 	dumpint(uint64(uintptr(unsafe.Pointer(fn))))
@@ -406,6 +531,7 @@ func dumpgs() {
 }
 
 func finq_callback(fn *funcval, obj unsafe.Pointer, nret uintptr, fint *_type, ot *ptrtype) {
+	dumptype(&ot.typ)
 	dumpint(tagQueuedFinalizer)
 	dumpint(uint64(uintptr(obj)))
 	dumpint(uint64(uintptr(unsafe.Pointer(fn))))
@@ -632,7 +758,7 @@ func dumpmemprof() {
 	}
 }
 
-var dumphdr = []byte("go1.7 heap dump\n")
+var dumphdr = []byte("go1.14 heap dump\n")
 
 func mdump() {
 	// make sure we're done sweeping
@@ -641,9 +767,9 @@ func mdump() {
 			s.ensureSwept()
 		}
 	}
-	memclrNoHeapPointers(unsafe.Pointer(&typecache), unsafe.Sizeof(typecache))
 	dwrite(unsafe.Pointer(&dumphdr[0]), uintptr(len(dumphdr)))
 	dumpparams()
+	dumptypes()
 	dumpitabs()
 	dumpobjs()
 	dumpgs()
@@ -653,6 +779,7 @@ func mdump() {
 	dumpmemprof()
 	dumpint(tagEOF)
 	flush()
+	freeTypeTables()
 }
 
 func writeheapdump_m(fd uintptr) {
@@ -1249,6 +1249,13 @@ func gcStart(trigger gcTrigger) {
 		mode = gcForceBlockMode
 	}
 
+	// Goroutine leak detection requires that user goroutines do
+	// not run during mark. See mgcleak.go.
+	detectLeaks := atomic.Cas(&goroutineLeak.requested, 1, 0)
+	if detectLeaks && mode == gcBackgroundMode {
+		mode = gcForceMode
+	}
+
 	// Ok, we're doing it! Stop everybody else
 	semacquire(&gcsema)
 	semacquire(&worldsema)
@@ -1296,6 +1303,11 @@ func gcStart(trigger gcTrigger) {
 
 	work.cycles++
 
+	if detectLeaks {
+		// Must happen before write barriers are enabled.
+		goroutineLeakPrepare()
+	}
+
 	gcController.startCycle()
 	work.heapGoal = memstats.next_gc
 
@@ -1349,14 +1361,18 @@ func gcStart(trigger gcTrigger) {
 		work.pauseNS += now - work.pauseStart
 		work.tMark = now
 	})
-	// In STW mode, we could block the instant systemstack
-	// returns, so don't do anything important here. Make sure we
-	// block rather than returning to user code.
+	// Release the world sema before Gosched() in STW mode
+	// because we will need to reacquire it later but before
+	// this goroutine becomes runnable again, and we could
+	// self-deadlock otherwise.
+	semrelease(&worldsema)
+
+	// Make sure we block instead of returning to user code
+	// in STW mode.
 	if mode != gcBackgroundMode {
 		Gosched()
 	}
 
-	semrelease(&worldsema)
 	semrelease(&work.startSema)
 }
 
@@ -1553,6 +1569,11 @@ top:
 					break
 				}
 			}
+			// Goroutine leak detection resumes mark when it
+			// finds goroutines that are reachable after all.
+			if !restart && goroutineLeak.enabled {
+				restart = goroutineLeakScan()
+			}
 		})
 		if restart {
 			getg().m.preemptoff = ""
@@ -0,0 +1,226 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Goroutine leak detection.
+//
+// A goroutine blocked on a channel, a sync.Cond, or a semaphore (as
+// used by sync.Mutex and sync.WaitGroup) can only be woken by another
+// goroutine that can reach the object it is blocked on. If no such
+// goroutine exists, the blocked goroutine is leaked: it will never
+// run again, and everything its stack refers to is retained forever.
+//
+// A garbage collection cycle can find leaked goroutines by treating
+// the stacks of blocked goroutines not as roots, but as objects that
+// are reachable only if the object they are blocked on is:
+//
+// 1. Before mark, goroutineLeakPrepare finds the candidates: user
+// goroutines blocked on one of the objects above. Their stacks are
+// not scanned by markroot. Their g.waiting lists are hidden from the
+// garbage collector, since the g is always reachable through allgs
+// and its sudogs refer to the channels it is blocked on. Semaphores
+// are not found through semtable, which is not scanned at all.
+//
+// 2. When mark completes, goroutineLeakScan scans the stacks of the
+// candidates whose objects have been marked, and resumes mark. This
+// repeats until no more candidates are found reachable.
+//
+// 3. The remaining candidates are leaked. goroutineLeakScan records
+// them and scans their stacks, too, so that the cycle retains
+// everything they refer to like any other, and has semtable
+// scanned again by later cycles.
+//
+// Candidates must not run while their stacks are not scanned, so the
+// cycle runs with user goroutines stopped, like with
+// GODEBUG=gcstoptheworld=1. A candidate may still be readied, by a
+// system goroutine or a timer, in which case it is reachable.
+
+package runtime
+
+import (
+	"runtime/internal/atomic"
+	"runtime/internal/sys"
+	"unsafe"
+)
+
+var goroutineLeak struct {
+	// requested is set to have the next garbage collection
+	// cycle detect leaked goroutines. Accessed atomically.
+	requested uint32
+
+	// enabled is set during a cycle that detects leaked
+	// goroutines, until all candidates have been scanned.
+	// Accessed only with the world stopped.
+	enabled bool
+
+	// cycle is the last cycle that detected leaked
+	// goroutines. Accessed atomically.
+	cycle uint32
+
+	// count is the number of leaked goroutines found by
+	// the last cycle that detected them. Accessed atomically.
+	count uint32
+}
+
+// detectGoroutineLeaks runs a garbage collection cycle that detects
+// leaked goroutines, blocks until it is complete, and returns the
+// number of leaked goroutines.
+//
+//go:linkname detectGoroutineLeaks runtime/pprof.runtime_detectGoroutineLeaks
+func detectGoroutineLeaks() int {
+	// As in GC, but another cycle may begin before we
+	// request leak detection, so try until one detects them.
+	for {
+		n := atomic.Load(&work.cycles)
+		gcWaitOnMark(n)
+		atomic.Store(&goroutineLeak.requested, 1)
+		gcStart(gcTrigger{kind: gcTriggerCycle, n: n + 1})
+		gcWaitOnMark(n + 1)
+		if atomic.Load(&goroutineLeak.cycle) > n {
+			return int(atomic.Load(&goroutineLeak.count))
+		}
+	}
+}
+
+//go:linkname testing_detectGoroutineLeaks testing.runtime_detectGoroutineLeaks
+func testing_detectGoroutineLeaks() int {
+	return detectGoroutineLeaks()
+}
+
+// goroutineLeakPrepare finds the candidate leaked goroutines
+// and hides their g.waiting lists from the garbage collector.
+//
+// The world must be stopped and write barriers disabled.
+func goroutineLeakPrepare() {
+	goroutineLeak.enabled = true
+	setSemtableScanned(false)
+	atomic.Store(&goroutineLeak.cycle, work.cycles)
+	for _, gp := range allgs {
+		gp.leaked = false
+		if !isLeakCandidate(gp) {
+			continue
+		}
+		gp.leakCandidate = true
+		gp.leakWaiting = uintptr(unsafe.Pointer(gp.waiting))
+		gp.waiting = nil
+	}
+}
+
+// isLeakCandidate reports whether gp is a user goroutine blocked on
+// a channel, sync.Cond or semaphore.
+func isLeakCandidate(gp *g) bool {
+	if readgstatus(gp) != _Gwaiting || isSystemGoroutine(gp, false) {
+		return false
+	}
+	switch gp.waitreason {
+	case waitReasonChanReceiveNilChan, waitReasonChanSendNilChan, waitReasonSelectNoCases:
+		return true
+	case waitReasonChanReceive, waitReasonChanSend, waitReasonSelect:
+		return gp.waiting != nil
+	case waitReasonSemacquire, waitReasonSyncCondWait:
+		// Semaphores outside the heap, such as those
+		// of global variables, are always reachable.
+		base, _, _ := findObject(gp.waitaddr, 0, 0)
+		return base != 0
+	}
+	return false
+}
+
+// goroutineLeakScan scans the stacks of the candidate leaked
+// goroutines that have become reachable. If there are none, the
+// remaining candidates are leaked, and it scans their stacks, ending
+// leak detection for this cycle. It reports whether it scanned any
+// stacks, in which case mark must resume.
+//
+// The world must be stopped. goroutineLeakScan must run on the
+// system stack.
+//
+//go:systemstack
+func goroutineLeakScan() bool {
+	gcw := &getg().m.p.ptr().gcw
+	scanned := false
+	for _, gp := range allgs {
+		if gp.leakCandidate && leakCandidateReachable(gp) {
+			scanLeakCandidate(gp, gcw)
+			scanned = true
+		}
+	}
+	if scanned {
+		return true
+	}
+
+	n := uint32(0)
+	for _, gp := range allgs {
+		if gp.leakCandidate {
+			gp.leaked = true
+			scanLeakCandidate(gp, gcw)
+			scanned = true
+			n++
+		}
+	}
+	atomic.Store(&goroutineLeak.count, n)
+	setSemtableScanned(true)
+	goroutineLeak.enabled = false
+	return scanned
+}
+
+// setSemtableScanned sets whether the garbage collector scans the
+// treaps of semtable, by setting or clearing their bits in the
+// pointer mask of the runtime's global variables.
+//
+// The world must be stopped, and no roots may be being scanned.
+func setSemtableScanned(scanned bool) {
+	md := &firstmoduledata
+	for i := range semtable {
+		p := uintptr(unsafe.Pointer(&semtable[i].root.treap))
+		mask, off := md.gcdatamask, p-md.data
+		if md.bss <= p && p < md.ebss {
+			mask, off = md.gcbssmask, p-md.bss
+		}
+		w := off / sys.PtrSize
+		b := addb(mask.bytedata, w/8)
+		if scanned {
+			*b |= 1 << (w % 8)
+		} else {
+			*b &^= 1 << (w % 8)
+		}
+	}
+}
+
+// leakCandidateReachable reports whether the candidate leaked goroutine
+// gp has been readied or any of the objects it is blocked on is marked.
+func leakCandidateReachable(gp *g) bool {
+	if readgstatus(gp) != _Gwaiting {
+		return true
+	}
+	switch gp.waitreason {
+	case waitReasonChanReceive, waitReasonChanSend, waitReasonSelect:
+		for sg := (*sudog)(unsafe.Pointer(gp.leakWaiting)); sg != nil; sg = sg.waitlink {
+			if isMarkedOrNotHeap(uintptr(unsafe.Pointer(sg.c))) {
+				return true
+			}
+		}
+	case waitReasonSemacquire, waitReasonSyncCondWait:
+		return isMarkedOrNotHeap(gp.waitaddr)
+	}
+	return false
+}
+
+// isMarkedOrNotHeap reports whether the heap object containing p is
+// marked, or p does not point into the heap.
+func isMarkedOrNotHeap(p uintptr) bool {
+	base, s, objIndex := findObject(p, 0, 0)
+	if base == 0 {
+		return true
+	}
+	return s.markBitsForIndex(objIndex).isMarked()
+}
+
+// scanLeakCandidate restores the g.waiting list of the candidate
+// leaked goroutine gp, which copystack needs, and scans its stack.
+func scanLeakCandidate(gp *g, gcw *gcWork) {
+	gp.leakCandidate = false
+	gp.waiting = (*sudog)(unsafe.Pointer(gp.leakWaiting))
+	gp.leakWaiting = 0
+	scang(gp, gcw)
+}
@@ -189,6 +189,10 @@ func markroot(gcw *gcWork, i uint32) {
 		} else {
 			throw("markroot: bad index")
 		}
+		if gp.leakCandidate {
+			// Scanned by goroutineLeakScan, if at all.
+			break
+		}
 
 		// remember when we've first observed the G blocked
 		// needed only to output in traceback
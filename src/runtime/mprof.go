@@ -766,6 +766,39 @@ func GoroutineProfile(p []StackRecord) (n int, ok bool) {
 	return n, ok
 }
 
+// goroutineLeakProfile is like GoroutineProfile, but returns only the
+// leaked goroutines found by the last leak detection (see mgcleak.go).
+//
+//go:linkname goroutineLeakProfile runtime/pprof.runtime_goroutineLeakProfile
+func goroutineLeakProfile(p []StackRecord) (n int, ok bool) {
+	isLeaked := func(gp1 *g) bool {
+		return gp1.leaked && readgstatus(gp1) == _Gwaiting
+	}
+
+	stopTheWorld("profile")
+
+	for _, gp1 := range allgs {
+		if isLeaked(gp1) {
+			n++
+		}
+	}
+
+	if n <= len(p) {
+		ok = true
+		r := p
+		for _, gp1 := range allgs {
+			if isLeaked(gp1) {
+				saveg(^uintptr(0), ^uintptr(0), gp1, &r[0])
+				r = r[1:]
+			}
+		}
+	}
+
+	startTheWorld()
+
+	return n, ok
+}
+
 func saveg(pc, sp uintptr, gp *g, r *StackRecord) {
 	n := gentraceback(pc, sp, 0, gp, 0, &r.Stack0[0], len(r.Stack0), nil, nil, 0)
 	if n < len(r.Stack0) {
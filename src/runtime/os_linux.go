@@ -5,11 +5,23 @@
 package runtime
 
 import (
+	"runtime/internal/atomic"
 	"runtime/internal/sys"
 	"unsafe"
 )
 
-type mOS struct{}
+type mOS struct {
+	// profileTimer holds the ID of the POSIX interval timer for
+	// profiling CPU usage on this thread.
+	//
+	// It is valid when profileTimerValid is non-zero. A thread
+	// creates and manages its own timer, and these fields are read
+	// and written only by this thread. But because some of the
+	// reads of profileTimerValid are in the signal handler, it is
+	// accessed atomically.
+	profileTimer      int32
+	profileTimerValid uint32
+}
 
 //go:noescape
 func futex(addr unsafe.Pointer, op int32, val uint32, ts, addr2 unsafe.Pointer, val3 uint32) int32
@@ -356,6 +368,14 @@ func sigaltstack(new, old *stackt)
 //go:noescape
 func setitimer(mode int32, new, old *itimerval)
 
+//go:noescape
+func timer_create(clockid int32, sevp *sigevent, timerid *int32) int32
+
+//go:noescape
+func timer_settime(timerid int32, flags int32, new, old *itimerspec) int32
+
+func timer_delete(timerid int32) int32
+
 //go:noescape
 func rtsigprocmask(how int32, new, old *sigset, size int32)
 
@@ -452,3 +472,120 @@ func sysSigaction(sig uint32, new, old *sigactiont) {
 // rt_sigaction is implemented in assembly.
 //go:noescape
 func rt_sigaction(sig uintptr, new, old *sigactiont, size uintptr) int32
+
+// setProcessCPUProfiler is called when the profiling timer changes.
+// It is called with prof.signalLock held. hz is the new timer, and is
+// 0 if profiling is being disabled.
+//
+// The process-wide setitimer timer remains in use on Linux, to
+// profile threads that have no per-thread timer, such as those not
+// created by Go.
+func setProcessCPUProfiler(hz int32) {
+	setProcessCPUProfilerTimer(hz)
+}
+
+// setThreadCPUProfiler makes any thread-specific changes required to
+// implement profiling at a rate of hz.
+//
+// On Linux, each thread has its own interval timer measuring the CPU
+// time of just that thread. The process-wide setitimer timer counts
+// the CPU time of all threads, but delivers its signal to whichever
+// thread the kernel chooses, and only one signal per tick, so on a
+// machine with many busy threads it undercounts and misattributes
+// samples.
+func setThreadCPUProfiler(hz int32) {
+	mp := getg().m
+	mp.profilehz = hz
+
+	// Destroy any active timer.
+	if atomic.Load(&mp.profileTimerValid) != 0 {
+		timerid := mp.profileTimer
+		atomic.Store(&mp.profileTimerValid, 0)
+		mp.profileTimer = 0
+
+		ret := timer_delete(timerid)
+		if ret != 0 {
+			print("runtime: failed to disable profiling timer; timer_delete(", timerid, ") errno=", -ret, "\n")
+			throw("timer_delete")
+		}
+	}
+
+	if hz == 0 {
+		// If the goal was to disable profiling for this
+		// thread, then the job's done.
+		return
+	}
+
+	// The period of the timer is 1/hz. But to observe even a
+	// fraction of a period of CPU time on this thread, set the
+	// initial delay to a uniformly random value in (0, period],
+	// so that, say, a tenth of a period of work has a 10% chance
+	// of producing a sample. Otherwise the profile would be
+	// biased against short-lived and mostly idle threads, such as
+	// those the garbage collector wakes up for a few milliseconds.
+	var spec itimerspec
+	spec.it_value.setNsec(1 + int64(fastrandn(uint32(1e9/hz))))
+	spec.it_interval.setNsec(1e9 / int64(hz))
+
+	var timerid int32
+	var sevp sigevent
+	sevp.notify = _SIGEV_THREAD_ID
+	sevp.signo = _SIGPROF
+	sevp.sigev_notify_thread_id = int32(mp.procid)
+	ret := timer_create(_CLOCK_THREAD_CPUTIME_ID, &sevp, &timerid)
+	if ret != 0 {
+		// If we cannot create a timer for this M, leave
+		// profileTimerValid false to fall back to the
+		// process-wide setitimer profiler.
+		return
+	}
+
+	ret = timer_settime(timerid, 0, &spec, nil)
+	if ret != 0 {
+		print("runtime: failed to configure profiling timer; ",
+			"timer_settime(", timerid,
+			", 0, {interval: {",
+			spec.it_interval.tv_sec, "s + ", spec.it_interval.tv_nsec, "ns} value: {",
+			spec.it_value.tv_sec, "s + ", spec.it_value.tv_nsec, "ns}}, nil) errno=", -ret, "\n")
+		throw("timer_settime")
+	}
+
+	mp.profileTimer = timerid
+	atomic.Store(&mp.profileTimerValid, 1)
+}
+
+// validSIGPROF reports whether a SIGPROF signal delivered to the
+// thread running mp, which may be nil, should be counted as a
+// profiling sample. Threads with a per-thread timer count only its
+// signals, and ignore those of the process-wide timer, so that their
+// CPU time is not counted twice.
+//
+//go:nosplit
+func validSIGPROF(mp *m, c *sigctxt) bool {
+	code := int32(c.sigcode())
+	setitimer := code == _SI_KERNEL
+	timer_create := code == _SI_TIMER
+
+	if !(setitimer || timer_create) {
+		// The signal doesn't correspond to a profiling timer
+		// at all (it may have been sent by another process).
+		return true
+	}
+
+	if mp == nil {
+		// Without an M we can't tell whether this thread has
+		// a per-thread timer left over from an earlier time
+		// it ran Go code. To avoid double-counting, process
+		// only signals from setitimer.
+		return setitimer
+	}
+
+	if atomic.Load(&mp.profileTimerValid) != 0 {
+		// This M has its own timer, which accurately reports
+		// its CPU usage; ignore the process-wide timer.
+		return timer_create
+	}
+
+	// No per-thread timer means the only valid profiler is setitimer.
+	return setitimer
+}
@@ -32,6 +32,8 @@ const (
 	_SS_DISABLE  = 2
 	_NSIG        = 129
 	_SI_USER     = 0
+	_SI_KERNEL   = 0x80
+	_SI_TIMER    = -0x3
 	_SIG_BLOCK   = 1
 	_SIG_UNBLOCK = 2
 	_SIG_SETMASK = 3
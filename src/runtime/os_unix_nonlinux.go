@@ -0,0 +1,29 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build aix darwin dragonfly freebsd netbsd openbsd solaris
+
+package runtime
+
+// setProcessCPUProfiler is called when the profiling timer changes.
+// It is called with prof.signalLock held. hz is the new timer, and is
+// 0 if profiling is being disabled.
+func setProcessCPUProfiler(hz int32) {
+	setProcessCPUProfilerTimer(hz)
+}
+
+// setThreadCPUProfiler makes any thread-specific changes required to
+// implement profiling at a rate of hz.
+func setThreadCPUProfiler(hz int32) {
+	setThreadCPUProfilerHz(hz)
+}
+
+// validSIGPROF reports whether a SIGPROF signal delivered to the
+// thread running mp, which may be nil, should be counted as a
+// profiling sample. Only the process-wide timer is in use, so all are.
+//
+//go:nosplit
+func validSIGPROF(mp *m, c *sigctxt) bool {
+	return true
+}
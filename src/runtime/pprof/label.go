@@ -32,6 +32,8 @@ func labelValue(ctx context.Context) labelMap {
 // labelMap is the representation of the label set held in the context type.
 // This is an initial implementation, but it will be replaced with something
 // that admits incremental immutable modification more efficiently.
+// The runtime reads it as a map[string]string when recording the labels
+// in an execution trace (see traceGoLabels in runtime/trace.go).
 type labelMap map[string]string
 
 // WithLabels returns a new context.Context with the given labels added.
@@ -54,7 +56,7 @@ func WithLabels(ctx context.Context, labels LabelSet) context.Context {
 // Labels takes an even number of strings representing key-value pairs
 // and makes a LabelSet containing them.
 // A label overwrites a prior label with the same key.
-// Currently only CPU profile utilizes labels information.
+// Currently only CPU profile and execution trace utilize labels information.
 // See https://golang.org/issue/23458 for details.
 func Labels(args ...string) LabelSet {
 	if len(args)%2 != 0 {
@@ -99,12 +99,13 @@ import (
 //
 // Each Profile has a unique name. A few profiles are predefined:
 //
-//	goroutine    - stack traces of all current goroutines
-//	heap         - a sampling of memory allocations of live objects
-//	allocs       - a sampling of all past memory allocations
-//	threadcreate - stack traces that led to the creation of new OS threads
-//	block        - stack traces that led to blocking on synchronization primitives
-//	mutex        - stack traces of holders of contended mutexes
+//	goroutine     - stack traces of all current goroutines
+//	goroutineleak - stack traces of leaked goroutines
+//	heap          - a sampling of memory allocations of live objects
+//	allocs        - a sampling of all past memory allocations
+//	threadcreate  - stack traces that led to the creation of new OS threads
+//	block         - stack traces that led to blocking on synchronization primitives
+//	mutex         - stack traces of holders of contended mutexes
 //
 // These predefined profiles maintain themselves and panic on an explicit
 // Add or Remove method call.
@@ -126,6 +127,12 @@ import (
 // pprof display to -alloc_space, the total number of bytes allocated since
 // the program began (including garbage-collected bytes).
 //
+// The goroutineleak profile reports goroutines blocked on a channel,
+// a sync.Cond, or a sync.Mutex or other synchronization primitive
+// that no other goroutine can reach, so that they can never run again.
+// Writing the profile runs a garbage collection cycle to find them,
+// during which all goroutines are stopped.
+//
 // The CPU profile is not available as a Profile. It has a special API,
 // the StartCPUProfile and StopCPUProfile functions, because it streams
 // output to a writer during profiling.
@@ -150,6 +157,12 @@ var goroutineProfile = &Profile{
 	write: writeGoroutine,
 }
 
+var goroutineLeakProfile = &Profile{
+	name:  "goroutineleak",
+	count: countGoroutineLeak,
+	write: writeGoroutineLeak,
+}
+
 var threadcreateProfile = &Profile{
 	name:  "threadcreate",
 	count: countThreadCreate,
@@ -185,12 +198,13 @@ func lockProfiles() {
 	if profiles.m == nil {
 		// Initial built-in profiles.
 		profiles.m = map[string]*Profile{
-			"goroutine":    goroutineProfile,
-			"threadcreate": threadcreateProfile,
-			"heap":         heapProfile,
-			"allocs":       allocsProfile,
-			"block":        blockProfile,
-			"mutex":        mutexProfile,
+			"goroutine":     goroutineProfile,
+			"goroutineleak": goroutineLeakProfile,
+			"threadcreate":  threadcreateProfile,
+			"heap":          heapProfile,
+			"allocs":        allocsProfile,
+			"block":         blockProfile,
+			"mutex":         mutexProfile,
 		}
 	}
 }
@@ -670,6 +684,19 @@ func writeGoroutine(w io.Writer, debug int) error {
 	return writeRuntimeProfile(w, debug, "goroutine", runtime.GoroutineProfile)
 }
 
+// countGoroutineLeak detects leaked goroutines
+// and returns their number.
+func countGoroutineLeak() int {
+	return runtime_detectGoroutineLeaks()
+}
+
+// writeGoroutineLeak detects leaked goroutines and writes
+// their profile to w.
+func writeGoroutineLeak(w io.Writer, debug int) error {
+	runtime_detectGoroutineLeaks()
+	return writeRuntimeProfile(w, debug, "goroutineleak", runtime_goroutineLeakProfile)
+}
+
 func writeGoroutineStacks(w io.Writer) error {
 	// We don't know how big the buffer needs to be to collect
 	// all the goroutines. Start with 1 MB and try a few times, doubling each time.
@@ -731,6 +758,11 @@ var cpu struct {
 // While profiling, the profile will be buffered and written to w.
 // StartCPUProfile returns an error if profiling is already enabled.
 //
+// StartCPUProfile samples at 100 Hz, which is frequent enough to
+// produce useful data, rare enough not to bog down the system, and a
+// nice round number to make it easy to convert sample counts to
+// seconds. Use StartCPUProfileRate to sample at a different rate.
+//
 // On Unix-like systems, StartCPUProfile does not work by default for
 // Go code built with -buildmode=c-archive or -buildmode=c-shared.
 // StartCPUProfile relies on the SIGPROF signal, but that signal will
@@ -739,16 +771,23 @@ var cpu struct {
 // for syscall.SIGPROF, but note that doing so may break any profiling
 // being done by the main program.
 func StartCPUProfile(w io.Writer) error {
-	// The runtime routines allow a variable profiling rate,
-	// but in practice operating systems cannot trigger signals
-	// at more than about 500 Hz, and our processing of the
-	// signal is not cheap (mostly getting the stack trace).
-	// 100 Hz is a reasonable choice: it is frequent enough to
-	// produce useful data, rare enough not to bog down the
-	// system, and a nice round number to make it easy to
-	// convert sample counts to seconds. Instead of requiring
-	// each client to specify the frequency, we hard code it.
-	const hz = 100
+	return StartCPUProfileRate(w, 100)
+}
+
+// StartCPUProfileRate is like StartCPUProfile, but samples hz times
+// per second of CPU time.
+//
+// Higher rates give more detail for short-running programs, at a
+// higher cost per second of profiling. On Linux, each thread has its
+// own profiling timer, so that rates up to a few thousand hertz are
+// accurate even with many busy threads. Elsewhere, the operating
+// system may not be able to deliver signals at more than about 500 Hz.
+//
+// StartCPUProfileRate returns an error if hz is not positive.
+func StartCPUProfileRate(w io.Writer, hz int) error {
+	if hz <= 0 {
+		return fmt.Errorf("invalid cpu profiling rate %d", hz)
+	}
 
 	cpu.Lock()
 	defer cpu.Unlock()
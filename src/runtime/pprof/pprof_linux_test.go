@@ -0,0 +1,75 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/pprof/internal/profile"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestCPUProfileMultithreadMagnitude checks that the CPU time in a
+// profile of several busy threads matches the CPU time the kernel
+// reports for the process. Linux uses a profiling timer per thread,
+// so that samples are not lost when several threads are busy at once.
+func TestCPUProfileMultithreadMagnitude(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	const workers = 4
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(workers))
+
+	// Profiling is statistical, and other processes may steal
+	// CPU time from ours, so allow a few attempts.
+	var profTime, osTime time.Duration
+	for attempt := 0; attempt < 3; attempt++ {
+		var prof bytes.Buffer
+		if err := StartCPUProfile(&prof); err != nil {
+			t.Fatal(err)
+		}
+		before := cpuTime(t)
+		done := make(chan bool)
+		for i := 0; i < workers; i++ {
+			go func() {
+				var salt int
+				cpuHogger(cpuHog1, &salt, 1*time.Second)
+				done <- true
+			}()
+		}
+		for i := 0; i < workers; i++ {
+			<-done
+		}
+		osTime = cpuTime(t) - before
+		StopCPUProfile()
+
+		p, err := profile.Parse(&prof)
+		if err != nil {
+			t.Fatalf("failed to parse profile: %v", err)
+		}
+		profTime = 0
+		for _, s := range p.Sample {
+			profTime += time.Duration(s.Value[1])
+		}
+		t.Logf("profile has %v of CPU time, the OS reports %v", profTime, osTime)
+
+		// Sampling is random, so allow some slack.
+		if diff := profTime - osTime; diff < osTime/5 && -diff < osTime/5 {
+			return
+		}
+	}
+	t.Errorf("profile has %v of CPU time, want about %v", profTime, osTime)
+}
+
+// cpuTime returns the CPU time used by the process so far.
+func cpuTime(t *testing.T) time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		t.Fatalf("Getrusage: %v", err)
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
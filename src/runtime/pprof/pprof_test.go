@@ -106,6 +106,37 @@ func TestCPUProfileInlining(t *testing.T) {
 	})
 }
 
+func TestCPUProfileRate(t *testing.T) {
+	var prof bytes.Buffer
+	if err := StartCPUProfileRate(&prof, 0); err == nil {
+		StopCPUProfile()
+		t.Fatalf("StartCPUProfileRate succeeded with rate 0")
+	}
+
+	const hz = 500
+	if err := StartCPUProfileRate(&prof, hz); err != nil {
+		t.Fatal(err)
+	}
+	if err := StartCPUProfile(&prof); err == nil {
+		t.Errorf("StartCPUProfile succeeded while profiling")
+	}
+	cpuHogger(cpuHog1, &salt1, 100*time.Millisecond)
+	StopCPUProfile()
+
+	p, err := profile.Parse(&prof)
+	if err != nil {
+		t.Fatalf("failed to parse profile: %v", err)
+	}
+	if want := int64(time.Second / hz); p.Period != want {
+		t.Errorf("profile period is %d, want %d", p.Period, want)
+	}
+	for _, s := range p.Sample {
+		if s.Value[1] != s.Value[0]*p.Period {
+			t.Errorf("sample of %d with %d ns of CPU time, want %d ns", s.Value[0], s.Value[1], s.Value[0]*p.Period)
+		}
+	}
+}
+
 func inlinedCaller(x int) int {
 	x = inlinedCallee(x)
 	return x
@@ -889,6 +920,67 @@ func TestGoroutineCounts(t *testing.T) {
 	time.Sleep(10 * time.Millisecond) // let goroutines exit
 }
 
+func leakedChanSend() {
+	c := make(chan int)
+	go func() { c <- 1 }()
+}
+
+func leakedWaitGroupWait() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go wg.Wait()
+}
+
+func liveChanRecv(c chan int) {
+	<-c
+}
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	leakedChanSend()
+	leakedWaitGroupWait()
+	c := make(chan int)
+	go liveChanRecv(c)
+	defer close(c)
+	time.Sleep(10 * time.Millisecond) // let goroutines block
+
+	var w bytes.Buffer
+	if err := Lookup("goroutineleak").WriteTo(&w, 1); err != nil {
+		t.Fatal(err)
+	}
+	prof := w.String()
+	for _, leaked := range []string{"pprof.leakedChanSend.func1", "sync.(*WaitGroup).Wait"} {
+		if !strings.Contains(prof, leaked) {
+			t.Errorf("leaked goroutine %s not in goroutineleak profile:\n%s", leaked, prof)
+		}
+	}
+	if strings.Contains(prof, "liveChanRecv") {
+		t.Errorf("goroutine blocked on reachable channel in goroutineleak profile:\n%s", prof)
+	}
+	n := Lookup("goroutineleak").Count()
+	if n < 2 {
+		t.Errorf("goroutineleak profile count = %d, want at least 2", n)
+	}
+	// Count detects leaked goroutines anew.
+	leakedChanSend()
+	time.Sleep(10 * time.Millisecond) // let goroutine block
+	if n1 := Lookup("goroutineleak").Count(); n1 < n+1 {
+		t.Errorf("goroutineleak profile count = %d after leaking another goroutine, want at least %d", n1, n+1)
+	}
+
+	// Check proto profile
+	w.Reset()
+	if err := Lookup("goroutineleak").WriteTo(&w, 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := profile.Parse(&w)
+	if err != nil {
+		t.Fatalf("error parsing protobuf profile: %v", err)
+	}
+	if err := p.CheckValid(); err != nil {
+		t.Errorf("protobuf profile is invalid: %v", err)
+	}
+}
+
 func containsInOrder(s string, all ...string) bool {
 	for _, t := range all {
 		i := strings.Index(s, t)
@@ -6,6 +6,7 @@ package pprof
 
 import (
 	"context"
+	"runtime"
 	"unsafe"
 )
 
@@ -15,6 +16,12 @@ func runtime_setProfLabel(labels unsafe.Pointer)
 // runtime_getProfLabel is defined in runtime/proflabel.go.
 func runtime_getProfLabel() unsafe.Pointer
 
+// runtime_detectGoroutineLeaks is defined in runtime/mgcleak.go.
+func runtime_detectGoroutineLeaks() int
+
+// runtime_goroutineLeakProfile is defined in runtime/mprof.go.
+func runtime_goroutineLeakProfile(p []runtime.StackRecord) (n int, ok bool)
+
 // SetGoroutineLabels sets the current goroutine's labels to match ctx.
 // A new goroutine inherits the labels of the goroutine that created it.
 // This is a lower-level API than Do, which should be used instead when possible.
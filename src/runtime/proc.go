@@ -201,6 +201,7 @@ func main() {
 	}
 	fn := main_main // make an indirect call, as the linker doesn't know the address of the main package when laying down the runtime
 	fn()
+	runExitHooks()
 	if raceenabled {
 		racefini()
 	}
@@ -229,10 +230,11 @@ func main() {
 	}
 }
 
-// os_beforeExit is called from os.Exit(0).
+// os_beforeExit is called from os.Exit.
 //go:linkname os_beforeExit os.runtime_beforeExit
-func os_beforeExit() {
-	if raceenabled {
+func os_beforeExit(exitCode int) {
+	runExitHooks()
+	if exitCode == 0 && raceenabled {
 		racefini()
 	}
 }
@@ -1297,6 +1299,11 @@ func mexit(osStack bool) {
 		throw("locked m0 woke up")
 	}
 
+	// Stop this thread's profiling timer, if any.
+	if m.profilehz != 0 {
+		setThreadCPUProfiler(0)
+	}
+
 	sigblock()
 	unminit()
 
@@ -1305,6 +1312,10 @@ func mexit(osStack bool) {
 		stackfree(m.gsignal.stack)
 	}
 
+	// Flush the trace buffer while m is still on allm,
+	// where the tracer looks for trace buffers.
+	traceMExit(m)
+
 	// Remove m from allm.
 	lock(&sched.lock)
 	for pprev := &allm; *pprev != nil; pprev = &(*pprev).alllink {
@@ -3679,6 +3690,13 @@ func sigprof(pc, sp, lr uintptr, gp *g, mp *m) {
 		return
 	}
 
+	// If mp.profilehz is 0, then profiling is not enabled for this
+	// thread. We must check this to avoid a deadlock between
+	// setcpuprofilerate and the call to cpuprof.add, below.
+	if mp != nil && mp.profilehz == 0 {
+		return
+	}
+
 	// On mips{,le}, 64bit atomics are emulated with spinlocks, in
 	// runtime/internal/atomic. If SIGPROF arrives while the program is inside
 	// the critical section, it creates a deadlock (when writing the sample).
@@ -3828,6 +3846,20 @@ func sigprof(pc, sp, lr uintptr, gp *g, mp *m) {
 	}
 
 	if prof.hz != 0 {
+		if trace.enabled {
+			// Attribute the sample to the user goroutine
+			// running on mp, if any, even if the signal
+			// arrived on g0 or gsignal.
+			gprof := gp
+			var pp *p
+			if mp != nil {
+				if mp.curg != nil {
+					gprof = mp.curg
+				}
+				pp = mp.p.ptr()
+			}
+			traceCPUSample(gprof, pp, stk[:n])
+		}
 		cpuprof.add(gp, stk[:n])
 	}
 	getg().m.mallocing--
@@ -4011,7 +4043,6 @@ func (pp *p) destroy() {
 	freemcache(pp.mcache)
 	pp.mcache = nil
 	gfpurge(pp)
-	traceProcFree(pp)
 	if raceenabled {
 		raceprocdestroy(pp.raceprocctx)
 		pp.raceprocctx = 0
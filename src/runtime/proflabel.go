@@ -31,7 +31,11 @@ func runtime_setProfLabel(labels unsafe.Pointer) {
 	if raceenabled {
 		racereleasemerge(unsafe.Pointer(&labelSync))
 	}
-	getg().labels = labels
+	gp := getg()
+	gp.labels = labels
+	if trace.enabled {
+		traceGoLabels(gp)
+	}
 }
 
 //go:linkname runtime_getProfLabel runtime/pprof.runtime_getProfLabel
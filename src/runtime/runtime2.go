@@ -436,6 +436,12 @@ type g struct {
 	labels         unsafe.Pointer // profiler labels
 	timer          *timer         // cached timer for time.Sleep
 	selectDone     uint32         // are we participating in a select and did someone win the race?
+	waitaddr       uintptr        // semaphore or notifyList a _Gwaiting g is blocked on; for leak detection
+
+	// Goroutine leak detection state; see mgcleak.go.
+	leakWaiting   uintptr // g.waiting, hidden from the garbage collector
+	leakCandidate bool    // g may be leaked; its stack is not a root
+	leaked        bool    // g was found leaked by the last leak detection
 
 	// Per-G GC state
 
@@ -500,6 +506,8 @@ type m struct {
 	waittraceev   byte
 	waittraceskip int
 	startingtrace bool
+	tracebuf      traceBufPtr // batches of trace events of the Ps this M ran
+	traceexited   bool        // tracebuf is flushed for mexit; use the global buffer
 	syscalltick   uint32
 	thread        uintptr // thread handle
 	freelink      *m      // on sched.freem
@@ -562,8 +570,6 @@ type p struct {
 	sudogcache []*sudog
 	sudogbuf   [128]*sudog
 
-	tracebuf traceBufPtr
-
 	// traceSweep indicates the sweep events should be traced.
 	// This is used to defer the sweep start event until a span
 	// has actually been swept.
@@ -141,7 +141,9 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags, skipframes i
 		// Any semrelease after the cansemacquire knows we're waiting
 		// (we set nwait above), so go to sleep.
 		root.queue(addr, s, lifo)
+		gp.waitaddr = uintptr(unsafe.Pointer(addr))
 		goparkunlock(&root.lock, waitReasonSemacquire, traceEvGoBlockSync, 4+skipframes)
+		gp.waitaddr = 0
 		if s.ticket != 0 || cansemacquire(addr) {
 			break
 		}
@@ -492,8 +494,9 @@ func notifyListWait(l *notifyList, t uint32) {
 	}
 
 	// Enqueue itself.
+	gp := getg()
 	s := acquireSudog()
-	s.g = getg()
+	s.g = gp
 	s.ticket = t
 	s.releasetime = 0
 	t0 := int64(0)
@@ -507,7 +510,9 @@ func notifyListWait(l *notifyList, t uint32) {
 		l.tail.next = s
 	}
 	l.tail = s
+	gp.waitaddr = uintptr(unsafe.Pointer(l))
 	goparkunlock(&l.lock, waitReasonSyncCondWait, traceEvGoBlockCond, 3)
+	gp.waitaddr = 0
 	if t0 != 0 {
 		blockevent(s.releasetime-t0, 2)
 	}
@@ -35,7 +35,12 @@ func sighandler(sig uint32, info *siginfo, ctxt unsafe.Pointer, gp *g) {
 	c := &sigctxt{info, ctxt}
 
 	if sig == _SIGPROF {
-		sigprof(c.sigpc(), c.sigsp(), c.siglr(), gp, _g_.m)
+		// Some platforms (Linux) have per-thread timers, which we
+		// use in combination with the process-wide timer. Avoid
+		// double-counting.
+		if validSIGPROF(_g_.m, c) {
+			sigprof(c.sigpc(), c.sigsp(), c.siglr(), gp, _g_.m)
+		}
 		return
 	}
 
@@ -13,6 +13,7 @@
 package runtime
 
 import (
+	"runtime/internal/atomic"
 	"runtime/internal/sys"
 	"unsafe"
 )
@@ -20,7 +21,7 @@ import (
 // Event types in the trace, args are given in square brackets.
 const (
 	traceEvNone              = 0  // unused
-	traceEvBatch             = 1  // start of per-P batch of events [pid, timestamp]
+	traceEvBatch             = 1  // start of per-P batch of events [pid, timestamp, batch sequence number]
 	traceEvFrequency         = 2  // contains tracer timer frequency [frequency (ticks per second)]
 	traceEvStack             = 3  // stack [stack id, number of PCs, array of {PC, func string ID, file string ID, line}]
 	traceEvGomaxprocs        = 4  // current value of GOMAXPROCS [timestamp, GOMAXPROCS, stack id]
@@ -68,7 +69,9 @@ const (
 	traceEvUserTaskEnd       = 46 // end of a task [timestamp, internal task id, stack]
 	traceEvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	traceEvUserLog           = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
-	traceEvCount             = 49
+	traceEvGoLabels          = 49 // goroutine's profiler labels [timestamp, goroutine id, labels]
+	traceEvCPUSample         = 50 // CPU profiling sample [timestamp, real timestamp, real P id (-1 when absent), goroutine id, stack id]
+	traceEvCount             = 51
 	// Byte is used but only 6 bits are available for event type.
 	// The remaining 2 bits are used to specify the number of arguments.
 	// That means, the max event type value is 63.
@@ -91,6 +94,8 @@ const (
 	traceStackSize = 128
 	// Identifier of a fake P that is used when we trace without a real P.
 	traceGlobProc = -1
+	// Identifier of no P, used to end a batch in a buffer.
+	traceNoProc = -2
 	// Maximum number of bytes to encode uint64 in base-128.
 	traceBytesPerNumber = 10
 	// Shift of the number of arguments in the first event byte.
@@ -102,10 +107,14 @@ const (
 	// Such wakeups happen on buffered channels and sync.Mutex,
 	// but are generally not interesting for end user.
 	traceFutileWakeup byte = 128
+	// Maximum number of profiler labels recorded in a traceEvGoLabels event.
+	traceMaxLabels = 64
 )
 
 // trace is global tracing context.
 var trace struct {
+	batchSeq uint64 // batch sequence number, accessed atomically; keep at top to ensure alignment on 32-bit systems
+
 	lock          mutex       // protects the following members
 	lockOwner     *g          // to avoid deadlocks during recursive lock locks
 	enabled       bool        // when set runtime traces events
@@ -141,17 +150,37 @@ var trace struct {
 
 	bufLock mutex       // protects buf
 	buf     traceBufPtr // global trace buffer, used when running without a p
+
+	// CPU profiling samples are written by the signal handler to
+	// cpuLogWrite and moved into the trace by traceReadCPU.
+	// cpuLogRead and cpuLogWrite are the same profBuf; cpuLogWrite
+	// is cleared under signalLock when tracing stops so that the
+	// signal handler no longer writes to it.
+	// Each record has a two-word header: the P, encoded as id<<1|1,
+	// or 2 when there is no P (the header of an overflow record is
+	// all zeros), and the goroutine id.
+	signalLock  uint32      // protects use of cpuLogWrite by the signal handler
+	cpuLogRead  *profBuf    // CPU samples, read by traceReadCPU
+	cpuLogWrite *profBuf    // CPU samples, written by traceCPUSample; accessed atomically
+	cpuLogBuf   traceBufPtr // trace buffer for CPU samples, protected by lock
 }
 
-// traceBufHeader is per-P tracing buffer.
+// traceBufHeader is per-M tracing buffer.
 type traceBufHeader struct {
 	link      traceBufPtr             // in trace.empty/full
 	lastTicks uint64                  // when we wrote the last event
 	pos       int                     // next write offset in arr
+	pid       int32                   // P of the current batch
 	stk       [traceStackSize]uintptr // scratch buffer for traceback
 }
 
-// traceBuf is per-P tracing buffer.
+// traceBuf is per-M tracing buffer.
+//
+// An M writes the events of the P it is running in a batch for that
+// P. The batch ends when the M may lose the P, at traceEvProcStop and
+// traceEvGoSysCall. The batches of a P are numbered in the order in
+// which they were started, which is the order of their events,
+// because only the M that owns a P writes its events.
 //
 //go:notinheap
 type traceBuf struct {
@@ -200,6 +229,25 @@ func StartTrace() error {
 		return errorString("tracing is already enabled")
 	}
 
+	traceStartGeneration()
+	trace.headerWritten = false
+	trace.footerWritten = false
+
+	// Start recording CPU profiling samples, if CPU profiling is on.
+	profBuf := newProfBuf(2, 1<<17, 1<<14)
+	trace.cpuLogRead = profBuf
+	atomicstorep(unsafe.Pointer(&trace.cpuLogWrite), unsafe.Pointer(profBuf))
+
+	unlock(&trace.bufLock)
+
+	startTheWorldGC()
+	return nil
+}
+
+// traceStartGeneration starts a generation of the trace by recording
+// the state of every goroutine, and enables tracing.
+// The world must be stopped and trace.bufLock held.
+func traceStartGeneration() {
 	// Can't set trace.enabled yet. While the world is stopped, exitsyscall could
 	// already emit a delayed event (see exitTicks in exitsyscall) if we set trace.enabled here.
 	// That would lead to an inconsistent trace:
@@ -213,7 +261,7 @@ func StartTrace() error {
 	// Obtain current stack ID to use in all traceEvGoCreate events below.
 	mp := acquirem()
 	stkBuf := make([]uintptr, traceStackSize)
-	stackID := traceStackID(mp, stkBuf, 2)
+	stackID := traceStackID(mp, stkBuf, 3)
 	releasem(mp)
 
 	for _, gp := range allgs {
@@ -245,8 +293,6 @@ func StartTrace() error {
 	// It will lead to a false conclusion that cputicks is broken.
 	trace.ticksStart = cputicks()
 	trace.timeStart = nanotime()
-	trace.headerWritten = false
-	trace.footerWritten = false
 
 	// string to id mapping
 	//  0 : reserved for an empty string
@@ -265,10 +311,12 @@ func StartTrace() error {
 	}
 	traceReleaseBuffer(pid)
 
-	unlock(&trace.bufLock)
-
-	startTheWorldGC()
-	return nil
+	// Record the profiler labels of existing goroutines.
+	for _, gp := range allgs {
+		if readgstatus(gp) != _Gdead && gp.labels != nil {
+			traceGoLabels(gp)
+		}
+	}
 }
 
 // StopTrace stops tracing, if it was previously enabled.
@@ -289,32 +337,16 @@ func StopTrace() {
 
 	traceGoSched()
 
-	// Loop over all allocated Ps because dead Ps may still have
-	// trace buffers.
-	for _, p := range allp[:cap(allp)] {
-		buf := p.tracebuf
-		if buf != 0 {
-			traceFullQueue(buf)
-			p.tracebuf = 0
-		}
-	}
-	if trace.buf != 0 {
-		buf := trace.buf
-		trace.buf = 0
-		if buf.ptr().pos != 0 {
-			traceFullQueue(buf)
-		}
-	}
-
-	for {
-		trace.ticksEnd = cputicks()
-		trace.timeEnd = nanotime()
-		// Windows time can tick only every 15ms, wait for at least one tick.
-		if trace.timeEnd != trace.timeStart {
-			break
-		}
+	// Stop recording CPU samples and move the remaining ones into the trace.
+	for !atomic.Cas(&trace.signalLock, 0, 1) {
 		osyield()
 	}
+	atomicstorep(unsafe.Pointer(&trace.cpuLogWrite), nil)
+	trace.cpuLogRead.close()
+	atomic.Store(&trace.signalLock, 0)
+	traceReadCPU()
+	traceQueueBuffers()
+	traceSetEnd()
 
 	trace.enabled = false
 	trace.shutdown = true
@@ -331,14 +363,17 @@ func StopTrace() {
 
 	// The lock protects us from races with StartTrace/StopTrace because they do stop-the-world.
 	lock(&trace.lock)
-	for _, p := range allp[:cap(allp)] {
-		if p.tracebuf != 0 {
-			throw("trace: non-empty trace buffer in proc")
+	for mp := allm; mp != nil; mp = mp.alllink {
+		if mp.tracebuf != 0 {
+			throw("trace: non-empty trace buffer in m")
 		}
 	}
 	if trace.buf != 0 {
 		throw("trace: non-empty global trace buffer")
 	}
+	if trace.cpuLogBuf != 0 {
+		throw("trace: non-empty CPU sample buffer")
+	}
 	if trace.fullHead != 0 || trace.fullTail != 0 {
 		throw("trace: non-empty full trace buffer")
 	}
@@ -351,10 +386,135 @@ func StopTrace() {
 		sysFree(unsafe.Pointer(buf), unsafe.Sizeof(*buf.ptr()), &memstats.other_sys)
 	}
 	trace.strings = nil
+	trace.cpuLogRead = nil
 	trace.shutdown = false
 	unlock(&trace.lock)
 }
 
+// traceQueueBuffers queues all trace buffers that hold events,
+// so that the reader returns them before any events written later.
+// The world must be stopped and trace.bufLock held.
+func traceQueueBuffers() {
+	// Only Ms with a P write to their buffers, and Ms flush
+	// their buffers before they leave allm (see traceMExit).
+	for mp := allm; mp != nil; mp = mp.alllink {
+		buf := mp.tracebuf
+		if buf != 0 {
+			traceFullQueue(buf)
+			mp.tracebuf = 0
+		}
+	}
+	if trace.buf != 0 {
+		buf := trace.buf
+		trace.buf = 0
+		if buf.ptr().pos != 0 {
+			traceFullQueue(buf)
+		}
+	}
+	if trace.cpuLogBuf != 0 {
+		buf := trace.cpuLogBuf
+		trace.cpuLogBuf = 0
+		if buf.ptr().pos != 0 {
+			traceFullQueue(buf)
+		}
+	}
+}
+
+// traceSetEnd records the end time of the current generation.
+func traceSetEnd() {
+	for {
+		trace.ticksEnd = cputicks()
+		trace.timeEnd = nanotime()
+		// Windows time can tick only every 15ms, wait for at least one tick.
+		if trace.timeEnd != trace.timeStart {
+			break
+		}
+		osyield()
+	}
+}
+
+// traceAppendFooter appends the events that end a generation,
+// except for its stack table, to data: the frequency of the ticks
+// between trace.ticksStart and trace.ticksEnd and the IDs of the
+// timer goroutines.
+func traceAppendFooter(data []byte) []byte {
+	// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
+	freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
+	data = append(data, traceEvFrequency|0<<traceArgCountShift)
+	data = traceAppend(data, uint64(freq))
+	for i := range timers {
+		tb := &timers[i]
+		if tb.gp != nil {
+			data = append(data, traceEvTimerGoroutine|0<<traceArgCountShift)
+			data = traceAppend(data, uint64(tb.gp.goid))
+		}
+	}
+	return data
+}
+
+// traceAdvance ends the current generation of the trace and starts
+// a new one, without stopping the tracer, so that the trace read
+// so far can be parsed on its own. It does nothing if tracing is
+// not enabled.
+//
+// The trace data of the new generation begins with a trace header,
+// which ReadTrace returns in a chunk of its own.
+func traceAdvance() {
+	// See the comments in StartTrace and StopTrace.
+	stopTheWorldGC("advance trace generation")
+	lock(&trace.bufLock)
+
+	if !trace.enabled {
+		unlock(&trace.bufLock)
+		startTheWorldGC()
+		return
+	}
+
+	// End the current generation. Tracing stays enabled
+	// throughout, so that the Ms without a P, which block on
+	// trace.bufLock, write their events to one generation or the
+	// other, and the events of this goroutine, which might allocate,
+	// go to the next generation.
+	traceGoSched()
+	traceReadCPU()
+	traceQueueBuffers()
+	traceSetEnd()
+	footer := traceAppendFooter(nil)
+	bufp := traceFlush(0, 0)
+	buf := bufp.ptr()
+	buf.pos += copy(buf.arr[buf.pos:], footer)
+	lock(&trace.lock)
+	traceFullQueue(bufp)
+	unlock(&trace.lock)
+	trace.stackTab.dump()
+
+	// Start the next generation with its own header.
+	lock(&trace.lock)
+	if trace.empty != 0 {
+		bufp = trace.empty
+		trace.empty = bufp.ptr().link
+	} else {
+		bufp = traceBufPtr(sysAlloc(unsafe.Sizeof(traceBuf{}), &memstats.other_sys))
+		if bufp == 0 {
+			throw("trace: out of memory")
+		}
+	}
+	buf = bufp.ptr()
+	buf.pos = copy(buf.arr[:], traceHeader)
+	traceFullQueue(bufp)
+	unlock(&trace.lock)
+	traceStartGeneration()
+
+	unlock(&trace.bufLock)
+	startTheWorldGC()
+}
+
+// traceHeader is the header that begins each generation of the trace.
+// Each change to the layout of the events gets a new version: 1.14 split
+// traces into generations and numbered the batches, and 1.15 added the
+// GoLabels and CPUSample events.
+const traceHeader = "go 1.15 trace\x00\x00\x00"
+
 // ReadTrace returns the next chunk of binary tracing data, blocking until data
 // is available. If tracing is turned off and all the data accumulated while it
 // was on has been returned, ReadTrace returns nil. The caller must copy the
@@ -390,7 +550,11 @@ func ReadTrace() []byte {
 		trace.headerWritten = true
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return []byte("go 1.11 trace\x00\x00\x00")
+		return []byte(traceHeader)
+	}
+	// Move pending CPU samples into the trace.
+	if trace.enabled {
+		traceReadCPU()
 	}
 	// Wait for new data.
 	if trace.fullHead == 0 && !trace.shutdown {
@@ -409,20 +573,9 @@ func ReadTrace() []byte {
 	// Write footer with timer frequency.
 	if !trace.footerWritten {
 		trace.footerWritten = true
-		// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
-		freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		var data []byte
-		data = append(data, traceEvFrequency|0<<traceArgCountShift)
-		data = traceAppend(data, uint64(freq))
-		for i := range timers {
-			tb := &timers[i]
-			if tb.gp != nil {
-				data = append(data, traceEvTimerGoroutine|0<<traceArgCountShift)
-				data = traceAppend(data, uint64(tb.gp.goid))
-			}
-		}
+		data := traceAppendFooter(nil)
 		// This will emit a bunch of full buffers, we will pick them up
 		// on the next iteration.
 		trace.stackTab.dump()
@@ -465,16 +618,20 @@ func traceReader() *g {
 	return gp
 }
 
-// traceProcFree frees trace buffer associated with pp.
-func traceProcFree(pp *p) {
-	buf := pp.tracebuf
-	pp.tracebuf = 0
-	if buf == 0 {
-		return
+// traceMExit queues the trace buffer of mp, which is exiting.
+// The events that mp writes after this go to the global buffer,
+// still in batches of its P.
+func traceMExit(mp *m) {
+	lock(&trace.bufLock)
+	mp.traceexited = true
+	buf := mp.tracebuf
+	mp.tracebuf = 0
+	if buf != 0 {
+		lock(&trace.lock)
+		traceFullQueue(buf)
+		unlock(&trace.lock)
 	}
-	lock(&trace.lock)
-	traceFullQueue(buf)
-	unlock(&trace.lock)
+	unlock(&trace.bufLock)
 }
 
 // traceFullQueue queues buf into queue of full buffers.
@@ -531,6 +688,11 @@ func traceEvent(ev byte, skip int, args ...uint64) {
 		}
 	}
 	traceEventLocked(0, mp, pid, bufp, ev, skip, args...)
+	if ev == traceEvProcStop || ev == traceEvGoSysCall {
+		// Another M may write the next events of the P, so
+		// the next event of this M must start a new batch.
+		bufp.ptr().pid = traceNoProc
+	}
 	traceReleaseBuffer(pid)
 }
 
@@ -538,9 +700,8 @@ func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev by
 	buf := bufp.ptr()
 	// TODO: test on non-zero extraBytes param.
 	maxSize := 2 + 5*traceBytesPerNumber + extraBytes // event type, length, sequence, timestamp, stack id and two add params
-	if buf == nil || len(buf.arr)-buf.pos < maxSize {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
-		bufp.set(buf)
+	if buf == nil || buf.pid != pid || len(buf.arr)-buf.pos < maxSize {
+		buf = traceBatch(bufp, pid, maxSize)
 	}
 
 	ticks := uint64(cputicks()) / traceTickDiv
@@ -605,19 +766,25 @@ func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
 // traceAcquireBuffer returns trace buffer to use and, if necessary, locks it.
 func traceAcquireBuffer() (mp *m, pid int32, bufp *traceBufPtr) {
 	mp = acquirem()
-	if p := mp.p.ptr(); p != nil {
-		return mp, p.id, &p.tracebuf
+	p := mp.p.ptr()
+	if p != nil && !mp.traceexited {
+		return mp, p.id, &mp.tracebuf
 	}
 	lock(&trace.bufLock)
+	if p != nil {
+		// mp is exiting and has flushed its buffer (see traceMExit).
+		return mp, p.id, &trace.buf
+	}
 	return mp, traceGlobProc, &trace.buf
 }
 
 // traceReleaseBuffer releases a buffer previously acquired with traceAcquireBuffer.
 func traceReleaseBuffer(pid int32) {
-	if pid == traceGlobProc {
+	mp := getg().m
+	if pid == traceGlobProc || mp.traceexited {
 		unlock(&trace.bufLock)
 	}
-	releasem(getg().m)
+	releasem(mp)
 }
 
 // traceFlush puts buf onto stack of full buffers and returns an empty buffer.
@@ -642,13 +809,7 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	bufp := buf.ptr()
 	bufp.link.set(nil)
 	bufp.pos = 0
-
-	// initialize the buffer for a new batch
-	ticks := uint64(cputicks()) / traceTickDiv
-	bufp.lastTicks = ticks
-	bufp.byte(traceEvBatch | 1<<traceArgCountShift)
-	bufp.varint(uint64(pid))
-	bufp.varint(ticks)
+	bufp.batch(pid)
 
 	if dolock {
 		unlock(&trace.lock)
@@ -656,6 +817,38 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	return buf
 }
 
+// traceBatchSize is the maximum size of the event that starts a batch.
+const traceBatchSize = 1 + 3*traceBytesPerNumber
+
+// traceBatch makes *bufp a buffer with room for size more bytes in
+// a batch of events of pid, and returns it. If *bufp holds a batch of
+// another P, a new batch is started in place if there is room for it.
+// Otherwise the buffer is flushed.
+func traceBatch(bufp *traceBufPtr, pid int32, size int) *traceBuf {
+	buf := bufp.ptr()
+	if buf != nil && buf.pid == pid && len(buf.arr)-buf.pos >= size {
+		return buf
+	}
+	if buf != nil && buf.pid != pid && len(buf.arr)-buf.pos >= traceBatchSize+size {
+		buf.batch(pid)
+		return buf
+	}
+	buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+	bufp.set(buf)
+	return buf
+}
+
+// batch starts a new batch of events of pid in buf.
+func (buf *traceBuf) batch(pid int32) {
+	ticks := uint64(cputicks()) / traceTickDiv
+	buf.lastTicks = ticks
+	buf.pid = pid
+	buf.byte(traceEvBatch | 2<<traceArgCountShift)
+	buf.varint(uint64(pid))
+	buf.varint(ticks)
+	buf.varint(atomic.Xadd64(&trace.batchSeq, 1))
+}
+
 // traceString adds a string to the trace.strings and returns the id.
 func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr) {
 	if s == "" {
@@ -695,8 +888,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 	buf := bufp.ptr()
 	size := 1 + 2*traceBytesPerNumber + len(s)
 	if buf == nil || len(buf.arr)-buf.pos < size {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
-		bufp.set(buf)
+		buf = traceBatch(bufp, pid, size)
 	}
 	buf.byte(traceEvString)
 	buf.varint(id)
@@ -715,6 +907,120 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 	return id, bufp
 }
 
+// traceGoLabels records the profiler labels of gp.
+// The labels follow the event as a count and a key and value
+// string ID for each label.
+// A goroutine has no labels when it is created, or when tracing
+// starts, unless a traceEvGoLabels event follows its traceEvGoCreate.
+func traceGoLabels(gp *g) {
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+
+	// gp.labels is set by runtime/pprof and points to its labelMap.
+	var ids [2 * traceMaxLabels]uint64
+	n := 0
+	if labels := (*map[string]string)(gp.labels); labels != nil {
+		for k, v := range *labels {
+			if n == len(ids) {
+				break
+			}
+			ids[n], bufp = traceString(bufp, pid, k)
+			ids[n+1], bufp = traceString(bufp, pid, v)
+			n += 2
+		}
+	}
+
+	extraSpace := (1 + n) * traceBytesPerNumber
+	traceEventLocked(extraSpace, mp, pid, bufp, traceEvGoLabels, -1, uint64(gp.goid))
+	// traceEventLocked reserved extra space for the labels.
+	buf := bufp.ptr()
+	buf.varint(uint64(n / 2))
+	for _, id := range ids[:n] {
+		buf.varint(id)
+	}
+
+	traceReleaseBuffer(pid)
+}
+
+// traceCPUSample writes a CPU profiling sample of gp running on pp
+// to trace.cpuLogWrite. pp is nil if no P was running.
+// It is called from the SIGPROF handler and must not allocate
+// memory or acquire locks.
+//go:nowritebarrierrec
+func traceCPUSample(gp *g, pp *p, stk []uintptr) {
+	if !trace.enabled {
+		return
+	}
+
+	now := cputicks()
+	var hdr [2]uint64
+	if pp != nil {
+		hdr[0] = uint64(pp.id)<<1 | 1
+	} else {
+		hdr[0] = 2
+	}
+	if gp != nil {
+		hdr[1] = uint64(gp.goid)
+	}
+
+	// Simple cas-lock to coordinate with StopTrace.
+	for !atomic.Cas(&trace.signalLock, 0, 1) {
+		osyield()
+	}
+	if log := (*profBuf)(atomic.Loadp(unsafe.Pointer(&trace.cpuLogWrite))); log != nil {
+		log.write(nil, now, hdr[:], stk)
+	}
+	atomic.Store(&trace.signalLock, 0)
+}
+
+// traceReadCPU moves the CPU profiling samples written to
+// trace.cpuLogRead so far into the trace. It does not wait
+// for more samples.
+// The caller must hold trace.lock or have stopped the world.
+func traceReadCPU() {
+	bufp := &trace.cpuLogBuf
+	for {
+		data, tags, _ := trace.cpuLogRead.read(profBufNonBlocking)
+		if len(data) == 0 {
+			break
+		}
+		for len(data) > 0 {
+			if len(data) < 4 || data[0] < 4 || data[0] > uint64(len(data)) || len(tags) < 1 {
+				break // malformed record
+			}
+			ticks := data[1]
+			pid := data[2] >> 1
+			if data[2]&1 == 0 {
+				pid = ^uint64(0) // no P
+			}
+			goid := data[3]
+			stk := data[4:data[0]]
+			overflow := data[2] == 0 && data[3] == 0 && len(stk) == 1
+			data = data[data[0]:]
+			tags = tags[1:]
+			if overflow {
+				// Samples lost because the buffer was full.
+				continue
+			}
+
+			buf := bufp.ptr()
+			if buf == nil {
+				buf = traceFlush(0, traceGlobProc).ptr()
+				bufp.set(buf)
+			}
+			n := 0
+			for ; n < len(stk) && n < len(buf.stk); n++ {
+				buf.stk[n] = uintptr(stk[n])
+			}
+			stackID := trace.stackTab.put(buf.stk[:n])
+			traceEventLocked(0, nil, traceGlobProc, bufp, traceEvCPUSample, -1, ticks/traceTickDiv, pid, goid, uint64(stackID))
+		}
+	}
+}
+
 // traceAppend appends v to buf in little-endian-base-128 encoding.
 func traceAppend(buf []byte, v uint64) []byte {
 	for ; v >= 0x80; v >>= 7 {
@@ -1051,6 +1357,10 @@ func traceGoCreate(newg *g, pc uintptr) {
 	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
 	id := trace.stackTab.put([]uintptr{pc + sys.PCQuantum})
 	traceEvent(traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	// The new goroutine inherited the profiler labels of its creator.
+	if newg.labels != nil {
+		traceGoLabels(newg)
+	}
 }
 
 func traceGoStart() {
@@ -1149,7 +1459,12 @@ func traceNextGC() {
 }
 
 // To access runtime functions from runtime/trace.
-// See runtime/trace/annotation.go
+// See runtime/trace/annotation.go and runtime/trace/trace.go
+
+//go:linkname trace_advance runtime/trace.advance
+func trace_advance() {
+	traceAdvance()
+}
 
 //go:linkname trace_userTaskCreate runtime/trace.userTaskCreate
 func trace_userTaskCreate(id, parentID uint64, taskType string) {
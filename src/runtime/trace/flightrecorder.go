@@ -0,0 +1,278 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FlightRecorderConfig is the configuration of a FlightRecorder.
+type FlightRecorderConfig struct {
+	// MinAge is the minimum age of the trace data the flight recorder
+	// keeps. Data younger than MinAge is always kept, unless that
+	// would exceed MaxBytes. If MinAge is zero, it defaults to
+	// 10 seconds.
+	MinAge time.Duration
+
+	// MaxBytes is the maximum size of the trace data the flight
+	// recorder keeps. It takes precedence over MinAge, except that
+	// the most recent complete generation of trace data is always
+	// kept. If MaxBytes is zero, it defaults to 10 MiB.
+	MaxBytes uint64
+}
+
+// A FlightRecorder keeps a window of recent trace data in memory,
+// so that the moments before an interesting event, such as a latency
+// spike, can be written out after the fact, without writing a trace
+// of the whole execution.
+//
+// The flight recorder traces in generations of about a second each.
+// Every generation is a complete execution trace: at its start, the
+// tracer records the state of every goroutine, and at its end, the
+// stack and string tables it used. The flight recorder discards the
+// oldest generations once the remaining ones cover the configured
+// minimum age, or exceed the configured size. Ending a generation
+// and starting the next stops the world briefly, but the tracer
+// keeps running, so no events are lost between generations.
+//
+// The data written by WriteTo is a sequence of generations, each with
+// its own trace header, that can be read by 'go tool trace'.
+type FlightRecorder struct {
+	cfg FlightRecorderConfig
+
+	// cutMu serializes the ends of generations,
+	// so that cuts and ended can be compared.
+	cutMu sync.Mutex
+
+	mu      sync.Mutex
+	cond    sync.Cond     // signaled when a generation ends or reading stops
+	running bool          // between Start and Stop
+	gens    []*generation // complete generations, oldest first
+	size    uint64        // total size of gens
+	cuts    int           // generations ended by cut
+	ended   int           // generations read completely
+	read    bool          // all the trace data has been read
+	stop    chan struct{} // closed to stop run
+	done    chan struct{} // closed when run returns
+	readEnd chan struct{} // closed when the reader returns
+
+	writing int32 // accessed atomically; WriteTo is in progress
+}
+
+// A generation is the trace data of one generation.
+type generation struct {
+	start time.Time
+	data  []byte
+}
+
+// NewFlightRecorder returns a new flight recorder with the given
+// configuration. The flight recorder does not record anything
+// until it is started.
+func NewFlightRecorder(cfg FlightRecorderConfig) *FlightRecorder {
+	if cfg.MinAge == 0 {
+		cfg.MinAge = 10 * time.Second
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 10 << 20
+	}
+	r := &FlightRecorder{cfg: cfg}
+	r.cond.L = &r.mu
+	return r
+}
+
+// Start starts recording trace data.
+// Start returns an error if tracing is already enabled,
+// either by Start or by another flight recorder.
+func (r *FlightRecorder) Start() error {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return errors.New("flight recorder already started")
+	}
+	if err := runtime.StartTrace(); err != nil {
+		return err
+	}
+	r.running = true
+	r.gens = nil
+	r.size = 0
+	r.cuts = 0
+	r.ended = 0
+	r.read = false
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	r.readEnd = make(chan struct{})
+	go r.readTrace(r.readEnd)
+	go r.run(r.stop, r.done)
+	tracing.recorder = r
+	atomic.StoreInt32(&tracing.enabled, 1)
+	return nil
+}
+
+// Stop stops recording trace data and discards the data recorded.
+func (r *FlightRecorder) Stop() {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = false
+	stop, done, readEnd := r.stop, r.done, r.readEnd
+	r.mu.Unlock()
+
+	close(stop)
+	<-done
+
+	tracing.Lock()
+	runtime.StopTrace()
+	<-readEnd
+	atomic.StoreInt32(&tracing.enabled, 0)
+	tracing.recorder = nil
+	tracing.Unlock()
+
+	r.mu.Lock()
+	r.gens = nil
+	r.size = 0
+	r.mu.Unlock()
+}
+
+// Enabled reports whether the flight recorder is recording.
+func (r *FlightRecorder) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// WriteTo ends the current generation of trace data and writes all
+// the data the flight recorder has kept to w. It returns the number
+// of bytes written and the first error encountered, if any.
+// Only one call to WriteTo may be in progress at a time.
+func (r *FlightRecorder) WriteTo(w io.Writer) (n int64, err error) {
+	if !atomic.CompareAndSwapInt32(&r.writing, 0, 1) {
+		return 0, errors.New("call to WriteTo for FlightRecorder already in progress")
+	}
+	defer atomic.StoreInt32(&r.writing, 0)
+
+	if !r.Enabled() {
+		return 0, errors.New("cannot write trace data of a flight recorder that is not running")
+	}
+
+	// End the current generation, so that the most recent
+	// events are written too, and wait until it is read.
+	cut := r.cut()
+	r.mu.Lock()
+	for r.ended < cut && !r.read {
+		r.cond.Wait()
+	}
+	if r.ended < cut {
+		r.mu.Unlock()
+		return 0, errors.New("flight recorder stopped during WriteTo")
+	}
+	// Complete generations are never modified, so they
+	// can be written without holding the lock.
+	gens := append([]*generation(nil), r.gens...)
+	r.mu.Unlock()
+
+	for _, g := range gens {
+		m, err := w.Write(g.data)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// cut ends the current generation and returns
+// the number of generations ended so far.
+func (r *FlightRecorder) cut() int {
+	r.cutMu.Lock()
+	defer r.cutMu.Unlock()
+	advance()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cuts++
+	return r.cuts
+}
+
+// readTrace reads the trace data until the tracer is stopped,
+// adding each generation as it is completed, and closes readEnd.
+// The runtime returns the header that begins each generation
+// in a chunk of its own; it is the same for all generations.
+func (r *FlightRecorder) readTrace(readEnd chan struct{}) {
+	defer close(readEnd)
+
+	var header string
+	g := &generation{start: time.Now()}
+	for {
+		data := runtime.ReadTrace()
+		if data == nil {
+			break
+		}
+		if header == "" {
+			header = string(data)
+		} else if string(data) == header {
+			r.add(g)
+			g = &generation{start: time.Now()}
+		}
+		g.data = append(g.data, data...)
+	}
+
+	// The last generation is incomplete; the flight recorder
+	// is stopped and discards it.
+	r.mu.Lock()
+	r.read = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// run ends a generation periodically until stop is closed.
+// It closes done when it returns.
+func (r *FlightRecorder) run(stop, done chan struct{}) {
+	defer close(done)
+
+	period := generationPeriod
+	if r.cfg.MinAge < period {
+		period = r.cfg.MinAge
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.cut()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// add adds the complete generation g and discards old generations
+// that are no longer needed.
+func (r *FlightRecorder) add(g *generation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gens = append(r.gens, g)
+	r.size += uint64(len(g.data))
+	r.ended++
+	r.cond.Broadcast()
+
+	// The generations after the oldest one cover the minimum age
+	// if the second oldest began at least MinAge ago.
+	oldest := time.Now().Add(-r.cfg.MinAge)
+	for len(r.gens) > 1 && (!r.gens[1].start.After(oldest) || r.size > r.cfg.MaxBytes) {
+		r.size -= uint64(len(r.gens[0].data))
+		r.gens[0] = nil
+		r.gens = r.gens[1:]
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"internal/trace"
+	"io/ioutil"
+	. "runtime/trace"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Minute})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+	if !fr.Enabled() || !IsEnabled() {
+		t.Fatalf("flight recorder not enabled after Start")
+	}
+	if err := Start(ioutil.Discard); err == nil {
+		Stop()
+		t.Fatalf("Start succeeded while flight recorder is running")
+	}
+	Stop() // must not stop the flight recorder
+
+	// Run for a few generations, then do something to look for.
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(done)
+	// End a generation, so that the region is in the next one.
+	if _, err := fr.WriteTo(ioutil.Discard); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	WithRegion(context.Background(), "flightRegion", func() {})
+
+	var buf bytes.Buffer
+	if _, err := fr.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte(" trace\x00")); n < 2 {
+		t.Errorf("flight recorder wrote %d generations, want at least 2", n)
+	}
+	saveTrace(t, &buf, "TestFlightRecorder")
+	events, _ := parseTrace(t, &buf)
+	found := false
+	for _, ev := range events {
+		if ev.Type == trace.EvUserRegion && ev.SArgs[0] == "flightRegion" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("region in the last generation not found in flight recorder data")
+	}
+
+	fr.Stop()
+	if fr.Enabled() || IsEnabled() {
+		t.Fatalf("flight recorder enabled after Stop")
+	}
+	if _, err := fr.WriteTo(&buf); err == nil {
+		t.Fatalf("WriteTo succeeded after Stop")
+	}
+}
+
+func TestFlightRecorderNoGaps(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Minute})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+
+	// Log numbered events while generations end,
+	// and check that none of them is lost.
+	done := make(chan bool)
+	logged := make(chan int)
+	go func() {
+		i := 0
+		for {
+			select {
+			case <-done:
+				logged <- i
+				return
+			default:
+				Log(context.Background(), "seq", strconv.Itoa(i))
+				i++
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if _, err := fr.WriteTo(ioutil.Discard); err != nil {
+			t.Fatalf("WriteTo failed: %v", err)
+		}
+	}
+	close(done)
+	n := <-logged
+
+	var buf bytes.Buffer
+	if _, err := fr.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if g := bytes.Count(buf.Bytes(), []byte(" trace\x00")); g < 6 {
+		t.Errorf("flight recorder wrote %d generations, want at least 6", g)
+	}
+	events, _ := parseTrace(t, &buf)
+	next := 0
+	for _, ev := range events {
+		if ev.Type != trace.EvUserLog || ev.SArgs[0] != "seq" {
+			continue
+		}
+		if ev.SArgs[1] != strconv.Itoa(next) {
+			t.Fatalf("found log message %s, want %d", ev.SArgs[1], next)
+		}
+		next++
+	}
+	if next != n {
+		t.Errorf("found %d log messages, want %d", next, n)
+	}
+}
+
+func TestFlightRecorderMaxBytes(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: 20 * time.Millisecond, MaxBytes: 1})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// Only the most recent generation is kept.
+	var buf bytes.Buffer
+	if _, err := fr.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte(" trace\x00")); n != 1 {
+		t.Errorf("flight recorder wrote %d generations, want 1", n)
+	}
+	parseTrace(t, &buf)
+}
+
+func TestFlightRecorderConcurrentWriteTo(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: 20 * time.Millisecond})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				var buf bytes.Buffer
+				if _, err := fr.WriteTo(&buf); err != nil {
+					// Another WriteTo is in progress.
+					continue
+				}
+				if _, err := trace.Parse(&buf, ""); err != nil && err != trace.ErrTimeOrder {
+					t.Errorf("failed to parse trace: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	fr.Stop()
+
+	// The flight recorder can be started again.
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to restart flight recorder: %v", err)
+	}
+	fr.Stop()
+}
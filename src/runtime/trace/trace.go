@@ -14,6 +14,11 @@
 // captured for most events. The generated trace can be interpreted
 // using `go tool trace`.
 //
+// The trace also records the profiler labels of goroutines, set with
+// runtime/pprof.Do or SetGoroutineLabels, and, while CPU profiling
+// is on, the CPU profile samples, so that `go tool trace` can build
+// CPU profiles of tasks and of goroutines with given labels.
+//
 // Support for tracing tests and benchmarks built with the standard
 // testing package is built into `go test`. For example, the following
 // command runs the test in the current directory and writes the trace
@@ -34,6 +39,10 @@
 // See the net/http/pprof package for more details about all of the
 // debug endpoints installed by this import.
 //
+// A long-running program can instead keep only the most recent trace
+// data in memory with a FlightRecorder, and write it out when something
+// interesting happens.
+//
 // User annotation
 //
 // Package trace provides user annotation APIs that can be used to
@@ -112,11 +121,16 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Start enables tracing for the current program.
 // While tracing, the trace will be buffered and written to w.
 // Start returns an error if tracing is already enabled.
+//
+// The trace is written in generations of about a second each, like
+// the data of a FlightRecorder, so that tools can read a long trace
+// one generation at a time.
 func Start(w io.Writer) error {
 	tracing.Lock()
 	defer tracing.Unlock()
@@ -133,21 +147,60 @@ func Start(w io.Writer) error {
 			w.Write(data)
 		}
 	}()
+	tracing.stop = make(chan struct{})
+	tracing.done = make(chan struct{})
+	go advanceGenerations(tracing.stop, tracing.done)
 	atomic.StoreInt32(&tracing.enabled, 1)
 	return nil
 }
 
+// advanceGenerations ends a generation of the trace every
+// generationPeriod until stop is closed. It closes done when it returns.
+func advanceGenerations(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(generationPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			advance()
+		case <-stop:
+			return
+		}
+	}
+}
+
 // Stop stops the current tracing, if any.
 // Stop only returns after all the writes for the trace have completed.
+// Stop does not stop a FlightRecorder; use its Stop method.
 func Stop() {
 	tracing.Lock()
 	defer tracing.Unlock()
+	if tracing.recorder != nil {
+		return
+	}
 	atomic.StoreInt32(&tracing.enabled, 0)
 
+	if tracing.stop != nil {
+		close(tracing.stop)
+		<-tracing.done
+		tracing.stop, tracing.done = nil, nil
+	}
 	runtime.StopTrace()
 }
 
 var tracing struct {
-	sync.Mutex       // gate mutators (Start, Stop)
-	enabled    int32 // accessed via atomic
+	sync.Mutex                 // gate mutators (Start, Stop)
+	enabled    int32           // accessed via atomic
+	recorder   *FlightRecorder // running flight recorder, if any
+	stop, done chan struct{}   // stop advanceGenerations, and wait for it
 }
+
+// generationPeriod is the duration of a generation of the trace.
+const generationPeriod = time.Second
+
+// advance ends the current generation of the trace and starts a new
+// one, as described in the documentation of FlightRecorder. It does
+// nothing if tracing is not enabled.
+// The function body is defined in runtime/trace.go.
+func advance()
@@ -6,6 +6,7 @@ package trace_test
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"internal/race"
 	"internal/trace"
@@ -14,6 +15,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"runtime/pprof"
 	. "runtime/trace"
 	"strconv"
 	"sync"
@@ -138,6 +140,121 @@ func TestTrace(t *testing.T) {
 	}
 }
 
+func TestTraceGenerations(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	done := make(chan bool)
+	go func() {
+		// Keep some goroutines busy across the ends of generations.
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	// Generations are about a second long.
+	time.Sleep(1500 * time.Millisecond)
+	close(done)
+	Stop()
+	saveTrace(t, buf, "TestTraceGenerations")
+	if n := bytes.Count(buf.Bytes(), []byte("go 1.15 trace\x00")); n < 2 {
+		t.Fatalf("got %d generations, want at least 2", n)
+	}
+	parseTrace(t, buf)
+}
+
+func TestTraceLabelsAndCPUSamples(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	// A goroutine with labels that exists before tracing starts.
+	ready := make(chan bool)
+	done := make(chan bool)
+	pprof.Do(context.Background(), pprof.Labels("before", "start"), func(context.Context) {
+		go func() {
+			ready <- true
+			<-done
+		}()
+	})
+	<-ready
+	defer close(done)
+
+	if err := pprof.StartCPUProfile(ioutil.Discard); err != nil {
+		t.Skipf("failed to start CPU profiling: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		pprof.StopCPUProfile()
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	var goid uint64
+	pprof.Do(context.Background(), pprof.Labels("request", "a"), func(context.Context) {
+		// Burn CPU so the goroutine is sampled.
+		for start := time.Now(); time.Since(start) < 500*time.Millisecond; {
+			cpuHog1(1e5)
+		}
+	})
+	Stop()
+	pprof.StopCPUProfile()
+	saveTrace(t, buf, "TestTraceLabelsAndCPUSamples")
+
+	events, _ := parseTrace(t, buf)
+	labels := make(map[uint64]map[string]string)
+	before, samples := false, 0
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvGoLabels:
+			m := make(map[string]string)
+			for i := 0; i+1 < len(ev.SArgs); i += 2 {
+				m[ev.SArgs[i]] = ev.SArgs[i+1]
+			}
+			labels[ev.G] = m
+			if m["before"] == "start" {
+				before = true
+			}
+			if m["request"] == "a" {
+				goid = ev.G
+			}
+		case trace.EvCPUSample:
+			if goid != 0 && ev.G == goid && labels[goid]["request"] == "a" {
+				samples++
+			}
+		}
+	}
+	if !before {
+		t.Errorf("labels of goroutine created before tracing started are not in the trace")
+	}
+	if goid == 0 {
+		t.Fatalf("labels set while tracing are not in the trace")
+	}
+	if samples == 0 {
+		t.Errorf("no CPU samples of goroutine %d with labels in the trace", goid)
+	}
+}
+
+func cpuHog1(n int) int {
+	x := 0
+	for i := 0; i < n; i++ {
+		if x%2 == 0 {
+			x = x*3 + i
+		} else {
+			x /= 2
+		}
+	}
+	return x
+}
+
 func parseTrace(t *testing.T, r io.Reader) ([]*trace.Event, map[uint64]*trace.GDesc) {
 	res, err := trace.Parse(r, "")
 	if err == trace.ErrTimeOrder {
@@ -26,6 +26,21 @@ type CoverBlock struct {
 	Stmts uint16 // Number of statements included in this block.
 }
 
+// CoverBranch records the coverage data for a single decision when
+// the coverage mode is "branch". A decision is either an operand of the
+// && and || operators in an if or for condition, which has two outcomes,
+// true and false, or a switch statement, which has one outcome per clause
+// (including an implicit default). The position fields are as in CoverBlock.
+// NOTE: This struct is internal to the testing infrastructure and may change.
+// It is not covered (yet) by the Go 1 compatibility guidelines.
+type CoverBranch struct {
+	Line0    uint32 // Line number for decision start.
+	Col0     uint16 // Column number for decision start.
+	Line1    uint32 // Line number for decision end.
+	Col1     uint16 // Column number for decision end.
+	Outcomes uint16 // Number of outcomes, counted consecutively in BranchCounters.
+}
+
 var cover Cover
 
 // Cover records information about test coverage checking.
@@ -35,6 +50,8 @@ type Cover struct {
 	Mode            string
 	Counters        map[string][]uint32
 	Blocks          map[string][]CoverBlock
+	BranchCounters  map[string][]uint32
+	Branches        map[string][]CoverBranch
 	CoveredPackages string
 }
 
@@ -112,5 +129,40 @@ func coverReport() {
 		fmt.Println("coverage: [no statements]")
 		return
 	}
-	fmt.Printf("coverage: %.1f%% of statements%s\n", 100*float64(active)/float64(total), cover.CoveredPackages)
+	branches := ""
+	if cover.Mode == "branch" {
+		branches = coverBranchReport(f)
+	}
+	fmt.Printf("coverage: %.1f%% of statements%s%s\n", 100*float64(active)/float64(total), branches, cover.CoveredPackages)
+}
+
+// coverBranchReport writes the branch lines of the coverage profile to f,
+// if f is not nil, and returns the branch coverage summary to be appended
+// to the statement coverage percentage.
+func coverBranchReport(f *os.File) string {
+	var taken, total int64
+	for name, branches := range cover.Branches {
+		counts := cover.BranchCounters[name]
+		k := 0
+		for _, b := range branches {
+			line := fmt.Sprintf("%s:%d.%d,%d.%d br", name, b.Line0, b.Col0, b.Line1, b.Col1)
+			for i := 0; i < int(b.Outcomes); i++ {
+				count := atomic.LoadUint32(&counts[k])
+				k++
+				total++
+				if count > 0 {
+					taken++
+				}
+				line += fmt.Sprintf(" %d", count)
+			}
+			if f != nil {
+				_, err := fmt.Fprintln(f, line)
+				mustBeNil(err)
+			}
+		}
+	}
+	if total == 0 {
+		return ", [no branches]"
+	}
+	return fmt.Sprintf(", %.1f%% of branches", 100*float64(taken)/float64(total))
 }
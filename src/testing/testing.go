@@ -290,6 +290,7 @@ func Init() {
 	mutexProfile = flag.String("test.mutexprofile", "", "write a mutex contention profile to the named file after execution")
 	mutexProfileFraction = flag.Int("test.mutexprofilefraction", 1, "if >= 0, calls runtime.SetMutexProfileFraction()")
 	traceFile = flag.String("test.trace", "", "write an execution trace to `file`")
+	goroutineLeak = flag.Bool("test.goroutineleak", false, "fail if tests leave leaked goroutines behind")
 	timeout = flag.Duration("test.timeout", 0, "panic test binary after duration `d` (default 0, timeout disabled)")
 	cpuListStr = flag.String("test.cpu", "", "comma-separated `list` of cpu counts to run each test with")
 	parallel = flag.Int("test.parallel", runtime.GOMAXPROCS(0), "run at most `n` tests in parallel")
@@ -316,6 +317,7 @@ var (
 	mutexProfile         *string
 	mutexProfileFraction *int
 	traceFile            *string
+	goroutineLeak        *bool
 	timeout              *time.Duration
 	cpuListStr           *string
 	parallel             *int
@@ -1120,7 +1122,7 @@ func (m *M) Run() int {
 	if !testRan && !exampleRan && *matchBenchmarks == "" {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 	}
-	if !testOk || !exampleOk || !runBenchmarks(m.deps.ImportPath(), m.deps.MatchString, m.benchmarks) || race.Errors() > 0 {
+	if !testOk || !exampleOk || !runBenchmarks(m.deps.ImportPath(), m.deps.MatchString, m.benchmarks) || race.Errors() > 0 || !m.checkGoroutineLeaks() {
 		fmt.Println("FAIL")
 		return 1
 	}
@@ -1129,6 +1131,23 @@ func (m *M) Run() int {
 	return 0
 }
 
+// checkGoroutineLeaks reports whether no goroutines have been leaked,
+// if requested by the -test.goroutineleak flag. Otherwise it prints
+// the stacks of the leaked goroutines.
+func (m *M) checkGoroutineLeaks() bool {
+	if !*goroutineLeak || runtime_detectGoroutineLeaks() == 0 {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "testing: tests leaked goroutines:\n")
+	if err := m.deps.WriteProfileTo("goroutineleak", os.Stderr, 1); err != nil {
+		fmt.Fprintf(os.Stderr, "testing: writing leaked goroutines: %s\n", err)
+	}
+	return false
+}
+
+// runtime_detectGoroutineLeaks is defined in runtime/mgcleak.go.
+func runtime_detectGoroutineLeaks() int
+
 func (t *T) report() {
 	if t.parent == nil {
 		return
@@ -0,0 +1,43 @@
+// run
+
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The linker can prune methods that are not directly called or
+// assigned to interfaces, but only keeps exported methods with a
+// name passed to MethodByName as a constant. Test it here.
+
+package main
+
+import "reflect"
+
+var called1, called2 = false, false
+
+type M int
+
+func (m M) UniqueMethodName1() {
+	called1 = true
+}
+
+func (m M) UniqueMethodName2() {
+	called2 = true
+}
+
+var v M
+
+func main() {
+	reflect.ValueOf(v).MethodByName("UniqueMethodName1").Interface().(func())()
+	if !called1 {
+		panic("UniqueMethodName1 not called")
+	}
+
+	m, ok := reflect.TypeOf(v).MethodByName("UniqueMethodName2")
+	if !ok {
+		panic("UniqueMethodName2 not found")
+	}
+	m.Func.Interface().(func(M))(v)
+	if !called2 {
+		panic("UniqueMethodName2 not called")
+	}
+}
@@ -0,0 +1,37 @@
+// run
+
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The linker can prune methods that are not directly called or
+// assigned to interfaces, but not if a method value of
+// reflect.Value.MethodByName is called. Test it here.
+
+package main
+
+import "reflect"
+
+var called = false
+
+type M int
+
+func (m M) UniqueMethodName() {
+	called = true
+}
+
+var v M
+
+var name = "UniqueMethodName"
+
+//go:noinline
+func byName(f func(string) reflect.Value, s string) reflect.Value {
+	return f(s)
+}
+
+func main() {
+	byName(reflect.ValueOf(v).MethodByName, name).Interface().(func())()
+	if !called {
+		panic("UniqueMethodName not called")
+	}
+}
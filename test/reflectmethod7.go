@@ -0,0 +1,63 @@
+// run
+
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The linker can prune methods that are not directly called or
+// assigned to interfaces, but not if reflect.Value.MethodByName is
+// called through an interface, by a method expression or by
+// reflection with a name that is not constant. Test it here.
+
+package main
+
+import "reflect"
+
+var called1, called2, called3 = false, false, false
+
+type M int
+
+func (m M) UniqueMethodName1() {
+	called1 = true
+}
+
+func (m M) UniqueMethodName2() {
+	called2 = true
+}
+
+func (m M) UniqueMethodName3() {
+	called3 = true
+}
+
+var v M
+
+var name1, name2, name3 = "UniqueMethodName1", "UniqueMethodName2", "UniqueMethodName3"
+
+type byNamer interface {
+	MethodByName(string) reflect.Value
+}
+
+//go:noinline
+func call(f func(reflect.Value, string) reflect.Value, x reflect.Value, s string) reflect.Value {
+	return f(x, s)
+}
+
+func main() {
+	var n byNamer = reflect.ValueOf(v)
+	n.MethodByName(name1).Call(nil)
+	if !called1 {
+		panic("UniqueMethodName1 not called")
+	}
+
+	call(reflect.Value.MethodByName, reflect.ValueOf(v), name2).Call(nil)
+	if !called2 {
+		panic("UniqueMethodName2 not called")
+	}
+
+	byName := reflect.ValueOf(reflect.ValueOf(v)).MethodByName("MethodByName")
+	m := byName.Call([]reflect.Value{reflect.ValueOf(name3)})[0].Interface().(reflect.Value)
+	m.Call(nil)
+	if !called3 {
+		panic("UniqueMethodName3 not called")
+	}
+}